@@ -1,17 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"gokube/pkg/api"
 	"gokube/pkg/api/server"
+	"gokube/pkg/debug"
+	"gokube/pkg/logging"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
-	clientv3 "go.etcd.io/etcd/client/v3"
-
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +23,16 @@ var (
 	address        string
 	etcdPeerPort   int
 	etcdClientPort int
+	dataDir        string
+	preserveData   bool
+	compaction     time.Duration
+	defragment     time.Duration
+	maxReplicas    int32
+	bootstrapDir   string
+	clusterName    string
+	etcdConfig     storage.EtcdConfig
+	logOptions     = logging.DefaultOptions()
+	debugOptions   = debug.DefaultOptions("127.0.0.1:6060")
 )
 
 func main() {
@@ -36,6 +50,17 @@ func main() {
 	rootCmd.Flags().StringVar(&address, "address", ":8080", `The address to serve on (default ":8080")`)
 	rootCmd.Flags().IntVar(&etcdPeerPort, "etcd-peer-port", 0, `The port to start etcd peer on (default random port)`)
 	rootCmd.Flags().IntVar(&etcdClientPort, "etcd-client-port", 2379, `The port to start etcd client on (default 2379)`)
+	rootCmd.Flags().StringVar(&dataDir, "data-dir", "", `The directory the embedded etcd keeps its data in, so the cluster survives a restart (default a temporary directory removed at shutdown)`)
+	rootCmd.Flags().BoolVar(&preserveData, "preserve-data", true, `Keep --data-dir when shutting down; set to false to remove it`)
+	rootCmd.Flags().DurationVar(&compaction, "etcd-compaction-interval", storage.DefaultCompactionInterval, `How often the embedded etcd discards its old revisions; 0 never does`)
+	rootCmd.Flags().DurationVar(&defragment, "etcd-defrag-interval", storage.DefaultDefragmentInterval, `How often the embedded etcd gives the space of discarded revisions back to the disk; 0 never does`)
+	rootCmd.Flags().StringVar(&bootstrapDir, "bootstrap-manifest-dir", "", `A directory of node, pod and ReplicaSet manifests to create at startup, skipping the objects that exist`)
+	rootCmd.Flags().StringVar(&clusterName, "cluster-name", "", "The name of the cluster, whose keys are kept under /gokube/<name> in etcd so clusters can share one; every component of a cluster must be given the same name")
+	rootCmd.Flags().Int32Var(&maxReplicas, "max-replicas-per-replicaset", api.DefaultMaxReplicasPerReplicaSet, `The largest replica count accepted for a ReplicaSet`)
+
+	etcdConfig.AddFlags(rootCmd.Flags())
+	logOptions.AddFlags(rootCmd.Flags())
+	debugOptions.AddFlags(rootCmd.Flags())
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -44,29 +69,97 @@ func main() {
 }
 
 func runAPIServer() error {
+	if err := logging.Setup(logOptions); err != nil {
+		return err
+	}
+	if maxReplicas < 1 {
+		return fmt.Errorf("--max-replicas-per-replicaset must be at least 1, got %d", maxReplicas)
+	}
+	rootPrefix, err := storage.ClusterPrefix(clusterName)
+	if err != nil {
+		return err
+	}
+	// The API is not authenticated, so the debug endpoints must not be reachable through its port
+	if err := debugOptions.ValidateSeparateFrom(address); err != nil {
+		return err
+	}
+
+	debugServer, err := debug.Serve(debugOptions)
+	if err != nil {
+		return err
+	}
+	if debugServer != nil {
+		defer debugServer.Close()
+	}
+
 	// Create a channel to handle shutdown signals
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
 
-	// Start embedded etcd
-	etcdServer, port, err := storage.StartEmbeddedEtcdWithPort(etcdPeerPort, etcdClientPort)
-	if err != nil {
-		return fmt.Errorf("failed to start etcd: %v", err)
+	// Start embedded etcd, unless an external one is given
+	stopEtcd := func() {}
+	embedded := len(etcdConfig.Endpoints) == 0
+	if embedded {
+		etcdServer, port, err := storage.StartEmbeddedEtcdWithOptions(storage.EmbeddedEtcdOptions{
+			PeerPort:     etcdPeerPort,
+			ClientPort:   etcdClientPort,
+			Dir:          dataDir,
+			PreserveData: preserveData,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start etcd: %v", err)
+		}
+		stopEtcd = func() { storage.StopEmbeddedEtcd(etcdServer) }
+		etcdConfig.Endpoints = []string{fmt.Sprintf("localhost:%d", port)}
 	}
 
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   []string{fmt.Sprintf("http://localhost:%d", port)},
-		DialTimeout: 5 * time.Second,
-	})
+	cli, err := storage.NewEtcdClient(etcdConfig)
 	if err != nil {
+		stopEtcd()
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
 	defer cli.Close()
 
-	store := storage.NewEtcdStorage(cli)
+	// The embedded etcd is ours to maintain; an external one is maintained by whoever runs it
+	if embedded {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			storage.NewMaintainer(cli, compaction, defragment).Start(ctx)
+		}()
+		stop := stopEtcd
+		stopEtcd = func() {
+			cancel()
+			<-done
+			stop()
+		}
+	}
+
+	// etcd electing a leader or restarting is ridden out rather than failing the requests
+	store := storage.NewEtcdStorageWithRetry(cli, storage.DefaultRetryConfig, runtime.JSONCodec)
+	storageMetrics := storage.NewPrometheusStorageMetrics()
+	store.SetMetrics(storageMetrics)
+	store.SetRootPrefix(rootPrefix)
 	apiServer := server.NewAPIServer(store)
+	apiServer.RegisterMetrics(storageMetrics, store.WatchMetrics())
+	apiServer.SetMaxReplicasPerReplicaSet(maxReplicas)
+	if err := apiServer.EnsureDefaultNamespace(context.Background()); err != nil {
+		stopEtcd()
+		return err
+	}
+	if err := apiServer.MigrateLegacyReplicaSets(context.Background()); err != nil {
+		stopEtcd()
+		return err
+	}
+	if bootstrapDir != "" {
+		if _, err := apiServer.Bootstrap(context.Background(), bootstrapDir); err != nil {
+			stopEtcd()
+			return err
+		}
+	}
 
-	fmt.Printf("Starting API server on %s\n", address)
+	slog.Info("Starting API server", "address", address)
 
 	// Start the API server in a goroutine
 	errCh := make(chan error, 1)
@@ -77,11 +170,11 @@ func runAPIServer() error {
 	// Wait for either an error or shutdown signal
 	select {
 	case err := <-errCh:
-		storage.StopEmbeddedEtcd(etcdServer)
+		stopEtcd()
 		return err
 	case <-stopCh:
-		fmt.Println("\nReceived shutdown signal. Stopping services...")
-		storage.StopEmbeddedEtcd(etcdServer)
+		slog.Info("Received shutdown signal, stopping services")
+		stopEtcd()
 		return nil
 	}
 }
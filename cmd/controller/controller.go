@@ -3,21 +3,40 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/client/informers"
 	"gokube/pkg/controller"
+	"gokube/pkg/debug"
+	"gokube/pkg/logging"
 	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
 	"github.com/spf13/cobra"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 var (
 	apiServerURL string
 	etcdPort     int
+	resyncPeriod time.Duration
+	workers      int
+	maxReplicas  int32
+	eventTTL     time.Duration
+	clusterName  string
+	etcdConfig   storage.EtcdConfig
+	logOptions   = logging.DefaultOptions()
+	debugOptions = debug.DefaultOptions("127.0.0.1:6061")
+
+	// nodeMonitorGracePeriod and deadNodeTimeout are how long a silent node stays Ready, then NotReady
+	nodeMonitorGracePeriod time.Duration
+	deadNodeTimeout        time.Duration
 )
 
 func main() {
@@ -34,6 +53,19 @@ func main() {
 
 	rootCmd.Flags().StringVar(&apiServerURL, "api-server", "localhost:8080", "URL of the API server")
 	rootCmd.Flags().IntVar(&etcdPort, "etcd-port", 2379, "Port of the etcd server")
+	rootCmd.Flags().StringVar(&clusterName, "cluster-name", "", "The name of the cluster, whose keys are kept under /gokube/<name> in etcd so clusters can share one; every component of a cluster must be given the same name")
+	rootCmd.Flags().DurationVar(&resyncPeriod, "resync-period", controller.DefaultResyncPeriod, "How often to reconcile all ReplicaSets (minimum 100ms)")
+	rootCmd.Flags().IntVar(&workers, "workers", controller.DefaultWorkers, "Number of ReplicaSets reconciled in parallel (1-64)")
+	rootCmd.Flags().DurationVar(&eventTTL, "event-ttl", controller.DefaultEventTTL, "How long events are kept after they last happened")
+	rootCmd.Flags().DurationVar(&nodeMonitorGracePeriod, "node-monitor-grace-period", controller.DefaultNodeMonitorGracePeriod,
+		"How long a node may go without a heartbeat before it is marked NotReady")
+	rootCmd.Flags().DurationVar(&deadNodeTimeout, "dead-node-timeout", controller.DefaultDeadNodeTimeout,
+		"How long a node stays NotReady before it is removed with its pods")
+	rootCmd.Flags().Int32Var(&maxReplicas, "max-replicas-per-replicaset", api.DefaultMaxReplicasPerReplicaSet, "ReplicaSets above this replica count are not acted on")
+
+	etcdConfig.AddFlags(rootCmd.Flags())
+	logOptions.AddFlags(rootCmd.Flags())
+	debugOptions.AddFlags(rootCmd.Flags())
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -42,34 +74,109 @@ func main() {
 }
 
 func runController() error {
+	if err := logging.Setup(logOptions); err != nil {
+		return err
+	}
+
+	options := controller.Options{
+		ResyncPeriod: resyncPeriod,
+		Workers:      workers,
+		MaxReplicas:  maxReplicas,
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	if eventTTL <= 0 {
+		return fmt.Errorf("%w: event TTL must be positive, got %v", controller.ErrInvalidOptions, eventTTL)
+	}
+	rootPrefix, err := storage.ClusterPrefix(clusterName)
+	if err != nil {
+		return err
+	}
+	if nodeMonitorGracePeriod <= 0 || deadNodeTimeout <= 0 {
+		return fmt.Errorf("%w: node monitor grace period and dead node timeout must be positive, got %v and %v",
+			controller.ErrInvalidOptions, nodeMonitorGracePeriod, deadNodeTimeout)
+	}
+
+	debugServer, err := debug.Serve(debugOptions)
+	if err != nil {
+		return err
+	}
+	if debugServer != nil {
+		defer debugServer.Close()
+	}
+
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
 
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints: []string{fmt.Sprintf("localhost:%d", etcdPort)},
-	})
+	if len(etcdConfig.Endpoints) == 0 {
+		etcdConfig.Endpoints = []string{fmt.Sprintf("localhost:%d", etcdPort)}
+	}
+	cli, err := storage.NewEtcdClient(etcdConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
 	defer cli.Close()
 
-	// Create etcd storage instance
-	store := storage.NewEtcdStorage(cli)
+	// Create etcd storage instance, retrying while etcd elects a leader or restarts
+	store := storage.NewEtcdStorageWithRetry(cli, storage.DefaultRetryConfig, runtime.JSONCodec)
+	store.SetRootPrefix(rootPrefix)
 
 	// Initialize registries with the etcd storage
+	namespaceRegistry := registry.NewNamespaceRegistry(store)
 	rsRegistry := registry.NewReplicaSetRegistry(store)
+	rsRegistry.SetNamespaceRegistry(namespaceRegistry)
+	quotaRegistry := registry.NewResourceQuotaRegistry(store)
+	limitRangeRegistry := registry.NewLimitRangeRegistry(store)
+	serviceRegistry := registry.NewServiceRegistry(store)
 	podRegistry := registry.NewPodRegistry(store)
+	podRegistry.SetNamespaceRegistry(namespaceRegistry)
+	podRegistry.SetResourceQuotaRegistry(quotaRegistry)
+	podRegistry.SetLimitRangeRegistry(limitRangeRegistry)
+
+	rsController, err := controller.NewReplicaSetControllerWithOptions(rsRegistry, podRegistry, options)
+	if err != nil {
+		return fmt.Errorf("failed to create controller: %v", err)
+	}
 
-	rsController := controller.NewReplicaSetController(rsRegistry, podRegistry)
+	// The pods are read from a cache kept in sync with the API server rather than listed from etcd on every pass,
+	// and the changes watched on the API server trigger the passes
+	apiClient, err := client.New(apiServerURL, client.Options{})
+	if err != nil {
+		return fmt.Errorf("failed to create API server client: %v", err)
+	}
+	factory := informers.NewSharedInformerFactory(apiClient, informers.DefaultRelistPeriod)
+	rsController.UsePodInformer(factory.Pods())
+	rsController.UseReplicaSetInformer(factory.ReplicaSets())
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	go rsController.Start(ctx)
-
-	fmt.Println("Controller started successfully")
+	// Terminating namespaces are cleaned up, and the Endpoints recomputed, on the ReplicaSet resync period
+	namespaceController := controller.NewNamespaceController(namespaceRegistry, rsRegistry, podRegistry, quotaRegistry, limitRangeRegistry, serviceRegistry, resyncPeriod)
+	go namespaceController.Start(ctx)
+	endpointsController := controller.NewEndpointsController(serviceRegistry, registry.NewEndpointsRegistry(store), podRegistry,
+		registry.NewNodeRegistry(store), resyncPeriod)
+	go endpointsController.Start(ctx)
+	eventGCController := controller.NewEventGCController(registry.NewEventRegistry(store), eventTTL, min(eventTTL, controller.DefaultEventGCPeriod))
+	go eventGCController.Start(ctx)
+	nodeController := controller.NewNodeController(registry.NewNodeRegistry(store), podRegistry, nodeMonitorGracePeriod, deadNodeTimeout, resyncPeriod)
+	nodeController.SetLeaseRegistry(registry.NewNodeLeaseRegistry(store))
+	go nodeController.Start(ctx)
+	podIndexController := controller.NewPodIndexController(podRegistry, controller.DefaultPodIndexResyncPeriod)
+	go podIndexController.Start(ctx)
+
+	factory.Start(ctx)
+	go func() {
+		// Reconciling against an empty cache would create pods that already exist
+		if factory.WaitForCacheSync(ctx) {
+			rsController.Start(ctx)
+		}
+	}()
+
+	slog.Info("Controller started", "apiServer", apiServerURL, "workers", workers)
 
 	<-stopCh
-	fmt.Println("\nReceived shutdown signal. Stopping controller...")
+	slog.Info("Received shutdown signal, stopping controller")
 	return nil
 }
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gokube/pkg/kubectl"
+)
+
+func main() {
+	// Ctrl-C ends long-running commands such as get --watch cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := kubectl.NewCommand(os.Stdin, os.Stdout, os.Stderr).ExecuteContext(ctx); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, kubectl.FormatError(err))
+		stop()
+		os.Exit(1)
+	}
+}
@@ -1,17 +1,46 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"gokube/pkg/debug"
 	"gokube/pkg/kubelet"
+	"gokube/pkg/logging"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	nodeName     string
-	apiServerURL string
+	nodeName                  string
+	apiServerURL              string
+	nodeStatusUpdateFrequency time.Duration
+	nodeStatusReportFrequency time.Duration
+	nodeLeaseDurationSeconds  int32
+	relistPeriod              time.Duration
+	syncInterval              time.Duration
+	statusUpdateInterval      time.Duration
+	maxPods                   int32
+	maxParallelPodStarts      int
+	address                   string
+	port                      int
+	containerGCPeriod         time.Duration
+	maxDeadContainersPerPod   int
+	orphanGracePeriod         time.Duration
+	podManifestPath           string
+	fileCheckFrequency        time.Duration
+	registrationTimeout       time.Duration
+	evictionMemoryAvailable   int64
+	evictionDiskAvailable     int
+	dockerRootDir             string
+	logOptions                = logging.DefaultOptions()
+	debugOptions              = debug.DefaultOptions("127.0.0.1:6063")
 )
 
 func main() {
@@ -26,8 +55,30 @@ func main() {
 		},
 	}
 
-	rootCmd.Flags().StringVar(&nodeName, "node-name", "test", "The name of the node")
+	rootCmd.Flags().StringVar(&nodeName, "node-name", "", "The name of the node, defaults to the lowercased host name")
 	rootCmd.Flags().StringVar(&apiServerURL, "api-server-url", "localhost:8080", "The URL of the API server")
+	rootCmd.Flags().DurationVar(&nodeStatusUpdateFrequency, "node-status-update-frequency", kubelet.DefaultNodeStatusUpdateFrequency, "How often the kubelet checks the node status for changes to report to the API server")
+	rootCmd.Flags().DurationVar(&nodeStatusReportFrequency, "node-status-report-frequency", kubelet.DefaultNodeStatusReportFrequency, "How often the kubelet reports a node status that did not change while it renews the node lease")
+	rootCmd.Flags().Int32Var(&nodeLeaseDurationSeconds, "node-lease-duration-seconds", kubelet.DefaultNodeLeaseDurationSeconds, "How long the node lease lasts after the kubelet renewed it, 0 to report the node status on every check instead")
+	rootCmd.Flags().DurationVar(&relistPeriod, "relist-period", kubelet.DefaultRelistPeriod, "How often to list pods when the API server cannot watch them")
+	rootCmd.Flags().DurationVar(&syncInterval, "sync-interval", kubelet.DefaultSyncInterval, "How often to reconcile the pods of this node with their containers")
+	rootCmd.Flags().DurationVar(&statusUpdateInterval, "status-update-interval", kubelet.DefaultStatusUpdateInterval, "How often to report pod statuses that did not change, 0 to report only changes")
+	rootCmd.Flags().Int32Var(&maxPods, "max-pods", kubelet.DefaultMaxPods, "The number of pods this node advertises it can run")
+	rootCmd.Flags().StringVar(&address, "kubelet-address", kubelet.DefaultAddress, "The IP address the kubelet serves healthz, pods and container logs on")
+	rootCmd.Flags().IntVar(&port, "port", kubelet.DefaultPort, "The port the kubelet serves healthz, pods and container logs on")
+	rootCmd.Flags().DurationVar(&containerGCPeriod, "container-gc-period", kubelet.DefaultContainerGCPeriod, "How often to remove dead containers of pods that are gone")
+	rootCmd.Flags().IntVar(&maxDeadContainersPerPod, "maximum-dead-containers-per-pod", kubelet.DefaultMaxDeadContainersPerPod, "How many dead containers of a removed pod to keep for debugging")
+	rootCmd.Flags().DurationVar(&orphanGracePeriod, "orphan-grace-period", kubelet.DefaultOrphanGracePeriod, "How old a container must be before it is removed because its pod no longer exists")
+	rootCmd.Flags().StringVar(&podManifestPath, "pod-manifest-path", "", "A directory of pod manifests to run as static pods, even without an API server")
+	rootCmd.Flags().DurationVar(&fileCheckFrequency, "file-check-frequency", kubelet.DefaultFileCheckFrequency, "How often to check the pod manifest path for changes")
+	rootCmd.Flags().DurationVar(&registrationTimeout, "registration-timeout", kubelet.DefaultRegistrationTimeout, "How long to retry registering the node while the API server is unavailable, 0 to retry forever")
+	rootCmd.Flags().Int64Var(&evictionMemoryAvailable, "eviction-memory-available", 0, "Evict pods when less memory than this many bytes is available, 0 to disable")
+	rootCmd.Flags().IntVar(&evictionDiskAvailable, "eviction-disk-available-percent", 0, "Evict pods when less than this percentage of the Docker root dir disk is available, 0 to disable")
+	rootCmd.Flags().StringVar(&dockerRootDir, "docker-root-dir", kubelet.DefaultDockerRootDir, "The Docker root directory whose disk is watched for pressure")
+	rootCmd.Flags().IntVar(&maxParallelPodStarts, "max-parallel-pod-starts", kubelet.DefaultMaxParallelPodStarts, "How many pods may pull images and start containers at the same time")
+
+	logOptions.AddFlags(rootCmd.Flags())
+	debugOptions.AddFlags(rootCmd.Flags())
 
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -36,7 +87,50 @@ func main() {
 }
 
 func runKubelet() error {
-	k, err := kubelet.NewKubelet(nodeName, apiServerURL)
+	if err := logging.Setup(logOptions); err != nil {
+		return err
+	}
+
+	options := kubelet.Options{
+		NodeStatusUpdateFrequency:    nodeStatusUpdateFrequency,
+		NodeStatusReportFrequency:    nodeStatusReportFrequency,
+		NodeLeaseDurationSeconds:     nodeLeaseDurationSeconds,
+		RelistPeriod:                 relistPeriod,
+		SyncInterval:                 syncInterval,
+		StatusUpdateInterval:         statusUpdateInterval,
+		MaxPods:                      maxPods,
+		MaxParallelPodStarts:         maxParallelPodStarts,
+		Address:                      address,
+		Port:                         port,
+		ContainerGCPeriod:            containerGCPeriod,
+		MaxDeadContainersPerPod:      maxDeadContainersPerPod,
+		OrphanGracePeriod:            orphanGracePeriod,
+		PodManifestPath:              podManifestPath,
+		FileCheckFrequency:           fileCheckFrequency,
+		RegistrationTimeout:          registrationTimeout,
+		EvictionMemoryAvailable:      evictionMemoryAvailable,
+		EvictionDiskAvailablePercent: evictionDiskAvailable,
+		DockerRootDir:                dockerRootDir,
+	}
+
+	// The kubelet API is not authenticated, so the debug endpoints must not be reachable through its port
+	if err := debugOptions.ValidateSeparateFrom(net.JoinHostPort(address, strconv.Itoa(port))); err != nil {
+		return err
+	}
+	debugServer, err := debug.Serve(debugOptions)
+	if err != nil {
+		return err
+	}
+	if debugServer != nil {
+		defer debugServer.Close()
+	}
+
+	name, err := kubelet.ResolveNodeName(nodeName)
+	if err != nil {
+		return fmt.Errorf("invalid node name, set a valid one with --node-name: %v", err)
+	}
+
+	k, err := kubelet.NewKubeletWithOptions(name, apiServerURL, options)
 	if err != nil {
 		return fmt.Errorf("failed to create kubelet: %v", err)
 	}
@@ -45,6 +139,11 @@ func runKubelet() error {
 		return fmt.Errorf("failed to start kubelet: %v", err)
 	}
 
-	// Block forever
-	select {}
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+	<-stopCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return k.Stop(ctx)
 }
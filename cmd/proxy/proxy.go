@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gokube/pkg/client"
+	"gokube/pkg/client/informers"
+	"gokube/pkg/debug"
+	"gokube/pkg/logging"
+	"gokube/pkg/proxy"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	apiServerURL string
+	bindAddress  string
+	syncPeriod   time.Duration
+	logOptions   = logging.DefaultOptions()
+	debugOptions = debug.DefaultOptions("127.0.0.1:6064")
+)
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Start the gokube proxy, which forwards the Service ports of the node to the pods of the Services",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runProxy(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	rootCmd.Flags().StringVar(&apiServerURL, "api-server", "localhost:8080", "URL of the API server")
+	rootCmd.Flags().StringVar(&bindAddress, "bind-address", "0.0.0.0", "The IP address the Service ports are opened on")
+	rootCmd.Flags().DurationVar(&syncPeriod, "sync-period", proxy.DefaultSyncPeriod, "How often to resync the Service ports when nothing changed")
+
+	logOptions.AddFlags(rootCmd.Flags())
+	debugOptions.AddFlags(rootCmd.Flags())
+
+	if err := rootCmd.Execute(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runProxy() error {
+	if err := logging.Setup(logOptions); err != nil {
+		return err
+	}
+	if net.ParseIP(bindAddress) == nil {
+		return fmt.Errorf("bind address must be an IP address, got %q", bindAddress)
+	}
+	if syncPeriod <= 0 {
+		return fmt.Errorf("sync period must be positive, got %v", syncPeriod)
+	}
+
+	debugServer, err := debug.Serve(debugOptions)
+	if err != nil {
+		return err
+	}
+	if debugServer != nil {
+		defer debugServer.Close()
+	}
+
+	stopCh := make(chan os.Signal, 1)
+	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
+
+	apiClient, err := client.New(apiServerURL, client.Options{})
+	if err != nil {
+		return fmt.Errorf("failed to create API server client: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	factory := informers.NewSharedInformerFactory(apiClient, informers.DefaultRelistPeriod)
+	services, endpoints := factory.Services(), factory.Endpoints()
+	factory.Start(ctx)
+
+	proxier := proxy.NewProxier(bindAddress)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		proxier.Run(ctx, services, endpoints, syncPeriod)
+	}()
+
+	slog.Info("Proxy started", "apiServer", apiServerURL, "bindAddress", bindAddress)
+
+	<-stopCh
+	slog.Info("Received shutdown signal, stopping proxy")
+	cancel()
+	<-done
+	return nil
+}
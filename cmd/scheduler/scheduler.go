@@ -3,22 +3,29 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"gokube/pkg/debug"
+	"gokube/pkg/logging"
 	"gokube/pkg/registry"
+	"gokube/pkg/runtime"
 	"gokube/pkg/scheduler"
 	"gokube/pkg/storage"
 
 	"github.com/spf13/cobra"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 var (
 	etcdPort       int
 	schedulingRate time.Duration
+	clusterName    string
+	etcdConfig     storage.EtcdConfig
+	logOptions     = logging.DefaultOptions()
+	debugOptions   = debug.DefaultOptions("127.0.0.1:6062")
 )
 
 func main() {
@@ -34,8 +41,13 @@ func main() {
 	}
 
 	rootCmd.Flags().IntVar(&etcdPort, "etcd-port", 2379, "Port of the etcd server")
+	rootCmd.Flags().StringVar(&clusterName, "cluster-name", "", "The name of the cluster, whose keys are kept under /gokube/<name> in etcd so clusters can share one; every component of a cluster must be given the same name")
 	rootCmd.Flags().DurationVar(&schedulingRate, "scheduling-rate", 10*time.Second, "How often to run the scheduling loop")
 
+	etcdConfig.AddFlags(rootCmd.Flags())
+	logOptions.AddFlags(rootCmd.Flags())
+	debugOptions.AddFlags(rootCmd.Flags())
+
 	if err := rootCmd.Execute(); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -43,20 +55,38 @@ func main() {
 }
 
 func runScheduler() error {
+	if err := logging.Setup(logOptions); err != nil {
+		return err
+	}
+	rootPrefix, err := storage.ClusterPrefix(clusterName)
+	if err != nil {
+		return err
+	}
+
+	debugServer, err := debug.Serve(debugOptions)
+	if err != nil {
+		return err
+	}
+	if debugServer != nil {
+		defer debugServer.Close()
+	}
+
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
 
 	// Create etcd client
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints: []string{fmt.Sprintf("localhost:%d", etcdPort)},
-	})
+	if len(etcdConfig.Endpoints) == 0 {
+		etcdConfig.Endpoints = []string{fmt.Sprintf("localhost:%d", etcdPort)}
+	}
+	cli, err := storage.NewEtcdClient(etcdConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
 	defer cli.Close()
 
-	// Create etcd storage instance
-	store := storage.NewEtcdStorage(cli)
+	// Create etcd storage instance, retrying while etcd elects a leader or restarts
+	store := storage.NewEtcdStorageWithRetry(cli, storage.DefaultRetryConfig, runtime.JSONCodec)
+	store.SetRootPrefix(rootPrefix)
 
 	// Initialize registries with the etcd storage
 	podRegistry := registry.NewPodRegistry(store)
@@ -70,11 +100,9 @@ func runScheduler() error {
 
 	go sched.Start(ctx)
 
-	fmt.Printf("Scheduler started successfully\n")
-	fmt.Printf("Connected to etcd at localhost:%d\n", etcdPort)
-	fmt.Printf("Scheduling rate: %v\n", schedulingRate)
+	slog.Info("Scheduler started", "etcdPort", etcdPort, "schedulingRate", schedulingRate)
 
 	<-stopCh
-	fmt.Println("\nReceived shutdown signal. Stopping scheduler...")
+	slog.Info("Received shutdown signal, stopping scheduler")
 	return nil
 }
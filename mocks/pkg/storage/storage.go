@@ -12,6 +12,7 @@ package storage
 import (
 	context "context"
 	runtime "gokube/pkg/runtime"
+	storage "gokube/pkg/storage"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +42,21 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// Count mocks base method.
+func (m *MockStorage) Count(ctx context.Context, prefix string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx, prefix)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockStorageMockRecorder) Count(ctx, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockStorage)(nil).Count), ctx, prefix)
+}
+
 // Create mocks base method.
 func (m *MockStorage) Create(ctx context.Context, key string, obj runtime.Object) error {
 	m.ctrl.T.Helper()
@@ -83,6 +99,21 @@ func (mr *MockStorageMockRecorder) DeletePrefix(ctx, prefix any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePrefix", reflect.TypeOf((*MockStorage)(nil).DeletePrefix), ctx, prefix)
 }
 
+// Exists mocks base method.
+func (m *MockStorage) Exists(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockStorageMockRecorder) Exists(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockStorage)(nil).Exists), ctx, key)
+}
+
 // Get mocks base method.
 func (m *MockStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
 	m.ctrl.T.Helper()
@@ -111,6 +142,79 @@ func (mr *MockStorageMockRecorder) List(ctx, prefix, listObj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockStorage)(nil).List), ctx, prefix, listObj)
 }
 
+// ListFunc mocks base method.
+func (m *MockStorage) ListFunc(ctx context.Context, prefix string, newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFunc", ctx, prefix, newItem, appendItem)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListFunc indicates an expected call of ListFunc.
+func (mr *MockStorageMockRecorder) ListFunc(ctx, prefix, newItem, appendItem any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFunc", reflect.TypeOf((*MockStorage)(nil).ListFunc), ctx, prefix, newItem, appendItem)
+}
+
+// ListKeys mocks base method.
+func (m *MockStorage) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListKeys", ctx, prefix)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListKeys indicates an expected call of ListKeys.
+func (mr *MockStorageMockRecorder) ListKeys(ctx, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKeys", reflect.TypeOf((*MockStorage)(nil).ListKeys), ctx, prefix)
+}
+
+// ListPage mocks base method.
+func (m *MockStorage) ListPage(ctx context.Context, prefix string, options storage.ListOptions, listObj any) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPage", ctx, prefix, options, listObj)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPage indicates an expected call of ListPage.
+func (mr *MockStorageMockRecorder) ListPage(ctx, prefix, options, listObj any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPage", reflect.TypeOf((*MockStorage)(nil).ListPage), ctx, prefix, options, listObj)
+}
+
+// ListPageFunc mocks base method.
+func (m *MockStorage) ListPageFunc(ctx context.Context, prefix string, options storage.ListOptions, newItem func() runtime.Object, appendItem func(runtime.Object)) (storage.ListResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPageFunc", ctx, prefix, options, newItem, appendItem)
+	ret0, _ := ret[0].(storage.ListResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPageFunc indicates an expected call of ListPageFunc.
+func (mr *MockStorageMockRecorder) ListPageFunc(ctx, prefix, options, newItem, appendItem any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPageFunc", reflect.TypeOf((*MockStorage)(nil).ListPageFunc), ctx, prefix, options, newItem, appendItem)
+}
+
+// Txn mocks base method.
+func (m *MockStorage) Txn(ctx context.Context, ops []storage.TxnOp) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Txn", ctx, ops)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Txn indicates an expected call of Txn.
+func (mr *MockStorageMockRecorder) Txn(ctx, ops any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Txn", reflect.TypeOf((*MockStorage)(nil).Txn), ctx, ops)
+}
+
 // Update mocks base method.
 func (m *MockStorage) Update(ctx context.Context, key string, obj runtime.Object) error {
 	m.ctrl.T.Helper()
@@ -124,3 +228,33 @@ func (mr *MockStorageMockRecorder) Update(ctx, key, obj any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorage)(nil).Update), ctx, key, obj)
 }
+
+// Watch mocks base method.
+func (m *MockStorage) Watch(ctx context.Context, prefix string, newItem func() runtime.Object) (<-chan storage.ObjectEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx, prefix, newItem)
+	ret0, _ := ret[0].(<-chan storage.ObjectEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockStorageMockRecorder) Watch(ctx, prefix, newItem any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockStorage)(nil).Watch), ctx, prefix, newItem)
+}
+
+// WatchFrom mocks base method.
+func (m *MockStorage) WatchFrom(ctx context.Context, prefix string, revision int64, newItem func() runtime.Object) (<-chan storage.ObjectEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchFrom", ctx, prefix, revision, newItem)
+	ret0, _ := ret[0].(<-chan storage.ObjectEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchFrom indicates an expected call of WatchFrom.
+func (mr *MockStorageMockRecorder) WatchFrom(ctx, prefix, revision, newItem any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchFrom", reflect.TypeOf((*MockStorage)(nil).WatchFrom), ctx, prefix, revision, newItem)
+}
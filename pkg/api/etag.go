@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+)
+
+// MetaObject is an object with ObjectMeta, such as a Pod
+type MetaObject interface {
+	GetObjectMeta() *ObjectMeta
+}
+
+// GetObjectMeta returns the metadata, which makes the objects embedding ObjectMeta MetaObjects
+func (m *ObjectMeta) GetObjectMeta() *ObjectMeta {
+	return m
+}
+
+// ObjectETag returns the entity tag of an object, its quoted resource version, or "" if it has none
+func ObjectETag(obj MetaObject) string {
+	version := obj.GetObjectMeta().ResourceVersion
+	if version == "" {
+		return ""
+	}
+	return `"` + version + `"`
+}
+
+// ListETag returns the entity tag of a list of items selected by filter, such as the query of the request.
+// It hashes the filter with the names and resource versions of the items, so it changes when an item changes,
+// joins or leaves the list, but not when objects outside the list change. It is "" if an item has no resource
+// version.
+func ListETag[T MetaObject](filter string, items []T) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(filter))
+	for _, item := range items {
+		meta := item.GetObjectMeta()
+		if meta.ResourceVersion == "" {
+			return ""
+		}
+		_, _ = hash.Write([]byte("\x00" + meta.Namespace + "/" + meta.Name + "@" + meta.ResourceVersion))
+	}
+	return `"list-` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// WriteResponseWithETag writes entity with status 200 like WriteResponse, tagged with etag. A request whose
+// If-None-Match names etag already holds the entity, so it is answered 304 Not Modified without a body. An
+// empty etag writes the entity untagged.
+func WriteResponseWithETag(request *restful.Request, response *restful.Response, etag string, entity interface{}) {
+	if etag == "" {
+		WriteResponse(response, http.StatusOK, entity)
+		return
+	}
+	response.Header().Set("ETag", etag)
+	if matchesETag(request.HeaderParameter("If-None-Match"), etag) {
+		response.WriteHeader(http.StatusNotModified)
+		return
+	}
+	WriteResponse(response, http.StatusOK, entity)
+}
+
+// matchesETag checks if an If-None-Match header names etag, or any tag with *. Weak tags match their strong
+// counterpart, as GET requests compare tags weakly.
+func matchesETag(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
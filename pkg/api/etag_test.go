@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectETag(t *testing.T) {
+	assert.Equal(t, `"42"`, ObjectETag(&Pod{ObjectMeta: ObjectMeta{Name: "web", ResourceVersion: "42"}}))
+	assert.Equal(t, "", ObjectETag(&Pod{ObjectMeta: ObjectMeta{Name: "web"}}), "an object without a version is untagged")
+}
+
+func TestListETag(t *testing.T) {
+	pods := func(versions ...string) []*Pod {
+		var items []*Pod
+		for i, version := range versions {
+			items = append(items, &Pod{ObjectMeta: ObjectMeta{Name: string(rune('a' + i)), ResourceVersion: version}})
+		}
+		return items
+	}
+
+	etag := ListETag("", pods("1", "2"))
+	assert.Regexp(t, `^"list-[0-9a-f]{16}"$`, etag)
+	assert.Equal(t, etag, ListETag("", pods("1", "2")), "the same list has the same tag")
+	assert.NotEqual(t, etag, ListETag("", pods("1", "3")), "an item changed")
+	assert.NotEqual(t, etag, ListETag("", pods("1")), "an item left")
+	assert.NotEqual(t, etag, ListETag("", pods("1", "2", "3")), "an item joined")
+	assert.NotEqual(t, etag, ListETag("nodeName=node-1", pods("1", "2")), "another filter selected the items")
+	assert.NotEqual(t, ListETag("", pods()), ListETag("nodeName=node-1", pods()), "empty lists of different filters")
+	assert.Equal(t, "", ListETag("", pods("1", "")), "an item without a version leaves the list untagged")
+}
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{ifNoneMatch: "", want: false},
+		{ifNoneMatch: `"42"`, want: true},
+		{ifNoneMatch: `"41"`, want: false},
+		{ifNoneMatch: `W/"42"`, want: true},
+		{ifNoneMatch: `"40", "42"`, want: true},
+		{ifNoneMatch: `"40","41"`, want: false},
+		{ifNoneMatch: "*", want: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchesETag(tt.ifNoneMatch, `"42"`), tt.ifNoneMatch)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidEvent = errors.New("invalid event")
+)
+
+// Types of events: Normal events report expected changes, Warning events report problems
+const (
+	EventTypeNormal  = "Normal"
+	EventTypeWarning = "Warning"
+)
+
+const (
+	KindPod  = "Pod"
+	KindNode = "Node"
+)
+
+// ObjectReference points at the object an event is about
+type ObjectReference struct {
+	Kind string `json:"kind" validate:"required"`
+	Name string `json:"name" validate:"required"`
+	UID  string `json:"uid,omitempty"`
+}
+
+// Event records something that happened to an object, such as a pod being evicted from its node
+type Event struct {
+	ObjectMeta     `json:"metadata,omitempty"`
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	// Type is EventTypeNormal or EventTypeWarning
+	Type   string `json:"type" validate:"oneof=Normal Warning"`
+	Reason string `json:"reason" validate:"required"`
+	// Message is a human-readable description of what happened
+	Message string `json:"message,omitempty"`
+	// Source is the component that reported the event, such as the kubelet of a node
+	Source         string    `json:"source,omitempty"`
+	FirstTimestamp time.Time `json:"firstTimestamp,omitempty"`
+	LastTimestamp  time.Time `json:"lastTimestamp,omitempty"`
+	// Count is how many times the event happened between FirstTimestamp and LastTimestamp
+	Count int32 `json:"count,omitempty"`
+}
+
+// Validate checks that the event names the object it is about and why it was recorded. The name is
+// generated when the event is created, so it is not required.
+func (e *Event) Validate() error {
+	if err := ValidateStruct(e, "ObjectMeta"); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventValidation(t *testing.T) {
+	valid := func() Event {
+		return Event{
+			InvolvedObject: ObjectReference{Kind: KindPod, Name: "web"},
+			Type:           EventTypeWarning,
+			Reason:         "Evicted",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(e *Event)
+		wantErr bool
+	}{
+		{name: "valid event without a name", modify: func(e *Event) {}},
+		{name: "normal event", modify: func(e *Event) { e.Type = EventTypeNormal }},
+		{name: "missing reason", modify: func(e *Event) { e.Reason = "" }, wantErr: true},
+		{name: "unknown type", modify: func(e *Event) { e.Type = "Fatal" }, wantErr: true},
+		{name: "missing involved object", modify: func(e *Event) { e.InvolvedObject = ObjectReference{} }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := valid()
+			tt.modify(&event)
+			err := event.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidEvent)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// EndpointsHandler serves the Endpoints of the Services. They are read-only, as the endpoints controller
+// writes them to the registry.
+type EndpointsHandler struct {
+	endpointsRegistry *registry.EndpointsRegistry
+}
+
+// NewEndpointsHandler creates a new EndpointsHandler
+func NewEndpointsHandler(endpointsRegistry *registry.EndpointsRegistry) *EndpointsHandler {
+	return &EndpointsHandler{endpointsRegistry: endpointsRegistry}
+}
+
+// GetEndpoints handles GET requests to retrieve the Endpoints of a Service
+func (h *EndpointsHandler) GetEndpoints(request *restful.Request, response *restful.Response) {
+	endpoints, err := h.endpointsRegistry.Get(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrEndpointsNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, endpoints)
+}
+
+// ListEndpoints handles GET requests to list the Endpoints of a namespace, or of all namespaces on /endpoints
+func (h *EndpointsHandler) ListEndpoints(request *restful.Request, response *restful.Response) {
+	endpoints, err := h.endpointsRegistry.List(request.Request.Context(), request.PathParameter("namespace"))
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, endpoints)
+}
+
+// RegisterEndpointsRoutes registers Endpoints routes with the WebService
+func RegisterEndpointsRoutes(ws *restful.WebService, handler *EndpointsHandler) {
+	ws.Route(ws.GET("/endpoints").To(handler.ListEndpoints))
+	ws.Route(ws.GET("/namespaces/{namespace}/endpoints").To(handler.ListEndpoints))
+	ws.Route(ws.GET("/namespaces/{namespace}/endpoints/{name}").To(handler.GetEndpoints))
+}
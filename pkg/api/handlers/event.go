@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// EventHandler handles Event-related requests
+type EventHandler struct {
+	eventRegistry *registry.EventRegistry
+}
+
+// NewEventHandler creates a new EventHandler
+func NewEventHandler(eventRegistry *registry.EventRegistry) *EventHandler {
+	return &EventHandler{eventRegistry: eventRegistry}
+}
+
+// CreateEvent handles POST requests to record a new Event
+func (h *EventHandler) CreateEvent(request *restful.Request, response *restful.Response) {
+	event := new(api.Event)
+	if err := request.ReadEntity(event); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.eventRegistry.CreateEvent(request.Request.Context(), event); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrEventInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrEventAlreadyExists):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, event)
+}
+
+// ListEvents handles GET requests to list Events, optionally only those about the object given by the
+// involvedObject.kind and involvedObject.name query parameters
+func (h *EventHandler) ListEvents(request *restful.Request, response *restful.Response) {
+	kind := request.QueryParameter("involvedObject.kind")
+	name := request.QueryParameter("involvedObject.name")
+	events, err := h.eventRegistry.ListEvents(request.Request.Context(), kind, name)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, events)
+}
+
+// RegisterEventRoutes registers Event routes with the WebService
+func RegisterEventRoutes(ws *restful.WebService, handler *EventHandler) {
+	ws.Route(ws.POST("/events").To(handler.CreateEvent))
+	ws.Route(ws.GET("/events").To(handler.ListEvents))
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEvents(t *testing.T) {
+	post := func(container *restful.Container, event api.Event) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(event)
+		req := httptest.NewRequest("POST", "/api/v1/events", bytes.NewReader(body))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("should record events and list them by involved object", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterEventRoutes(ws, NewEventHandler(registry.NewEventRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			resp := post(container, api.Event{
+				InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web"},
+				Type:           api.EventTypeWarning,
+				Reason:         "Evicted",
+				Source:         "kubelet/node-1",
+			})
+			require.Equal(t, http.StatusCreated, resp.Code)
+			resp = post(container, api.Event{
+				InvolvedObject: api.ObjectReference{Kind: api.KindNode, Name: "node-1"},
+				Reason:         "NodeHasMemoryPressure",
+			})
+			require.Equal(t, http.StatusCreated, resp.Code)
+
+			req := httptest.NewRequest("GET", "/api/v1/events?involvedObject.kind=Pod&involvedObject.name=web", nil)
+			resp = httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			require.Equal(t, http.StatusOK, resp.Code)
+			var events []api.Event
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &events))
+			require.Len(t, events, 1)
+			assert.Equal(t, "Evicted", events[0].Reason)
+			assert.Equal(t, "kubelet/node-1", events[0].Source)
+		})
+	})
+
+	t.Run("should return bad request for an invalid event", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterEventRoutes(ws, NewEventHandler(registry.NewEventRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			resp := post(container, api.Event{Reason: "Evicted"})
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// NodeLeaseHandler handles the HTTP requests about the leases of the nodes
+type NodeLeaseHandler struct {
+	leaseRegistry *registry.NodeLeaseRegistry
+}
+
+// NewNodeLeaseHandler creates a new NodeLeaseHandler
+func NewNodeLeaseHandler(leaseRegistry *registry.NodeLeaseRegistry) *NodeLeaseHandler {
+	return &NodeLeaseHandler{leaseRegistry: leaseRegistry}
+}
+
+// RenewNodeLease handles PUT requests to renew the lease of a node, acquiring it if it expired
+func (h *NodeLeaseHandler) RenewNodeLease(request *restful.Request, response *restful.Response) {
+	lease := new(api.Lease)
+	if err := request.ReadEntity(lease); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if lease.Name != request.PathParameter("name") {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("lease name in URL does not match the name in the request body"))
+		return
+	}
+
+	if err := h.leaseRegistry.RenewNodeLease(request.Request.Context(), lease); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrLeaseInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrLeaseHeld):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, lease)
+}
+
+// GetNodeLease handles GET requests to retrieve the lease of a node
+func (h *NodeLeaseHandler) GetNodeLease(request *restful.Request, response *restful.Response) {
+	lease, err := h.leaseRegistry.GetNodeLease(request.Request.Context(), request.PathParameter("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrLeaseNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, lease)
+}
+
+// ListNodeLeases handles GET requests to list the leases of the nodes whose kubelets are alive
+func (h *NodeLeaseHandler) ListNodeLeases(request *restful.Request, response *restful.Response) {
+	leases, err := h.leaseRegistry.ListNodeLeases(request.Request.Context())
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, leases)
+}
+
+// RegisterNodeLeaseRoutes registers the node lease routes with the WebService
+func RegisterNodeLeaseRoutes(ws *restful.WebService, handler *NodeLeaseHandler) {
+	ws.Route(ws.GET("/leases/nodes").To(handler.ListNodeLeases))
+	ws.Route(ws.GET("/leases/nodes/{name}").To(handler.GetNodeLease))
+	ws.Route(ws.PUT("/leases/nodes/{name}").To(handler.RenewNodeLease))
+}
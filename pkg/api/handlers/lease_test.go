@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestNodeLeases(t *testing.T) {
+	serve := func(container *restful.Container, method, path string, body any) *httptest.ResponseRecorder {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, req)
+		return resp
+	}
+	lease := api.Lease{ObjectMeta: api.ObjectMeta{Name: "node-1"}, HolderIdentity: "machine-1", LeaseDurationSeconds: 40}
+
+	t.Run("should renew, get and list node leases", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterNodeLeaseRoutes(ws, NewNodeLeaseHandler(registry.NewNodeLeaseRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			resp := serve(container, http.MethodPut, "/api/v1/leases/nodes/node-1", lease)
+			require.Equal(t, http.StatusOK, resp.Code)
+			renewed := api.Lease{}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &renewed))
+			assert.False(t, renewed.AcquireTime.IsZero())
+
+			resp = serve(container, http.MethodGet, "/api/v1/leases/nodes/node-1", nil)
+			require.Equal(t, http.StatusOK, resp.Code)
+			stored := api.Lease{}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &stored))
+			assert.Equal(t, "machine-1", stored.HolderIdentity)
+			assert.True(t, renewed.AcquireTime.Equal(stored.AcquireTime))
+
+			resp = serve(container, http.MethodGet, "/api/v1/leases/nodes", nil)
+			require.Equal(t, http.StatusOK, resp.Code)
+			var leases []api.Lease
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &leases))
+			assert.Len(t, leases, 1)
+
+			resp = serve(container, http.MethodGet, "/api/v1/leases/nodes/node-2", nil)
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+
+			other := lease
+			other.HolderIdentity = "machine-2"
+			resp = serve(container, http.MethodPut, "/api/v1/leases/nodes/node-1", other)
+			assert.Equal(t, http.StatusConflict, resp.Code, "the lease of another live holder is not taken over")
+		})
+	})
+
+	t.Run("should return bad request for an invalid lease", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterNodeLeaseRoutes(ws, NewNodeLeaseHandler(registry.NewNodeLeaseRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			resp := serve(container, http.MethodPut, "/api/v1/leases/nodes/node-2", lease)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+			short := lease
+			short.LeaseDurationSeconds = 1
+			resp = serve(container, http.MethodPut, "/api/v1/leases/nodes/node-1", short)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
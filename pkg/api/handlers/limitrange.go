@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// LimitRangeHandler handles LimitRange-related HTTP requests. LimitRanges are addressed within
+// their namespace, e.g. /namespaces/staging/limitranges/limits.
+type LimitRangeHandler struct {
+	limitRangeRegistry *registry.LimitRangeRegistry
+}
+
+// NewLimitRangeHandler creates a new LimitRangeHandler
+func NewLimitRangeHandler(limitRangeRegistry *registry.LimitRangeRegistry) *LimitRangeHandler {
+	return &LimitRangeHandler{limitRangeRegistry: limitRangeRegistry}
+}
+
+// CreateLimitRange handles POST requests to create a new LimitRange in the namespace of the path
+func (h *LimitRangeHandler) CreateLimitRange(request *restful.Request, response *restful.Response) {
+	limitRange := new(api.LimitRange)
+	if err := request.ReadEntity(limitRange); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	namespace := request.PathParameter("namespace")
+	if limitRange.Namespace == "" {
+		limitRange.Namespace = namespace
+	}
+	if limitRange.Namespace != namespace {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the limit range in the request body"))
+		return
+	}
+
+	if err := h.limitRangeRegistry.Create(request.Request.Context(), limitRange); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrLimitRangeAlreadyExists):
+			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrLimitRangeInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNamespaceTerminating):
+			api.WriteError(response, http.StatusForbidden, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, limitRange)
+}
+
+// GetLimitRange handles GET requests to retrieve a LimitRange
+func (h *LimitRangeHandler) GetLimitRange(request *restful.Request, response *restful.Response) {
+	limitRange, err := h.limitRangeRegistry.Get(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrLimitRangeNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, limitRange)
+}
+
+// ListLimitRanges handles GET requests to list the LimitRanges of a namespace
+func (h *LimitRangeHandler) ListLimitRanges(request *restful.Request, response *restful.Response) {
+	limitRanges, err := h.limitRangeRegistry.List(request.Request.Context(), request.PathParameter("namespace"))
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, limitRanges)
+}
+
+// DeleteLimitRange handles DELETE requests to delete a LimitRange
+func (h *LimitRangeHandler) DeleteLimitRange(request *restful.Request, response *restful.Response) {
+	if err := h.limitRangeRegistry.Delete(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name")); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrLimitRangeNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusNoContent, nil)
+}
+
+// RegisterLimitRangeRoutes registers LimitRange routes with the WebService
+func RegisterLimitRangeRoutes(ws *restful.WebService, handler *LimitRangeHandler) {
+	ws.Route(ws.POST("/namespaces/{namespace}/limitranges").To(handler.CreateLimitRange))
+	ws.Route(ws.GET("/namespaces/{namespace}/limitranges").To(handler.ListLimitRanges))
+	ws.Route(ws.GET("/namespaces/{namespace}/limitranges/{name}").To(handler.GetLimitRange))
+	ws.Route(ws.DELETE("/namespaces/{namespace}/limitranges/{name}").To(handler.DeleteLimitRange))
+}
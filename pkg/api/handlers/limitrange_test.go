@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestLimitRanges(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		store := storage.NewEtcdStorage(etcdServer)
+		limitRangeRegistry := registry.NewLimitRangeRegistry(store)
+		podRegistry := registry.NewPodRegistry(store)
+		podRegistry.SetLimitRangeRegistry(limitRangeRegistry)
+		RegisterLimitRangeRoutes(ws, NewLimitRangeHandler(limitRangeRegistry))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+		serve := func(method, path string, body any) *httptest.ResponseRecorder {
+			data, _ := json.Marshal(body)
+			req := httptest.NewRequest(method, path, bytes.NewReader(data))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		limitRange := &api.LimitRange{
+			ObjectMeta: api.ObjectMeta{Name: "limits"},
+			Spec: api.LimitRangeSpec{
+				DefaultRequest: api.ResourceList{api.ResourceMemory: "128Mi"},
+				Max:            api.ResourceList{api.ResourceMemory: "1Gi"},
+			},
+		}
+		resp := serve(http.MethodPost, "/api/v1/namespaces/staging/limitranges", limitRange)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web", Namespace: "staging"},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx"}}},
+		}
+		resp = serve(http.MethodPost, "/api/v1/pods", pod)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+		var created api.Pod
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+		assert.Equal(t, &api.ResourceRequirements{Requests: api.ResourceList{api.ResourceMemory: "128Mi"}}, created.Spec.Containers[0].Resources)
+
+		pod.Name = "big"
+		pod.Spec.Containers[0].Resources = &api.ResourceRequirements{Requests: api.ResourceList{api.ResourceMemory: "2Gi"}}
+		resp = serve(http.MethodPost, "/api/v1/pods", pod)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		var status api.Status
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+		assert.Equal(t, []api.FieldError{{
+			Field:   "spec.containers[0].resources.requests.memory",
+			Message: "must be at most 1Gi under limit range limits, got 2Gi",
+		}}, status.Errors)
+
+		resp = serve(http.MethodGet, "/api/v1/namespaces/staging/limitranges/limits", nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = serve(http.MethodDelete, "/api/v1/namespaces/staging/limitranges/limits", nil)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		resp = serve(http.MethodGet, "/api/v1/namespaces/staging/limitranges/limits", nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+}
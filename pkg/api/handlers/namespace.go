@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// NamespaceHandler handles Namespace-related HTTP requests
+type NamespaceHandler struct {
+	namespaceRegistry *registry.NamespaceRegistry
+}
+
+// NewNamespaceHandler creates a new NamespaceHandler
+func NewNamespaceHandler(namespaceRegistry *registry.NamespaceRegistry) *NamespaceHandler {
+	return &NamespaceHandler{namespaceRegistry: namespaceRegistry}
+}
+
+const namespaceAttributeKey = "namespace"
+
+// LoadNamespaceIntoRequest retrieves the namespace and stores it in the request attributes
+func (h *NamespaceHandler) LoadNamespaceIntoRequest(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	name := req.PathParameter("name")
+	namespace, err := h.namespaceRegistry.Get(req.Request.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(resp, http.StatusNotFound, err)
+		default:
+			api.WriteError(resp, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	req.SetAttribute(namespaceAttributeKey, namespace)
+	chain.ProcessFilter(req, resp)
+}
+
+// CreateNamespace handles POST requests to create a new Namespace
+func (h *NamespaceHandler) CreateNamespace(request *restful.Request, response *restful.Response) {
+	namespace := new(api.Namespace)
+	if err := request.ReadEntity(namespace); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.namespaceRegistry.Create(request.Request.Context(), namespace); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNamespaceAlreadyExists):
+			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrNamespaceInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, namespace)
+}
+
+// GetNamespace handles GET requests to retrieve a Namespace
+func (h *NamespaceHandler) GetNamespace(request *restful.Request, response *restful.Response) {
+	namespace, ok := request.Attribute(namespaceAttributeKey).(*api.Namespace)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve namespace from request attributes"))
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, namespace)
+}
+
+// ListNamespaces handles GET requests to list all Namespaces
+func (h *NamespaceHandler) ListNamespaces(request *restful.Request, response *restful.Response) {
+	namespaces, err := h.namespaceRegistry.List(request.Request.Context())
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, namespaces)
+}
+
+// DeleteNamespace handles DELETE requests to delete a Namespace. The Namespace is answered as Terminating;
+// it is removed once the namespace controller has deleted its pods and ReplicaSets.
+func (h *NamespaceHandler) DeleteNamespace(request *restful.Request, response *restful.Response) {
+	namespace, err := h.namespaceRegistry.Delete(request.Request.Context(), request.PathParameter("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNamespaceProtected):
+			api.WriteError(response, http.StatusForbidden, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, namespace)
+}
+
+// RegisterNamespaceRoutes registers Namespace routes with the WebService
+func RegisterNamespaceRoutes(ws *restful.WebService, handler *NamespaceHandler) {
+	ws.Route(ws.POST("/namespaces").To(handler.CreateNamespace))
+	ws.Route(ws.GET("/namespaces").To(handler.ListNamespaces))
+	ws.Route(ws.GET("/namespaces/{name}").Filter(handler.LoadNamespaceIntoRequest).To(handler.GetNamespace))
+	ws.Route(ws.DELETE("/namespaces/{name}").To(handler.DeleteNamespace))
+}
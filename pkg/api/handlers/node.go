@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"gokube/pkg/api"
 	"gokube/pkg/registry"
@@ -70,7 +71,7 @@ func (h *NodeHandler) GetNode(request *restful.Request, response *restful.Respon
 		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve node from request attributes"))
 		return
 	}
-	api.WriteResponse(response, http.StatusOK, node)
+	api.WriteResponseWithETag(request, response, api.ObjectETag(node), node)
 }
 
 // UpdateNode handles PUT requests to update a Node
@@ -94,8 +95,12 @@ func (h *NodeHandler) UpdateNode(request *restful.Request, response *restful.Res
 
 	if err := h.nodeRegistry.UpdateNode(request.Request.Context(), node); err != nil {
 		switch {
+		case errors.Is(err, registry.ErrNodeNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
 		case errors.Is(err, registry.ErrNodeInvalid):
 			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNodeConflict):
+			api.WriteError(response, http.StatusConflict, err)
 		default:
 			api.WriteError(response, http.StatusInternalServerError, err)
 		}
@@ -105,6 +110,45 @@ func (h *NodeHandler) UpdateNode(request *restful.Request, response *restful.Res
 	api.WriteResponse(response, http.StatusOK, node)
 }
 
+// UpdateNodeStatus handles PUT requests to the status subresource of a Node
+func (h *NodeHandler) UpdateNodeStatus(request *restful.Request, response *restful.Response) {
+	existingNode, ok := request.Attribute(nodeAttributeKey).(*api.Node)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve node from request attributes"))
+		return
+	}
+
+	node := new(api.Node)
+	if err := request.ReadEntity(node); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if existingNode.Name != node.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("node name in URL does not match the name in the request body"))
+		return
+	}
+
+	if err := h.nodeRegistry.UpdateNodeStatus(request.Request.Context(), node); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrNodeNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNodeConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	updatedNode, err := h.nodeRegistry.GetNode(request.Request.Context(), node.Name)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, updatedNode)
+}
+
 // DeleteNode handles DELETE requests to remove a Node
 func (h *NodeHandler) DeleteNode(request *restful.Request, response *restful.Response) {
 	node, ok := request.Attribute(nodeAttributeKey).(*api.Node)
@@ -114,18 +158,36 @@ func (h *NodeHandler) DeleteNode(request *restful.Request, response *restful.Res
 	}
 
 	if err := h.nodeRegistry.DeleteNode(request.Request.Context(), node.Name); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+		api.WriteError(response, statusOfDelete(err), err)
 		return
 	}
 
 	api.WriteResponse(response, http.StatusNoContent, nil)
 }
 
-// ListNodes handles GET requests to list all Nodes
+// ListNodes handles GET requests to list all Nodes, or only the Nodes named by ?names=a,b,c. With ?watch=true
+// the changes to all Nodes after ?resourceVersion= are streamed instead.
 func (h *NodeHandler) ListNodes(request *restful.Request, response *restful.Response) {
+	names := namesParameter(request)
+	if watchRequested(request) {
+		if names != nil {
+			api.WriteError(response, http.StatusBadRequest, errors.New("a watch cannot be narrowed down by names"))
+			return
+		}
+		h.watchNodes(request, response)
+		return
+	}
 
 	nodeName := request.Attribute("nodeName")
-	nodes, err := h.nodeRegistry.ListNodes(request.Request.Context())
+	var nodes []*api.Node
+	var err error
+	if names != nil {
+		nodes, err = getNamed(request, response, names, h.nodeRegistry.GetNodes)
+	} else {
+		var revision int64
+		nodes, revision, err = h.nodeRegistry.ListNodesWithRevision(request.Request.Context())
+		response.Header().Set(api.ResourceVersionHeader, strconv.FormatInt(revision, 10))
+	}
 	if err != nil {
 		api.WriteError(response, http.StatusInternalServerError, err)
 		return
@@ -134,7 +196,24 @@ func (h *NodeHandler) ListNodes(request *restful.Request, response *restful.Resp
 
 	}
 
-	api.WriteResponse(response, http.StatusOK, nodes)
+	api.WriteResponseWithETag(request, response, api.ListETag(listFilter(request), nodes), nodes)
+}
+
+// watchNodes streams the changes to the Nodes
+func (h *NodeHandler) watchNodes(request *restful.Request, response *restful.Response) {
+	revision, err := resourceVersionParameter(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	events, err := h.nodeRegistry.WatchNodes(request.Request.Context(), revision)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	serveWatch(request, response, events, func(event registry.NodeEvent) (any, bool) {
+		return api.NodeWatchEvent{Type: event.Type, Object: event.Node}, true
+	})
 }
 
 // RegisterNodeRoutes registers Node routes with the WebService
@@ -143,5 +222,6 @@ func RegisterNodeRoutes(ws *restful.WebService, handler *NodeHandler) {
 	ws.Route(ws.GET("/nodes").To(handler.ListNodes))
 	ws.Route(ws.GET("/nodes/{name}").Filter(handler.LoadNodeIntoRequest).To(handler.GetNode))
 	ws.Route(ws.PUT("/nodes/{name}").Filter(handler.LoadNodeIntoRequest).To(handler.UpdateNode))
+	ws.Route(ws.PUT("/nodes/{name}/status").Filter(handler.LoadNodeIntoRequest).To(handler.UpdateNodeStatus))
 	ws.Route(ws.DELETE("/nodes/{name}").Filter(handler.LoadNodeIntoRequest).To(handler.DeleteNode))
 }
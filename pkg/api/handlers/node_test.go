@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
 	"gokube/pkg/api"
@@ -93,7 +94,6 @@ func TestCreateNode(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterNodeRoutes(ws, handler)
 
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 			mockStore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			node := &api.Node{
@@ -405,6 +405,89 @@ func TestUpdateNode(t *testing.T) {
 	})
 }
 
+func TestUpdateNodeStatus(t *testing.T) {
+	t.Run("should update status and keep the spec", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			nodeRegistry := registry.NewNodeRegistry(store)
+			handler := NewNodeHandler(nodeRegistry)
+			ctx := context.Background()
+
+			RegisterNodeRoutes(ws, handler)
+
+			node := &api.Node{
+				ObjectMeta: api.ObjectMeta{
+					Name: "test-node",
+				},
+				Spec: api.NodeSpec{
+					Unschedulable: true,
+				},
+			}
+			require.NoError(t, nodeRegistry.CreateNode(ctx, node))
+
+			heartbeat := time.Now().UTC().Truncate(time.Second)
+			statusUpdate := &api.Node{
+				ObjectMeta:        api.ObjectMeta{Name: "test-node"},
+				Status:            api.NodeReady,
+				LastHeartbeatTime: heartbeat,
+			}
+
+			body, _ := json.Marshal(statusUpdate)
+			req := httptest.NewRequest("PUT", "/api/v1/nodes/test-node/status", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var returnedNode api.Node
+			err := json.Unmarshal(resp.Body.Bytes(), &returnedNode)
+			assert.NoError(t, err)
+			assert.Equal(t, api.NodeReady, returnedNode.Status)
+			assert.True(t, heartbeat.Equal(returnedNode.LastHeartbeatTime))
+			assert.True(t, returnedNode.Spec.Unschedulable)
+		})
+	})
+
+	t.Run("should return not found for non-existent node", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			store := storage.NewEtcdStorage(etcdServer)
+			handler := NewNodeHandler(registry.NewNodeRegistry(store))
+
+			RegisterNodeRoutes(ws, handler)
+
+			body, _ := json.Marshal(&api.Node{ObjectMeta: api.ObjectMeta{Name: "missing"}})
+			req := httptest.NewRequest("PUT", "/api/v1/nodes/missing/status", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("should return conflict for an update from another machine", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			nodeRegistry := registry.NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+			handler := NewNodeHandler(nodeRegistry)
+
+			RegisterNodeRoutes(ws, handler)
+			require.NoError(t, nodeRegistry.CreateNode(context.Background(), &api.Node{ObjectMeta: api.ObjectMeta{Name: "test-node", UID: "machine-1"}}))
+
+			body, _ := json.Marshal(&api.Node{ObjectMeta: api.ObjectMeta{Name: "test-node", UID: "machine-2"}, Status: api.NodeReady})
+			req := httptest.NewRequest("PUT", "/api/v1/nodes/test-node/status", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+	})
+}
+
 func TestDeleteNode(t *testing.T) {
 	t.Run("should delete existing node", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
@@ -544,7 +627,7 @@ func TestListNodes(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterNodeRoutes(ws, handler)
 
-			mockStore.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
+			mockStore.EXPECT().ListPageFunc(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(storage.ListResult{}, errors.New("simulated registry failure"))
 
 			req := httptest.NewRequest("GET", "/api/v1/nodes", nil)
 			req.Header.Set("Content-Type", restful.MIME_JSON)
@@ -1,17 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/emicklei/go-restful/v3"
 
 	"gokube/pkg/api"
 	"gokube/pkg/registry"
+	"gokube/pkg/storage"
 )
 
-// PodHandler handles Pod-related requests
+// PodHandler handles Pod-related requests. Pods are addressed within their namespace, e.g.
+// /namespaces/staging/pods/web, or in the default namespace on /pods/web as before pods had namespaces. They
+// are listed across namespaces on /pods.
 type PodHandler struct {
 	podRegistry *registry.PodRegistry
 }
@@ -26,7 +32,7 @@ const podAttributeKey = "pod"
 // LoadPodIntoRequest retrieves the pod and stores it in the request attributes
 func (h *PodHandler) LoadPodIntoRequest(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 	name := req.PathParameter("name")
-	pod, err := h.podRegistry.GetPod(req.Request.Context(), name)
+	pod, err := h.podRegistry.GetPod(req.Request.Context(), pathNamespace(req), name)
 	if err != nil {
 		switch {
 		case errors.Is(err, registry.ErrPodNotFound):
@@ -40,39 +46,194 @@ func (h *PodHandler) LoadPodIntoRequest(req *restful.Request, resp *restful.Resp
 	chain.ProcessFilter(req, resp)
 }
 
-// CreatePod handles POST requests to create a new Pod
+// CreatePod handles POST requests to create a new Pod in the namespace of the path, or in the namespace of the
+// pod on /pods
 func (h *PodHandler) CreatePod(request *restful.Request, response *restful.Response) {
 	pod := new(api.Pod)
 	if err := request.ReadEntity(pod); err != nil {
 		api.WriteError(response, http.StatusBadRequest, err)
 		return
 	}
+	if namespace := request.PathParameter("namespace"); namespace != "" {
+		if pod.Namespace == "" {
+			pod.Namespace = namespace
+		}
+		if pod.Namespace != namespace {
+			api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the pod in the request body"))
+			return
+		}
+	}
 
-	//Assignment2: Implement CreatePod handler.
+	if err := h.podRegistry.CreatePod(request.Request.Context(), pod); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrPodAlreadyExists):
+			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrPodInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNamespaceTerminating), errors.Is(err, registry.ErrQuotaExceeded):
+			api.WriteError(response, http.StatusForbidden, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
 
 	api.WriteResponse(response, http.StatusCreated, pod)
 }
 
-// ListPods handles GET requests to list all Pods
+// ListPods handles GET requests to list the Pods of the namespace of the path, or of all namespaces on /pods,
+// narrowed down to the Pods bound to the node given by ?nodeName=, the Pods of the status given by ?status=,
+// or only the Pods named by ?names=a,b,c; the filters given are all applied.
+// With ?countOnly=true the number of Pods of each status is answered instead of the Pods, and with
+// ?namesOnly=true a JSON array of their names. With ?watch=true the changes to the Pods after ?resourceVersion=
+// are streamed instead, narrowed down by ?nodeName= and ?labelSelector= only.
 func (h *PodHandler) ListPods(request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("namespace")
 	nodeName := request.QueryParameter("nodeName")
-	pods, err := h.podRegistry.ListPods(request.Request.Context())
+	status := api.PodStatus(request.QueryParameter("status"))
+	names := namesParameter(request)
+	selector, err := selectorParameter(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	countOnly := request.QueryParameter("countOnly") == "true"
+	namesOnly := request.QueryParameter("namesOnly") == "true"
+	page, paged, err := listOptionsParameters(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if status != "" {
+		if err := api.ValidatePodStatus(status); err != nil {
+			api.WriteError(response, http.StatusBadRequest, err)
+			return
+		}
+	}
+	filtered := nodeName != "" || status != "" || names != nil || selector != nil
+	if watchRequested(request) {
+		if status != "" || names != nil || countOnly || namesOnly || paged {
+			api.WriteError(response, http.StatusBadRequest, errors.New("a watch only narrows down the pods by nodeName and labelSelector"))
+			return
+		}
+		h.watchPods(request, response, namespace, nodeName, selector)
+		return
+	}
+	if paged && (filtered || countOnly || namesOnly) {
+		api.WriteError(response, http.StatusBadRequest, errors.New("limit and continue only page the list of all pods"))
+		return
+	}
+	if countOnly && namesOnly {
+		api.WriteError(response, http.StatusBadRequest, errors.New("countOnly and namesOnly cannot be combined"))
+		return
+	}
+	if namesOnly && !filtered {
+		// Listing the names of all Pods needs only their keys
+		podNames, err := h.podRegistry.ListPodNames(request.Request.Context(), namespace)
+		if err != nil {
+			api.WriteError(response, http.StatusInternalServerError, err)
+			return
+		}
+		api.WriteResponse(response, http.StatusOK, podNames)
+		return
+	}
+	if countOnly && !filtered && namespace == "" {
+		// Counting all Pods needs only their status
+		counts, err := h.podRegistry.CountPodsByStatus(request.Request.Context())
+		if err != nil {
+			api.WriteError(response, http.StatusInternalServerError, err)
+			return
+		}
+		api.WriteResponse(response, http.StatusOK, counts)
+		return
+	}
+
+	var pods []*api.Pod
+	switch {
+	case paged:
+		var next string
+		pods, next, err = h.podRegistry.ListPodsPage(request.Request.Context(), namespace, page)
+		if errors.Is(err, registry.ErrInvalidContinue) {
+			api.WriteError(response, http.StatusBadRequest, err)
+			return
+		}
+		if next != "" {
+			response.Header().Set(api.ContinueHeader, next)
+		}
+	case names != nil:
+		pods, err = getNamed(request, response, names, func(ctx context.Context, names []string) (map[string]*api.Pod, []string, error) {
+			return h.podRegistry.GetPods(ctx, pathNamespace(request), names)
+		})
+	case nodeName != "":
+		pods, err = h.podRegistry.ListPodsByNode(request.Request.Context(), nodeName)
+	case status != "":
+		pods, err = h.podRegistry.ListPodsByStatus(request.Request.Context(), status)
+	case selector != nil:
+		pods, err = h.podRegistry.ListPodsBySelector(request.Request.Context(), selector)
+	default:
+		var revision int64
+		pods, revision, err = h.podRegistry.ListPodsWithRevision(request.Request.Context(), namespace)
+		response.Header().Set(api.ResourceVersionHeader, strconv.FormatInt(revision, 10))
+	}
 	if err != nil {
 		api.WriteError(response, http.StatusInternalServerError, err)
 		return
 	}
 
-	if nodeName != "" {
-		filteredPods := make([]*api.Pod, 0)
+	// Only one filter was applied by the registry, and the indexes span all namespaces; the pods are narrowed
+	// down to the others here
+	if filtered {
+		filteredPods := make([]*api.Pod, 0, len(pods))
 		for _, pod := range pods {
-			if pod.NodeName == nodeName {
+			if (namespace == "" || api.NamespaceOf(&pod.ObjectMeta) == namespace) &&
+				(nodeName == "" || pod.NodeName == nodeName) && (status == "" || pod.Status == status) &&
+				api.SelectorMatches(selector, pod.Labels) {
 				filteredPods = append(filteredPods, pod)
 			}
 		}
 		pods = filteredPods
 	}
 
-	api.WriteResponse(response, http.StatusOK, pods)
+	if countOnly {
+		counts := make(map[api.PodStatus]int)
+		for _, pod := range pods {
+			counts[pod.Status]++
+		}
+		api.WriteResponse(response, http.StatusOK, counts)
+		return
+	}
+	if namesOnly {
+		podNames := make([]string, len(pods))
+		for i, pod := range pods {
+			podNames[i] = pod.Name
+		}
+		api.WriteResponse(response, http.StatusOK, podNames)
+		return
+	}
+	api.WriteResponseWithETag(request, response, api.ListETag(listFilter(request), pods), pods)
+}
+
+// watchPods streams the changes to the Pods of namespace, all if empty, bound to nodeName and matching selector,
+// where given
+func (h *PodHandler) watchPods(request *restful.Request, response *restful.Response, namespace, nodeName string, selector map[string]string) {
+	revision, err := resourceVersionParameter(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	events, err := h.podRegistry.WatchPods(request.Request.Context(), revision)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	serveWatch(request, response, events, func(event registry.PodEvent) (any, bool) {
+		pod := event.Pod
+		keep := (namespace == "" || api.NamespaceOf(&pod.ObjectMeta) == namespace) &&
+			(nodeName == "" || pod.NodeName == nodeName) && api.SelectorMatches(selector, pod.Labels)
+		return api.PodWatchEvent{Type: event.Type, Object: pod}, keep
+	})
 }
 
 // GetPod handles GET requests to retrieve a Pod
@@ -82,7 +243,7 @@ func (h *PodHandler) GetPod(request *restful.Request, response *restful.Response
 		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve pod from request attributes"))
 		return
 	}
-	api.WriteResponse(response, http.StatusOK, pod)
+	api.WriteResponseWithETag(request, response, api.ObjectETag(pod), pod)
 }
 
 // UpdatePod handles PUT requests to update a Pod
@@ -103,12 +264,31 @@ func (h *PodHandler) UpdatePod(request *restful.Request, response *restful.Respo
 		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("pod name in URL does not match pod name in request body"))
 		return
 	}
+	if updatedPod.Namespace == "" {
+		updatedPod.Namespace = existingPod.Namespace
+	}
+	if api.NamespaceOf(&updatedPod.ObjectMeta) != api.NamespaceOf(&existingPod.ObjectMeta) {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the pod in the request body"))
+		return
+	}
 
 	if err := h.podRegistry.UpdatePod(request.Request.Context(), updatedPod); err != nil {
 		switch {
+		case errors.Is(err, registry.ErrPodNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+			return
 		case errors.Is(err, registry.ErrPodInvalid):
 			api.WriteError(response, http.StatusBadRequest, err)
 			return
+		case errors.Is(err, registry.ErrMirrorPodReadOnly):
+			api.WriteError(response, http.StatusForbidden, err)
+			return
+		case errors.Is(err, registry.ErrPodImmutable):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+			return
+		case errors.Is(err, registry.ErrPodConflict), errors.Is(err, registry.ErrInvalidTransition):
+			api.WriteError(response, http.StatusConflict, err)
+			return
 		default:
 			api.WriteError(response, http.StatusInternalServerError, err)
 			return
@@ -118,7 +298,61 @@ func (h *PodHandler) UpdatePod(request *restful.Request, response *restful.Respo
 	api.WriteResponse(response, http.StatusOK, updatedPod)
 }
 
-// DeletePod handles DELETE requests to remove a Pod
+// UpdatePodStatus handles PUT requests to the status subresource of a Pod
+func (h *PodHandler) UpdatePodStatus(request *restful.Request, response *restful.Response) {
+	existingPod, ok := request.Attribute(podAttributeKey).(*api.Pod)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve pod from request attributes"))
+		return
+	}
+
+	update := new(api.PodStatusUpdate)
+	if err := request.ReadEntity(update); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if existingPod.Name != update.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("pod name in URL does not match pod name in request body"))
+		return
+	}
+	if update.Namespace == "" {
+		update.Namespace = existingPod.Namespace
+	}
+	if update.Namespace != api.NamespaceOf(&existingPod.ObjectMeta) {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the pod in the request body"))
+		return
+	}
+
+	if err := h.podRegistry.UpdatePodStatus(request.Request.Context(), update); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrPodNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrPodInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrPodConflict), errors.Is(err, registry.ErrInvalidTransition):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	updatedPod, err := h.podRegistry.GetPod(request.Request.Context(), update.Namespace, update.Name)
+	switch {
+	case errors.Is(err, registry.ErrPodNotFound) && len(update.RemoveFinalizers) > 0:
+		// The update removed the last finalizer of a deleted pod, and the pod with it
+		api.WriteResponse(response, http.StatusNoContent, nil)
+	case err != nil:
+		api.WriteError(response, http.StatusInternalServerError, err)
+	default:
+		api.WriteResponse(response, http.StatusOK, updatedPod)
+	}
+}
+
+// DeletePod handles DELETE requests to delete a Pod. A pod that has finalizers, such as that of the kubelet
+// of its node, is answered as Terminating; it is removed once they are all removed. With ?force=true the pod
+// is removed at once, whatever its finalizers.
 func (h *PodHandler) DeletePod(request *restful.Request, response *restful.Response) {
 	pod, ok := request.Attribute(podAttributeKey).(*api.Pod)
 	if !ok {
@@ -126,12 +360,54 @@ func (h *PodHandler) DeletePod(request *restful.Request, response *restful.Respo
 		return
 	}
 
-	if err := h.podRegistry.DeletePod(request.Request.Context(), pod.Name); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+	ctx := request.Request.Context()
+	if request.QueryParameter("force") == "true" {
+		if err := h.podRegistry.DeletePod(ctx, pod.Namespace, pod.Name); err != nil {
+			api.WriteError(response, statusOfDelete(err), err)
+			return
+		}
+		api.WriteResponse(response, http.StatusNoContent, nil)
 		return
 	}
 
-	api.WriteResponse(response, http.StatusNoContent, nil)
+	deleting, err := h.podRegistry.DeletePodGracefully(ctx, pod.Namespace, pod.Name)
+	switch {
+	case err != nil:
+		api.WriteError(response, statusOfDelete(err), err)
+	case deleting != nil:
+		api.WriteResponse(response, http.StatusOK, deleting)
+	default:
+		api.WriteResponse(response, http.StatusNoContent, nil)
+	}
+}
+
+// EvictPod handles POST requests to the eviction subresource of a Pod, removing the pod from its node.
+// The pod is deleted gracefully like on DELETE, so its kubelet stops its containers before it is gone.
+// Mirror pods cannot be evicted, as their kubelet runs them from a manifest.
+func (h *PodHandler) EvictPod(request *restful.Request, response *restful.Response) {
+	pod, ok := request.Attribute(podAttributeKey).(*api.Pod)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve pod from request attributes"))
+		return
+	}
+
+	eviction := new(api.Eviction)
+	if err := request.ReadEntity(eviction); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+
+	if pod.IsMirrorPod() {
+		api.WriteError(response, http.StatusForbidden, fmt.Errorf("%w: pod %s cannot be evicted", registry.ErrMirrorPodReadOnly, pod.Name))
+		return
+	}
+
+	if _, err := h.podRegistry.DeletePodGracefully(request.Request.Context(), pod.Namespace, pod.Name); err != nil {
+		api.WriteError(response, statusOfDelete(err), err)
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, eviction)
 }
 
 // ListUnassignedPods handles GET requests to list all unassigned Pods
@@ -150,6 +426,97 @@ func RegisterPodRoutes(ws *restful.WebService, podHandler *PodHandler) {
 	ws.Route(ws.GET("/pods").To(podHandler.ListPods))
 	ws.Route(ws.GET("/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.GetPod))
 	ws.Route(ws.PUT("/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePod))
+	ws.Route(ws.PUT("/pods/{name}/status").Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePodStatus))
 	ws.Route(ws.DELETE("/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.DeletePod))
+	ws.Route(ws.POST("/pods/{name}/eviction").Filter(podHandler.LoadPodIntoRequest).To(podHandler.EvictPod))
 	ws.Route(ws.GET("/pods/unassigned").To(podHandler.ListUnassignedPods))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/pods").To(podHandler.CreatePod))
+	ws.Route(ws.GET("/namespaces/{namespace}/pods").To(podHandler.ListPods))
+	ws.Route(ws.GET("/namespaces/{namespace}/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.GetPod))
+	ws.Route(ws.PUT("/namespaces/{namespace}/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePod))
+	ws.Route(ws.PUT("/namespaces/{namespace}/pods/{name}/status").Filter(podHandler.LoadPodIntoRequest).To(podHandler.UpdatePodStatus))
+	ws.Route(ws.DELETE("/namespaces/{namespace}/pods/{name}").Filter(podHandler.LoadPodIntoRequest).To(podHandler.DeletePod))
+	ws.Route(ws.POST("/namespaces/{namespace}/pods/{name}/eviction").Filter(podHandler.LoadPodIntoRequest).To(podHandler.EvictPod))
+}
+
+// pathNamespace is the namespace of the path of a request, the default namespace on paths without one such
+// as /pods
+func pathNamespace(request *restful.Request) string {
+	if namespace := request.PathParameter("namespace"); namespace != "" {
+		return namespace
+	}
+	return api.DefaultNamespace
+}
+
+// namesParameter splits the ?names= parameter of a list request, or returns nil if there is none
+func namesParameter(request *restful.Request) []string {
+	if !request.Request.URL.Query().Has("names") {
+		return nil
+	}
+	names := []string{}
+	for _, name := range strings.Split(request.QueryParameter("names"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// selectorParameter parses the ?labelSelector= parameter of a list request, e.g. app=web,tier=frontend. It
+// returns nil without one; an empty one selects everything.
+func selectorParameter(request *restful.Request) (map[string]string, error) {
+	if !request.Request.URL.Query().Has("labelSelector") {
+		return nil, nil
+	}
+	return api.ParseSelector(request.QueryParameter("labelSelector"))
+}
+
+// listOptionsParameters parses the ?limit= and ?continue= parameters of a list request, and reports whether
+// either was given. A limit of 0 means no limit.
+func listOptionsParameters(request *restful.Request) (storage.ListOptions, bool, error) {
+	query := request.Request.URL.Query()
+	options := storage.ListOptions{Continue: query.Get("continue")}
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if options.Limit, err = strconv.ParseInt(limit, 10, 64); err != nil || options.Limit < 0 {
+			return storage.ListOptions{}, false, fmt.Errorf("limit must be a non-negative integer, got %q", limit)
+		}
+	}
+	return options, query.Has("limit") || query.Has("continue"), nil
+}
+
+// listFilter returns the query of a list request in a canonical form, to tag the list it selects
+func listFilter(request *restful.Request) string {
+	return request.Request.URL.Query().Encode()
+}
+
+// statusOfDelete returns the status of a delete that failed with err: not found for an object deleted since
+// the request loaded it, internal server error otherwise
+func statusOfDelete(err error) int {
+	if errors.Is(err, registry.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// getNamed gets the named objects in the order they were asked for, each once, and lists the names without
+// an object in the MissingNamesHeader of the response
+func getNamed[T any](request *restful.Request, response *restful.Response, names []string,
+	getAll func(ctx context.Context, names []string) (map[string]*T, []string, error)) ([]*T, error) {
+	found, missing, err := getAll(request.Request.Context(), names)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		response.Header().Set(api.MissingNamesHeader, strings.Join(missing, ","))
+	}
+	objects := make([]*T, 0, len(found))
+	for _, name := range names {
+		if object, ok := found[name]; ok {
+			objects = append(objects, object)
+			delete(found, name)
+		}
+	}
+	return objects, nil
 }
@@ -7,7 +7,10 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
 	"gokube/pkg/api"
@@ -71,6 +74,40 @@ func TestCreatePod(t *testing.T) {
 		})
 	})
 
+	t.Run("should return the UID and creation time the pod was given", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterPodRoutes(ws, NewPodHandler(registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))))
+			post := func(pod *api.Pod) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(pod)
+				req := httptest.NewRequest("POST", "/api/v1/pods", bytes.NewReader(body))
+				req.Header.Set("Content-Type", restful.MIME_JSON)
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, req)
+				return resp
+			}
+			newPod := func(name, uid string) *api.Pod {
+				return &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name, UID: uid},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				}
+			}
+
+			var created [2]api.Pod
+			for i, name := range []string{"web-1", "web-2"} {
+				resp := post(newPod(name, ""))
+				require.Equal(t, http.StatusCreated, resp.Code)
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created[i]))
+				assert.NotEmpty(t, created[i].UID)
+				assert.WithinDuration(t, time.Now(), created[i].CreationTimestamp, time.Minute)
+			}
+			assert.NotEqual(t, created[0].UID, created[1].UID)
+
+			resp := post(newPod("web-3", "my-uid"))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), "uid is assigned by the server")
+		})
+	})
+
 	t.Run("should return bad request for invalid pod", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
@@ -102,6 +139,12 @@ func TestCreatePod(t *testing.T) {
 			container.ServeHTTP(resp, req)
 
 			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			var status api.Status
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+			assert.Equal(t, []api.FieldError{
+				{Field: "spec.containers[0].name", Message: "is required"},
+				{Field: "spec.replicas", Message: "must be at least 0, got -1"},
+			}, status.Errors)
 		})
 	})
 
@@ -132,6 +175,8 @@ func TestCreatePod(t *testing.T) {
 			err := podRegistry.CreatePod(ctx, pod)
 			require.NoError(t, err)
 
+			// Post the pod again, without the UID the first one was given
+			pod.UID = ""
 			body, _ := json.Marshal(pod)
 			req := httptest.NewRequest("POST", "/api/v1/pods", bytes.NewReader(body))
 			req.Header.Set("Content-Type", restful.MIME_JSON)
@@ -154,8 +199,8 @@ func TestCreatePod(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterPodRoutes(ws, handler)
 
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
-			mockStore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
+			// The pod is stored with its index entries in one transaction
+			mockStore.EXPECT().Txn(gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			pod := &api.Pod{
 				ObjectMeta: api.ObjectMeta{
@@ -248,6 +293,260 @@ func TestListPods(t *testing.T) {
 		})
 	})
 
+	t.Run("should list only the pods bound to the node", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for name, nodeName := range map[string]string{"web-a": "node-a", "db-a": "node-a", "web-b": "node-b"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					NodeName:   nodeName,
+					Status:     api.PodScheduled,
+				}))
+			}
+			list := func(query string) []string {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods"+query, nil))
+				require.Equal(t, http.StatusOK, resp.Code, query)
+				var pods []api.Pod
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+				names := make([]string, 0, len(pods))
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+				return names
+			}
+
+			assert.Equal(t, []string{"db-a", "web-a", "web-b"}, list(""))
+			assert.Equal(t, []string{"db-a", "web-a"}, list("?nodeName=node-a"))
+			assert.Equal(t, []string{"web-b"}, list("?nodeName=node-b"), "a kubelet never gets the pods of another node")
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?nodeName=node-c", nil))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.JSONEq(t, `[]`, resp.Body.String(), "a node without pods gets an empty list, not null")
+		})
+	})
+
+	t.Run("should list the pods of a status", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for _, status := range api.PodStatuses {
+				for _, nodeName := range []string{"node-1", "node-2"} {
+					require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+						ObjectMeta: api.ObjectMeta{Name: strings.ToLower(string(status)) + "-" + nodeName},
+						Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+						NodeName:   nodeName,
+						Status:     status,
+					}))
+				}
+			}
+			list := func(query string) []string {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?"+query, nil))
+				require.Equal(t, http.StatusOK, resp.Code, query)
+				var pods []api.Pod
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+				names := make([]string, 0, len(pods))
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+				return names
+			}
+
+			for _, status := range api.PodStatuses {
+				prefix := strings.ToLower(string(status))
+				assert.Equal(t, []string{prefix + "-node-1", prefix + "-node-2"}, list("status="+string(status)))
+				assert.Equal(t, []string{prefix + "-node-2"}, list("status="+string(status)+"&nodeName=node-2"))
+			}
+			assert.Equal(t, []string{"running-node-1"}, list("status=Running&names=running-node-1,failed-node-1"))
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?status=Crashing", nil))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), "must be one of Pending, Scheduled, Running, Succeeded, Failed")
+		})
+	})
+
+	t.Run("should list the pods matching a label selector", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for name, labels := range map[string]map[string]string{
+				"web-1": {"app": "web", "tier": "frontend"},
+				"web-2": {"app": "web", "tier": "backend"},
+				"db-1":  {"app": "db"},
+			} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name, Labels: labels},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					NodeName:   "node-" + strings.TrimPrefix(name, labels["app"]+"-"),
+				}))
+			}
+			list := func(query string) []string {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?"+query, nil))
+				require.Equal(t, http.StatusOK, resp.Code, query)
+				var pods []api.Pod
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+				names := make([]string, 0, len(pods))
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+				return names
+			}
+
+			assert.Equal(t, []string{"web-1", "web-2"}, list("labelSelector=app%3Dweb"))
+			assert.Equal(t, []string{"web-1"}, list("labelSelector=app%3Dweb,tier%3Dfrontend"))
+			assert.Equal(t, []string{"web-2"}, list("labelSelector="+url.QueryEscape("app=web, tier==backend")))
+			assert.Equal(t, []string{}, list("labelSelector=app%3Dweb,tier%3Dcache"))
+			assert.Equal(t, []string{"db-1", "web-1", "web-2"}, list("labelSelector="), "an empty selector selects all pods")
+			assert.Equal(t, []string{"db-1"}, list("labelSelector=app%3Ddb&nodeName=node-1"))
+			assert.Equal(t, []string{}, list("labelSelector=app%3Ddb&nodeName=node-2"))
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?labelSelector=app", nil))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), "invalid label selector")
+		})
+	})
+
+	t.Run("should count the pods of each status", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for name, nodeName := range map[string]string{"web-1": "node-1", "web-2": "node-2", "web-3": ""} {
+				pod := &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					NodeName:   nodeName,
+				}
+				if nodeName != "" {
+					pod.Status = api.PodRunning
+				}
+				require.NoError(t, podRegistry.CreatePod(ctx, pod))
+			}
+
+			for query, expected := range map[string]string{
+				"countOnly=true":                 `{"Pending": 1, "Running": 2}`,
+				"countOnly=true&nodeName=node-1": `{"Running": 1}`,
+				"countOnly=true&names=web-3":     `{"Pending": 1}`,
+			} {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?"+query, nil))
+				assert.Equal(t, http.StatusOK, resp.Code, query)
+				assert.JSONEq(t, expected, resp.Body.String(), query)
+			}
+		})
+	})
+
+	t.Run("should list only the names of the pods", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for name, nodeName := range map[string]string{"web-1": "node-1", "web-2": "node-2", "web-3": ""} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					NodeName:   nodeName,
+				}))
+			}
+
+			for query, expected := range map[string]string{
+				"namesOnly=true":                 `["web-1", "web-2", "web-3"]`,
+				"namesOnly=true&nodeName=node-2": `["web-2"]`,
+			} {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?"+query, nil))
+				assert.Equal(t, http.StatusOK, resp.Code, query)
+				assert.JSONEq(t, expected, resp.Body.String(), query)
+			}
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?namesOnly=true&countOnly=true", nil))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("should page the pods with limit and continue", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for _, name := range []string{"web-1", "web-2", "web-3"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				}))
+			}
+
+			var names []string
+			path := "/api/v1/pods?limit=2"
+			for pages := 0; path != ""; pages++ {
+				require.Less(t, pages, 3)
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+				require.Equal(t, http.StatusOK, resp.Code)
+				var pods []api.Pod
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+				assert.LessOrEqual(t, len(pods), 2)
+				for _, pod := range pods {
+					names = append(names, pod.Name)
+				}
+				path = ""
+				if next := resp.Header().Get(api.ContinueHeader); next != "" {
+					path = "/api/v1/pods?limit=2&continue=" + url.QueryEscape(next)
+				}
+			}
+			assert.Equal(t, []string{"web-1", "web-2", "web-3"}, names)
+
+			for _, query := range []string{"limit=-1", "limit=two", "continue=bogus", "limit=1&nodeName=node-1", "limit=1&countOnly=true"} {
+				resp := httptest.NewRecorder()
+				container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/pods?"+query, nil))
+				assert.Equal(t, http.StatusBadRequest, resp.Code, query)
+			}
+		})
+	})
+
+	t.Run("should get only the named pods and report the missing ones", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for _, name := range []string{"web-1", "web-2", "web-3"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				}))
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/pods?names=web-3,gone,web-1,web-3", nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, "gone", resp.Header().Get(api.MissingNamesHeader))
+			var pods []api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+			require.Len(t, pods, 2)
+			assert.Equal(t, "web-3", pods[0].Name, "pods are answered in the order asked for")
+			assert.Equal(t, "web-1", pods[1].Name)
+
+			req = httptest.NewRequest("GET", "/api/v1/pods?names=", nil)
+			resp = httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.JSONEq(t, "[]", resp.Body.String(), "no names select no pods")
+		})
+	})
+
 	t.Run("should return internal server error for registry failure", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -259,7 +558,7 @@ func TestListPods(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterPodRoutes(ws, handler)
 
-			mockStore.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
+			mockStore.EXPECT().ListPageFunc(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(storage.ListResult{}, errors.New("simulated registry failure"))
 
 			req := httptest.NewRequest("GET", "/api/v1/pods", nil)
 			req.Header.Set("Content-Type", restful.MIME_JSON)
@@ -272,6 +571,61 @@ func TestListPods(t *testing.T) {
 	})
 }
 
+func TestListPodsConditionally(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+		ctx := context.Background()
+		for name, nodeName := range map[string]string{"web-1": "node-1", "web-2": "node-2"} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				NodeName:   nodeName,
+			}))
+		}
+		get := func(path, etag string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", path, nil)
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		first := get("/api/v1/pods", "")
+		require.Equal(t, http.StatusOK, first.Code)
+		etag := first.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		node1 := get("/api/v1/pods?nodeName=node-1", "")
+		require.Equal(t, http.StatusOK, node1.Code)
+		node1ETag := node1.Header().Get("ETag")
+		assert.NotEqual(t, etag, node1ETag)
+
+		resp := get("/api/v1/pods", etag)
+		assert.Equal(t, http.StatusNotModified, resp.Code, "a second identical poll")
+		assert.Empty(t, resp.Body.String())
+		assert.Equal(t, etag, resp.Header().Get("ETag"))
+
+		pod, err := podRegistry.GetPod(ctx, api.DefaultNamespace, "web-2")
+		require.NoError(t, err)
+		podETag := get("/api/v1/pods/web-2", "").Header().Get("ETag")
+		assert.Equal(t, http.StatusNotModified, get("/api/v1/pods/web-2", podETag).Code)
+		pod.Status = api.PodRunning
+		require.NoError(t, podRegistry.UpdatePod(ctx, pod))
+
+		resp = get("/api/v1/pods", etag)
+		assert.Equal(t, http.StatusOK, resp.Code, "the update invalidates the list")
+		assert.NotEqual(t, etag, resp.Header().Get("ETag"))
+		var pods []api.Pod
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+		assert.Len(t, pods, 2)
+		assert.Equal(t, http.StatusOK, get("/api/v1/pods/web-2", podETag).Code, "the update invalidates the pod")
+		assert.Equal(t, http.StatusNotModified, get("/api/v1/pods?nodeName=node-1", node1ETag).Code,
+			"a list the updated pod is not in is still current")
+	})
+}
+
 func TestGetPod(t *testing.T) {
 	t.Run("should get existing pod", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
@@ -389,13 +743,13 @@ func TestUpdatePod(t *testing.T) {
 			err := podRegistry.CreatePod(ctx, pod)
 			require.NoError(t, err)
 
-			// Update pod
+			// Update the image of the container, the only part of the spec that may change
 			updatedPod := &api.Pod{
 				ObjectMeta: api.ObjectMeta{
 					Name: "test-pod",
 				},
 				Spec: api.PodSpec{
-					Replicas: 2,
+					Replicas: 1,
 					Containers: []api.Container{
 						{
 							Name:  "nginx",
@@ -417,11 +771,118 @@ func TestUpdatePod(t *testing.T) {
 			var returnedPod api.Pod
 			err = json.Unmarshal(resp.Body.Bytes(), &returnedPod)
 			assert.NoError(t, err)
-			assert.Equal(t, updatedPod.Spec.Replicas, returnedPod.Spec.Replicas)
 			assert.Equal(t, updatedPod.Spec.Containers[0].Image, returnedPod.Spec.Containers[0].Image)
 		})
 	})
 
+	t.Run("should return unprocessable entity when the spec changes", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			require.NoError(t, podRegistry.CreatePod(context.Background(), pod))
+
+			pod.Spec.Containers = append(pod.Spec.Containers, api.Container{Name: "sidecar", Image: "envoy:latest"})
+			body, _ := json.Marshal(pod)
+			req := httptest.NewRequest("PUT", "/api/v1/pods/test-pod", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			var status api.Status
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+			assert.Equal(t, []api.FieldError{{Field: "spec.containers", Message: "may not be added or removed, the pod has 1"}}, status.Errors)
+		})
+	})
+
+	t.Run("should return conflict for a stale resource version", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+			require.NoError(t, podRegistry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "test-pod", Status: api.PodRunning}))
+
+			pod.Spec.Containers[0].Image = "nginx:1.27"
+			body, _ := json.Marshal(pod)
+			req := httptest.NewRequest("PUT", "/api/v1/pods/test-pod", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+	})
+
+	t.Run("should return conflict for a status the pod cannot go back to", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				Status:     api.PodSucceeded,
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+			pod, err := podRegistry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+			require.NoError(t, err)
+
+			pod.Status = api.PodPending
+			body, _ := json.Marshal(pod)
+			req := httptest.NewRequest("PUT", "/api/v1/pods/test-pod", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusConflict, resp.Code)
+			assert.Contains(t, resp.Body.String(), "cannot go from Succeeded to Pending")
+		})
+	})
+
+	t.Run("should return forbidden for a mirror pod", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{
+					Name:   "web-node-1",
+					Labels: map[string]string{api.MirrorPodLabel: "node-1"},
+				},
+				Spec: api.PodSpec{
+					Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}},
+				},
+				NodeName: "node-1",
+			}
+			require.NoError(t, podRegistry.CreatePod(context.Background(), pod))
+
+			pod.Spec.Containers[0].Image = "nginx:1.19"
+			body, _ := json.Marshal(pod)
+			req := httptest.NewRequest("PUT", "/api/v1/pods/web-node-1", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
 	t.Run("should return bad request when pod names don't match", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
@@ -531,9 +992,14 @@ func TestUpdatePod(t *testing.T) {
 				ObjectMeta: api.ObjectMeta{
 					Name: "test-pod",
 				},
+				Spec: api.PodSpec{
+					Replicas:   1,
+					Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}},
+				},
+				Status: api.PodPending,
 			}
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *existingPod)
-			mockStore.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
+			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *existingPod).Times(2)
+			mockStore.EXPECT().Txn(gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			pod := &api.Pod{
 				ObjectMeta: api.ObjectMeta{
@@ -590,6 +1056,110 @@ func TestUpdatePod(t *testing.T) {
 	})
 }
 
+func TestUpdatePodStatus(t *testing.T) {
+	createBoundPod := func(t *testing.T, podRegistry *registry.PodRegistry) *api.Pod {
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+			Spec: api.PodSpec{
+				Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}},
+			},
+			NodeName: "node-1",
+			Status:   api.PodScheduled,
+		}
+		require.NoError(t, podRegistry.CreatePod(context.Background(), pod))
+		return pod
+	}
+
+	putStatus := func(container *restful.Container, name string, update *api.PodStatusUpdate) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(update)
+		req := httptest.NewRequest("PUT", "/api/v1/pods/"+name+"/status", bytes.NewReader(body))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("should update status and keep the spec", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			pod := createBoundPod(t, podRegistry)
+
+			resp := putStatus(container, "test-pod", &api.PodStatusUpdate{
+				Name:              "test-pod",
+				UID:               pod.UID,
+				NodeName:          "node-1",
+				Status:            api.PodRunning,
+				ContainerStatuses: []api.ContainerStatus{{Name: "nginx", State: api.ContainerRunning}},
+			})
+
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var returnedPod api.Pod
+			err := json.Unmarshal(resp.Body.Bytes(), &returnedPod)
+			assert.NoError(t, err)
+			assert.Equal(t, api.PodRunning, returnedPod.Status)
+			assert.Equal(t, api.ContainerRunning, returnedPod.ContainerStatuses[0].State)
+			assert.Equal(t, "nginx:latest", returnedPod.Spec.Containers[0].Image)
+			assert.Equal(t, "node-1", returnedPod.NodeName)
+		})
+	})
+
+	t.Run("should return conflict for a pod bound to another node", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			createBoundPod(t, podRegistry)
+
+			resp := putStatus(container, "test-pod", &api.PodStatusUpdate{Name: "test-pod", NodeName: "node-2", Status: api.PodRunning})
+
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+	})
+
+	t.Run("should refuse unknown statuses and statuses the pod cannot reach", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			createBoundPod(t, podRegistry)
+
+			for _, status := range []api.PodStatus{"", "Bogus"} {
+				resp := putStatus(container, "test-pod", &api.PodStatusUpdate{Name: "test-pod", Status: status})
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "status %q", status)
+			}
+			require.Equal(t, http.StatusOK, putStatus(container, "test-pod", &api.PodStatusUpdate{Name: "test-pod", Status: api.PodFailed}).Code)
+			resp := putStatus(container, "test-pod", &api.PodStatusUpdate{Name: "test-pod", Status: api.PodRunning})
+			assert.Equal(t, http.StatusConflict, resp.Code, "a failed pod does not run again")
+
+			pod, err := podRegistry.GetPod(context.Background(), api.DefaultNamespace, "test-pod")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodFailed, pod.Status)
+		})
+	})
+
+	t.Run("should return bad request when pod names don't match", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			createBoundPod(t, podRegistry)
+
+			resp := putStatus(container, "test-pod", &api.PodStatusUpdate{Name: "other-pod", Status: api.PodRunning})
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("should return not found for non-existent pod", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterPodRoutes(ws, NewPodHandler(registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			resp := putStatus(container, "missing", &api.PodStatusUpdate{Name: "missing", Status: api.PodRunning})
+
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+}
+
 func TestDeletePod(t *testing.T) {
 	t.Run("should delete existing pod", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
@@ -628,11 +1198,58 @@ func TestDeletePod(t *testing.T) {
 			assert.Equal(t, http.StatusNoContent, resp.Code)
 
 			// Verify pod is deleted
-			_, err = podRegistry.GetPod(ctx, "test-pod")
+			_, err = podRegistry.GetPod(ctx, api.DefaultNamespace, "test-pod")
 			assert.Error(t, err)
 		})
 	})
 
+	t.Run("should keep a bound pod until the kubelet is done with it", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+			for _, name := range []string{"web", "db"} {
+				require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+					ObjectMeta: api.ObjectMeta{Name: name},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					NodeName:   "node-1",
+					Status:     api.PodRunning,
+				}))
+			}
+
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/pods/web", nil))
+			require.Equal(t, http.StatusOK, resp.Code)
+			var deleting api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &deleting))
+			assert.NotNil(t, deleting.DeletionTimestamp)
+			assert.Equal(t, api.PodTerminating, deleting.Status)
+			assert.Contains(t, deleting.Finalizers, api.PodKubeletFinalizer)
+			_, err := podRegistry.GetPod(ctx, api.DefaultNamespace, "web")
+			assert.NoError(t, err)
+
+			// The kubelet removing its finalizer removes the pod
+			body, _ := json.Marshal(&api.PodStatusUpdate{
+				Name:             "web",
+				Status:           api.PodTerminating,
+				RemoveFinalizers: []string{api.PodKubeletFinalizer},
+			})
+			req := httptest.NewRequest("PUT", "/api/v1/pods/web/status", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp = httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+			_, err = podRegistry.GetPod(ctx, api.DefaultNamespace, "web")
+			assert.ErrorIs(t, err, registry.ErrPodNotFound)
+
+			resp = httptest.NewRecorder()
+			container.ServeHTTP(resp, httptest.NewRequest("DELETE", "/api/v1/pods/db?force=true", nil))
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+			_, err = podRegistry.GetPod(ctx, api.DefaultNamespace, "db")
+			assert.ErrorIs(t, err, registry.ErrPodNotFound, "a forced delete does not wait for the kubelet")
+		})
+	})
+
 	t.Run("should return internal server error for registry failure", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -650,8 +1267,8 @@ func TestDeletePod(t *testing.T) {
 					Name: "test-pod",
 				},
 			}
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *pod)
-			mockStore.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
+			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).SetArg(2, *pod).Times(2)
+			mockStore.EXPECT().Txn(gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			req := httptest.NewRequest("DELETE", "/api/v1/pods/test-pod", nil)
 			resp := httptest.NewRecorder()
@@ -680,6 +1297,83 @@ func TestDeletePod(t *testing.T) {
 	})
 }
 
+func TestEvictPod(t *testing.T) {
+	newPod := func(name string, labels map[string]string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name, Labels: labels},
+			NodeName:   "node-1",
+			Status:     api.PodRunning,
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+		}
+	}
+	evict := func(container *restful.Container, name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(api.Eviction{Reason: "MemoryPressure", Message: "the node is low on memory"})
+		req := httptest.NewRequest("POST", "/api/v1/pods/"+name+"/eviction", bytes.NewReader(body))
+		req.Header.Set("Content-Type", restful.MIME_JSON)
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("should keep the evicted pod until its kubelet stopped it", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			require.NoError(t, podRegistry.CreatePod(context.Background(), newPod("test-pod", nil)))
+
+			resp := evict(container, "test-pod")
+
+			assert.Equal(t, http.StatusCreated, resp.Code)
+			pod, err := podRegistry.GetPod(context.Background(), api.DefaultNamespace, "test-pod")
+			require.NoError(t, err)
+			assert.True(t, pod.IsDeleting())
+			assert.Equal(t, api.PodTerminating, pod.Status)
+			assert.Contains(t, pod.Finalizers, api.PodKubeletFinalizer)
+		})
+	})
+
+	t.Run("should remove an evicted pod that finished at once", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			finished := newPod("test-pod", nil)
+			finished.Status = api.PodSucceeded
+			require.NoError(t, podRegistry.CreatePod(context.Background(), finished))
+
+			resp := evict(container, "test-pod")
+
+			assert.Equal(t, http.StatusCreated, resp.Code)
+			_, err := podRegistry.GetPod(context.Background(), api.DefaultNamespace, "test-pod")
+			assert.ErrorIs(t, err, registry.ErrPodNotFound)
+		})
+	})
+
+	t.Run("should not evict a mirror pod", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			mirror := newPod("static-pod-node-1", map[string]string{api.MirrorPodLabel: "node-1"})
+			require.NoError(t, podRegistry.CreatePod(context.Background(), mirror))
+
+			resp := evict(container, "static-pod-node-1")
+
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			_, err := podRegistry.GetPod(context.Background(), api.DefaultNamespace, "static-pod-node-1")
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("should return not found for non-existent pod", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			RegisterPodRoutes(ws, NewPodHandler(registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))))
+
+			resp := evict(container, "non-existent-pod")
+
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+}
+
 func TestListUnassignedPods(t *testing.T) {
 	t.Run("should list all unassigned pods", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
@@ -721,7 +1415,8 @@ func TestListUnassignedPods(t *testing.T) {
 						},
 					},
 				},
-				Status: api.PodRunning,
+				NodeName: "node-1",
+				Status:   api.PodRunning,
 			}
 
 			err := podRegistry.CreatePod(ctx, unassignedPod)
@@ -747,6 +1442,39 @@ func TestListUnassignedPods(t *testing.T) {
 		})
 	})
 
+	t.Run("should list the pods without a node whatever their status", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+			ctx := context.Background()
+
+			for _, pod := range []*api.Pod{
+				{ObjectMeta: api.ObjectMeta{Name: "bound-pending"}, NodeName: "node-1", Status: api.PodPending},
+				{ObjectMeta: api.ObjectMeta{Name: "unbound-scheduled"}, Status: api.PodScheduled},
+				{ObjectMeta: api.ObjectMeta{Name: "unbound-succeeded"}, Status: api.PodSucceeded},
+			} {
+				pod.Spec = api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}}
+				require.NoError(t, podRegistry.CreatePod(ctx, pod))
+			}
+
+			req := httptest.NewRequest("GET", "/api/v1/pods/unassigned", nil)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var pods []api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+			require.Len(t, pods, 1)
+			assert.Equal(t, "unbound-scheduled", pods[0].Name)
+			assert.Equal(t, api.PodScheduled, pods[0].Status)
+
+			pending, err := podRegistry.ListPendingPods(ctx)
+			require.NoError(t, err)
+			require.Len(t, pending, 1, "the pending pods are still listed by their status alone")
+			assert.Equal(t, "bound-pending", pending[0].Name)
+		})
+	})
+
 	t.Run("should return internal server error for registry failure", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
@@ -758,7 +1486,7 @@ func TestListUnassignedPods(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterPodRoutes(ws, handler)
 
-			mockStore.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
+			mockStore.EXPECT().ListFunc(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			req := httptest.NewRequest("GET", "/api/v1/pods/unassigned", nil)
 			req.Header.Set("Content-Type", restful.MIME_JSON)
@@ -770,3 +1498,60 @@ func TestListUnassignedPods(t *testing.T) {
 		})
 	})
 }
+
+func TestNamespacedPodRoutes(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+		serve := func(method, path string, body any) *httptest.ResponseRecorder {
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+			req := httptest.NewRequest(method, path, bytes.NewReader(data))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+		list := func(path string) []string {
+			resp := serve("GET", path, nil)
+			require.Equal(t, http.StatusOK, resp.Code, path)
+			var pods []api.Pod
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pods))
+			refs := make([]string, 0, len(pods))
+			for _, pod := range pods {
+				refs = append(refs, pod.Namespace+"/"+pod.Name)
+			}
+			return refs
+		}
+		newPod := func(namespace string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "web", Namespace: namespace},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			}
+		}
+
+		// Pods of the same name live side by side in different namespaces
+		resp := serve("POST", "/api/v1/namespaces/staging/pods", newPod(""))
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+		resp = serve("POST", "/api/v1/pods", newPod(""))
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+		resp = serve("POST", "/api/v1/namespaces/staging/pods", newPod("prod"))
+		assert.Equal(t, http.StatusBadRequest, resp.Code, "the namespace of the pod must match the path")
+
+		assert.Equal(t, []string{"default/web", "staging/web"}, list("/api/v1/pods"), "/pods lists every namespace")
+		assert.Equal(t, []string{"staging/web"}, list("/api/v1/namespaces/staging/pods"))
+		assert.Equal(t, []string{"default/web"}, list("/api/v1/namespaces/default/pods?names=web"))
+
+		resp = serve("GET", "/api/v1/namespaces/prod/pods/web", nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		resp = serve("PUT", "/api/v1/namespaces/staging/pods/web/status", &api.PodStatusUpdate{Name: "web", Status: api.PodFailed})
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		staging, err := podRegistry.GetPod(context.Background(), "staging", "web")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodFailed, staging.Status)
+
+		resp = serve("DELETE", "/api/v1/namespaces/staging/pods/web", nil)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, []string{"default/web"}, list("/api/v1/pods"), "the pod of the other namespace is kept")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"gokube/pkg/api"
 	"gokube/pkg/registry"
@@ -26,7 +27,7 @@ const replicasetAttributeKey = "replicaset"
 // LoadReplicasetIntoRequest retrieves the replicaset and stores it in the request attributes
 func (h *ReplicasetHandler) LoadReplicasetIntoRequest(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 	name := req.PathParameter("name")
-	replicaset, err := h.replicasetRegistry.Get(req.Request.Context(), name)
+	replicaset, err := h.replicasetRegistry.Get(req.Request.Context(), pathNamespace(req), name)
 	if err != nil {
 		switch {
 		case errors.Is(err, registry.ErrReplicaSetNotFound):
@@ -40,18 +41,34 @@ func (h *ReplicasetHandler) LoadReplicasetIntoRequest(req *restful.Request, resp
 	chain.ProcessFilter(req, resp)
 }
 
-// CreateReplicaset handles POST requests to create a new Replicaset
+// CreateReplicaset handles POST requests to create a new Replicaset in the namespace of the path, or in the
+// namespace of the replicaset on /replicasets
 func (h *ReplicasetHandler) CreateReplicaset(request *restful.Request, response *restful.Response) {
 	replicaset := new(api.ReplicaSet)
 	if err := request.ReadEntity(replicaset); err != nil {
 		api.WriteError(response, http.StatusBadRequest, err)
 		return
 	}
+	if namespace := request.PathParameter("namespace"); namespace != "" {
+		if replicaset.Namespace == "" {
+			replicaset.Namespace = namespace
+		}
+		if replicaset.Namespace != namespace {
+			api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the replicaset in the request body"))
+			return
+		}
+	}
 
 	if err := h.replicasetRegistry.Create(request.Request.Context(), replicaset); err != nil {
 		switch {
 		case errors.Is(err, registry.ErrReplicaSetExists):
 			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrReplicaSetInvalid):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNamespaceTerminating):
+			api.WriteError(response, http.StatusForbidden, err)
 		default:
 			api.WriteError(response, http.StatusInternalServerError, err)
 		}
@@ -68,7 +85,7 @@ func (h *ReplicasetHandler) GetReplicaset(request *restful.Request, response *re
 		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve replicaset from request attributes"))
 		return
 	}
-	api.WriteResponse(response, http.StatusOK, replicaset)
+	api.WriteResponseWithETag(request, response, api.ObjectETag(replicaset), replicaset)
 }
 
 // UpdateReplicaset handles PUT requests to update a replicaset
@@ -89,15 +106,79 @@ func (h *ReplicasetHandler) UpdateReplicaset(request *restful.Request, response
 		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("replicaset name in URL does not match the replicaset in the request body"))
 		return
 	}
+	if replicaset.Namespace == "" {
+		replicaset.Namespace = existingReplicaset.Namespace
+	}
+	if api.NamespaceOf(&replicaset.ObjectMeta) != api.NamespaceOf(&existingReplicaset.ObjectMeta) {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the replicaset in the request body"))
+		return
+	}
 
 	if err := h.replicasetRegistry.Update(request.Request.Context(), replicaset); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+		switch {
+		case errors.Is(err, registry.ErrReplicaSetInvalid), errors.Is(err, registry.ErrReplicaSetImmutable):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+		case errors.Is(err, registry.ErrReplicaSetConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
 		return
 	}
 
 	api.WriteResponse(response, http.StatusOK, replicaset)
 }
 
+// GetReplicasetScale handles GET requests to the scale subresource of a replicaset
+func (h *ReplicasetHandler) GetReplicasetScale(request *restful.Request, response *restful.Response) {
+	replicaset, ok := request.Attribute(replicasetAttributeKey).(*api.ReplicaSet)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve replicaset from request attributes"))
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, api.NewScale(replicaset))
+}
+
+// UpdateReplicasetScale handles PUT requests to the scale subresource, changing only the replica count
+func (h *ReplicasetHandler) UpdateReplicasetScale(request *restful.Request, response *restful.Response) {
+	existingReplicaset, ok := request.Attribute(replicasetAttributeKey).(*api.ReplicaSet)
+	if !ok {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to retrieve replicaset from request attributes"))
+		return
+	}
+
+	scale := new(api.Scale)
+	if err := request.ReadEntity(scale); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	if scale.Name == "" {
+		scale.Name = existingReplicaset.Name
+	}
+	if existingReplicaset.Name != scale.Name {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("replicaset name in URL does not match the scale in the request body"))
+		return
+	}
+	scale.Namespace = existingReplicaset.Namespace
+
+	replicaset, err := h.replicasetRegistry.UpdateScale(request.Request.Context(), scale)
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrReplicaSetNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrReplicaSetConflict):
+			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrReplicaSetInvalid):
+			api.WriteError(response, http.StatusUnprocessableEntity, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusOK, api.NewScale(replicaset))
+}
+
 // DeleteReplicaset handles DELETE requests to remove a replicaset
 func (h *ReplicasetHandler) DeleteReplicaset(request *restful.Request, response *restful.Response) {
 	replicaset, ok := request.Attribute(replicasetAttributeKey).(*api.ReplicaSet)
@@ -106,23 +187,49 @@ func (h *ReplicasetHandler) DeleteReplicaset(request *restful.Request, response
 		return
 	}
 
-	if err := h.replicasetRegistry.Delete(request.Request.Context(), replicaset.Name); err != nil {
-		api.WriteError(response, http.StatusInternalServerError, err)
+	if err := h.replicasetRegistry.Delete(request.Request.Context(), replicaset.Namespace, replicaset.Name); err != nil {
+		api.WriteError(response, statusOfDelete(err), err)
 		return
 	}
 
 	api.WriteResponse(response, http.StatusNoContent, nil)
 }
 
-// ListReplicasets handles GET requests to list all replicasets
+// ListReplicasets handles GET requests to list the replicasets of the namespace of the path, or of all
+// namespaces on /replicasets. With ?watch=true the changes to them after ?resourceVersion= are streamed instead.
 func (h *ReplicasetHandler) ListReplicasets(request *restful.Request, response *restful.Response) {
-	replicasets, err := h.replicasetRegistry.List(request.Request.Context())
+	namespace := request.PathParameter("namespace")
+	if watchRequested(request) {
+		h.watchReplicasets(request, response, namespace)
+		return
+	}
+
+	replicasets, revision, err := h.replicasetRegistry.ListWithRevision(request.Request.Context(), namespace)
 	if err != nil {
 		api.WriteError(response, http.StatusInternalServerError, err)
 		return
 	}
+	response.Header().Set(api.ResourceVersionHeader, strconv.FormatInt(revision, 10))
 
-	api.WriteResponse(response, http.StatusOK, replicasets)
+	api.WriteResponseWithETag(request, response, api.ListETag(listFilter(request), replicasets), replicasets)
+}
+
+// watchReplicasets streams the changes to the replicasets of namespace, all if empty
+func (h *ReplicasetHandler) watchReplicasets(request *restful.Request, response *restful.Response, namespace string) {
+	revision, err := resourceVersionParameter(request)
+	if err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	events, err := h.replicasetRegistry.Watch(request.Request.Context(), revision)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	serveWatch(request, response, events, func(event registry.ReplicaSetEvent) (any, bool) {
+		rs := event.ReplicaSet
+		return api.ReplicaSetWatchEvent{Type: event.Type, Object: rs}, namespace == "" || api.NamespaceOf(&rs.ObjectMeta) == namespace
+	})
 }
 
 // RegisterReplicasetRoutes registers replicaset routes with the WebService
@@ -132,4 +239,14 @@ func RegisterReplicasetRoutes(ws *restful.WebService, handler *ReplicasetHandler
 	ws.Route(ws.GET("/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.GetReplicaset))
 	ws.Route(ws.PUT("/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.UpdateReplicaset))
 	ws.Route(ws.DELETE("/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.DeleteReplicaset))
+	ws.Route(ws.GET("/replicasets/{name}/scale").Filter(handler.LoadReplicasetIntoRequest).To(handler.GetReplicasetScale))
+	ws.Route(ws.PUT("/replicasets/{name}/scale").Filter(handler.LoadReplicasetIntoRequest).To(handler.UpdateReplicasetScale))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/replicasets").To(handler.CreateReplicaset))
+	ws.Route(ws.GET("/namespaces/{namespace}/replicasets").To(handler.ListReplicasets))
+	ws.Route(ws.GET("/namespaces/{namespace}/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.GetReplicaset))
+	ws.Route(ws.PUT("/namespaces/{namespace}/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.UpdateReplicaset))
+	ws.Route(ws.DELETE("/namespaces/{namespace}/replicasets/{name}").Filter(handler.LoadReplicasetIntoRequest).To(handler.DeleteReplicaset))
+	ws.Route(ws.GET("/namespaces/{namespace}/replicasets/{name}/scale").Filter(handler.LoadReplicasetIntoRequest).To(handler.GetReplicasetScale))
+	ws.Route(ws.PUT("/namespaces/{namespace}/replicasets/{name}/scale").Filter(handler.LoadReplicasetIntoRequest).To(handler.UpdateReplicasetScale))
 }
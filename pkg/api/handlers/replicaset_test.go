@@ -88,7 +88,6 @@ func TestCreateReplicaset(t *testing.T) {
 		withTestServer(t, func(_ *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			RegisterReplicasetRoutes(ws, handler)
 
-			mockStore.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 			mockStore.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("simulated registry failure"))
 
 			replicaset := &api.ReplicaSet{
@@ -165,7 +164,8 @@ func TestCreateReplicaset(t *testing.T) {
 			err := replicasetRegistry.Create(ctx, replicaset)
 			require.NoError(t, err)
 
-			// Try to create same replicaset again
+			// Try to create same replicaset again, without the UID the first one was given
+			replicaset.UID = ""
 			body, _ := json.Marshal(replicaset)
 			req := httptest.NewRequest("POST", "/api/v1/replicasets", bytes.NewReader(body))
 			req.Header.Set("Content-Type", restful.MIME_JSON)
@@ -355,6 +355,38 @@ func TestUpdateReplicaset(t *testing.T) {
 		})
 	})
 
+	t.Run("should reject a change of the selector with unprocessable entity", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			replicasetRegistry := registry.NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry))
+			replicaset := &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: "nginx-rs"},
+				Spec: api.ReplicaSetSpec{
+					Replicas: 2,
+					Selector: map[string]string{"name": "nginx-rs", "tier": "web"},
+					Template: api.PodTemplateSpec{
+						Spec: api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+					},
+				},
+			}
+			require.NoError(t, replicasetRegistry.Create(context.Background(), replicaset))
+
+			replicaset.Spec.Selector = map[string]string{"name": "other"}
+			body, _ := json.Marshal(replicaset)
+			req := httptest.NewRequest("PUT", "/api/v1/replicasets/nginx-rs", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			var status api.Status
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+			assert.Equal(t, []api.FieldError{{Field: "spec.selector", Message: `is immutable, the ReplicaSet selects "name=nginx-rs,tier=web"`}},
+				status.Errors)
+		})
+	})
+
 	t.Run("should return bad request when replicaset names don't match", func(t *testing.T) {
 		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
 			store := storage.NewEtcdStorage(etcdServer)
@@ -430,3 +462,162 @@ func TestUpdateReplicaset(t *testing.T) {
 	})
 
 }
+
+func TestReplicasetMaxReplicas(t *testing.T) {
+	newReplicaset := func(replicas int32) *api.ReplicaSet {
+		return &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "nginx-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: replicas,
+				Selector: map[string]string{"name": "nginx-rs"},
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				},
+			},
+		}
+	}
+
+	t.Run("should reject create above the replica cap with unprocessable entity", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			replicasetRegistry := registry.NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+			RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry))
+
+			body, _ := json.Marshal(newReplicaset(300000))
+			req := httptest.NewRequest("POST", "/api/v1/replicasets", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			var status api.Status
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+			assert.Equal(t, []api.FieldError{{Field: "spec.replicas", Message: "must be at most 1000, got 300000"}}, status.Errors)
+			_, err := replicasetRegistry.Get(context.Background(), api.DefaultNamespace, "nginx-rs")
+			assert.ErrorIs(t, err, registry.ErrReplicaSetNotFound)
+		})
+	})
+
+	t.Run("should reject update above a configured replica cap with unprocessable entity", func(t *testing.T) {
+		withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+			replicasetRegistry := registry.NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+			replicasetRegistry.SetMaxReplicas(5)
+			RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry))
+			require.NoError(t, replicasetRegistry.Create(context.Background(), newReplicaset(5)))
+
+			body, _ := json.Marshal(newReplicaset(6))
+			req := httptest.NewRequest("PUT", "/api/v1/replicasets/nginx-rs", bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+
+			container.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+	})
+}
+
+func TestReplicasetScale(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		replicasetRegistry := registry.NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+		replicasetRegistry.SetMaxReplicas(5)
+		RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry))
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "nginx-rs"},
+			Spec:       api.ReplicaSetSpec{Replicas: 2, Selector: map[string]string{"name": "nginx-rs"}},
+			Status:     api.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 1},
+		}
+		require.NoError(t, replicasetRegistry.Create(context.Background(), rs))
+
+		serve := func(method, path string, scale *api.Scale) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(scale)
+			req := httptest.NewRequest(method, path, bytes.NewReader(body))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := serve("GET", "/api/v1/replicasets/nginx-rs/scale", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var scale api.Scale
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &scale))
+		assert.Equal(t, rs.UID, scale.UID)
+		assert.Equal(t, api.ScaleSpec{Replicas: 2}, scale.Spec)
+		assert.Equal(t, api.ScaleStatus{Replicas: 2, ReadyReplicas: 1}, scale.Status)
+
+		scale.Spec.Replicas = 4
+		resp = serve("PUT", "/api/v1/replicasets/nginx-rs/scale", &scale)
+		require.Equal(t, http.StatusOK, resp.Code)
+		stored, err := replicasetRegistry.Get(context.Background(), api.DefaultNamespace, "nginx-rs")
+		require.NoError(t, err)
+		assert.Equal(t, int32(4), stored.Spec.Replicas)
+
+		scale.Spec.Replicas = 6
+		assert.Equal(t, http.StatusUnprocessableEntity, serve("PUT", "/api/v1/replicasets/nginx-rs/scale", &scale).Code)
+		scale.Spec.Replicas, scale.UID = 1, "uid-0"
+		assert.Equal(t, http.StatusConflict, serve("PUT", "/api/v1/replicasets/nginx-rs/scale", &scale).Code)
+		scale.Name = "other-rs"
+		assert.Equal(t, http.StatusBadRequest, serve("PUT", "/api/v1/replicasets/nginx-rs/scale", &scale).Code)
+		assert.Equal(t, http.StatusNotFound, serve("PUT", "/api/v1/replicasets/missing/scale", &api.Scale{}).Code)
+	})
+}
+
+func TestNamespacedReplicasetRoutes(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		replicasetRegistry := registry.NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry))
+		serve := func(method, path string, body any) *httptest.ResponseRecorder {
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+			req := httptest.NewRequest(method, path, bytes.NewReader(data))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+		list := func(path string) []string {
+			resp := serve("GET", path, nil)
+			require.Equal(t, http.StatusOK, resp.Code, path)
+			var replicasets []api.ReplicaSet
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &replicasets))
+			refs := make([]string, 0, len(replicasets))
+			for _, rs := range replicasets {
+				refs = append(refs, rs.Namespace+"/"+rs.Name)
+			}
+			return refs
+		}
+		newReplicaset := func(namespace string, replicas int32) *api.ReplicaSet {
+			return &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: "nginx-rs", Namespace: namespace},
+				Spec:       api.ReplicaSetSpec{Replicas: replicas, Selector: map[string]string{"name": "nginx-rs"}},
+			}
+		}
+
+		// ReplicaSets of the same name live side by side in different namespaces
+		resp := serve("POST", "/api/v1/namespaces/staging/replicasets", newReplicaset("", 3))
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+		resp = serve("POST", "/api/v1/replicasets", newReplicaset("", 1))
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+		resp = serve("POST", "/api/v1/namespaces/staging/replicasets", newReplicaset("prod", 1))
+		assert.Equal(t, http.StatusBadRequest, resp.Code, "the namespace of the replicaset must match the path")
+
+		assert.Equal(t, []string{"default/nginx-rs", "staging/nginx-rs"}, list("/api/v1/replicasets"), "/replicasets lists every namespace")
+		assert.Equal(t, []string{"staging/nginx-rs"}, list("/api/v1/namespaces/staging/replicasets"))
+
+		resp = serve("GET", "/api/v1/namespaces/prod/replicasets/nginx-rs", nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		resp = serve("PUT", "/api/v1/namespaces/staging/replicasets/nginx-rs/scale", &api.Scale{ObjectMeta: api.ObjectMeta{Name: "nginx-rs"}, Spec: api.ScaleSpec{Replicas: 5}})
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		staging, err := replicasetRegistry.Get(context.Background(), "staging", "nginx-rs")
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), staging.Spec.Replicas)
+		stored, err := replicasetRegistry.Get(context.Background(), api.DefaultNamespace, "nginx-rs")
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), stored.Spec.Replicas, "the replicaset of the other namespace is not scaled")
+
+		resp = serve("DELETE", "/api/v1/namespaces/staging/replicasets/nginx-rs", nil)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, []string{"default/nginx-rs"}, list("/api/v1/replicasets"), "the replicaset of the other namespace is kept")
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// ResourceQuotaHandler handles ResourceQuota-related HTTP requests. ResourceQuotas are addressed within
+// their namespace, e.g. /namespaces/staging/resourcequotas/compute.
+type ResourceQuotaHandler struct {
+	resourceQuotaRegistry *registry.ResourceQuotaRegistry
+}
+
+// NewResourceQuotaHandler creates a new ResourceQuotaHandler
+func NewResourceQuotaHandler(resourceQuotaRegistry *registry.ResourceQuotaRegistry) *ResourceQuotaHandler {
+	return &ResourceQuotaHandler{resourceQuotaRegistry: resourceQuotaRegistry}
+}
+
+// CreateResourceQuota handles POST requests to create a new ResourceQuota in the namespace of the path
+func (h *ResourceQuotaHandler) CreateResourceQuota(request *restful.Request, response *restful.Response) {
+	quota := new(api.ResourceQuota)
+	if err := request.ReadEntity(quota); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	namespace := request.PathParameter("namespace")
+	if quota.Namespace == "" {
+		quota.Namespace = namespace
+	}
+	if quota.Namespace != namespace {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the resource quota in the request body"))
+		return
+	}
+
+	if err := h.resourceQuotaRegistry.Create(request.Request.Context(), quota); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrResourceQuotaAlreadyExists):
+			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrResourceQuotaInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNamespaceTerminating):
+			api.WriteError(response, http.StatusForbidden, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, quota)
+}
+
+// GetResourceQuota handles GET requests to retrieve a ResourceQuota with what its namespace uses
+func (h *ResourceQuotaHandler) GetResourceQuota(request *restful.Request, response *restful.Response) {
+	quota, err := h.resourceQuotaRegistry.Get(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrResourceQuotaNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, quota)
+}
+
+// ListResourceQuotas handles GET requests to list the ResourceQuotas of a namespace
+func (h *ResourceQuotaHandler) ListResourceQuotas(request *restful.Request, response *restful.Response) {
+	quotas, err := h.resourceQuotaRegistry.List(request.Request.Context(), request.PathParameter("namespace"))
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, quotas)
+}
+
+// DeleteResourceQuota handles DELETE requests to delete a ResourceQuota
+func (h *ResourceQuotaHandler) DeleteResourceQuota(request *restful.Request, response *restful.Response) {
+	if err := h.resourceQuotaRegistry.Delete(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name")); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrResourceQuotaNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusNoContent, nil)
+}
+
+// RegisterResourceQuotaRoutes registers ResourceQuota routes with the WebService
+func RegisterResourceQuotaRoutes(ws *restful.WebService, handler *ResourceQuotaHandler) {
+	ws.Route(ws.POST("/namespaces/{namespace}/resourcequotas").To(handler.CreateResourceQuota))
+	ws.Route(ws.GET("/namespaces/{namespace}/resourcequotas").To(handler.ListResourceQuotas))
+	ws.Route(ws.GET("/namespaces/{namespace}/resourcequotas/{name}").To(handler.GetResourceQuota))
+	ws.Route(ws.DELETE("/namespaces/{namespace}/resourcequotas/{name}").To(handler.DeleteResourceQuota))
+}
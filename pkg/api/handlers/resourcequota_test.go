@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestResourceQuotas(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		store := storage.NewEtcdStorage(etcdServer)
+		quotaRegistry := registry.NewResourceQuotaRegistry(store)
+		podRegistry := registry.NewPodRegistry(store)
+		podRegistry.SetResourceQuotaRegistry(quotaRegistry)
+		RegisterResourceQuotaRoutes(ws, NewResourceQuotaHandler(quotaRegistry))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+
+		serve := func(method, path string, body any) *httptest.ResponseRecorder {
+			data, _ := json.Marshal(body)
+			req := httptest.NewRequest(method, path, bytes.NewReader(data))
+			req.Header.Set("Content-Type", restful.MIME_JSON)
+			resp := httptest.NewRecorder()
+			container.ServeHTTP(resp, req)
+			return resp
+		}
+
+		quota := &api.ResourceQuota{
+			ObjectMeta: api.ObjectMeta{Name: "compute"},
+			Spec:       api.ResourceQuotaSpec{Hard: api.ResourceList{api.ResourceCPU: "1"}},
+		}
+		resp := serve(http.MethodPost, "/api/v1/namespaces/staging/resourcequotas", quota)
+		require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+		quota.Namespace = "production"
+		resp = serve(http.MethodPost, "/api/v1/namespaces/staging/resourcequotas", quota)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		for i, cpu := range []string{"750m", "500m"} {
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: fmt.Sprintf("web-%d", i), Namespace: "staging"},
+				Spec: api.PodSpec{Containers: []api.Container{{
+					Name: "nginx", Image: "nginx", Resources: &api.ResourceRequirements{Requests: api.ResourceList{api.ResourceCPU: cpu}},
+				}}},
+			}
+			resp = serve(http.MethodPost, "/api/v1/pods", pod)
+			if i == 0 {
+				require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+				continue
+			}
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			var status api.Status
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+			assert.Contains(t, status.Message, "exceeded quota compute in namespace staging: cpu requested 500m, used 750m, limited to 1")
+		}
+
+		resp = serve(http.MethodGet, "/api/v1/namespaces/staging/resourcequotas/compute", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var got api.ResourceQuota
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+		assert.Equal(t, api.ResourceList{api.ResourceCPU: "750m"}, got.Status.Used)
+
+		resp = serve(http.MethodGet, "/api/v1/namespaces/production/resourcequotas/compute", nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		resp = serve(http.MethodDelete, "/api/v1/namespaces/staging/resourcequotas/compute", nil)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		resp = serve(http.MethodGet, "/api/v1/namespaces/staging/resourcequotas", nil)
+		require.Equal(t, http.StatusOK, resp.Code)
+		var quotas []*api.ResourceQuota
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &quotas))
+		assert.Empty(t, quotas)
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// ServiceHandler handles Service-related HTTP requests. Services are addressed within their namespace, e.g.
+// /namespaces/staging/services/web, and listed across namespaces on /services.
+type ServiceHandler struct {
+	serviceRegistry *registry.ServiceRegistry
+}
+
+// NewServiceHandler creates a new ServiceHandler
+func NewServiceHandler(serviceRegistry *registry.ServiceRegistry) *ServiceHandler {
+	return &ServiceHandler{serviceRegistry: serviceRegistry}
+}
+
+// CreateService handles POST requests to create a new Service in the namespace of the path
+func (h *ServiceHandler) CreateService(request *restful.Request, response *restful.Response) {
+	service := new(api.Service)
+	if err := request.ReadEntity(service); err != nil {
+		api.WriteError(response, http.StatusBadRequest, err)
+		return
+	}
+	namespace := request.PathParameter("namespace")
+	if service.Namespace == "" {
+		service.Namespace = namespace
+	}
+	if service.Namespace != namespace {
+		api.WriteError(response, http.StatusBadRequest, fmt.Errorf("namespace in URL does not match the service in the request body"))
+		return
+	}
+
+	if err := h.serviceRegistry.Create(request.Request.Context(), service); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrServiceAlreadyExists):
+			api.WriteError(response, http.StatusConflict, err)
+		case errors.Is(err, registry.ErrServiceInvalid):
+			api.WriteError(response, http.StatusBadRequest, err)
+		case errors.Is(err, registry.ErrNamespaceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		case errors.Is(err, registry.ErrNamespaceTerminating):
+			api.WriteError(response, http.StatusForbidden, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	api.WriteResponse(response, http.StatusCreated, service)
+}
+
+// GetService handles GET requests to retrieve a Service
+func (h *ServiceHandler) GetService(request *restful.Request, response *restful.Response) {
+	service, err := h.serviceRegistry.Get(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, registry.ErrServiceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, service)
+}
+
+// ListServices handles GET requests to list the Services of a namespace, or of all namespaces on /services
+func (h *ServiceHandler) ListServices(request *restful.Request, response *restful.Response) {
+	services, err := h.serviceRegistry.List(request.Request.Context(), request.PathParameter("namespace"))
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, services)
+}
+
+// DeleteService handles DELETE requests to delete a Service
+func (h *ServiceHandler) DeleteService(request *restful.Request, response *restful.Response) {
+	if err := h.serviceRegistry.Delete(request.Request.Context(), request.PathParameter("namespace"), request.PathParameter("name")); err != nil {
+		switch {
+		case errors.Is(err, registry.ErrServiceNotFound):
+			api.WriteError(response, http.StatusNotFound, err)
+		default:
+			api.WriteError(response, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	api.WriteResponse(response, http.StatusNoContent, nil)
+}
+
+// RegisterServiceRoutes registers Service routes with the WebService
+func RegisterServiceRoutes(ws *restful.WebService, handler *ServiceHandler) {
+	ws.Route(ws.GET("/services").To(handler.ListServices))
+	ws.Route(ws.POST("/namespaces/{namespace}/services").To(handler.CreateService))
+	ws.Route(ws.GET("/namespaces/{namespace}/services").To(handler.ListServices))
+	ws.Route(ws.GET("/namespaces/{namespace}/services/{name}").To(handler.GetService))
+	ws.Route(ws.DELETE("/namespaces/{namespace}/services/{name}").To(handler.DeleteService))
+}
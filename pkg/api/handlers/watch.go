@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+)
+
+// watchRequested checks if a list request asks for the changes to the objects rather than the objects, with
+// ?watch=true
+func watchRequested(request *restful.Request) bool {
+	return request.QueryParameter("watch") == "true"
+}
+
+// resourceVersionParameter parses the ?resourceVersion= parameter of a watch, after whose change the watch
+// starts. It returns 0, for the changes from now on, without one.
+func resourceVersionParameter(request *restful.Request) (int64, error) {
+	resourceVersion := request.QueryParameter("resourceVersion")
+	if resourceVersion == "" {
+		return 0, nil
+	}
+	revision, err := strconv.ParseInt(resourceVersion, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, fmt.Errorf("resourceVersion must be a non-negative integer, got %q", resourceVersion)
+	}
+	return revision, nil
+}
+
+// serveWatch streams the events of a registry watch as newline-delimited JSON, each as the watch event toEvent
+// makes of it, until the watch ends or the client goes away. Events toEvent does not keep are left out. The
+// watch ends when the client falls too far behind or asked for changes the storage no longer has, after which
+// the client lists the objects again and watches from the resource version of the list.
+func serveWatch[E any](request *restful.Request, response *restful.Response, events <-chan E, toEvent func(E) (any, bool)) {
+	response.Header().Set("Content-Type", api.WatchContentType)
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	encoder := json.NewEncoder(response)
+	for event := range events {
+		watchEvent, ok := toEvent(event)
+		if !ok {
+			continue
+		}
+		if err := encoder.Encode(watchEvent); err != nil {
+			// The client went away; the watch ends with the context of the request
+			slog.DebugContext(request.Request.Context(), "Failed to write watch event", logging.Err(err))
+			return
+		}
+		response.Flush()
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+// listResourceVersion lists path on the server and returns the resource version the list was read at
+func listResourceVersion(t *testing.T, server *httptest.Server, path string) string {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resourceVersion := resp.Header.Get(api.ResourceVersionHeader)
+	require.NotEmpty(t, resourceVersion)
+	return resourceVersion
+}
+
+// openWatch opens a watch on path and returns the function reading its next event as "TYPE name"
+func openWatch[E any](t *testing.T, ctx context.Context, server *httptest.Server, path string, describe func(E) string) func() string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, api.WatchContentType, resp.Header.Get("Content-Type"))
+
+	decoder := json.NewDecoder(resp.Body)
+	return func() string {
+		var event E
+		require.NoError(t, decoder.Decode(&event))
+		return describe(event)
+	}
+}
+
+func TestWatchPods(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		podRegistry := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterPodRoutes(ws, NewPodHandler(podRegistry))
+		server := httptest.NewServer(container)
+		defer server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		newPod := func(name, nodeName string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				NodeName:   nodeName,
+			}
+		}
+
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("web-1", "node-1")))
+		resourceVersion := listResourceVersion(t, server, "/api/v1/pods")
+		// Changes made between the list and the watch are not missed
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("web-2", "node-2")))
+		require.NoError(t, podRegistry.CreatePod(ctx, newPod("web-3", "node-1")))
+		next := openWatch(t, ctx, server, "/api/v1/pods?watch=true&nodeName=node-1&resourceVersion="+resourceVersion,
+			func(event api.PodWatchEvent) string { return fmt.Sprintf("%s %s", event.Type, event.Object.Name) })
+		pod, err := podRegistry.GetPod(ctx, api.DefaultNamespace, "web-1")
+		require.NoError(t, err)
+		pod.Status = api.PodRunning
+		require.NoError(t, podRegistry.UpdatePod(ctx, pod))
+		require.NoError(t, podRegistry.DeletePod(ctx, api.DefaultNamespace, "web-3"))
+
+		assert.Equal(t, "ADDED web-3", next())
+		assert.Equal(t, "MODIFIED web-1", next())
+		assert.Equal(t, "DELETED web-3", next())
+
+		for _, path := range []string{"/api/v1/pods?watch=true&status=Running", "/api/v1/pods?watch=true&limit=1",
+			"/api/v1/pods?watch=true&resourceVersion=latest"} {
+			resp, err := http.Get(server.URL + path)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+		}
+	})
+}
+
+func TestWatchNodes(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		nodeRegistry := registry.NewNodeRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterNodeRoutes(ws, NewNodeHandler(nodeRegistry))
+		server := httptest.NewServer(container)
+		defer server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		resourceVersion := listResourceVersion(t, server, "/api/v1/nodes")
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1"}}))
+		next := openWatch(t, ctx, server, "/api/v1/nodes?watch=true&resourceVersion="+resourceVersion,
+			func(event api.NodeWatchEvent) string { return fmt.Sprintf("%s %s", event.Type, event.Object.Name) })
+		require.NoError(t, nodeRegistry.DeleteNode(ctx, "node-1"))
+
+		assert.Equal(t, "ADDED node-1", next())
+		assert.Equal(t, "DELETED node-1", next())
+	})
+}
+
+func TestWatchReplicasets(t *testing.T) {
+	withTestServer(t, func(etcdServer *clientv3.Client, ws *restful.WebService, container *restful.Container) {
+		replicasetRegistry := registry.NewReplicaSetRegistry(storage.NewEtcdStorage(etcdServer))
+		RegisterReplicasetRoutes(ws, NewReplicasetHandler(replicasetRegistry))
+		server := httptest.NewServer(container)
+		defer server.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		newReplicaset := func(namespace string) *api.ReplicaSet {
+			return &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: "nginx-rs", Namespace: namespace},
+				Spec:       api.ReplicaSetSpec{Replicas: 1, Selector: map[string]string{"name": "nginx-rs"}},
+			}
+		}
+
+		// Only the ReplicaSets of the namespace of the path are watched
+		next := openWatch(t, ctx, server, "/api/v1/namespaces/staging/replicasets?watch=true",
+			func(event api.ReplicaSetWatchEvent) string {
+				return fmt.Sprintf("%s %s/%s", event.Type, event.Object.Namespace, event.Object.Name)
+			})
+		require.NoError(t, replicasetRegistry.Create(ctx, newReplicaset(api.DefaultNamespace)))
+		require.NoError(t, replicasetRegistry.Create(ctx, newReplicaset("staging")))
+		require.NoError(t, replicasetRegistry.Delete(ctx, "staging", "nginx-rs"))
+
+		assert.Equal(t, "ADDED staging/nginx-rs", next())
+		assert.Equal(t, "DELETED staging/nginx-rs", next())
+	})
+}
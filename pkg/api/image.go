@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/distribution/reference"
+)
+
+var (
+	ErrInvalidImage = errors.New("invalid image reference")
+)
+
+// ValidateImage checks that ref names an image by tag, such as nginx or nginx:1.27, or by digest, such as
+// nginx@sha256:<digest>
+func ValidateImage(ref string) error {
+	if _, err := reference.ParseNormalizedNamed(ref); err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidImage, ref, err)
+	}
+	return nil
+}
+
+// ImageDigest returns the digest an image reference pins, or "" if it names an image by tag. A digest names
+// immutable content, so an image pulled by digest never has to be pulled again.
+func ImageDigest(ref string) string {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ""
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		return digested.Digest().String()
+	}
+	return ""
+}
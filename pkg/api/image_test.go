@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nginxDigest = "sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+
+func TestValidateImage(t *testing.T) {
+	valid := []string{
+		"nginx",
+		"nginx:1.27",
+		"library/nginx:latest",
+		"registry.example.com:5000/team/app:v1",
+		"nginx@" + nginxDigest,
+		"nginx:1.27@" + nginxDigest,
+	}
+	for _, ref := range valid {
+		assert.NoError(t, ValidateImage(ref), ref)
+	}
+
+	invalid := []string{
+		"",
+		"Nginx",
+		"nginx:",
+		"nginx@sha256:short",
+		"nginx@md5:0d17b565c37bcbd895e9d92315a05c1c",
+	}
+	for _, ref := range invalid {
+		assert.ErrorIs(t, ValidateImage(ref), ErrInvalidImage, ref)
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	assert.Equal(t, nginxDigest, ImageDigest("nginx@"+nginxDigest))
+	assert.Equal(t, nginxDigest, ImageDigest("nginx:1.27@"+nginxDigest))
+	assert.Empty(t, ImageDigest("nginx:latest"))
+	assert.Empty(t, ImageDigest("nginx"))
+}
+
+func TestPodValidationRejectsInvalidImages(t *testing.T) {
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: "pod"},
+		Spec:       PodSpec{Containers: []Container{{Name: "web", Image: "nginx@" + nginxDigest}}},
+	}
+	assert.NoError(t, pod.Validate())
+
+	pod.Spec.Containers[0].Image = "nginx@sha256:short"
+	assert.ErrorIs(t, pod.Validate(), ErrInvalidPodSpec)
+}
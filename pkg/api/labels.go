@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSelector is returned for a label selector that is not key=value pairs separated by commas
+var ErrInvalidSelector = errors.New("invalid label selector")
+
+// SelectorMatches checks if every key/value pair of the selector is present in labels.
+// An empty selector matches everything.
+func SelectorMatches(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if labelValue, ok := labels[key]; !ok || labelValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSelector parses an equality-based label selector such as app=web,tier=frontend, also accepting == for =.
+// An empty selector selects everything.
+func ParseSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if strings.TrimSpace(selector) == "" {
+		return labels, nil
+	}
+	for _, requirement := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(requirement, "=")
+		value = strings.TrimPrefix(value, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || strings.HasSuffix(key, "!") {
+			return nil, fmt.Errorf("%w %q: expected key=value pairs separated by commas", ErrInvalidSelector, selector)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// FormatSelector formats selector the way ParseSelector reads it, with the keys in order
+func FormatSelector(selector map[string]string) string {
+	requirements := make([]string, 0, len(selector))
+	for key, value := range selector {
+		requirements = append(requirements, key+"="+value)
+	}
+	slices.Sort(requirements)
+	return strings.Join(requirements, ",")
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend"}
+
+	tests := []struct {
+		name     string
+		selector map[string]string
+		expected bool
+	}{
+		{name: "empty selector matches everything", selector: map[string]string{}, expected: true},
+		{name: "all pairs present", selector: map[string]string{"app": "web", "tier": "frontend"}, expected: true},
+		{name: "subset of pairs present", selector: map[string]string{"app": "web"}, expected: true},
+		{name: "value differs", selector: map[string]string{"app": "db"}, expected: false},
+		{name: "key missing", selector: map[string]string{"app": "web", "env": "prod"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SelectorMatches(tt.selector, labels))
+		})
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	labels, err := ParseSelector("app=web, tier==frontend,empty=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "web", "tier": "frontend", "empty": ""}, labels)
+
+	labels, err = ParseSelector(" ")
+	require.NoError(t, err)
+	assert.Empty(t, labels, "an empty selector selects everything")
+
+	for _, invalid := range []string{"app", "app!=web", "=web", "app=web,"} {
+		_, err := ParseSelector(invalid)
+		assert.ErrorIs(t, err, ErrInvalidSelector, invalid)
+	}
+}
+
+func TestFormatSelector(t *testing.T) {
+	selector := map[string]string{"tier": "frontend", "app": "web"}
+	assert.Equal(t, "app=web,tier=frontend", FormatSelector(selector))
+
+	parsed, err := ParseSelector(FormatSelector(selector))
+	require.NoError(t, err)
+	assert.Equal(t, selector, parsed)
+	assert.Empty(t, FormatSelector(nil))
+}
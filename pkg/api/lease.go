@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidLease = errors.New("invalid lease")
+
+// MinLeaseDurationSeconds is the shortest lease etcd can expire reliably
+const MinLeaseDurationSeconds = 2
+
+// Lease tells that its holder is alive for as long as it keeps renewing it. Every kubelet holds the lease
+// named after its node, which is much cheaper to renew than reporting the whole node status, and which the
+// API server deletes once it is not renewed for its duration.
+type Lease struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	// HolderIdentity names the holder of the lease, the UID of the machine for a node lease
+	HolderIdentity string `json:"holderIdentity,omitempty"`
+	// LeaseDurationSeconds is how long the lease lasts after it was last renewed
+	LeaseDurationSeconds int32 `json:"leaseDurationSeconds"`
+	// AcquireTime is when the lease was acquired. Renewals do not change the stored lease, so it has no renew
+	// time; a lease that is stored is alive.
+	AcquireTime time.Time `json:"acquireTime,omitempty"`
+}
+
+// Duration returns how long the lease lasts after it was last renewed
+func (l *Lease) Duration() time.Duration {
+	return time.Duration(l.LeaseDurationSeconds) * time.Second
+}
+
+// Validate checks that the lease is named after a node and lasts at least MinLeaseDurationSeconds
+func (l *Lease) Validate() error {
+	if err := ValidateNodeName(l.Name); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLease, err)
+	}
+	if l.LeaseDurationSeconds < MinLeaseDurationSeconds {
+		return fmt.Errorf("%w: %w", ErrInvalidLease, FieldErrors{{
+			Field:   "leaseDurationSeconds",
+			Message: fmt.Sprintf("must be at least %d, got %d", MinLeaseDurationSeconds, l.LeaseDurationSeconds),
+		}})
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var ErrInvalidLimitRange = errors.New("invalid limit range")
+
+const KindLimitRange = "LimitRange"
+
+// LimitRange sets the resources of the containers of its namespace: defaults for the containers that leave
+// them out, and bounds that all containers must keep within
+type LimitRange struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       LimitRangeSpec `json:"spec"`
+}
+
+// LimitRangeSpec is the defaults and bounds of a LimitRange, which apply to each container
+type LimitRangeSpec struct {
+	// Default is the limit of a container that sets none
+	Default ResourceList `json:"default,omitempty"`
+	// DefaultRequest is the request of a container that sets none. Without it the request defaults to the
+	// limit of the container.
+	DefaultRequest ResourceList `json:"defaultRequest,omitempty"`
+	// Min is the least a container may request
+	Min ResourceList `json:"min,omitempty"`
+	// Max is the most a container may request or limit
+	Max ResourceList `json:"max,omitempty"`
+}
+
+// Validate checks that the limit range is named by a lowercase RFC 1123 label and sets cpu and memory only,
+// with defaults between its bounds
+func (l *LimitRange) Validate() error {
+	var fieldErrs FieldErrors
+	if !isLabelName(l.Name) {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   "metadata.name",
+			Message: fmt.Sprintf("must be a lowercase RFC 1123 label, such as limits, got %q", l.Name),
+		})
+	}
+	lists := []struct {
+		field     string
+		resources ResourceList
+	}{
+		{"spec.default", l.Spec.Default},
+		{"spec.defaultRequest", l.Spec.DefaultRequest},
+		{"spec.min", l.Spec.Min},
+		{"spec.max", l.Spec.Max},
+	}
+	for _, list := range lists {
+		fieldErrs = append(fieldErrs, validateContainerResources(list.field, list.resources)...)
+	}
+	if len(fieldErrs) == 0 {
+		// Default requests must keep within both bounds, default limits and minimums below the maximum
+		for _, list := range lists[:3] {
+			maxOnly := list.field != "spec.defaultRequest"
+			for _, resource := range slices.Sorted(maps.Keys(list.resources)) {
+				if message := l.checkBounds(resource, list.resources[resource], maxOnly, ""); message != "" {
+					fieldErrs = append(fieldErrs, FieldError{Field: list.field + "." + string(resource), Message: message})
+				}
+			}
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidLimitRange, fieldErrs)
+	}
+	return nil
+}
+
+// ApplyDefaults sets the default limits and requests on the containers of the pod that leave them out
+func (l *LimitRange) ApplyDefaults(pod *Pod) {
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		resources := container.Resources
+		if resources == nil {
+			resources = &ResourceRequirements{}
+		}
+		for resource, quantity := range l.Spec.Default {
+			if _, ok := resources.Limits[resource]; !ok {
+				resources.Limits = setQuantity(resources.Limits, resource, quantity)
+			}
+		}
+		for resource, quantity := range l.Spec.DefaultRequest {
+			if _, ok := resources.Requests[resource]; !ok {
+				resources.Requests = setQuantity(resources.Requests, resource, quantity)
+			}
+		}
+		// Like Kubernetes, a container that is only limited requests its limit
+		for resource, quantity := range resources.Limits {
+			if _, ok := resources.Requests[resource]; !ok {
+				resources.Requests = setQuantity(resources.Requests, resource, quantity)
+			}
+		}
+		if len(resources.Requests) > 0 || len(resources.Limits) > 0 {
+			container.Resources = resources
+		}
+	}
+}
+
+// Check reports the containers of the pod whose requests or limits are outside the bounds of the limit range.
+// A container must request each resource the limit range sets a minimum for.
+func (l *LimitRange) Check(pod *Pod) FieldErrors {
+	var fieldErrs FieldErrors
+	for i, container := range pod.Spec.Containers {
+		var requests, limits ResourceList
+		if container.Resources != nil {
+			requests, limits = container.Resources.Requests, container.Resources.Limits
+		}
+		prefix := fmt.Sprintf("spec.containers[%d].resources", i)
+		for _, resource := range slices.Sorted(maps.Keys(l.Spec.Min)) {
+			if _, ok := requests[resource]; !ok {
+				fieldErrs = append(fieldErrs, FieldError{
+					Field:   prefix + ".requests." + string(resource),
+					Message: fmt.Sprintf("is required by limit range %s, which sets a minimum of %s", l.Name, l.Spec.Min[resource]),
+				})
+			}
+		}
+		for _, list := range []struct {
+			field     string
+			resources ResourceList
+			checkMin  bool
+		}{{prefix + ".requests", requests, true}, {prefix + ".limits", limits, false}} {
+			for _, resource := range slices.Sorted(maps.Keys(list.resources)) {
+				if message := l.checkBounds(resource, list.resources[resource], !list.checkMin, " under limit range "+l.Name); message != "" {
+					fieldErrs = append(fieldErrs, FieldError{Field: list.field + "." + string(resource), Message: message})
+				}
+			}
+		}
+	}
+	return fieldErrs
+}
+
+// checkBounds describes how a quantity is outside the bounds of the limit range, or returns an empty string
+// if it is within them. Limits may exceed the minimum and are only checked against the maximum. The bound is
+// followed by under, which names where it comes from.
+func (l *LimitRange) checkBounds(resource ResourceName, quantity string, maxOnly bool, under string) string {
+	value, err := ParseQuantity(resource, quantity)
+	if err != nil {
+		// Reported by the validation of the quantity
+		return ""
+	}
+	if min, ok := l.Spec.Min[resource]; ok && !maxOnly {
+		if bound, err := ParseQuantity(resource, min); err == nil && value < bound {
+			return fmt.Sprintf("must be at least %s%s, got %s", min, under, quantity)
+		}
+	}
+	if max, ok := l.Spec.Max[resource]; ok {
+		if bound, err := ParseQuantity(resource, max); err == nil && value > bound {
+			return fmt.Sprintf("must be at most %s%s, got %s", max, under, quantity)
+		}
+	}
+	return ""
+}
+
+func setQuantity(resources ResourceList, resource ResourceName, quantity string) ResourceList {
+	if resources == nil {
+		resources = ResourceList{}
+	}
+	resources[resource] = quantity
+	return resources
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLimitRange() *LimitRange {
+	return &LimitRange{
+		ObjectMeta: ObjectMeta{Name: "limits"},
+		Spec: LimitRangeSpec{
+			Default:        ResourceList{ResourceCPU: "500m", ResourceMemory: "256Mi"},
+			DefaultRequest: ResourceList{ResourceCPU: "100m"},
+			Min:            ResourceList{ResourceCPU: "50m"},
+			Max:            ResourceList{ResourceCPU: "2", ResourceMemory: "1Gi"},
+		},
+	}
+}
+
+func newLimitedPod(resources ...*ResourceRequirements) *Pod {
+	pod := &Pod{ObjectMeta: ObjectMeta{Name: "web"}}
+	for _, r := range resources {
+		pod.Spec.Containers = append(pod.Spec.Containers, Container{Name: "app", Image: "nginx", Resources: r})
+	}
+	return pod
+}
+
+func TestLimitRangeApplyDefaults(t *testing.T) {
+	limitRange := newLimitRange()
+	require.NoError(t, limitRange.Validate())
+
+	t.Run("should default containers without resources", func(t *testing.T) {
+		pod := newLimitedPod(nil)
+		limitRange.ApplyDefaults(pod)
+		assert.Equal(t, &ResourceRequirements{
+			Requests: ResourceList{ResourceCPU: "100m", ResourceMemory: "256Mi"},
+			Limits:   ResourceList{ResourceCPU: "500m", ResourceMemory: "256Mi"},
+		}, pod.Spec.Containers[0].Resources)
+		assert.Empty(t, limitRange.Check(pod))
+	})
+
+	t.Run("should only fill in what a container leaves out", func(t *testing.T) {
+		pod := newLimitedPod(&ResourceRequirements{Limits: ResourceList{ResourceMemory: "512Mi"}})
+		limitRange.ApplyDefaults(pod)
+		assert.Equal(t, &ResourceRequirements{
+			Requests: ResourceList{ResourceCPU: "100m", ResourceMemory: "512Mi"},
+			Limits:   ResourceList{ResourceCPU: "500m", ResourceMemory: "512Mi"},
+		}, pod.Spec.Containers[0].Resources)
+	})
+
+	t.Run("should leave pods that specify everything alone", func(t *testing.T) {
+		specified := &ResourceRequirements{
+			Requests: ResourceList{ResourceCPU: "1", ResourceMemory: "64Mi"},
+			Limits:   ResourceList{ResourceCPU: "2", ResourceMemory: "1Gi"},
+		}
+		pod := newLimitedPod(specified)
+		limitRange.ApplyDefaults(pod)
+		assert.Equal(t, &ResourceRequirements{
+			Requests: ResourceList{ResourceCPU: "1", ResourceMemory: "64Mi"},
+			Limits:   ResourceList{ResourceCPU: "2", ResourceMemory: "1Gi"},
+		}, pod.Spec.Containers[0].Resources)
+		assert.Empty(t, limitRange.Check(pod))
+	})
+}
+
+func TestLimitRangeCheck(t *testing.T) {
+	limitRange := newLimitRange()
+
+	pod := newLimitedPod(
+		&ResourceRequirements{Requests: ResourceList{ResourceCPU: "10m"}},
+		&ResourceRequirements{Requests: ResourceList{ResourceCPU: "1"}, Limits: ResourceList{ResourceCPU: "4"}},
+		&ResourceRequirements{Requests: ResourceList{ResourceMemory: "64Mi"}},
+	)
+	assert.Equal(t, FieldErrors{
+		{Field: "spec.containers[0].resources.requests.cpu", Message: "must be at least 50m under limit range limits, got 10m"},
+		{Field: "spec.containers[1].resources.limits.cpu", Message: "must be at most 2 under limit range limits, got 4"},
+		{Field: "spec.containers[2].resources.requests.cpu", Message: "is required by limit range limits, which sets a minimum of 50m"},
+	}, limitRange.Check(pod))
+}
+
+func TestLimitRangeValidate(t *testing.T) {
+	limitRange := newLimitRange()
+	limitRange.Spec.DefaultRequest[ResourceCPU] = "10m"
+	limitRange.Spec.Default[ResourceMemory] = "2Gi"
+	limitRange.Spec.Max["pods"] = "10"
+
+	err := limitRange.Validate()
+	assert.ErrorIs(t, err, ErrInvalidLimitRange)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, FieldErrors{{Field: "spec.max.pods", Message: "is not a resource: must be cpu or memory"}}, fieldErrs)
+
+	delete(limitRange.Spec.Max, "pods")
+	require.ErrorAs(t, limitRange.Validate(), &fieldErrs)
+	assert.Equal(t, FieldErrors{
+		{Field: "spec.default.memory", Message: "must be at most 1Gi, got 2Gi"},
+		{Field: "spec.defaultRequest.cpu", Message: "must be at least 50m, got 10m"},
+	}, fieldErrs)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+const (
+	KindNamespace = "Namespace"
+	// DefaultNamespace holds the objects created without a namespace. It always exists and cannot be deleted.
+	DefaultNamespace = "default"
+)
+
+// NamespacePhase is the lifecycle phase of a namespace
+type NamespacePhase string
+
+const (
+	// NamespaceActive accepts new pods and ReplicaSets
+	NamespaceActive NamespacePhase = "Active"
+	// NamespaceTerminating is deleted: its objects are being deleted and no new ones are accepted. The
+	// namespace is removed once it is empty.
+	NamespaceTerminating NamespacePhase = "Terminating"
+)
+
+// Namespace groups pods and ReplicaSets, which are deleted along with it
+type Namespace struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Status     NamespaceStatus `json:"status,omitempty"`
+}
+
+// NamespaceStatus is the state of a namespace, set by the API server
+type NamespaceStatus struct {
+	Phase NamespacePhase `json:"phase,omitempty"`
+}
+
+// IsTerminating checks if the namespace is deleted and waits for its objects to be deleted
+func (n *Namespace) IsTerminating() bool {
+	return n.Status.Phase == NamespaceTerminating
+}
+
+// Validate checks that the namespace is named by a lowercase RFC 1123 label, such as staging
+func (n *Namespace) Validate() error {
+	if err := ValidateStruct(n); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidNamespace, err)
+	}
+	if !isLabelName(n.Name) {
+		return fmt.Errorf("%w: %w", ErrInvalidNamespace, FieldErrors{{
+			Field:   "metadata.name",
+			Message: fmt.Sprintf("must be a lowercase RFC 1123 label, such as staging, got %q", n.Name),
+		}})
+	}
+	return nil
+}
+
+// isLabelName checks if name is a lowercase RFC 1123 label, i.e. a host name without dots
+func isLabelName(name string) bool {
+	return name == strings.ToLower(name) && !strings.Contains(name, ".") && validate.Var(name, "hostname_rfc1123") == nil
+}
+
+// NamespaceOf returns the namespace of an object, which is DefaultNamespace when it has none
+func NamespaceOf(meta *ObjectMeta) string {
+	if meta.Namespace == "" {
+		return DefaultNamespace
+	}
+	return meta.Namespace
+}
@@ -1,20 +1,58 @@
 package api
 
-import "github.com/go-playground/validator/v10"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Node is a simplified representation of a Kubernetes Node
 type Node struct {
 	ObjectMeta `json:"metadata,omitempty"`
 	Spec       NodeSpec   `json:"spec,omitempty"`
 	Status     NodeStatus `json:"status,omitempty"`
+	// Address is the IP address the kubelet reports for the node, where the host ports of its pods are reached
+	Address string `json:"address,omitempty"`
+	// LastHeartbeatTime is when the kubelet last reported the node status
+	LastHeartbeatTime time.Time    `json:"lastHeartbeatTime,omitempty"`
+	Capacity          NodeCapacity `json:"capacity,omitempty"`
+	// Conditions are the pressure conditions the kubelet reports, such as MemoryPressure
+	Conditions []NodeCondition `json:"conditions,omitempty"`
+}
+
+// GetCondition returns the condition of the given type, or nil if the node doesn't have it
+func (n *Node) GetCondition(conditionType NodeStatus) *NodeCondition {
+	for i := range n.Conditions {
+		if n.Conditions[i].Type == conditionType {
+			return &n.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// NodeCapacity describes the resources a node offers to pods
+type NodeCapacity struct {
+	// CPU is the number of cores
+	CPU int64 `json:"cpu,omitempty"`
+	// Memory is the total memory in bytes
+	Memory int64 `json:"memory,omitempty"`
+	// MaxPods is the number of pods the kubelet is willing to run
+	MaxPods int32 `json:"maxPods,omitempty"`
 }
 
 // Validate checks if the Node configuration is valid
 func (n *Node) Validate() error {
-	validate := validator.New()
-	if err := validate.Struct(n); err != nil {
-		return ErrInvalidNodeSpec
+	if err := ValidateStruct(n); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidNodeSpec, err)
 	}
 
 	return nil
 }
+
+// ValidateNodeName checks that name can name a node, i.e. it is a lowercase RFC 1123 host name
+func ValidateNodeName(name string) error {
+	if name != strings.ToLower(name) || validate.Var(name, "required,hostname_rfc1123") != nil {
+		return fmt.Errorf("%w: %q must be a lowercase RFC 1123 host name", ErrInvalidNodeName, name)
+	}
+	return nil
+}
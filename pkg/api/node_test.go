@@ -3,7 +3,6 @@ package api
 import (
 	"testing"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -72,16 +71,14 @@ func TestNodeValidation(t *testing.T) {
 		},
 	}
 
-	validate := validator.New()
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Test Validate method
 			err := tt.node.Validate()
-			assert.Equal(t, tt.wantErr, err)
+			assert.ErrorIs(t, err, tt.wantErr)
 
 			// Test struct validation
-			err = validate.Struct(tt.node)
+			err = ValidateStruct(tt.node)
 			if tt.wantErr != nil {
 				assert.Error(t, err)
 			} else {
@@ -90,3 +87,12 @@ func TestNodeValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateNodeName(t *testing.T) {
+	for _, name := range []string{"node-1", "worker.example.com", "10-0-0-1"} {
+		assert.NoError(t, ValidateNodeName(name), name)
+	}
+	for _, name := range []string{"", "Node-1", "node_1", "node 1", "-node", "node..example"} {
+		assert.ErrorIs(t, ValidateNodeName(name), ErrInvalidNodeName, name)
+	}
+}
@@ -3,41 +3,239 @@ package api
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
 	"strings"
-
-	"github.com/go-playground/validator/v10"
+	"time"
 )
 
 var (
 	ErrInvalidPodSpec = errors.New("invalid pod spec")
 )
 
+// MirrorPodLabel marks the read-only copy of a static pod that a kubelet publishes to the API server.
+// Its value is the name of the node running the pod.
+const MirrorPodLabel = "gokube.io/mirror-pod"
+
+// PodKubeletFinalizer is the finalizer a pod bound to a node gets when it is deleted. The kubelet of the node
+// removes it once it has stopped the containers of the pod, which lets the pod be removed.
+const PodKubeletFinalizer = "gokube.io/kubelet"
+
+// DefaultTerminationGracePeriodSeconds is how long the containers of a pod that sets no grace period get to
+// exit when the pod is stopped, before they are killed
+const DefaultTerminationGracePeriodSeconds int64 = 30
+
+// RestartPolicy describes how the kubelet handles containers of a pod that exit
+type RestartPolicy string
+
+const (
+	RestartPolicyAlways    RestartPolicy = "Always"
+	RestartPolicyOnFailure RestartPolicy = "OnFailure"
+	// RestartPolicyNever leaves exited containers alone. It is also what an empty policy means.
+	RestartPolicyNever RestartPolicy = "Never"
+)
+
 type PodSpec struct {
-	Containers []Container `json:"containers" validate:"required,dive,required"`
-	Replicas   int32       `json:"replicas" validate:"gte=0"`
+	Containers    []Container   `json:"containers" validate:"required,dive,required"`
+	Replicas      int32         `json:"replicas" validate:"gte=0"`
+	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty" validate:"omitempty,oneof=Always OnFailure Never"`
+	// TerminationGracePeriodSeconds is how long the containers get to exit after being asked to stop before
+	// they are killed; zero kills them straight away. It defaults to DefaultTerminationGracePeriodSeconds.
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty" validate:"omitempty,gte=0"`
+	// Priority orders pods for eviction: a kubelet under pressure evicts pods of lower priority first
+	Priority int32 `json:"priority,omitempty"`
 }
 
 type Pod struct {
-	ObjectMeta `json:"metadata,omitempty"`
-	Spec       PodSpec   `json:"spec" validate:"required"`
-	NodeName   string    `json:"nodeName,omitempty"`
-	Status     PodStatus `json:"status"`
+	ObjectMeta        `json:"metadata,omitempty"`
+	Spec              PodSpec           `json:"spec" validate:"required"`
+	NodeName          string            `json:"nodeName,omitempty"`
+	Status            PodStatus         `json:"status"`
+	ContainerStatuses []ContainerStatus `json:"containerStatuses,omitempty"`
 	// Add other fields as needed
 }
 
+// Eviction is the body of a request to the eviction subresource of a pod, which removes the pod from its
+// node, e.g. because the node is running out of resources. The ReplicaSet of the pod replaces it.
+type Eviction struct {
+	// Reason is a machine-readable cause of the eviction, such as MemoryPressure
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// PodStatusUpdate is the body of a request to the status subresource of a pod. It carries only what the
+// kubelet observes, so a status update can never overwrite the spec. Name, UID and NodeName are preconditions:
+// the update is rejected if the pod was recreated or bound to another node in the meantime. Namespace is that
+// of the pod, the default namespace if empty.
+type PodStatusUpdate struct {
+	Namespace         string            `json:"namespace,omitempty"`
+	Name              string            `json:"name"`
+	UID               string            `json:"uid,omitempty"`
+	NodeName          string            `json:"nodeName,omitempty"`
+	Status            PodStatus         `json:"status"`
+	ContainerStatuses []ContainerStatus `json:"containerStatuses,omitempty"`
+	// RemoveFinalizers are the finalizers of the pod the kubelet is done with, such as PodKubeletFinalizer once
+	// it stopped the containers of a deleted pod
+	RemoveFinalizers []string `json:"removeFinalizers,omitempty"`
+}
+
+// ShouldRestart checks if a container that exited with exitCode must be restarted under the pod's restart policy
+func (s *PodSpec) ShouldRestart(exitCode int) bool {
+	switch s.RestartPolicy {
+	case RestartPolicyAlways:
+		return true
+	case RestartPolicyOnFailure:
+		return exitCode != 0
+	default:
+		return false
+	}
+}
+
+// TerminationGracePeriod returns how long the containers of the pod get to exit when they are stopped
+func (s *PodSpec) TerminationGracePeriod() time.Duration {
+	if s.TerminationGracePeriodSeconds == nil {
+		return time.Duration(DefaultTerminationGracePeriodSeconds) * time.Second
+	}
+	return time.Duration(*s.TerminationGracePeriodSeconds) * time.Second
+}
+
+// GetContainer returns the named container, or nil if the pod has none of that name
+func (s *PodSpec) GetContainer(name string) *Container {
+	for i := range s.Containers {
+		if s.Containers[i].Name == name {
+			return &s.Containers[i]
+		}
+	}
+	return nil
+}
+
 // Validate validates the PodSpec of the Pod.
 func (p *Pod) Validate() error {
-	validate := validator.New()
-	if err := validate.Struct(p); err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidPodSpec, err)
+	var fieldErrs FieldErrors
+	if err := ValidateStruct(p); err != nil {
+		if !errors.As(err, &fieldErrs) {
+			return fmt.Errorf("%w: %w", ErrInvalidPodSpec, err)
+		}
+	}
+	// Report bad images along with the other violations, rather than one at a time
+	for i, container := range p.Spec.Containers {
+		if container.Image == "" {
+			continue
+		}
+		if err := ValidateImage(container.Image); err != nil {
+			fieldErrs = append(fieldErrs, FieldError{
+				Field:   fmt.Sprintf("spec.containers[%d].image", i),
+				Message: fmt.Sprintf("must be an image reference, such as nginx:1.27, got %q", container.Image),
+			})
+		}
+	}
+	fieldErrs = append(fieldErrs, p.validateResources()...)
+	fieldErrs = append(fieldErrs, p.validatePorts()...)
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidPodSpec, fieldErrs)
+	}
+	return nil
+}
+
+// validatePorts checks that the container ports are valid ports and that no two of them are published on the
+// same host port
+func (p *Pod) validatePorts() FieldErrors {
+	var fieldErrs FieldErrors
+	hostPorts := map[int32]bool{}
+	for i, container := range p.Spec.Containers {
+		for j, port := range container.Ports {
+			prefix := fmt.Sprintf("spec.containers[%d].ports[%d]", i, j)
+			if !isPort(port.ContainerPort) {
+				fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".containerPort", Message: fmt.Sprintf("must be between 1 and %d, got %d", MaxPort, port.ContainerPort)})
+			}
+			switch {
+			case port.HostPort == 0:
+			case !isPort(port.HostPort):
+				fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".hostPort", Message: fmt.Sprintf("must be between 1 and %d, got %d", MaxPort, port.HostPort)})
+			case hostPorts[port.HostPort]:
+				fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".hostPort", Message: fmt.Sprintf("must be unique, %d is taken", port.HostPort)})
+			default:
+				hostPorts[port.HostPort] = true
+			}
+		}
 	}
+	return fieldErrs
+}
 
+// ValidateUpdate checks that the pod only changes what may change once old was created, like in Kubernetes:
+// the images of the containers, the metadata, the status and the node, which may only be set once when the pod
+// is bound. Adding, removing or renaming containers and every other change to the spec is reported.
+func (p *Pod) ValidateUpdate(old *Pod) error {
+	var fieldErrs FieldErrors
+	immutable := func(field string) {
+		fieldErrs = append(fieldErrs, FieldError{Field: field, Message: "is immutable"})
+	}
+	if len(p.Spec.Containers) != len(old.Spec.Containers) {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   "spec.containers",
+			Message: fmt.Sprintf("may not be added or removed, the pod has %d", len(old.Spec.Containers)),
+		})
+	} else {
+		for i, container := range p.Spec.Containers {
+			oldContainer := old.Spec.Containers[i]
+			prefix := fmt.Sprintf("spec.containers[%d]", i)
+			if container.Name != oldContainer.Name {
+				fieldErrs = append(fieldErrs, FieldError{
+					Field:   prefix + ".name",
+					Message: fmt.Sprintf("is immutable, the container is named %q", oldContainer.Name),
+				})
+			}
+			if !reflect.DeepEqual(container.Resources, oldContainer.Resources) {
+				immutable(prefix + ".resources")
+			}
+			if !slices.Equal(container.Ports, oldContainer.Ports) {
+				immutable(prefix + ".ports")
+			}
+		}
+	}
+	if p.Spec.RestartPolicy != old.Spec.RestartPolicy {
+		immutable("spec.restartPolicy")
+	}
+	if p.Spec.TerminationGracePeriod() != old.Spec.TerminationGracePeriod() {
+		immutable("spec.terminationGracePeriodSeconds")
+	}
+	if p.Spec.Priority != old.Spec.Priority {
+		immutable("spec.priority")
+	}
+	if p.Spec.Replicas != old.Spec.Replicas {
+		immutable("spec.replicas")
+	}
+	if old.NodeName != "" && p.NodeName != old.NodeName {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   "nodeName",
+			Message: fmt.Sprintf("is immutable once set, the pod is bound to %q", old.NodeName),
+		})
+	}
+	if old.IsDeleting() {
+		for _, finalizer := range p.Finalizers {
+			if !slices.Contains(old.Finalizers, finalizer) {
+				fieldErrs = append(fieldErrs, FieldError{
+					Field:   "metadata.finalizers",
+					Message: fmt.Sprintf("may not gain %q, the pod is being deleted", finalizer),
+				})
+			}
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidPodSpec, fieldErrs)
+	}
 	return nil
 }
 
-// IsActive checks if the pod is active.
+// IsMirrorPod checks if the pod is the API server copy of a static pod run by a kubelet
+func (p *Pod) IsMirrorPod() bool {
+	_, ok := p.Labels[MirrorPodLabel]
+	return ok
+}
+
+// IsActive checks if the pod is active. A deleted pod is not, even while its containers are being stopped.
 func (p *Pod) IsActive() bool {
-	return p.Status != PodFailed //even succeeded pods should be considered active? or else controller keeps on creating pods
+	return p.Status != PodFailed && !p.IsDeleting() //even succeeded pods should be considered active? or else controller keeps on creating pods
 }
 
 func IsPodActiveAndOwnedBy(pod *Pod, meta *ObjectMeta) bool {
@@ -45,6 +243,11 @@ func IsPodActiveAndOwnedBy(pod *Pod, meta *ObjectMeta) bool {
 	return IsOwnedBy(pod, meta) && pod.IsActive()
 }
 
+// IsOwnedBy checks if the pod is controlled by the object described by meta.
+// Pods without a controller reference fall back to the legacy name prefix convention.
 func IsOwnedBy(pod *Pod, meta *ObjectMeta) bool {
+	if ref := pod.GetControllerOf(); ref != nil {
+		return ref.Name == meta.Name && (ref.UID == "" || meta.UID == "" || ref.UID == meta.UID)
+	}
 	return strings.HasPrefix(pod.Name, meta.Name)
 }
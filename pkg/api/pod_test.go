@@ -2,14 +2,13 @@ package api
 
 import (
 	"testing"
+	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPodSpecValidation(t *testing.T) {
-	validate := validator.New()
-
 	t.Run("should validate PodSpec with required fields", func(t *testing.T) {
 		podSpec := PodSpec{
 			Containers: []Container{
@@ -21,7 +20,7 @@ func TestPodSpecValidation(t *testing.T) {
 			Replicas: 3,
 		}
 
-		err := validate.Struct(podSpec)
+		err := ValidateStruct(podSpec)
 		assert.NoError(t, err)
 	})
 
@@ -30,9 +29,9 @@ func TestPodSpecValidation(t *testing.T) {
 			Replicas: 3,
 		}
 
-		err := validate.Struct(podSpec)
+		err := ValidateStruct(podSpec)
 		assert.Error(t, err)
-		assert.EqualError(t, err, "Key: 'PodSpec.Containers' Error:Field validation for 'Containers' failed on the 'required' tag")
+		assert.EqualError(t, err, "containers is required")
 	})
 
 	t.Run("should fail validation if container image is missing", func(t *testing.T) {
@@ -45,9 +44,9 @@ func TestPodSpecValidation(t *testing.T) {
 			Replicas: 3,
 		}
 
-		err := validate.Struct(podSpec)
+		err := ValidateStruct(podSpec)
 		assert.Error(t, err)
-		assert.EqualError(t, err, "Key: 'PodSpec.Containers[0].Image' Error:Field validation for 'Image' failed on the 'required' tag")
+		assert.EqualError(t, err, "containers[0].image is required")
 	})
 
 	t.Run("should fail validation if replicas is negative", func(t *testing.T) {
@@ -61,15 +60,13 @@ func TestPodSpecValidation(t *testing.T) {
 			Replicas: -1,
 		}
 
-		err := validate.Struct(podSpec)
+		err := ValidateStruct(podSpec)
 		assert.Error(t, err)
-		assert.EqualError(t, err, "Key: 'PodSpec.Replicas' Error:Field validation for 'Replicas' failed on the 'gte' tag")
+		assert.EqualError(t, err, "replicas must be at least 0, got -1")
 	})
 }
 
 func TestPodValidation(t *testing.T) {
-	validate := validator.New()
-
 	t.Run("should validate Pod with required fields", func(t *testing.T) {
 		pod := Pod{
 			ObjectMeta: ObjectMeta{
@@ -87,7 +84,7 @@ func TestPodValidation(t *testing.T) {
 			Status: PodPending,
 		}
 
-		err := validate.Struct(pod)
+		err := ValidateStruct(pod)
 		assert.NoError(t, err)
 	})
 
@@ -99,9 +96,9 @@ func TestPodValidation(t *testing.T) {
 			Status: PodPending,
 		}
 
-		err := validate.Struct(pod)
+		err := ValidateStruct(pod)
 		assert.Error(t, err)
-		assert.EqualError(t, err, "Key: 'Pod.Spec.Containers' Error:Field validation for 'Containers' failed on the 'required' tag")
+		assert.EqualError(t, err, "spec.containers is required")
 	})
 }
 
@@ -259,6 +256,32 @@ func TestIsOwnedBy(t *testing.T) {
 			},
 			expected: false,
 		},
+		{
+			name: "Controller reference takes precedence over name prefix",
+			pod: Pod{
+				ObjectMeta: ObjectMeta{
+					Name:            "replicaset-12345-pod",
+					OwnerReferences: []OwnerReference{{Kind: KindReplicaSet, Name: "other", Controller: true}},
+				},
+			},
+			meta: ObjectMeta{
+				Name: "replicaset-12345",
+			},
+			expected: false,
+		},
+		{
+			name: "Pod is owned through controller reference",
+			pod: Pod{
+				ObjectMeta: ObjectMeta{
+					Name:            "adopted-pod",
+					OwnerReferences: []OwnerReference{{Kind: KindReplicaSet, Name: "replicaset-12345", Controller: true}},
+				},
+			},
+			meta: ObjectMeta{
+				Name: "replicaset-12345",
+			},
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -267,3 +290,166 @@ func TestIsOwnedBy(t *testing.T) {
 		})
 	}
 }
+
+func TestPodSpecShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy   RestartPolicy
+		exitCode int
+		expected bool
+	}{
+		{RestartPolicyAlways, 0, true},
+		{RestartPolicyAlways, 1, true},
+		{RestartPolicyOnFailure, 0, false},
+		{RestartPolicyOnFailure, 1, true},
+		{RestartPolicyNever, 1, false},
+		{"", 1, false},
+	}
+
+	for _, tt := range tests {
+		spec := PodSpec{RestartPolicy: tt.policy}
+		assert.Equal(t, tt.expected, spec.ShouldRestart(tt.exitCode), "policy %q, exit code %d", tt.policy, tt.exitCode)
+	}
+}
+
+func TestPodSpecTerminationGracePeriod(t *testing.T) {
+	spec := PodSpec{}
+	assert.Equal(t, 30*time.Second, spec.TerminationGracePeriod())
+
+	zero := int64(0)
+	spec.TerminationGracePeriodSeconds = &zero
+	assert.Equal(t, time.Duration(0), spec.TerminationGracePeriod())
+
+	negative := int64(-1)
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: "pod"},
+		Spec:       PodSpec{Containers: []Container{{Name: "c1", Image: "nginx"}}, TerminationGracePeriodSeconds: &negative},
+	}
+	assert.ErrorIs(t, pod.Validate(), ErrInvalidPodSpec)
+}
+
+func TestPodSpecRestartPolicyValidation(t *testing.T) {
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: "pod"},
+		Spec: PodSpec{
+			Containers:    []Container{{Name: "c1", Image: "nginx"}},
+			RestartPolicy: "Sometimes",
+		},
+	}
+	assert.ErrorIs(t, pod.Validate(), ErrInvalidPodSpec)
+
+	pod.Spec.RestartPolicy = RestartPolicyOnFailure
+	assert.NoError(t, pod.Validate())
+}
+
+func TestPodValidateReportsAllFieldErrors(t *testing.T) {
+	pod := &Pod{
+		Spec: PodSpec{
+			Containers:    []Container{{Name: "web", Image: "nginx:latest"}, {Name: "sidecar", Image: "Not An Image"}},
+			RestartPolicy: "Sometimes",
+		},
+	}
+
+	err := pod.Validate()
+	assert.ErrorIs(t, err, ErrInvalidPodSpec)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, FieldErrors{
+		{Field: "metadata.name", Message: "is required"},
+		{Field: "spec.restartPolicy", Message: `must be one of Always, OnFailure, Never, got "Sometimes"`},
+		{Field: "spec.containers[1].image", Message: `must be an image reference, such as nginx:1.27, got "Not An Image"`},
+	}, fieldErrs)
+	assert.EqualError(t, err, `invalid pod spec: metadata.name is required; spec.restartPolicy must be one of Always, `+
+		`OnFailure, Never, got "Sometimes"; spec.containers[1].image must be an image reference, such as nginx:1.27, got "Not An Image"`)
+}
+
+func TestPodValidateUpdate(t *testing.T) {
+	gracePeriod := int64(10)
+	newPod := func() *Pod {
+		return &Pod{
+			ObjectMeta: ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}},
+			Spec: PodSpec{
+				Containers: []Container{
+					{Name: "web", Image: "nginx:1.27", Ports: []ContainerPort{{ContainerPort: 80}}},
+					{Name: "sidecar", Image: "envoy:1.30"},
+				},
+				RestartPolicy:                 RestartPolicyAlways,
+				TerminationGracePeriodSeconds: &gracePeriod,
+			},
+			Status: PodPending,
+		}
+	}
+	testCases := []struct {
+		name      string
+		old       func(pod *Pod)
+		update    func(pod *Pod)
+		fieldErrs FieldErrors
+	}{
+		{name: "no change", update: func(pod *Pod) {}},
+		{name: "image", update: func(pod *Pod) { pod.Spec.Containers[0].Image = "nginx:1.28" }},
+		{name: "labels and annotations", update: func(pod *Pod) {
+			pod.Labels = map[string]string{"app": "web", "tier": "frontend"}
+			pod.Annotations = map[string]string{"note": "canary"}
+		}},
+		{name: "status", update: func(pod *Pod) {
+			pod.Status = PodRunning
+			pod.ContainerStatuses = []ContainerStatus{{Name: "web", State: ContainerRunning}}
+		}},
+		{name: "binding", update: func(pod *Pod) { pod.NodeName = "node-1" }},
+		{name: "grace period left out when it is the default", old: func(pod *Pod) { pod.Spec.TerminationGracePeriodSeconds = nil },
+			update: func(pod *Pod) {
+				defaultGracePeriod := DefaultTerminationGracePeriodSeconds
+				pod.Spec.TerminationGracePeriodSeconds = &defaultGracePeriod
+			}},
+		{name: "added container", update: func(pod *Pod) {
+			pod.Spec.Containers = append(pod.Spec.Containers, Container{Name: "metrics", Image: "exporter:1.0"})
+		}, fieldErrs: FieldErrors{{Field: "spec.containers", Message: "may not be added or removed, the pod has 2"}}},
+		{name: "removed container", update: func(pod *Pod) { pod.Spec.Containers = pod.Spec.Containers[:1] },
+			fieldErrs: FieldErrors{{Field: "spec.containers", Message: "may not be added or removed, the pod has 2"}}},
+		{name: "renamed container", update: func(pod *Pod) { pod.Spec.Containers[1].Name = "proxy" },
+			fieldErrs: FieldErrors{{Field: "spec.containers[1].name", Message: `is immutable, the container is named "sidecar"`}}},
+		{name: "resources and ports", update: func(pod *Pod) {
+			pod.Spec.Containers[0].Ports[0].HostPort = 8080
+			pod.Spec.Containers[1].Resources = &ResourceRequirements{Requests: ResourceList{ResourceCPU: "100m"}}
+		}, fieldErrs: FieldErrors{
+			{Field: "spec.containers[0].ports", Message: "is immutable"},
+			{Field: "spec.containers[1].resources", Message: "is immutable"},
+		}},
+		{name: "pod settings", update: func(pod *Pod) {
+			pod.Spec.RestartPolicy = RestartPolicyNever
+			pod.Spec.TerminationGracePeriodSeconds = nil
+			pod.Spec.Priority = 100
+			pod.Spec.Replicas = 2
+		}, fieldErrs: FieldErrors{
+			{Field: "spec.restartPolicy", Message: "is immutable"},
+			{Field: "spec.terminationGracePeriodSeconds", Message: "is immutable"},
+			{Field: "spec.priority", Message: "is immutable"},
+			{Field: "spec.replicas", Message: "is immutable"},
+		}},
+		{name: "rebinding", old: func(pod *Pod) { pod.NodeName = "node-1" }, update: func(pod *Pod) { pod.NodeName = "node-2" },
+			fieldErrs: FieldErrors{{Field: "nodeName", Message: `is immutable once set, the pod is bound to "node-1"`}}},
+		{name: "unbinding", old: func(pod *Pod) { pod.NodeName = "node-1" }, update: func(pod *Pod) { pod.NodeName = "" },
+			fieldErrs: FieldErrors{{Field: "nodeName", Message: `is immutable once set, the pod is bound to "node-1"`}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			old := newPod()
+			if tc.old != nil {
+				tc.old(old)
+			}
+			pod := newPod()
+			pod.NodeName = old.NodeName
+			pod.Spec.TerminationGracePeriodSeconds = old.Spec.TerminationGracePeriodSeconds
+			tc.update(pod)
+
+			err := pod.ValidateUpdate(old)
+			if tc.fieldErrs == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidPodSpec)
+			var fieldErrs FieldErrors
+			require.ErrorAs(t, err, &fieldErrs)
+			assert.Equal(t, tc.fieldErrs, fieldErrs)
+		})
+	}
+}
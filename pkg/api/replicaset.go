@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultMaxReplicasPerReplicaSet is the largest replica count accepted for a ReplicaSet unless configured otherwise
+const DefaultMaxReplicasPerReplicaSet int32 = 1000
+
+// ValidateUpdate checks that the ReplicaSet keeps the selector of old. Changing it would orphan the pods the
+// ReplicaSet owns and could adopt the pods of another one, so only the replicas and the template may change.
+func (rs *ReplicaSet) ValidateUpdate(old *ReplicaSet) error {
+	if maps.Equal(rs.Spec.Selector, old.Spec.Selector) {
+		return nil
+	}
+	selector := make([]string, 0, len(old.Spec.Selector))
+	for _, key := range slices.Sorted(maps.Keys(old.Spec.Selector)) {
+		selector = append(selector, key+"="+old.Spec.Selector[key])
+	}
+	return FieldErrors{{
+		Field:   "spec.selector",
+		Message: fmt.Sprintf("is immutable, the ReplicaSet selects %q", strings.Join(selector, ",")),
+	}}
+}
+
+// GetCondition returns the condition of the given type, or nil if the ReplicaSet doesn't have it
+func (s *ReplicaSetStatus) GetCondition(conditionType ReplicaSetConditionType) *ReplicaSetCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or replaces the condition of the same type.
+// The transition time is only updated when the condition status changes.
+func (s *ReplicaSetStatus) SetCondition(condition ReplicaSetCondition) {
+	existing := s.GetCondition(condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = time.Now().UTC()
+		}
+		s.Conditions = append(s.Conditions, condition)
+		return
+	}
+
+	if existing.Status != condition.Status {
+		existing.LastTransitionTime = time.Now().UTC()
+	}
+	existing.Status = condition.Status
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+}
+
+// RemoveCondition removes the condition of the given type
+func (s *ReplicaSetStatus) RemoveCondition(conditionType ReplicaSetConditionType) {
+	conditions := s.Conditions[:0]
+	for _, condition := range s.Conditions {
+		if condition.Type != conditionType {
+			conditions = append(conditions, condition)
+		}
+	}
+	s.Conditions = conditions
+}
+
+// Scale is the body of the scale subresource of a ReplicaSet, which reads and changes only the replica count
+type Scale struct {
+	// ObjectMeta names the ReplicaSet. A UID is a precondition: the update is rejected if the ReplicaSet was
+	// recreated in the meantime.
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       ScaleSpec   `json:"spec"`
+	Status     ScaleStatus `json:"status,omitempty"`
+}
+
+// ScaleSpec is the desired replica count
+type ScaleSpec struct {
+	Replicas int32 `json:"replicas"`
+}
+
+// ScaleStatus is the replica count the controller last observed
+type ScaleStatus struct {
+	Replicas      int32 `json:"replicas"`
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+}
+
+// NewScale returns the scale subresource of rs
+func NewScale(rs *ReplicaSet) *Scale {
+	return &Scale{
+		ObjectMeta: ObjectMeta{Name: rs.Name, Namespace: rs.Namespace, UID: rs.UID, ResourceVersion: rs.ResourceVersion},
+		Spec:       ScaleSpec{Replicas: rs.Spec.Replicas},
+		Status:     ScaleStatus{Replicas: rs.Status.Replicas, ReadyReplicas: rs.Status.ReadyReplicas},
+	}
+}
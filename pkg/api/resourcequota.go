@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+var ErrInvalidResourceQuota = errors.New("invalid resource quota")
+
+const KindResourceQuota = "ResourceQuota"
+
+// ResourceQuota limits the pods of its namespace: their number and the cpu and memory they request in total
+type ResourceQuota struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       ResourceQuotaSpec   `json:"spec"`
+	Status     ResourceQuotaStatus `json:"status,omitempty"`
+}
+
+// ResourceQuotaSpec is the limits of a ResourceQuota
+type ResourceQuotaSpec struct {
+	// Hard limits pods, cpu and memory, e.g. pods: 10, cpu: 4
+	Hard ResourceList `json:"hard"`
+}
+
+// ResourceQuotaStatus compares the limits of a ResourceQuota to what the pods of its namespace use. The API
+// server works it out from the pods whenever the quota is read.
+type ResourceQuotaStatus struct {
+	Hard ResourceList `json:"hard,omitempty"`
+	Used ResourceList `json:"used,omitempty"`
+}
+
+// Validate checks that the quota is named by a lowercase RFC 1123 label and limits only pods, cpu and memory
+func (q *ResourceQuota) Validate() error {
+	var fieldErrs FieldErrors
+	if !isLabelName(q.Name) {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   "metadata.name",
+			Message: fmt.Sprintf("must be a lowercase RFC 1123 label, such as compute, got %q", q.Name),
+		})
+	}
+	if len(q.Spec.Hard) == 0 {
+		fieldErrs = append(fieldErrs, FieldError{Field: "spec.hard", Message: "is required"})
+	}
+	for _, resource := range slices.Sorted(maps.Keys(q.Spec.Hard)) {
+		field := fmt.Sprintf("spec.hard.%s", resource)
+		switch resource {
+		case ResourcePods, ResourceCPU, ResourceMemory:
+			if _, err := ParseQuantity(resource, q.Spec.Hard[resource]); err != nil {
+				fieldErrs = append(fieldErrs, FieldError{Field: field, Message: fmt.Sprintf("must be a quantity, such as %s, got %q", quantityExample(resource), q.Spec.Hard[resource])})
+			}
+		default:
+			fieldErrs = append(fieldErrs, FieldError{Field: field, Message: "is not a resource: must be pods, cpu or memory"})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidResourceQuota, fieldErrs)
+	}
+	return nil
+}
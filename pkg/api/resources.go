@@ -0,0 +1,190 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// ResourceName names a resource that pods request and quotas limit
+type ResourceName string
+
+const (
+	// ResourceCPU is measured in cores, written as 2, 0.5 or 500m for half a core
+	ResourceCPU ResourceName = "cpu"
+	// ResourceMemory is measured in bytes, written as 134217728, 128Mi or 1G
+	ResourceMemory ResourceName = "memory"
+	// ResourcePods is the number of pods, which only quotas limit
+	ResourcePods ResourceName = "pods"
+)
+
+// ResourceList maps resources to quantities, such as cpu: 500m
+type ResourceList map[ResourceName]string
+
+// ResourceRequirements are the resources a container needs
+type ResourceRequirements struct {
+	// Requests are the resources reserved for the container, which count towards the quotas of its namespace
+	Requests ResourceList `json:"requests,omitempty"`
+	// Limits are the most the container may use; a request must not exceed its limit
+	Limits ResourceList `json:"limits,omitempty"`
+}
+
+// memorySuffixes are the multipliers of the memory units, binary ones first so Mi is not read as M
+var memorySuffixes = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+// ParseQuantity reads a quantity of a resource in its base unit: millicores of cpu, bytes of memory and a
+// number of pods
+func ParseQuantity(resource ResourceName, quantity string) (int64, error) {
+	value, err := parseQuantity(resource, quantity)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q of %s: %w", ErrInvalidQuantity, quantity, resource, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("%w %q of %s: must not be negative", ErrInvalidQuantity, quantity, resource)
+	}
+	return value, nil
+}
+
+func parseQuantity(resource ResourceName, quantity string) (int64, error) {
+	switch resource {
+	case ResourceCPU:
+		if millis, ok := strings.CutSuffix(quantity, "m"); ok {
+			return strconv.ParseInt(millis, 10, 64)
+		}
+		cores, err := strconv.ParseFloat(quantity, 64)
+		if err != nil || math.IsInf(cores, 0) || math.IsNaN(cores) {
+			return 0, errors.New("expected cores, such as 0.5, or millicores, such as 500m")
+		}
+		return int64(math.Round(cores * 1000)), nil
+	case ResourceMemory:
+		for _, unit := range memorySuffixes {
+			if number, ok := strings.CutSuffix(quantity, unit.suffix); ok {
+				value, err := strconv.ParseInt(number, 10, 64)
+				if err != nil {
+					return 0, err
+				}
+				if value > math.MaxInt64/unit.multiplier {
+					return 0, errors.New("too large")
+				}
+				return value * unit.multiplier, nil
+			}
+		}
+		return strconv.ParseInt(quantity, 10, 64)
+	case ResourcePods:
+		return strconv.ParseInt(quantity, 10, 64)
+	default:
+		return 0, errors.New("unknown resource")
+	}
+}
+
+// FormatQuantity writes a quantity of a resource given in its base unit the way people write it: cpu in cores
+// or millicores and memory in the largest binary unit that divides it
+func FormatQuantity(resource ResourceName, value int64) string {
+	switch resource {
+	case ResourceCPU:
+		if value%1000 == 0 {
+			return strconv.FormatInt(value/1000, 10)
+		}
+		return strconv.FormatInt(value, 10) + "m"
+	case ResourceMemory:
+		for _, unit := range []string{"Ti", "Gi", "Mi", "Ki"} {
+			multiplier := memoryMultiplier(unit)
+			if value != 0 && value%multiplier == 0 {
+				return strconv.FormatInt(value/multiplier, 10) + unit
+			}
+		}
+		return strconv.FormatInt(value, 10)
+	default:
+		return strconv.FormatInt(value, 10)
+	}
+}
+
+func memoryMultiplier(suffix string) int64 {
+	for _, unit := range memorySuffixes {
+		if unit.suffix == suffix {
+			return unit.multiplier
+		}
+	}
+	return 1
+}
+
+// ResourceRequests sums the requests of the containers of the pod in the base unit of each resource, and
+// counts the pod itself as one pod. Requests that don't parse are left out; CreatePod rejects them.
+func (p *Pod) ResourceRequests() map[ResourceName]int64 {
+	requests := map[ResourceName]int64{ResourcePods: 1}
+	for _, container := range p.Spec.Containers {
+		if container.Resources == nil {
+			continue
+		}
+		for resource, quantity := range container.Resources.Requests {
+			if value, err := ParseQuantity(resource, quantity); err == nil {
+				requests[resource] += value
+			}
+		}
+	}
+	return requests
+}
+
+// validateResources checks that the containers request and limit only cpu and memory, in valid quantities,
+// and request no more than their limits
+func (p *Pod) validateResources() FieldErrors {
+	var fieldErrs FieldErrors
+	for i, container := range p.Spec.Containers {
+		if container.Resources == nil {
+			continue
+		}
+		prefix := fmt.Sprintf("spec.containers[%d].resources", i)
+		fieldErrs = append(fieldErrs, validateContainerResources(prefix+".requests", container.Resources.Requests)...)
+		fieldErrs = append(fieldErrs, validateContainerResources(prefix+".limits", container.Resources.Limits)...)
+		for _, resource := range slices.Sorted(maps.Keys(container.Resources.Requests)) {
+			request, requestErr := ParseQuantity(resource, container.Resources.Requests[resource])
+			limit, limitErr := ParseQuantity(resource, container.Resources.Limits[resource])
+			if requestErr == nil && limitErr == nil && request > limit {
+				fieldErrs = append(fieldErrs, FieldError{
+					Field:   fmt.Sprintf("%s.requests.%s", prefix, resource),
+					Message: fmt.Sprintf("must be at most the limit %s, got %s", container.Resources.Limits[resource], container.Resources.Requests[resource]),
+				})
+			}
+		}
+	}
+	return fieldErrs
+}
+
+func validateContainerResources(field string, resources ResourceList) FieldErrors {
+	var fieldErrs FieldErrors
+	for _, resource := range slices.Sorted(maps.Keys(resources)) {
+		quantity := resources[resource]
+		switch resource {
+		case ResourceCPU, ResourceMemory:
+			if _, err := ParseQuantity(resource, quantity); err != nil {
+				fieldErrs = append(fieldErrs, FieldError{Field: field + "." + string(resource), Message: fmt.Sprintf("must be a quantity, such as %s, got %q", quantityExample(resource), quantity)})
+			}
+		default:
+			fieldErrs = append(fieldErrs, FieldError{Field: field + "." + string(resource), Message: "is not a resource: must be cpu or memory"})
+		}
+	}
+	return fieldErrs
+}
+
+func quantityExample(resource ResourceName) string {
+	switch resource {
+	case ResourceCPU:
+		return "500m"
+	case ResourceMemory:
+		return "128Mi"
+	default:
+		return "10"
+	}
+}
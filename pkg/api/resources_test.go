@@ -0,0 +1,72 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		resource ResourceName
+		quantity string
+		expected int64
+	}{
+		{ResourceCPU, "500m", 500},
+		{ResourceCPU, "2", 2000},
+		{ResourceCPU, "0.25", 250},
+		{ResourceMemory, "1024", 1024},
+		{ResourceMemory, "128Mi", 128 << 20},
+		{ResourceMemory, "1Gi", 1 << 30},
+		{ResourceMemory, "1M", 1000000},
+		{ResourcePods, "10", 10},
+	}
+	for _, tt := range tests {
+		value, err := ParseQuantity(tt.resource, tt.quantity)
+		require.NoError(t, err, tt.quantity)
+		assert.Equal(t, tt.expected, value, tt.quantity)
+	}
+
+	for _, quantity := range []string{"", "lots", "-1", "1.5m", "1Zi"} {
+		_, err := ParseQuantity(ResourceMemory, quantity)
+		assert.ErrorIs(t, err, ErrInvalidQuantity, quantity)
+	}
+	_, err := ParseQuantity(ResourceCPU, "-500m")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+	_, err = ParseQuantity("gpu", "1")
+	assert.ErrorIs(t, err, ErrInvalidQuantity)
+}
+
+func TestFormatQuantity(t *testing.T) {
+	assert.Equal(t, "1500m", FormatQuantity(ResourceCPU, 1500))
+	assert.Equal(t, "2", FormatQuantity(ResourceCPU, 2000))
+	assert.Equal(t, "0", FormatQuantity(ResourceCPU, 0))
+	assert.Equal(t, "384Mi", FormatQuantity(ResourceMemory, 384<<20))
+	assert.Equal(t, "2Gi", FormatQuantity(ResourceMemory, 2<<30))
+	assert.Equal(t, "1000", FormatQuantity(ResourceMemory, 1000))
+	assert.Equal(t, "0", FormatQuantity(ResourceMemory, 0))
+	assert.Equal(t, "3", FormatQuantity(ResourcePods, 3))
+}
+
+func TestPodResourceRequests(t *testing.T) {
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: "web"},
+		Spec: PodSpec{Containers: []Container{
+			{Name: "app", Image: "nginx", Resources: &ResourceRequirements{Requests: ResourceList{ResourceCPU: "250m", ResourceMemory: "64Mi"}}},
+			{Name: "sidecar", Image: "busybox", Resources: &ResourceRequirements{Requests: ResourceList{ResourceCPU: "0.5"}}},
+			{Name: "init", Image: "busybox"},
+		}},
+	}
+	require.NoError(t, pod.Validate())
+	assert.Equal(t, map[ResourceName]int64{ResourcePods: 1, ResourceCPU: 750, ResourceMemory: 64 << 20}, pod.ResourceRequests())
+
+	pod.Spec.Containers[1].Resources.Requests = ResourceList{ResourceCPU: "half", ResourcePods: "1"}
+	err := pod.Validate()
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, FieldErrors{
+		{Field: "spec.containers[1].resources.requests.cpu", Message: `must be a quantity, such as 500m, got "half"`},
+		{Field: "spec.containers[1].resources.requests.pods", Message: "is not a resource: must be cpu or memory"},
+	}, fieldErrs)
+}
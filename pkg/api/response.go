@@ -1,16 +1,32 @@
 package api
 
 import (
-	"log"
+	"encoding/json"
+	"errors"
+	"log/slog"
 
 	"github.com/emicklei/go-restful/v3"
+
+	"gokube/pkg/logging"
 )
 
+// MissingNamesHeader lists the names asked for with ?names= that have no object, separated by commas
+const MissingNamesHeader = "X-Missing-Names"
+
+// ContinueHeader holds the token to send as ?continue= for the next page of a list asked for with ?limit=. It
+// is left out after the last page.
+const ContinueHeader = "X-Continue"
+
+// ResourceVersionHeader holds the resource version a list was read at, to send as ?resourceVersion= with
+// ?watch=true to follow the changes made after the list. Only the unfiltered lists of the resources that can be
+// watched have it.
+const ResourceVersionHeader = "X-Resource-Version"
+
 // WriteResponse is a helper function to write the response and log any errors
 func WriteResponse(response *restful.Response, status int, entity interface{}) {
 	if entity != nil {
 		if err := response.WriteHeaderAndEntity(status, entity); err != nil {
-			log.Printf("Error writing response: %v", err)
+			slog.Error("Failed to write response", logging.Err(err))
 		}
 		return
 	}
@@ -18,9 +34,27 @@ func WriteResponse(response *restful.Response, status int, entity interface{}) {
 	response.WriteHeader(status)
 }
 
-// WriteError is a helper function to write an error response
+// Status is the body of an error response
+type Status struct {
+	// Code repeats the HTTP status code of the response
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Errors lists each violation of an object that failed validation, by the path of the field
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// WriteError is a helper function to write an error response with a Status body. The body is always JSON,
+// also for routes producing other content, such as container logs. FieldErrors in the chain of err are
+// listed in the Errors of the Status.
 func WriteError(response *restful.Response, status int, err error) {
-	if writeErr := response.WriteError(status, err); writeErr != nil {
-		log.Printf("Error writing error response: %v", writeErr)
+	body := &Status{Code: status, Message: err.Error()}
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		body.Errors = fieldErrs
+	}
+	response.Header().Set("Content-Type", restful.MIME_JSON)
+	response.WriteHeader(status)
+	if writeErr := json.NewEncoder(response).Encode(body); writeErr != nil {
+		slog.Error("Failed to write error response", logging.Err(writeErr))
 	}
 }
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+	"gokube/pkg/manifest"
+	"gokube/pkg/registry"
+)
+
+// BootstrapSummary counts what became of the objects of the bootstrap manifests
+type BootstrapSummary struct {
+	// Created is the number of objects created
+	Created int
+	// Existing is the number of objects left alone because they exist already, e.g. created on an earlier start
+	Existing int
+	// Failed is the number of objects, or of files that could not be read, that were not created
+	Failed int
+}
+
+// Bootstrap creates the objects of the JSON and YAML manifests in dir through the registries, so the API
+// server starts with a known set of nodes, pods and ReplicaSets. Objects that exist already are skipped, so
+// bootstrapping again on a restart changes nothing. A file or object that fails is logged and counted, and
+// the others are still created. Only a directory that cannot be read fails the bootstrap.
+func (s *APIServer) Bootstrap(ctx context.Context, dir string) (BootstrapSummary, error) {
+	var summary BootstrapSummary
+	paths, err := manifest.Files(dir)
+	if err != nil {
+		return summary, fmt.Errorf("failed to read bootstrap manifests: %w", err)
+	}
+
+	logger := s.logger.With("dir", dir)
+	for _, path := range paths {
+		manifests, err := manifest.ReadFile(path)
+		if err != nil {
+			logger.Warn("Skipping bootstrap manifest", "path", path, logging.Err(err))
+			summary.Failed++
+			continue
+		}
+		for _, m := range manifests {
+			ref := strings.ToLower(m.Kind) + "/" + m.ObjectMeta().Name
+			err := s.createBootstrapObject(ctx, m.Object)
+			switch {
+			case err == nil:
+				logger.Debug("Created bootstrap object", "object", ref, "source", m.Source)
+				summary.Created++
+			case errors.Is(err, registry.ErrNodeAlreadyExists), errors.Is(err, registry.ErrPodAlreadyExists),
+				errors.Is(err, registry.ErrReplicaSetExists):
+				logger.Debug("Bootstrap object exists already", "object", ref, "source", m.Source)
+				summary.Existing++
+			default:
+				logger.Warn("Failed to create bootstrap object", "object", ref, "source", m.Source, logging.Err(err))
+				summary.Failed++
+			}
+		}
+	}
+
+	logger.Info("Bootstrapped objects", "created", summary.Created, "existing", summary.Existing, "failed", summary.Failed)
+	return summary, nil
+}
+
+func (s *APIServer) createBootstrapObject(ctx context.Context, object any) error {
+	switch object := object.(type) {
+	case *api.Node:
+		return s.nodeRegistry.CreateNode(ctx, object)
+	case *api.Pod:
+		return s.podRegistry.CreatePod(ctx, object)
+	case *api.ReplicaSet:
+		return s.replicasetRegistry.Create(ctx, object)
+	default:
+		return fmt.Errorf("%w %T", manifest.ErrUnknownKind, object)
+	}
+}
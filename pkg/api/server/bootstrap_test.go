@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestAPIServer_Bootstrap(t *testing.T) {
+	writeFile := func(t *testing.T, dir, name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	t.Run("should create the objects once across restarts", func(t *testing.T) {
+		withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			writeFile(t, dir, "nodes.yaml", "kind: Node\nmetadata:\n  name: node-1\n---\nkind: Node\nmetadata:\n  name: node-2\n")
+			writeFile(t, dir, "web.json", `{"kind": "ReplicaSet", "metadata": {"name": "web"}, "spec": {"replicas": 2,
+				"selector": {"app": "web"}, "template": {"metadata": {"labels": {"app": "web"}},
+				"spec": {"containers": [{"name": "nginx", "image": "nginx:1.27"}]}}}}`)
+			writeFile(t, dir, "broken.yaml", "kind: Pod\n  metadata: [\n")
+			writeFile(t, dir, "invalid.yaml", "kind: Pod\nmetadata:\n  name: db\nspec:\n  containers:\n  - name: postgres\n")
+			writeFile(t, dir, "README.md", "not a manifest")
+
+			store := storage.NewEtcdStorage(etcdServer)
+			server := NewAPIServer(store)
+			summary, err := server.Bootstrap(ctx, dir)
+			require.NoError(t, err)
+			assert.Equal(t, BootstrapSummary{Created: 3, Failed: 2}, summary, "a broken file doesn't stop the others")
+
+			node, err := server.nodeRegistry.GetNode(ctx, "node-1")
+			require.NoError(t, err)
+			rs, err := server.replicasetRegistry.Get(ctx, api.DefaultNamespace, "web")
+			require.NoError(t, err)
+			assert.Equal(t, int32(2), rs.Spec.Replicas)
+
+			// A restarted API server finds the objects of its first start
+			restarted := NewAPIServer(store)
+			summary, err = restarted.Bootstrap(ctx, dir)
+			require.NoError(t, err)
+			assert.Equal(t, BootstrapSummary{Existing: 3, Failed: 2}, summary)
+
+			unchangedNode, err := restarted.nodeRegistry.GetNode(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Equal(t, node.ResourceVersion, unchangedNode.ResourceVersion)
+			unchangedRS, err := restarted.replicasetRegistry.Get(ctx, api.DefaultNamespace, "web")
+			require.NoError(t, err)
+			assert.Equal(t, rs.ResourceVersion, unchangedRS.ResourceVersion)
+		})
+	})
+
+	t.Run("should create nothing from an empty directory", func(t *testing.T) {
+		withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			summary, err := NewAPIServer(storage.NewEtcdStorage(etcdServer)).Bootstrap(context.Background(), t.TempDir())
+			require.NoError(t, err)
+			assert.Equal(t, BootstrapSummary{}, summary)
+		})
+	})
+
+	t.Run("should fail if the directory does not exist", func(t *testing.T) {
+		withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			_, err := NewAPIServer(storage.NewEtcdStorage(etcdServer)).Bootstrap(context.Background(), filepath.Join(t.TempDir(), "missing"))
+			assert.ErrorIs(t, err, os.ErrNotExist)
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+)
+
+// metricsTimeout bounds the storage reads of a scrape
+const metricsTimeout = 5 * time.Second
+
+// podStatusCollector reports the number of pods of each status, counted afresh on every scrape
+type podStatusCollector struct {
+	podRegistry *registry.PodRegistry
+	pods        *prometheus.Desc
+}
+
+func newPodStatusCollector(podRegistry *registry.PodRegistry) *podStatusCollector {
+	return &podStatusCollector{
+		podRegistry: podRegistry,
+		pods:        prometheus.NewDesc("gokube_pods", "Number of pods by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *podStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pods
+}
+
+func (c *podStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+	defer cancel()
+	counts, err := c.podRegistry.CountPodsByStatus(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.pods, err)
+		return
+	}
+	// Every status is reported even while no pod has it, so the series don't come and go
+	for _, status := range api.PodStatuses {
+		ch <- prometheus.MustNewConstMetric(c.pods, prometheus.GaugeValue, float64(counts[status]), string(status))
+		delete(counts, status)
+	}
+	// Statuses of pods stored by older versions
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.pods, prometheus.GaugeValue, float64(count), string(status))
+	}
+}
@@ -1,13 +1,21 @@
 package server
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
 	"gokube/pkg/api"
 	"gokube/pkg/api/handlers"
+	"gokube/pkg/logging"
 	"gokube/pkg/registry"
 
 	"github.com/emicklei/go-restful/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"gokube/pkg/storage"
 )
@@ -17,23 +25,131 @@ type APIServer struct {
 	nodeRegistry       *registry.NodeRegistry
 	podRegistry        *registry.PodRegistry
 	replicasetRegistry *registry.ReplicaSetRegistry
+	eventRegistry      *registry.EventRegistry
+	namespaceRegistry  *registry.NamespaceRegistry
+	quotaRegistry      *registry.ResourceQuotaRegistry
+	limitRangeRegistry *registry.LimitRangeRegistry
+	serviceRegistry    *registry.ServiceRegistry
+	endpointsRegistry  *registry.EndpointsRegistry
+	nodeLeaseRegistry  *registry.NodeLeaseRegistry
+	logger             *slog.Logger
+	// metrics gathers what /metrics reports
+	metrics *prometheus.Registry
 }
 
 // NewAPIServer creates a new instance of APIServer
 func NewAPIServer(storage storage.Storage) *APIServer {
-	return &APIServer{
+	s := &APIServer{
 		nodeRegistry:       registry.NewNodeRegistry(storage),
 		podRegistry:        registry.NewPodRegistry(storage),
 		replicasetRegistry: registry.NewReplicaSetRegistry(storage),
+		eventRegistry:      registry.NewEventRegistry(storage),
+		namespaceRegistry:  registry.NewNamespaceRegistry(storage),
+		quotaRegistry:      registry.NewResourceQuotaRegistry(storage),
+		limitRangeRegistry: registry.NewLimitRangeRegistry(storage),
+		serviceRegistry:    registry.NewServiceRegistry(storage),
+		endpointsRegistry:  registry.NewEndpointsRegistry(storage),
+		nodeLeaseRegistry:  registry.NewNodeLeaseRegistry(storage),
+		logger:             logging.Component("apiserver"),
+		metrics:            prometheus.NewRegistry(),
 	}
+	// Pods and ReplicaSets are only created in namespaces that exist and are not terminating
+	s.podRegistry.SetNamespaceRegistry(s.namespaceRegistry)
+	s.replicasetRegistry.SetNamespaceRegistry(s.namespaceRegistry)
+	s.quotaRegistry.SetNamespaceRegistry(s.namespaceRegistry)
+	s.limitRangeRegistry.SetNamespaceRegistry(s.namespaceRegistry)
+	s.serviceRegistry.SetNamespaceRegistry(s.namespaceRegistry)
+	s.podRegistry.SetResourceQuotaRegistry(s.quotaRegistry)
+	s.podRegistry.SetLimitRangeRegistry(s.limitRangeRegistry)
+	s.metrics.MustRegister(newPodStatusCollector(s.podRegistry))
+	return s
+}
+
+// EnsureDefaultNamespace creates the default namespace unless it exists, e.g. from an earlier start
+func (s *APIServer) EnsureDefaultNamespace(ctx context.Context) error {
+	if err := s.namespaceRegistry.EnsureDefault(ctx); err != nil {
+		return fmt.Errorf("failed to create the %s namespace: %w", api.DefaultNamespace, err)
+	}
+	return nil
+}
+
+// MigrateLegacyReplicaSets moves the ReplicaSets an older version stored without a namespace to theirs, so
+// they are served and reconciled again
+func (s *APIServer) MigrateLegacyReplicaSets(ctx context.Context) error {
+	migrated, err := s.replicasetRegistry.MigrateLegacyReplicaSets(ctx)
+	if migrated > 0 {
+		s.logger.InfoContext(ctx, "Moved the replicasets stored without a namespace", "replicasets", migrated)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move the replicasets stored without a namespace: %w", err)
+	}
+	return nil
+}
+
+// SetLogger makes the API server log to logger instead of the default logger
+func (s *APIServer) SetLogger(logger *slog.Logger) {
+	s.logger = logging.WithComponent(logger, "apiserver")
+}
+
+// SetMaxReplicasPerReplicaSet changes the largest replica count accepted for a ReplicaSet
+func (s *APIServer) SetMaxReplicasPerReplicaSet(maxReplicas int32) {
+	s.replicasetRegistry.SetMaxReplicas(maxReplicas)
+}
+
+// RegisterMetrics adds collectors to what /metrics reports, such as the storage.PrometheusStorageMetrics of
+// the storage of the API server
+func (s *APIServer) RegisterMetrics(collectors ...prometheus.Collector) {
+	s.metrics.MustRegister(collectors...)
 }
 
 // Start initializes and starts the API server
 func (s *APIServer) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.StartWithListener(listener)
+}
+
+// StartWithListener serves the API on a listener the caller opened, e.g. on port 0 to pick a free port, and
+// returns once the listener is closed
+func (s *APIServer) StartWithListener(listener net.Listener) error {
+	return http.Serve(listener, s.Handler())
+}
+
+// Handler returns the handler serving the API and its metrics on /metrics, e.g. to run the API server inside
+// an httptest.Server
+func (s *APIServer) Handler() http.Handler {
 	container := restful.NewContainer()
+	container.Filter(s.logRequest)
 	s.registerRoutes(container)
+	container.Handle("/metrics", promhttp.HandlerFor(s.metrics, promhttp.HandlerOpts{}))
+	return container
+}
+
+// logRequest tags the request with an ID and logs it once it is served: changes at info level, reads at
+// debug level, as the components poll, and server errors at error level
+func (s *APIServer) logRequest(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	requestID := request.HeaderParameter(logging.RequestIDHeader)
+	if requestID == "" {
+		requestID = logging.NewRequestID()
+	}
+	ctx := logging.WithRequestID(request.Request.Context(), requestID)
+	request.Request = request.Request.WithContext(ctx)
+	response.Header().Set(logging.RequestIDHeader, requestID)
 
-	return http.ListenAndServe(address, container)
+	started := time.Now()
+	chain.ProcessFilter(request, response)
+
+	level := slog.LevelDebug
+	switch {
+	case response.StatusCode() >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case request.Request.Method != http.MethodGet:
+		level = slog.LevelInfo
+	}
+	s.logger.Log(ctx, level, "Served request", "method", request.Request.Method, "path", request.Request.URL.Path,
+		"status", response.StatusCode(), "duration", time.Since(started))
 }
 
 // registerRoutes adds routes to the container
@@ -42,9 +158,17 @@ func (s *APIServer) registerRoutes(container *restful.Container) {
 
 	ws.Path("/api/v1").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
 	ws.Route(ws.GET("/healthz").To(s.healthz))
+	ws.Route(ws.GET("/readyz").To(s.readyz))
 	handlers.RegisterPodRoutes(ws, handlers.NewPodHandler(s.podRegistry))
 	handlers.RegisterNodeRoutes(ws, handlers.NewNodeHandler(s.nodeRegistry))
 	handlers.RegisterReplicasetRoutes(ws, handlers.NewReplicasetHandler(s.replicasetRegistry))
+	handlers.RegisterEventRoutes(ws, handlers.NewEventHandler(s.eventRegistry))
+	handlers.RegisterNamespaceRoutes(ws, handlers.NewNamespaceHandler(s.namespaceRegistry))
+	handlers.RegisterResourceQuotaRoutes(ws, handlers.NewResourceQuotaHandler(s.quotaRegistry))
+	handlers.RegisterLimitRangeRoutes(ws, handlers.NewLimitRangeHandler(s.limitRangeRegistry))
+	handlers.RegisterServiceRoutes(ws, handlers.NewServiceHandler(s.serviceRegistry))
+	handlers.RegisterEndpointsRoutes(ws, handlers.NewEndpointsHandler(s.endpointsRegistry))
+	handlers.RegisterNodeLeaseRoutes(ws, handlers.NewNodeLeaseHandler(s.nodeLeaseRegistry))
 
 	container.Add(ws)
 }
@@ -52,3 +176,19 @@ func (s *APIServer) registerRoutes(container *restful.Container) {
 func (s *APIServer) healthz(request *restful.Request, response *restful.Response) {
 	api.WriteResponse(response, http.StatusOK, nil)
 }
+
+// Readiness is the body of /readyz, a summary of what the API server stores
+type Readiness struct {
+	Pods int64 `json:"pods"`
+}
+
+// readyz answers whether the API server can serve requests, which needs its storage. Counting the pods reaches
+// the storage without reading any of them, and summarizes it on the way.
+func (s *APIServer) readyz(request *restful.Request, response *restful.Response) {
+	pods, err := s.podRegistry.CountPods(request.Request.Context())
+	if err != nil {
+		api.WriteError(response, http.StatusServiceUnavailable, err)
+		return
+	}
+	api.WriteResponse(response, http.StatusOK, &Readiness{Pods: pods})
+}
@@ -1,12 +1,17 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
 	"gokube/pkg/storage"
 
 	"github.com/emicklei/go-restful/v3"
@@ -32,23 +37,38 @@ func TestNewAPIServer(t *testing.T) {
 
 func TestAPIServer_Start(t *testing.T) {
 	t.Run("should start server and handle requests", func(t *testing.T) {
-		withTestServer(t, func(etcdServer *clientv3.Client) {
+		withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
 			store := storage.NewEtcdStorage(etcdServer)
 			server := NewAPIServer(store)
 
-			// Start server in a goroutine
-			go func() {
-				err := server.Start(":0")
-				require.NoError(t, err)
-			}()
-
-			// Give the server time to start
-			time.Sleep(100 * time.Millisecond)
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			served := make(chan error, 1)
+			go func() { served <- server.StartWithListener(listener) }()
+
+			healthz := "http://" + listener.Addr().String() + "/api/v1/healthz"
+			require.Eventually(t, func() bool {
+				resp, err := http.Get(healthz)
+				if err != nil {
+					return false
+				}
+				resp.Body.Close()
+				return resp.StatusCode == http.StatusOK
+			}, 5*time.Second, 10*time.Millisecond)
+
+			// Closing the listener stops the server
+			require.NoError(t, listener.Close())
+			select {
+			case err := <-served:
+				assert.ErrorIs(t, err, net.ErrClosed)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the server did not stop when its listener was closed")
+			}
 		})
 	})
 
 	t.Run("should handle healthz endpoint", func(t *testing.T) {
-		withTestServer(t, func(etcdServer *clientv3.Client) {
+		withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
 			store := storage.NewEtcdStorage(etcdServer)
 			server := NewAPIServer(store)
 
@@ -61,11 +81,65 @@ func TestAPIServer_Start(t *testing.T) {
 			assert.Equal(t, http.StatusOK, resp.Code)
 		})
 	})
+
+	t.Run("should summarize the pods when ready", func(t *testing.T) {
+		store := storage.NewMemoryStorage()
+		for _, name := range []string{"web-1", "web-2"} {
+			require.NoError(t, store.Create(context.Background(), "/pods/"+name, &api.Pod{ObjectMeta: api.ObjectMeta{Name: name}}))
+		}
+		chaos := storage.NewChaosStorage(store, nil)
+		container := NewAPIServer(chaos).createTestContainer()
+
+		resp := httptest.NewRecorder()
+		container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/readyz", nil))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"pods": 2}`, resp.Body.String())
+
+		chaos.SetPolicy(storage.FailNth(storage.Match{Op: storage.OpCount}, 1, storage.ErrEtcdClient))
+		resp = httptest.NewRecorder()
+		container.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code, "not ready without storage")
+	})
+}
+
+func TestAPIServer_LogsRequests(t *testing.T) {
+	withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		server := NewAPIServer(storage.NewEtcdStorage(etcdServer))
+		var logs bytes.Buffer
+		logger, err := logging.New(&logs, logging.Options{Level: "debug", Format: logging.FormatText})
+		require.NoError(t, err)
+		server.SetLogger(logger)
+		handler := server.Handler()
+
+		t.Run("should echo the request ID of the client", func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", "/api/v1/healthz", nil)
+			req.Header.Set(logging.RequestIDHeader, "request-1")
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, "request-1", resp.Header().Get(logging.RequestIDHeader))
+			assert.Regexp(t, `level=DEBUG msg="Served request" component=apiserver method=GET path=/api/v1/healthz status=200 duration=\S+ requestID=request-1`, logs.String())
+		})
+
+		t.Run("should make up a request ID and log changes at info level", func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("DELETE", "/api/v1/pods/missing", nil)
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+
+			requestID := resp.Header().Get(logging.RequestIDHeader)
+			require.NotEmpty(t, requestID)
+			assert.Regexp(t, `level=INFO msg="Served request" component=apiserver method=DELETE path=/api/v1/pods/missing status=404 .*requestID=`+requestID, logs.String())
+		})
+	})
 }
 
 func TestAPIServer_RegisterRoutes(t *testing.T) {
 	t.Run("should register all routes correctly", func(t *testing.T) {
-		withTestServer(t, func(etcdServer *clientv3.Client) {
+		withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
 			store := storage.NewEtcdStorage(etcdServer)
 			server := NewAPIServer(store)
 			container := server.createTestContainer()
@@ -84,6 +158,7 @@ func TestAPIServer_RegisterRoutes(t *testing.T) {
 				"/api/v1/nodes/{name}:PUT":    true, // Get node
 				"/api/v1/nodes/{name}:DELETE": true, // Delete node
 				"/api/v1/healthz:GET":         true, // Health check
+				"/api/v1/readyz:GET":          true, // Readiness check
 			}
 
 			foundRoutes := make(map[string]bool)
@@ -107,14 +182,48 @@ func (s *APIServer) createTestContainer() *restful.Container {
 	return container
 }
 
-// Helper function to set up a test environment with etcd
-func withTestServer(t *testing.T, fn func(*clientv3.Client)) {
-	// Set up etcd client for testing
-	client, err := clientv3.New(clientv3.Config{
-		Endpoints: []string{"localhost:2379"},
+// Helper function to set up a test environment with an embedded etcd
+func TestAPIServer_Metrics(t *testing.T) {
+	withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		server := NewAPIServer(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+		for name, status := range map[string]api.PodStatus{"web-1": api.PodRunning, "web-2": api.PodRunning, "web-3": api.PodPending} {
+			require.NoError(t, server.podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+				Status:     status,
+			}))
+		}
+
+		resp := httptest.NewRecorder()
+		server.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Body.String(), `gokube_pods{status="Running"} 2`)
+		assert.Contains(t, resp.Body.String(), `gokube_pods{status="Pending"} 1`)
+		assert.Contains(t, resp.Body.String(), `gokube_pods{status="Failed"} 0`, "statuses without pods are reported")
 	})
-	require.NoError(t, err)
-	defer client.Close()
+}
+
+func TestAPIServer_StorageMetrics(t *testing.T) {
+	withTestServer(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		storageMetrics := storage.NewPrometheusStorageMetrics()
+		store.SetMetrics(storageMetrics)
+		server := NewAPIServer(store)
+		server.RegisterMetrics(storageMetrics)
+
+		resp := httptest.NewRecorder()
+		server.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/nodes/missing", nil))
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		resp = httptest.NewRecorder()
+		server.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Contains(t, resp.Body.String(), `gokube_storage_request_duration_seconds_count{op="get",prefix="/registry/nodes/"} 1`)
+		assert.Contains(t, resp.Body.String(), `gokube_storage_requests_total{op="get",prefix="/registry/nodes/",result="error"} 1`)
+	})
+}
 
-	fn(client)
+func withTestServer(t *testing.T, fn func(t *testing.T, etcdServer *clientv3.Client)) {
+	storage.TestWithEmbeddedEtcd(t, fn)
 }
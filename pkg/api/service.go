@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidService = errors.New("invalid service")
+
+const (
+	KindService   = "Service"
+	KindEndpoints = "Endpoints"
+	// MaxPort is the highest TCP port
+	MaxPort = 65535
+)
+
+// Service exposes the pods its selector matches on a port of every node, where the proxy forwards the
+// connections to them
+type Service struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Spec       ServiceSpec `json:"spec"`
+}
+
+// ServiceSpec selects the pods of a Service and the ports it exposes them on
+type ServiceSpec struct {
+	Selector map[string]string `json:"selector"`
+	Ports    []ServicePort     `json:"ports"`
+}
+
+// ServicePort exposes a container port of the pods of a Service
+type ServicePort struct {
+	// Name tells the ports of the Service apart; it is required when there are several
+	Name string `json:"name,omitempty"`
+	// Port is the port the proxy listens on
+	Port int32 `json:"port"`
+	// TargetPort is the container port connections are forwarded to, which defaults to Port. The container
+	// must publish it on a host port of its node.
+	TargetPort int32 `json:"targetPort,omitempty"`
+}
+
+// GetTargetPort returns the container port the connections to the port go to
+func (p *ServicePort) GetTargetPort() int32 {
+	if p.TargetPort == 0 {
+		return p.Port
+	}
+	return p.TargetPort
+}
+
+// Validate checks that the Service is named by a lowercase RFC 1123 label, selects pods and exposes distinct,
+// valid ports
+func (s *Service) Validate() error {
+	var fieldErrs FieldErrors
+	if !isLabelName(s.Name) {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   "metadata.name",
+			Message: fmt.Sprintf("must be a lowercase RFC 1123 label, such as web, got %q", s.Name),
+		})
+	}
+	if len(s.Spec.Selector) == 0 {
+		fieldErrs = append(fieldErrs, FieldError{Field: "spec.selector", Message: "is required"})
+	}
+	if len(s.Spec.Ports) == 0 {
+		fieldErrs = append(fieldErrs, FieldError{Field: "spec.ports", Message: "is required"})
+	}
+	names, ports := map[string]bool{}, map[int32]bool{}
+	for i, port := range s.Spec.Ports {
+		prefix := fmt.Sprintf("spec.ports[%d]", i)
+		if port.Name == "" && len(s.Spec.Ports) > 1 {
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".name", Message: "is required when there are several ports"})
+		}
+		if port.Name != "" && names[port.Name] {
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".name", Message: fmt.Sprintf("must be unique, %q is taken", port.Name)})
+		}
+		names[port.Name] = true
+		if !isPort(port.Port) {
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".port", Message: fmt.Sprintf("must be between 1 and %d, got %d", MaxPort, port.Port)})
+		} else if ports[port.Port] {
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".port", Message: fmt.Sprintf("must be unique, %d is taken", port.Port)})
+		}
+		ports[port.Port] = true
+		if port.TargetPort != 0 && !isPort(port.TargetPort) {
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + ".targetPort", Message: fmt.Sprintf("must be between 1 and %d, got %d", MaxPort, port.TargetPort)})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidService, fieldErrs)
+	}
+	return nil
+}
+
+func isPort(port int32) bool {
+	return port >= 1 && port <= MaxPort
+}
+
+// Endpoints are where the connections to a Service go: for each port of the Service, the node addresses and
+// host ports of its running pods. They are named after their Service and kept up to date by the endpoints
+// controller.
+type Endpoints struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Ports      []EndpointPort `json:"ports"`
+}
+
+// EndpointPort lists the backends of a port of a Service
+type EndpointPort struct {
+	// Name is the name of the port of the Service
+	Name string `json:"name,omitempty"`
+	// Port is the port of the Service
+	Port      int32             `json:"port"`
+	Addresses []EndpointAddress `json:"addresses"`
+}
+
+// EndpointAddress is a pod that serves a port of a Service, reached through a host port of its node
+type EndpointAddress struct {
+	// IP is the address of the node of the pod
+	IP string `json:"ip"`
+	// Port is the host port the container port is published on
+	Port     int32  `json:"port"`
+	NodeName string `json:"nodeName,omitempty"`
+	PodName  string `json:"podName,omitempty"`
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceValidate(t *testing.T) {
+	valid := func() *Service {
+		return &Service{
+			ObjectMeta: ObjectMeta{Name: "web"},
+			Spec: ServiceSpec{
+				Selector: map[string]string{"app": "web"},
+				Ports:    []ServicePort{{Name: "http", Port: 80, TargetPort: 8080}, {Name: "https", Port: 443}},
+			},
+		}
+	}
+	require.NoError(t, valid().Validate())
+
+	service := valid()
+	service.Spec.Selector = nil
+	service.Spec.Ports = []ServicePort{{Port: 80}, {Name: "http", Port: 80, TargetPort: 70000}}
+	err := service.Validate()
+	assert.ErrorIs(t, err, ErrInvalidService)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, FieldErrors{
+		{Field: "spec.selector", Message: "is required"},
+		{Field: "spec.ports[0].name", Message: "is required when there are several ports"},
+		{Field: "spec.ports[1].port", Message: "must be unique, 80 is taken"},
+		{Field: "spec.ports[1].targetPort", Message: "must be between 1 and 65535, got 70000"},
+	}, fieldErrs)
+
+	assert.Equal(t, int32(443), valid().Spec.Ports[1].GetTargetPort(), "the target port defaults to the port")
+}
+
+func TestPodValidatePorts(t *testing.T) {
+	pod := &Pod{
+		ObjectMeta: ObjectMeta{Name: "web"},
+		Spec: PodSpec{Containers: []Container{
+			{Name: "web", Image: "nginx", Ports: []ContainerPort{{ContainerPort: 8080, HostPort: 30080}}},
+			{Name: "sidecar", Image: "nginx", Ports: []ContainerPort{{ContainerPort: 0}, {ContainerPort: 9090, HostPort: 30080}}},
+		}},
+	}
+	err := pod.Validate()
+	assert.ErrorIs(t, err, ErrInvalidPodSpec)
+	var fieldErrs FieldErrors
+	require.ErrorAs(t, err, &fieldErrs)
+	assert.Equal(t, FieldErrors{
+		{Field: "spec.containers[1].ports[0].containerPort", Message: "must be between 1 and 65535, got 0"},
+		{Field: "spec.containers[1].ports[1].hostPort", Message: "must be unique, 30080 is taken"},
+	}, fieldErrs)
+}
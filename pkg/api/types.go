@@ -2,6 +2,9 @@ package api
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -27,24 +30,199 @@ const (
 
 	//TODO: Kubernetes separates PodPhase and PodCondition. We have simplified to have a single pod status.
 	PodScheduled PodStatus = "Scheduled"
+
+	// PodTerminating means the pod was deleted before it finished and is kept until its kubelet has stopped its
+	// containers
+	PodTerminating PodStatus = "Terminating"
 )
 
+// PodStatuses are all the statuses of a pod, in the order a pod goes through them, followed by Terminating,
+// which a pod that has not finished moves to when it is deleted
+var PodStatuses = []PodStatus{PodPending, PodScheduled, PodRunning, PodSucceeded, PodFailed, PodTerminating}
+
 var (
 	ErrInvalidNodeSpec = errors.New("invalid node spec")
+	ErrInvalidNodeName = errors.New("invalid node name")
+	// ErrInvalidPodStatus is returned for a status that is not one of PodStatuses
+	ErrInvalidPodStatus = errors.New("invalid pod status")
 )
 
+// ValidatePodStatus returns ErrInvalidPodStatus, naming the allowed statuses, unless status is one of
+// PodStatuses
+func ValidatePodStatus(status PodStatus) error {
+	if slices.Contains(PodStatuses, status) {
+		return nil
+	}
+	allowed := make([]string, len(PodStatuses))
+	for i, s := range PodStatuses {
+		allowed[i] = string(s)
+	}
+	return fmt.Errorf("%w %q, must be one of %s", ErrInvalidPodStatus, status, strings.Join(allowed, ", "))
+}
+
+// podTransitions are the statuses a pod may move on to from each status. A pod may skip Scheduled, as one bound
+// to its node when it is created goes straight to Running, and Running, as the containers of a short pod may
+// exit before the kubelet reports them running, but nothing comes back from Succeeded, Failed or Terminating.
+var podTransitions = map[PodStatus][]PodStatus{
+	PodPending:   {PodScheduled, PodRunning, PodFailed, PodTerminating},
+	PodScheduled: {PodRunning, PodSucceeded, PodFailed, PodTerminating},
+	PodRunning:   {PodSucceeded, PodFailed, PodTerminating},
+}
+
+// IsFinished checks if a pod of status s is done: all of its containers exited and none will be started again
+func (s PodStatus) IsFinished() bool {
+	return s == PodSucceeded || s == PodFailed
+}
+
+// CanTransitionTo reports whether a pod of status s may move to status to. Staying at the same status is always
+// allowed.
+func (s PodStatus) CanTransitionTo(to PodStatus) bool {
+	return s == to || slices.Contains(podTransitions[s], to)
+}
+
 type Container struct {
 	Name  string `json:"name" validate:"required"`
 	Image string `json:"image" validate:"required"`
+	// Resources are the cpu and memory the container requests
+	Resources *ResourceRequirements `json:"resources,omitempty"`
+	// Ports are the ports the container listens on
+	Ports []ContainerPort `json:"ports,omitempty"`
+}
+
+// ContainerPort is a port a container listens on, which may be published on a port of its node
+type ContainerPort struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int32  `json:"containerPort"`
+	// HostPort publishes the container port on this port of the node, which is how Services reach the pod;
+	// zero leaves it unpublished
+	HostPort int32 `json:"hostPort,omitempty"`
+}
+
+type ContainerState string
+
+const (
+	ContainerWaiting    ContainerState = "Waiting"
+	ContainerRunning    ContainerState = "Running"
+	ContainerTerminated ContainerState = "Terminated"
+)
+
+// ContainerStatus is the kubelet's view of a single container of a pod
+type ContainerStatus struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID,omitempty"`
+	Image       string `json:"image,omitempty"`
+	// ImageID is the image the container actually runs, as a repo digest such as nginx@sha256:<digest> for
+	// pulled images, so it is known even when Image names a tag like latest
+	ImageID string         `json:"imageID,omitempty"`
+	State   ContainerState `json:"state"`
+	// Reason explains the state, e.g. CrashLoopBackOff while a restart is being delayed
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable description of the reason, such as the image pull error
+	Message      string `json:"message,omitempty"`
+	ExitCode     int    `json:"exitCode,omitempty"`
+	RestartCount int32  `json:"restartCount"`
+}
+
+// GetContainerStatus returns the status of the named container, or nil if none was reported
+func (p *Pod) GetContainerStatus(name string) *ContainerStatus {
+	for i := range p.ContainerStatuses {
+		if p.ContainerStatuses[i].Name == name {
+			return &p.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// SetContainerStatus adds the status or replaces the one reported for the same container
+func (p *Pod) SetContainerStatus(status ContainerStatus) {
+	if existing := p.GetContainerStatus(status.Name); existing != nil {
+		*existing = status
+		return
+	}
+	p.ContainerStatuses = append(p.ContainerStatuses, status)
 }
 
 // ObjectMeta is minimal metadata that all persisted resources must have
 type ObjectMeta struct {
-	Name              string    `json:"name" validate:"required"`
-	Namespace         string    `json:"namespace,omitempty"`
-	UID               string    `json:"uid,omitempty"`
-	ResourceVersion   string    `json:"resourceVersion,omitempty"`
-	CreationTimestamp time.Time `json:"creationTimestamp,omitempty"`
+	Name              string            `json:"name" validate:"required"`
+	Namespace         string            `json:"namespace,omitempty"`
+	UID               string            `json:"uid,omitempty"`
+	ResourceVersion   string            `json:"resourceVersion,omitempty"`
+	CreationTimestamp time.Time         `json:"creationTimestamp,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	OwnerReferences   []OwnerReference  `json:"ownerReferences,omitempty"`
+	// DeletionTimestamp is when the object was deleted, set by the server on an object that is kept until its
+	// finalizers are removed
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	// Finalizers name what has to be done before a deleted object is removed; whoever does it removes its
+	// finalizer, and the object is removed once none is left
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+// IsDeleting checks if the object was deleted and waits for its finalizers to be removed
+func (m *ObjectMeta) IsDeleting() bool {
+	return m.DeletionTimestamp != nil
+}
+
+// GetResourceVersion returns the resource version, the etcd revision the object was read or written at
+func (m *ObjectMeta) GetResourceVersion() string {
+	return m.ResourceVersion
+}
+
+// SetResourceVersion sets the resource version, which the storage keeps current on every read and write
+func (m *ObjectMeta) SetResourceVersion(version string) {
+	m.ResourceVersion = version
+}
+
+// TraceIDAnnotation holds the ID of the request that created an object, or of the object it was created for,
+// e.g. the ReplicaSet of a pod. Components log their work on the object with it, and send it as the request
+// ID of their API calls, so the logs of an object can be followed across components.
+const TraceIDAnnotation = "gokube.io/trace-id"
+
+// TraceID returns the trace ID of the object, see TraceIDAnnotation
+func (m *ObjectMeta) TraceID() string {
+	return m.Annotations[TraceIDAnnotation]
+}
+
+// SetTraceID sets the trace ID of the object, see TraceIDAnnotation
+func (m *ObjectMeta) SetTraceID(traceID string) {
+	if m.Annotations == nil {
+		m.Annotations = make(map[string]string)
+	}
+	m.Annotations[TraceIDAnnotation] = traceID
+}
+
+const (
+	KindReplicaSet = "ReplicaSet"
+)
+
+// OwnerReference identifies the object that owns (and, if Controller is set, manages) another object
+type OwnerReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+	Controller bool   `json:"controller,omitempty"`
+}
+
+// GetControllerOf returns the owner reference marked as the managing controller, or nil if there is none
+func (m *ObjectMeta) GetControllerOf() *OwnerReference {
+	for i := range m.OwnerReferences {
+		if m.OwnerReferences[i].Controller {
+			return &m.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+// NewControllerRef creates an OwnerReference pointing at the given controller object
+func NewControllerRef(owner *ObjectMeta, kind string) OwnerReference {
+	return OwnerReference{
+		Kind:       kind,
+		Name:       owner.Name,
+		UID:        owner.UID,
+		Controller: true,
+	}
 }
 
 // NodeSpec describes the basic attributes of a node
@@ -63,6 +241,16 @@ const (
 	NodeDiskPressure   NodeStatus = "DiskPressure"
 )
 
+// NodeCondition reports a problem of a node, such as running low on memory. The node stays Ready while it
+// has conditions.
+type NodeCondition struct {
+	Type               NodeStatus      `json:"type"`
+	Status             ConditionStatus `json:"status"`
+	Reason             string          `json:"reason,omitempty"`
+	Message            string          `json:"message,omitempty"`
+	LastTransitionTime time.Time       `json:"lastTransitionTime,omitempty"`
+}
+
 // ReplicaSet represents the configuration of a ReplicaSet
 type ReplicaSet struct {
 	ObjectMeta `json:"metadata,omitempty"`
@@ -85,8 +273,32 @@ type PodTemplateSpec struct {
 
 // ReplicaSetStatus represents the current status of a ReplicaSet
 type ReplicaSetStatus struct {
-	Replicas             int32 `json:"replicas"`
-	FullyLabeledReplicas int32 `json:"fullyLabeledReplicas,omitempty"`
-	ReadyReplicas        int32 `json:"readyReplicas,omitempty"`
-	AvailableReplicas    int32 `json:"availableReplicas,omitempty"`
+	Replicas             int32                 `json:"replicas"`
+	FullyLabeledReplicas int32                 `json:"fullyLabeledReplicas,omitempty"`
+	ReadyReplicas        int32                 `json:"readyReplicas,omitempty"`
+	AvailableReplicas    int32                 `json:"availableReplicas,omitempty"`
+	Conditions           []ReplicaSetCondition `json:"conditions,omitempty"`
+}
+
+type ConditionStatus string
+
+const (
+	ConditionTrue  ConditionStatus = "True"
+	ConditionFalse ConditionStatus = "False"
+)
+
+type ReplicaSetConditionType string
+
+const (
+	// ReplicaSetReplicaFailure is added when the controller is unable to create or delete pods for a ReplicaSet
+	ReplicaSetReplicaFailure ReplicaSetConditionType = "ReplicaFailure"
+)
+
+// ReplicaSetCondition describes the state of a ReplicaSet at a certain point
+type ReplicaSetCondition struct {
+	Type               ReplicaSetConditionType `json:"type"`
+	Status             ConditionStatus         `json:"status"`
+	Reason             string                  `json:"reason,omitempty"`
+	Message            string                  `json:"message,omitempty"`
+	LastTransitionTime time.Time               `json:"lastTransitionTime,omitempty"`
 }
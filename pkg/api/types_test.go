@@ -1,15 +1,13 @@
 package api
 
 import (
+	"slices"
 	"testing"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestContainerValidation(t *testing.T) {
-	validate := validator.New()
-
 	tests := []struct {
 		name      string
 		container Container
@@ -28,20 +26,20 @@ func TestContainerValidation(t *testing.T) {
 			container: Container{
 				Image: "nginx:latest",
 			},
-			wantErr: "Key: 'Container.Name' Error:Field validation for 'Name' failed on the 'required' tag",
+			wantErr: "name is required",
 		},
 		{
 			name: "missing container image",
 			container: Container{
 				Name: "nginx-container",
 			},
-			wantErr: "Key: 'Container.Image' Error:Field validation for 'Image' failed on the 'required' tag",
+			wantErr: "image is required",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validate.Struct(tt.container)
+			err := ValidateStruct(tt.container)
 			if tt.wantErr == "" {
 				assert.NoError(t, err)
 			} else {
@@ -52,8 +50,6 @@ func TestContainerValidation(t *testing.T) {
 	}
 }
 func TestObjectMetaValidation(t *testing.T) {
-	validate := validator.New()
-
 	tests := []struct {
 		name       string
 		objectMeta ObjectMeta
@@ -78,20 +74,20 @@ func TestObjectMetaValidation(t *testing.T) {
 		{
 			name:       "missing name field",
 			objectMeta: ObjectMeta{},
-			wantErr:    "Key: 'ObjectMeta.Name' Error:Field validation for 'Name' failed on the 'required' tag",
+			wantErr:    "name is required",
 		},
 		{
 			name: "empty name field",
 			objectMeta: ObjectMeta{
 				Name: "",
 			},
-			wantErr: "Key: 'ObjectMeta.Name' Error:Field validation for 'Name' failed on the 'required' tag",
+			wantErr: "name is required",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := validate.Struct(tt.objectMeta)
+			err := ValidateStruct(tt.objectMeta)
 			if tt.wantErr == "" {
 				assert.NoError(t, err)
 			} else {
@@ -101,3 +97,22 @@ func TestObjectMetaValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestPodStatus_CanTransitionTo(t *testing.T) {
+	allowed := map[PodStatus][]PodStatus{
+		PodPending:     {PodPending, PodScheduled, PodRunning, PodFailed, PodTerminating},
+		PodScheduled:   {PodScheduled, PodRunning, PodSucceeded, PodFailed, PodTerminating},
+		PodRunning:     {PodRunning, PodSucceeded, PodFailed, PodTerminating},
+		PodSucceeded:   {PodSucceeded},
+		PodFailed:      {PodFailed},
+		PodTerminating: {PodTerminating},
+	}
+
+	for _, from := range PodStatuses {
+		for _, to := range PodStatuses {
+			t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+				assert.Equal(t, slices.Contains(allowed[from], to), from.CanTransitionTo(to))
+			})
+		}
+	}
+}
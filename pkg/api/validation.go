@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a violation of a field of an object
+type FieldError struct {
+	// Field is the path of the field in the JSON form of the object, e.g. spec.containers[0].image
+	Field string `json:"field"`
+	// Message says what is wrong with the field, e.g. is required
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// FieldErrors are all the violations of an object. The API server reports them in the Errors of a Status.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validate checks the validate tags of the API types. It is safe for concurrent use and caches the types.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Report fields by their JSON names, as users write them
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// ValidateStruct checks the validate tags of obj, skipping the Go fields named in except, e.g. ObjectMeta.
+// The violations are returned as FieldErrors.
+func ValidateStruct(obj any, except ...string) error {
+	var err error
+	if len(except) > 0 {
+		err = validate.StructExcept(obj, except...)
+	} else {
+		err = validate.Struct(obj)
+	}
+	return translateValidationError(err)
+}
+
+// translateValidationError turns the violations the validator reports into FieldErrors
+func translateValidationError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+	fieldErrs := make(FieldErrors, 0, len(validationErrs))
+	for _, validationErr := range validationErrs {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   fieldPath(validationErr.Namespace()),
+			Message: validationMessage(validationErr),
+		})
+	}
+	return fieldErrs
+}
+
+// fieldPath turns the namespace of a violation, such as Pod.spec.containers[0].image, into the path of the
+// field in the object by dropping the type name
+func fieldPath(namespace string) string {
+	_, path, _ := strings.Cut(namespace, ".")
+	return path
+}
+
+// validationMessage says in words what the tag that failed requires
+func validationMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(strings.Fields(err.Param()), ", "), fmt.Sprint(err.Value()))
+	case "gte", "min":
+		return fmt.Sprintf("must be at least %s, got %v", err.Param(), err.Value())
+	case "lte", "max":
+		return fmt.Sprintf("must be at most %s, got %v", err.Param(), err.Value())
+	case "hostname_rfc1123":
+		return "must be an RFC 1123 host name"
+	default:
+		return fmt.Sprintf("is invalid: failed the %s check", err.Tag())
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+// WatchContentType is the content type of a watch response: a stream of newline-delimited JSON events
+const WatchContentType = "application/json;stream=watch"
+
+// EventType describes the kind of change a watch event reports
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+)
+
+// PodWatchEvent is a single change in a pod watch stream. For EventDeleted, Object is the last known state of
+// the pod.
+type PodWatchEvent struct {
+	Type   EventType `json:"type"`
+	Object *Pod      `json:"object"`
+}
+
+// NodeWatchEvent is a single change in a node watch stream. For EventDeleted, Object is the last known state of
+// the node.
+type NodeWatchEvent struct {
+	Type   EventType `json:"type"`
+	Object *Node     `json:"object"`
+}
+
+// ReplicaSetWatchEvent is a single change in a ReplicaSet watch stream. For EventDeleted, Object is the last
+// known state of the ReplicaSet.
+type ReplicaSetWatchEvent struct {
+	Type   EventType   `json:"type"`
+	Object *ReplicaSet `json:"object"`
+}
@@ -0,0 +1,426 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"gokube/pkg/logging"
+)
+
+const (
+	apiPath = "/api/v1"
+
+	// DefaultTimeout bounds each attempt of a request when no timeout is configured
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxRetries is how often a failed request is retried when no limit is configured
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoff is the wait before the first retry when no backoff is configured
+	DefaultRetryBackoff = 100 * time.Millisecond
+	// MaxRetryBackoff caps the doubling wait between retries
+	MaxRetryBackoff = 5 * time.Second
+	// DefaultQPS is the sustained rate of requests a client sends when no rate is configured
+	DefaultQPS = 50
+	// DefaultBurst is how many requests a client sends at once above DefaultQPS when no burst is configured
+	DefaultBurst = 100
+)
+
+var (
+	ErrInvalidURL = errors.New("invalid API server URL")
+	// ErrUnreachable is returned when a request could not be sent or got no answer, e.g. because the API
+	// server is down. The error of the connection is wrapped as well.
+	ErrUnreachable = errors.New("failed to send request to API server")
+)
+
+// Options configure how the client connects to the API server
+type Options struct {
+	// TLSConfig configures the connections to an https API server, e.g. to trust its CA
+	TLSConfig *tls.Config
+	// Token is sent as a bearer token with every request
+	Token string
+	// Timeout bounds each attempt of a request, from sending it to reading the answer, so a hung API server
+	// cannot hang the caller. Zero uses DefaultTimeout and a negative timeout disables it. Watches are not
+	// bounded.
+	Timeout time.Duration
+	// MaxRetries is how often a request is retried after a connection error or a 5xx answer, for the verbs
+	// that are safe to repeat, or after a 429 answer, for all of them. Zero uses DefaultMaxRetries and a
+	// negative count disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for every further one up to MaxRetryBackoff.
+	// A Retry-After header of the answer takes precedence. Zero uses DefaultRetryBackoff.
+	RetryBackoff time.Duration
+	// QPS is the sustained rate of requests, retries included, the client sends, so a hot loop cannot
+	// overload the API server. Zero uses DefaultQPS and a negative rate disables the limit.
+	QPS float64
+	// Burst is how many requests the client sends at once above QPS. Zero uses DefaultBurst.
+	Burst int
+	// ConditionalGets keeps the last answer of every GET the API server tagged with an ETag, and asks for it
+	// again with If-None-Match, so an object or list that did not change is answered 304 Not Modified without
+	// a body and decoded from the kept copy. It suits pollers, which read the same few paths over and over.
+	ConditionalGets bool
+}
+
+// Client talks to the gokube API server. It is safe for concurrent use.
+type Client struct {
+	baseURL      *url.URL
+	token        string
+	httpClient   *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+	// limiter paces the requests; nil sends them as they come
+	limiter *rate.Limiter
+	// responses keeps the tagged answers of GETs for Options.ConditionalGets; nil when disabled
+	responses *responseCache
+	// sleep waits between retries and for the limiter, returning false if ctx is done first
+	sleep func(ctx context.Context, d time.Duration) bool
+}
+
+// New creates a client for the API server at baseURL, such as https://10.0.0.1:8080. A bare host:port, as
+// the components are configured with, is taken as an http URL.
+func New(baseURL string, options Options) (*Client, error) {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidURL, baseURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w %q: expected http(s)://host:port", ErrInvalidURL, baseURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	httpClient := http.DefaultClient
+	if options.TLSConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = options.TLSConfig
+		httpClient = &http.Client{Transport: transport}
+	}
+	c := &Client{
+		baseURL:      parsed,
+		token:        options.Token,
+		httpClient:   httpClient,
+		timeout:      valueOrDefault(options.Timeout, DefaultTimeout),
+		maxRetries:   valueOrDefault(options.MaxRetries, DefaultMaxRetries),
+		retryBackoff: valueOrDefault(options.RetryBackoff, DefaultRetryBackoff),
+		sleep:        sleep,
+	}
+	if options.ConditionalGets {
+		c.responses = newResponseCache(maxCachedResponses)
+	}
+	if qps := valueOrDefault(options.QPS, DefaultQPS); qps > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(qps), max(valueOrDefault(options.Burst, DefaultBurst), 1))
+	}
+	return c, nil
+}
+
+// valueOrDefault returns the value of an option, or its default when it is not set
+func valueOrDefault[T time.Duration | int | float64](value, defaultValue T) T {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// Pods returns the client for pods
+func (c *Client) Pods() *PodClient {
+	return &PodClient{client: c}
+}
+
+// PodsIn returns the client for the pods of a namespace
+func (c *Client) PodsIn(namespace string) *PodClient {
+	return &PodClient{client: c, namespace: namespace}
+}
+
+// Nodes returns the client for nodes
+func (c *Client) Nodes() *NodeClient {
+	return &NodeClient{client: c}
+}
+
+// NodeLeases returns the client for the leases of the nodes
+func (c *Client) NodeLeases() *NodeLeaseClient {
+	return &NodeLeaseClient{client: c}
+}
+
+// ReplicaSets returns the client for ReplicaSets
+func (c *Client) ReplicaSets() *ReplicaSetClient {
+	return &ReplicaSetClient{client: c}
+}
+
+// ReplicaSetsIn returns the client for the ReplicaSets of a namespace
+func (c *Client) ReplicaSetsIn(namespace string) *ReplicaSetClient {
+	return &ReplicaSetClient{client: c, namespace: namespace}
+}
+
+// Events returns the client for events
+func (c *Client) Events() *EventClient {
+	return &EventClient{client: c}
+}
+
+// Namespaces returns the client for namespaces
+func (c *Client) Namespaces() *NamespaceClient {
+	return &NamespaceClient{client: c}
+}
+
+// ResourceQuotas returns the client for the ResourceQuotas of a namespace
+func (c *Client) ResourceQuotas(namespace string) *ResourceQuotaClient {
+	return &ResourceQuotaClient{client: c, namespace: namespace}
+}
+
+// LimitRanges returns the client for the LimitRanges of a namespace
+func (c *Client) LimitRanges(namespace string) *LimitRangeClient {
+	return &LimitRangeClient{client: c, namespace: namespace}
+}
+
+// Services returns the client for the Services of a namespace, or of all namespaces if namespace is empty,
+// which can only be listed
+func (c *Client) Services(namespace string) *ServiceClient {
+	return &ServiceClient{client: c, namespace: namespace}
+}
+
+// Endpoints returns the client for the Endpoints of a namespace, or of all namespaces if namespace is empty
+func (c *Client) Endpoints(namespace string) *EndpointsClient {
+	return &EndpointsClient{client: c, namespace: namespace}
+}
+
+// Healthz checks if the API server is up
+func (c *Client) Healthz(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/healthz", nil, nil, nil)
+}
+
+// do sends a request to path below /api/v1 with body, if any, encoded as JSON, and decodes the response into
+// result, if set. An answer without a body leaves result as it is. Answers other than 2xx are returned as a
+// *StatusError. With Options.ConditionalGets, a GET whose answer is kept is sent with its ETag, and a 304 Not
+// Modified answer is decoded from the kept copy.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	_, err := c.doWithHeader(ctx, method, path, query, body, result)
+	return err
+}
+
+// doWithHeader is do that also returns the header of the response
+func (c *Client) doWithHeader(ctx context.Context, method, path string, query url.Values, body, result any) (http.Header, error) {
+	var header http.Header
+	var cacheKey string
+	var cached cachedResponse
+	conditional := c.responses != nil && method == http.MethodGet && result != nil
+	if conditional {
+		cacheKey = path + "?" + query.Encode()
+		var ok bool
+		if cached, ok = c.responses.get(cacheKey); ok {
+			header = http.Header{"If-None-Match": {cached.etag}}
+		}
+	}
+	resp, err := c.send(ctx, c.timeout, method, path, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && header != nil {
+		return resp.Header, decodeResponse(method, path, bytes.NewReader(cached.body), result)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newStatusError(resp)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return resp.Header, nil
+	}
+	etag := resp.Header.Get("ETag")
+	if !conditional || etag == "" {
+		return resp.Header, decodeResponse(method, path, resp.Body, result)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of %s %s: %w", method, path, err)
+	}
+	if err := decodeResponse(method, path, bytes.NewReader(data), result); err != nil {
+		return nil, err
+	}
+	c.responses.put(cacheKey, cachedResponse{etag: etag, body: data})
+	return resp.Header, nil
+}
+
+// decodeResponse decodes the JSON body of the response to a request into result. An empty body leaves result
+// as it is.
+func decodeResponse(method, path string, body io.Reader, result any) error {
+	if err := json.NewDecoder(body).Decode(result); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send sends a request with the given extra header, if any, and returns the response whatever its status,
+// once the retries are used up. Each attempt gets timeout, if positive, to be answered and read, until the
+// response body is closed. Failures to reach the API server wrap the error of the connection, so callers can
+// tell them apart.
+func (c *Client) send(ctx context.Context, timeout time.Duration, method, path string, query url.Values, body any, header http.Header) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+	}
+	target := c.baseURL.String() + apiPath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	backoff := c.retryBackoff
+	for retries := 0; ; retries++ {
+		resp, err := c.sendOnce(ctx, timeout, method, target, data, header)
+		wait, retry := retryAfter(method, resp, err)
+		if !retry || retries >= c.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			// Read to the end, so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			resp.Body.Close()
+		}
+		if wait < 0 {
+			wait, backoff = backoff, min(2*backoff, MaxRetryBackoff)
+		}
+		if !c.sleep(ctx, wait) {
+			return nil, fmt.Errorf("%w: %w", ErrUnreachable, ctx.Err())
+		}
+	}
+}
+
+// sendOnce makes a single attempt at a request, once the rate limiter lets it through
+func (c *Client) sendOnce(ctx context.Context, timeout time.Duration, method, target string, data []byte, header http.Header) (*http.Response, error) {
+	if c.limiter != nil {
+		reservation := c.limiter.Reserve()
+		if delay := reservation.Delay(); delay > 0 && !c.sleep(ctx, delay) {
+			reservation.Cancel()
+			return nil, fmt.Errorf("%w: %w", ErrUnreachable, ctx.Err())
+		}
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	// Forward the ID the caller traces its work with, so the API server logs the request under it
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// retryAfter checks if an attempt at a request is worth retrying, and how long to wait before. A negative
+// wait leaves it to the backoff. Only the verbs that are safe to repeat are retried after failures that may
+// have happened after the API server acted on the request, i.e. everything but a 429.
+func retryAfter(method string, resp *http.Response, err error) (time.Duration, bool) {
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+	switch {
+	case err != nil:
+		return -1, idempotent && errors.Is(err, ErrUnreachable)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return parseRetryAfter(resp.Header.Get("Retry-After")), true
+	case resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented:
+		return parseRetryAfter(resp.Header.Get("Retry-After")), idempotent
+	default:
+		return -1, false
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as a date. It returns -1 when there is none.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return -1
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return -1
+}
+
+// cancelingBody releases the timeout of a request once its response is read
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// namePath returns the escaped path of the named object in a collection, e.g. /pods/web
+func namePath(collection, name string, subresource ...string) string {
+	return strings.Join(append([]string{collection, url.PathEscape(name)}, subresource...), "/")
+}
+
+// namesQuery asks a list endpoint for the named objects only
+func namesQuery(names []string) url.Values {
+	return url.Values{"names": {strings.Join(names, ",")}}
+}
+
+// byName indexes the objects answered for a request of namesQuery, and lists the names without an object in
+// the order they were asked for
+func byName[T any](names []string, objects []*T, name func(*T) string) (map[string]*T, []string) {
+	found := make(map[string]*T, len(objects))
+	for _, object := range objects {
+		found[name(object)] = object
+	}
+	var missing []string
+	for _, name := range names {
+		if _, ok := found[name]; !ok && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	return found, missing
+}
@@ -0,0 +1,539 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/logging"
+	"gokube/pkg/storage"
+)
+
+// withAPIServer runs test against a client of an API server backed by embedded etcd
+func withAPIServer(t *testing.T, test func(t *testing.T, c *Client)) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		apiServer := httptest.NewServer(server.NewAPIServer(storage.NewEtcdStorage(etcdClient)).Handler())
+		defer apiServer.Close()
+
+		c, err := New(apiServer.URL, Options{})
+		require.NoError(t, err)
+		test(t, c)
+	})
+}
+
+func newPod(name string) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+	}
+}
+
+func TestClientAgainstAPIServer(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, c *Client) {
+		ctx := context.Background()
+		require.NoError(t, c.Healthz(ctx))
+
+		t.Run("pods", func(t *testing.T) {
+			created, err := c.Pods().Create(ctx, newPod("web"))
+			require.NoError(t, err)
+			assert.NotEmpty(t, created.UID)
+
+			_, err = c.Pods().Create(ctx, newPod("web"))
+			assert.True(t, IsConflict(err), "creating an existing pod conflicts: %v", err)
+			_, err = c.Pods().Create(ctx, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "empty"}})
+			assert.True(t, IsInvalid(err), "a pod without containers is invalid: %v", err)
+
+			named, missing, err := c.Pods().GetNamed(ctx, []string{"web", "gone"})
+			require.NoError(t, err)
+			assert.Equal(t, created.UID, named["web"].UID)
+			assert.Equal(t, []string{"gone"}, missing)
+
+			unassigned, err := c.Pods().ListUnassigned(ctx)
+			require.NoError(t, err)
+			require.Len(t, unassigned, 1)
+
+			created.NodeName = "node-1"
+			created.Status = api.PodScheduled
+			_, err = c.Pods().Update(ctx, created)
+			require.NoError(t, err)
+			pods, err := c.Pods().List(ctx, PodListOptions{NodeName: "node-1"})
+			require.NoError(t, err)
+			require.Len(t, pods, 1)
+			counts, err := c.Pods().CountByStatus(ctx, PodListOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, map[api.PodStatus]int{api.PodScheduled: 1}, counts)
+			pods, err = c.Pods().List(ctx, PodListOptions{NodeName: "node-2"})
+			require.NoError(t, err)
+			assert.Empty(t, pods)
+			pods, err = c.Pods().List(ctx, PodListOptions{LabelSelector: map[string]string{"app": "db"}})
+			require.NoError(t, err)
+			assert.Empty(t, pods)
+
+			updated, err := c.Pods().UpdateStatus(ctx, &api.PodStatusUpdate{Name: "web", UID: created.UID, NodeName: "node-1", Status: api.PodRunning})
+			require.NoError(t, err)
+			assert.Equal(t, api.PodRunning, updated.Status)
+			_, err = c.Pods().UpdateStatus(ctx, &api.PodStatusUpdate{Name: "web", UID: "other", NodeName: "node-1", Status: api.PodFailed})
+			assert.True(t, IsConflict(err), "a status update for another pod conflicts: %v", err)
+
+			pod, err := c.Pods().Get(ctx, "web")
+			require.NoError(t, err)
+			assert.Equal(t, api.PodRunning, pod.Status)
+
+			require.NoError(t, c.Pods().Evict(ctx, "web", &api.Eviction{Reason: "MemoryPressure"}))
+			pod, err = c.Pods().Get(ctx, "web")
+			require.NoError(t, err)
+			assert.True(t, pod.IsDeleting(), "an evicted pod waits for its kubelet to stop it")
+			require.NoError(t, c.Pods().ForceDelete(ctx, "web"))
+			assert.True(t, IsNotFound(c.Pods().Delete(ctx, "web")))
+
+			pods, resourceVersion, err := c.Pods().ListWithResourceVersion(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, pods)
+			_, err = c.Pods().Create(ctx, newPod("watched"))
+			require.NoError(t, err)
+			watchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			watch, err := c.Pods().Watch(watchCtx, PodListOptions{ResourceVersion: resourceVersion})
+			require.NoError(t, err)
+			defer watch.Close()
+			event, err := watch.Next()
+			require.NoError(t, err)
+			assert.Equal(t, api.EventAdded, event.Type, "the watch starts after the list")
+			assert.Equal(t, "watched", event.Object.Name)
+			require.NoError(t, c.Pods().ForceDelete(ctx, "watched"))
+			event, err = watch.Next()
+			require.NoError(t, err)
+			assert.Equal(t, api.EventDeleted, event.Type)
+		})
+
+		t.Run("namespaced pods", func(t *testing.T) {
+			_, err := c.Namespaces().Create(ctx, &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "staging"}})
+			require.NoError(t, err)
+			staging := c.PodsIn("staging")
+			created, err := staging.Create(ctx, newPod("cache"))
+			require.NoError(t, err)
+			assert.Equal(t, "staging", created.Namespace)
+
+			_, err = c.Pods().Get(ctx, "cache")
+			assert.True(t, IsNotFound(err), "pods are got from the default namespace: %v", err)
+			pods, err := staging.List(ctx, PodListOptions{})
+			require.NoError(t, err)
+			require.Len(t, pods, 1)
+			_, err = staging.UpdateStatus(ctx, &api.PodStatusUpdate{Name: "cache", Status: api.PodFailed})
+			require.NoError(t, err)
+			require.NoError(t, staging.Delete(ctx, "cache"))
+		})
+
+		t.Run("nodes", func(t *testing.T) {
+			_, err := c.Nodes().Create(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1"}, Status: api.NodeReady})
+			require.NoError(t, err)
+
+			node, err := c.Nodes().Get(ctx, "node-1")
+			require.NoError(t, err)
+			node.Status = api.NodeNotReady
+			updated, err := c.Nodes().UpdateStatus(ctx, node)
+			require.NoError(t, err)
+			assert.Equal(t, api.NodeNotReady, updated.Status)
+
+			node.UID = "machine-1"
+			_, err = c.Nodes().Update(ctx, node)
+			assert.True(t, IsConflict(err), "the node read is stale since its status was updated: %v", err)
+			updated.UID = "machine-1"
+			_, err = c.Nodes().Update(ctx, updated)
+			require.NoError(t, err)
+			nodes, err := c.Nodes().List(ctx)
+			require.NoError(t, err)
+			require.Len(t, nodes, 1)
+			assert.Equal(t, "machine-1", nodes[0].UID)
+			named, missing, err := c.Nodes().GetNamed(ctx, []string{"node-2", "node-1"})
+			require.NoError(t, err)
+			assert.Equal(t, "machine-1", named["node-1"].UID)
+			assert.Equal(t, []string{"node-2"}, missing)
+
+			require.NoError(t, c.Nodes().Delete(ctx, "node-1"))
+			_, err = c.Nodes().Get(ctx, "node-1")
+			assert.True(t, IsNotFound(err))
+		})
+
+		t.Run("replicasets", func(t *testing.T) {
+			rs := &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: "web"},
+				Spec: api.ReplicaSetSpec{
+					Replicas: 2,
+					Selector: map[string]string{"app": "web"},
+					Template: api.PodTemplateSpec{
+						ObjectMeta: api.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}},
+						Spec:       newPod("web").Spec,
+					},
+				},
+			}
+			_, err := c.ReplicaSets().Create(ctx, rs)
+			require.NoError(t, err)
+
+			rs.Spec.Replicas = 3
+			_, err = c.ReplicaSets().Update(ctx, rs)
+			require.NoError(t, err)
+			got, err := c.ReplicaSets().Get(ctx, "web")
+			require.NoError(t, err)
+			assert.Equal(t, int32(3), got.Spec.Replicas)
+			list, err := c.ReplicaSets().List(ctx)
+			require.NoError(t, err)
+			assert.Len(t, list, 1)
+
+			scale, err := c.ReplicaSets().GetScale(ctx, "web")
+			require.NoError(t, err)
+			assert.Equal(t, int32(3), scale.Spec.Replicas)
+			scale.Spec.Replicas = 1
+			scale, err = c.ReplicaSets().UpdateScale(ctx, scale)
+			require.NoError(t, err)
+			assert.Equal(t, int32(1), scale.Spec.Replicas)
+			scale.Spec.Replicas = -1
+			_, err = c.ReplicaSets().UpdateScale(ctx, scale)
+			assert.True(t, IsInvalid(err), "a negative replica count is invalid: %v", err)
+
+			require.NoError(t, c.ReplicaSets().Delete(ctx, "web"))
+			_, err = c.ReplicaSets().Get(ctx, "web")
+			assert.True(t, IsNotFound(err))
+		})
+
+		t.Run("events", func(t *testing.T) {
+			object := api.ObjectReference{Kind: api.KindNode, Name: "node-1"}
+			created, err := c.Events().Create(ctx, &api.Event{InvolvedObject: object, Reason: "NodeHasMemoryPressure", Message: "low on memory"})
+			require.NoError(t, err)
+			assert.NotEmpty(t, created.Name)
+
+			events, err := c.Events().List(ctx, api.KindNode, "node-1")
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "NodeHasMemoryPressure", events[0].Reason)
+			events, err = c.Events().List(ctx, api.KindPod, "node-1")
+			require.NoError(t, err)
+			assert.Empty(t, events)
+		})
+	})
+}
+
+func TestClientConditionalGets(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		handler := server.NewAPIServer(storage.NewEtcdStorage(etcdClient)).Handler()
+		var mutex sync.Mutex
+		var codes []int
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+			if r.Method == http.MethodGet {
+				mutex.Lock()
+				codes = append(codes, recorder.Code)
+				mutex.Unlock()
+			}
+			for name, values := range recorder.Header() {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(recorder.Code)
+			_, _ = w.Write(recorder.Body.Bytes())
+		}))
+		defer apiServer.Close()
+		c, err := New(apiServer.URL, Options{ConditionalGets: true})
+		require.NoError(t, err)
+		ctx := context.Background()
+
+		_, err = c.Pods().Create(ctx, newPod("web"))
+		require.NoError(t, err)
+		first, err := c.Pods().List(ctx, PodListOptions{})
+		require.NoError(t, err)
+		second, err := c.Pods().List(ctx, PodListOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, first, second, "the unchanged list is decoded from the kept copy")
+		assert.Equal(t, []int{http.StatusOK, http.StatusNotModified}, codes)
+
+		first[0].Status = api.PodRunning
+		_, err = c.Pods().Update(ctx, first[0])
+		require.NoError(t, err)
+		third, err := c.Pods().List(ctx, PodListOptions{})
+		require.NoError(t, err)
+		require.Len(t, third, 1)
+		assert.Equal(t, api.PodRunning, third[0].Status)
+		assert.Equal(t, []int{http.StatusOK, http.StatusNotModified, http.StatusOK}, codes, "the update invalidates the list")
+	})
+}
+
+func TestNew(t *testing.T) {
+	c, err := New("localhost:8080", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", c.baseURL.String(), "a bare address is an http URL")
+
+	c, err = New("https://api.example.com/", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com", c.baseURL.String())
+
+	for _, invalid := range []string{"ftp://api.example.com", "http://", "http://api example.com"} {
+		_, err := New(invalid, Options{})
+		assert.ErrorIs(t, err, ErrInvalidURL, invalid)
+	}
+}
+
+func TestClientSendsTokenAndDecodesErrors(t *testing.T) {
+	var authorization string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/api/v1/pods/web":
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(api.Status{Code: http.StatusNotFound, Message: "pod not found: web"})
+		default:
+			// Not the API server, e.g. a proxy answering in plain text
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}
+	}))
+	defer apiServer.Close()
+
+	c, err := New(apiServer.URL, Options{Token: "secret"})
+	require.NoError(t, err)
+
+	_, err = c.Pods().Get(context.Background(), "web")
+	assert.Equal(t, "Bearer secret", authorization)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, &StatusError{Code: http.StatusNotFound, Message: "pod not found: web"}, statusErr)
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsServerError(err))
+
+	_, err = c.Nodes().List(context.Background())
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "bad gateway", statusErr.Message)
+	assert.True(t, IsServerError(err))
+}
+
+func TestClientForwardsRequestID(t *testing.T) {
+	requestIDs := make(chan string, 2)
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs <- r.Header.Get(logging.RequestIDHeader)
+		_ = json.NewEncoder(w).Encode([]api.Node{})
+	}))
+	defer apiServer.Close()
+	c, err := New(apiServer.URL, Options{})
+	require.NoError(t, err)
+
+	_, err = c.Nodes().List(logging.WithRequestID(context.Background(), "trace-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "trace-1", <-requestIDs)
+
+	_, err = c.Nodes().List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, <-requestIDs, "the API server makes up the IDs of untraced requests")
+}
+
+func TestClientKeepsConnectionErrors(t *testing.T) {
+	c, err := New("127.0.0.1:1", Options{})
+	require.NoError(t, err)
+
+	_, err = c.Pods().Get(context.Background(), "web")
+	assert.ErrorIs(t, err, ErrUnreachable)
+	var opErr *net.OpError
+	assert.True(t, errors.As(err, &opErr), "callers tell an unreachable API server by the connection error: %v", err)
+	assert.Zero(t, StatusCode(err))
+}
+
+func TestPodWatch(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("watch"))
+		assert.Equal(t, "node-1", r.URL.Query().Get("nodeName"))
+		assert.Equal(t, "42", r.URL.Query().Get("resourceVersion"))
+		w.Header().Set("Content-Type", api.WatchContentType)
+		encoder := json.NewEncoder(w)
+		_ = encoder.Encode(api.PodWatchEvent{Type: api.EventAdded, Object: newPod("web")})
+		// Events without an object, such as bookmarks, are skipped
+		_ = encoder.Encode(api.PodWatchEvent{Type: api.EventModified})
+		_ = encoder.Encode(api.PodWatchEvent{Type: api.EventDeleted, Object: newPod("web")})
+	}))
+	defer apiServer.Close()
+
+	c, err := New(apiServer.URL, Options{})
+	require.NoError(t, err)
+	watch, err := c.Pods().Watch(context.Background(), PodListOptions{NodeName: "node-1", ResourceVersion: "42"})
+	require.NoError(t, err)
+	defer watch.Close()
+
+	event, err := watch.Next()
+	require.NoError(t, err)
+	assert.Equal(t, api.EventAdded, event.Type)
+	event, err = watch.Next()
+	require.NoError(t, err)
+	assert.Equal(t, api.EventDeleted, event.Type)
+	assert.Equal(t, "web", event.Object.Name)
+	_, err = watch.Next()
+	assert.ErrorIs(t, err, ErrWatchClosed)
+}
+
+// failingAPIServer answers the requests with the given status codes in turn, then as if they succeeded
+func failingAPIServer(t *testing.T, codes ...int) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var requests []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method)
+		n := len(requests)
+		mu.Unlock()
+		if n > len(codes) && r.Method == http.MethodPost {
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		if n > len(codes) {
+			_ = json.NewEncoder(w).Encode([]*api.Pod{})
+			return
+		}
+		if codes[n-1] == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "2")
+		}
+		w.WriteHeader(codes[n-1])
+		_ = json.NewEncoder(w).Encode(api.Status{Code: codes[n-1], Message: "try again"})
+	}))
+	t.Cleanup(apiServer.Close)
+	return apiServer, &requests
+}
+
+// recordSleeps makes c retry without waiting, recording the waits instead
+func recordSleeps(c *Client) *[]time.Duration {
+	var sleeps []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) bool {
+		sleeps = append(sleeps, d)
+		return ctx.Err() == nil
+	}
+	return &sleeps
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("idempotent requests back off on server errors", func(t *testing.T) {
+		apiServer, requests := failingAPIServer(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusInternalServerError)
+		c, err := New(apiServer.URL, Options{})
+		require.NoError(t, err)
+		sleeps := recordSleeps(c)
+
+		_, err = c.Pods().List(ctx, PodListOptions{})
+		require.NoError(t, err)
+		assert.Len(t, *requests, 4)
+		assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}, *sleeps)
+	})
+
+	t.Run("the last error is returned once the retries are used up", func(t *testing.T) {
+		apiServer, requests := failingAPIServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		c, err := New(apiServer.URL, Options{MaxRetries: 2, RetryBackoff: 3 * time.Second})
+		require.NoError(t, err)
+		sleeps := recordSleeps(c)
+
+		_, err = c.Pods().List(ctx, PodListOptions{})
+		assert.True(t, IsServerError(err))
+		assert.Len(t, *requests, 3)
+		assert.Equal(t, []time.Duration{3 * time.Second, MaxRetryBackoff}, *sleeps)
+	})
+
+	t.Run("creates are not repeated after server errors", func(t *testing.T) {
+		apiServer, requests := failingAPIServer(t, http.StatusInternalServerError)
+		c, err := New(apiServer.URL, Options{})
+		require.NoError(t, err)
+		recordSleeps(c)
+
+		_, err = c.Pods().Create(ctx, newPod("web"))
+		assert.True(t, IsServerError(err))
+		assert.Equal(t, []string{http.MethodPost}, *requests)
+	})
+
+	t.Run("throttled requests wait as long as the server asks", func(t *testing.T) {
+		apiServer, requests := failingAPIServer(t, http.StatusTooManyRequests)
+		c, err := New(apiServer.URL, Options{})
+		require.NoError(t, err)
+		sleeps := recordSleeps(c)
+
+		_, err = c.Pods().Create(ctx, newPod("web"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{http.MethodPost, http.MethodPost}, *requests)
+		assert.Equal(t, []time.Duration{2 * time.Second}, *sleeps)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		apiServer, requests := failingAPIServer(t, http.StatusConflict)
+		c, err := New(apiServer.URL, Options{})
+		require.NoError(t, err)
+
+		_, err = c.Pods().List(ctx, PodListOptions{})
+		assert.True(t, IsConflict(err))
+		assert.Len(t, *requests, 1)
+	})
+
+	t.Run("retries disabled", func(t *testing.T) {
+		apiServer, requests := failingAPIServer(t, http.StatusServiceUnavailable)
+		c, err := New(apiServer.URL, Options{MaxRetries: -1})
+		require.NoError(t, err)
+
+		_, err = c.Pods().List(ctx, PodListOptions{})
+		assert.True(t, IsServerError(err))
+		assert.Len(t, *requests, 1)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Equal(t, time.Duration(-1), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(-1), parseRetryAfter("soon"))
+	assert.Zero(t, parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)), "a date in the past")
+	assert.InDelta(t, time.Minute, parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)), float64(2*time.Second))
+}
+
+func TestClientTimesOutHungAPIServer(t *testing.T) {
+	var requests atomic.Int32
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-r.Context().Done()
+	}))
+	defer apiServer.Close()
+
+	c, err := New(apiServer.URL, Options{Timeout: 50 * time.Millisecond, MaxRetries: 1, RetryBackoff: time.Millisecond})
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = c.Nodes().Get(context.Background(), "node-1")
+	assert.ErrorIs(t, err, ErrUnreachable)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, int32(2), requests.Load(), "a timed out request is retried")
+}
+
+func TestClientLimitsRate(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*api.Node{})
+	}))
+	defer apiServer.Close()
+
+	c, err := New(apiServer.URL, Options{QPS: 20, Burst: 2})
+	require.NoError(t, err)
+	start := time.Now()
+	for range 6 {
+		_, err := c.Nodes().List(context.Background())
+		require.NoError(t, err)
+	}
+	// The burst goes out at once, the other 4 requests 50ms apart
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+
+	c, err = New(apiServer.URL, Options{QPS: -1})
+	require.NoError(t, err)
+	start = time.Now()
+	for range 50 {
+		_, err := c.Nodes().List(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), time.Second, "the limit is disabled")
+}
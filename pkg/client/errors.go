@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gokube/pkg/api"
+)
+
+// maxErrorBodySize limits how much of an error response is read
+const maxErrorBodySize = 64 * 1024
+
+// StatusError is an error answered by the API server
+type StatusError struct {
+	// Code is the HTTP status code of the response
+	Code int
+	// Message is the reason given by the API server
+	Message string
+	// Errors lists the fields of an object the API server rejected as invalid
+	Errors []api.FieldError
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API server answered %d %s", e.Code, http.StatusText(e.Code))
+	}
+	return fmt.Sprintf("API server answered %d %s: %s", e.Code, http.StatusText(e.Code), e.Message)
+}
+
+// newStatusError reads the api.Status body of an error response. Bodies of other servers, e.g. a proxy in
+// front of the API server, are kept as the message.
+func newStatusError(resp *http.Response) *StatusError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	var status api.Status
+	if err := json.Unmarshal(data, &status); err != nil || status.Message == "" {
+		status.Message = strings.TrimSpace(string(data))
+	}
+	return &StatusError{Code: resp.StatusCode, Message: status.Message, Errors: status.Errors}
+}
+
+// StatusCode returns the HTTP status code of a *StatusError in the chain of err, or 0 if there is none
+func StatusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code
+	}
+	return 0
+}
+
+// IsNotFound checks if err reports that the object does not exist
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsConflict checks if err reports that the object already exists or changed in the meantime
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// IsForbidden checks if err reports that the request is not allowed, e.g. writing a mirror pod
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+// IsInvalid checks if err reports that the API server rejected the object sent
+func IsInvalid(err error) bool {
+	code := StatusCode(err)
+	return code == http.StatusBadRequest || code == http.StatusUnprocessableEntity
+}
+
+// IsServerError checks if err reports that the API server failed, which is worth retrying
+func IsServerError(err error) bool {
+	return StatusCode(err) >= http.StatusInternalServerError
+}
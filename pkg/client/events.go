@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"gokube/pkg/api"
+)
+
+// EventClient records and reads events
+type EventClient struct {
+	client *Client
+}
+
+// Create records the event and returns it as stored, e.g. with its generated name
+func (c *EventClient) Create(ctx context.Context, event *api.Event) (*api.Event, error) {
+	created := &api.Event{}
+	if err := c.client.do(ctx, http.MethodPost, "/events", nil, event, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// List lists the events about the object of the given kind and name, or every event when both are empty
+func (c *EventClient) List(ctx context.Context, kind, name string) ([]*api.Event, error) {
+	query := url.Values{}
+	if kind != "" {
+		query.Set("involvedObject.kind", kind)
+	}
+	if name != "" {
+		query.Set("involvedObject.name", name)
+	}
+	var events []*api.Event
+	if err := c.client.do(ctx, http.MethodGet, "/events", query, nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,179 @@
+package informers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+// runnable is an informer of any resource
+type runnable interface {
+	Run(ctx context.Context)
+	HasSynced() bool
+}
+
+// SharedInformerFactory hands out one informer per resource, so the components of a process that watch the
+// same resource share a single cache and connection. It is safe for concurrent use.
+type SharedInformerFactory struct {
+	client       *client.Client
+	relistPeriod time.Duration
+
+	mutex       sync.Mutex
+	pods        *Informer[api.Pod]
+	nodes       *Informer[api.Node]
+	replicaSets *Informer[api.ReplicaSet]
+	services    *Informer[api.Service]
+	endpoints   *Informer[api.Endpoints]
+	// informers are the informers handed out, in order
+	informers []runnable
+}
+
+// NewSharedInformerFactory creates a factory of informers of the API server c talks to, which relist the
+// resources the API server cannot watch every relistPeriod, e.g. DefaultRelistPeriod
+func NewSharedInformerFactory(c *client.Client, relistPeriod time.Duration) *SharedInformerFactory {
+	return &SharedInformerFactory{client: c, relistPeriod: relistPeriod}
+}
+
+// Pods returns the informer of pods, indexed by NodeNameIndex
+func (f *SharedInformerFactory) Pods() *Informer[api.Pod] {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.pods == nil {
+		list := f.client.Pods().ListWithResourceVersion
+		watch := func(ctx context.Context, resourceVersion string) (Watch[api.Pod], error) {
+			watch, err := f.client.Pods().Watch(ctx, client.PodListOptions{ResourceVersion: resourceVersion})
+			if err != nil {
+				return nil, err
+			}
+			return podWatch{watch}, nil
+		}
+		f.pods = NewInformer("pods", list, watch, func(pod *api.Pod) *api.ObjectMeta { return &pod.ObjectMeta },
+			Indexers[api.Pod]{NodeNameIndex: PodNodeName}, f.relistPeriod)
+		f.informers = append(f.informers, f.pods)
+	}
+	return f.pods
+}
+
+// Nodes returns the informer of nodes
+func (f *SharedInformerFactory) Nodes() *Informer[api.Node] {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.nodes == nil {
+		watch := func(ctx context.Context, resourceVersion string) (Watch[api.Node], error) {
+			watch, err := f.client.Nodes().Watch(ctx, resourceVersion)
+			if err != nil {
+				return nil, err
+			}
+			return nodeWatch{watch}, nil
+		}
+		f.nodes = NewInformer("nodes", f.client.Nodes().ListWithResourceVersion, watch, func(node *api.Node) *api.ObjectMeta { return &node.ObjectMeta },
+			nil, f.relistPeriod)
+		f.informers = append(f.informers, f.nodes)
+	}
+	return f.nodes
+}
+
+// ReplicaSets returns the informer of ReplicaSets
+func (f *SharedInformerFactory) ReplicaSets() *Informer[api.ReplicaSet] {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.replicaSets == nil {
+		watch := func(ctx context.Context, resourceVersion string) (Watch[api.ReplicaSet], error) {
+			watch, err := f.client.ReplicaSets().Watch(ctx, resourceVersion)
+			if err != nil {
+				return nil, err
+			}
+			return replicaSetWatch{watch}, nil
+		}
+		f.replicaSets = NewInformer("replicasets", f.client.ReplicaSets().ListWithResourceVersion, watch, func(rs *api.ReplicaSet) *api.ObjectMeta { return &rs.ObjectMeta },
+			nil, f.relistPeriod)
+		f.informers = append(f.informers, f.replicaSets)
+	}
+	return f.replicaSets
+}
+
+// Services returns the informer of the Services of all namespaces
+func (f *SharedInformerFactory) Services() *Informer[api.Service] {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.services == nil {
+		f.services = NewInformer("services", withoutResourceVersion(f.client.Services("").List), nil, func(service *api.Service) *api.ObjectMeta { return &service.ObjectMeta },
+			nil, f.relistPeriod)
+		f.informers = append(f.informers, f.services)
+	}
+	return f.services
+}
+
+// Endpoints returns the informer of the Endpoints of all namespaces
+func (f *SharedInformerFactory) Endpoints() *Informer[api.Endpoints] {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.endpoints == nil {
+		f.endpoints = NewInformer("endpoints", withoutResourceVersion(f.client.Endpoints("").List), nil, func(endpoints *api.Endpoints) *api.ObjectMeta { return &endpoints.ObjectMeta },
+			nil, f.relistPeriod)
+		f.informers = append(f.informers, f.endpoints)
+	}
+	return f.endpoints
+}
+
+// Start runs the informers handed out so far until ctx is done. Informers already running are left alone,
+// so Start can be called again after asking for more informers.
+func (f *SharedInformerFactory) Start(ctx context.Context) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, informer := range f.informers {
+		go informer.Run(ctx)
+	}
+}
+
+// WaitForCacheSync waits until the informers handed out so far synced. It returns false if ctx is done first.
+func (f *SharedInformerFactory) WaitForCacheSync(ctx context.Context) bool {
+	f.mutex.Lock()
+	synced := make([]func() bool, len(f.informers))
+	for i, informer := range f.informers {
+		synced[i] = informer.HasSynced
+	}
+	f.mutex.Unlock()
+	return WaitForCacheSync(ctx, synced...)
+}
+
+// podWatch adapts a pod watch of the client to Watch
+type podWatch struct {
+	*client.PodWatch
+}
+
+func (w podWatch) Next() (api.EventType, *api.Pod, error) {
+	event, err := w.PodWatch.Next()
+	return event.Type, event.Object, err
+}
+
+// nodeWatch adapts a node watch of the client to Watch
+type nodeWatch struct {
+	*client.NodeWatch
+}
+
+func (w nodeWatch) Next() (api.EventType, *api.Node, error) {
+	event, err := w.NodeWatch.Next()
+	return event.Type, event.Object, err
+}
+
+// replicaSetWatch adapts a ReplicaSet watch of the client to Watch
+type replicaSetWatch struct {
+	*client.ReplicaSetWatch
+}
+
+func (w replicaSetWatch) Next() (api.EventType, *api.ReplicaSet, error) {
+	event, err := w.ReplicaSetWatch.Next()
+	return event.Type, event.Object, err
+}
+
+// withoutResourceVersion makes a ListFunc of the list of a resource the API server cannot watch
+func withoutResourceVersion[T any](list func(ctx context.Context) ([]*T, error)) ListFunc[T] {
+	return func(ctx context.Context) ([]*T, string, error) {
+		objects, err := list(ctx)
+		return objects, "", err
+	}
+}
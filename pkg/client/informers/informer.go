@@ -0,0 +1,283 @@
+package informers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/logging"
+)
+
+const (
+	// DefaultRelistPeriod is how often informers relist the resources the API server cannot watch
+	DefaultRelistPeriod = time.Second
+	// cacheSyncPollInterval is how often WaitForCacheSync checks the informers
+	cacheSyncPollInterval = 10 * time.Millisecond
+)
+
+// ListFunc lists all objects of a resource, along with the resource version they were read at, or "" if it is
+// not known
+type ListFunc[T any] func(ctx context.Context) ([]*T, string, error)
+
+// WatchFunc opens a watch on the changes to a resource after resourceVersion, or from now if it is empty.
+// It returns an error wrapping client.ErrWatchNotSupported if the API server cannot watch the resource.
+type WatchFunc[T any] func(ctx context.Context, resourceVersion string) (Watch[T], error)
+
+// Watch is an open watch stream
+type Watch[T any] interface {
+	// Next blocks until the next change arrives, or returns an error once the stream ended
+	Next() (api.EventType, *T, error)
+	Close() error
+}
+
+// EventHandler is told about the changes an informer sees. Funcs left nil are skipped.
+type EventHandler[T any] struct {
+	AddFunc    func(object *T)
+	UpdateFunc func(old, new *T)
+	DeleteFunc func(object *T)
+}
+
+// Informer keeps a Store of the objects of a resource in sync with the API server and tells its handlers
+// about the changes. It lists the objects once, then follows a watch from the last resource version seen,
+// reopening the watch where it left off when it drops. A watch that ends without a change may be from a
+// resource version the API server no longer keeps, so the objects are listed again before the next. Resources the API server cannot watch are relisted
+// every relist period instead, and the changes found by comparing the listings.
+type Informer[T any] struct {
+	resource     string
+	list         ListFunc[T]
+	watch        WatchFunc[T]
+	meta         func(*T) *api.ObjectMeta
+	store        *Store[T]
+	relistPeriod time.Duration
+	logger       *slog.Logger
+
+	// mutex serializes the changes to the store with the calls of the handlers about them, so handlers
+	// added late see every object exactly once
+	mutex    sync.Mutex
+	handlers []EventHandler[T]
+	synced   atomic.Bool
+	running  atomic.Bool
+	// resourceVersion is the latest resource version seen, where a watch is resumed from
+	resourceVersion string
+}
+
+// NewInformer creates an informer of the resource named for logging, e.g. pods. watch is nil if the API
+// server cannot watch the resource.
+func NewInformer[T any](resource string, list ListFunc[T], watch WatchFunc[T], meta func(*T) *api.ObjectMeta,
+	indexers Indexers[T], relistPeriod time.Duration) *Informer[T] {
+	return &Informer[T]{
+		resource:     resource,
+		list:         list,
+		watch:        watch,
+		meta:         meta,
+		store:        NewStore(meta, indexers),
+		relistPeriod: relistPeriod,
+		logger:       logging.Component("informer").With("resource", resource),
+	}
+}
+
+// Store returns the cache of the informer, which is empty until HasSynced
+func (i *Informer[T]) Store() *Store[T] {
+	return i.store
+}
+
+// HasSynced checks if the store holds the first listing of the objects
+func (i *Informer[T]) HasSynced() bool {
+	return i.synced.Load()
+}
+
+// AddEventHandler registers handler for the changes to come. A handler added once the informer synced is
+// told about the objects in the store as added first. Handlers are called one at a time from the goroutine
+// of Run and must not block.
+func (i *Informer[T]) AddEventHandler(handler EventHandler[T]) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.handlers = append(i.handlers, handler)
+	if handler.AddFunc != nil && i.HasSynced() {
+		for _, object := range i.store.List() {
+			handler.AddFunc(object)
+		}
+	}
+}
+
+// Run keeps the store in sync until ctx is done. Calls made while the informer is already running return
+// straight away.
+func (i *Informer[T]) Run(ctx context.Context) {
+	if !i.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	canWatch, relist := i.watch != nil, true
+	for ctx.Err() == nil {
+		if relist {
+			if err := i.relist(ctx); err != nil {
+				if ctx.Err() == nil {
+					i.logger.Error("Failed to list", logging.Err(err))
+				}
+				sleep(ctx, i.relistPeriod)
+				continue
+			}
+			relist = false
+		}
+		if !canWatch {
+			sleep(ctx, i.relistPeriod)
+			relist = true
+			continue
+		}
+
+		watchedFrom := i.resourceVersion
+		err := i.watchChanges(ctx)
+		switch {
+		case ctx.Err() != nil:
+		case errors.Is(err, client.ErrWatchNotSupported):
+			canWatch = false
+		default:
+			relist = i.resourceVersion == watchedFrom
+			i.logger.Info("Watch ended, resuming it", "resourceVersion", i.resourceVersion, "relist", relist, logging.Err(err))
+			sleep(ctx, i.relistPeriod)
+		}
+	}
+}
+
+// relist replaces the objects in the store with the listed ones
+func (i *Informer[T]) relist(ctx context.Context) error {
+	objects, resourceVersion, err := i.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.resourceVersion = laterResourceVersion(i.resourceVersion, resourceVersion)
+	listed := make(map[string]struct{}, len(objects))
+	for _, object := range objects {
+		meta := i.meta(object)
+		listed[KeyOf(meta)] = struct{}{}
+		i.resourceVersion = laterResourceVersion(i.resourceVersion, meta.ResourceVersion)
+		old, ok := i.store.set(object)
+		switch {
+		case !ok:
+			i.added(object)
+		case !reflect.DeepEqual(old, object):
+			i.updated(old, object)
+		}
+	}
+	for _, object := range i.store.List() {
+		if _, ok := listed[KeyOf(i.meta(object))]; !ok {
+			i.store.delete(object)
+			i.deleted(object)
+		}
+	}
+	i.synced.Store(true)
+	return nil
+}
+
+// watchChanges applies the changes of a watch to the store until the watch ends
+func (i *Informer[T]) watchChanges(ctx context.Context) error {
+	watch, err := i.watch(ctx, i.resourceVersion)
+	if err != nil {
+		return err
+	}
+	defer watch.Close()
+
+	for {
+		eventType, object, err := watch.Next()
+		if err != nil {
+			return err
+		}
+		i.apply(eventType, object)
+	}
+}
+
+func (i *Informer[T]) apply(eventType api.EventType, object *T) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.resourceVersion = laterResourceVersion(i.resourceVersion, i.meta(object).ResourceVersion)
+	if eventType == api.EventDeleted {
+		if old, ok := i.store.delete(object); ok {
+			object = old
+		}
+		i.deleted(object)
+		return
+	}
+	if old, ok := i.store.set(object); ok {
+		i.updated(old, object)
+	} else {
+		i.added(object)
+	}
+}
+
+func (i *Informer[T]) added(object *T) {
+	for _, handler := range i.handlers {
+		if handler.AddFunc != nil {
+			handler.AddFunc(object)
+		}
+	}
+}
+
+func (i *Informer[T]) updated(old, new *T) {
+	for _, handler := range i.handlers {
+		if handler.UpdateFunc != nil {
+			handler.UpdateFunc(old, new)
+		}
+	}
+}
+
+func (i *Informer[T]) deleted(object *T) {
+	for _, handler := range i.handlers {
+		if handler.DeleteFunc != nil {
+			handler.DeleteFunc(object)
+		}
+	}
+}
+
+// WaitForCacheSync waits until all synced funcs, such as Informer.HasSynced, report true. It returns false
+// if ctx is done first.
+func WaitForCacheSync(ctx context.Context, synced ...func() bool) bool {
+	ticker := time.NewTicker(cacheSyncPollInterval)
+	defer ticker.Stop()
+	for {
+		allSynced := true
+		for _, hasSynced := range synced {
+			allSynced = allSynced && hasSynced()
+		}
+		if allSynced {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// laterResourceVersion returns the later of two resource versions, which are etcd revisions
+func laterResourceVersion(a, b string) string {
+	revisionA, errA := strconv.ParseInt(a, 10, 64)
+	revisionB, errB := strconv.ParseInt(b, 10, 64)
+	switch {
+	case errB != nil:
+		return a
+	case errA != nil || revisionB > revisionA:
+		return b
+	default:
+		return a
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
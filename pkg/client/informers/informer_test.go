@@ -0,0 +1,216 @@
+package informers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/client"
+	"gokube/pkg/storage"
+)
+
+// recorder records the changes an informer tells its handlers about, e.g. "update web node-1"
+type recorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(format string, args ...any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) get() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func (r *recorder) podHandler() EventHandler[api.Pod] {
+	return EventHandler[api.Pod]{
+		AddFunc:    func(pod *api.Pod) { r.record("add %s", pod.Name) },
+		UpdateFunc: func(old, new *api.Pod) { r.record("update %s %s", new.Name, new.NodeName) },
+		DeleteFunc: func(pod *api.Pod) { r.record("delete %s", pod.Name) },
+	}
+}
+
+func newPod(name string) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+	}
+}
+
+func TestInformersAgainstAPIServer(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		apiServer := httptest.NewServer(server.NewAPIServer(storage.NewEtcdStorage(etcdClient)).Handler())
+		defer apiServer.Close()
+		c, err := client.New(apiServer.URL, client.Options{})
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err = c.Pods().Create(ctx, newPod("web-1"))
+		require.NoError(t, err)
+		_, err = c.Nodes().Create(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1"}})
+		require.NoError(t, err)
+
+		factory := NewSharedInformerFactory(c, 10*time.Millisecond)
+		assert.Same(t, factory.Pods(), factory.Pods(), "informers are shared")
+		events := &recorder{}
+		factory.Pods().AddEventHandler(events.podHandler())
+		factory.Nodes()
+		factory.Start(ctx)
+		require.True(t, factory.WaitForCacheSync(ctx))
+		assert.Equal(t, []string{"add web-1"}, events.get())
+		_, ok := factory.Nodes().Store().Get("node-1")
+		assert.True(t, ok)
+
+		_, err = c.Pods().Create(ctx, newPod("web-2"))
+		require.NoError(t, err)
+		pod, err := c.Pods().Get(ctx, "web-1")
+		require.NoError(t, err)
+		pod.NodeName = "node-1"
+		_, err = c.Pods().Update(ctx, pod)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(events.get()) == 3 }, 5*time.Second, 10*time.Millisecond)
+		assert.ElementsMatch(t, []string{"add web-2", "update web-1 node-1"}, events.get()[1:])
+		pods, err := factory.Pods().Store().ByIndex(NodeNameIndex, "node-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web-1"}, podNames(pods))
+
+		require.NoError(t, c.Pods().Delete(ctx, "web-2"))
+		require.Eventually(t, func() bool { return len(events.get()) == 4 }, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "delete web-2", events.get()[3])
+
+		late := &recorder{}
+		factory.Pods().AddEventHandler(late.podHandler())
+		assert.Equal(t, []string{"add web-1"}, late.get(), "a late handler is told about the cached pods")
+
+		// Informers asked for after Start run on the next one
+		_, err = c.ReplicaSets().Create(ctx, &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec:       api.ReplicaSetSpec{Selector: map[string]string{"app": "web"}, Template: api.PodTemplateSpec{Spec: newPod("").Spec}},
+		})
+		require.NoError(t, err)
+		replicaSets := factory.ReplicaSets()
+		factory.Start(ctx)
+		require.True(t, factory.WaitForCacheSync(ctx))
+		assert.Len(t, replicaSets.Store().List(), 1)
+	})
+}
+
+func TestInformerResumesWatch(t *testing.T) {
+	pod := func(name, resourceVersion, nodeName string) *api.Pod {
+		return &api.Pod{ObjectMeta: api.ObjectMeta{Name: name, ResourceVersion: resourceVersion}, NodeName: nodeName}
+	}
+	var mutex sync.Mutex
+	var lists int
+	var watches []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		if r.URL.Query().Get("watch") != "true" {
+			lists++
+			mutex.Unlock()
+			_ = json.NewEncoder(w).Encode([]*api.Pod{pod("web-1", "7", ""), pod("web-2", "5", "")})
+			return
+		}
+		watches = append(watches, r.URL.Query().Get("resourceVersion"))
+		n := len(watches)
+		mutex.Unlock()
+
+		w.Header().Set("Content-Type", api.WatchContentType)
+		encoder := json.NewEncoder(w)
+		switch n {
+		case 1:
+			// Dropped after a single event
+			_ = encoder.Encode(api.PodWatchEvent{Type: api.EventModified, Object: pod("web-1", "9", "node-1")})
+		case 2:
+			_ = encoder.Encode(api.PodWatchEvent{Type: api.EventDeleted, Object: pod("web-2", "12", "")})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer apiServer.Close()
+	c, err := client.New(apiServer.URL, client.Options{})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	informer := NewSharedInformerFactory(c, 10*time.Millisecond).Pods()
+	events := &recorder{}
+	informer.AddEventHandler(events.podHandler())
+	go informer.Run(ctx)
+	require.Eventually(t, func() bool { return len(events.get()) == 4 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []string{"add web-1", "add web-2", "update web-1 node-1", "delete web-2"}, events.get())
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, lists, "a dropped watch is resumed without relisting")
+	assert.Equal(t, []string{"7", "9"}, watches, "the watch starts after the list and resumes after the last event")
+}
+
+func TestInformerRelistsAfterAnEmptyWatch(t *testing.T) {
+	var mutex sync.Mutex
+	var watches []string
+	lists := 0
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		if r.URL.Query().Get("watch") != "true" {
+			lists++
+			n := lists
+			mutex.Unlock()
+			// The list is read at a later resource version than the pods it holds
+			w.Header().Set(api.ResourceVersionHeader, fmt.Sprint(10*n))
+			_ = json.NewEncoder(w).Encode([]*api.Pod{{ObjectMeta: api.ObjectMeta{Name: "web", ResourceVersion: "3"}}})
+			return
+		}
+		watches = append(watches, r.URL.Query().Get("resourceVersion"))
+		n := len(watches)
+		mutex.Unlock()
+
+		w.Header().Set("Content-Type", api.WatchContentType)
+		// The first watch ends at once, as for a resource version the API server no longer keeps
+		if n > 1 {
+			<-r.Context().Done()
+		}
+	}))
+	defer apiServer.Close()
+	c, err := client.New(apiServer.URL, client.Options{})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go NewSharedInformerFactory(c, 10*time.Millisecond).Pods().Run(ctx)
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(watches) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 2, lists, "a watch that ended without a change is followed by a list")
+	assert.Equal(t, []string{"10", "20"}, watches, "the watches start at the resource version of the list")
+}
+
+func TestWaitForCacheSync(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.True(t, WaitForCacheSync(ctx))
+	assert.False(t, WaitForCacheSync(ctx, func() bool { return true }, func() bool { return false }))
+}
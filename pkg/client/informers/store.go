@@ -0,0 +1,149 @@
+package informers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gokube/pkg/api"
+)
+
+const (
+	// NodeNameIndex indexes pods by the node they are bound to
+	NodeNameIndex = "nodeName"
+)
+
+var (
+	ErrUnknownIndex = errors.New("unknown index")
+)
+
+// IndexFunc returns the values an object is indexed under; none leaves it out of the index
+type IndexFunc[T any] func(object *T) []string
+
+// Indexers are the indexes of a Store by name
+type Indexers[T any] map[string]IndexFunc[T]
+
+// PodNodeName indexes pods by NodeName. Unscheduled pods are left out.
+func PodNodeName(pod *api.Pod) []string {
+	if pod.NodeName == "" {
+		return nil
+	}
+	return []string{pod.NodeName}
+}
+
+// KeyOf returns the key of an object in a Store: namespace/name, or just the name in the default namespace
+func KeyOf(meta *api.ObjectMeta) string {
+	if api.NamespaceOf(meta) == api.DefaultNamespace {
+		return meta.Name
+	}
+	return meta.Namespace + "/" + meta.Name
+}
+
+// Store is an in-memory cache of objects by key, with secondary indexes. It is safe for concurrent use. The
+// objects it returns are shared with the other readers and must not be modified.
+type Store[T any] struct {
+	mutex    sync.RWMutex
+	meta     func(*T) *api.ObjectMeta
+	indexers Indexers[T]
+	objects  map[string]*T
+	// indices maps index name to indexed value to the keys of the objects indexed under it
+	indices map[string]map[string]map[string]struct{}
+}
+
+// NewStore creates an empty store of the objects meta returns the metadata of
+func NewStore[T any](meta func(*T) *api.ObjectMeta, indexers Indexers[T]) *Store[T] {
+	indices := make(map[string]map[string]map[string]struct{}, len(indexers))
+	for name := range indexers {
+		indices[name] = make(map[string]map[string]struct{})
+	}
+	return &Store[T]{meta: meta, indexers: indexers, objects: make(map[string]*T), indices: indices}
+}
+
+// Get returns the object with the given key, as returned by KeyOf
+func (s *Store[T]) Get(key string) (*T, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	object, ok := s.objects[key]
+	return object, ok
+}
+
+// List returns all objects, sorted by key
+func (s *Store[T]) List() []*T {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	return s.sorted(keys)
+}
+
+// ByIndex returns the objects indexed under value by the named index, sorted by key
+func (s *Store[T]) ByIndex(index, value string) ([]*T, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	values, ok := s.indices[index]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownIndex, index)
+	}
+	keys := make([]string, 0, len(values[value]))
+	for key := range values[value] {
+		keys = append(keys, key)
+	}
+	return s.sorted(keys), nil
+}
+
+func (s *Store[T]) sorted(keys []string) []*T {
+	sort.Strings(keys)
+	objects := make([]*T, len(keys))
+	for i, key := range keys {
+		objects[i] = s.objects[key]
+	}
+	return objects
+}
+
+// set adds or replaces an object, returning the one it replaced, if any
+func (s *Store[T]) set(object *T) (*T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := KeyOf(s.meta(object))
+	old, ok := s.objects[key]
+	if ok {
+		s.unindex(key, old)
+	}
+	s.objects[key] = object
+	for name, indexFunc := range s.indexers {
+		for _, value := range indexFunc(object) {
+			if s.indices[name][value] == nil {
+				s.indices[name][value] = make(map[string]struct{})
+			}
+			s.indices[name][value][key] = struct{}{}
+		}
+	}
+	return old, ok
+}
+
+// delete removes the object with the key of object, returning the removed one, if any
+func (s *Store[T]) delete(object *T) (*T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := KeyOf(s.meta(object))
+	old, ok := s.objects[key]
+	if ok {
+		s.unindex(key, old)
+		delete(s.objects, key)
+	}
+	return old, ok
+}
+
+func (s *Store[T]) unindex(key string, object *T) {
+	for name, indexFunc := range s.indexers {
+		for _, value := range indexFunc(object) {
+			delete(s.indices[name][value], key)
+			if len(s.indices[name][value]) == 0 {
+				delete(s.indices[name], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package informers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+)
+
+func newPodStore() *Store[api.Pod] {
+	return NewStore(func(pod *api.Pod) *api.ObjectMeta { return &pod.ObjectMeta }, Indexers[api.Pod]{NodeNameIndex: PodNodeName})
+}
+
+func podNames(pods []*api.Pod) []string {
+	names := make([]string, len(pods))
+	for i, pod := range pods {
+		names[i] = KeyOf(&pod.ObjectMeta)
+	}
+	return names
+}
+
+func TestStore(t *testing.T) {
+	store := newPodStore()
+	web := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}, NodeName: "node-1"}
+	_, replaced := store.set(web)
+	assert.False(t, replaced)
+	store.set(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "cache", Namespace: "staging"}, NodeName: "node-1"})
+	store.set(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "pending"}})
+
+	pod, ok := store.Get("web")
+	require.True(t, ok)
+	assert.Same(t, web, pod)
+	_, ok = store.Get("cache")
+	assert.False(t, ok, "keys include the namespace")
+	_, ok = store.Get("staging/cache")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"pending", "staging/cache", "web"}, podNames(store.List()))
+
+	pods, err := store.ByIndex(NodeNameIndex, "node-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging/cache", "web"}, podNames(pods))
+
+	// Moving a pod moves it in the index
+	old, replaced := store.set(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}, NodeName: "node-2"})
+	assert.True(t, replaced)
+	assert.Same(t, web, old)
+	pods, err = store.ByIndex(NodeNameIndex, "node-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging/cache"}, podNames(pods))
+	pods, err = store.ByIndex(NodeNameIndex, "node-2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, podNames(pods))
+
+	_, deleted := store.delete(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}})
+	assert.True(t, deleted)
+	_, deleted = store.delete(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}})
+	assert.False(t, deleted)
+	pods, err = store.ByIndex(NodeNameIndex, "node-2")
+	require.NoError(t, err)
+	assert.Empty(t, pods)
+	assert.Equal(t, []string{"pending", "staging/cache"}, podNames(store.List()))
+
+	_, err = store.ByIndex("phase", "Running")
+	assert.ErrorIs(t, err, ErrUnknownIndex)
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// NodeLeaseClient renews and reads the leases of the nodes
+type NodeLeaseClient struct {
+	client *Client
+}
+
+// Renew renews the lease of a node for its duration, acquiring it if it expired, and returns it as stored
+func (c *NodeLeaseClient) Renew(ctx context.Context, lease *api.Lease) (*api.Lease, error) {
+	renewed := &api.Lease{}
+	if err := c.client.do(ctx, http.MethodPut, namePath("/leases/nodes", lease.Name), nil, lease, renewed); err != nil {
+		return nil, err
+	}
+	return renewed, nil
+}
+
+// Get gets the lease of the named node, which exists only while its kubelet renews it
+func (c *NodeLeaseClient) Get(ctx context.Context, name string) (*api.Lease, error) {
+	lease := &api.Lease{}
+	if err := c.client.do(ctx, http.MethodGet, namePath("/leases/nodes", name), nil, nil, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// List lists the leases of the nodes whose kubelets are alive
+func (c *NodeLeaseClient) List(ctx context.Context) ([]*api.Lease, error) {
+	var leases []*api.Lease
+	if err := c.client.do(ctx, http.MethodGet, "/leases/nodes", nil, nil, &leases); err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
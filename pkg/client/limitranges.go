@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// LimitRangeClient reads and writes the LimitRanges of a namespace
+type LimitRangeClient struct {
+	client    *Client
+	namespace string
+}
+
+func (c *LimitRangeClient) path() string {
+	return namePath("/namespaces", c.namespace, "limitranges")
+}
+
+func (c *LimitRangeClient) Create(ctx context.Context, limitRange *api.LimitRange) (*api.LimitRange, error) {
+	created := &api.LimitRange{}
+	if err := c.client.do(ctx, http.MethodPost, c.path(), nil, limitRange, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Get reads the ResourceQuota with what the pods of the namespace use
+func (c *LimitRangeClient) Get(ctx context.Context, name string) (*api.LimitRange, error) {
+	limitRange := &api.LimitRange{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(), name), nil, nil, limitRange); err != nil {
+		return nil, err
+	}
+	return limitRange, nil
+}
+
+func (c *LimitRangeClient) List(ctx context.Context) ([]*api.LimitRange, error) {
+	var limitRanges []*api.LimitRange
+	if err := c.client.do(ctx, http.MethodGet, c.path(), nil, nil, &limitRanges); err != nil {
+		return nil, err
+	}
+	return limitRanges, nil
+}
+
+func (c *LimitRangeClient) Delete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath(c.path(), name), nil, nil, nil)
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// NamespaceClient reads and writes namespaces
+type NamespaceClient struct {
+	client *Client
+}
+
+func (c *NamespaceClient) Create(ctx context.Context, namespace *api.Namespace) (*api.Namespace, error) {
+	created := &api.Namespace{}
+	if err := c.client.do(ctx, http.MethodPost, "/namespaces", nil, namespace, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *NamespaceClient) Get(ctx context.Context, name string) (*api.Namespace, error) {
+	namespace := &api.Namespace{}
+	if err := c.client.do(ctx, http.MethodGet, namePath("/namespaces", name), nil, nil, namespace); err != nil {
+		return nil, err
+	}
+	return namespace, nil
+}
+
+func (c *NamespaceClient) List(ctx context.Context) ([]*api.Namespace, error) {
+	var namespaces []*api.Namespace
+	if err := c.client.do(ctx, http.MethodGet, "/namespaces", nil, nil, &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// Delete marks the namespace as terminating and returns it. The namespace is removed once its pods and
+// ReplicaSets are deleted.
+func (c *NamespaceClient) Delete(ctx context.Context, name string) (*api.Namespace, error) {
+	namespace := &api.Namespace{}
+	if err := c.client.do(ctx, http.MethodDelete, namePath("/namespaces", name), nil, nil, namespace); err != nil {
+		return nil, err
+	}
+	return namespace, nil
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// NodeClient reads and writes nodes
+type NodeClient struct {
+	client *Client
+}
+
+func (c *NodeClient) Create(ctx context.Context, node *api.Node) (*api.Node, error) {
+	created := &api.Node{}
+	if err := c.client.do(ctx, http.MethodPost, "/nodes", nil, node, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *NodeClient) Get(ctx context.Context, name string) (*api.Node, error) {
+	node := &api.Node{}
+	if err := c.client.do(ctx, http.MethodGet, namePath("/nodes", name), nil, nil, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (c *NodeClient) List(ctx context.Context) ([]*api.Node, error) {
+	var nodes []*api.Node
+	if err := c.client.do(ctx, http.MethodGet, "/nodes", nil, nil, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// ListWithResourceVersion lists the nodes along with the resource version they were read at, for Watch to
+// follow their changes from without missing any. The resource version is empty if the API server does not tell
+// it.
+func (c *NodeClient) ListWithResourceVersion(ctx context.Context) ([]*api.Node, string, error) {
+	var nodes []*api.Node
+	header, err := c.client.doWithHeader(ctx, http.MethodGet, "/nodes", nil, nil, &nodes)
+	if err != nil {
+		return nil, "", err
+	}
+	return nodes, header.Get(api.ResourceVersionHeader), nil
+}
+
+// GetNamed gets the named nodes in a single request. The names of the nodes that do not exist are returned
+// as missing rather than as an error.
+func (c *NodeClient) GetNamed(ctx context.Context, names []string) (map[string]*api.Node, []string, error) {
+	var nodes []*api.Node
+	if err := c.client.do(ctx, http.MethodGet, "/nodes", namesQuery(names), nil, &nodes); err != nil {
+		return nil, nil, err
+	}
+	found, missing := byName(names, nodes, func(node *api.Node) string { return node.Name })
+	return found, missing, nil
+}
+
+// Update replaces the node
+func (c *NodeClient) Update(ctx context.Context, node *api.Node) (*api.Node, error) {
+	updated := &api.Node{}
+	if err := c.client.do(ctx, http.MethodPut, namePath("/nodes", node.Name), nil, node, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// UpdateStatus writes the status of the node, such as its heartbeat and capacity, through its status subresource
+func (c *NodeClient) UpdateStatus(ctx context.Context, node *api.Node) (*api.Node, error) {
+	updated := &api.Node{}
+	if err := c.client.do(ctx, http.MethodPut, namePath("/nodes", node.Name, "status"), nil, node, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (c *NodeClient) Delete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath("/nodes", name), nil, nil, nil)
+}
+
+// Watch opens a watch on the nodes from the change after resourceVersion, or from now if it is empty. It returns
+// an error wrapping ErrWatchNotSupported if the API server cannot watch.
+func (c *NodeClient) Watch(ctx context.Context, resourceVersion string) (*NodeWatch, error) {
+	stream, err := c.client.watch(ctx, "/nodes", watchQuery(resourceVersion))
+	if err != nil {
+		return nil, err
+	}
+	return &NodeWatch{stream}, nil
+}
+
+// NodeWatch is an open node watch stream
+type NodeWatch struct {
+	*watchStream
+}
+
+// Next blocks until the next event arrives. It returns an error wrapping ErrWatchClosed once the API server
+// ended the stream.
+func (w *NodeWatch) Next() (api.NodeWatchEvent, error) {
+	return nextEvent(w.watchStream, func(event *api.NodeWatchEvent) bool { return event.Object != nil })
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"gokube/pkg/api"
+)
+
+// PodListOptions narrow down the pods that are listed or watched
+type PodListOptions struct {
+	// NodeName only selects the pods bound to the node
+	NodeName string
+	// LabelSelector only selects the pods with all of these labels
+	LabelSelector map[string]string
+	// ResourceVersion resumes a watch after the change of the given resource version, so a watch that
+	// dropped is reopened without missing or repeating events
+	ResourceVersion string
+}
+
+func (o PodListOptions) query() url.Values {
+	query := url.Values{}
+	if o.NodeName != "" {
+		query.Set("nodeName", o.NodeName)
+	}
+	if len(o.LabelSelector) > 0 {
+		query.Set("labelSelector", api.FormatSelector(o.LabelSelector))
+	}
+	if o.ResourceVersion != "" {
+		query.Set("resourceVersion", o.ResourceVersion)
+	}
+	return query
+}
+
+// PodClient reads and writes the pods of a namespace. Without a namespace, it addresses pods by name in the
+// default namespace, writes pods in their own namespace and lists those of all namespaces.
+type PodClient struct {
+	client    *Client
+	namespace string
+}
+
+// path is the collection of the pods of the client's namespace, or of namespace for a client without one.
+// Without either it is /pods.
+func (c *PodClient) path(namespace string) string {
+	if c.namespace != "" {
+		namespace = c.namespace
+	}
+	if namespace == "" {
+		return "/pods"
+	}
+	return namePath("/namespaces", namespace, "pods")
+}
+
+// Create creates the pod and returns it as stored, e.g. with its UID
+func (c *PodClient) Create(ctx context.Context, pod *api.Pod) (*api.Pod, error) {
+	created := &api.Pod{}
+	if err := c.client.do(ctx, http.MethodPost, c.path(pod.Namespace), nil, pod, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *PodClient) Get(ctx context.Context, name string) (*api.Pod, error) {
+	pod := &api.Pod{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(""), name), nil, nil, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+func (c *PodClient) List(ctx context.Context, options PodListOptions) ([]*api.Pod, error) {
+	var pods []*api.Pod
+	if err := c.client.do(ctx, http.MethodGet, c.path(""), options.query(), nil, &pods); err != nil {
+		return nil, err
+	}
+	return pods, nil
+}
+
+// ListWithResourceVersion lists the pods along with the resource version they were read at, for Watch to follow
+// their changes from without missing any. The resource version is empty if the API server does not tell it.
+func (c *PodClient) ListWithResourceVersion(ctx context.Context) ([]*api.Pod, string, error) {
+	var pods []*api.Pod
+	header, err := c.client.doWithHeader(ctx, http.MethodGet, c.path(""), nil, nil, &pods)
+	if err != nil {
+		return nil, "", err
+	}
+	return pods, header.Get(api.ResourceVersionHeader), nil
+}
+
+// GetNamed gets the named pods in a single request. The names of the pods that do not exist are returned as
+// missing rather than as an error.
+func (c *PodClient) GetNamed(ctx context.Context, names []string) (map[string]*api.Pod, []string, error) {
+	var pods []*api.Pod
+	if err := c.client.do(ctx, http.MethodGet, c.path(""), namesQuery(names), nil, &pods); err != nil {
+		return nil, nil, err
+	}
+	found, missing := byName(names, pods, func(pod *api.Pod) string { return pod.Name })
+	return found, missing, nil
+}
+
+// CountByStatus counts the pods of each status, or of the pods bound to options.NodeName, without
+// transferring the pods. Statuses without pods are left out.
+func (c *PodClient) CountByStatus(ctx context.Context, options PodListOptions) (map[api.PodStatus]int, error) {
+	query := options.query()
+	query.Set("countOnly", "true")
+	counts := map[api.PodStatus]int{}
+	if err := c.client.do(ctx, http.MethodGet, c.path(""), query, nil, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// ListUnassigned lists the pods that are not bound to a node yet
+func (c *PodClient) ListUnassigned(ctx context.Context) ([]*api.Pod, error) {
+	var pods []*api.Pod
+	if err := c.client.do(ctx, http.MethodGet, "/pods/unassigned", nil, nil, &pods); err != nil {
+		return nil, err
+	}
+	return pods, nil
+}
+
+// Update replaces the pod
+func (c *PodClient) Update(ctx context.Context, pod *api.Pod) (*api.Pod, error) {
+	updated := &api.Pod{}
+	if err := c.client.do(ctx, http.MethodPut, namePath(c.path(pod.Namespace), pod.Name), nil, pod, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// UpdateStatus writes the status of a pod through its status subresource, leaving its spec untouched. It
+// fails with a conflict if the pod does not match the UID and node name of the update.
+func (c *PodClient) UpdateStatus(ctx context.Context, update *api.PodStatusUpdate) (*api.Pod, error) {
+	updated := &api.Pod{}
+	if err := c.client.do(ctx, http.MethodPut, namePath(c.path(update.Namespace), update.Name, "status"), nil, update, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete deletes the pod. A pod bound to a node is kept as Terminating until its kubelet has stopped its
+// containers.
+func (c *PodClient) Delete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath(c.path(""), name), nil, nil, nil)
+}
+
+// ForceDelete removes the pod at once, without waiting for its finalizers, e.g. for a pod whose node is gone
+func (c *PodClient) ForceDelete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath(c.path(""), name), url.Values{"force": {"true"}}, nil, nil)
+}
+
+// Evict removes the pod from its node through its eviction subresource
+func (c *PodClient) Evict(ctx context.Context, name string, eviction *api.Eviction) error {
+	return c.client.do(ctx, http.MethodPost, namePath(c.path(""), name, "eviction"), nil, eviction, nil)
+}
+
+// Watch opens a watch on the pods selected by options. It returns an error wrapping ErrWatchNotSupported if
+// the API server cannot watch, in which case callers fall back to listing.
+func (c *PodClient) Watch(ctx context.Context, options PodListOptions) (*PodWatch, error) {
+	stream, err := c.client.watch(ctx, c.path(""), options.query())
+	if err != nil {
+		return nil, err
+	}
+	return &PodWatch{stream}, nil
+}
+
+// PodWatch is an open pod watch stream
+type PodWatch struct {
+	*watchStream
+}
+
+// Next blocks until the next event arrives. It returns an error wrapping ErrWatchClosed once the API server
+// ended the stream.
+func (w *PodWatch) Next() (api.PodWatchEvent, error) {
+	return nextEvent(w.watchStream, func(event *api.PodWatchEvent) bool { return event.Object != nil })
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// ReplicaSetClient reads and writes the ReplicaSets of a namespace. Without a namespace, it addresses
+// ReplicaSets by name in the default namespace, writes ReplicaSets in their own namespace and lists those of
+// all namespaces.
+type ReplicaSetClient struct {
+	client    *Client
+	namespace string
+}
+
+// path is the collection of the ReplicaSets of the client's namespace, or of namespace for a client without
+// one. Without either it is /replicasets.
+func (c *ReplicaSetClient) path(namespace string) string {
+	if c.namespace != "" {
+		namespace = c.namespace
+	}
+	if namespace == "" {
+		return "/replicasets"
+	}
+	return namePath("/namespaces", namespace, "replicasets")
+}
+
+func (c *ReplicaSetClient) Create(ctx context.Context, rs *api.ReplicaSet) (*api.ReplicaSet, error) {
+	created := &api.ReplicaSet{}
+	if err := c.client.do(ctx, http.MethodPost, c.path(rs.Namespace), nil, rs, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *ReplicaSetClient) Get(ctx context.Context, name string) (*api.ReplicaSet, error) {
+	rs := &api.ReplicaSet{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(""), name), nil, nil, rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (c *ReplicaSetClient) List(ctx context.Context) ([]*api.ReplicaSet, error) {
+	var replicaSets []*api.ReplicaSet
+	if err := c.client.do(ctx, http.MethodGet, c.path(""), nil, nil, &replicaSets); err != nil {
+		return nil, err
+	}
+	return replicaSets, nil
+}
+
+// ListWithResourceVersion lists the ReplicaSets along with the resource version they were read at, for Watch to
+// follow their changes from without missing any. The resource version is empty if the API server does not tell
+// it.
+func (c *ReplicaSetClient) ListWithResourceVersion(ctx context.Context) ([]*api.ReplicaSet, string, error) {
+	var replicaSets []*api.ReplicaSet
+	header, err := c.client.doWithHeader(ctx, http.MethodGet, c.path(""), nil, nil, &replicaSets)
+	if err != nil {
+		return nil, "", err
+	}
+	return replicaSets, header.Get(api.ResourceVersionHeader), nil
+}
+
+// Update replaces the ReplicaSet
+func (c *ReplicaSetClient) Update(ctx context.Context, rs *api.ReplicaSet) (*api.ReplicaSet, error) {
+	updated := &api.ReplicaSet{}
+	if err := c.client.do(ctx, http.MethodPut, namePath(c.path(rs.Namespace), rs.Name), nil, rs, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (c *ReplicaSetClient) Delete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath(c.path(""), name), nil, nil, nil)
+}
+
+// GetScale reads the scale subresource of the named ReplicaSet
+func (c *ReplicaSetClient) GetScale(ctx context.Context, name string) (*api.Scale, error) {
+	scale := &api.Scale{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(""), name, "scale"), nil, nil, scale); err != nil {
+		return nil, err
+	}
+	return scale, nil
+}
+
+// UpdateScale sets the replica count of the ReplicaSet the scale names. The API server answers a conflict if
+// the scale has a UID and the ReplicaSet was recreated in the meantime.
+func (c *ReplicaSetClient) UpdateScale(ctx context.Context, scale *api.Scale) (*api.Scale, error) {
+	updated := &api.Scale{}
+	if err := c.client.do(ctx, http.MethodPut, namePath(c.path(scale.Namespace), scale.Name, "scale"), nil, scale, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Watch opens a watch on the ReplicaSets from the change after resourceVersion, or from now if it is empty. It
+// returns an error wrapping ErrWatchNotSupported if the API server cannot watch.
+func (c *ReplicaSetClient) Watch(ctx context.Context, resourceVersion string) (*ReplicaSetWatch, error) {
+	stream, err := c.client.watch(ctx, c.path(""), watchQuery(resourceVersion))
+	if err != nil {
+		return nil, err
+	}
+	return &ReplicaSetWatch{stream}, nil
+}
+
+// ReplicaSetWatch is an open ReplicaSet watch stream
+type ReplicaSetWatch struct {
+	*watchStream
+}
+
+// Next blocks until the next event arrives. It returns an error wrapping ErrWatchClosed once the API server
+// ended the stream.
+func (w *ReplicaSetWatch) Next() (api.ReplicaSetWatchEvent, error) {
+	return nextEvent(w.watchStream, func(event *api.ReplicaSetWatchEvent) bool { return event.Object != nil })
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// ResourceQuotaClient reads and writes the ResourceQuotas of a namespace
+type ResourceQuotaClient struct {
+	client    *Client
+	namespace string
+}
+
+func (c *ResourceQuotaClient) path() string {
+	return namePath("/namespaces", c.namespace, "resourcequotas")
+}
+
+func (c *ResourceQuotaClient) Create(ctx context.Context, quota *api.ResourceQuota) (*api.ResourceQuota, error) {
+	created := &api.ResourceQuota{}
+	if err := c.client.do(ctx, http.MethodPost, c.path(), nil, quota, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Get reads the ResourceQuota with what the pods of the namespace use
+func (c *ResourceQuotaClient) Get(ctx context.Context, name string) (*api.ResourceQuota, error) {
+	quota := &api.ResourceQuota{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(), name), nil, nil, quota); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+func (c *ResourceQuotaClient) List(ctx context.Context) ([]*api.ResourceQuota, error) {
+	var quotas []*api.ResourceQuota
+	if err := c.client.do(ctx, http.MethodGet, c.path(), nil, nil, &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+func (c *ResourceQuotaClient) Delete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath(c.path(), name), nil, nil, nil)
+}
@@ -0,0 +1,43 @@
+package client
+
+import "sync"
+
+// maxCachedResponses bounds how many answers a client keeps for conditional GETs
+const maxCachedResponses = 256
+
+// cachedResponse is the body of an answer to a GET, with the ETag the API server tagged it with
+type cachedResponse struct {
+	etag string
+	body []byte
+}
+
+// responseCache keeps the last tagged answer of each GET, by path and query. Once full, an arbitrary answer
+// makes room for a new one; pollers read the same few paths, so the cache rarely fills.
+type responseCache struct {
+	mutex     sync.Mutex
+	responses map[string]cachedResponse
+	size      int
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{responses: make(map[string]cachedResponse), size: size}
+}
+
+func (c *responseCache) get(key string) (cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	response, ok := c.responses[key]
+	return response, ok
+}
+
+func (c *responseCache) put(key string, response cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.responses[key]; !ok && len(c.responses) >= c.size {
+		for evicted := range c.responses {
+			delete(c.responses, evicted)
+			break
+		}
+	}
+	c.responses[key] = response
+}
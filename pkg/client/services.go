@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"gokube/pkg/api"
+)
+
+// ServiceClient reads and writes the Services of a namespace, or lists those of all namespaces
+type ServiceClient struct {
+	client    *Client
+	namespace string
+}
+
+func (c *ServiceClient) path() string {
+	if c.namespace == "" {
+		return "/services"
+	}
+	return namePath("/namespaces", c.namespace, "services")
+}
+
+func (c *ServiceClient) Create(ctx context.Context, service *api.Service) (*api.Service, error) {
+	created := &api.Service{}
+	if err := c.client.do(ctx, http.MethodPost, c.path(), nil, service, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *ServiceClient) Get(ctx context.Context, name string) (*api.Service, error) {
+	service := &api.Service{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(), name), nil, nil, service); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func (c *ServiceClient) List(ctx context.Context) ([]*api.Service, error) {
+	var services []*api.Service
+	if err := c.client.do(ctx, http.MethodGet, c.path(), nil, nil, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (c *ServiceClient) Delete(ctx context.Context, name string) error {
+	return c.client.do(ctx, http.MethodDelete, namePath(c.path(), name), nil, nil, nil)
+}
+
+// EndpointsClient reads the Endpoints of the Services of a namespace, or lists those of all namespaces
+type EndpointsClient struct {
+	client    *Client
+	namespace string
+}
+
+func (c *EndpointsClient) path() string {
+	if c.namespace == "" {
+		return "/endpoints"
+	}
+	return namePath("/namespaces", c.namespace, "endpoints")
+}
+
+func (c *EndpointsClient) Get(ctx context.Context, name string) (*api.Endpoints, error) {
+	endpoints := &api.Endpoints{}
+	if err := c.client.do(ctx, http.MethodGet, namePath(c.path(), name), nil, nil, endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (c *EndpointsClient) List(ctx context.Context) ([]*api.Endpoints, error) {
+	var endpoints []*api.Endpoints
+	if err := c.client.do(ctx, http.MethodGet, c.path(), nil, nil, &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
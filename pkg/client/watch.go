@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gokube/pkg/api"
+)
+
+var (
+	// ErrWatchNotSupported is returned when the API server answers a watch with anything but a watch stream
+	ErrWatchNotSupported = errors.New("API server does not support watching")
+	// ErrWatchClosed is returned once the API server ended a watch stream
+	ErrWatchClosed = errors.New("watch closed by API server")
+)
+
+// watchStream is an open watch stream, whose events are decoded by nextEvent
+type watchStream struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+}
+
+// watch opens a watch on the collection at path, with the list parameters of query. It returns an error wrapping
+// ErrWatchNotSupported if the API server answers with anything but a watch stream.
+func (c *Client) watch(ctx context.Context, path string, query url.Values) (*watchStream, error) {
+	query.Set("watch", "true")
+	// A watch lasts as long as the caller reads it, so no timeout applies
+	resp, err := c.send(ctx, 0, http.MethodGet, path, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(contentType, api.WatchContentType) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: status code %d, content type %q", ErrWatchNotSupported, resp.StatusCode, contentType)
+	}
+	return &watchStream{body: resp.Body, decoder: json.NewDecoder(resp.Body)}, nil
+}
+
+// nextEvent blocks until the next event of the stream that hasObject arrives; events without an object, such as
+// bookmarks, are skipped. It returns an error wrapping ErrWatchClosed once the API server ended the stream.
+func nextEvent[E any](w *watchStream, hasObject func(*E) bool) (E, error) {
+	for {
+		var event, none E
+		if err := w.decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return none, ErrWatchClosed
+			}
+			return none, fmt.Errorf("failed to decode watch event: %w", err)
+		}
+		if hasObject(&event) {
+			return event, nil
+		}
+	}
+}
+
+// Close ends the watch
+func (w *watchStream) Close() error {
+	return w.body.Close()
+}
+
+// watchQuery returns the query of a watch from resourceVersion, or from now if it is empty
+func watchQuery(resourceVersion string) url.Values {
+	query := url.Values{}
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+	return query
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"sort"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+)
+
+// EndpointsController keeps the Endpoints of every Service up to date with the running pods it selects, and
+// removes the Endpoints of the Services that are gone
+type EndpointsController struct {
+	serviceRegistry   *registry.ServiceRegistry
+	endpointsRegistry *registry.EndpointsRegistry
+	podRegistry       *registry.PodRegistry
+	nodeRegistry      *registry.NodeRegistry
+	resyncPeriod      time.Duration
+	logger            *slog.Logger
+}
+
+// NewEndpointsController creates a new EndpointsController that recomputes the Endpoints every resyncPeriod
+func NewEndpointsController(serviceRegistry *registry.ServiceRegistry, endpointsRegistry *registry.EndpointsRegistry,
+	podRegistry *registry.PodRegistry, nodeRegistry *registry.NodeRegistry, resyncPeriod time.Duration) *EndpointsController {
+	return &EndpointsController{
+		serviceRegistry:   serviceRegistry,
+		endpointsRegistry: endpointsRegistry,
+		podRegistry:       podRegistry,
+		nodeRegistry:      nodeRegistry,
+		resyncPeriod:      resyncPeriod,
+		logger:            logging.Component("endpoints-controller"),
+	}
+}
+
+// SetLogger makes the controller log to logger instead of the default logger
+func (ec *EndpointsController) SetLogger(logger *slog.Logger) {
+	ec.logger = logging.WithComponent(logger, "endpoints-controller")
+}
+
+func (ec *EndpointsController) Start(ctx context.Context) {
+	ticker := time.NewTicker(ec.resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// The failures were logged by Run
+		_ = ec.Run(ctx)
+	}
+}
+
+// Run updates the Endpoints of every Service once
+func (ec *EndpointsController) Run(ctx context.Context) error {
+	ctx = logging.WithOperationID(ctx, logging.NewRequestID())
+	services, err := ec.serviceRegistry.List(ctx, "")
+	if err != nil {
+		ec.logger.ErrorContext(ctx, "Failed to list services", logging.Err(err))
+		return err
+	}
+	pods, err := ec.podRegistry.ListPods(ctx, "")
+	if err != nil {
+		ec.logger.ErrorContext(ctx, "Failed to list pods", logging.Err(err))
+		return err
+	}
+	nodes, err := ec.nodeRegistry.ListNodes(ctx)
+	if err != nil {
+		ec.logger.ErrorContext(ctx, "Failed to list nodes", logging.Err(err))
+		return err
+	}
+	existing, err := ec.endpointsRegistry.List(ctx, "")
+	if err != nil {
+		ec.logger.ErrorContext(ctx, "Failed to list endpoints", logging.Err(err))
+		return err
+	}
+
+	addresses := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		addresses[node.Name] = node.Address
+	}
+	current := make(map[string]*api.Endpoints, len(existing))
+	for _, endpoints := range existing {
+		current[endpoints.Namespace+"/"+endpoints.Name] = endpoints
+	}
+
+	var errs []error
+	for _, service := range services {
+		key := service.Namespace + "/" + service.Name
+		endpoints := ComputeEndpoints(service, pods, addresses)
+		old, found := current[key]
+		delete(current, key)
+		if found && reflect.DeepEqual(old.Ports, endpoints.Ports) {
+			continue
+		}
+		if err := ec.endpointsRegistry.Set(ctx, endpoints); err != nil {
+			ec.logger.ErrorContext(ctx, "Failed to update endpoints", "service", key, logging.Err(err))
+			errs = append(errs, err)
+			continue
+		}
+		ec.logger.InfoContext(ctx, "Updated endpoints", "service", key, "addresses", countAddresses(endpoints))
+	}
+	// What is left belongs to Services that were deleted
+	for key, endpoints := range current {
+		if err := registry.IgnoreNotFound(ec.endpointsRegistry.Delete(ctx, endpoints.Namespace, endpoints.Name)); err != nil {
+			ec.logger.ErrorContext(ctx, "Failed to delete endpoints", "service", key, logging.Err(err))
+			errs = append(errs, err)
+			continue
+		}
+		ec.logger.InfoContext(ctx, "Deleted endpoints", "service", key)
+	}
+	return errors.Join(errs...)
+}
+
+// ComputeEndpoints works out the Endpoints of a Service: for each of its ports, the running pods it selects
+// that publish the target port on a host port, reached at the address of their node. Pods on nodes without an
+// address are left out.
+func ComputeEndpoints(service *api.Service, pods []*api.Pod, nodeAddresses map[string]string) *api.Endpoints {
+	endpoints := &api.Endpoints{
+		ObjectMeta: api.ObjectMeta{Name: service.Name, Namespace: service.Namespace},
+		Ports:      make([]api.EndpointPort, 0, len(service.Spec.Ports)),
+	}
+	for _, servicePort := range service.Spec.Ports {
+		port := api.EndpointPort{Name: servicePort.Name, Port: servicePort.Port, Addresses: []api.EndpointAddress{}}
+		for _, pod := range pods {
+			if api.NamespaceOf(&pod.ObjectMeta) != service.Namespace || pod.Status != api.PodRunning ||
+				!api.SelectorMatches(service.Spec.Selector, pod.Labels) {
+				continue
+			}
+			address := nodeAddresses[pod.NodeName]
+			hostPort := findHostPort(pod, servicePort.GetTargetPort())
+			if address == "" || hostPort == 0 {
+				continue
+			}
+			port.Addresses = append(port.Addresses, api.EndpointAddress{
+				IP:       address,
+				Port:     hostPort,
+				NodeName: pod.NodeName,
+				PodName:  pod.Name,
+			})
+		}
+		sort.Slice(port.Addresses, func(i, j int) bool { return port.Addresses[i].PodName < port.Addresses[j].PodName })
+		endpoints.Ports = append(endpoints.Ports, port)
+	}
+	return endpoints
+}
+
+// findHostPort returns the host port a container of the pod publishes containerPort on, or zero
+func findHostPort(pod *api.Pod, containerPort int32) int32 {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.ContainerPort == containerPort && port.HostPort != 0 {
+				return port.HostPort
+			}
+		}
+	}
+	return 0
+}
+
+// countAddresses counts the backends of all ports of the Endpoints
+func countAddresses(endpoints *api.Endpoints) int {
+	count := 0
+	for _, port := range endpoints.Ports {
+		count += len(port.Addresses)
+	}
+	return count
+}
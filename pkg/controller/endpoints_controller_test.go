@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func newBackendPod(name, nodeName string, status api.PodStatus, hostPort int32) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: api.DefaultNamespace, Labels: map[string]string{"app": "web"}},
+		Spec: api.PodSpec{Containers: []api.Container{{
+			Name:  "web",
+			Image: "nginx",
+			Ports: []api.ContainerPort{{ContainerPort: 8080, HostPort: hostPort}},
+		}}},
+		NodeName: nodeName,
+		Status:   status,
+	}
+}
+
+func TestComputeEndpoints(t *testing.T) {
+	service := &api.Service{
+		ObjectMeta: api.ObjectMeta{Name: "web", Namespace: api.DefaultNamespace},
+		Spec: api.ServiceSpec{
+			Selector: map[string]string{"app": "web"},
+			Ports:    []api.ServicePort{{Name: "http", Port: 80, TargetPort: 8080}, {Name: "metrics", Port: 9090}},
+		},
+	}
+	other := newBackendPod("other", "node-0", api.PodRunning, 30082)
+	other.Labels = map[string]string{"app": "api"}
+	pods := []*api.Pod{
+		newBackendPod("web-1", "node-1", api.PodRunning, 30081),
+		newBackendPod("web-0", "node-0", api.PodRunning, 30080),
+		newBackendPod("pending", "node-0", api.PodScheduled, 30083),
+		newBackendPod("unpublished", "node-0", api.PodRunning, 0),
+		newBackendPod("lost", "node-9", api.PodRunning, 30084),
+		other,
+	}
+	addresses := map[string]string{"node-0": "10.0.0.1", "node-1": "10.0.0.2"}
+
+	assert.Equal(t, &api.Endpoints{
+		ObjectMeta: api.ObjectMeta{Name: "web", Namespace: api.DefaultNamespace},
+		Ports: []api.EndpointPort{
+			{Name: "http", Port: 80, Addresses: []api.EndpointAddress{
+				{IP: "10.0.0.1", Port: 30080, NodeName: "node-0", PodName: "web-0"},
+				{IP: "10.0.0.2", Port: 30081, NodeName: "node-1", PodName: "web-1"},
+			}},
+			// No container publishes the port
+			{Name: "metrics", Port: 9090, Addresses: []api.EndpointAddress{}},
+		},
+	}, ComputeEndpoints(service, pods, addresses))
+}
+
+func TestEndpointsController_Run(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		serviceRegistry := registry.NewServiceRegistry(store)
+		endpointsRegistry := registry.NewEndpointsRegistry(store)
+		podRegistry := registry.NewPodRegistry(store)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		ec := NewEndpointsController(serviceRegistry, endpointsRegistry, podRegistry, nodeRegistry, time.Hour)
+		ctx := context.Background()
+
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-0"}, Address: "10.0.0.1"}))
+		require.NoError(t, podRegistry.CreatePod(ctx, newBackendPod("web-0", "node-0", api.PodScheduled, 30080)))
+		require.NoError(t, serviceRegistry.Create(ctx, &api.Service{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec:       api.ServiceSpec{Selector: map[string]string{"app": "web"}, Ports: []api.ServicePort{{Port: 80, TargetPort: 8080}}},
+		}))
+		pod, err := podRegistry.GetPod(ctx, api.DefaultNamespace, "web-0")
+		require.NoError(t, err)
+		pod.Status = api.PodRunning
+		require.NoError(t, podRegistry.UpdatePod(ctx, pod))
+
+		require.NoError(t, ec.Run(ctx))
+		endpoints, err := endpointsRegistry.Get(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, []api.EndpointAddress{{IP: "10.0.0.1", Port: 30080, NodeName: "node-0", PodName: "web-0"}}, endpoints.Ports[0].Addresses)
+
+		require.NoError(t, podRegistry.DeletePod(ctx, api.DefaultNamespace, "web-0"))
+		require.NoError(t, ec.Run(ctx))
+		endpoints, err = endpointsRegistry.Get(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Empty(t, endpoints.Ports[0].Addresses, "the deleted pod is no longer an endpoint")
+
+		require.NoError(t, serviceRegistry.Delete(ctx, api.DefaultNamespace, "web"))
+		require.NoError(t, ec.Run(ctx))
+		_, err = endpointsRegistry.Get(ctx, api.DefaultNamespace, "web")
+		assert.ErrorIs(t, err, registry.ErrEndpointsNotFound, "the endpoints go with their service")
+	})
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+)
+
+const (
+	// DefaultEventTTL is how long events are kept after they last happened when no TTL is configured
+	DefaultEventTTL = time.Hour
+	// DefaultEventGCPeriod is how often expired events are looked for
+	DefaultEventGCPeriod = time.Minute
+)
+
+// EventGCController deletes the events that last happened longer than the TTL ago, so events do not pile up
+// in etcd
+type EventGCController struct {
+	eventRegistry *registry.EventRegistry
+	ttl           time.Duration
+	period        time.Duration
+	logger        *slog.Logger
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// NewEventGCController creates a new EventGCController that deletes the events older than ttl every period
+func NewEventGCController(eventRegistry *registry.EventRegistry, ttl, period time.Duration) *EventGCController {
+	return &EventGCController{
+		eventRegistry: eventRegistry,
+		ttl:           ttl,
+		period:        period,
+		logger:        logging.Component("event-gc-controller"),
+		now:           time.Now,
+	}
+}
+
+// SetLogger makes the controller log to logger instead of the default logger
+func (gc *EventGCController) SetLogger(logger *slog.Logger) {
+	gc.logger = logging.WithComponent(logger, "event-gc-controller")
+}
+
+func (gc *EventGCController) Start(ctx context.Context) {
+	ticker := time.NewTicker(gc.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// The failures were logged by Run
+		_ = gc.Run(ctx)
+	}
+}
+
+// Run deletes the expired events once
+func (gc *EventGCController) Run(ctx context.Context) error {
+	ctx = logging.WithOperationID(ctx, logging.NewRequestID())
+	deleted, err := gc.eventRegistry.DeleteExpired(ctx, gc.now().Add(-gc.ttl))
+	if deleted > 0 {
+		gc.logger.InfoContext(ctx, "Deleted expired events", "events", deleted, "ttl", gc.ttl)
+	}
+	if err != nil {
+		gc.logger.ErrorContext(ctx, "Failed to delete expired events", logging.Err(err))
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestEventGCController_DeletesExpiredEvents(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		eventRegistry := registry.NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+		gc := NewEventGCController(eventRegistry, time.Hour, time.Minute)
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		gc.now = func() time.Time { return now }
+		ctx := context.Background()
+
+		// The repeat keeps the event alive, as the TTL counts from the last time it happened
+		for _, at := range []time.Time{now.Add(-3 * time.Hour), now.Add(-10 * time.Minute)} {
+			require.NoError(t, eventRegistry.CreateEvent(ctx, &api.Event{
+				InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web"},
+				Reason:         "BackOff",
+				FirstTimestamp: at,
+			}))
+		}
+		require.NoError(t, eventRegistry.CreateEvent(ctx, &api.Event{
+			InvolvedObject: api.ObjectReference{Kind: api.KindNode, Name: "node-1"},
+			Reason:         "NodeReady",
+			FirstTimestamp: now.Add(-2 * time.Hour),
+		}))
+
+		require.NoError(t, gc.Run(ctx))
+		events, err := eventRegistry.ListEvents(ctx, "", "")
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "web", events[0].InvolvedObject.Name)
+		assert.Equal(t, int32(2), events[0].Count)
+
+		// Once the repeat is older than the TTL too, the event expires
+		now = now.Add(time.Hour)
+		require.NoError(t, gc.Run(ctx))
+		events, err = eventRegistry.ListEvents(ctx, "", "")
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// ExpectationsTimeout is how long unobserved creations/deletions block further action for a ReplicaSet
+const ExpectationsTimeout = 5 * time.Minute
+
+// ControllerExpectations records the pod creations and deletions a controller has issued but not yet
+// observed in a pod listing. While expectations for a key are outstanding the controller must not act
+// on that key again, otherwise a stale listing makes it create (or delete) the same pods twice.
+type ControllerExpectations struct {
+	mutex        sync.Mutex
+	expectations map[string]*expectation
+	timeout      time.Duration
+	now          func() time.Time
+}
+
+type expectation struct {
+	creations map[string]struct{}
+	deletions map[string]struct{}
+	timestamp time.Time
+}
+
+// NewControllerExpectations creates an empty expectations store
+func NewControllerExpectations() *ControllerExpectations {
+	return &ControllerExpectations{
+		expectations: make(map[string]*expectation),
+		timeout:      ExpectationsTimeout,
+		now:          time.Now,
+	}
+}
+
+// ExpectCreations records that pods with the given names were created for key
+func (e *ControllerExpectations) ExpectCreations(key string, podNames []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	exp := e.getOrCreate(key)
+	for _, name := range podNames {
+		exp.creations[name] = struct{}{}
+	}
+}
+
+// ExpectDeletions records that pods with the given names were deleted for key
+func (e *ControllerExpectations) ExpectDeletions(key string, podNames []string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	exp := e.getOrCreate(key)
+	for _, name := range podNames {
+		exp.deletions[name] = struct{}{}
+	}
+}
+
+// ObservePods clears the expectations for key that are confirmed by the given pod listing:
+// expected creations that are present and expected deletions that are absent or being deleted, as a
+// pod deleted gracefully is kept until its kubelet has stopped it.
+func (e *ControllerExpectations) ObservePods(key string, pods []*api.Pod) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	exp, ok := e.expectations[key]
+	if !ok {
+		return
+	}
+
+	present := make(map[string]*api.Pod, len(pods))
+	for _, pod := range pods {
+		present[pod.Name] = pod
+	}
+
+	for name := range exp.creations {
+		if _, ok := present[name]; ok {
+			delete(exp.creations, name)
+		}
+	}
+	for name := range exp.deletions {
+		if pod, ok := present[name]; !ok || pod.IsDeleting() {
+			delete(exp.deletions, name)
+		}
+	}
+
+	if exp.fulfilled() {
+		delete(e.expectations, key)
+	}
+}
+
+// SatisfiedExpectations checks if the controller may act on key again: either nothing is
+// outstanding or the outstanding expectations have expired.
+func (e *ControllerExpectations) SatisfiedExpectations(key string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	exp, ok := e.expectations[key]
+	if !ok || exp.fulfilled() {
+		return true
+	}
+	if e.now().Sub(exp.timestamp) > e.timeout {
+		delete(e.expectations, key)
+		return true
+	}
+	return false
+}
+
+// DeleteExpectations forgets everything recorded for key
+func (e *ControllerExpectations) DeleteExpectations(key string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	delete(e.expectations, key)
+}
+
+func (e *ControllerExpectations) getOrCreate(key string) *expectation {
+	exp, ok := e.expectations[key]
+	if !ok {
+		exp = &expectation{
+			creations: make(map[string]struct{}),
+			deletions: make(map[string]struct{}),
+		}
+		e.expectations[key] = exp
+	}
+	exp.timestamp = e.now()
+	return exp
+}
+
+func (exp *expectation) fulfilled() bool {
+	return len(exp.creations) == 0 && len(exp.deletions) == 0
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestControllerExpectations(t *testing.T) {
+	pod := func(name string) *api.Pod { return &api.Pod{ObjectMeta: api.ObjectMeta{Name: name}} }
+
+	t.Run("should be satisfied when nothing is expected", func(t *testing.T) {
+		e := NewControllerExpectations()
+		assert.True(t, e.SatisfiedExpectations("rs"))
+	})
+
+	t.Run("should wait until expected creations are observed", func(t *testing.T) {
+		e := NewControllerExpectations()
+		e.ExpectCreations("rs", []string{"a", "b"})
+		assert.False(t, e.SatisfiedExpectations("rs"))
+
+		e.ObservePods("rs", []*api.Pod{pod("a")})
+		assert.False(t, e.SatisfiedExpectations("rs"))
+
+		e.ObservePods("rs", []*api.Pod{pod("a"), pod("b")})
+		assert.True(t, e.SatisfiedExpectations("rs"))
+	})
+
+	t.Run("should wait until expected deletions are observed", func(t *testing.T) {
+		e := NewControllerExpectations()
+		e.ExpectDeletions("rs", []string{"a"})
+
+		e.ObservePods("rs", []*api.Pod{pod("a")})
+		assert.False(t, e.SatisfiedExpectations("rs"))
+
+		e.ObservePods("rs", []*api.Pod{})
+		assert.True(t, e.SatisfiedExpectations("rs"))
+	})
+
+	t.Run("should observe a pod being deleted as deleted", func(t *testing.T) {
+		e := NewControllerExpectations()
+		e.ExpectDeletions("rs", []string{"a"})
+
+		deleting := pod("a")
+		deletionTimestamp := time.Now()
+		deleting.DeletionTimestamp = &deletionTimestamp
+		e.ObservePods("rs", []*api.Pod{deleting})
+		assert.True(t, e.SatisfiedExpectations("rs"), "a pod deleted gracefully is kept until its kubelet stopped it")
+	})
+
+	t.Run("should expire outstanding expectations", func(t *testing.T) {
+		e := NewControllerExpectations()
+		now := time.Now()
+		e.now = func() time.Time { return now }
+		e.ExpectCreations("rs", []string{"a"})
+		assert.False(t, e.SatisfiedExpectations("rs"))
+
+		now = now.Add(ExpectationsTimeout + time.Second)
+		assert.True(t, e.SatisfiedExpectations("rs"))
+	})
+
+	t.Run("should keep keys independent", func(t *testing.T) {
+		e := NewControllerExpectations()
+		e.ExpectCreations("rs-1", []string{"a"})
+		assert.True(t, e.SatisfiedExpectations("rs-2"))
+
+		e.DeleteExpectations("rs-1")
+		assert.True(t, e.SatisfiedExpectations("rs-1"))
+	})
+}
+
+// staleLister serves pod listings that lag a few syncs behind storage, the way a cache would.
+type staleLister struct {
+	mutex     sync.Mutex
+	list      func(ctx context.Context) ([]*api.Pod, error)
+	snapshots [][]*api.Pod
+	lag       int
+}
+
+func (l *staleLister) ListPods(ctx context.Context) ([]*api.Pod, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	pods, err := l.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.snapshots = append(l.snapshots, pods)
+	if len(l.snapshots) <= l.lag {
+		return l.snapshots[0], nil
+	}
+	return l.snapshots[len(l.snapshots)-1-l.lag], nil
+}
+
+func TestReconcile_StaleListingDoesNotOvershoot(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		ctx := context.Background()
+
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		lister := &staleLister{list: func(ctx context.Context) ([]*api.Pod, error) { return podRegistry.ListPods(ctx, "") }, lag: 3}
+		rsc.listPods = lister.ListPods
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "storm-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 3,
+				Selector: map[string]string{"app": "storm"},
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		require.NoError(t, replicaSetRegistry.Create(ctx, rs))
+
+		for i := 0; i < 20; i++ {
+			require.NoError(t, rsc.Reconcile(ctx, rs))
+
+			pods, err := podRegistry.ListPods(ctx, "")
+			require.NoError(t, err)
+			require.LessOrEqualf(t, len(pods), 3, "pod count overshot desired replicas on sync %d", i)
+		}
+
+		pods, err := podRegistry.ListPods(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, pods, 3)
+	})
+}
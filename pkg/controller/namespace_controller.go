@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+)
+
+// NamespaceController deletes the pods, ReplicaSets, Services, ResourceQuotas and LimitRanges of terminating
+// namespaces and then removes the namespaces
+type NamespaceController struct {
+	namespaceRegistry  *registry.NamespaceRegistry
+	replicaSetRegistry *registry.ReplicaSetRegistry
+	podRegistry        *registry.PodRegistry
+	quotaRegistry      *registry.ResourceQuotaRegistry
+	limitRangeRegistry *registry.LimitRangeRegistry
+	serviceRegistry    *registry.ServiceRegistry
+	resyncPeriod       time.Duration
+	logger             *slog.Logger
+}
+
+// NewNamespaceController creates a new NamespaceController that looks for terminating namespaces every
+// resyncPeriod
+func NewNamespaceController(namespaceRegistry *registry.NamespaceRegistry, rsRegistry *registry.ReplicaSetRegistry,
+	podRegistry *registry.PodRegistry, quotaRegistry *registry.ResourceQuotaRegistry, limitRangeRegistry *registry.LimitRangeRegistry,
+	serviceRegistry *registry.ServiceRegistry, resyncPeriod time.Duration) *NamespaceController {
+	return &NamespaceController{
+		namespaceRegistry:  namespaceRegistry,
+		replicaSetRegistry: rsRegistry,
+		podRegistry:        podRegistry,
+		quotaRegistry:      quotaRegistry,
+		limitRangeRegistry: limitRangeRegistry,
+		serviceRegistry:    serviceRegistry,
+		resyncPeriod:       resyncPeriod,
+		logger:             logging.Component("namespace-controller"),
+	}
+}
+
+// SetLogger makes the controller log to logger instead of the default logger
+func (nc *NamespaceController) SetLogger(logger *slog.Logger) {
+	nc.logger = logging.WithComponent(logger, "namespace-controller")
+}
+
+func (nc *NamespaceController) Start(ctx context.Context) {
+	ticker := time.NewTicker(nc.resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// The namespaces that failed were logged by Run
+		_ = nc.Run(ctx)
+	}
+}
+
+// Run cleans up every terminating namespace once
+func (nc *NamespaceController) Run(ctx context.Context) error {
+	ctx = logging.WithOperationID(ctx, logging.NewRequestID())
+	namespaces, err := nc.namespaceRegistry.List(ctx)
+	if err != nil {
+		nc.logger.ErrorContext(ctx, "Failed to list namespaces", logging.Err(err))
+		return err
+	}
+
+	var errs []error
+	for _, namespace := range namespaces {
+		if !namespace.IsTerminating() {
+			continue
+		}
+		if err := nc.Reconcile(ctx, namespace); err != nil {
+			nc.logger.ErrorContext(ctx, "Failed to clean up namespace", "namespace", namespace.Name, logging.Err(err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reconcile deletes the ReplicaSets of a terminating namespace, then its pods, Services, ResourceQuotas and
+// LimitRanges, and removes the namespace once none are left. The ReplicaSets go first so the ReplicaSet
+// controller does not replace the deleted pods. The pods are deleted gracefully, so a namespace with pods
+// still running on a node is removed on a later pass, once their kubelets have stopped them.
+func (nc *NamespaceController) Reconcile(ctx context.Context, namespace *api.Namespace) error {
+	replicaSets, err := nc.replicaSetRegistry.List(ctx, namespace.Name)
+	if err != nil {
+		return err
+	}
+	deletedReplicaSets := 0
+	for _, rs := range replicaSets {
+		if err := registry.IgnoreNotFound(nc.replicaSetRegistry.Delete(ctx, rs.Namespace, rs.Name)); err != nil {
+			return fmt.Errorf("failed to delete replicaset %s: %w", rs.Name, err)
+		}
+		deletedReplicaSets++
+	}
+
+	pods, err := nc.podRegistry.ListPods(ctx, namespace.Name)
+	if err != nil {
+		return err
+	}
+	deletedPods, stoppingPods := 0, 0
+	for _, pod := range pods {
+		deleting, err := nc.podRegistry.DeletePodGracefully(ctx, pod.Namespace, pod.Name)
+		if err = registry.IgnoreNotFound(err); err != nil {
+			return fmt.Errorf("failed to delete pod %s: %w", pod.Name, err)
+		}
+		if deleting != nil {
+			stoppingPods++
+		}
+		deletedPods++
+	}
+
+	// The endpoints controller removes the Endpoints of the Services
+	if err := nc.serviceRegistry.DeleteNamespace(ctx, namespace.Name); err != nil {
+		return fmt.Errorf("failed to delete services: %w", err)
+	}
+	if err := nc.quotaRegistry.DeleteNamespace(ctx, namespace.Name); err != nil {
+		return fmt.Errorf("failed to delete resource quotas: %w", err)
+	}
+	if err := nc.limitRangeRegistry.DeleteNamespace(ctx, namespace.Name); err != nil {
+		return fmt.Errorf("failed to delete limit ranges: %w", err)
+	}
+
+	if stoppingPods > 0 {
+		nc.logger.InfoContext(ctx, "Waiting for the pods of the namespace to stop", "namespace", namespace.Name, "pods", stoppingPods)
+		return nil
+	}
+	if err := nc.namespaceRegistry.Finalize(ctx, namespace.Name); err != nil {
+		return err
+	}
+	nc.logger.InfoContext(ctx, "Removed namespace", "namespace", namespace.Name, "replicaSets", deletedReplicaSets, "pods", deletedPods)
+	return nil
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/client"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestNamespaceController_DeletesNamespaceContents(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		apiServer := server.NewAPIServer(etcdStorage)
+		ctx := context.Background()
+		if err := apiServer.EnsureDefaultNamespace(ctx); err != nil {
+			t.Fatalf("Failed to create the default namespace: %v", err)
+		}
+		httpServer := httptest.NewServer(apiServer.Handler())
+		defer httpServer.Close()
+		apiClient, err := client.New(httpServer.URL, client.Options{})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		newPod := func(name, namespace string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name, Namespace: namespace},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			}
+		}
+		newReplicaSet := func(name, namespace string) *api.ReplicaSet {
+			return &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: name, Namespace: namespace},
+				Spec:       api.ReplicaSetSpec{Replicas: 1, Template: api.PodTemplateSpec{Spec: newPod("", "").Spec}},
+			}
+		}
+
+		if _, err := apiClient.Namespaces().Create(ctx, &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "team-a"}}); err != nil {
+			t.Fatalf("Failed to create namespace: %v", err)
+		}
+		bound := newPod("bound", "team-a")
+		bound.NodeName = "node-1"
+		for _, pod := range []*api.Pod{newPod("web-1", "team-a"), newPod("web-2", "team-a"), bound, newPod("other", "")} {
+			if _, err := apiClient.Pods().Create(ctx, pod); err != nil {
+				t.Fatalf("Failed to create pod %s: %v", pod.Name, err)
+			}
+		}
+		for _, rs := range []*api.ReplicaSet{newReplicaSet("web", "team-a"), newReplicaSet("web", "")} {
+			if _, err := apiClient.ReplicaSets().Create(ctx, rs); err != nil {
+				t.Fatalf("Failed to create ReplicaSet %s: %v", rs.Name, err)
+			}
+		}
+
+		deleted, err := apiClient.Namespaces().Delete(ctx, "team-a")
+		if err != nil {
+			t.Fatalf("Failed to delete namespace: %v", err)
+		}
+		if deleted.Status.Phase != api.NamespaceTerminating {
+			t.Errorf("Expected the deleted namespace to be Terminating, got %q", deleted.Status.Phase)
+		}
+
+		// Nothing new can be created in the namespace while its contents are deleted
+		_, err = apiClient.Pods().Create(ctx, newPod("web-3", "team-a"))
+		if code := client.StatusCode(err); code != http.StatusForbidden {
+			t.Errorf("Expected creating a pod in a terminating namespace to be forbidden, got %d: %v", code, err)
+		}
+		_, err = apiClient.ReplicaSets().Create(ctx, newReplicaSet("api", "team-a"))
+		if code := client.StatusCode(err); code != http.StatusForbidden {
+			t.Errorf("Expected creating a ReplicaSet in a terminating namespace to be forbidden, got %d: %v", code, err)
+		}
+		_, err = apiClient.Namespaces().Create(ctx, &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "team-a"}})
+		if !client.IsConflict(err) {
+			t.Errorf("Expected recreating a terminating namespace to conflict, got %v", err)
+		}
+
+		nc := NewNamespaceController(registry.NewNamespaceRegistry(etcdStorage), registry.NewReplicaSetRegistry(etcdStorage),
+			registry.NewPodRegistry(etcdStorage), registry.NewResourceQuotaRegistry(etcdStorage),
+			registry.NewLimitRangeRegistry(etcdStorage), registry.NewServiceRegistry(etcdStorage), time.Hour)
+		if err := nc.Run(ctx); err != nil {
+			t.Fatalf("Failed to clean up namespaces: %v", err)
+		}
+
+		// The pod bound to a node is deleted gracefully, so the namespace waits for its kubelet to stop it
+		stopping, err := apiClient.PodsIn("team-a").Get(ctx, "bound")
+		if err != nil {
+			t.Fatalf("Expected the bound pod to be kept until its kubelet stopped it, got %v", err)
+		}
+		if !stopping.IsDeleting() {
+			t.Errorf("Expected the bound pod to be deleting")
+		}
+		if _, err := apiClient.Namespaces().Get(ctx, "team-a"); err != nil {
+			t.Errorf("Expected the namespace to be kept while its pods stop, got %v", err)
+		}
+		_, err = apiClient.PodsIn("team-a").UpdateStatus(ctx, &api.PodStatusUpdate{Name: "bound", Namespace: "team-a",
+			NodeName: "node-1", Status: api.PodTerminating, RemoveFinalizers: []string{api.PodKubeletFinalizer}})
+		if err != nil {
+			t.Fatalf("Failed to finalize the bound pod: %v", err)
+		}
+		if err := nc.Run(ctx); err != nil {
+			t.Fatalf("Failed to clean up namespaces: %v", err)
+		}
+
+		pods, err := apiClient.Pods().List(ctx, client.PodListOptions{})
+		if err != nil {
+			t.Fatalf("Failed to list pods: %v", err)
+		}
+		if len(pods) != 1 || pods[0].Name != "other" {
+			t.Errorf("Expected only the pod of the default namespace to be left, got %d pods", len(pods))
+		}
+		replicaSets, err := apiClient.ReplicaSets().List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list ReplicaSets: %v", err)
+		}
+		if len(replicaSets) != 1 || replicaSets[0].Namespace != api.DefaultNamespace {
+			t.Errorf("Expected only the ReplicaSet of the default namespace to be left, got %d ReplicaSets", len(replicaSets))
+		}
+		if _, err := apiClient.Namespaces().Get(ctx, "team-a"); !client.IsNotFound(err) {
+			t.Errorf("Expected the namespace to be removed, got %v", err)
+		}
+		_, err = apiClient.Pods().Create(ctx, newPod("web-3", "team-a"))
+		if !client.IsNotFound(err) {
+			t.Errorf("Expected creating a pod in a removed namespace to fail as not found, got %v", err)
+		}
+
+		// The default namespace is never deleted
+		_, err = apiClient.Namespaces().Delete(ctx, api.DefaultNamespace)
+		if code := client.StatusCode(err); code != http.StatusForbidden {
+			t.Errorf("Expected deleting the default namespace to be forbidden, got %d: %v", code, err)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+)
+
+const (
+	// DefaultNodeMonitorGracePeriod is how long a node may go without a heartbeat before it is marked NotReady
+	DefaultNodeMonitorGracePeriod = 40 * time.Second
+	// DefaultDeadNodeTimeout is how long a node stays NotReady before it is considered dead and removed, with
+	// the pods bound to it, so the ReplicaSets replace them and another machine may register the name
+	DefaultDeadNodeTimeout = 5 * time.Minute
+)
+
+// NodeController watches the heartbeats of the nodes. A node whose kubelet stopped reporting for the grace
+// period is marked NotReady, and one that stays silent for the dead node timeout after that is deleted along
+// with the pods bound to it. A node whose lease is alive is never silent, however old its status.
+type NodeController struct {
+	nodeRegistry    *registry.NodeRegistry
+	podRegistry     *registry.PodRegistry
+	leaseRegistry   *registry.NodeLeaseRegistry
+	gracePeriod     time.Duration
+	deadNodeTimeout time.Duration
+	resyncPeriod    time.Duration
+	logger          *slog.Logger
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// NewNodeController creates a new NodeController that checks the nodes every resyncPeriod
+func NewNodeController(nodeRegistry *registry.NodeRegistry, podRegistry *registry.PodRegistry, gracePeriod, deadNodeTimeout,
+	resyncPeriod time.Duration) *NodeController {
+	return &NodeController{
+		nodeRegistry:    nodeRegistry,
+		podRegistry:     podRegistry,
+		gracePeriod:     gracePeriod,
+		deadNodeTimeout: deadNodeTimeout,
+		resyncPeriod:    resyncPeriod,
+		logger:          logging.Component("node-controller"),
+		now:             time.Now,
+	}
+}
+
+// SetLogger makes the controller log to logger instead of the default logger
+func (nc *NodeController) SetLogger(logger *slog.Logger) {
+	nc.logger = logging.WithComponent(logger, "node-controller")
+}
+
+// SetLeaseRegistry makes the controller treat the nodes whose lease is alive as reporting, so their kubelets
+// may report the node status less often than the grace period
+func (nc *NodeController) SetLeaseRegistry(leaseRegistry *registry.NodeLeaseRegistry) {
+	nc.leaseRegistry = leaseRegistry
+}
+
+func (nc *NodeController) Start(ctx context.Context) {
+	ticker := time.NewTicker(nc.resyncPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// The failures were logged by Run
+		_ = nc.Run(ctx)
+	}
+}
+
+// Run checks the heartbeats of all nodes once
+func (nc *NodeController) Run(ctx context.Context) error {
+	ctx = logging.WithOperationID(ctx, logging.NewRequestID())
+	nodes, err := nc.nodeRegistry.ListNodes(ctx)
+	if err != nil {
+		nc.logger.ErrorContext(ctx, "Failed to list nodes", logging.Err(err))
+		return err
+	}
+
+	leased, err := nc.leasedNodes(ctx)
+	if err != nil {
+		nc.logger.ErrorContext(ctx, "Failed to list node leases", logging.Err(err))
+		return err
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		if leased[node.Name] {
+			continue
+		}
+		if err := nc.checkNode(ctx, node); err != nil {
+			nc.logger.ErrorContext(ctx, "Failed to check node", "node", node.Name, logging.Err(err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// leasedNodes returns the names of the nodes whose lease is alive, none without a lease registry. A node
+// lease is removed by the storage once it expires, so every lease listed is alive.
+func (nc *NodeController) leasedNodes(ctx context.Context) (map[string]bool, error) {
+	leased := make(map[string]bool)
+	if nc.leaseRegistry == nil {
+		return leased, nil
+	}
+	leases, err := nc.leaseRegistry.ListNodeLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, lease := range leases {
+		leased[lease.Name] = true
+	}
+	return leased, nil
+}
+
+// checkNode marks the node NotReady or removes it, depending on how long its kubelet has been silent. A node
+// that never reported is timed from its creation.
+func (nc *NodeController) checkNode(ctx context.Context, node *api.Node) error {
+	lastSeen := node.LastHeartbeatTime
+	if lastSeen.IsZero() {
+		lastSeen = node.CreationTimestamp
+	}
+	if lastSeen.IsZero() {
+		return nil
+	}
+	silent := nc.now().Sub(lastSeen)
+	switch {
+	case silent > nc.gracePeriod+nc.deadNodeTimeout:
+		return nc.removeNode(ctx, node, silent)
+	case silent > nc.gracePeriod && node.Status != api.NodeNotReady:
+		nc.logger.InfoContext(ctx, "Node stopped reporting, marking it NotReady", "node", node.Name, "lastHeartbeat", lastSeen)
+		node.Status = api.NodeNotReady
+		return nc.nodeRegistry.UpdateNodeStatus(ctx, node)
+	}
+	return nil
+}
+
+// removeNode deletes the pods bound to a dead node, then the node, so the name is free for another machine
+func (nc *NodeController) removeNode(ctx context.Context, node *api.Node, silent time.Duration) error {
+	pods, err := nc.podRegistry.ListPodsByNode(ctx, node.Name)
+	if err != nil {
+		return err
+	}
+	// What was deleted meanwhile, by a user or another controller, is as good as deleted here
+	for _, pod := range pods {
+		if err := registry.IgnoreNotFound(nc.podRegistry.DeletePod(ctx, pod.Namespace, pod.Name)); err != nil {
+			return err
+		}
+		nc.logger.InfoContext(ctx, "Deleted pod of dead node", "node", node.Name, "pod", pod.Name)
+	}
+	if err := registry.IgnoreNotFound(nc.nodeRegistry.DeleteNode(ctx, node.Name)); err != nil {
+		return err
+	}
+	nc.logger.InfoContext(ctx, "Removed dead node", "node", node.Name, "nodeUID", node.UID, "silentFor", silent.Round(time.Second))
+	return nil
+}
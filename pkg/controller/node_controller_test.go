@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestNodeController_DeclaresSilentNodesDead(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		podRegistry := registry.NewPodRegistry(store)
+		nc := NewNodeController(nodeRegistry, podRegistry, time.Minute, 5*time.Minute, time.Second)
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		nc.now = func() time.Time { return now }
+		ctx := context.Background()
+
+		for _, name := range []string{"node-1", "node-2"} {
+			require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+				ObjectMeta:        api.ObjectMeta{Name: name, UID: "machine-" + name},
+				Status:            api.NodeReady,
+				LastHeartbeatTime: now.Add(-10 * time.Second),
+			}))
+		}
+		for name, nodeName := range map[string]string{"web-1": "node-1", "web-2": "node-2", "pending": ""} {
+			require.NoError(t, podRegistry.CreatePod(ctx, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+				NodeName:   nodeName,
+			}))
+		}
+		status := func(name string) api.NodeStatus {
+			node, err := nodeRegistry.GetNode(ctx, name)
+			require.NoError(t, err)
+			return node.Status
+		}
+
+		// Both nodes reported recently
+		require.NoError(t, nc.Run(ctx))
+		assert.Equal(t, api.NodeReady, status("node-1"))
+
+		// node-1 stops reporting
+		now = now.Add(2 * time.Minute)
+		require.NoError(t, nodeRegistry.UpdateNodeStatus(ctx, &api.Node{
+			ObjectMeta:        api.ObjectMeta{Name: "node-2", UID: "machine-node-2"},
+			Status:            api.NodeReady,
+			LastHeartbeatTime: now,
+		}))
+		require.NoError(t, nc.Run(ctx))
+		assert.Equal(t, api.NodeNotReady, status("node-1"))
+		assert.Equal(t, api.NodeReady, status("node-2"))
+		node, err := nodeRegistry.GetNode(ctx, "node-1")
+		require.NoError(t, err)
+		assert.Equal(t, "machine-node-1", node.UID)
+
+		// Once it has been NotReady for the dead node timeout, it is removed with its pods
+		now = now.Add(5 * time.Minute)
+		require.NoError(t, nodeRegistry.UpdateNodeStatus(ctx, &api.Node{
+			ObjectMeta:        api.ObjectMeta{Name: "node-2", UID: "machine-node-2"},
+			Status:            api.NodeReady,
+			LastHeartbeatTime: now,
+		}))
+		require.NoError(t, nc.Run(ctx))
+		_, err = nodeRegistry.GetNode(ctx, "node-1")
+		assert.ErrorIs(t, err, registry.ErrNodeNotFound)
+		pods, err := podRegistry.ListPods(ctx, "")
+		require.NoError(t, err)
+		var names []string
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		assert.ElementsMatch(t, []string{"web-2", "pending"}, names)
+		assert.Equal(t, api.NodeReady, status("node-2"))
+	})
+}
+
+func TestNodeController_KeepsLeasedNodesReady(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		nodeRegistry := registry.NewNodeRegistry(store)
+		leaseRegistry := registry.NewNodeLeaseRegistry(store)
+		nc := NewNodeController(nodeRegistry, registry.NewPodRegistry(store), time.Minute, 5*time.Minute, time.Second)
+		nc.SetLeaseRegistry(leaseRegistry)
+		ctx := context.Background()
+
+		// The status of node-1 is older than the grace period, but its kubelet renews the lease
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{
+			ObjectMeta:        api.ObjectMeta{Name: "node-1", UID: "machine-node-1"},
+			Status:            api.NodeReady,
+			LastHeartbeatTime: time.Now().Add(-2 * time.Minute),
+		}))
+		require.NoError(t, leaseRegistry.RenewNodeLease(ctx, &api.Lease{
+			ObjectMeta:           api.ObjectMeta{Name: "node-1"},
+			HolderIdentity:       "machine-node-1",
+			LeaseDurationSeconds: api.MinLeaseDurationSeconds,
+		}))
+		status := func() api.NodeStatus {
+			node, err := nodeRegistry.GetNode(ctx, "node-1")
+			require.NoError(t, err)
+			return node.Status
+		}
+
+		require.NoError(t, nc.Run(ctx))
+		assert.Equal(t, api.NodeReady, status())
+
+		// Once the kubelet stops renewing, the lease expires and the stale status counts again
+		require.Eventually(t, func() bool {
+			require.NoError(t, nc.Run(ctx))
+			return status() == api.NodeNotReady
+		}, 10*time.Second, 200*time.Millisecond)
+	})
+}
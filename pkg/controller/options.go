@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+const (
+	// DefaultResyncPeriod is how often the controller re-lists ReplicaSets when no period is configured
+	DefaultResyncPeriod = 1 * time.Second
+	// DefaultWorkers is the number of ReplicaSets reconciled in parallel when no worker count is configured
+	DefaultWorkers = 1
+
+	MinResyncPeriod = 100 * time.Millisecond
+	MinWorkers      = 1
+	MaxWorkers      = 64
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid controller options")
+)
+
+// Options configures how often and how concurrently a ReplicaSetController reconciles
+type Options struct {
+	ResyncPeriod time.Duration
+	Workers      int
+	// MaxReplicas is the largest replica count the controller acts on; zero means api.DefaultMaxReplicasPerReplicaSet
+	MaxReplicas int32
+}
+
+// DefaultOptions returns the Options used by NewReplicaSetController
+func DefaultOptions() Options {
+	return Options{
+		ResyncPeriod: DefaultResyncPeriod,
+		Workers:      DefaultWorkers,
+		MaxReplicas:  api.DefaultMaxReplicasPerReplicaSet,
+	}
+}
+
+// Validate checks that the options are within sane bounds
+func (o Options) Validate() error {
+	if o.ResyncPeriod < MinResyncPeriod {
+		return fmt.Errorf("%w: resync period %v is below the minimum of %v", ErrInvalidOptions, o.ResyncPeriod, MinResyncPeriod)
+	}
+	if o.Workers < MinWorkers || o.Workers > MaxWorkers {
+		return fmt.Errorf("%w: workers must be between %d and %d, got %d", ErrInvalidOptions, MinWorkers, MaxWorkers, o.Workers)
+	}
+	if o.MaxReplicas < 0 {
+		return fmt.Errorf("%w: max replicas must not be negative, got %d", ErrInvalidOptions, o.MaxReplicas)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options Options
+		wantErr bool
+	}{
+		{name: "defaults", options: DefaultOptions()},
+		{name: "minimum resync and workers", options: Options{ResyncPeriod: MinResyncPeriod, Workers: MinWorkers}},
+		{name: "maximum workers", options: Options{ResyncPeriod: time.Second, Workers: MaxWorkers}},
+		{name: "resync too short", options: Options{ResyncPeriod: 10 * time.Millisecond, Workers: 1}, wantErr: true},
+		{name: "zero workers", options: Options{ResyncPeriod: time.Second, Workers: 0}, wantErr: true},
+		{name: "too many workers", options: Options{ResyncPeriod: time.Second, Workers: MaxWorkers + 1}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidOptions)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewReplicaSetControllerWithOptions(t *testing.T) {
+	_, err := NewReplicaSetControllerWithOptions(nil, nil, Options{ResyncPeriod: time.Millisecond, Workers: 1})
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+
+	rsc, err := NewReplicaSetControllerWithOptions(nil, nil, Options{ResyncPeriod: MinResyncPeriod, Workers: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, MinResyncPeriod, rsc.options.ResyncPeriod)
+	assert.Equal(t, 4, rsc.options.Workers)
+}
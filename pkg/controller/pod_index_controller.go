@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+)
+
+// DefaultPodIndexResyncPeriod is how often the index of the pods is checked against the pods
+const DefaultPodIndexResyncPeriod = 5 * time.Minute
+
+// PodIndexController repairs the index the PodRegistry keeps of the statuses and nodes of the pods, which
+// drifts when pods are written without it, e.g. by an older version. It also moves the pods an older version
+// stored without a namespace to theirs.
+type PodIndexController struct {
+	podRegistry *registry.PodRegistry
+	period      time.Duration
+	logger      *slog.Logger
+}
+
+// NewPodIndexController creates a new PodIndexController that repairs the index every period
+func NewPodIndexController(podRegistry *registry.PodRegistry, period time.Duration) *PodIndexController {
+	return &PodIndexController{
+		podRegistry: podRegistry,
+		period:      period,
+		logger:      logging.Component("pod-index-controller"),
+	}
+}
+
+// SetLogger makes the controller log to logger instead of the default logger
+func (c *PodIndexController) SetLogger(logger *slog.Logger) {
+	c.logger = logging.WithComponent(logger, "pod-index-controller")
+}
+
+// Start repairs the index right away, as pods stored by an older version are not in their namespace and
+// have no index entries yet, then every period
+func (c *PodIndexController) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.period)
+	defer ticker.Stop()
+
+	for {
+		// The failures were logged by Run
+		_ = c.Run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run moves the pods without a namespace and repairs the index once
+func (c *PodIndexController) Run(ctx context.Context) error {
+	ctx = logging.WithOperationID(ctx, logging.NewRequestID())
+	migrated, err := c.podRegistry.MigrateLegacyPods(ctx)
+	if migrated > 0 {
+		c.logger.InfoContext(ctx, "Moved the pods stored without a namespace", "pods", migrated)
+	}
+	if err != nil {
+		c.logger.ErrorContext(ctx, "Failed to move the pods stored without a namespace", logging.Err(err))
+		return err
+	}
+	fixed, err := c.podRegistry.ReconcileIndex(ctx)
+	if fixed > 0 {
+		c.logger.InfoContext(ctx, "Repaired the pod index", "entries", fixed)
+	}
+	if err != nil {
+		c.logger.ErrorContext(ctx, "Failed to repair the pod index", logging.Err(err))
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestPodIndexController_IndexesPodsStoredWithoutIt(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		podRegistry := registry.NewPodRegistry(store)
+		ctx := context.Background()
+
+		// Written by a version that did not index the pods
+		require.NoError(t, store.Create(ctx, "/pods/web", &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+			Status:     api.PodPending,
+		}))
+		pending, err := podRegistry.ListPendingPods(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		require.NoError(t, NewPodIndexController(podRegistry, DefaultPodIndexResyncPeriod).Run(ctx))
+		pending, err = podRegistry.ListPendingPods(ctx)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "web", pending[0].Name)
+	})
+}
@@ -2,11 +2,18 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 
 	"gokube/pkg/api"
+	"gokube/pkg/client/informers"
+	"gokube/pkg/logging"
 	"gokube/pkg/registry"
 	"gokube/pkg/registry/names"
 )
@@ -15,29 +22,116 @@ import (
 type ReplicaSetController struct {
 	replicaSetRegistry *registry.ReplicaSetRegistry
 	podRegistry        *registry.PodRegistry
+	options            Options
+	expectations       *ControllerExpectations
+	// listPods lists the pods the controller reconciles against; it can lag behind the creations and
+	// deletions the controller issued, which is what the expectations guard against.
+	listPods func(ctx context.Context) ([]*api.Pod, error)
+	// syncRequests asks the reconcile loop for a pass before its next tick
+	syncRequests chan struct{}
+	// nameGenerator names the pods created; it defaults to names.SimpleNameGenerator
+	nameGenerator names.NameGenerator
+	logger        *slog.Logger
 }
 
-// NewReplicaSetController creates a new ReplicaSetController
+// NewReplicaSetController creates a new ReplicaSetController with the default options
 func NewReplicaSetController(rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry) *ReplicaSetController {
+	// The pods of every namespace are listed; each ReplicaSet only adopts those of its own
+	listPods := func(ctx context.Context) ([]*api.Pod, error) { return podRegistry.ListPods(ctx, "") }
 	return &ReplicaSetController{
 		replicaSetRegistry: rsRegistry,
 		podRegistry:        podRegistry,
+		options:            DefaultOptions(),
+		expectations:       NewControllerExpectations(),
+		listPods:           listPods,
+		syncRequests:       make(chan struct{}, 1),
+		nameGenerator:      names.SimpleNameGenerator,
+		logger:             logging.Component("controller"),
 	}
 }
 
+// SetLogger makes the controller log to logger instead of the default logger
+func (rsc *ReplicaSetController) SetLogger(logger *slog.Logger) {
+	rsc.logger = logging.WithComponent(logger, "controller")
+}
+
+// UsePodInformer makes the controller read the pods from the cache of informer instead of listing them on
+// every pass, and reconcile as soon as a pod changes rather than on the next tick. The informer is run by
+// the caller.
+func (rsc *ReplicaSetController) UsePodInformer(informer *informers.Informer[api.Pod]) {
+	rsc.listPods = func(ctx context.Context) ([]*api.Pod, error) {
+		return informer.Store().List(), nil
+	}
+	informer.AddEventHandler(informers.EventHandler[api.Pod]{
+		AddFunc:    func(*api.Pod) { rsc.requestSync() },
+		UpdateFunc: func(_, _ *api.Pod) { rsc.requestSync() },
+		DeleteFunc: func(*api.Pod) { rsc.requestSync() },
+	})
+}
+
+// UseReplicaSetInformer makes the controller reconcile as soon as a ReplicaSet is created, deleted or has its
+// spec changed rather than on the next tick. The status the controller writes on every pass is not a change
+// to act on. The informer is run by the caller.
+func (rsc *ReplicaSetController) UseReplicaSetInformer(informer *informers.Informer[api.ReplicaSet]) {
+	informer.AddEventHandler(informers.EventHandler[api.ReplicaSet]{
+		AddFunc: func(*api.ReplicaSet) { rsc.requestSync() },
+		UpdateFunc: func(oldRS, newRS *api.ReplicaSet) {
+			if !reflect.DeepEqual(oldRS.Spec, newRS.Spec) {
+				rsc.requestSync()
+			}
+		},
+		DeleteFunc: func(*api.ReplicaSet) { rsc.requestSync() },
+	})
+}
+
+// NewReplicaSetControllerWithOptions creates a new ReplicaSetController with the given resync period and worker count
+func NewReplicaSetControllerWithOptions(rsRegistry *registry.ReplicaSetRegistry, podRegistry *registry.PodRegistry, options Options) (*ReplicaSetController, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	rsc := NewReplicaSetController(rsRegistry, podRegistry)
+	rsc.options = options
+	return rsc, nil
+}
+
 func (rsc *ReplicaSetController) Reconcile(ctx context.Context, rs *api.ReplicaSet) error {
 	// Get current ReplicaSet state
-	currentRS, err := rsc.replicaSetRegistry.Get(ctx, rs.Name)
+	currentRS, err := rsc.replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
 	if err != nil {
 		return err
 	}
 
+	// Refuse to act on objects stored before the replica cap was enforced
+	if maxReplicas := rsc.maxReplicas(); currentRS.Spec.Replicas > maxReplicas {
+		currentRS.Status.SetCondition(api.ReplicaSetCondition{
+			Type:    api.ReplicaSetReplicaFailure,
+			Status:  api.ConditionTrue,
+			Reason:  "TooManyReplicas",
+			Message: fmt.Sprintf("spec.replicas %d exceeds the maximum of %d", currentRS.Spec.Replicas, maxReplicas),
+		})
+		return rsc.replicaSetRegistry.UpdateStatus(ctx, currentRS)
+	}
+	currentRS.Status.RemoveCondition(api.ReplicaSetReplicaFailure)
+
 	// Get all pods
-	allPods, err := rsc.podRegistry.ListPods(ctx)
+	allPods, err := rsc.listPods(ctx)
 	if err != nil {
 		return err
 	}
 
+	// Wait until the pods created or deleted by the previous sync show up in the listing
+	key := replicaSetKey(currentRS)
+	rsc.expectations.ObservePods(key, allPods)
+	if !rsc.expectations.SatisfiedExpectations(key) {
+		return nil
+	}
+
+	// Adopt orphaned pods that match the selector so they count towards the replicas
+	if err := rsc.adoptOrphanPods(ctx, currentRS, allPods); err != nil {
+		return err
+	}
+
 	// Get active pods for this ReplicaSet
 	activePods, err := rsc.getPodsForReplicaSet(currentRS, allPods, api.IsPodActiveAndOwnedBy)
 	if err != nil {
@@ -47,14 +141,185 @@ func (rsc *ReplicaSetController) Reconcile(ctx context.Context, rs *api.ReplicaS
 	// Compare current pod count with desired replica count
 	currentPodCount := len(activePods)
 	desiredPodCount := int(currentRS.Spec.Replicas)
+	readyPodCount := countReadyPods(activePods)
 
-	//Assignment 3:. Implement Logic to Create Pods.
-	_ = currentPodCount
-	_ = desiredPodCount
+	switch {
+	case currentPodCount < desiredPodCount:
+		// The missing pods are created in batches, each with the status counting the pods created so far, so
+		// a failure leaves neither part of a batch nor a status that is ahead of the pods
+		pods := make([]*api.Pod, 0, desiredPodCount-currentPodCount)
+		for i := currentPodCount; i < desiredPodCount; i++ {
+			pod, err := rsc.newPod(ctx, currentRS, pods)
+			if err != nil {
+				return err
+			}
+			pods = append(pods, pod)
+		}
+		currentRS.Status.Replicas = int32(currentPodCount)
+		currentRS.Status.ReadyReplicas = int32(readyPodCount)
+		for batch := range slices.Chunk(pods, maxPodsPerCreate) {
+			currentRS.Status.Replicas += int32(len(batch))
+			if err := rsc.createPods(ctx, key, currentRS, batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	case currentPodCount > desiredPodCount:
+		deleted := make([]string, 0, currentPodCount-desiredPodCount)
+		defer func() { rsc.expectations.ExpectDeletions(key, deleted) }()
+		for _, pod := range podsToDelete(activePods, currentPodCount-desiredPodCount) {
+			switch _, err := rsc.podRegistry.DeletePodGracefully(ctx, pod.Namespace, pod.Name); {
+			case errors.Is(err, registry.ErrPodNotFound):
+				// Deleted by someone else, so its delete may have been seen already and is not expected
+			case err != nil:
+				return fmt.Errorf("failed to delete pod %s: %w", pod.Name, err)
+			default:
+				deleted = append(deleted, pod.Name)
+			}
+			if isPodReady(pod) {
+				readyPodCount--
+			}
+		}
+	}
+
+	currentRS.Status.Replicas = int32(desiredPodCount)
+	currentRS.Status.ReadyReplicas = int32(readyPodCount)
+	return rsc.replicaSetRegistry.UpdateStatus(ctx, currentRS)
+}
 
+// adoptOrphanPods sets rs as the controller of active pods of its namespace that match its selector but have
+// no controller, replacing them in pods with the adopted ones. Pods controlled by another object are never
+// adopted.
+func (rsc *ReplicaSetController) adoptOrphanPods(ctx context.Context, rs *api.ReplicaSet, pods []*api.Pod) error {
+	if len(rs.Spec.Selector) == 0 {
+		return nil
+	}
+
+	for i, pod := range pods {
+		if pod.GetControllerOf() != nil || !pod.IsActive() || !api.SelectorMatches(rs.Spec.Selector, pod.Labels) ||
+			api.NamespaceOf(&pod.ObjectMeta) != api.NamespaceOf(&rs.ObjectMeta) {
+			continue
+		}
+
+		// The listed pod may be shared with an informer cache, so the change is made to a copy, which takes
+		// its place in pods to count towards the replicas
+		adopted := *pod
+		adopted.OwnerReferences = append(slices.Clone(pod.OwnerReferences), api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet))
+		if err := rsc.podRegistry.UpdatePod(ctx, &adopted); err != nil {
+			return fmt.Errorf("failed to adopt pod %s: %w", pod.Name, err)
+		}
+		pods[i] = &adopted
+		rsc.logger.InfoContext(ctx, "Adopted pod", "replicaSet", rs.Name, "replicaSetUID", rs.UID, "pod", pod.Name, "podUID", pod.UID)
+	}
 	return nil
 }
 
+// newPod returns a new pod of the ReplicaSet, named apart from the existing pods and from pods, the ones about
+// to be created with it
+func (rsc *ReplicaSetController) newPod(ctx context.Context, rs *api.ReplicaSet, pods []*api.Pod) (*api.Pod, error) {
+	name, err := rsc.generatePodName(ctx, rs, pods)
+	if err != nil {
+		return nil, err
+	}
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name:            name,
+			Namespace:       rs.Namespace,
+			Labels:          podLabelsFromReplicaSet(rs),
+			OwnerReferences: []api.OwnerReference{api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet)},
+		},
+		Spec:   rs.Spec.Template.Spec,
+		Status: api.PodPending,
+	}
+	// The pod is traced with the request that created its ReplicaSet
+	pod.SetTraceID(rs.TraceID())
+	return pod, nil
+}
+
+// maxPodsPerCreate is how many pods createPods is given at once. A pod takes up to three writes of the
+// transaction, with its index entries, so a batch stays below the 128 writes etcd allows a transaction by
+// default along with the quotas and the status written with it.
+const maxPodsPerCreate = 32
+
+// createPods creates pods and updates the status of rs in one transaction, and expects the pods to show up
+// in the listing once it succeeds
+func (rsc *ReplicaSetController) createPods(ctx context.Context, key string, rs *api.ReplicaSet, pods []*api.Pod) error {
+	statusOp, err := rsc.replicaSetRegistry.UpdateStatusOp(ctx, rs)
+	if err != nil {
+		return err
+	}
+	if err := rsc.podRegistry.CreatePodsWith(ctx, pods, statusOp); err != nil {
+		return fmt.Errorf("failed to create pods for replicaset %s: %w", rs.Name, err)
+	}
+
+	created := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		created = append(created, pod.Name)
+		rsc.logger.InfoContext(ctx, "Created pod", "replicaSet", rs.Name, "replicaSetUID", rs.UID, "pod", pod.Name, "podUID", pod.UID)
+	}
+	rsc.expectations.ExpectCreations(key, created)
+	return nil
+}
+
+// replicaSetKey identifies a ReplicaSet in the expectations store
+func replicaSetKey(rs *api.ReplicaSet) string {
+	if rs.Namespace == "" {
+		return rs.Name
+	}
+	return rs.Namespace + "/" + rs.Name
+}
+
+// podLabelsFromReplicaSet returns the template labels, filling in any selector labels the template omits
+// so that created pods always match the selector.
+func podLabelsFromReplicaSet(rs *api.ReplicaSet) map[string]string {
+	labels := make(map[string]string, len(rs.Spec.Selector)+len(rs.Spec.Template.Labels))
+	for key, value := range rs.Spec.Selector {
+		labels[key] = value
+	}
+	for key, value := range rs.Spec.Template.Labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// podsToDelete picks the pods to remove when scaling down, preferring pods that
+// have made the least progress so running workloads are disturbed last.
+func podsToDelete(pods []*api.Pod, count int) []*api.Pod {
+	candidates := make([]*api.Pod, len(pods))
+	copy(candidates, pods)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return podStatusRank(candidates[i].Status) < podStatusRank(candidates[j].Status)
+	})
+	return candidates[:count]
+}
+
+// isPodReady checks if the pod serves, i.e. all of its containers were started. There are no readiness probes
+// yet, so a running pod is ready.
+func isPodReady(pod *api.Pod) bool {
+	return pod.Status == api.PodRunning
+}
+
+func countReadyPods(pods []*api.Pod) int {
+	ready := 0
+	for _, pod := range pods {
+		if isPodReady(pod) {
+			ready++
+		}
+	}
+	return ready
+}
+
+func podStatusRank(status api.PodStatus) int {
+	switch status {
+	case api.PodPending:
+		return 0
+	case api.PodScheduled:
+		return 1
+	default:
+		return 2
+	}
+}
+
 func (rsc *ReplicaSetController) getPodsForReplicaSet(
 	rs *api.ReplicaSet,
 	allPods []*api.Pod,
@@ -75,7 +340,7 @@ func (rsc *ReplicaSetController) getPodsOwnedBy(rs *api.ReplicaSet, pods []*api.
 }
 
 func (rsc *ReplicaSetController) Start(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(rsc.options.ResyncPeriod)
 	defer ticker.Stop()
 
 	for {
@@ -83,31 +348,90 @@ func (rsc *ReplicaSetController) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := rsc.Run(ctx); err != nil {
-				fmt.Printf("Error reconciling replicaset: %v\n", err)
-			}
+		case <-rsc.syncRequests:
 		}
+		// The ReplicaSets that failed were logged by Run
+		_ = rsc.Run(ctx)
 	}
 }
 
-func (rsc *ReplicaSetController) Run(_ context.Context) error {
+// requestSync asks the reconcile loop for a pass without waiting for the next tick. Requests made while a
+// pass is already pending are merged into it.
+func (rsc *ReplicaSetController) requestSync() {
+	select {
+	case rsc.syncRequests <- struct{}{}:
+	default:
+	}
+}
 
-	rscList, err := rsc.replicaSetRegistry.List(context.Background())
+// Run reconciles every ReplicaSet once, spreading the work across the configured number of workers. The logs
+// of a pass share an operation ID, and the work on a ReplicaSet is traced with its trace ID.
+func (rsc *ReplicaSetController) Run(ctx context.Context) error {
+	ctx = logging.WithOperationID(ctx, logging.NewRequestID())
+	rscList, err := rsc.replicaSetRegistry.List(ctx, "")
 	if err != nil {
-		log.Fatalf("failed to list replicaSets: %v", err)
-		return err
+		rsc.logger.ErrorContext(ctx, "Failed to list ReplicaSets", logging.Err(err))
+		return fmt.Errorf("failed to list replicaSets: %w", err)
+	}
+
+	queue := make(chan *api.ReplicaSet)
+	errCh := make(chan error, len(rscList))
+
+	var wg sync.WaitGroup
+	for i := 0; i < rsc.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rs := range queue {
+				rsCtx := logging.WithRequestID(ctx, rs.TraceID())
+				if err := rsc.Reconcile(rsCtx, rs); err != nil {
+					rsc.logger.ErrorContext(rsCtx, "Failed to reconcile ReplicaSet", "replicaSet", rs.Name, "replicaSetUID", rs.UID, logging.Err(err))
+					errCh <- err
+				}
+			}
+		}()
 	}
 
 	for _, rs := range rscList {
-		err := rsc.Reconcile(context.Background(), rs)
-		if err != nil {
-			log.Fatalf("failed to reconcile: %v", err)
-		}
+		queue <- rs
 	}
-	return nil
+	close(queue)
+	wg.Wait()
+	close(errCh)
+
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (rsc *ReplicaSetController) maxReplicas() int32 {
+	if rsc.options.MaxReplicas == 0 {
+		return api.DefaultMaxReplicasPerReplicaSet
+	}
+	return rsc.options.MaxReplicas
 }
 
-// GeneratePodNameFromReplicaSet creates a pod name based on the ReplicaSet and container names
-func generatePodNameFromReplicaSet(replicaSetName string) string {
-	return names.SimpleNameGenerator.GenerateName(replicaSetName)
+func (rsc *ReplicaSetController) workers() int {
+	if rsc.options.Workers < MinWorkers {
+		return MinWorkers
+	}
+	return rsc.options.Workers
+}
+
+// generatePodName names a new pod of the ReplicaSet after it, skipping the names of existing pods and of pending,
+// the pods about to be created
+func (rsc *ReplicaSetController) generatePodName(ctx context.Context, rs *api.ReplicaSet, pending []*api.Pod) (string, error) {
+	name, err := names.GenerateUniqueName(rsc.nameGenerator, rs.Name, func(name string) bool {
+		if slices.ContainsFunc(pending, func(pod *api.Pod) bool { return pod.Name == name }) {
+			return true
+		}
+		_, err := rsc.podRegistry.GetPod(ctx, rs.Namespace, name)
+		return err == nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to name a pod for replicaset %s: %w", rs.Name, err)
+	}
+	return name, nil
 }
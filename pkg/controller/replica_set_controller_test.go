@@ -2,11 +2,18 @@ package controller
 
 import (
 	"context"
+	"math/rand"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/client"
+	"gokube/pkg/client/informers"
 	"gokube/pkg/registry"
+	"gokube/pkg/registry/names"
 	"gokube/pkg/storage"
 )
 
@@ -75,7 +82,7 @@ func TestReconcile(t *testing.T) {
 			t.Run(tc.name, func(t *testing.T) {
 				ctx := context.Background()
 
-				err := replicaSetRegistry.Delete(ctx, tc.initialRS.Name)
+				err := registry.IgnoreNotFound(replicaSetRegistry.Delete(ctx, tc.initialRS.Namespace, tc.initialRS.Name))
 				if err != nil {
 					t.Fatalf("Failed to Delete ReplicaSet: %v", err)
 				}
@@ -102,7 +109,7 @@ func TestReconcile(t *testing.T) {
 				}
 
 				// Check the number of pods
-				allPods, err := podRegistry.ListPods(ctx)
+				allPods, err := podRegistry.ListPods(ctx, "")
 				if err != nil {
 					t.Fatalf("Failed to list pods: %v", err)
 				}
@@ -115,7 +122,7 @@ func TestReconcile(t *testing.T) {
 				}
 
 				// Check the ReplicaSet status
-				updatedRS, err := replicaSetRegistry.Get(ctx, tc.initialRS.Name)
+				updatedRS, err := replicaSetRegistry.Get(ctx, tc.initialRS.Namespace, tc.initialRS.Name)
 				if err != nil {
 					t.Fatalf("Failed to get updated ReplicaSet: %v", err)
 				}
@@ -188,3 +195,735 @@ func TestGetActivePodsForReplicaSet(t *testing.T) {
 		})
 	}
 }
+
+func TestReplicaSetController_Start(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+
+		rsc, err := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, Options{
+			ResyncPeriod: MinResyncPeriod,
+			Workers:      2,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create controller: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for _, name := range []string{"web", "db"} {
+			rs := &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec: api.ReplicaSetSpec{
+					Replicas: 2,
+					Template: api.PodTemplateSpec{
+						Spec: api.PodSpec{
+							Containers: []api.Container{{Name: "test-container", Image: "nginx"}},
+						},
+					},
+				},
+			}
+			if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+				t.Fatalf("Failed to create ReplicaSet: %v", err)
+			}
+		}
+
+		go rsc.Start(ctx)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			pods, err := podRegistry.ListPods(ctx, "")
+			if err != nil {
+				t.Fatalf("Failed to list pods: %v", err)
+			}
+			if len(pods) == 4 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected 4 pods to be created, got %d", len(pods))
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+}
+
+func TestReplicaSetController_UsePodInformer(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		apiServer := httptest.NewServer(server.NewAPIServer(etcdStorage).Handler())
+		defer apiServer.Close()
+		apiClient, err := client.New(apiServer.URL, client.Options{})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		// Only pod changes can make the controller act before the test times out
+		rsc, err := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, Options{ResyncPeriod: time.Hour, Workers: 1})
+		if err != nil {
+			t.Fatalf("Failed to create controller: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		factory := informers.NewSharedInformerFactory(apiClient, 20*time.Millisecond)
+		rsc.UsePodInformer(factory.Pods())
+		factory.Start(ctx)
+		if !factory.WaitForCacheSync(ctx) {
+			t.Fatal("Pod informer did not sync")
+		}
+		go rsc.Start(ctx)
+
+		selector := map[string]string{"app": "web"}
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 2,
+				Selector: selector,
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: selector},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+		orphan := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "handmade", Labels: selector},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+		}
+		if err := podRegistry.CreatePod(ctx, orphan); err != nil {
+			t.Fatalf("Failed to create pod: %v", err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			pods, err := podRegistry.ListPods(ctx, "")
+			if err != nil {
+				t.Fatalf("Failed to list pods: %v", err)
+			}
+			owned, _ := rsc.getPodsOwnedBy(rs, pods)
+			if len(owned) == 2 && len(pods) == 2 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the orphan to be adopted and 1 pod to be created, got %d pods, %d owned", len(pods), len(owned))
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+}
+
+func TestReplicaSetController_UseReplicaSetInformer(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		apiServer := httptest.NewServer(server.NewAPIServer(etcdStorage).Handler())
+		defer apiServer.Close()
+		apiClient, err := client.New(apiServer.URL, client.Options{})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		// Only ReplicaSet changes can make the controller act before the test times out
+		rsc, err := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, Options{ResyncPeriod: time.Hour, Workers: 1})
+		if err != nil {
+			t.Fatalf("Failed to create controller: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		factory := informers.NewSharedInformerFactory(apiClient, time.Hour)
+		rsc.UseReplicaSetInformer(factory.ReplicaSets())
+		factory.Start(ctx)
+		if !factory.WaitForCacheSync(ctx) {
+			t.Fatal("ReplicaSet informer did not sync")
+		}
+		go rsc.Start(ctx)
+
+		selector := map[string]string{"app": "web"}
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 1,
+				Selector: selector,
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: selector},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+		waitForPods := func(want int) {
+			t.Helper()
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				pods, err := podRegistry.ListPods(ctx, "")
+				if err != nil {
+					t.Fatalf("Failed to list pods: %v", err)
+				}
+				if len(pods) == want {
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("Expected %d pods, got %d", want, len(pods))
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+		waitForPods(1)
+
+		current, err := replicaSetRegistry.Get(ctx, api.DefaultNamespace, "web")
+		if err != nil {
+			t.Fatalf("Failed to get ReplicaSet: %v", err)
+		}
+		current.Spec.Replicas = 3
+		if err := replicaSetRegistry.Update(ctx, current); err != nil {
+			t.Fatalf("Failed to scale ReplicaSet: %v", err)
+		}
+		waitForPods(3)
+	})
+}
+
+func TestReconcile_ScaleDown(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "scale-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 1,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+
+		for name, status := range map[string]api.PodStatus{
+			"scale-rs-running": api.PodRunning,
+			"scale-rs-pending": api.PodPending,
+		} {
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				Status:     status,
+			}
+			if err := podRegistry.CreatePod(ctx, pod); err != nil {
+				t.Fatalf("Failed to create Pod: %v", err)
+			}
+		}
+
+		if err := rsc.Reconcile(ctx, rs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		pods, err := podRegistry.ListPods(ctx, "")
+		if err != nil {
+			t.Fatalf("Failed to list pods: %v", err)
+		}
+		if len(pods) != 1 || pods[0].Name != "scale-rs-running" {
+			t.Errorf("Expected only the running pod to survive scale down, got %v", pods)
+		}
+
+		updatedRS, err := replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
+		if err != nil {
+			t.Fatalf("Failed to get ReplicaSet: %v", err)
+		}
+		if updatedRS.Status.ReadyReplicas != 1 {
+			t.Errorf("Expected the running pod to be counted as ready, got %d ready replicas", updatedRS.Status.ReadyReplicas)
+		}
+	})
+}
+
+func TestReconcile_ScaleDownDeletesGracefully(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "scale-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 1,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+		for _, name := range []string{"scale-rs-1", "scale-rs-2"} {
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				NodeName:   "node-1",
+				Status:     api.PodRunning,
+			}
+			if err := podRegistry.CreatePod(ctx, pod); err != nil {
+				t.Fatalf("Failed to create Pod: %v", err)
+			}
+		}
+
+		// The pod removed is kept until the kubelet of its node has stopped it, and is not replaced meanwhile
+		for range 2 {
+			if err := rsc.Reconcile(ctx, rs); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		pods, err := podRegistry.ListPods(ctx, "")
+		if err != nil {
+			t.Fatalf("Failed to list pods: %v", err)
+		}
+		deleting := 0
+		for _, pod := range pods {
+			if pod.IsDeleting() {
+				deleting++
+				if pod.Status != api.PodTerminating {
+					t.Errorf("Expected the pod scaled down to be Terminating, got %s", pod.Status)
+				}
+			}
+		}
+		if len(pods) != 2 || deleting != 1 {
+			t.Errorf("Expected one of the 2 pods to be deleted gracefully, got %d pods with %d being deleted", len(pods), deleting)
+		}
+	})
+}
+
+func TestReconcile_RetriesTakenPodNames(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		rsc.nameGenerator = names.NewSimpleNameGenerator(rand.NewSource(1))
+		ctx := context.Background()
+
+		// A pod of another ReplicaSet already has the first name the controller generates
+		expected := names.NewSimpleNameGenerator(rand.NewSource(1))
+		taken, free := expected.GenerateName("web"), expected.GenerateName("web")
+		other := &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name:            taken,
+				OwnerReferences: []api.OwnerReference{{Kind: api.KindReplicaSet, Name: "webapp", Controller: true}},
+			},
+			Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+		}
+		if err := podRegistry.CreatePod(ctx, other); err != nil {
+			t.Fatalf("Failed to create Pod: %v", err)
+		}
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 1,
+				Selector: map[string]string{"app": "web"},
+				Template: api.PodTemplateSpec{
+					ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "web"}},
+					Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+
+		if err := rsc.Reconcile(ctx, rs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		pod, err := podRegistry.GetPod(ctx, api.DefaultNamespace, free)
+		if err != nil {
+			t.Fatalf("Expected the pod to get the next generated name %s: %v", free, err)
+		}
+		if pod.GetControllerOf() == nil || pod.GetControllerOf().Name != "web" {
+			t.Errorf("Expected the pod to be controlled by the ReplicaSet, got %v", pod.OwnerReferences)
+		}
+	})
+}
+
+func TestReconcile_CreatesPodsWithTheStatus(t *testing.T) {
+	chaos := storage.NewChaosStorage(storage.NewMemoryStorage(), nil)
+	replicaSetRegistry := registry.NewReplicaSetRegistry(chaos)
+	podRegistry := registry.NewPodRegistry(chaos)
+	rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+	ctx := context.Background()
+
+	rs := &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec: api.ReplicaSetSpec{
+			Replicas: 3,
+			Template: api.PodTemplateSpec{
+				Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			},
+		},
+	}
+	if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+		t.Fatalf("Failed to create ReplicaSet: %v", err)
+	}
+
+	// The third pod fails, so neither the other pods nor the status are written
+	chaos.SetPolicy(storage.FailNth(storage.Match{Op: storage.OpCreate, Prefix: "/pods/"}, 3, storage.ErrEtcdClient))
+	if err := rsc.Reconcile(ctx, rs); err == nil {
+		t.Fatal("Expected the reconcile to fail")
+	}
+	if pods, _ := podRegistry.ListPods(ctx, ""); len(pods) != 0 {
+		t.Errorf("Expected no pods, got %d", len(pods))
+	}
+	stored, err := replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
+	if err != nil {
+		t.Fatalf("Failed to get ReplicaSet: %v", err)
+	}
+	if stored.Status.Replicas != 0 {
+		t.Errorf("Expected the status to count no replicas, got %d", stored.Status.Replicas)
+	}
+
+	chaos.SetPolicy(nil)
+	if err := rsc.Reconcile(ctx, rs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pods, _ := podRegistry.ListPods(ctx, ""); len(pods) != 3 {
+		t.Errorf("Expected 3 pods, got %d", len(pods))
+	}
+	if stored, _ = replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name); stored.Status.Replicas != 3 {
+		t.Errorf("Expected the status to count 3 replicas, got %d", stored.Status.Replicas)
+	}
+}
+
+func TestReconcile_CreatesPodsInBatches(t *testing.T) {
+	chaos := storage.NewChaosStorage(storage.NewMemoryStorage(), nil)
+	replicaSetRegistry := registry.NewReplicaSetRegistry(chaos)
+	podRegistry := registry.NewPodRegistry(chaos)
+	rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+	ctx := context.Background()
+
+	rs := &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec: api.ReplicaSetSpec{
+			Replicas: 100,
+			Template: api.PodTemplateSpec{
+				Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			},
+		},
+	}
+	if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+		t.Fatalf("Failed to create ReplicaSet: %v", err)
+	}
+
+	// The pods do not fit in one transaction; a pod of the second batch fails, which keeps the first one
+	chaos.SetPolicy(storage.FailNth(storage.Match{Op: storage.OpCreate, Prefix: "/pods/"}, maxPodsPerCreate+5, storage.ErrEtcdClient))
+	if err := rsc.Reconcile(ctx, rs); err == nil {
+		t.Fatal("Expected the reconcile to fail")
+	}
+	if pods, _ := podRegistry.ListPods(ctx, ""); len(pods) != maxPodsPerCreate {
+		t.Errorf("Expected %d pods, got %d", maxPodsPerCreate, len(pods))
+	}
+	stored, err := replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
+	if err != nil {
+		t.Fatalf("Failed to get ReplicaSet: %v", err)
+	}
+	if stored.Status.Replicas != maxPodsPerCreate {
+		t.Errorf("Expected the status to count %d replicas, got %d", maxPodsPerCreate, stored.Status.Replicas)
+	}
+
+	chaos.SetPolicy(nil)
+	if err := rsc.Reconcile(ctx, stored); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pods, _ := podRegistry.ListPods(ctx, ""); len(pods) != 100 {
+		t.Errorf("Expected 100 pods, got %d", len(pods))
+	}
+	if stored, _ = replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name); stored.Status.Replicas != 100 {
+		t.Errorf("Expected the status to count 100 replicas, got %d", stored.Status.Replicas)
+	}
+}
+
+func TestReconcile_Adoption(t *testing.T) {
+	newPod := func(name string, labels map[string]string, status api.PodStatus, owners ...api.OwnerReference) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name, Labels: labels, OwnerReferences: owners},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+			Status:     status,
+		}
+	}
+	selector := map[string]string{"app": "web"}
+
+	testCases := []struct {
+		name            string
+		replicas        int32
+		initialPods     []*api.Pod
+		expectedOwned   int
+		expectedSurvive []string
+		expectedGone    []string
+	}{
+		{
+			name:            "adopts orphan pods matching the selector",
+			replicas:        2,
+			initialPods:     []*api.Pod{newPod("handmade", selector, api.PodRunning)},
+			expectedOwned:   2,
+			expectedSurvive: []string{"handmade"},
+		},
+		{
+			name:     "does not adopt pods owned by another controller",
+			replicas: 1,
+			initialPods: []*api.Pod{
+				newPod("foreign", selector, api.PodRunning, api.OwnerReference{Kind: api.KindReplicaSet, Name: "other-rs", Controller: true}),
+			},
+			expectedOwned:   1,
+			expectedSurvive: []string{"foreign"},
+		},
+		{
+			name:     "does not adopt pods not matching the selector",
+			replicas: 1,
+			initialPods: []*api.Pod{
+				newPod("unrelated", map[string]string{"app": "db"}, api.PodRunning),
+			},
+			expectedOwned:   1,
+			expectedSurvive: []string{"unrelated"},
+		},
+		{
+			name:     "adopted pods take part in scale down victim selection",
+			replicas: 1,
+			initialPods: []*api.Pod{
+				newPod("adopted-running", selector, api.PodRunning),
+				newPod("adopted-pending", selector, api.PodPending),
+				newPod("foreign-pending", selector, api.PodPending, api.OwnerReference{Kind: api.KindReplicaSet, Name: "other-rs", Controller: true}),
+			},
+			expectedOwned:   1,
+			expectedSurvive: []string{"adopted-running", "foreign-pending"},
+			expectedGone:    []string{"adopted-pending"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+				etcdStorage := storage.NewEtcdStorage(etcdServer)
+				replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+				podRegistry := registry.NewPodRegistry(etcdStorage)
+				rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+				ctx := context.Background()
+
+				rs := &api.ReplicaSet{
+					ObjectMeta: api.ObjectMeta{Name: "web-rs"},
+					Spec: api.ReplicaSetSpec{
+						Replicas: tc.replicas,
+						Selector: selector,
+						Template: api.PodTemplateSpec{
+							Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+						},
+					},
+				}
+				if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+					t.Fatalf("Failed to create ReplicaSet: %v", err)
+				}
+				for _, pod := range tc.initialPods {
+					if err := podRegistry.CreatePod(ctx, pod); err != nil {
+						t.Fatalf("Failed to create Pod: %v", err)
+					}
+				}
+
+				if err := rsc.Reconcile(ctx, rs); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				pods, err := podRegistry.ListPods(ctx, "")
+				if err != nil {
+					t.Fatalf("Failed to list pods: %v", err)
+				}
+				owned, _ := rsc.getPodsOwnedBy(rs, pods)
+				if len(owned) != tc.expectedOwned {
+					t.Errorf("Expected %d owned pods, got %d", tc.expectedOwned, len(owned))
+				}
+				for _, pod := range owned {
+					if ref := pod.GetControllerOf(); ref == nil || ref.Name != rs.Name {
+						t.Errorf("Expected pod %s to have a controller reference to %s", pod.Name, rs.Name)
+					}
+				}
+
+				for _, name := range tc.expectedSurvive {
+					if _, err := podRegistry.GetPod(ctx, api.DefaultNamespace, name); err != nil {
+						t.Errorf("Expected pod %s to survive: %v", name, err)
+					}
+				}
+				for _, name := range tc.expectedGone {
+					if _, err := podRegistry.GetPod(ctx, api.DefaultNamespace, name); err == nil {
+						t.Errorf("Expected pod %s to be deleted", name)
+					}
+				}
+
+				if foreign, err := podRegistry.GetPod(ctx, api.DefaultNamespace, "foreign"); err == nil {
+					if ref := foreign.GetControllerOf(); ref == nil || ref.Name != "other-rs" {
+						t.Errorf("Expected foreign pod to keep its controller, got %v", ref)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestReconcile_RefusesReplicasAboveCap(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(etcdStorage)
+		podRegistry := registry.NewPodRegistry(etcdStorage)
+		ctx := context.Background()
+
+		rsc, err := NewReplicaSetControllerWithOptions(replicaSetRegistry, podRegistry, Options{
+			ResyncPeriod: MinResyncPeriod,
+			Workers:      1,
+			MaxReplicas:  5,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create controller: %v", err)
+		}
+
+		// Stored before the cap was lowered
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "huge-rs"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 6,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+
+		if err := rsc.Reconcile(ctx, rs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		pods, err := podRegistry.ListPods(ctx, "")
+		if err != nil {
+			t.Fatalf("Failed to list pods: %v", err)
+		}
+		if len(pods) != 0 {
+			t.Errorf("Expected no pods to be created, got %d", len(pods))
+		}
+
+		stored, err := replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
+		if err != nil {
+			t.Fatalf("Failed to get ReplicaSet: %v", err)
+		}
+		condition := stored.Status.GetCondition(api.ReplicaSetReplicaFailure)
+		if condition == nil || condition.Status != api.ConditionTrue || condition.Reason != "TooManyReplicas" {
+			t.Fatalf("Expected a TooManyReplicas condition, got %+v", stored.Status.Conditions)
+		}
+
+		// Bringing the spec back under the cap clears the condition
+		stored.Spec.Replicas = 2
+		if err := replicaSetRegistry.Update(ctx, stored); err != nil {
+			t.Fatalf("Failed to update ReplicaSet: %v", err)
+		}
+		if err := rsc.Reconcile(ctx, stored); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		stored, err = replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
+		if err != nil {
+			t.Fatalf("Failed to get ReplicaSet: %v", err)
+		}
+		if stored.Status.GetCondition(api.ReplicaSetReplicaFailure) != nil {
+			t.Errorf("Expected the condition to be cleared, got %+v", stored.Status.Conditions)
+		}
+	})
+}
+
+func TestReplicaSetController_SurvivesStorageFailures(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		chaos := storage.NewChaosStorage(storage.NewEtcdStorage(etcdServer), nil)
+		replicaSetRegistry := registry.NewReplicaSetRegistry(chaos)
+		podRegistry := registry.NewPodRegistry(chaos)
+		rsc := NewReplicaSetController(replicaSetRegistry, podRegistry)
+		ctx := context.Background()
+
+		rs := &api.ReplicaSet{
+			ObjectMeta: api.ObjectMeta{Name: "flaky"},
+			Spec: api.ReplicaSetSpec{
+				Replicas: 3,
+				Template: api.PodTemplateSpec{
+					Spec: api.PodSpec{Containers: []api.Container{{Name: "test-container", Image: "nginx"}}},
+				},
+			},
+		}
+		if err := replicaSetRegistry.Create(ctx, rs); err != nil {
+			t.Fatalf("Failed to create ReplicaSet: %v", err)
+		}
+
+		// The pods are checked past the faults
+		pods := registry.NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+
+		// runPasses reconciles until the ReplicaSet has want pods and goes on for a few more passes, which
+		// would create or delete pods they should not
+		runPasses := func(want int) {
+			t.Helper()
+			var listed []*api.Pod
+			for i := 0; i < 20; i++ {
+				_ = rsc.Run(ctx)
+				var err error
+				if listed, err = pods.ListPods(ctx, ""); err != nil {
+					t.Fatalf("Failed to list pods: %v", err)
+				}
+				if len(listed) > int(rs.Spec.Replicas) {
+					t.Fatalf("Expected at most %d pods, got %d", rs.Spec.Replicas, len(listed))
+				}
+				if len(listed) == want && i >= 5 {
+					return
+				}
+			}
+			t.Fatalf("Expected %d pods, got %d", want, len(listed))
+		}
+
+		// Listing and creating pods and updating the status fail now and then while scaling up
+		chaos.SetPolicy(storage.Combine(
+			storage.FailNth(storage.Match{Op: storage.OpList, Prefix: "/pods/"}, 1, storage.ErrEtcdClient),
+			storage.Script(storage.Match{Op: storage.OpCreate, Prefix: "/pods/"},
+				storage.Fault{}, storage.Fault{Err: storage.ErrEtcdClient}, storage.Fault{Err: storage.ErrEtcdClient}),
+			storage.FailNth(storage.Match{Op: storage.OpUpdate, Prefix: "/replicasets"}, 1, storage.ErrConflict),
+		))
+		runPasses(3)
+		if injected := injectedCalls(chaos); injected != 4 {
+			t.Errorf("Expected all 4 faults to be injected, got %d", injected)
+		}
+
+		// Deleting pods fails while scaling down
+		chaos.SetPolicy(storage.FailRandomly(storage.Match{Op: storage.OpDelete, Prefix: "/pods/"}, 0.5, storage.ErrEtcdClient, 1))
+		scaled, err := replicaSetRegistry.Get(ctx, rs.Namespace, rs.Name)
+		if err != nil {
+			t.Fatalf("Failed to get ReplicaSet: %v", err)
+		}
+		scaled.Spec.Replicas = 1
+		if err := replicaSetRegistry.Update(ctx, scaled); err != nil {
+			t.Fatalf("Failed to scale ReplicaSet: %v", err)
+		}
+		runPasses(1)
+	})
+}
+
+// injectedCalls counts the calls chaos failed on purpose
+func injectedCalls(chaos *storage.ChaosStorage) int {
+	injected := 0
+	for _, call := range chaos.Calls() {
+		if call.Injected {
+			injected++
+		}
+	}
+	return injected
+}
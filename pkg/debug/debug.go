@@ -0,0 +1,134 @@
+package debug
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"gokube/pkg/logging"
+)
+
+var ErrInvalidOptions = errors.New("invalid debug options")
+
+// Options configures the debug listener of a binary, which serves profiles and runtime stats of the process.
+// The endpoints are not authenticated, so they are off by default and served on a listener of their own,
+// never on the API port.
+type Options struct {
+	// EnablePprof serves /debug/pprof and /debug/vars on Address
+	EnablePprof bool
+	// Address is the address of the debug listener, by default on the loopback interface only
+	Address string
+}
+
+// DefaultOptions disables the debug endpoints, with the listener on the given address once they are enabled
+func DefaultOptions(address string) Options {
+	return Options{Address: address}
+}
+
+// AddFlags registers --enable-pprof and --debug-address, defaulting to the current options
+func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.EnablePprof, "enable-pprof", o.EnablePprof, "Serve pprof profiles and runtime stats on --debug-address")
+	flags.StringVar(&o.Address, "debug-address", o.Address, "The address of the listener of the debug endpoints")
+}
+
+// Validate checks the address when the endpoints are enabled
+func (o Options) Validate() error {
+	if !o.EnablePprof {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(o.Address); err != nil {
+		return fmt.Errorf("%w: invalid debug address %q: %v", ErrInvalidOptions, o.Address, err)
+	}
+	return nil
+}
+
+// ValidateSeparateFrom checks that the debug listener does not share the port of address, such as the API
+// port, which would expose the unauthenticated endpoints
+func (o Options) ValidateSeparateFrom(address string) error {
+	if !o.EnablePprof {
+		return nil
+	}
+	_, debugPort, err := net.SplitHostPort(o.Address)
+	if err != nil {
+		return fmt.Errorf("%w: invalid debug address %q: %v", ErrInvalidOptions, o.Address, err)
+	}
+	if _, port, err := net.SplitHostPort(address); err == nil && port == debugPort {
+		return fmt.Errorf("%w: the debug address %q must not share the port of %q", ErrInvalidOptions, o.Address, address)
+	}
+	return nil
+}
+
+// NewHandler serves /healthz and, if pprof is enabled, /debug/pprof and /debug/vars. Other paths are not found.
+func NewHandler(options Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	if !options.EnablePprof {
+		return mux
+	}
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	publishRuntimeStats()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Serve serves NewHandler on the debug address in the background, until the returned server is shut down.
+// The Addr of the server is the address listened on. It returns nil if pprof is disabled, as there is
+// nothing to debug then.
+func Serve(options Options) (*http.Server, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	if !options.EnablePprof {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", options.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on the debug address: %w", err)
+	}
+	server := &http.Server{Addr: listener.Addr().String(), Handler: NewHandler(options)}
+	logger := logging.Component("debug")
+	go func() {
+		logger.Info("Serving debug endpoints", "address", server.Addr)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Debug server stopped", logging.Err(err))
+		}
+	}()
+	return server, nil
+}
+
+var (
+	started            = time.Now()
+	publishRuntimeOnce sync.Once
+)
+
+// publishRuntimeStats adds the runtime stats to /debug/vars, next to the memstats and cmdline of expvar.
+// Variables can only be published once per process.
+func publishRuntimeStats() {
+	publishRuntimeOnce.Do(func() {
+		expvar.Publish("runtime", expvar.Func(func() any {
+			return map[string]any{
+				"goroutines": runtime.NumGoroutine(),
+				"gomaxprocs": runtime.GOMAXPROCS(0),
+				"numCPU":     runtime.NumCPU(),
+				"goVersion":  runtime.Version(),
+				"uptime":     time.Since(started).Round(time.Second).String(),
+			}
+		}))
+	})
+}
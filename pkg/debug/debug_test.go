@@ -0,0 +1,85 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	paths := []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline", "/debug/vars"}
+
+	t.Run("should serve the debug endpoints when pprof is enabled", func(t *testing.T) {
+		handler := NewHandler(Options{EnablePprof: true})
+		for _, path := range append(paths, "/healthz") {
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+			assert.Equal(t, http.StatusOK, resp.Code, path)
+		}
+	})
+
+	t.Run("should report runtime stats in the vars", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		NewHandler(Options{EnablePprof: true}).ServeHTTP(resp, httptest.NewRequest("GET", "/debug/vars", nil))
+
+		var vars struct {
+			Runtime map[string]any `json:"runtime"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &vars))
+		assert.Contains(t, vars.Runtime, "goroutines")
+		assert.Contains(t, vars.Runtime, "uptime")
+	})
+
+	t.Run("should not find the debug endpoints when pprof is disabled", func(t *testing.T) {
+		handler := NewHandler(Options{})
+		for _, path := range paths {
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest("GET", path, nil))
+			assert.Equal(t, http.StatusNotFound, resp.Code, path)
+		}
+	})
+}
+
+func TestServe(t *testing.T) {
+	t.Run("should not listen when pprof is disabled", func(t *testing.T) {
+		server, err := Serve(DefaultOptions("127.0.0.1:0"))
+		require.NoError(t, err)
+		assert.Nil(t, server)
+	})
+
+	t.Run("should serve profiles on the debug address", func(t *testing.T) {
+		options := Options{EnablePprof: true, Address: "127.0.0.1:0"}
+		server, err := Serve(options)
+		require.NoError(t, err)
+		require.NotNil(t, server)
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NoError(t, server.Shutdown(ctx))
+		})
+
+		resp, err := http.Get("http://" + server.Addr + "/debug/pprof/heap")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		_, err = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.NoError(t, Options{Address: "bad"}.Validate(), "the address is not used while pprof is disabled")
+	assert.ErrorIs(t, Options{EnablePprof: true, Address: "bad"}.Validate(), ErrInvalidOptions)
+
+	options := Options{EnablePprof: true, Address: "127.0.0.1:8080"}
+	assert.ErrorIs(t, options.ValidateSeparateFrom(":8080"), ErrInvalidOptions)
+	assert.NoError(t, options.ValidateSeparateFrom(":8081"))
+	assert.NoError(t, Options{Address: "127.0.0.1:8080"}.ValidateSeparateFrom(":8080"))
+}
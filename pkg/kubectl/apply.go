@@ -0,0 +1,193 @@
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/manifest"
+)
+
+var (
+	ErrInvalidManifest = manifest.ErrInvalidManifest
+	ErrUnknownKind     = manifest.ErrUnknownKind
+)
+
+func newApplyCommand(o *options) *cobra.Command {
+	var filenames []string
+	cmd := &cobra.Command{
+		Use:   "apply -f (FILENAME|DIRECTORY|-)",
+		Short: "Create or update objects from JSON or YAML manifests",
+		Example: `  gokubectl apply -f nginx-rs.yaml
+  cat pod.json | gokubectl apply -f -`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var manifests []manifest.Manifest
+			for _, filename := range filenames {
+				read, err := o.readManifests(filename)
+				if err != nil {
+					return err
+				}
+				manifests = append(manifests, read...)
+			}
+			return o.apply(cmd.Context(), manifests)
+		},
+	}
+	cmd.Flags().StringSliceVarP(&filenames, "filename", "f", nil, "The manifest file or directory of manifests to apply; - reads standard input")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+// readManifests reads the manifests of a file, of the JSON and YAML files of a directory, or of standard
+// input when filename is -. Every manifest is parsed before anything is applied.
+func (o *options) readManifests(filename string) ([]manifest.Manifest, error) {
+	if filename == "-" {
+		data, err := io.ReadAll(o.in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read standard input: %w", err)
+		}
+		return manifest.Parse("<stdin>", data)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{filename}
+	if info.IsDir() {
+		if paths, err = manifest.Files(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	var manifests []manifest.Manifest
+	for _, path := range paths {
+		parsed, err := manifest.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, parsed...)
+	}
+	return manifests, nil
+}
+
+// apply creates the objects of the manifests that don't exist and updates the ones that do, printing what
+// happened to each one, e.g. pod/web created
+func (o *options) apply(ctx context.Context, manifests []manifest.Manifest) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range manifests {
+		var result string
+		var err error
+		switch object := m.Object.(type) {
+		case *api.Pod:
+			o.setNamespace(&object.ObjectMeta)
+			pods := c.PodsIn(namespaceOf(object.Namespace))
+			result, err = applyObject(ctx, object.Name, object, pods.Get, pods.Create, pods.Update, mergePod)
+		case *api.Node:
+			result, err = applyObject(ctx, object.Name, object, c.Nodes().Get, c.Nodes().Create, c.Nodes().Update, mergeNode)
+		case *api.ReplicaSet:
+			o.setNamespace(&object.ObjectMeta)
+			replicaSets := c.ReplicaSetsIn(namespaceOf(object.Namespace))
+			result, err = applyObject(ctx, object.Name, object, replicaSets.Get, replicaSets.Create, replicaSets.Update, mergeReplicaSet)
+		}
+		ref := strings.ToLower(m.Kind) + "/" + m.ObjectMeta().Name
+		if err != nil {
+			return fmt.Errorf("failed to apply %s from %s: %w", ref, m.Source, err)
+		}
+		fmt.Fprintf(o.out, "%s %s\n", ref, result)
+	}
+	return nil
+}
+
+// setNamespace puts an object without a namespace into the --namespace namespace
+func (o *options) setNamespace(meta *api.ObjectMeta) {
+	if meta.Namespace == "" && namespaceOf(o.namespace) != DefaultNamespace {
+		meta.Namespace = o.namespace
+	}
+}
+
+// applyObject creates the object if it doesn't exist. Otherwise merge copies the fields set by the cluster
+// from the live object, and the object is updated unless that leaves nothing to change. It returns created,
+// configured or unchanged.
+func applyObject[T any](ctx context.Context, name string, object *T,
+	get func(context.Context, string) (*T, error),
+	create, update func(context.Context, *T) (*T, error),
+	merge func(object, live *T)) (string, error) {
+	live, err := get(ctx, name)
+	if client.IsNotFound(err) {
+		if _, err := create(ctx, object); err != nil {
+			return "", err
+		}
+		return "created", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	merge(object, live)
+	if reflect.DeepEqual(object, live) {
+		return "unchanged", nil
+	}
+	if _, err := update(ctx, object); err != nil {
+		return "", err
+	}
+	return "configured", nil
+}
+
+// mergeMeta keeps the metadata the cluster sets, including the trace ID, and the owners of objects whose
+// manifest names none
+func mergeMeta(meta, live *api.ObjectMeta) {
+	meta.UID = live.UID
+	meta.ResourceVersion = live.ResourceVersion
+	meta.CreationTimestamp = live.CreationTimestamp
+	if traceID := live.TraceID(); traceID != "" && meta.TraceID() == "" {
+		meta.SetTraceID(traceID)
+	}
+	if meta.OwnerReferences == nil {
+		meta.OwnerReferences = live.OwnerReferences
+	}
+}
+
+// mergePod keeps the status of the pod, the node it was scheduled to and the defaults the API server set
+func mergePod(pod, live *api.Pod) {
+	mergeMeta(&pod.ObjectMeta, &live.ObjectMeta)
+	if pod.Namespace == "" {
+		pod.Namespace = live.Namespace
+	}
+	if pod.Spec.TerminationGracePeriodSeconds == nil {
+		pod.Spec.TerminationGracePeriodSeconds = live.Spec.TerminationGracePeriodSeconds
+	}
+	if pod.NodeName == "" {
+		pod.NodeName = live.NodeName
+	}
+	pod.Status = live.Status
+	pod.ContainerStatuses = live.ContainerStatuses
+}
+
+// mergeNode keeps what the kubelet reports about the node. Its UID is the machine ID the kubelet registered.
+func mergeNode(node, live *api.Node) {
+	mergeMeta(&node.ObjectMeta, &live.ObjectMeta)
+	node.Status = live.Status
+	node.LastHeartbeatTime = live.LastHeartbeatTime
+	node.Capacity = live.Capacity
+	node.Conditions = live.Conditions
+}
+
+func mergeReplicaSet(rs, live *api.ReplicaSet) {
+	mergeMeta(&rs.ObjectMeta, &live.ObjectMeta)
+	if rs.Namespace == "" {
+		rs.Namespace = live.Namespace
+	}
+	rs.Status = live.Status
+}
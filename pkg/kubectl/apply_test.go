@@ -0,0 +1,149 @@
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+const webManifest = `# The web tier
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  containers:
+  - name: nginx
+    image: nginx:latest
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: nginx-rs
+spec:
+  replicas: %d
+  selector:
+    app: nginx
+  template:
+    metadata:
+      name: nginx
+      labels:
+        app: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:latest
+`
+
+func writeManifest(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestApply(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		ctx := context.Background()
+		path := writeManifest(t, t.TempDir(), "web.yaml", fmt.Sprintf(webManifest, 2))
+
+		out, _, err := run(t, address, "apply", "-f", path)
+		require.NoError(t, err)
+		assert.Equal(t, "pod/web created\nreplicaset/nginx-rs created\n", out)
+		pod, err := c.Pods().Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "web"}, pod.Labels)
+
+		// The cluster works on the objects in the meantime
+		pod.NodeName, pod.Status = "node-1", api.PodRunning
+		_, err = c.Pods().Update(ctx, pod)
+		require.NoError(t, err)
+		rs, err := c.ReplicaSets().Get(ctx, "nginx-rs")
+		require.NoError(t, err)
+		rs.Status = api.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 2}
+		_, err = c.ReplicaSets().Update(ctx, rs)
+		require.NoError(t, err)
+
+		out, _, err = run(t, address, "apply", "-f", path)
+		require.NoError(t, err)
+		assert.Equal(t, "pod/web unchanged\nreplicaset/nginx-rs unchanged\n", out, "applying the same manifest again changes nothing")
+
+		writeManifest(t, filepath.Dir(path), "web.yaml", fmt.Sprintf(webManifest, 5))
+		out, _, err = run(t, address, "apply", "-f", filepath.Dir(path))
+		require.NoError(t, err)
+		assert.Equal(t, "pod/web unchanged\nreplicaset/nginx-rs configured\n", out)
+
+		rs, err = c.ReplicaSets().Get(ctx, "nginx-rs")
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), rs.Spec.Replicas)
+		assert.Equal(t, api.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 2}, rs.Status, "the status set by the cluster is kept")
+		pod, err = c.Pods().Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, "node-1", pod.NodeName)
+		assert.Equal(t, api.PodRunning, pod.Status)
+	})
+}
+
+func TestApplyFromStandardInput(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		manifest := `{"kind": "Node", "metadata": {"name": "node-1"}, "spec": {"unschedulable": true}}`
+		out, _, err := runWithInput(t, address, manifest, "apply", "-f", "-", "-n", "staging")
+		require.NoError(t, err)
+		assert.Equal(t, "node/node-1 created\n", out)
+		node, err := c.Nodes().Get(context.Background(), "node-1")
+		require.NoError(t, err)
+		assert.True(t, node.Spec.Unschedulable)
+		assert.Empty(t, node.Namespace, "nodes are not namespaced")
+
+		manifest = `{"kind": "Pod", "metadata": {"name": "cache"}, "spec": {"containers": [{"name": "redis", "image": "redis"}]}}`
+		_, _, err = runWithInput(t, address, manifest, "apply", "-f", "-", "-n", "staging")
+		require.NoError(t, err)
+		pod, err := c.PodsIn("staging").Get(context.Background(), "cache")
+		require.NoError(t, err)
+		assert.Equal(t, "staging", pod.Namespace)
+	})
+}
+
+func TestApplyRejectsInvalidManifests(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		dir := t.TempDir()
+		pod := "kind: Pod\nmetadata:\n  name: web\nspec:\n  containers:\n  - name: nginx\n    image: nginx\n"
+
+		for _, test := range []struct {
+			manifest string
+			expected error
+			message  string
+		}{
+			{pod + "---\nkind: Service\nmetadata:\n  name: web\n", ErrUnknownKind, `document 2: unknown kind "Service"`},
+			{"---\n" + pod + "---\n" + pod + "  replicas: many\n", ErrInvalidManifest, "document 3: invalid manifest: Pod"},
+			{pod + "---\nkind: Pod\nmetadata:\n  name: db\nspec:\n  containrs: []\n", ErrInvalidManifest, "document 2: invalid manifest: Pod"},
+			{"metadata:\n  name: web\n", ErrInvalidManifest, "document 1: invalid manifest: kind is not set"},
+			{"kind: Pod\nspec: {}\n", ErrInvalidManifest, "document 1: invalid manifest: Pod: metadata.name is not set"},
+			{"kind: Pod\n  metadata: [\n", ErrInvalidManifest, "document 1: invalid manifest"},
+		} {
+			path := writeManifest(t, dir, "invalid.yaml", test.manifest)
+			_, _, err := run(t, address, "apply", "-f", path)
+			assert.ErrorIs(t, err, test.expected, test.manifest)
+			assert.ErrorContains(t, err, path+", "+test.message)
+		}
+
+		pods, err := c.Pods().List(context.Background(), client.PodListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, pods, "nothing is applied when a manifest is invalid")
+
+		// The API server rejects a pod without containers
+		path := writeManifest(t, dir, "empty.json", `{"kind": "Pod", "metadata": {"name": "empty"}, "spec": {}}`)
+		_, _, err = run(t, address, "apply", "-f", path)
+		require.True(t, client.IsInvalid(err), "a pod without containers is invalid: %v", err)
+		assert.Regexp(t, `^Error from server \(BadRequest\): failed to apply pod/empty from .*empty.json, document 1: `, FormatError(err))
+	})
+}
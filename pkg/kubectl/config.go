@@ -0,0 +1,178 @@
+package kubectl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// newConfigCommand returns the config command, which edits the config file. Its subcommands work on the file
+// alone, so a current context that is broken can still be fixed.
+func newConfigCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Edit the clusters, credentials and contexts of the gokubectl config file",
+		// The connection settings are not resolved, as the config subcommands do not talk to a cluster
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+	cmd.AddCommand(newUseContextCommand(o), newCurrentContextCommand(o), newSetClusterCommand(o),
+		newSetCredentialsCommand(o), newSetContextCommand(o))
+	return cmd
+}
+
+func newUseContextCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:     "use-context NAME",
+		Short:   "Make a context the current one",
+		Example: `  gokubectl config use-context staging`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.editConfig(func(config *Config) (string, error) {
+				if _, ok := config.Contexts[args[0]]; !ok {
+					return "", fmt.Errorf("%w: %s", ErrContextNotFound, args[0])
+				}
+				config.CurrentContext = args[0]
+				return fmt.Sprintf("Switched to context %q.", args[0]), nil
+			})
+		},
+	}
+}
+
+func newCurrentContextCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "current-context",
+		Short: "Print the current context",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := LoadConfig(o.configPath)
+			if err != nil {
+				return err
+			}
+			if config.CurrentContext == "" {
+				return errors.New("current-context is not set")
+			}
+			_, err = fmt.Fprintln(o.out, config.CurrentContext)
+			return err
+		},
+	}
+}
+
+func newSetClusterCommand(o *options) *cobra.Command {
+	var cluster Cluster
+	cmd := &cobra.Command{
+		Use:     "set-cluster NAME [--server=ADDRESS] [--certificate-authority=PATH]",
+		Short:   "Add a cluster, or change the settings given of an existing one",
+		Example: `  gokubectl config set-cluster prod --server=https://10.0.0.1:8080 --certificate-authority=ca.pem`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.editConfig(func(config *Config) (string, error) {
+				existing, ok := config.Clusters[args[0]]
+				if !ok {
+					existing = &Cluster{}
+				}
+				if cmd.Flags().Changed("server") {
+					existing.Server = cluster.Server
+				}
+				if cmd.Flags().Changed("certificate-authority") {
+					existing.CertificateAuthority = cluster.CertificateAuthority
+				}
+				if existing.Server == "" {
+					return "", fmt.Errorf("%w: cluster %s needs a --server", ErrInvalidConfig, args[0])
+				}
+				if config.Clusters == nil {
+					config.Clusters = make(map[string]*Cluster)
+				}
+				config.Clusters[args[0]] = existing
+				return fmt.Sprintf("Cluster %q set.", args[0]), nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&cluster.Server, "server", "", "The address of the API server")
+	cmd.Flags().StringVar(&cluster.CertificateAuthority, "certificate-authority", "", "The PEM file of the CA of the API server")
+	return cmd
+}
+
+func newSetCredentialsCommand(o *options) *cobra.Command {
+	var credentials Credentials
+	cmd := &cobra.Command{
+		Use:     "set-credentials NAME [--token=TOKEN]",
+		Short:   "Add credentials, or change the settings given of existing ones",
+		Example: `  gokubectl config set-credentials admin --token=s3cr3t`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.editConfig(func(config *Config) (string, error) {
+				existing, ok := config.Credentials[args[0]]
+				if !ok {
+					existing = &Credentials{}
+				}
+				if cmd.Flags().Changed("token") {
+					existing.Token = credentials.Token
+				}
+				if config.Credentials == nil {
+					config.Credentials = make(map[string]*Credentials)
+				}
+				config.Credentials[args[0]] = existing
+				return fmt.Sprintf("Credentials %q set.", args[0]), nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&credentials.Token, "token", "", "The bearer token to authenticate with")
+	return cmd
+}
+
+func newSetContextCommand(o *options) *cobra.Command {
+	var settings Context
+	cmd := &cobra.Command{
+		Use:     "set-context NAME [--cluster=NAME] [--credentials=NAME] [--namespace=NAMESPACE]",
+		Short:   "Add a context, or change the settings given of an existing one",
+		Example: `  gokubectl config set-context prod --cluster=prod --credentials=admin --namespace=web`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.editConfig(func(config *Config) (string, error) {
+				existing, ok := config.Contexts[args[0]]
+				if !ok {
+					existing = &Context{}
+				}
+				if cmd.Flags().Changed("cluster") {
+					existing.Cluster = settings.Cluster
+				}
+				if cmd.Flags().Changed("credentials") {
+					existing.Credentials = settings.Credentials
+				}
+				// --namespace is the persistent flag of the root command, which the context takes as its own
+				if cmd.Flags().Changed("namespace") {
+					existing.Namespace = o.namespace
+				}
+				if existing.Cluster == "" {
+					return "", fmt.Errorf("%w: context %s needs a --cluster", ErrInvalidConfig, args[0])
+				}
+				if config.Contexts == nil {
+					config.Contexts = make(map[string]*Context)
+				}
+				config.Contexts[args[0]] = existing
+				return fmt.Sprintf("Context %q set.", args[0]), nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&settings.Cluster, "cluster", "", "The cluster of the context")
+	cmd.Flags().StringVar(&settings.Credentials, "credentials", "", "The credentials used for the cluster")
+	return cmd
+}
+
+// editConfig loads the config file, applies edit to it, saves it and prints the message edit returned
+func (o *options) editConfig(edit func(config *Config) (string, error)) error {
+	config, err := LoadConfig(o.configPath)
+	if err != nil {
+		return err
+	}
+	message, err := edit(config)
+	if err != nil {
+		return err
+	}
+	if err := config.Save(o.configPath); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(o.out, message)
+	return err
+}
@@ -0,0 +1,185 @@
+package kubectl
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runWithConfig runs gokubectl with args and the config file at path, without a --server flag
+func runWithConfig(t *testing.T, path string, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	cmd := newCommand(&options{in: strings.NewReader(""), out: &out, errOut: &errOut, now: func() time.Time { return testNow },
+		configPath: path})
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestConfig_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".gokube", "config")
+	config := &Config{
+		CurrentContext: "prod",
+		Clusters: map[string]*Cluster{
+			"prod":  {Server: "https://10.0.0.1:8080", CertificateAuthority: "/etc/gokube/ca.pem"},
+			"local": {Server: "localhost:8080"},
+		},
+		Credentials: map[string]*Credentials{"admin": {Token: "s3cr3t"}},
+		Contexts: map[string]*Context{
+			"prod":  {Cluster: "prod", Credentials: "admin", Namespace: "web"},
+			"local": {Cluster: "local"},
+		},
+	}
+
+	require.NoError(t, config.Save(path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the config holds tokens")
+	loaded, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, config, loaded)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "current-context: prod\n")
+	assert.Contains(t, string(data), "certificate-authority: /etc/gokube/ca.pem\n")
+
+	missing, err := LoadConfig(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Equal(t, &Config{}, missing)
+
+	require.NoError(t, os.WriteFile(path, []byte("current-context: prod\nusers: {}\n"), 0o600))
+	_, err = LoadConfig(path)
+	assert.ErrorIs(t, err, ErrInvalidConfig, "unknown fields are rejected")
+}
+
+func TestConfigCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+
+	_, err := runWithConfig(t, path, "config", "current-context")
+	assert.ErrorContains(t, err, "current-context is not set")
+	_, err = runWithConfig(t, path, "config", "set-cluster", "prod")
+	assert.ErrorIs(t, err, ErrInvalidConfig, "a new cluster needs a server")
+	_, err = runWithConfig(t, path, "config", "set-context", "prod", "--credentials=admin")
+	assert.ErrorIs(t, err, ErrInvalidConfig, "a new context needs a cluster")
+
+	out, err := runWithConfig(t, path, "config", "set-cluster", "prod", "--server=https://10.0.0.1:8080")
+	require.NoError(t, err)
+	assert.Equal(t, "Cluster \"prod\" set.\n", out)
+	_, err = runWithConfig(t, path, "config", "set-cluster", "prod", "--certificate-authority=/etc/gokube/ca.pem")
+	require.NoError(t, err)
+	_, err = runWithConfig(t, path, "config", "set-credentials", "admin", "--token=s3cr3t")
+	require.NoError(t, err)
+	_, err = runWithConfig(t, path, "config", "set-context", "prod", "--cluster=prod", "--credentials=admin", "-n", "web")
+	require.NoError(t, err)
+
+	_, err = runWithConfig(t, path, "config", "use-context", "staging")
+	assert.ErrorIs(t, err, ErrContextNotFound)
+	out, err = runWithConfig(t, path, "config", "use-context", "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "Switched to context \"prod\".\n", out)
+	out, err = runWithConfig(t, path, "config", "current-context")
+	require.NoError(t, err)
+	assert.Equal(t, "prod\n", out)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{
+		CurrentContext: "prod",
+		Clusters:       map[string]*Cluster{"prod": {Server: "https://10.0.0.1:8080", CertificateAuthority: "/etc/gokube/ca.pem"}},
+		Credentials:    map[string]*Credentials{"admin": {Token: "s3cr3t"}},
+		Contexts:       map[string]*Context{"prod": {Cluster: "prod", Credentials: "admin", Namespace: "web"}},
+	}, config, "changing a setting keeps the others")
+
+	// A current context whose cluster is gone fails the commands talking to a cluster, but can be fixed
+	config.Contexts["broken"] = &Context{Cluster: "gone"}
+	config.CurrentContext = "broken"
+	require.NoError(t, config.Save(path))
+	_, err = runWithConfig(t, path, "get", "pods")
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = runWithConfig(t, path, "config", "use-context", "prod")
+	require.NoError(t, err)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	config := &Config{
+		CurrentContext: "prod",
+		Clusters: map[string]*Cluster{
+			"prod":    {Server: "prod:8080", CertificateAuthority: "/etc/gokube/prod-ca.pem"},
+			"staging": {Server: "staging:8080"},
+		},
+		Credentials: map[string]*Credentials{"admin": {Token: "admin-token"}},
+		Contexts: map[string]*Context{
+			"prod":    {Cluster: "prod", Credentials: "admin", Namespace: "web"},
+			"staging": {Cluster: "staging"},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, config.Save(path))
+
+	testCases := []struct {
+		name    string
+		options options
+		want    options
+	}{
+		{name: "defaults without a config file", options: options{},
+			want: options{server: DefaultServer, namespace: DefaultNamespace}},
+		{name: "current context", options: options{configPath: path},
+			want: options{server: "prod:8080", namespace: "web", token: "admin-token", certificateAuthority: "/etc/gokube/prod-ca.pem"}},
+		{name: "flags over the current context", options: options{configPath: path, namespace: "staging", token: "mine"},
+			want: options{server: "prod:8080", namespace: "staging", token: "mine", certificateAuthority: "/etc/gokube/prod-ca.pem"}},
+		{name: "server flag without the CA of the context", options: options{configPath: path, server: "other:8080"},
+			want: options{server: "other:8080", namespace: "web", token: "admin-token"}},
+		{name: "context flag", options: options{configPath: path, context: "staging"},
+			want: options{server: "staging:8080", namespace: DefaultNamespace}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := tc.options
+			require.NoError(t, o.resolve())
+			got := options{server: o.server, namespace: o.namespace, token: o.token, certificateAuthority: o.certificateAuthority}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("unknown context", func(t *testing.T) {
+		o := options{configPath: path, context: "dev"}
+		assert.ErrorIs(t, o.resolve(), ErrContextNotFound)
+	})
+}
+
+func TestCommandsUseTheContext(t *testing.T) {
+	var mutex sync.Mutex
+	var authorizations []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mutex.Unlock()
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer apiServer.Close()
+
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, (&Config{
+		CurrentContext: "test",
+		Clusters:       map[string]*Cluster{"test": {Server: apiServer.URL}},
+		Credentials:    map[string]*Credentials{"admin": {Token: "s3cr3t"}},
+		Contexts:       map[string]*Context{"test": {Cluster: "test", Credentials: "admin"}},
+	}).Save(path))
+
+	_, err := runWithConfig(t, path, "get", "nodes")
+	require.NoError(t, err)
+	_, err = runWithConfig(t, path, "get", "nodes", "--token", "override")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer s3cr3t", "Bearer override"}, authorizations)
+}
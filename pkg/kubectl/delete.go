@@ -0,0 +1,276 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+var (
+	ErrInvalidSelector = api.ErrInvalidSelector
+	ErrDeleteFailed    = errors.New("failed to delete some objects")
+)
+
+// deleteOptions are the flags of the delete command
+type deleteOptions struct {
+	filenames []string
+	selector  string
+	// cascade deletes the pods of a ReplicaSet with it; otherwise they are orphaned
+	cascade        bool
+	ignoreNotFound bool
+	// force removes pods at once rather than waiting for their kubelet to stop their containers
+	force bool
+}
+
+// deleteTarget is an object to delete
+type deleteTarget struct {
+	r    resource
+	name string
+	// namespace is the namespace of the object, or empty for the --namespace namespace
+	namespace string
+}
+
+func newDeleteCommand(o *options) *cobra.Command {
+	d := &deleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete ((pods|nodes|replicasets) (name ...|-l selector) | -f FILENAME)",
+		Short: "Delete objects by name, by label selector or by manifest",
+		Example: `  gokubectl delete pod web
+  gokubectl delete replicaset nginx-rs --cascade=false
+  gokubectl delete pods -l app=web
+  gokubectl delete pod web --force
+  gokubectl delete -f nginx-rs.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := o.deleteTargets(cmd.Context(), d, args)
+			if err != nil {
+				return err
+			}
+			return o.delete(cmd.Context(), d, targets)
+		},
+	}
+	cmd.Flags().StringSliceVarP(&d.filenames, "filename", "f", nil, "The manifest file or directory of manifests whose objects to delete; - reads standard input")
+	cmd.Flags().StringVarP(&d.selector, "selector", "l", "", "Delete the objects with these labels, e.g. app=web,tier=frontend")
+	cmd.Flags().BoolVar(&d.cascade, "cascade", true, "Delete the pods of a ReplicaSet with it")
+	cmd.Flags().BoolVar(&d.ignoreNotFound, "ignore-not-found", false, "Only warn about objects that don't exist")
+	cmd.Flags().BoolVar(&d.force, "force", false, "Remove pods at once, without waiting for their containers to be stopped")
+	return cmd
+}
+
+// deleteTargets finds the objects named by the arguments, by a selector or by manifests
+func (o *options) deleteTargets(ctx context.Context, d *deleteOptions, args []string) ([]deleteTarget, error) {
+	if len(d.filenames) > 0 {
+		if len(args) > 0 || d.selector != "" {
+			return nil, errors.New("objects to delete are given either by -f or by resource, not both")
+		}
+		var targets []deleteTarget
+		for _, filename := range d.filenames {
+			manifests, err := o.readManifests(filename)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range manifests {
+				r, err := parseResource(m.Kind)
+				if err != nil {
+					return nil, err
+				}
+				meta := m.ObjectMeta()
+				targets = append(targets, deleteTarget{r: r, name: meta.Name, namespace: meta.Namespace})
+			}
+		}
+		return targets, nil
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("the resource to delete is required, e.g. gokubectl delete pod web")
+	}
+	r, err := parseResource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	names := args[1:]
+	switch {
+	case d.selector != "" && len(names) > 0:
+		return nil, errors.New("objects to delete are given either by name or by --selector, not both")
+	case d.selector == "" && len(names) == 0:
+		return nil, fmt.Errorf("the names of the %s to delete or a --selector is required", r.name)
+	case d.selector != "":
+		return o.selectTargets(ctx, r, d.selector)
+	}
+
+	targets := make([]deleteTarget, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, deleteTarget{r: r, name: name})
+	}
+	return targets, nil
+}
+
+// selectTargets lists the objects of the resource in the --namespace namespace whose labels match selector
+func (o *options) selectTargets(ctx context.Context, r resource, selector string) ([]deleteTarget, error) {
+	labels, err := api.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	c, err := o.client()
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []*api.ObjectMeta
+	switch r.name {
+	case podsResource.name:
+		pods, err := c.PodsIn(namespaceOf(o.namespace)).List(ctx, client.PodListOptions{LabelSelector: labels})
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			metas = append(metas, &pod.ObjectMeta)
+		}
+	case nodesResource.name:
+		nodes, err := c.Nodes().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			metas = append(metas, &node.ObjectMeta)
+		}
+	default:
+		replicaSets, err := c.ReplicaSetsIn(namespaceOf(o.namespace)).List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rs := range replicaSets {
+			metas = append(metas, &rs.ObjectMeta)
+		}
+	}
+
+	var targets []deleteTarget
+	for _, meta := range metas {
+		if r.namespaced && namespaceOf(meta.Namespace) != namespaceOf(o.namespace) {
+			continue
+		}
+		if api.SelectorMatches(labels, meta.Labels) {
+			targets = append(targets, deleteTarget{r: r, name: meta.Name, namespace: meta.Namespace})
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(o.errOut, "No resources found")
+	}
+	return targets, nil
+}
+
+// delete deletes the targets, printing a line for each deleted object. It carries on after a failure,
+// reporting it on errOut, and fails once every target was tried.
+func (o *options) delete(ctx context.Context, d *deleteOptions, targets []deleteTarget) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, target := range targets {
+		err := o.deleteObject(ctx, c, d, target)
+		switch {
+		case err == nil:
+		case d.ignoreNotFound && client.IsNotFound(err):
+			fmt.Fprintf(o.errOut, "Warning: %s %q not found\n", target.r.kind, target.name)
+		default:
+			failed++
+			fmt.Fprintln(o.errOut, FormatError(err))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d not deleted", ErrDeleteFailed, failed, len(targets))
+	}
+	return nil
+}
+
+func (o *options) deleteObject(ctx context.Context, c *client.Client, d *deleteOptions, target deleteTarget) error {
+	namespace := target.namespace
+	if namespace == "" {
+		namespace = o.namespace
+	}
+	switch target.r.name {
+	case podsResource.name:
+		pods := c.PodsIn(namespaceOf(namespace))
+		deletePod := pods.Delete
+		if d.force {
+			deletePod = pods.ForceDelete
+		}
+		if err := deletePod(ctx, target.name); err != nil {
+			return err
+		}
+	case nodesResource.name:
+		if err := c.Nodes().Delete(ctx, target.name); err != nil {
+			return err
+		}
+	default:
+		rs, err := c.ReplicaSetsIn(namespaceOf(namespace)).Get(ctx, target.name)
+		if err != nil {
+			return err
+		}
+		if err := o.deleteReplicaSet(ctx, c, rs, d.cascade); err != nil {
+			return err
+		}
+	}
+	o.printDeleted(target.r, target.name)
+	return nil
+}
+
+// deleteReplicaSet deletes rs. The API server doesn't delete the pods of a ReplicaSet, so with cascade the
+// ReplicaSet is scaled to zero first, for the controller to stop replacing pods, and its pods are deleted
+// before it. Otherwise the pods are orphaned: they lose their controller reference and can be adopted by
+// another ReplicaSet.
+func (o *options) deleteReplicaSet(ctx context.Context, c *client.Client, rs *api.ReplicaSet, cascade bool) error {
+	replicaSets := c.ReplicaSetsIn(namespaceOf(rs.Namespace))
+	if cascade && rs.Spec.Replicas != 0 {
+		rs.Spec.Replicas = 0
+		if _, err := replicaSets.Update(ctx, rs); err != nil {
+			return fmt.Errorf("failed to scale replicaset %q to zero: %w", rs.Name, err)
+		}
+	}
+
+	pods, err := c.PodsIn(namespaceOf(rs.Namespace)).List(ctx, client.PodListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		ref := pod.GetControllerOf()
+		if ref == nil || ref.Kind != api.KindReplicaSet || !api.IsOwnedBy(pod, &rs.ObjectMeta) {
+			continue
+		}
+
+		if cascade {
+			err := c.PodsIn(pod.Namespace).Delete(ctx, pod.Name)
+			if client.IsNotFound(err) {
+				// Already deleted by the controller scaling down
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to delete pod %q of replicaset %q: %w", pod.Name, rs.Name, err)
+			}
+			o.printDeleted(podsResource, pod.Name)
+			continue
+		}
+
+		owners := pod.OwnerReferences[:0]
+		for _, owner := range pod.OwnerReferences {
+			if !owner.Controller {
+				owners = append(owners, owner)
+			}
+		}
+		pod.OwnerReferences = owners
+		if _, err := c.Pods().Update(ctx, pod); err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("failed to orphan pod %q of replicaset %q: %w", pod.Name, rs.Name, err)
+		}
+	}
+	return replicaSets.Delete(ctx, rs.Name)
+}
+
+func (o *options) printDeleted(r resource, name string) {
+	fmt.Fprintf(o.out, "%s %q deleted\n", r.kind, name)
+}
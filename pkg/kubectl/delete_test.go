@@ -0,0 +1,156 @@
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+func createPod(t *testing.T, c *client.Client, name, namespace string, labels map[string]string, owners ...api.OwnerReference) {
+	t.Helper()
+	_, err := c.Pods().Create(context.Background(), &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, OwnerReferences: owners},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+	})
+	require.NoError(t, err)
+}
+
+func createReplicaSet(t *testing.T, c *client.Client, name string, replicas int32) *api.ReplicaSet {
+	t.Helper()
+	rs, err := c.ReplicaSets().Create(context.Background(), &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec: api.ReplicaSetSpec{
+			Replicas: replicas,
+			Selector: map[string]string{"app": name},
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{Name: name, Labels: map[string]string{"app": name}},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	return rs
+}
+
+func podNames(t *testing.T, c *client.Client) []string {
+	t.Helper()
+	pods, err := c.Pods().List(context.Background(), client.PodListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func TestDeleteByName(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		createPod(t, c, "web", "", nil)
+		createPod(t, c, "db", "", nil)
+		createPod(t, c, "cache", "staging", nil)
+
+		out, _, err := run(t, address, "delete", "pod", "web")
+		require.NoError(t, err)
+		assert.Equal(t, "pod \"web\" deleted\n", out)
+
+		out, errOut, err := run(t, address, "delete", "pods", "missing", "db", "cache")
+		assert.ErrorIs(t, err, ErrDeleteFailed, "deleting fails when any object could not be deleted")
+		assert.Equal(t, "pod \"db\" deleted\n", out, "the other objects are still deleted")
+		assert.Regexp(t, `^Error from server \(NotFound\): .*missing\nError from server \(NotFound\): pod not found: default/cache\n$`, errOut)
+		assert.Equal(t, []string{"cache"}, podNames(t, c))
+
+		out, errOut, err = run(t, address, "delete", "pod", "missing", "cache", "--ignore-not-found", "-n", "staging")
+		require.NoError(t, err)
+		assert.Equal(t, "pod \"cache\" deleted\n", out)
+		assert.Equal(t, "Warning: pod \"missing\" not found\n", errOut)
+
+		_, err = c.Nodes().Create(context.Background(), &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1"}, Status: api.NodeReady})
+		require.NoError(t, err)
+		out, _, err = run(t, address, "delete", "node", "node-1")
+		require.NoError(t, err)
+		assert.Equal(t, "node \"node-1\" deleted\n", out)
+	})
+}
+
+func TestDeleteBySelector(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		createPod(t, c, "web-1", "", map[string]string{"app": "web", "tier": "frontend"})
+		createPod(t, c, "web-2", "", map[string]string{"app": "web"})
+		createPod(t, c, "db", "", map[string]string{"app": "db"})
+		createPod(t, c, "web-staging", "staging", map[string]string{"app": "web"})
+
+		out, _, err := run(t, address, "delete", "pods", "-l", "app=web")
+		require.NoError(t, err)
+		assert.Equal(t, "pod \"web-1\" deleted\npod \"web-2\" deleted\n", out)
+		assert.ElementsMatch(t, []string{"db", "web-staging"}, podNames(t, c))
+
+		out, errOut, err := run(t, address, "delete", "pods", "-l", "app==db,tier=frontend")
+		require.NoError(t, err)
+		assert.Empty(t, out)
+		assert.Equal(t, "No resources found\n", errOut)
+
+		_, _, err = run(t, address, "delete", "pods", "db", "-l", "app=db")
+		assert.Error(t, err)
+		_, _, err = run(t, address, "delete", "pods")
+		assert.Error(t, err)
+		assert.ElementsMatch(t, []string{"db", "web-staging"}, podNames(t, c))
+	})
+}
+
+func TestDeleteReplicaSet(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		web := createReplicaSet(t, c, "web", 2)
+		createPod(t, c, "web-1", "", web.Labels, api.NewControllerRef(&web.ObjectMeta, api.KindReplicaSet))
+		createPod(t, c, "web-2", "", web.Labels, api.NewControllerRef(&web.ObjectMeta, api.KindReplicaSet))
+		// Named like a pod of the ReplicaSet, but not controlled by it
+		createPod(t, c, "web-standalone", "", nil)
+
+		out, _, err := run(t, address, "delete", "rs", "web")
+		require.NoError(t, err)
+		assert.Equal(t, "pod \"web-1\" deleted\npod \"web-2\" deleted\nreplicaset \"web\" deleted\n", out, "the pods are deleted first")
+		assert.Equal(t, []string{"web-standalone"}, podNames(t, c))
+		_, err = c.ReplicaSets().Get(context.Background(), "web")
+		assert.True(t, client.IsNotFound(err))
+
+		db := createReplicaSet(t, c, "db", 1)
+		createPod(t, c, "db-1", "", nil, api.NewControllerRef(&db.ObjectMeta, api.KindReplicaSet))
+		out, _, err = run(t, address, "delete", "replicaset", "db", "--cascade=false")
+		require.NoError(t, err)
+		assert.Equal(t, "replicaset \"db\" deleted\n", out)
+		pod, err := c.Pods().Get(context.Background(), "db-1")
+		require.NoError(t, err)
+		assert.Nil(t, pod.GetControllerOf(), "the orphaned pod can be adopted by another ReplicaSet")
+	})
+}
+
+func TestDeleteFromManifest(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		dir := t.TempDir()
+		path := writeManifest(t, dir, "web.yaml", fmt.Sprintf(webManifest, 1))
+		_, _, err := run(t, address, "apply", "-f", path)
+		require.NoError(t, err)
+		rs, err := c.ReplicaSets().Get(context.Background(), "nginx-rs")
+		require.NoError(t, err)
+		createPod(t, c, "nginx-rs-1", "", rs.Spec.Selector, api.NewControllerRef(&rs.ObjectMeta, api.KindReplicaSet))
+
+		out, _, err := run(t, address, "delete", "-f", filepath.Join(dir, "web.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "pod \"web\" deleted\npod \"nginx-rs-1\" deleted\nreplicaset \"nginx-rs\" deleted\n", out)
+		assert.Empty(t, podNames(t, c))
+
+		out, errOut, err := run(t, address, "delete", "-f", path, "--ignore-not-found")
+		require.NoError(t, err)
+		assert.Empty(t, out)
+		assert.Equal(t, "Warning: pod \"web\" not found\nWarning: replicaset \"nginx-rs\" not found\n", errOut)
+
+		_, _, err = run(t, address, "delete", "pods", "web", "-f", path)
+		assert.Error(t, err)
+	})
+}
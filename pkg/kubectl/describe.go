@@ -0,0 +1,244 @@
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+func newDescribeCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe (pod|node) NAME",
+		Short: "Show the details of an object and the events about it",
+		Example: `  gokubectl describe pod web
+  gokubectl describe node node-1`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := parseResource(args[0])
+			if err != nil {
+				return err
+			}
+			switch r.name {
+			case podsResource.name:
+				return o.describePod(cmd.Context(), args[1])
+			case nodesResource.name:
+				return o.describeNode(cmd.Context(), args[1])
+			default:
+				return fmt.Errorf("%w %q: only pods and nodes can be described", ErrUnknownResource, args[0])
+			}
+		},
+	}
+}
+
+// describer writes the sections of a description. Values are aligned per block of lines, and nested
+// lines are indented by two spaces per level.
+type describer struct {
+	table *tabwriter.Writer
+	now   time.Time
+}
+
+func newDescriber(out io.Writer, now time.Time) *describer {
+	return &describer{table: tabwriter.NewWriter(out, 0, 8, 2, ' ', 0), now: now}
+}
+
+// line writes the cells of a line at the given level of indentation
+func (d *describer) line(level int, cells ...string) {
+	fmt.Fprintln(d.table, strings.Repeat("  ", level)+strings.Join(cells, "\t"))
+}
+
+// field writes a name: value line
+func (d *describer) field(level int, name string, value any) {
+	d.line(level, name+":", fmt.Sprint(value))
+}
+
+// labels writes the labels one per line, sorted by key
+func (d *describer) labels(level int, name string, labels map[string]string) {
+	if len(labels) == 0 {
+		d.field(level, name, "<none>")
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 {
+			d.field(level, name, key+"="+labels[key])
+			continue
+		}
+		d.line(level, "", key+"="+labels[key])
+	}
+}
+
+// timestamp renders a time absolutely and as an age, e.g. Mon, 01 Jan 2024 11:55:00 +0000 (5m ago)
+func (d *describer) timestamp(t time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.UTC().Format(time.RFC1123Z), age(t, d.now))
+}
+
+// events writes the events oldest first
+func (d *describer) events(events []*api.Event) {
+	if len(events) == 0 {
+		// Not aligned with the lines before, which belong to another section
+		d.line(0, "Events:  <none>")
+		return
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].LastTimestamp.Before(events[j].LastTimestamp) })
+	d.line(0, "Events:")
+	d.line(1, "TYPE", "REASON", "AGE", "FROM", "MESSAGE")
+	for _, event := range events {
+		when := age(event.LastTimestamp, d.now)
+		if event.Count > 1 {
+			when = fmt.Sprintf("%s (x%d over %s)", when, event.Count, age(event.FirstTimestamp, d.now))
+		}
+		d.line(1, event.Type, event.Reason, when, valueOrNone(event.Source), event.Message)
+	}
+}
+
+func (d *describer) flush() error {
+	return d.table.Flush()
+}
+
+// describePod prints the pod, its containers and the events about it
+func (o *options) describePod(ctx context.Context, name string) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	pod, err := c.PodsIn(namespaceOf(o.namespace)).Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	events, err := c.Events().List(ctx, api.KindPod, name)
+	if err != nil {
+		return err
+	}
+	// Events of an earlier pod of the same name are not about this one
+	events = filterEvents(events, pod.UID)
+
+	d := newDescriber(o.out, o.now())
+	d.field(0, "Name", pod.Name)
+	d.field(0, "Namespace", namespaceOf(pod.Namespace))
+	d.field(0, "UID", valueOrNone(pod.UID))
+	d.labels(0, "Labels", pod.Labels)
+	if ref := pod.GetControllerOf(); ref != nil {
+		d.field(0, "Controlled By", ref.Kind+"/"+ref.Name)
+	}
+	d.field(0, "Created", d.timestamp(pod.CreationTimestamp))
+	d.field(0, "Node", valueOrNone(pod.NodeName))
+	d.field(0, "Status", pod.Status)
+	d.field(0, "Priority", pod.Spec.Priority)
+	d.field(0, "Restart Policy", valueOrNone(string(pod.Spec.RestartPolicy)))
+	gracePeriod := "<none>"
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		gracePeriod = fmt.Sprintf("%ds", *pod.Spec.TerminationGracePeriodSeconds)
+	}
+	d.field(0, "Termination Grace Period", gracePeriod)
+
+	d.line(0, "Containers:")
+	for _, container := range pod.Spec.Containers {
+		d.line(1, container.Name+":")
+		d.field(2, "Image", container.Image)
+		status := pod.GetContainerStatus(container.Name)
+		if status == nil {
+			d.field(2, "State", "<unknown>")
+			continue
+		}
+		d.field(2, "Container ID", valueOrNone(status.ContainerID))
+		d.field(2, "Image ID", valueOrNone(status.ImageID))
+		d.field(2, "State", status.State)
+		if status.Reason != "" {
+			d.field(3, "Reason", status.Reason)
+		}
+		if status.Message != "" {
+			d.field(3, "Message", status.Message)
+		}
+		if status.State == api.ContainerTerminated {
+			d.field(3, "Exit Code", status.ExitCode)
+		}
+		d.field(2, "Restart Count", status.RestartCount)
+	}
+	d.events(events)
+	return d.flush()
+}
+
+// describeNode prints the node, its conditions and capacity, the pods bound to it and the events about it
+func (o *options) describeNode(ctx context.Context, name string) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	node, err := c.Nodes().Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	pods, err := c.Pods().List(ctx, client.PodListOptions{NodeName: name})
+	if err != nil {
+		return err
+	}
+	events, err := c.Events().List(ctx, api.KindNode, name)
+	if err != nil {
+		return err
+	}
+
+	d := newDescriber(o.out, o.now())
+	d.field(0, "Name", node.Name)
+	d.field(0, "UID", valueOrNone(node.UID))
+	d.labels(0, "Labels", node.Labels)
+	d.field(0, "Created", d.timestamp(node.CreationTimestamp))
+	d.field(0, "Status", valueOrNone(string(node.Status)))
+	d.field(0, "Unschedulable", node.Spec.Unschedulable)
+	d.field(0, "Last Heartbeat", d.timestamp(node.LastHeartbeatTime))
+
+	if len(node.Conditions) == 0 {
+		d.field(0, "Conditions", "<none>")
+	} else {
+		d.line(0, "Conditions:")
+		d.line(1, "TYPE", "STATUS", "REASON", "LAST TRANSITION", "MESSAGE")
+		for _, condition := range node.Conditions {
+			d.line(1, string(condition.Type), string(condition.Status), valueOrNone(condition.Reason),
+				age(condition.LastTransitionTime, d.now)+" ago", condition.Message)
+		}
+	}
+
+	d.line(0, "Capacity:")
+	d.field(1, "cpu", node.Capacity.CPU)
+	d.field(1, "memory", node.Capacity.Memory)
+	d.field(1, "pods", node.Capacity.MaxPods)
+
+	if len(pods) == 0 {
+		d.line(0, "Pods:  <none>")
+	} else {
+		d.line(0, fmt.Sprintf("Pods: (%d in total)", len(pods)))
+		d.line(1, "NAMESPACE", "NAME", "STATUS", "AGE")
+		for _, pod := range pods {
+			d.line(1, namespaceOf(pod.Namespace), pod.Name, string(pod.Status), age(pod.CreationTimestamp, d.now))
+		}
+	}
+	d.events(filterEvents(events, node.UID))
+	return d.flush()
+}
+
+// filterEvents drops the events about another object of the same name, told apart by UID. Events without a
+// UID are kept.
+func filterEvents(events []*api.Event, uid string) []*api.Event {
+	kept := events[:0]
+	for _, event := range events {
+		if event.InvolvedObject.UID == "" || uid == "" || event.InvolvedObject.UID == uid {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
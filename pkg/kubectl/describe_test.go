@@ -0,0 +1,106 @@
+package kubectl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+func seedDescribedObjects(t *testing.T, c *client.Client) {
+	ctx := context.Background()
+	_, err := c.Nodes().Create(ctx, &api.Node{
+		ObjectMeta:        api.ObjectMeta{Name: "node-1", UID: "machine-1", Labels: map[string]string{"zone": "a", "disk": "ssd"}, CreationTimestamp: testNow.Add(-50 * time.Hour)},
+		Status:            api.NodeReady,
+		LastHeartbeatTime: testNow.Add(-10 * time.Second),
+		Capacity:          api.NodeCapacity{CPU: 4, Memory: 8 << 30, MaxPods: 110},
+		Conditions: []api.NodeCondition{{
+			Type: api.NodeMemoryPressure, Status: api.ConditionTrue, Reason: "KubeletHasInsufficientMemory",
+			Message: "available memory is 90Mi", LastTransitionTime: testNow.Add(-3 * time.Minute),
+		}},
+	})
+	require.NoError(t, err)
+
+	rs := &api.ObjectMeta{Name: "web", UID: "rs-uid"}
+	gracePeriod := int64(10)
+	pod, err := c.Pods().Create(ctx, &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name: "web-1", Labels: map[string]string{"app": "web", "tier": "frontend"},
+			OwnerReferences: []api.OwnerReference{api.NewControllerRef(rs, api.KindReplicaSet)}, CreationTimestamp: testNow.Add(-5 * time.Minute),
+		},
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{Name: "nginx", Image: "nginx:1.25"},
+				{Name: "log-shipper", Image: "busybox:latest"},
+				{Name: "sidecar", Image: "envoy:latest"},
+			},
+			RestartPolicy:                 api.RestartPolicyAlways,
+			TerminationGracePeriodSeconds: &gracePeriod,
+		},
+	})
+	require.NoError(t, err)
+	pod.NodeName, pod.Status = "node-1", api.PodScheduled
+	_, err = c.Pods().Update(ctx, pod)
+	require.NoError(t, err)
+	_, err = c.Pods().UpdateStatus(ctx, &api.PodStatusUpdate{
+		Name: "web-1", UID: pod.UID, NodeName: "node-1", Status: api.PodRunning,
+		ContainerStatuses: []api.ContainerStatus{
+			{Name: "nginx", ContainerID: "docker://3f2a", Image: "nginx:1.25", ImageID: "nginx@sha256:0a1b", State: api.ContainerRunning},
+			{Name: "log-shipper", ContainerID: "docker://9c4d", Image: "busybox:latest", State: api.ContainerWaiting,
+				Reason: "CrashLoopBackOff", Message: "back-off 40s restarting failed container", ExitCode: 1, RestartCount: 3},
+		},
+	})
+	require.NoError(t, err)
+	_, err = c.Pods().Create(ctx, &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "cache", Namespace: "staging", CreationTimestamp: testNow.Add(-2 * time.Hour)},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "redis", Image: "redis"}}},
+		NodeName:   "node-1",
+	})
+	require.NoError(t, err)
+
+	for _, event := range []*api.Event{
+		{InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web-1", UID: pod.UID}, Type: api.EventTypeWarning, Reason: "BackOff",
+			Message: "Back-off restarting failed container log-shipper", Source: "kubelet/node-1",
+			FirstTimestamp: testNow.Add(-4 * time.Minute), LastTimestamp: testNow.Add(-30 * time.Second), Count: 3},
+		{InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web-1", UID: pod.UID}, Type: api.EventTypeNormal, Reason: "Pulled",
+			Message: "Successfully pulled image nginx:1.25", Source: "kubelet/node-1", FirstTimestamp: testNow.Add(-5 * time.Minute)},
+		// About an earlier pod of the same name
+		{InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web-1", UID: "old-uid"}, Type: api.EventTypeNormal, Reason: "Killing",
+			FirstTimestamp: testNow.Add(-time.Hour)},
+		{InvolvedObject: api.ObjectReference{Kind: api.KindNode, Name: "node-1"}, Type: api.EventTypeWarning, Reason: "NodeHasMemoryPressure",
+			Message: "available memory is 90Mi", Source: "kubelet/node-1", FirstTimestamp: testNow.Add(-3 * time.Minute)},
+	} {
+		_, err := c.Events().Create(ctx, event)
+		require.NoError(t, err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		seedDescribedObjects(t, c)
+
+		out, _, err := run(t, address, "describe", "pod", "web-1")
+		require.NoError(t, err)
+		assertGolden(t, "describe-pod", uids.ReplaceAllString(out, "<uid>"))
+
+		out, _, err = run(t, address, "describe", "no", "node-1")
+		require.NoError(t, err)
+		assertGolden(t, "describe-node", out)
+
+		out, _, err = run(t, address, "describe", "pod", "cache", "-n", "staging")
+		require.NoError(t, err)
+		assertGolden(t, "describe-pod-without-status", uids.ReplaceAllString(out, "<uid>"))
+
+		_, _, err = run(t, address, "describe", "pod", "cache")
+		assert.True(t, client.IsNotFound(err))
+		_, _, err = run(t, address, "describe", "node", "node-2")
+		assert.True(t, client.IsNotFound(err))
+		_, _, err = run(t, address, "describe", "rs", "web")
+		assert.ErrorIs(t, err, ErrUnknownResource)
+	})
+}
@@ -0,0 +1,159 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+var ErrUnknownResource = errors.New("unknown resource type")
+
+// resource is an object type the commands work with
+type resource struct {
+	// name is the plural name, e.g. pods
+	name string
+	// kind names a single object in messages, e.g. pod
+	kind    string
+	aliases []string
+	// namespaced objects are filtered by --namespace
+	namespaced bool
+}
+
+var (
+	podsResource        = resource{name: "pods", kind: "pod", aliases: []string{"pod", "po"}, namespaced: true}
+	nodesResource       = resource{name: "nodes", kind: "node", aliases: []string{"node", "no"}}
+	replicaSetsResource = resource{name: "replicasets", kind: "replicaset", aliases: []string{"replicaset", "rs"}, namespaced: true}
+
+	resources = []resource{podsResource, nodesResource, replicaSetsResource}
+)
+
+// parseResource finds the resource by its name or one of its aliases
+func parseResource(name string) (resource, error) {
+	name = strings.ToLower(name)
+	for _, r := range resources {
+		if r.name == name || slices.Contains(r.aliases, name) {
+			return r, nil
+		}
+	}
+	return resource{}, fmt.Errorf("%w %q: expected pods, nodes or replicasets", ErrUnknownResource, name)
+}
+
+func newGetCommand(o *options) *cobra.Command {
+	var output string
+	var watch bool
+	cmd := &cobra.Command{
+		Use:   "get (pods|nodes|replicasets) [name]",
+		Short: "Display one or many objects",
+		Example: `  gokubectl get pods
+  gokubectl get node node-1 -o yaml
+  gokubectl get pods --watch`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := parseResource(args[0])
+			if err != nil {
+				return err
+			}
+			if err := validateOutputFormat(output); err != nil {
+				return err
+			}
+			if watch && output != "" {
+				return fmt.Errorf("%w %q: --watch prints a table", ErrUnknownOutputFormat, output)
+			}
+			var name string
+			if len(args) == 2 {
+				name = args[1]
+			}
+			return o.get(cmd.Context(), r, name, output, watch)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output format: json or yaml; a table when not set")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "After printing the objects, print a row for every change to them until interrupted")
+	return cmd
+}
+
+// get prints the named object of the resource, or all of them in the namespace when name is empty. With
+// watch, it then prints the changes to them.
+func (o *options) get(ctx context.Context, r resource, name, output string, watch bool) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	switch r.name {
+	case podsResource.name:
+		podClient := c.PodsIn(namespaceOf(o.namespace))
+		pods, err := getObjects(ctx, name, podClient.Get, func(ctx context.Context) ([]*api.Pod, error) {
+			return podClient.List(ctx, client.PodListOptions{})
+		})
+		if err != nil {
+			return err
+		}
+		headers := []string{"NAME", "STATUS", "NODE", "AGE"}
+		row := func(pod *api.Pod) []string {
+			return []string{pod.Name, string(pod.Status), valueOrNone(pod.NodeName), age(pod.CreationTimestamp, o.now())}
+		}
+		if watch {
+			meta := func(pod *api.Pod) *api.ObjectMeta { return &pod.ObjectMeta }
+			changes := watchChanges(o.pollInterval, podWatch(podClient), podClient.ListWithResourceVersion, meta)
+			return watchObjects(ctx, o, r, name, pods, headers, row, meta, changes)
+		}
+		return printObjects(o, r, output, name != "", pods, headers, row)
+	case nodesResource.name:
+		nodes, err := getObjects(ctx, name, c.Nodes().Get, c.Nodes().List)
+		if err != nil {
+			return err
+		}
+		headers := []string{"NAME", "STATUS"}
+		row := func(node *api.Node) []string {
+			return []string{node.Name, string(node.Status)}
+		}
+		if watch {
+			meta := func(node *api.Node) *api.ObjectMeta { return &node.ObjectMeta }
+			changes := watchChanges(o.pollInterval, nodeWatch(c.Nodes()), c.Nodes().ListWithResourceVersion, meta)
+			return watchObjects(ctx, o, r, name, nodes, headers, row, meta, changes)
+		}
+		return printObjects(o, r, output, name != "", nodes, headers, row)
+	default:
+		rsClient := c.ReplicaSetsIn(namespaceOf(o.namespace))
+		replicaSets, err := getObjects(ctx, name, rsClient.Get, rsClient.List)
+		if err != nil {
+			return err
+		}
+		headers := []string{"NAME", "DESIRED", "CURRENT", "READY"}
+		row := func(rs *api.ReplicaSet) []string {
+			return []string{rs.Name, fmt.Sprint(rs.Spec.Replicas), fmt.Sprint(rs.Status.Replicas), fmt.Sprint(rs.Status.ReadyReplicas)}
+		}
+		if watch {
+			meta := func(rs *api.ReplicaSet) *api.ObjectMeta { return &rs.ObjectMeta }
+			changes := watchChanges(o.pollInterval, replicaSetWatch(rsClient), rsClient.ListWithResourceVersion, meta)
+			return watchObjects(ctx, o, r, name, replicaSets, headers, row, meta, changes)
+		}
+		return printObjects(o, r, output, name != "", replicaSets, headers, row)
+	}
+}
+
+// getObjects gets the named object, or lists all of them when name is empty
+func getObjects[T any](ctx context.Context, name string, get func(context.Context, string) (*T, error), list func(context.Context) ([]*T, error)) ([]*T, error) {
+	if name == "" {
+		return list(ctx)
+	}
+	object, err := get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return []*T{object}, nil
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,147 @@
+package kubectl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigEnvVar names the environment variable that gives the config file used without --gokubeconfig
+	ConfigEnvVar = "GOKUBECONFIG"
+	// defaultConfigFile is the config file in the home directory used without --gokubeconfig or GOKUBECONFIG
+	defaultConfigFile = ".gokube/config"
+)
+
+var (
+	ErrInvalidConfig   = errors.New("invalid gokubectl config")
+	ErrContextNotFound = errors.New("context not found")
+)
+
+// Config is the gokubectl config file. It names the clusters gokubectl talks to, the credentials it
+// authenticates with and the contexts pairing the two, one of which is current. The settings of the current
+// context are used for the flags that are not given.
+type Config struct {
+	CurrentContext string                  `json:"current-context,omitempty"`
+	Clusters       map[string]*Cluster     `json:"clusters,omitempty"`
+	Credentials    map[string]*Credentials `json:"credentials,omitempty"`
+	Contexts       map[string]*Context     `json:"contexts,omitempty"`
+}
+
+// Cluster tells how to reach an API server
+type Cluster struct {
+	// Server is the address of the API server, such as https://10.0.0.1:8080
+	Server string `json:"server"`
+	// CertificateAuthority is the path of the PEM file of the CA that signed the certificate of the API server
+	CertificateAuthority string `json:"certificate-authority,omitempty"`
+}
+
+// Credentials authenticate gokubectl with an API server
+type Credentials struct {
+	// Token is sent as a bearer token
+	Token string `json:"token,omitempty"`
+}
+
+// Context pairs a cluster with the credentials used for it, and the namespace commands act on by default
+type Context struct {
+	Cluster     string `json:"cluster"`
+	Credentials string `json:"credentials,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+}
+
+// defaultConfigPath returns the config file named by GOKUBECONFIG, or else ~/.gokube/config. It is empty when
+// the home directory is unknown.
+func defaultConfigPath() string {
+	if path := os.Getenv(ConfigEnvVar); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultConfigFile)
+}
+
+// LoadConfig reads the config file at path. A file that does not exist, or no path, is an empty config.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gokubectl config: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidConfig, path, err)
+	}
+	return config, nil
+}
+
+// Save writes the config to path, creating its directory. The file is only readable by its owner, as it
+// holds tokens.
+func (c *Config) Save(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: no config file, set --gokubeconfig or %s", ErrInvalidConfig, ConfigEnvVar)
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode gokubectl config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to write gokubectl config: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write gokubectl config: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the cluster, credentials and namespace of the named context, the current one when name is
+// empty. Without a context, or without credentials in it, empty settings are returned.
+func (c *Config) resolve(name string) (Cluster, Credentials, string, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return Cluster{}, Credentials{}, "", nil
+	}
+	current, ok := c.Contexts[name]
+	if !ok {
+		return Cluster{}, Credentials{}, "", fmt.Errorf("%w: %s", ErrContextNotFound, name)
+	}
+	cluster, ok := c.Clusters[current.Cluster]
+	if !ok {
+		return Cluster{}, Credentials{}, "", fmt.Errorf("%w: context %s uses cluster %q, which is not configured", ErrInvalidConfig, name, current.Cluster)
+	}
+	var credentials Credentials
+	if current.Credentials != "" {
+		found, ok := c.Credentials[current.Credentials]
+		if !ok {
+			return Cluster{}, Credentials{}, "", fmt.Errorf("%w: context %s uses credentials %q, which are not configured", ErrInvalidConfig, name, current.Credentials)
+		}
+		credentials = *found
+	}
+	return *cluster, credentials, current.Namespace, nil
+}
+
+// loadTLSConfig returns a TLS config trusting the CA in the PEM file at path
+func loadTLSConfig(path string) (*tls.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate authority: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no PEM certificate in %s", ErrInvalidConfig, path)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
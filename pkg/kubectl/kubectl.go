@@ -0,0 +1,137 @@
+package kubectl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+const (
+	// DefaultServer is the API server address used without --server
+	DefaultServer = "localhost:8080"
+	// DefaultNamespace holds the objects created without a namespace
+	DefaultNamespace = api.DefaultNamespace
+	// DefaultPollInterval is how often commands waiting for the cluster, such as rollout status, read it again
+	DefaultPollInterval = time.Second
+)
+
+// options are the settings shared by every command
+type options struct {
+	server    string
+	namespace string
+	in        io.Reader
+	out       io.Writer
+	errOut    io.Writer
+	// now is the time ages are computed from; it defaults to time.Now
+	now func() time.Time
+	// pollInterval is how often commands waiting for the cluster read it again
+	pollInterval time.Duration
+	// token and certificateAuthority authenticate gokubectl with the API server and the API server with it
+	token                string
+	certificateAuthority string
+	// configPath is the config file the settings not given as flags are read from, none if empty
+	configPath string
+	// context names the context of the config file to use instead of the current one
+	context string
+}
+
+// resolve completes the settings not given as flags with those of the context of the config file, and then
+// with the defaults
+func (o *options) resolve() error {
+	config, err := LoadConfig(o.configPath)
+	if err != nil {
+		return err
+	}
+	cluster, credentials, namespace, err := config.resolve(o.context)
+	if err != nil {
+		return err
+	}
+	// The CA of the cluster only vouches for its server, not for one given by --server
+	if o.server == "" {
+		o.server, o.certificateAuthority = cluster.Server, cmp.Or(o.certificateAuthority, cluster.CertificateAuthority)
+	}
+	o.server = cmp.Or(o.server, DefaultServer)
+	o.token = cmp.Or(o.token, credentials.Token)
+	o.namespace = cmp.Or(o.namespace, namespace, DefaultNamespace)
+	return nil
+}
+
+// client returns a client of the API server given by --server or the context
+func (o *options) client() (*client.Client, error) {
+	options := client.Options{Token: o.token}
+	if o.certificateAuthority != "" {
+		tlsConfig, err := loadTLSConfig(o.certificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+	return client.New(o.server, options)
+}
+
+// NewCommand returns the gokubectl root command, reading manifests given as - from in, writing its output to
+// out and notices, such as an empty list, to errOut
+func NewCommand(in io.Reader, out, errOut io.Writer) *cobra.Command {
+	return newCommand(&options{in: in, out: out, errOut: errOut, now: time.Now, pollInterval: DefaultPollInterval,
+		configPath: defaultConfigPath()})
+}
+
+func newCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gokubectl",
+		Short: "gokubectl controls a gokube cluster",
+		// Errors are printed by FormatError, once
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return o.resolve()
+		},
+	}
+	cmd.SetIn(o.in)
+	cmd.SetOut(o.out)
+	cmd.SetErr(o.errOut)
+	// The flags take precedence over the context, which takes precedence over the defaults
+	cmd.PersistentFlags().StringVar(&o.server, "server", "", "The address of the API server (default the server of the context, or "+DefaultServer+")")
+	cmd.PersistentFlags().StringVarP(&o.namespace, "namespace", "n", "", "The namespace of the objects (default the namespace of the context, or "+DefaultNamespace+")")
+	cmd.PersistentFlags().StringVar(&o.token, "token", "", "The bearer token to authenticate with")
+	cmd.PersistentFlags().StringVar(&o.certificateAuthority, "certificate-authority", "", "The PEM file of the CA of the API server")
+	cmd.PersistentFlags().StringVar(&o.context, "context", "", "The context of the config file to use instead of the current one")
+	cmd.PersistentFlags().StringVar(&o.configPath, "gokubeconfig", o.configPath, "The config file, defaults to $"+ConfigEnvVar+" or ~/"+defaultConfigFile)
+
+	cmd.AddCommand(newGetCommand(o), newApplyCommand(o), newDeleteCommand(o), newScaleCommand(o), newRolloutCommand(o), newDescribeCommand(o),
+		newConfigCommand(o))
+	return cmd
+}
+
+// FormatError renders an error of a command as a single line. Errors answered by the API server show the
+// reason the server gave, e.g. Error from server (NotFound): pod not found: web, in the context the command
+// added to it.
+func FormatError(err error) string {
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		reason := strings.ReplaceAll(http.StatusText(statusErr.Code), " ", "")
+		if reason == "" {
+			reason = fmt.Sprint(statusErr.Code)
+		}
+		message := strings.Replace(err.Error(), statusErr.Error(), statusErr.Message, 1)
+		return fmt.Sprintf("Error from server (%s): %s", reason, strings.ReplaceAll(message, "\n", " "))
+	}
+	return "error: " + strings.ReplaceAll(err.Error(), "\n", " ")
+}
+
+// namespaceOf returns the namespace of an object, which is the default one when it has none
+func namespaceOf(namespace string) string {
+	if namespace == "" {
+		return DefaultNamespace
+	}
+	return namespace
+}
@@ -0,0 +1,192 @@
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/client"
+	"gokube/pkg/storage"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// resourceVersions matches the resource versions in JSON and YAML output, which depend on the etcd revisions of
+// the earlier tests
+var resourceVersions = regexp.MustCompile(`("?resourceVersion"?: )"\d+"`)
+
+// uids matches the UIDs the API server gives the objects it creates
+var uids = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// testNow is the time ages are computed from
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// withAPIServer runs test against an API server backed by embedded etcd, passing its address and a client.
+// The tests use the staging namespace besides the default one.
+func withAPIServer(t *testing.T, test func(t *testing.T, address string, c *client.Client)) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		apiServer := httptest.NewServer(server.NewAPIServer(storage.NewEtcdStorage(etcdClient)).Handler())
+		defer apiServer.Close()
+
+		c, err := client.New(apiServer.URL, client.Options{})
+		require.NoError(t, err)
+		_, err = c.Namespaces().Create(context.Background(), &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "staging"}})
+		require.NoError(t, err)
+		test(t, apiServer.URL, c)
+	})
+}
+
+// run runs gokubectl with args against the API server at address and returns what it printed to stdout and stderr
+func run(t *testing.T, address string, args ...string) (string, string, error) {
+	t.Helper()
+	return runWithInput(t, address, "", args...)
+}
+
+// runWithInput runs gokubectl like run, with input as its standard input
+func runWithInput(t *testing.T, address, input string, args ...string) (string, string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	cmd := newCommand(&options{in: strings.NewReader(input), out: &out, errOut: &errOut, now: func() time.Time { return testNow }})
+	cmd.SetArgs(append([]string{"--server", address}, args...))
+	err := cmd.Execute()
+	return out.String(), errOut.String(), err
+}
+
+// assertGolden compares actual with testdata/<name>.golden, or rewrites the file when run with -update
+func assertGolden(t *testing.T, name, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(actual), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+// seedPod creates a pod created the given time before testNow and puts it into the given state
+func seedPod(t *testing.T, c *client.Client, pod *api.Pod, created time.Duration) {
+	t.Helper()
+	ctx := context.Background()
+	pod.Spec.Containers = []api.Container{{Name: "nginx", Image: "nginx:latest"}}
+	pod.CreationTimestamp = testNow.Add(-created)
+	stored, err := c.Pods().Create(ctx, pod)
+	require.NoError(t, err)
+	stored.NodeName, stored.Status = pod.NodeName, pod.Status
+	_, err = c.Pods().Update(ctx, stored)
+	require.NoError(t, err)
+}
+
+func seedCluster(t *testing.T, c *client.Client) {
+	ctx := context.Background()
+	seedPod(t, c, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web-1"}, NodeName: "node-1", Status: api.PodRunning}, 5*time.Minute)
+	seedPod(t, c, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web-2"}, NodeName: "node-2", Status: api.PodScheduled}, 3*time.Hour+20*time.Minute)
+	seedPod(t, c, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "unscheduled-with-a-long-name"}, Status: api.PodPending}, 45*time.Second)
+	seedPod(t, c, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "cache", Namespace: "staging"}, NodeName: "node-1", Status: api.PodRunning}, 72*time.Hour)
+
+	for _, node := range []*api.Node{
+		{ObjectMeta: api.ObjectMeta{Name: "node-1", UID: "machine-1", CreationTimestamp: testNow.Add(-48 * time.Hour)}, Status: api.NodeReady, Capacity: api.NodeCapacity{CPU: 4, MaxPods: 110}},
+		{ObjectMeta: api.ObjectMeta{Name: "node-2", UID: "machine-2", CreationTimestamp: testNow.Add(-48 * time.Hour)}, Status: api.NodeNotReady},
+	} {
+		_, err := c.Nodes().Create(ctx, node)
+		require.NoError(t, err)
+	}
+
+	_, err := c.ReplicaSets().Create(ctx, &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec: api.ReplicaSetSpec{
+			Replicas: 3,
+			Selector: map[string]string{"app": "web"},
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			},
+		},
+		Status: api.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 1},
+	})
+	require.NoError(t, err)
+}
+
+func TestGet(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		seedCluster(t, c)
+
+		for _, test := range []struct {
+			golden string
+			args   []string
+		}{
+			{"get-pods", []string{"get", "pods"}},
+			{"get-pods-staging", []string{"get", "po", "-n", "staging"}},
+			{"get-pod", []string{"get", "pod", "web-1"}},
+			{"get-nodes", []string{"get", "nodes"}},
+			{"get-node-json", []string{"get", "node", "node-1", "-o", "json"}},
+			{"get-nodes-yaml", []string{"get", "nodes", "-o", "yaml"}},
+			{"get-replicasets", []string{"get", "rs"}},
+		} {
+			t.Run(test.golden, func(t *testing.T) {
+				out, errOut, err := run(t, address, test.args...)
+				require.NoError(t, err)
+				assert.Empty(t, errOut)
+				assertGolden(t, test.golden, resourceVersions.ReplaceAllString(out, `$1"<version>"`))
+			})
+		}
+
+		t.Run("empty namespace", func(t *testing.T) {
+			out, errOut, err := run(t, address, "get", "replicasets", "--namespace", "staging")
+			require.NoError(t, err)
+			assert.Empty(t, out)
+			assert.Equal(t, "No resources found in staging namespace.\n", errOut)
+		})
+
+		t.Run("errors", func(t *testing.T) {
+			_, _, err := run(t, address, "get", "pod", "missing")
+			assert.Regexp(t, `^Error from server \(NotFound\): .*missing$`, FormatError(err))
+
+			_, _, err = run(t, address, "get", "pod", "cache")
+			assert.Equal(t, `Error from server (NotFound): pod not found: default/cache`, FormatError(err), "pods of other namespaces are not found")
+
+			_, _, err = run(t, address, "get", "services")
+			assert.ErrorIs(t, err, ErrUnknownResource)
+
+			_, _, err = run(t, address, "get", "pods", "-o", "wide")
+			assert.ErrorIs(t, err, ErrUnknownOutputFormat)
+		})
+	})
+}
+
+func TestGetReportsUnreachableServer(t *testing.T) {
+	_, _, err := run(t, "127.0.0.1:1", "get", "nodes")
+	require.Error(t, err)
+	message := FormatError(err)
+	assert.Regexp(t, `^error: failed to send request to API server: .*connection refused$`, message)
+	assert.NotContains(t, message, "\n")
+}
+
+func TestAge(t *testing.T) {
+	for _, test := range []struct {
+		elapsed  time.Duration
+		expected string
+	}{
+		{-time.Second, "0s"},
+		{90 * time.Second, "90s"},
+		{119 * time.Minute, "119m"},
+		{47 * time.Hour, "47h"},
+		{50 * time.Hour, "2d"},
+	} {
+		assert.Equal(t, test.expected, age(testNow.Add(-test.elapsed), testNow), test.elapsed)
+	}
+	assert.Equal(t, "<unknown>", age(time.Time{}, testNow))
+}
@@ -0,0 +1,81 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	ErrUnknownOutputFormat = errors.New("unknown output format")
+)
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", "json", "yaml":
+		return nil
+	default:
+		return fmt.Errorf("%w %q: expected json or yaml", ErrUnknownOutputFormat, format)
+	}
+}
+
+// printObjects prints objects as a table with the given headers and one row per object, or in the given
+// output format. A named object is printed on its own rather than as a list.
+func printObjects[T any](o *options, r resource, format string, named bool, objects []*T, headers []string, row func(*T) []string) error {
+	if format != "" {
+		var value any = objects
+		if named {
+			value = objects[0]
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err == nil && format == "yaml" {
+			data, err = yaml.JSONToYAML(data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", r.name, err)
+		}
+		_, err = fmt.Fprintln(o.out, strings.TrimSuffix(string(data), "\n"))
+		return err
+	}
+
+	if len(objects) == 0 {
+		if r.namespaced {
+			_, err := fmt.Fprintf(o.errOut, "No resources found in %s namespace.\n", namespaceOf(o.namespace))
+			return err
+		}
+		_, err := fmt.Fprintln(o.errOut, "No resources found")
+		return err
+	}
+
+	table := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(table, strings.Join(headers, "\t"))
+	for _, object := range objects {
+		fmt.Fprintln(table, strings.Join(row(object), "\t"))
+	}
+	return table.Flush()
+}
+
+// age renders how long ago an object was created the way kubectl does, e.g. 90s, 5m, 3h or 12d
+func age(created, now time.Time) string {
+	if created.IsZero() {
+		return "<unknown>"
+	}
+	elapsed := now.Sub(created)
+	switch {
+	case elapsed < 0:
+		return "0s"
+	case elapsed < 2*time.Minute:
+		return fmt.Sprintf("%ds", int(elapsed.Seconds()))
+	case elapsed < 2*time.Hour:
+		return fmt.Sprintf("%dm", int(elapsed.Minutes()))
+	case elapsed < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(elapsed.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(elapsed.Hours()/24))
+	}
+}
@@ -0,0 +1,96 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+)
+
+var (
+	ErrRolloutTimeout = errors.New("timed out waiting for the rollout to finish")
+)
+
+func newRolloutCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "Follow the rollout of a ReplicaSet",
+	}
+	cmd.AddCommand(newRolloutStatusCommand(o))
+	return cmd
+}
+
+func newRolloutStatusCommand(o *options) *cobra.Command {
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:     "status replicaset NAME",
+		Short:   "Wait until all the pods of a ReplicaSet are ready",
+		Example: `  gokubectl rollout status replicaset nginx-rs --timeout=2m`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := replicaSetArg(args)
+			if err != nil {
+				return err
+			}
+			return o.rolloutStatus(cmd.Context(), name, timeout)
+		},
+	}
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "How long to wait before giving up; zero waits forever")
+	return cmd
+}
+
+// rolloutStatus polls the named ReplicaSet until as many of its pods are ready as it wants, printing a line
+// each time the counts change. The API server being unavailable for a while is waited out like a slow rollout.
+func (o *options) rolloutStatus(ctx context.Context, name string, timeout time.Duration) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	pollInterval := o.pollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	var last *api.ReplicaSet
+	for {
+		rs, err := c.ReplicaSetsIn(namespaceOf(o.namespace)).Get(ctx, name)
+		switch {
+		case err == nil:
+			if rs.Status.ReadyReplicas == rs.Spec.Replicas {
+				fmt.Fprintf(o.out, "%s %q successfully rolled out\n", replicaSetsResource.kind, name)
+				return nil
+			}
+			if last == nil || rs.Spec.Replicas != last.Spec.Replicas || rs.Status.ReadyReplicas != last.Status.ReadyReplicas {
+				fmt.Fprintf(o.out, "Waiting for %s %q rollout to finish: %s...\n", replicaSetsResource.kind, name, readiness(rs))
+			}
+			last = rs
+		case ctx.Err() != nil:
+			// The request was cut short by the timeout
+		case isTransient(err):
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			if last == nil {
+				return fmt.Errorf("%w: %s %q: %w", ErrRolloutTimeout, replicaSetsResource.kind, name, err)
+			}
+			return fmt.Errorf("%w: %s %q: %s", ErrRolloutTimeout, replicaSetsResource.kind, name, readiness(last))
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func readiness(rs *api.ReplicaSet) string {
+	return fmt.Sprintf("%d of %d replicas are ready", rs.Status.ReadyReplicas, rs.Spec.Replicas)
+}
@@ -0,0 +1,103 @@
+package kubectl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+// scriptedReplicaSets answers GET requests for a ReplicaSet with the next response of a script, repeating the
+// last one, as if the controller and kubelets brought up its pods between polls
+type scriptedReplicaSets struct {
+	mutex sync.Mutex
+	// script holds a ReplicaSet for each poll; a nil one answers a server error
+	script []*api.ReplicaSet
+	polls  int
+}
+
+func (s *scriptedReplicaSets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rs := s.script[min(s.polls, len(s.script)-1)]
+	s.polls++
+	if rs == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(rs)
+}
+
+func replicaSetWithReady(desired, ready int32) *api.ReplicaSet {
+	return &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec:       api.ReplicaSetSpec{Replicas: desired},
+		Status:     api.ReplicaSetStatus{Replicas: desired, ReadyReplicas: ready},
+	}
+}
+
+func runRolloutStatus(t *testing.T, script *scriptedReplicaSets, args ...string) (string, error) {
+	apiServer := httptest.NewServer(script)
+	defer apiServer.Close()
+
+	var out, errOut bytes.Buffer
+	cmd := newCommand(&options{out: &out, errOut: &errOut, now: time.Now, pollInterval: time.Millisecond})
+	cmd.SetArgs(append([]string{"--server", apiServer.URL, "rollout", "status", "replicaset", "web"}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestRolloutStatus(t *testing.T) {
+	script := &scriptedReplicaSets{script: []*api.ReplicaSet{
+		replicaSetWithReady(3, 0),
+		replicaSetWithReady(3, 0),
+		replicaSetWithReady(3, 1),
+		nil,
+		replicaSetWithReady(3, 1),
+		// Scaled up during the rollout
+		replicaSetWithReady(4, 2),
+		replicaSetWithReady(4, 4),
+	}}
+	out, err := runRolloutStatus(t, script)
+	require.NoError(t, err)
+	assert.Equal(t, `Waiting for replicaset "web" rollout to finish: 0 of 3 replicas are ready...
+Waiting for replicaset "web" rollout to finish: 1 of 3 replicas are ready...
+Waiting for replicaset "web" rollout to finish: 2 of 4 replicas are ready...
+replicaset "web" successfully rolled out
+`, out, "a line is printed only when the counts change, and server errors are waited out")
+	assert.Equal(t, 7, script.polls)
+}
+
+func TestRolloutStatusTimesOut(t *testing.T) {
+	out, err := runRolloutStatus(t, &scriptedReplicaSets{script: []*api.ReplicaSet{replicaSetWithReady(2, 0), replicaSetWithReady(2, 1)}}, "--timeout=50ms")
+	assert.ErrorIs(t, err, ErrRolloutTimeout)
+	assert.ErrorContains(t, err, "1 of 2 replicas are ready")
+	assert.Equal(t, `Waiting for replicaset "web" rollout to finish: 0 of 2 replicas are ready...
+Waiting for replicaset "web" rollout to finish: 1 of 2 replicas are ready...
+`, out)
+
+	_, err = runRolloutStatus(t, &scriptedReplicaSets{script: []*api.ReplicaSet{nil}}, "--timeout=20ms")
+	assert.ErrorIs(t, err, ErrRolloutTimeout, "an API server that never answers is a timeout")
+}
+
+func TestRolloutStatusOfMissingReplicaSet(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		_, _, err := run(t, address, "rollout", "status", "rs", "missing", "--timeout=1m")
+		assert.True(t, client.IsNotFound(err), "a missing ReplicaSet fails straight away: %v", err)
+
+		createReplicaSet(t, c, "empty", 0)
+		out, _, err := run(t, address, "rollout", "status", "rs", "empty")
+		require.NoError(t, err)
+		assert.Equal(t, "replicaset \"empty\" successfully rolled out\n", out)
+	})
+}
@@ -0,0 +1,76 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+var (
+	ErrInvalidReplicas = errors.New("invalid replica count")
+)
+
+func newScaleCommand(o *options) *cobra.Command {
+	var replicas int32
+	cmd := &cobra.Command{
+		Use:     "scale replicaset NAME --replicas=COUNT",
+		Short:   "Set the number of pods of a ReplicaSet",
+		Example: `  gokubectl scale replicaset nginx-rs --replicas=5`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := replicaSetArg(args)
+			if err != nil {
+				return err
+			}
+			if replicas < 0 {
+				return fmt.Errorf("%w %d: --replicas must not be negative", ErrInvalidReplicas, replicas)
+			}
+			return o.scale(cmd.Context(), name, replicas)
+		},
+	}
+	cmd.Flags().Int32Var(&replicas, "replicas", -1, "The number of pods the ReplicaSet should run")
+	_ = cmd.MarkFlagRequired("replicas")
+	return cmd
+}
+
+// replicaSetArg returns the name of the ReplicaSet given by the resource and name arguments
+func replicaSetArg(args []string) (string, error) {
+	r, err := parseResource(args[0])
+	if err != nil {
+		return "", err
+	}
+	if r.name != replicaSetsResource.name {
+		return "", fmt.Errorf("%w %q: only replicasets are supported", ErrUnknownResource, args[0])
+	}
+	return args[1], nil
+}
+
+// scale sets the replica count of the named ReplicaSet through its scale subresource. The UID read first is
+// sent as a precondition, so a ReplicaSet recreated in the meantime is not scaled by accident.
+func (o *options) scale(ctx context.Context, name string, replicas int32) error {
+	c, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	replicaSets := c.ReplicaSetsIn(namespaceOf(o.namespace))
+	scale, err := replicaSets.GetScale(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	scale.Spec.Replicas = replicas
+	if _, err := replicaSets.UpdateScale(ctx, &api.Scale{ObjectMeta: scale.ObjectMeta, Spec: scale.Spec}); err != nil {
+		if client.IsConflict(err) {
+			return fmt.Errorf("%w (the replicaset changed while it was being scaled, run the command again to retry)", err)
+		}
+		return err
+	}
+	fmt.Fprintf(o.out, "%s %q scaled\n", replicaSetsResource.kind, name)
+	return nil
+}
@@ -0,0 +1,69 @@
+package kubectl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+func TestScale(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		createReplicaSet(t, c, "web", 2)
+
+		out, _, err := run(t, address, "scale", "replicaset", "web", "--replicas=5")
+		require.NoError(t, err)
+		assert.Equal(t, "replicaset \"web\" scaled\n", out)
+		rs, err := c.ReplicaSets().Get(context.Background(), "web")
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), rs.Spec.Replicas)
+
+		out, _, err = run(t, address, "scale", "rs", "web", "--replicas", "0")
+		require.NoError(t, err)
+		assert.Equal(t, "replicaset \"web\" scaled\n", out)
+
+		_, _, err = run(t, address, "scale", "rs", "web", "--replicas=-1")
+		assert.ErrorIs(t, err, ErrInvalidReplicas)
+		_, _, err = run(t, address, "scale", "rs", "web")
+		assert.ErrorContains(t, err, "replicas", "the replica count is required")
+		_, _, err = run(t, address, "scale", "pods", "web", "--replicas=1")
+		assert.ErrorIs(t, err, ErrUnknownResource)
+
+		_, _, err = run(t, address, "scale", "rs", "missing", "--replicas=1")
+		assert.True(t, client.IsNotFound(err))
+		_, _, err = run(t, address, "scale", "rs", "web", "--replicas=1", "-n", "staging")
+		assert.True(t, client.IsNotFound(err), "replicasets of other namespaces are not found")
+
+		_, _, err = run(t, address, "scale", "rs", "web", "--replicas=100000")
+		assert.Regexp(t, `^Error from server \(UnprocessableEntity\): .*spec.replicas must be at most 1000`, FormatError(err))
+	})
+}
+
+func TestScaleReportsConflicts(t *testing.T) {
+	var requests []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(api.Scale{ObjectMeta: api.ObjectMeta{Name: "web", UID: "uid-1"}, Spec: api.ScaleSpec{Replicas: 2}})
+			return
+		}
+		var scale api.Scale
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&scale))
+		assert.Equal(t, "uid-1", scale.UID, "the UID read is a precondition")
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(api.Status{Code: http.StatusConflict, Message: "replicaset was changed: web"})
+	}))
+	defer apiServer.Close()
+
+	_, _, err := run(t, apiServer.URL, "scale", "rs", "web", "--replicas=3")
+	assert.True(t, client.IsConflict(err))
+	assert.Equal(t, []string{"GET /api/v1/namespaces/default/replicasets/web/scale", "PUT /api/v1/namespaces/default/replicasets/web/scale"}, requests)
+	assert.Regexp(t, `^Error from server \(Conflict\): .*run the command again to retry\)$`, FormatError(err))
+}
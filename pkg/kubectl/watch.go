@@ -0,0 +1,295 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+// changeSource calls emit for every change to the objects of a resource after the initial ones, until ctx
+// is done
+type changeSource[T any] func(ctx context.Context, initial []*T, emit func(api.EventType, *T)) error
+
+// watchObjects prints the objects as added, then a row for every change the source reports about them. It
+// returns once ctx is done, e.g. on Ctrl-C.
+func watchObjects[T any](ctx context.Context, o *options, r resource, name string, initial []*T, headers []string,
+	row func(*T) []string, meta func(*T) *api.ObjectMeta, changes changeSource[T]) error {
+	table := &streamTable{out: o.out}
+	rows := [][]string{append([]string{"EVENT"}, headers...)}
+	for _, object := range initial {
+		rows = append(rows, append([]string{string(api.EventAdded)}, row(object)...))
+	}
+	table.write(rows...)
+
+	emit := func(eventType api.EventType, object *T) {
+		m := meta(object)
+		if name != "" && m.Name != name {
+			return
+		}
+		if r.namespaced && namespaceOf(m.Namespace) != namespaceOf(o.namespace) {
+			return
+		}
+		table.write(append([]string{string(eventType)}, row(object)...))
+	}
+	if err := changes(ctx, initial, emit); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// objectWatch is an open watch stream of the objects of a resource
+type objectWatch[T any] struct {
+	next  func() (api.EventType, *T, error)
+	close func() error
+}
+
+// watchFunc opens a watch on the changes to the objects of a resource after resourceVersion
+type watchFunc[T any] func(ctx context.Context, resourceVersion string) (objectWatch[T], error)
+
+// listFunc lists the objects of a resource, along with the resource version they were read at if the API server
+// tells it
+type listFunc[T any] func(ctx context.Context) ([]*T, string, error)
+
+// watchChanges follows the changes to the objects of a resource through the API server. A watch that drops is
+// reopened from the resource version of the last change seen. A watch that ends without a change may be from a
+// resource version the API server no longer keeps, so the objects are listed again, and their changes reported,
+// before the next. Without watch support on the API server, the objects are polled instead.
+func watchChanges[T any](interval time.Duration, watch watchFunc[T], list listFunc[T], meta func(*T) *api.ObjectMeta) changeSource[T] {
+	return func(ctx context.Context, initial []*T, emit func(api.EventType, *T)) error {
+		known := make(map[string]*T, len(initial))
+		resourceVersion := ""
+		for _, object := range initial {
+			known[meta(object).Name] = object
+			resourceVersion = laterResourceVersion(resourceVersion, meta(object).ResourceVersion)
+		}
+
+		for {
+			w, err := watch(ctx, resourceVersion)
+			switch {
+			case errors.Is(err, client.ErrWatchNotSupported):
+				current := make([]*T, 0, len(known))
+				for _, object := range known {
+					current = append(current, object)
+				}
+				sort.Slice(current, func(i, j int) bool { return meta(current[i]).Name < meta(current[j]).Name })
+				withoutVersion := func(ctx context.Context) ([]*T, error) {
+					objects, _, err := list(ctx)
+					return objects, err
+				}
+				return pollChanges(interval, withoutVersion, meta)(ctx, current, emit)
+			case err != nil && !isTransient(err):
+				return err
+			case err == nil:
+				watchedFrom := resourceVersion
+				for {
+					eventType, object, err := w.next()
+					if err != nil {
+						break
+					}
+					if eventType == api.EventDeleted {
+						delete(known, meta(object).Name)
+					} else {
+						known[meta(object).Name] = object
+					}
+					resourceVersion = laterResourceVersion(resourceVersion, meta(object).ResourceVersion)
+					emit(eventType, object)
+				}
+				w.close()
+				if resourceVersion == watchedFrom && ctx.Err() == nil {
+					objects, listedVersion, err := list(ctx)
+					switch {
+					case err == nil:
+						known = emitChanges(known, objects, meta, emit)
+						resourceVersion = laterResourceVersion(resourceVersion, listedVersion)
+						for _, object := range objects {
+							resourceVersion = laterResourceVersion(resourceVersion, meta(object).ResourceVersion)
+						}
+					case !isTransient(err):
+						return err
+					}
+				}
+			}
+
+			// The watch dropped or could not be opened: reopen it after a while
+			if !sleep(ctx, interval) {
+				return nil
+			}
+		}
+	}
+}
+
+// podWatch opens watches on the pods of pods
+func podWatch(pods *client.PodClient) watchFunc[api.Pod] {
+	return func(ctx context.Context, resourceVersion string) (objectWatch[api.Pod], error) {
+		w, err := pods.Watch(ctx, client.PodListOptions{ResourceVersion: resourceVersion})
+		if err != nil {
+			return objectWatch[api.Pod]{}, err
+		}
+		next := func() (api.EventType, *api.Pod, error) {
+			event, err := w.Next()
+			return event.Type, event.Object, err
+		}
+		return objectWatch[api.Pod]{next: next, close: w.Close}, nil
+	}
+}
+
+// nodeWatch opens watches on the nodes of nodes
+func nodeWatch(nodes *client.NodeClient) watchFunc[api.Node] {
+	return func(ctx context.Context, resourceVersion string) (objectWatch[api.Node], error) {
+		w, err := nodes.Watch(ctx, resourceVersion)
+		if err != nil {
+			return objectWatch[api.Node]{}, err
+		}
+		next := func() (api.EventType, *api.Node, error) {
+			event, err := w.Next()
+			return event.Type, event.Object, err
+		}
+		return objectWatch[api.Node]{next: next, close: w.Close}, nil
+	}
+}
+
+// replicaSetWatch opens watches on the ReplicaSets of replicaSets
+func replicaSetWatch(replicaSets *client.ReplicaSetClient) watchFunc[api.ReplicaSet] {
+	return func(ctx context.Context, resourceVersion string) (objectWatch[api.ReplicaSet], error) {
+		w, err := replicaSets.Watch(ctx, resourceVersion)
+		if err != nil {
+			return objectWatch[api.ReplicaSet]{}, err
+		}
+		next := func() (api.EventType, *api.ReplicaSet, error) {
+			event, err := w.Next()
+			return event.Type, event.Object, err
+		}
+		return objectWatch[api.ReplicaSet]{next: next, close: w.Close}, nil
+	}
+}
+
+// pollChanges lists the objects every interval and reports how they differ from the previous listing. It is
+// used for the resources the API server cannot watch.
+func pollChanges[T any](interval time.Duration, list func(context.Context) ([]*T, error), meta func(*T) *api.ObjectMeta) changeSource[T] {
+	return func(ctx context.Context, initial []*T, emit func(api.EventType, *T)) error {
+		known := make(map[string]*T, len(initial))
+		for _, object := range initial {
+			known[meta(object).Name] = object
+		}
+
+		for sleep(ctx, interval) {
+			objects, err := list(ctx)
+			if err != nil {
+				if isTransient(err) {
+					continue
+				}
+				return err
+			}
+			known = emitChanges(known, objects, meta, emit)
+		}
+		return nil
+	}
+}
+
+// emitChanges reports how the listed objects differ from the known ones, by name, and returns the listed ones
+// by name
+func emitChanges[T any](known map[string]*T, objects []*T, meta func(*T) *api.ObjectMeta, emit func(api.EventType, *T)) map[string]*T {
+	current := make(map[string]*T, len(objects))
+	for _, object := range objects {
+		name := meta(object).Name
+		current[name] = object
+		previous, ok := known[name]
+		switch {
+		case !ok:
+			emit(api.EventAdded, object)
+		case !sameExceptVersion(previous, object, meta):
+			emit(api.EventModified, object)
+		}
+	}
+	var deleted []string
+	for name := range known {
+		if _, ok := current[name]; !ok {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		emit(api.EventDeleted, known[name])
+	}
+	return current
+}
+
+// sameExceptVersion checks if two versions of an object differ in more than their resource version, which
+// also changes with writes that leave the object as it was
+func sameExceptVersion[T any](previous, object *T, meta func(*T) *api.ObjectMeta) bool {
+	a, b := *previous, *object
+	meta(&a).ResourceVersion, meta(&b).ResourceVersion = "", ""
+	return reflect.DeepEqual(&a, &b)
+}
+
+// laterResourceVersion returns the later of two resource versions, which are etcd revisions
+func laterResourceVersion(a, b string) string {
+	revisionA, errA := strconv.ParseInt(a, 10, 64)
+	revisionB, errB := strconv.ParseInt(b, 10, 64)
+	switch {
+	case errB != nil:
+		return a
+	case errA != nil || revisionB > revisionA:
+		return b
+	default:
+		return a
+	}
+}
+
+// sleep waits for d, or less if ctx is done first. It returns false when ctx is done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		d = DefaultPollInterval
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// isTransient checks if err is worth retrying because the API server is unreachable or failing for now
+func isTransient(err error) bool {
+	return errors.Is(err, client.ErrUnreachable) || client.IsServerError(err)
+}
+
+// streamTable prints the rows of a table as they come. Columns are as wide as the widest cell written so
+// far, so rows written later line up with the earlier ones unless they hold a wider cell.
+type streamTable struct {
+	out    io.Writer
+	widths []int
+}
+
+func (t *streamTable) write(rows ...[]string) {
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(t.widths) {
+				t.widths = append(t.widths, 0)
+			}
+			t.widths[i] = max(t.widths[i], len(cell))
+		}
+	}
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			if i == len(row)-1 {
+				line.WriteString(cell)
+				break
+			}
+			fmt.Fprintf(&line, "%-*s", t.widths[i]+3, cell)
+		}
+		fmt.Fprintln(t.out, line.String())
+	}
+}
@@ -0,0 +1,244 @@
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+// syncBuffer is a bytes.Buffer a command can write to while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// startWatch runs gokubectl with args in the background, polling every 10ms. Cancelling the returned function
+// stops the command like Ctrl-C does and returns the error it ended with.
+func startWatch(t *testing.T, address string, args ...string) (*syncBuffer, func() error) {
+	t.Helper()
+	out := &syncBuffer{}
+	cmd := newCommand(&options{in: strings.NewReader(""), out: out, errOut: &syncBuffer{},
+		now: func() time.Time { return testNow }, pollInterval: 10 * time.Millisecond})
+	cmd.SetArgs(append([]string{"--server", address}, args...))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// A failed test still stops the command, which would keep the watch of the API server open
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+	return out, func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("the watch did not stop when interrupted")
+			return nil
+		}
+	}
+}
+
+func TestGetWatchFollowsPodChanges(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		ctx := context.Background()
+		seedPod(t, c, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web-1"}, Status: api.PodPending}, time.Minute)
+
+		out, stop := startWatch(t, address, "get", "pods", "--watch")
+		require.Eventually(t, func() bool { return strings.Contains(out.String(), "web-1") }, 5*time.Second, 10*time.Millisecond)
+
+		// Every change is watched, so web-2 is created in a single one
+		_, err := c.Pods().Create(ctx, &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web-2", CreationTimestamp: testNow},
+			Spec: api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}}})
+		require.NoError(t, err)
+		pod, err := c.Pods().Get(ctx, "web-1")
+		require.NoError(t, err)
+		pod.NodeName, pod.Status = "node-1", api.PodRunning
+		_, err = c.Pods().Update(ctx, pod)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return strings.Count(out.String(), "\n") == 4 }, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, c.Pods().Delete(ctx, "web-2"))
+		require.Eventually(t, func() bool { return strings.Contains(out.String(), "DELETED") }, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, stop())
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		require.Len(t, lines, 5)
+		assert.Regexp(t, `^EVENT +NAME +`, lines[0])
+		assert.Regexp(t, `^ADDED +web-1 +.*Pending`, lines[1])
+		assert.ElementsMatch(t, []string{"ADDED web-2 Pending", "MODIFIED web-1 Running"}, []string{summary(lines[2]), summary(lines[3])})
+		assert.Regexp(t, `^DELETED +web-2 `, lines[4])
+	})
+}
+
+// summary keeps the event, name and status of a watched pod row
+func summary(line string) string {
+	fields := strings.Fields(line)
+	return fields[0] + " " + fields[1] + " " + fields[2]
+}
+
+func TestGetWatchReplicaSetsByName(t *testing.T) {
+	withAPIServer(t, func(t *testing.T, address string, c *client.Client) {
+		createReplicaSet(t, c, "web", 1)
+		createReplicaSet(t, c, "api", 1)
+
+		out, stop := startWatch(t, address, "get", "rs", "web", "-w")
+		require.Eventually(t, func() bool { return strings.Contains(out.String(), "web") }, 5*time.Second, 10*time.Millisecond)
+		_, _, err := run(t, address, "scale", "rs", "api", "--replicas=2")
+		require.NoError(t, err)
+		_, _, err = run(t, address, "scale", "rs", "web", "--replicas=3")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return strings.Contains(out.String(), "MODIFIED") }, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, stop())
+
+		assert.NotContains(t, out.String(), "api", "only the named replicaset is watched")
+		assert.Regexp(t, `\nMODIFIED +web +3 `, out.String())
+	})
+}
+
+func TestGetWatchResumesAfterTheWatchDrops(t *testing.T) {
+	pod := func(name, resourceVersion string, status api.PodStatus) *api.Pod {
+		return &api.Pod{ObjectMeta: api.ObjectMeta{Name: name, ResourceVersion: resourceVersion, CreationTimestamp: testNow}, Status: status}
+	}
+	var mu sync.Mutex
+	var watches []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			_ = json.NewEncoder(w).Encode([]*api.Pod{pod("web-1", "7", api.PodPending), pod("web-2", "5", api.PodRunning)})
+			return
+		}
+		mu.Lock()
+		watches = append(watches, r.URL.Query().Get("resourceVersion"))
+		n := len(watches)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", api.WatchContentType)
+		encoder := json.NewEncoder(w)
+		switch n {
+		case 1:
+			// Dropped after a single event
+			_ = encoder.Encode(api.PodWatchEvent{Type: api.EventModified, Object: pod("web-1", "9", api.PodRunning)})
+		case 2:
+			_ = encoder.Encode(api.PodWatchEvent{Type: api.EventDeleted, Object: pod("web-2", "12", api.PodRunning)})
+			// Another namespace
+			other := pod("cache", "13", api.PodRunning)
+			other.Namespace = "staging"
+			_ = encoder.Encode(api.PodWatchEvent{Type: api.EventAdded, Object: other})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer apiServer.Close()
+
+	out, stop := startWatch(t, apiServer.URL, "get", "pods", "-w")
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "DELETED") }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, stop())
+
+	mu.Lock()
+	assert.Equal(t, []string{"7", "9"}, watches, "the watch starts after the list and resumes after the last event")
+	mu.Unlock()
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	assert.Regexp(t, `^ADDED +web-1 `, lines[1])
+	assert.Regexp(t, `^ADDED +web-2 `, lines[2])
+	assert.Regexp(t, `^MODIFIED +web-1 +.*Running`, lines[3])
+	assert.Regexp(t, `^DELETED +web-2 `, lines[4])
+}
+
+func TestGetWatchRelistsAfterAnEmptyWatch(t *testing.T) {
+	pod := func(resourceVersion string, status api.PodStatus) *api.Pod {
+		return &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web-1", ResourceVersion: resourceVersion, CreationTimestamp: testNow}, Status: status}
+	}
+	var mu sync.Mutex
+	var lists int
+	var watches []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Query().Get("watch") != "true" {
+			lists++
+			if lists == 1 {
+				_ = json.NewEncoder(w).Encode([]*api.Pod{pod("7", api.PodPending)})
+				return
+			}
+			// The pod changed while the watch was from a resource version the API server no longer had
+			w.Header().Set(api.ResourceVersionHeader, "20")
+			_ = json.NewEncoder(w).Encode([]*api.Pod{pod("8", api.PodRunning)})
+			return
+		}
+		watches = append(watches, r.URL.Query().Get("resourceVersion"))
+		w.Header().Set("Content-Type", api.WatchContentType)
+		// Every watch ends without an event
+	}))
+	defer apiServer.Close()
+
+	out, stop := startWatch(t, apiServer.URL, "get", "pods", "-w")
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(watches) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, stop())
+
+	mu.Lock()
+	assert.Equal(t, []string{"7", "20", "20"}, watches[:3], "the watch resumes from the resource version of the list")
+	mu.Unlock()
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3, "the lists after the first find no change")
+	assert.Regexp(t, `^ADDED +web-1 +.*Pending`, lines[1])
+	assert.Regexp(t, `^MODIFIED +web-1 +.*Running`, lines[2])
+}
+
+func TestGetWatchPollsWithoutWatchSupport(t *testing.T) {
+	var mu sync.Mutex
+	var lists int
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		// An API server that cannot watch lists the nodes instead
+		nodes := []*api.Node{{ObjectMeta: api.ObjectMeta{Name: "node-1", CreationTimestamp: testNow}, Status: api.NodeReady}}
+		if lists++; lists > 2 {
+			nodes = append(nodes, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-2", CreationTimestamp: testNow}, Status: api.NodeReady})
+		}
+		_ = json.NewEncoder(w).Encode(nodes)
+	}))
+	defer apiServer.Close()
+
+	out, stop := startWatch(t, apiServer.URL, "get", "nodes", "-w")
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "node-2") }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, stop())
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^ADDED +node-1 `, lines[1])
+	assert.Regexp(t, `^ADDED +node-2 `, lines[2])
+}
+
+func TestGetWatchRejectsOutputFormats(t *testing.T) {
+	_, _, err := run(t, "localhost:1", "get", "pods", "-w", "-o", "json")
+	assert.ErrorIs(t, err, ErrUnknownOutputFormat)
+}
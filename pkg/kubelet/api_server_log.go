@@ -0,0 +1,70 @@
+package kubelet
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// apiServerLog logs the errors of the loops that talk to the API server. While the API server cannot be
+// reached, e.g. because it starts after the kubelet, every poll fails the same way, so only the first
+// connection failure is logged until a request succeeds again. The zero value is ready to use.
+type apiServerLog struct {
+	mutex       sync.Mutex
+	unreachable bool
+	// logger writes the log lines; it defaults to the default logger
+	logger *slog.Logger
+	// logf writes the log lines instead of logger, for tests
+	logf func(format string, args ...any)
+}
+
+// Error logs err with the given message, unless it is a connection failure while the API server is already
+// known to be unreachable
+func (l *apiServerLog) Error(err error, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if !isConnectionError(err) {
+		l.printf("%s: %v", message, err)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.unreachable {
+		return
+	}
+	l.unreachable = true
+	l.printf("%s, API server is unreachable, retrying until it is back: %v", message, err)
+}
+
+// Reachable records a successful request to the API server
+func (l *apiServerLog) Reachable() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.unreachable {
+		l.unreachable = false
+		l.printf("API server is reachable again")
+	}
+}
+
+func (l *apiServerLog) printf(format string, args ...any) {
+	if l.logf == nil {
+		logger := l.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Warn(fmt.Sprintf(format, args...))
+		return
+	}
+	l.logf(format, args...)
+}
+
+// isConnectionError checks if err is a failure to connect to the API server, such as a refused connection,
+// rather than an error answered by the API server
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,51 @@
+package kubelet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logRecorder struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (r *logRecorder) logf(format string, args ...any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestAPIServerLog(t *testing.T) {
+	// Nothing listens on port 1, so the request fails the way it does while the API server is down
+	_, connErr := http.Get("http://127.0.0.1:1/api/v1/pods")
+	require.True(t, isConnectionError(fmt.Errorf("failed to send request to API server: %w", connErr)))
+
+	recorder := &logRecorder{}
+	apiLog := &apiServerLog{logf: recorder.logf}
+
+	for i := 0; i < 3; i++ {
+		apiLog.Error(connErr, "Error getting pod assignments")
+		apiLog.Error(connErr, "Error updating node status")
+	}
+	require.Len(t, recorder.lines, 1, "only the first connection failure should be logged")
+	assert.Contains(t, recorder.lines[0], "Error getting pod assignments, API server is unreachable")
+
+	// Errors answered by the API server are always logged
+	apiLog.Error(errors.New("status code: 500"), "Error updating node status")
+	apiLog.Error(errors.New("status code: 500"), "Error updating node status")
+	assert.Len(t, recorder.lines, 3)
+
+	apiLog.Reachable()
+	apiLog.Reachable()
+	assert.Equal(t, "API server is reachable again", recorder.lines[3])
+
+	apiLog.Error(connErr, "Error getting pod assignments")
+	assert.Len(t, recorder.lines, 5, "an outage after a recovery should be logged again")
+}
@@ -0,0 +1,85 @@
+package kubelet
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRestartBackoff is how long the kubelet waits before restarting a container a second time
+	DefaultRestartBackoff = 10 * time.Second
+	// MaxRestartBackoff caps the delay between restarts of a container that keeps crashing
+	MaxRestartBackoff = 5 * time.Minute
+)
+
+// backoff tracks an exponentially growing delay per key. The delay starts at initial,
+// doubles every time Next is called while the previous delay is still remembered, and never exceeds max.
+type backoff struct {
+	mutex   sync.Mutex
+	initial time.Duration
+	max     time.Duration
+	now     func() time.Time
+	entries map[string]*backoffEntry
+}
+
+type backoffEntry struct {
+	delay     time.Duration
+	lastStart time.Time
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	return &backoff{
+		initial: initial,
+		max:     max,
+		now:     time.Now,
+		entries: make(map[string]*backoffEntry),
+	}
+}
+
+// InBackoff checks if the delay recorded by the last call to Next for key has not elapsed yet
+func (b *backoff) InBackoff(key string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok {
+		return false
+	}
+	return b.now().Before(entry.lastStart.Add(entry.delay))
+}
+
+// Next records an attempt for key and grows the delay before the following attempt
+func (b *backoff) Next(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry, ok := b.entries[key]
+	if !ok {
+		b.entries[key] = &backoffEntry{delay: b.initial, lastStart: b.now()}
+		return
+	}
+	entry.delay *= 2
+	if entry.delay > b.max {
+		entry.delay = b.max
+	}
+	entry.lastStart = b.now()
+}
+
+// Delay returns the current delay for key, zero if no attempt was recorded
+func (b *backoff) Delay(key string) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if entry, ok := b.entries[key]; ok {
+		return entry.delay
+	}
+	return 0
+}
+
+// Reset forgets the delay recorded for key
+func (b *backoff) Reset(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.entries, key)
+}
@@ -0,0 +1,38 @@
+package kubelet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	now := time.Now()
+	b := newBackoff(DefaultRestartBackoff, MaxRestartBackoff)
+	b.now = func() time.Time { return now }
+
+	assert.False(t, b.InBackoff("pod/c1"), "a key without attempts is never in backoff")
+
+	b.Next("pod/c1")
+	assert.Equal(t, 10*time.Second, b.Delay("pod/c1"))
+	assert.True(t, b.InBackoff("pod/c1"))
+	assert.False(t, b.InBackoff("pod/c2"))
+
+	now = now.Add(10 * time.Second)
+	assert.False(t, b.InBackoff("pod/c1"))
+
+	b.Next("pod/c1")
+	assert.Equal(t, 20*time.Second, b.Delay("pod/c1"))
+	now = now.Add(10 * time.Second)
+	assert.True(t, b.InBackoff("pod/c1"))
+
+	for i := 0; i < 10; i++ {
+		b.Next("pod/c1")
+	}
+	assert.Equal(t, MaxRestartBackoff, b.Delay("pod/c1"))
+
+	b.Reset("pod/c1")
+	assert.False(t, b.InBackoff("pod/c1"))
+	assert.Equal(t, time.Duration(0), b.Delay("pod/c1"))
+}
@@ -0,0 +1,69 @@
+package kubelet
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"gokube/pkg/api"
+)
+
+const (
+	meminfoPath = "/proc/meminfo"
+	// fallbackMemory is reported when the total memory cannot be read, so the node never looks bigger than it is
+	fallbackMemory int64 = 1 << 30
+)
+
+// nodeCapacity describes the resources of the machine the kubelet runs on
+func nodeCapacity(maxPods int32) api.NodeCapacity {
+	memory, err := totalMemory(meminfoPath)
+	if err != nil {
+		memory = fallbackMemory
+	}
+
+	return api.NodeCapacity{
+		CPU:     int64(runtime.NumCPU()),
+		Memory:  memory,
+		MaxPods: maxPods,
+	}
+}
+
+// totalMemory reads the MemTotal entry of a meminfo file and returns it in bytes
+func totalMemory(path string) (int64, error) {
+	return readMeminfo(path, "MemTotal")
+}
+
+// availableMemory reads the MemAvailable entry of a meminfo file, the memory that can be used without
+// swapping, and returns it in bytes
+func availableMemory(path string) (int64, error) {
+	return readMeminfo(path, "MemAvailable")
+}
+
+// readMeminfo reads the named entry of a meminfo file and returns it in bytes
+func readMeminfo(path, name string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != name+":" {
+			continue
+		}
+		kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: %w", name, fields[1], err)
+		}
+		return kilobytes * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s not found in %s", name, path)
+}
@@ -0,0 +1,44 @@
+package kubelet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTotalMemory(t *testing.T) {
+	dir := t.TempDir()
+
+	meminfo := filepath.Join(dir, "meminfo")
+	require.NoError(t, os.WriteFile(meminfo, []byte("MemTotal:       16314668 kB\nMemFree:         1234 kB\n"), 0o644))
+	memory, err := totalMemory(meminfo)
+	require.NoError(t, err)
+	assert.Equal(t, int64(16314668*1024), memory)
+
+	missing := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(missing, []byte("MemFree: 1234 kB\n"), 0o644))
+	_, err = totalMemory(missing)
+	assert.Error(t, err)
+
+	_, err = totalMemory(filepath.Join(dir, "does-not-exist"))
+	assert.Error(t, err)
+}
+
+func TestAvailableMemory(t *testing.T) {
+	meminfo := filepath.Join(t.TempDir(), "meminfo")
+	require.NoError(t, os.WriteFile(meminfo, []byte("MemTotal:       16314668 kB\nMemAvailable:    524288 kB\n"), 0o644))
+
+	memory, err := availableMemory(meminfo)
+	require.NoError(t, err)
+	assert.Equal(t, int64(512<<20), memory)
+}
+
+func TestNodeCapacity(t *testing.T) {
+	capacity := nodeCapacity(DefaultMaxPods)
+	assert.Greater(t, capacity.CPU, int64(0))
+	assert.Greater(t, capacity.Memory, int64(0))
+	assert.Equal(t, int32(DefaultMaxPods), capacity.MaxPods)
+}
@@ -0,0 +1,219 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+
+	"github.com/distribution/reference"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+)
+
+// DockerRuntime runs containers with the Docker daemon
+type DockerRuntime struct {
+	dockerClient *client.Client
+
+	mutex sync.Mutex
+	// imageIDs caches the resolved image of every image ID and reference, as images do not change
+	imageIDs map[string]string
+}
+
+// NewDockerRuntime creates a ContainerRuntime backed by the given Docker client
+func NewDockerRuntime(dockerClient *client.Client) *DockerRuntime {
+	return &DockerRuntime{dockerClient: dockerClient}
+}
+
+func (r *DockerRuntime) ImagePresent(ctx context.Context, ref string) (bool, error) {
+	if _, _, err := r.dockerClient.ImageInspectWithRaw(ctx, ref); err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *DockerRuntime) PullImage(ctx context.Context, ref string) error {
+	out, err := r.dockerClient.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	logger := logging.Component("kubelet").With("image", ref)
+	return readPullProgress(ref, out, func(format string, args ...any) {
+		logger.Info(fmt.Sprintf(format, args...))
+	})
+}
+
+func (r *DockerRuntime) CreateContainer(ctx context.Context, name, image string, labels map[string]string, ports []api.ContainerPort) (string, error) {
+	exposedPorts, portBindings := nat.PortSet{}, nat.PortMap{}
+	for _, port := range ports {
+		if port.HostPort == 0 {
+			continue
+		}
+		containerPort := nat.Port(fmt.Sprintf("%d/tcp", port.ContainerPort))
+		exposedPorts[containerPort] = struct{}{}
+		portBindings[containerPort] = append(portBindings[containerPort], nat.PortBinding{HostPort: strconv.Itoa(int(port.HostPort))})
+	}
+	resp, err := r.dockerClient.ContainerCreate(ctx, &dockercontainer.Config{
+		Image:        image,
+		Labels:       labels,
+		ExposedPorts: exposedPorts,
+	}, &dockercontainer.HostConfig{PortBindings: portBindings}, nil, nil, name)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (r *DockerRuntime) StartContainer(ctx context.Context, containerID string) error {
+	return r.dockerClient.ContainerStart(ctx, containerID, dockercontainer.StartOptions{})
+}
+
+func (r *DockerRuntime) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
+	seconds := int(timeout.Seconds())
+	err := r.dockerClient.ContainerStop(ctx, containerID, dockercontainer.StopOptions{Timeout: &seconds})
+	if client.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+	}
+	return err
+}
+
+func (r *DockerRuntime) RemoveContainer(ctx context.Context, containerID string) error {
+	err := r.dockerClient.ContainerRemove(ctx, containerID, dockercontainer.RemoveOptions{Force: true})
+	if client.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+	}
+	return err
+}
+
+func (r *DockerRuntime) ListContainers(ctx context.Context) ([]Container, error) {
+	containers, err := r.dockerClient.ContainerList(ctx, dockercontainer.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", LabelPodName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Container, 0, len(containers))
+	for _, c := range containers {
+		rc := Container{
+			ID:            c.ID,
+			PodNamespace:  c.Labels[LabelPodNamespace],
+			PodName:       c.Labels[LabelPodName],
+			PodUID:        c.Labels[LabelPodUID],
+			ContainerName: c.Labels[LabelContainerName],
+			NodeName:      c.Labels[LabelNodeName],
+			Running:       c.State == "running",
+			Created:       time.Unix(c.Created, 0),
+			ImageID:       r.resolveImageID(ctx, c.ImageID, c.Image),
+
+			TerminationGracePeriod: TerminationGracePeriodFromLabels(c.Labels),
+		}
+		// The list does not carry exit codes, so exited containers are inspected
+		if !rc.Running {
+			inspected, err := r.InspectContainer(ctx, c.ID)
+			if errors.Is(err, ErrContainerNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			rc.ExitCode = inspected.ExitCode
+		}
+		result = append(result, rc)
+	}
+	return result, nil
+}
+
+func (r *DockerRuntime) InspectContainer(ctx context.Context, containerID string) (Container, error) {
+	info, err := r.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return Container{}, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+		}
+		return Container{}, err
+	}
+
+	rc := Container{ID: info.ID}
+	if info.Config != nil {
+		rc.PodNamespace = info.Config.Labels[LabelPodNamespace]
+		rc.PodName = info.Config.Labels[LabelPodName]
+		rc.PodUID = info.Config.Labels[LabelPodUID]
+		rc.ContainerName = info.Config.Labels[LabelContainerName]
+		rc.NodeName = info.Config.Labels[LabelNodeName]
+		rc.TerminationGracePeriod = TerminationGracePeriodFromLabels(info.Config.Labels)
+		rc.ImageID = r.resolveImageID(ctx, info.Image, info.Config.Image)
+	}
+	if info.State != nil {
+		rc.Running = info.State.Running
+		rc.ExitCode = info.State.ExitCode
+	}
+	if created, err := time.Parse(time.RFC3339Nano, info.Created); err == nil {
+		rc.Created = created
+	}
+	return rc, nil
+}
+
+// resolveImageID returns the repo digest of the image with the given ID that belongs to the repository of
+// ref, the reference the container was created from. Images without a repo digest, e.g. built locally,
+// are identified by their ID.
+func (r *DockerRuntime) resolveImageID(ctx context.Context, imageID, ref string) string {
+	key := imageID + " " + ref
+	r.mutex.Lock()
+	resolved, ok := r.imageIDs[key]
+	r.mutex.Unlock()
+	if ok {
+		return resolved
+	}
+
+	inspected, _, err := r.dockerClient.ImageInspectWithRaw(ctx, imageID)
+	if err != nil {
+		// Not cached, so the next call tries again
+		return imageID
+	}
+	resolved = repoDigest(inspected.RepoDigests, ref)
+	if resolved == "" {
+		resolved = imageID
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.imageIDs == nil {
+		r.imageIDs = make(map[string]string)
+	}
+	r.imageIDs[key] = resolved
+	return resolved
+}
+
+// repoDigest picks the repo digest of the repository ref names, such as nginx@sha256:<digest> for nginx:latest.
+// An image can be in several repositories, so any repo digest is the fallback.
+func repoDigest(repoDigests []string, ref string) string {
+	if len(repoDigests) == 0 {
+		return ""
+	}
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return repoDigests[0]
+	}
+	for _, digest := range repoDigests {
+		candidate, err := reference.ParseNormalizedNamed(digest)
+		if err == nil && candidate.Name() == named.Name() {
+			return reference.FamiliarString(candidate)
+		}
+	}
+	return repoDigests[0]
+}
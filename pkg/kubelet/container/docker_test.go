@@ -0,0 +1,20 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoDigest(t *testing.T) {
+	const (
+		nginxDigest  = "nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+		mirrorDigest = "registry.example.com/nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+	)
+	repoDigests := []string{mirrorDigest, "docker.io/library/" + nginxDigest}
+
+	assert.Equal(t, nginxDigest, repoDigest(repoDigests, "nginx:latest"), "the digest of the repository the image was pulled from")
+	assert.Equal(t, mirrorDigest, repoDigest(repoDigests, "registry.example.com/nginx:1.27"))
+	assert.Equal(t, mirrorDigest, repoDigest(repoDigests, "other:latest"), "any digest of an image tagged elsewhere")
+	assert.Empty(t, repoDigest(nil, "nginx:latest"), "a locally built image has no digest")
+}
@@ -0,0 +1,315 @@
+package fakeruntime
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+)
+
+// FakeRuntime is an in-memory ContainerRuntime. Tests script container states with Add and Exit, make
+// calls fail through the error fields, and inspect the calls the kubelet made through Calls.
+type FakeRuntime struct {
+	// PullErr, CreateErr, StartErr and ListErr are returned by the corresponding calls when set.
+	// Set them before the runtime is handed to the code under test.
+	PullErr   error
+	CreateErr error
+	StartErr  error
+	ListErr   error
+
+	mutex      sync.Mutex
+	containers map[string]*kubecontainer.Container
+	startErrs  map[string]error
+	trapping   map[string]bool
+	stops      map[string]stop
+	images     map[string]bool
+	nextID     int
+	calls      []string
+	created    []string
+	stopped    []string
+	removed    []string
+}
+
+var _ kubecontainer.ContainerRuntime = &FakeRuntime{}
+
+// New creates an empty FakeRuntime
+func New() *FakeRuntime {
+	return &FakeRuntime{
+		containers: make(map[string]*kubecontainer.Container),
+		images:     make(map[string]bool),
+	}
+}
+
+// Add puts a container into the runtime as if some kubelet had created it
+func (f *FakeRuntime) Add(c kubecontainer.Container) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.containers[c.ID] = &c
+}
+
+// AddImage makes the image present without a pull
+func (f *FakeRuntime) AddImage(ref string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.images[ref] = true
+}
+
+// FailStart makes starting the containers with the given container name fail with err, or succeed again
+// when err is nil
+func (f *FakeRuntime) FailStart(containerName string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.startErrs == nil {
+		f.startErrs = make(map[string]error)
+	}
+	f.startErrs[containerName] = err
+}
+
+// TrapSIGTERM makes the containers with the given container name exit cleanly when they are asked to stop
+// with a grace period, like a process that handles SIGTERM. Other containers die of the SIGTERM, and all
+// containers are killed when stopped without a grace period.
+func (f *FakeRuntime) TrapSIGTERM(containerName string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.trapping == nil {
+		f.trapping = make(map[string]bool)
+	}
+	f.trapping[containerName] = true
+}
+
+// stop records how a container was stopped
+type stop struct {
+	timeout  time.Duration
+	exitCode int
+}
+
+// StopResult returns the grace period the container was last stopped with and the exit code it stopped with,
+// which tells a graceful exit from a kill even after the container was removed
+func (f *FakeRuntime) StopResult(containerID string) (time.Duration, int, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	result, ok := f.stops[containerID]
+	return result.timeout, result.exitCode, ok
+}
+
+// Exit makes a running container exit with the given code
+func (f *FakeRuntime) Exit(containerID string, exitCode int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if c, ok := f.containers[containerID]; ok {
+		c.Running = false
+		c.ExitCode = exitCode
+	}
+}
+
+// Running returns the IDs of the running containers of the pod
+func (f *FakeRuntime) Running(podName string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ids []string
+	for _, c := range f.containers {
+		if c.PodName == podName && c.Running {
+			ids = append(ids, c.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Calls returns every call made to the runtime in order, e.g. "StartContainer container-1"
+func (f *FakeRuntime) Calls() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
+
+// Created returns the IDs of the containers created through the runtime
+func (f *FakeRuntime) Created() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.created...)
+}
+
+// Stopped returns the IDs of the containers stopped through the runtime
+func (f *FakeRuntime) Stopped() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.stopped...)
+}
+
+// Removed returns the IDs of the containers removed through the runtime
+func (f *FakeRuntime) Removed() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.removed...)
+}
+
+func (f *FakeRuntime) record(call string, args ...string) {
+	f.calls = append(f.calls, strings.Join(append([]string{call}, args...), " "))
+}
+
+func (f *FakeRuntime) ImagePresent(ctx context.Context, ref string) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("ImagePresent", ref)
+	return f.images[ref], nil
+}
+
+func (f *FakeRuntime) PullImage(ctx context.Context, ref string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("PullImage", ref)
+	if f.PullErr != nil {
+		return f.PullErr
+	}
+	f.images[ref] = true
+	return nil
+}
+
+func (f *FakeRuntime) CreateContainer(ctx context.Context, name, image string, labels map[string]string, ports []api.ContainerPort) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("CreateContainer", name)
+	if f.CreateErr != nil {
+		return "", f.CreateErr
+	}
+	f.nextID++
+	id := fmt.Sprintf("container-%d", f.nextID)
+	f.containers[id] = &kubecontainer.Container{
+		ID:            id,
+		PodNamespace:  labels[kubecontainer.LabelPodNamespace],
+		PodName:       labels[kubecontainer.LabelPodName],
+		PodUID:        labels[kubecontainer.LabelPodUID],
+		ContainerName: labels[kubecontainer.LabelContainerName],
+		NodeName:      labels[kubecontainer.LabelNodeName],
+		Created:       time.Unix(int64(f.nextID), 0),
+		ImageID:       ImageID(image),
+
+		TerminationGracePeriod: kubecontainer.TerminationGracePeriodFromLabels(labels),
+	}
+	f.created = append(f.created, id)
+	return id, nil
+}
+
+func (f *FakeRuntime) StartContainer(ctx context.Context, containerID string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("StartContainer", containerID)
+	if f.StartErr != nil {
+		return f.StartErr
+	}
+	c, ok := f.containers[containerID]
+	if !ok {
+		return fmt.Errorf("%w: %s", kubecontainer.ErrContainerNotFound, containerID)
+	}
+	if err := f.startErrs[c.ContainerName]; err != nil {
+		return err
+	}
+	c.Running = true
+	return nil
+}
+
+func (f *FakeRuntime) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("StopContainer", containerID)
+	c, ok := f.containers[containerID]
+	if !ok {
+		return fmt.Errorf("%w: %s", kubecontainer.ErrContainerNotFound, containerID)
+	}
+	if c.Running {
+		c.Running = false
+		switch {
+		case timeout == 0:
+			// Killed with SIGKILL
+			c.ExitCode = 137
+		case f.trapping[c.ContainerName]:
+			c.ExitCode = 0
+		default:
+			// Died of the SIGTERM
+			c.ExitCode = 143
+		}
+	}
+	if f.stops == nil {
+		f.stops = make(map[string]stop)
+	}
+	f.stops[containerID] = stop{timeout: timeout, exitCode: c.ExitCode}
+	f.stopped = append(f.stopped, containerID)
+	return nil
+}
+
+func (f *FakeRuntime) RemoveContainer(ctx context.Context, containerID string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("RemoveContainer", containerID)
+	if _, ok := f.containers[containerID]; !ok {
+		return fmt.Errorf("%w: %s", kubecontainer.ErrContainerNotFound, containerID)
+	}
+	delete(f.containers, containerID)
+	f.removed = append(f.removed, containerID)
+	return nil
+}
+
+func (f *FakeRuntime) ListContainers(ctx context.Context) ([]kubecontainer.Container, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("ListContainers")
+	if f.ListErr != nil {
+		return nil, f.ListErr
+	}
+	containers := make([]kubecontainer.Container, 0, len(f.containers))
+	for _, c := range f.containers {
+		containers = append(containers, *c)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].ID < containers[j].ID })
+	return containers, nil
+}
+
+func (f *FakeRuntime) InspectContainer(ctx context.Context, containerID string) (kubecontainer.Container, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.record("InspectContainer", containerID)
+	c, ok := f.containers[containerID]
+	if !ok {
+		return kubecontainer.Container{}, fmt.Errorf("%w: %s", kubecontainer.ErrContainerNotFound, containerID)
+	}
+	return *c, nil
+}
+
+// ImageID returns the image ID the fake runtime reports for containers created from ref: the reference itself
+// when it names a digest, else a digest derived from the reference, as if the registry resolved it
+func ImageID(ref string) string {
+	if api.ImageDigest(ref) != "" {
+		return ref
+	}
+	name := ref
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name = ref[:i]
+	}
+	return fmt.Sprintf("%s@sha256:%x", name, sha256.Sum256([]byte(ref)))
+}
@@ -0,0 +1,92 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// pullProgressMilestones are the download percentages logged while an image is pulled
+var pullProgressMilestones = []int{25, 50, 75}
+
+// layerProgress is the download progress of one layer of an image
+type layerProgress struct {
+	current int64
+	total   int64
+}
+
+// readPullProgress consumes the JSON progress stream of an image pull and logs a line when the pull
+// starts, when the download crosses a milestone and when it finishes. The daemon reports failures such
+// as a missing manifest inside the stream, so those are returned as errors.
+func readPullProgress(ref string, stream io.Reader, logf func(format string, args ...any)) error {
+	logf("Pulling image %s", ref)
+
+	layers := make(map[string]*layerProgress)
+	// order keeps the layers in the order they were reported, so the percentage is computed deterministically
+	var order []string
+	nextMilestone := 0
+
+	decoder := json.NewDecoder(stream)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			logf("Failed to pull image %s: %v", ref, err)
+			return fmt.Errorf("failed to read pull progress: %w", err)
+		}
+
+		if message.Error != nil || message.ErrorMessage != "" {
+			errMessage := message.ErrorMessage
+			if message.Error != nil {
+				errMessage = message.Error.Message
+			}
+			logf("Failed to pull image %s: %s", ref, errMessage)
+			return errors.New(errMessage)
+		}
+
+		if message.ID == "" {
+			continue
+		}
+		layer, ok := layers[message.ID]
+		if !ok {
+			layer = &layerProgress{}
+			layers[message.ID] = layer
+			order = append(order, message.ID)
+		}
+		switch message.Status {
+		case "Downloading":
+			if message.Progress != nil {
+				layer.current, layer.total = message.Progress.Current, message.Progress.Total
+			}
+		case "Download complete", "Pull complete", "Already exists":
+			layer.current = layer.total
+		}
+
+		percent := downloadPercent(layers, order)
+		for nextMilestone < len(pullProgressMilestones) && percent >= pullProgressMilestones[nextMilestone] {
+			logf("Pulling image %s: %d%% downloaded", ref, pullProgressMilestones[nextMilestone])
+			nextMilestone++
+		}
+	}
+
+	logf("Pulled image %s", ref)
+	return nil
+}
+
+// downloadPercent returns how much of the layers reported so far has been downloaded
+func downloadPercent(layers map[string]*layerProgress, order []string) int {
+	var current, total int64
+	for _, id := range order {
+		current += layers[id].current
+		total += layers[id].total
+	}
+	if total == 0 {
+		return 0
+	}
+	return int(current * 100 / total)
+}
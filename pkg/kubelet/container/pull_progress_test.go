@@ -0,0 +1,76 @@
+package container
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logRecorder collects the lines logged while reading a pull stream
+type logRecorder struct {
+	lines []string
+}
+
+func (r *logRecorder) logf(format string, args ...any) {
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func TestReadPullProgress(t *testing.T) {
+	t.Run("should log start, milestones and completion", func(t *testing.T) {
+		stream := strings.Join([]string{
+			`{"status":"Pulling from library/nginx","id":"latest"}`,
+			`{"status":"Pulling fs layer","id":"a"}`,
+			`{"status":"Pulling fs layer","id":"b"}`,
+			`{"status":"Downloading","progressDetail":{"current":30,"total":100},"id":"a"}`,
+			`{"status":"Downloading","progressDetail":{"current":10,"total":100},"id":"b"}`,
+			`{"status":"Downloading","progressDetail":{"current":100,"total":100},"id":"a"}`,
+			`{"status":"Download complete","id":"a"}`,
+			`{"status":"Downloading","progressDetail":{"current":90,"total":100},"id":"b"}`,
+			`{"status":"Download complete","id":"b"}`,
+			`{"status":"Pull complete","id":"a"}`,
+			`{"status":"Pull complete","id":"b"}`,
+			`{"status":"Digest: sha256:abc"}`,
+			`{"status":"Status: Downloaded newer image for nginx:latest"}`,
+		}, "\n")
+		recorder := &logRecorder{}
+
+		require.NoError(t, readPullProgress("nginx", strings.NewReader(stream), recorder.logf))
+		assert.Equal(t, []string{
+			"Pulling image nginx",
+			"Pulling image nginx: 25% downloaded",
+			"Pulling image nginx: 50% downloaded",
+			"Pulling image nginx: 75% downloaded",
+			"Pulled image nginx",
+		}, recorder.lines)
+	})
+
+	t.Run("should fail on an error in the stream", func(t *testing.T) {
+		stream := strings.Join([]string{
+			`{"status":"Pulling from library/nginx","id":"does-not-exist"}`,
+			`{"errorDetail":{"message":"manifest for nginx:does-not-exist not found"},"error":"manifest for nginx:does-not-exist not found"}`,
+		}, "\n")
+		recorder := &logRecorder{}
+
+		err := readPullProgress("nginx:does-not-exist", strings.NewReader(stream), recorder.logf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "manifest for nginx:does-not-exist not found")
+		assert.Equal(t, "Failed to pull image nginx:does-not-exist: manifest for nginx:does-not-exist not found", recorder.lines[len(recorder.lines)-1])
+	})
+
+	t.Run("should fail on a truncated stream", func(t *testing.T) {
+		recorder := &logRecorder{}
+
+		assert.Error(t, readPullProgress("nginx", strings.NewReader(`{"status":"Downloading","id":`), recorder.logf))
+	})
+
+	t.Run("should not log milestones for cached layers", func(t *testing.T) {
+		stream := `{"status":"Already exists","id":"a"}` + "\n" + `{"status":"Status: Image is up to date for nginx:latest"}`
+		recorder := &logRecorder{}
+
+		require.NoError(t, readPullProgress("nginx", strings.NewReader(stream), recorder.logf))
+		assert.Equal(t, []string{"Pulling image nginx", "Pulled image nginx"}, recorder.lines)
+	})
+}
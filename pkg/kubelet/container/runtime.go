@@ -0,0 +1,74 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// Labels the kubelet puts on every container it creates
+const (
+	LabelPodName       = "gokube.pod.name"
+	LabelPodNamespace  = "gokube.pod.namespace"
+	LabelPodUID        = "gokube.pod.uid"
+	LabelContainerName = "gokube.container.name"
+	// LabelNodeName keeps kubelets sharing a container runtime away from each other's containers
+	LabelNodeName = "gokube.node.name"
+	// LabelTerminationGracePeriod is the grace period of the pod in seconds, so the container can be stopped
+	// gracefully after its pod is gone
+	LabelTerminationGracePeriod = "gokube.pod.terminationGracePeriod"
+)
+
+var (
+	ErrContainerNotFound = errors.New("container not found")
+)
+
+// Container is a container as seen by the container runtime
+type Container struct {
+	ID            string
+	PodNamespace  string
+	PodName       string
+	PodUID        string
+	ContainerName string
+	NodeName      string
+	Running       bool
+	ExitCode      int
+	Created       time.Time
+	// ImageID is the image the container runs: the repo digest of a pulled image, such as
+	// nginx@sha256:<digest>, or the local image ID of an image that has none
+	ImageID string
+	// TerminationGracePeriod is how long the container gets to exit when it is stopped
+	TerminationGracePeriod time.Duration
+}
+
+// TerminationGracePeriodFromLabels returns the grace period recorded in the labels of a container, or the
+// default one for containers created without it
+func TerminationGracePeriodFromLabels(labels map[string]string) time.Duration {
+	seconds, err := strconv.ParseInt(labels[LabelTerminationGracePeriod], 10, 64)
+	if err != nil || seconds < 0 {
+		seconds = api.DefaultTerminationGracePeriodSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ContainerRuntime is the part of the container runtime the kubelet manages pod containers with
+type ContainerRuntime interface {
+	// ImagePresent checks if the image is available without pulling it
+	ImagePresent(ctx context.Context, ref string) (bool, error)
+	PullImage(ctx context.Context, ref string) error
+	// CreateContainer creates a stopped container that publishes the ports with a host port on the node, and
+	// returns its ID
+	CreateContainer(ctx context.Context, name, image string, labels map[string]string, ports []api.ContainerPort) (string, error)
+	StartContainer(ctx context.Context, containerID string) error
+	// StopContainer asks the container to exit with SIGTERM and kills it once timeout has passed
+	StopContainer(ctx context.Context, containerID string, timeout time.Duration) error
+	// RemoveContainer removes the container, killing it first if it is running
+	RemoveContainer(ctx context.Context, containerID string) error
+	// ListContainers returns every container carrying the gokube pod labels, running or not
+	ListContainers(ctx context.Context) ([]Container, error)
+	// InspectContainer returns the current state of a container, or an error wrapping ErrContainerNotFound
+	InspectContainer(ctx context.Context, containerID string) (Container, error)
+}
@@ -0,0 +1,142 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/logging"
+)
+
+// garbageCollectContainers removes the orphan containers left behind by a previous run of the kubelet, then
+// periodically removes orphans and dead containers the kubelet no longer needs
+func (k *Kubelet) garbageCollectContainers() {
+	ticker := time.NewTicker(k.options.ContainerGCPeriod)
+	defer ticker.Stop()
+
+	for {
+		if err := k.sweepOrphanContainers(context.Background()); err != nil {
+			k.apiServerLog.Error(err, "Error sweeping orphan containers")
+		}
+		select {
+		case <-ticker.C:
+		case <-k.done:
+			return
+		}
+		if err := k.collectDeadContainers(context.Background()); err != nil {
+			k.log().Error("Error collecting dead containers", logging.Err(err))
+		}
+	}
+}
+
+// sweepOrphanContainers removes the containers of this node whose pod exists neither in the kubelet nor on
+// the API server, e.g. because the pod was deleted while the kubelet was down. Containers created within the
+// OrphanGracePeriod are kept, as their pod may have been created after the pods were listed, and so are the
+// MaxDeadContainersPerPod newest dead containers of every orphaned pod. Nothing is removed when the API
+// server cannot be asked.
+func (k *Kubelet) sweepOrphanContainers(ctx context.Context) error {
+	pods, err := k.getPodAssignments()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]struct{}, len(pods))
+	for _, pod := range pods {
+		if pod.NodeName == k.nodeName {
+			existing[podKey(pod.Namespace, pod.Name, pod.UID)] = struct{}{}
+		}
+	}
+
+	containers, err := k.runtime.ListContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	// Dead orphans grouped by the pod instance that created them
+	deadByPod := make(map[string][]kubecontainer.Container)
+	for _, c := range containers {
+		if c.PodName == "" || !k.ownsContainer(c) || k.now().Sub(c.Created) < k.options.OrphanGracePeriod {
+			continue
+		}
+		key := podKey(c.PodNamespace, c.PodName, c.PodUID)
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		if k.pods.Has(&api.Pod{ObjectMeta: api.ObjectMeta{Namespace: c.PodNamespace, Name: c.PodName, UID: c.PodUID}}) {
+			continue // Static pods and pods assigned since the list
+		}
+		if !c.Running {
+			deadByPod[key] = append(deadByPod[key], c)
+			continue
+		}
+		k.removeOrphanContainer(ctx, c)
+	}
+
+	for _, dead := range deadByPod {
+		sort.Slice(dead, func(i, j int) bool { return dead[i].Created.After(dead[j].Created) })
+		for _, c := range dead[min(k.options.MaxDeadContainersPerPod, len(dead)):] {
+			k.removeOrphanContainer(ctx, c)
+		}
+	}
+	return nil
+}
+
+// removeOrphanContainer removes a container whose pod is gone, stopping it within its grace period first if
+// it is still running
+func (k *Kubelet) removeOrphanContainer(ctx context.Context, c kubecontainer.Container) {
+	var err error
+	if c.Running {
+		err = k.stopContainer(ctx, c.ID, c.TerminationGracePeriod)
+	} else {
+		err = k.runtime.RemoveContainer(ctx, c.ID)
+	}
+	if err != nil && !errors.Is(err, kubecontainer.ErrContainerNotFound) {
+		k.log().Error("Error removing orphan container", "pod", c.PodName, "containerID", c.ID, logging.Err(err))
+		return
+	}
+	k.log().Info("Removed orphan container, the pod no longer exists", "pod", c.PodName, "containerID", c.ID)
+}
+
+// collectDeadContainers removes the dead containers of pods this kubelet no longer runs, keeping the
+// MaxDeadContainersPerPod most recent ones of every pod for debugging. A pod counts as gone when it is not
+// tracked or when the tracked pod has a different UID, i.e. it was deleted and recreated under the same name.
+// Containers without the gokube labels are never touched.
+func (k *Kubelet) collectDeadContainers(ctx context.Context) error {
+	containers, err := k.runtime.ListContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	// Dead containers grouped by the pod instance that created them
+	deadByPod := make(map[string][]kubecontainer.Container)
+	for _, c := range containers {
+		if c.PodName == "" || c.Running || !k.ownsContainer(c) {
+			continue
+		}
+		if pod, tracked := k.pods.Get(c.PodNamespace, c.PodName); tracked && pod.UID == c.PodUID {
+			continue // Still owned by a running pod
+		}
+		key := podKey(c.PodNamespace, c.PodName, c.PodUID)
+		deadByPod[key] = append(deadByPod[key], c)
+	}
+
+	for key, dead := range deadByPod {
+		if len(dead) <= k.options.MaxDeadContainersPerPod {
+			continue
+		}
+		// Newest first, so the ones past the retention limit are the oldest
+		sort.Slice(dead, func(i, j int) bool { return dead[i].Created.After(dead[j].Created) })
+		for _, c := range dead[k.options.MaxDeadContainersPerPod:] {
+			if err := k.runtime.RemoveContainer(ctx, c.ID); err != nil {
+				k.log().Error("Error removing dead container", "pod", key, "containerID", c.ID, logging.Err(err))
+				continue
+			}
+			k.log().Info("Removed dead container", "pod", key, "containerID", c.ID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+func gokubeContainer(id, podName, podUID string, running bool, created int64) kubecontainer.Container {
+	return kubecontainer.Container{
+		ID:            id,
+		PodName:       podName,
+		PodUID:        podUID,
+		ContainerName: "app",
+		NodeName:      "node-1",
+		Running:       running,
+		Created:       time.Unix(created, 0),
+	}
+}
+
+func TestCollectDeadContainers(t *testing.T) {
+	runtime := fakeruntime.New()
+	for _, c := range []kubecontainer.Container{
+		// Pod that is gone: only the newest dead container is kept
+		gokubeContainer("gone-1", "gone", "uid-gone", false, 1),
+		gokubeContainer("gone-2", "gone", "uid-gone", false, 3),
+		gokubeContainer("gone-3", "gone", "uid-gone", false, 2),
+		// Tracked pod: its dead containers are left to the restart logic
+		gokubeContainer("web-1", "web", "uid-web", false, 1),
+		gokubeContainer("web-2", "web", "uid-web", false, 2),
+		// Same name as a tracked pod but an older UID: the pod was recreated
+		gokubeContainer("web-old-1", "web", "uid-web-old", false, 1),
+		gokubeContainer("web-old-2", "web", "uid-web-old", false, 2),
+		// Running containers are never collected
+		gokubeContainer("running-1", "gone", "uid-gone", true, 0),
+		// Containers without gokube labels are never collected
+		{ID: "foreign-1", Created: time.Unix(1, 0)},
+		{ID: "foreign-2", Created: time.Unix(2, 0)},
+		// Neither are the containers of another kubelet sharing the runtime
+		{ID: "other-node-1", PodName: "gone", PodUID: "uid-gone", NodeName: "node-2", Created: time.Unix(1, 0)},
+		{ID: "other-node-2", PodName: "gone", PodUID: "uid-gone", NodeName: "node-2", Created: time.Unix(2, 0)},
+	} {
+		runtime.Add(c)
+	}
+
+	kubelet := &Kubelet{nodeName: "node-1", pods: newPodManager(), runtime: runtime, options: DefaultOptions()}
+	kubelet.pods.Add(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", UID: "uid-web"}})
+
+	require.NoError(t, kubelet.collectDeadContainers(context.Background()))
+
+	removed := runtime.Removed()
+	sort.Strings(removed)
+	assert.Equal(t, []string{"gone-1", "gone-3", "web-old-1"}, removed)
+}
+
+func TestCollectDeadContainersRetention(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keep    int
+		removed []string
+	}{
+		{name: "keep none", keep: 0, removed: []string{"c1", "c2", "c3"}},
+		{name: "keep newest two", keep: 2, removed: []string{"c1"}},
+		{name: "keep more than exist", keep: 5, removed: nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runtime := fakeruntime.New()
+			runtime.Add(gokubeContainer("c1", "gone", "uid", false, 1))
+			runtime.Add(gokubeContainer("c2", "gone", "uid", false, 2))
+			runtime.Add(gokubeContainer("c3", "gone", "uid", false, 3))
+			kubelet := &Kubelet{nodeName: "node-1", pods: newPodManager(), runtime: runtime, options: DefaultOptions()}
+			kubelet.options.MaxDeadContainersPerPod = tc.keep
+
+			require.NoError(t, kubelet.collectDeadContainers(context.Background()))
+
+			removed := runtime.Removed()
+			sort.Strings(removed)
+			assert.Equal(t, tc.removed, removed)
+		})
+	}
+}
+
+func TestSweepOrphanContainers(t *testing.T) {
+	listed := []*api.Pod{
+		{ObjectMeta: api.ObjectMeta{Name: "web", UID: "uid-web"}, NodeName: "node-1", Status: api.PodRunning},
+		// Moved to another node; its containers here are orphans
+		{ObjectMeta: api.ObjectMeta{Name: "moved", UID: "uid-moved"}, NodeName: "node-2", Status: api.PodRunning},
+	}
+	fakeAPI := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(listed)
+	})
+	kubelet := newTestKubelet(t, fakeAPI)
+	now := kubelet.now().Unix()
+
+	runtime := fakeruntime.New()
+	for _, c := range []kubecontainer.Container{
+		// The pod still exists
+		gokubeContainer("web-1", "web", "uid-web", true, 1),
+		// A deleted pod: running containers are removed, the newest dead one is kept for debugging
+		gokubeContainer("gone-running", "gone", "uid-gone", true, 1),
+		gokubeContainer("gone-dead-1", "gone", "uid-gone", false, 2),
+		gokubeContainer("gone-dead-2", "gone", "uid-gone", false, 3),
+		gokubeContainer("moved-1", "moved", "uid-moved", true, 1),
+		// An earlier pod with the name of an existing one
+		gokubeContainer("web-old", "web", "uid-web-old", true, 1),
+		// Created within the grace period; its pod may not be listed yet
+		gokubeContainer("new-1", "new", "uid-new", true, now-10),
+		// Tracked by the kubelet but not on the API server, like a static pod
+		gokubeContainer("static-1", "static-node-1", "uid-static", true, 1),
+		// Containers of other kubelets and without gokube labels are never touched
+		{ID: "other-node", PodName: "gone", PodUID: "uid-gone", NodeName: "node-2", Running: true, Created: time.Unix(1, 0)},
+		{ID: "foreign", Running: true, Created: time.Unix(1, 0)},
+	} {
+		runtime.Add(c)
+	}
+	kubelet.runtime = runtime
+	kubelet.pods.Add(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "static-node-1", UID: "uid-static"}, NodeName: "node-1"})
+
+	require.NoError(t, kubelet.sweepOrphanContainers(context.Background()))
+
+	removed := runtime.Removed()
+	sort.Strings(removed)
+	assert.Equal(t, []string{"gone-dead-1", "gone-running", "moved-1", "web-old"}, removed)
+}
+
+func TestSweepOrphanContainersWithoutAPIServer(t *testing.T) {
+	kubelet := newTestKubelet(t, http.NotFoundHandler())
+	runtime := fakeruntime.New()
+	runtime.Add(gokubeContainer("gone-1", "gone", "uid-gone", true, 1))
+	kubelet.runtime = runtime
+
+	assert.Error(t, kubelet.sweepOrphanContainers(context.Background()))
+	assert.Empty(t, runtime.Removed(), "nothing may be removed when the pods cannot be listed")
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package kubelet
+
+import "syscall"
+
+// diskAvailablePercent returns the percentage of the disk holding path that unprivileged users can still use
+func diskAvailablePercent(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	if stat.Blocks == 0 {
+		return 100, nil
+	}
+	return float64(stat.Bavail) / float64(stat.Blocks) * 100, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package kubelet
+
+import (
+	"errors"
+	"runtime"
+)
+
+// diskAvailablePercent is only implemented on Linux, where the kubelet runs its containers
+func diskAvailablePercent(path string) (float64, error) {
+	return 0, errors.New("disk usage is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,23 @@
+package kubelet
+
+import (
+	"context"
+
+	"gokube/pkg/api"
+)
+
+// recordEvent reports an event about object to the API server. Events are informational, so a failure is
+// only logged.
+func (k *Kubelet) recordEvent(object api.ObjectReference, eventType, reason, message string) {
+	event := &api.Event{
+		InvolvedObject: object,
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         "kubelet/" + k.nodeName,
+		FirstTimestamp: k.now().UTC(),
+	}
+	if _, err := k.apiClient.Events().Create(context.Background(), event); err != nil {
+		k.apiServerLog.Error(err, "Error recording event %s for %s %s", reason, object.Kind, object.Name)
+	}
+}
@@ -0,0 +1,205 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/logging"
+)
+
+// EvictedReason is the reason of the event recorded for a pod evicted from a node under pressure
+const EvictedReason = "Evicted"
+
+// nodeUsage is a sample of the resources left on the node. Only the resources with an eviction threshold
+// are sampled.
+type nodeUsage struct {
+	memoryAvailable      int64
+	diskAvailablePercent float64
+}
+
+// nodePressure holds the pressure conditions the kubelet reports on its node. The zero value is ready to use.
+type nodePressure struct {
+	mutex      sync.Mutex
+	conditions []api.NodeCondition
+}
+
+// Set records whether the node is under the given pressure and reports whether that changed
+func (p *nodePressure) Set(conditionType api.NodeStatus, under bool, message string, now time.Time) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := api.ConditionFalse
+	reason := "NodeHasNo" + string(conditionType)
+	if under {
+		status = api.ConditionTrue
+		reason = "NodeHas" + string(conditionType)
+	}
+	condition := api.NodeCondition{Type: conditionType, Status: status, Reason: reason, Message: message, LastTransitionTime: now}
+
+	for i := range p.conditions {
+		if p.conditions[i].Type != conditionType {
+			continue
+		}
+		changed := p.conditions[i].Status != status
+		if !changed {
+			condition.LastTransitionTime = p.conditions[i].LastTransitionTime
+		}
+		p.conditions[i] = condition
+		return changed
+	}
+	p.conditions = append(p.conditions, condition)
+	return under
+}
+
+// Conditions returns a copy of the pressure conditions
+func (p *nodePressure) Conditions() []api.NodeCondition {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return append([]api.NodeCondition(nil), p.conditions...)
+}
+
+// sampleNodeUsage reads the available memory and the available disk of DockerRootDir
+func (k *Kubelet) sampleNodeUsage() (nodeUsage, error) {
+	var usage nodeUsage
+	var err error
+	if k.options.EvictionMemoryAvailable > 0 {
+		if usage.memoryAvailable, err = availableMemory(meminfoPath); err != nil {
+			return usage, fmt.Errorf("failed to read available memory: %w", err)
+		}
+	}
+	if k.options.EvictionDiskAvailablePercent > 0 {
+		if usage.diskAvailablePercent, err = diskAvailablePercent(k.options.DockerRootDir); err != nil {
+			return usage, fmt.Errorf("failed to read available disk of %s: %w", k.options.DockerRootDir, err)
+		}
+	}
+	return usage, nil
+}
+
+// checkNodePressure samples the resources of the node, updates the pressure conditions and, while the node
+// is under pressure, evicts a pod. One pod is evicted per check, so the next check sees the resources the
+// eviction freed before evicting more.
+func (k *Kubelet) checkNodePressure(ctx context.Context) {
+	usage, err := k.sampleUsage()
+	if err != nil {
+		k.log().Error("Error sampling node usage, skipping the pressure check", logging.Err(err))
+		return
+	}
+
+	var pressure, message string
+	if threshold := k.options.EvictionMemoryAvailable; threshold > 0 {
+		under := usage.memoryAvailable < threshold
+		conditionMessage := fmt.Sprintf("%d bytes of memory are available, the eviction threshold is %d bytes", usage.memoryAvailable, threshold)
+		k.setPressure(api.NodeMemoryPressure, under, conditionMessage)
+		if under {
+			pressure, message = string(api.NodeMemoryPressure), conditionMessage
+		}
+	}
+	if threshold := k.options.EvictionDiskAvailablePercent; threshold > 0 {
+		under := usage.diskAvailablePercent < float64(threshold)
+		conditionMessage := fmt.Sprintf("%.1f%% of the disk of %s is available, the eviction threshold is %d%%", usage.diskAvailablePercent, k.options.DockerRootDir, threshold)
+		k.setPressure(api.NodeDiskPressure, under, conditionMessage)
+		if under && pressure == "" {
+			pressure, message = string(api.NodeDiskPressure), conditionMessage
+		}
+	}
+
+	if pressure != "" {
+		k.evictPodForPressure(ctx, pressure, message)
+	}
+}
+
+// setPressure records a pressure condition and reports a change of it as a node event
+func (k *Kubelet) setPressure(conditionType api.NodeStatus, under bool, message string) {
+	if !k.pressure.Set(conditionType, under, message, k.now().UTC()) {
+		return
+	}
+
+	node := api.ObjectReference{Kind: api.KindNode, Name: k.nodeName, UID: k.nodeUID}
+	if under {
+		k.log().Warn("Node is under pressure", "condition", conditionType, "message", message)
+		k.recordEvent(node, api.EventTypeWarning, "NodeHas"+string(conditionType), message)
+		return
+	}
+	k.log().Info("Node is no longer under pressure", "condition", conditionType)
+	k.recordEvent(node, api.EventTypeNormal, "NodeHasNo"+string(conditionType), message)
+}
+
+// evictPodForPressure evicts the first pod in eviction order through the API server and stops it
+func (k *Kubelet) evictPodForPressure(ctx context.Context, pressure, message string) {
+	candidates, err := k.evictionCandidates(ctx)
+	if err != nil {
+		k.log().Error("Error choosing a pod to evict", "pressure", pressure, logging.Err(err))
+		return
+	}
+	if len(candidates) == 0 {
+		k.log().Warn("Node is under pressure, but no pod can be evicted", "pressure", pressure)
+		return
+	}
+
+	pod := candidates[0]
+	message = fmt.Sprintf("The node was low on resources: %s", message)
+	if err := k.evictPod(pod, pressure, message); err != nil {
+		k.apiServerLog.Error(err, "Error evicting pod %s for %s", pod.Name, pressure)
+		return
+	}
+
+	k.log().Warn("Evicted pod", "pod", pod.Name, "pressure", pressure)
+	k.recordEvent(api.ObjectReference{Kind: api.KindPod, Name: pod.Name, UID: pod.UID}, api.EventTypeWarning, EvictedReason, message)
+	k.removePod(pod.Namespace, pod.Name)
+}
+
+// evictionCandidates returns the pods that may be evicted in eviction order: pods of lower priority first,
+// and among pods of the same priority the most recently started first. Static pods are never evicted, as
+// the API server cannot remove them from the node.
+func (k *Kubelet) evictionCandidates(ctx context.Context) ([]*api.Pod, error) {
+	containers, err := k.runtime.ListContainers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	// A pod started when its oldest container was created
+	started := make(map[string]time.Time)
+	for _, c := range containers {
+		if !k.ownsContainer(c) {
+			continue
+		}
+		key := podKey(c.PodNamespace, c.PodName, c.PodUID)
+		if first, ok := started[key]; !ok || c.Created.Before(first) {
+			started[key] = c.Created
+		}
+	}
+
+	var candidates []*api.Pod
+	for _, pod := range k.pods.List() {
+		if !k.staticPods.Has(pod.Namespace, pod.Name) {
+			candidates = append(candidates, pod)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority < b.Spec.Priority
+		}
+		startedA, startedB := started[podKey(a.Namespace, a.Name, a.UID)], started[podKey(b.Namespace, b.Name, b.UID)]
+		if !startedA.Equal(startedB) {
+			return startedA.After(startedB)
+		}
+		return a.Name < b.Name
+	})
+	return candidates, nil
+}
+
+// evictPod asks the API server to evict the pod. A pod that is already gone counts as evicted.
+func (k *Kubelet) evictPod(pod *api.Pod, reason, message string) error {
+	err := k.apiClient.PodsIn(pod.Namespace).Evict(context.Background(), pod.Name, &api.Eviction{Reason: reason, Message: message})
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("failed to evict pod: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+// fakeEvictionAPI records the evictions and events a kubelet sends
+type fakeEvictionAPI struct {
+	mutex   sync.Mutex
+	evicted []string
+	events  []api.Event
+}
+
+func (f *fakeEvictionAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/eviction"):
+		f.evicted = append(f.evicted, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/pods/"), "/eviction"))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/events":
+		var event api.Event
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		f.events = append(f.events, event)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (f *fakeEvictionAPI) reasons() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var reasons []string
+	for _, event := range f.events {
+		reasons = append(reasons, event.InvolvedObject.Name+" "+event.Reason)
+	}
+	return reasons
+}
+
+// addRunningPod tracks a pod whose single container was created at the given time
+func addRunningPod(kubelet *Kubelet, runtime *fakeruntime.FakeRuntime, name string, priority int32, created time.Time) {
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name, UID: name + "-uid"},
+		NodeName:   "node-1",
+		Status:     api.PodRunning,
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "app"}}, Priority: priority},
+	}
+	kubelet.pods.Add(pod)
+	runtime.Add(kubecontainer.Container{
+		ID:            name + "-container",
+		PodName:       name,
+		PodUID:        pod.UID,
+		ContainerName: "app",
+		NodeName:      "node-1",
+		Running:       true,
+		Created:       created,
+	})
+}
+
+func TestEvictionCandidates(t *testing.T) {
+	kubelet := newTestKubelet(t, http.NotFoundHandler())
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	addRunningPod(kubelet, runtime, "old", 0, start)
+	addRunningPod(kubelet, runtime, "new", 0, start.Add(time.Hour))
+	addRunningPod(kubelet, runtime, "important", 100, start.Add(2*time.Hour))
+	addRunningPod(kubelet, runtime, "static-node-1", 0, start.Add(3*time.Hour))
+	kubelet.staticPods.pods = map[string]*staticPod{podFullName("", "static-node-1"): {}}
+
+	candidates, err := kubelet.evictionCandidates(context.Background())
+	require.NoError(t, err)
+
+	var names []string
+	for _, pod := range candidates {
+		names = append(names, pod.Name)
+	}
+	assert.Equal(t, []string{"new", "old", "important"}, names, "lower priority and more recently started pods go first, static pods never")
+}
+
+func TestCheckNodePressure(t *testing.T) {
+	fakeAPI := &fakeEvictionAPI{}
+	kubelet := newTestKubelet(t, fakeAPI)
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+	kubelet.stopPod = kubelet.killPod
+	kubelet.options.EvictionMemoryAvailable = 100 << 20
+	usage := nodeUsage{memoryAvailable: 50 << 20}
+	kubelet.sampleUsage = func() (nodeUsage, error) { return usage, nil }
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	addRunningPod(kubelet, runtime, "old", 0, start)
+	addRunningPod(kubelet, runtime, "new", 0, start.Add(time.Hour))
+
+	assert.False(t, DefaultOptions().evictionEnabled(), "eviction is disabled by default")
+	assert.Empty(t, kubelet.nodeStatus().Conditions)
+
+	// Under pressure one pod is evicted per check
+	kubelet.checkNodePressure(context.Background())
+
+	condition := kubelet.nodeStatus().GetCondition(api.NodeMemoryPressure)
+	require.NotNil(t, condition)
+	assert.Equal(t, api.ConditionTrue, condition.Status)
+	assert.Equal(t, []string{"new"}, fakeAPI.evicted)
+	assert.Equal(t, []string{"node-1 NodeHasMemoryPressure", "new Evicted"}, fakeAPI.reasons())
+	_, tracked := kubelet.pods.Get("", "new")
+	assert.False(t, tracked)
+
+	kubelet.syncPods(context.Background())
+	assert.Empty(t, runtime.Running("new"), "the evicted pod is stopped")
+	assert.Len(t, runtime.Running("old"), 1)
+
+	// Once enough memory is free again, nothing more is evicted
+	usage.memoryAvailable = 200 << 20
+	kubelet.checkNodePressure(context.Background())
+
+	assert.Equal(t, api.ConditionFalse, kubelet.nodeStatus().GetCondition(api.NodeMemoryPressure).Status)
+	assert.Equal(t, []string{"new"}, fakeAPI.evicted)
+	assert.Equal(t, "node-1 NodeHasNoMemoryPressure", fakeAPI.reasons()[2])
+	assert.Len(t, runtime.Running("old"), 1)
+}
@@ -0,0 +1,141 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// ImagePullState is the progress of the most recent pull of an image
+type ImagePullState string
+
+const (
+	ImagePulling    ImagePullState = "Pulling"
+	ImagePullFailed ImagePullState = ErrImagePullReason
+	ImagePulled     ImagePullState = "Pulled"
+
+	// DefaultImageCacheTTL is how long a successful pull is trusted before the runtime is asked again
+	DefaultImageCacheTTL = 5 * time.Minute
+)
+
+var (
+	ErrImagePullBackOff = errors.New("back-off pulling image")
+)
+
+// imagePuller makes images available in the container runtime
+type imagePuller interface {
+	ImagePresent(ctx context.Context, ref string) (bool, error)
+	PullImage(ctx context.Context, ref string) error
+}
+
+// imageManager ensures images are present before containers are created. Concurrent requests for the
+// same image share one pull, failed pulls are retried no sooner than an exponential backoff allows,
+// and recent successes are cached so starting another container from the same image costs nothing.
+// A tag can be moved to another image, so a pulled tag is only trusted for cacheTTL, while a digest names
+// one image forever and stays trusted once present.
+type imageManager struct {
+	puller   imagePuller
+	backoff  *backoff
+	cacheTTL time.Duration
+	now      func() time.Time
+
+	mutex  sync.Mutex
+	images map[string]*imageEntry
+}
+
+type imageEntry struct {
+	state    ImagePullState
+	err      error
+	pulledAt time.Time
+	// done is closed when the pull in progress finishes
+	done chan struct{}
+}
+
+func newImageManager(puller imagePuller) *imageManager {
+	return &imageManager{
+		puller:   puller,
+		backoff:  newBackoff(DefaultImagePullBackoff, MaxImagePullBackoff),
+		cacheTTL: DefaultImageCacheTTL,
+		now:      time.Now,
+		images:   make(map[string]*imageEntry),
+	}
+}
+
+// EnsureImage returns once ref is present, pulling it if needed. It returns an error wrapping
+// ErrImagePull if the pull failed, or ErrImagePullBackOff if the last failure is too recent to retry.
+func (m *imageManager) EnsureImage(ctx context.Context, ref string) error {
+	m.mutex.Lock()
+	entry, ok := m.images[ref]
+	if ok {
+		switch {
+		case entry.state == ImagePulled && (api.ImageDigest(ref) != "" || m.now().Sub(entry.pulledAt) < m.cacheTTL):
+			m.mutex.Unlock()
+			return nil
+		case entry.state == ImagePulling:
+			done := entry.done
+			m.mutex.Unlock()
+			return m.wait(ctx, ref, done)
+		case entry.state == ImagePullFailed && m.backoff.InBackoff(ref):
+			err := entry.err
+			m.mutex.Unlock()
+			return fmt.Errorf("%w %s: %v", ErrImagePullBackOff, ref, err)
+		}
+	}
+
+	entry = &imageEntry{state: ImagePulling, done: make(chan struct{})}
+	m.images[ref] = entry
+	m.mutex.Unlock()
+
+	err := m.pull(ctx, ref)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err != nil {
+		entry.state = ImagePullFailed
+		entry.err = fmt.Errorf("%w %s: %v", ErrImagePull, ref, err)
+		m.backoff.Next(ref)
+	} else {
+		entry.state = ImagePulled
+		entry.pulledAt = m.now()
+		m.backoff.Reset(ref)
+	}
+	close(entry.done)
+	return entry.err
+}
+
+// State returns the state of the most recent pull of ref and its error, if any
+func (m *imageManager) State(ref string) (ImagePullState, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, ok := m.images[ref]
+	if !ok {
+		return "", nil
+	}
+	return entry.state, entry.err
+}
+
+func (m *imageManager) pull(ctx context.Context, ref string) error {
+	present, err := m.puller.ImagePresent(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if present {
+		return nil
+	}
+	return m.puller.PullImage(ctx, ref)
+}
+
+func (m *imageManager) wait(ctx context.Context, ref string, done <-chan struct{}) error {
+	select {
+	case <-done:
+		_, err := m.State(ref)
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,163 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeImagePuller counts pulls and can fail or hold them until released
+type fakeImagePuller struct {
+	mutex   sync.Mutex
+	present map[string]bool
+	pullErr error
+	pulls   map[string]int
+	// release, if set, blocks every pull until it is closed
+	release chan struct{}
+}
+
+func (p *fakeImagePuller) ImagePresent(ctx context.Context, ref string) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.present[ref], nil
+}
+
+func (p *fakeImagePuller) PullImage(ctx context.Context, ref string) error {
+	p.mutex.Lock()
+	if p.pulls == nil {
+		p.pulls = make(map[string]int)
+	}
+	p.pulls[ref]++
+	release := p.release
+	p.mutex.Unlock()
+
+	if release != nil {
+		<-release
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.pullErr != nil {
+		return p.pullErr
+	}
+	if p.present == nil {
+		p.present = make(map[string]bool)
+	}
+	p.present[ref] = true
+	return nil
+}
+
+func (p *fakeImagePuller) pullCount(ref string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.pulls[ref]
+}
+
+func TestImageManager_DeduplicatesConcurrentPulls(t *testing.T) {
+	puller := &fakeImagePuller{release: make(chan struct{})}
+	manager := newImageManager(puller)
+
+	const requests = 5
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() { errs <- manager.EnsureImage(context.Background(), "nginx") }()
+	}
+
+	assert.Eventually(t, func() bool {
+		state, _ := manager.State("nginx")
+		return state == ImagePulling && puller.pullCount("nginx") == 1
+	}, time.Second, 10*time.Millisecond)
+
+	close(puller.release)
+	for i := 0; i < requests; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, 1, puller.pullCount("nginx"))
+
+	state, err := manager.State("nginx")
+	assert.Equal(t, ImagePulled, state)
+	assert.NoError(t, err)
+}
+
+func TestImageManager_CachesSuccessfulPulls(t *testing.T) {
+	now := time.Now()
+	puller := &fakeImagePuller{}
+	manager := newImageManager(puller)
+	manager.now = func() time.Time { return now }
+
+	require.NoError(t, manager.EnsureImage(context.Background(), "nginx"))
+	require.NoError(t, manager.EnsureImage(context.Background(), "nginx"))
+	assert.Equal(t, 1, puller.pullCount("nginx"))
+
+	// Once the cache expires the runtime is asked again, but a present image is not pulled twice
+	now = now.Add(DefaultImageCacheTTL)
+	require.NoError(t, manager.EnsureImage(context.Background(), "nginx"))
+	assert.Equal(t, 1, puller.pullCount("nginx"))
+}
+
+func TestImageManager_TrustsPulledDigests(t *testing.T) {
+	const digest = "nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+	now := time.Now()
+	puller := &fakeImagePuller{}
+	manager := newImageManager(puller)
+	manager.now = func() time.Time { return now }
+
+	require.NoError(t, manager.EnsureImage(context.Background(), digest))
+	require.NoError(t, manager.EnsureImage(context.Background(), "nginx:latest"))
+
+	// The runtime lost both images, e.g. to a prune. A tag is checked again once the cache expires, while
+	// a pulled digest cannot have changed and is not.
+	puller.mutex.Lock()
+	puller.present = nil
+	puller.mutex.Unlock()
+	now = now.Add(DefaultImageCacheTTL)
+	require.NoError(t, manager.EnsureImage(context.Background(), digest))
+	require.NoError(t, manager.EnsureImage(context.Background(), "nginx:latest"))
+	assert.Equal(t, 1, puller.pullCount(digest))
+	assert.Equal(t, 2, puller.pullCount("nginx:latest"))
+}
+
+func TestImageManager_BacksOffFailedPulls(t *testing.T) {
+	now := time.Now()
+	puller := &fakeImagePuller{pullErr: errors.New("registry unavailable")}
+	manager := newImageManager(puller)
+	manager.backoff.now = func() time.Time { return now }
+
+	err := manager.EnsureImage(context.Background(), "nginx")
+	assert.ErrorIs(t, err, ErrImagePull)
+	assert.Equal(t, 1, puller.pullCount("nginx"))
+
+	state, stateErr := manager.State("nginx")
+	assert.Equal(t, ImagePullFailed, state)
+	assert.ErrorIs(t, stateErr, ErrImagePull)
+
+	// Within the backoff the pull is not attempted
+	err = manager.EnsureImage(context.Background(), "nginx")
+	assert.ErrorIs(t, err, ErrImagePullBackOff)
+	assert.Equal(t, 1, puller.pullCount("nginx"))
+
+	now = now.Add(DefaultImagePullBackoff)
+	assert.ErrorIs(t, manager.EnsureImage(context.Background(), "nginx"), ErrImagePull)
+	assert.Equal(t, 2, puller.pullCount("nginx"))
+
+	// The delay doubled
+	now = now.Add(DefaultImagePullBackoff)
+	assert.ErrorIs(t, manager.EnsureImage(context.Background(), "nginx"), ErrImagePullBackOff)
+	assert.Equal(t, 2, puller.pullCount("nginx"))
+
+	// A successful pull clears the backoff
+	puller.mutex.Lock()
+	puller.pullErr = nil
+	puller.mutex.Unlock()
+	now = now.Add(DefaultImagePullBackoff)
+	assert.NoError(t, manager.EnsureImage(context.Background(), "nginx"))
+	assert.Equal(t, time.Duration(0), manager.backoff.Delay("nginx"))
+
+	// Other images are not affected by the backoff of nginx
+	assert.NoError(t, manager.EnsureImage(context.Background(), "busybox"))
+}
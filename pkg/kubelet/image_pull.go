@@ -0,0 +1,99 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+)
+
+const (
+	// ErrImagePullReason is reported for a container whose image could not be pulled
+	ErrImagePullReason = "ErrImagePull"
+	// ImagePullBackOff is reported while the next pull of a failed image is being delayed
+	ImagePullBackOff = "ImagePullBackOff"
+
+	DefaultImagePullBackoff = 10 * time.Second
+	MaxImagePullBackoff     = 5 * time.Minute
+)
+
+var (
+	ErrImagePull = errors.New("failed to pull image")
+)
+
+// recordImagePullFailure marks the container as waiting on its image and reports the error to the
+// API server straight away so it is visible without waiting for the next sync
+func (k *Kubelet) recordImagePullFailure(pod *api.Pod, containerName string, err error) {
+	k.setContainerWaiting(pod, containerName, imagePullReason(err), err.Error())
+}
+
+// imagePullReason returns the waiting reason reported for an image pull error
+func imagePullReason(err error) string {
+	if errors.Is(err, ErrImagePullBackOff) {
+		return ImagePullBackOff
+	}
+	return ErrImagePullReason
+}
+
+// retryImagePulls starts the containers of pod whose image pull failed. The image manager decides
+// whether the pull is retried or still backing off.
+func (k *Kubelet) retryImagePulls(ctx context.Context, pod *api.Pod) {
+	for _, spec := range pod.Spec.Containers {
+		current := pod.GetContainerStatus(spec.Name)
+		if !isWaitingForImage(current) {
+			continue
+		}
+
+		containerID, err := k.StartContainer(ctx, pod, spec.Name, spec.Image)
+		if isImagePullError(err) {
+			if errors.Is(err, ErrImagePull) {
+				k.log().Error("Failed to pull image", "pod", pod.Name, "container", spec.Name, logging.Err(err))
+			}
+			k.recordImagePullFailure(pod, spec.Name, err)
+			continue
+		}
+		if err != nil {
+			k.log().Error("Failed to start container", "pod", pod.Name, "container", spec.Name, logging.Err(err))
+			continue
+		}
+
+		k.pods.Mutate(pod, func(p *api.Pod) {
+			p.SetContainerStatus(api.ContainerStatus{Name: spec.Name, ContainerID: containerID, State: api.ContainerRunning})
+		})
+	}
+}
+
+func (k *Kubelet) setContainerWaiting(pod *api.Pod, containerName, reason, message string) {
+	if current, ok := k.pods.Get(pod.Namespace, pod.Name); ok {
+		if existing := current.GetContainerStatus(containerName); existing != nil &&
+			existing.State == api.ContainerWaiting && existing.Reason == reason && existing.Message == message {
+			return
+		}
+	}
+
+	updatedPod, ok := k.pods.Mutate(pod, func(p *api.Pod) {
+		status := api.ContainerStatus{Name: containerName}
+		if existing := p.GetContainerStatus(containerName); existing != nil {
+			status = *existing
+		}
+		status.State = api.ContainerWaiting
+		status.Reason = reason
+		status.Message = message
+		p.SetContainerStatus(status)
+	})
+	if !ok {
+		return
+	}
+	k.updatePodStatus(updatedPod)
+}
+
+func isWaitingForImage(status *api.ContainerStatus) bool {
+	return status != nil && status.State == api.ContainerWaiting &&
+		(status.Reason == ErrImagePullReason || status.Reason == ImagePullBackOff)
+}
+
+func isImagePullError(err error) bool {
+	return errors.Is(err, ErrImagePull) || errors.Is(err, ErrImagePullBackOff)
+}
@@ -0,0 +1,112 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+func TestImagePullFailureIsReportedAndRetried(t *testing.T) {
+	var mutex sync.Mutex
+	var reported []*api.Pod
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		pod := &api.Pod{}
+		_ = json.NewDecoder(r.Body).Decode(pod)
+		mutex.Lock()
+		reported = append(reported, pod)
+		mutex.Unlock()
+	}))
+
+	kubelet.images = newImageManager(&fakeImagePuller{pullErr: errors.New("manifest unknown")})
+	now := time.Now()
+	kubelet.images.backoff.now = func() time.Time { return now }
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "typo"},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "c1", Image: "gokube.invalid/does-not-exist:latest"}}},
+	}
+	kubelet.pods.Add(pod)
+
+	assert.NotPanics(t, func() { kubelet.runPod(pod) })
+
+	// The status is reported by the status queue
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(reported) == 1
+	}, time.Second, time.Millisecond)
+	mutex.Lock()
+	status := reported[0].GetContainerStatus("c1")
+	mutex.Unlock()
+	require.NotNil(t, status)
+	assert.Equal(t, api.ContainerWaiting, status.State)
+	assert.Equal(t, ErrImagePullReason, status.Reason)
+	assert.Contains(t, status.Message, "gokube.invalid/does-not-exist:latest")
+	assert.Contains(t, status.Message, "manifest unknown")
+
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, api.PodScheduled, determinePodStatus(current.ContainerStatuses))
+
+	// Inside the backoff the pull is not retried
+	kubelet.retryImagePulls(context.Background(), current)
+	current, _ = kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, ImagePullBackOff, current.GetContainerStatus("c1").Reason)
+	assert.Equal(t, DefaultImagePullBackoff, kubelet.images.backoff.Delay(pod.Spec.Containers[0].Image))
+
+	// Once it elapses the pull is retried, fails again and the backoff doubles
+	now = now.Add(DefaultImagePullBackoff)
+	kubelet.retryImagePulls(context.Background(), current)
+	current, _ = kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, ErrImagePullReason, current.GetContainerStatus("c1").Reason)
+	assert.Equal(t, 2*DefaultImagePullBackoff, kubelet.images.backoff.Delay(pod.Spec.Containers[0].Image))
+}
+
+func TestContainerStatusReportsTheResolvedImage(t *testing.T) {
+	const digest = "nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31"
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+	kubelet.images = newImageManager(runtime)
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec: api.PodSpec{Containers: []api.Container{
+			{Name: "pinned", Image: digest},
+			{Name: "tagged", Image: "redis:7"},
+		}},
+	}
+	kubelet.pods.Add(pod)
+	kubelet.runPod(pod)
+	kubelet.syncPods(context.Background())
+
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	pinned := current.GetContainerStatus("pinned")
+	require.NotNil(t, pinned)
+	assert.Equal(t, api.ContainerRunning, pinned.State)
+	assert.Equal(t, digest, pinned.Image)
+	assert.Equal(t, digest, pinned.ImageID, "a digest reference resolves to itself")
+
+	tagged := current.GetContainerStatus("tagged")
+	require.NotNil(t, tagged)
+	assert.Equal(t, "redis:7", tagged.Image)
+	assert.Equal(t, fakeruntime.ImageID("redis:7"), tagged.ImageID)
+	assert.NotEmpty(t, api.ImageDigest(tagged.ImageID), "a tag resolves to the digest it was pulled at")
+}
@@ -1,174 +1,351 @@
 package kubelet
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gokube/pkg/api"
+	"gokube/pkg/client"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/logging"
 	"gokube/pkg/registry/names"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/client"
+	dockerclient "github.com/docker/docker/client"
 )
 
+// apiClientOptions keep the client from retrying failed requests, as the kubelet retries them with backoffs of
+// its own, e.g. statusBackoff, and make the pod lists it polls cheap to read again while they do not change
+var apiClientOptions = client.Options{MaxRetries: -1, ConditionalGets: true}
+
 type Kubelet struct {
-	nodeName     string
-	apiServerURL string
-	dockerClient *client.Client
-	pods         map[string]*api.Pod
+	nodeName string
+	// nodeUID identifies the machine that owns the node object; empty when the machine ID is unknown
+	nodeUID      string
+	apiClient    *client.Client
+	dockerClient *dockerclient.Client
+	pods         *podManager
+	// staticPods are the pods run from the manifests in PodManifestPath
+	staticPods staticPods
+	// apiServerLog keeps the loops quiet while the API server is unreachable
+	apiServerLog apiServerLog
+	// startPod runs a newly assigned pod; it defaults to runPod
+	startPod func(pod *api.Pod)
+	// stopPod stops the containers of a pod that was removed from this node; it defaults to killPod
+	stopPod func(pod *api.Pod)
+	// containerLogs reads the logs of a container; it defaults to the Docker client
+	containerLogs  func(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	restartBackoff *backoff
+	// registrationBackoff spaces out node registration attempts while the API server is unavailable
+	registrationBackoff *backoff
+	// statusBackoff spaces out retries of failed pod status updates
+	statusBackoff *backoff
+	// statusReports remembers when pod statuses were last reported, for StatusUpdateInterval
+	statusReports podStatusReports
+	// statusQueue holds the pod statuses waiting to be reported; use statusUpdates, which starts its worker
+	statusQueue      *podStatusQueue
+	statusWorkerOnce sync.Once
+	// runtime creates, inspects and removes pod containers; it defaults to Docker
+	runtime  kubecontainer.ContainerRuntime
+	images   *imageManager
+	options  Options
+	capacity api.NodeCapacity
+	// address is the IP address reported for the node, where the host ports of its pods are reached
+	address string
+	now     func() time.Time
+	// sampleUsage samples the resources left on the node for eviction; it defaults to sampleNodeUsage
+	sampleUsage func() (nodeUsage, error)
+	// pressure holds the pressure conditions reported on the node
+	pressure nodePressure
+	// nodeStatusMutex guards lastNodeStatus, the node status last accepted by the API server, and
+	// leaseAcquireTime, when the node lease held by the kubelet was acquired
+	nodeStatusMutex  sync.Mutex
+	lastNodeStatus   *api.Node
+	leaseAcquireTime time.Time
+
+	startQueue       *podStartQueue
+	startWorkersOnce sync.Once
+	// syncRequests asks the sync loop for a pass before its next tick
+	syncRequests chan struct{}
+	// serverMutex guards server, which Stop may read while Start is still running
+	serverMutex sync.Mutex
+	server      *http.Server
+	// recovering is set while Start rebuilds the state of the previous run, see recoverPods
+	recovering atomic.Bool
+	// done is closed by Stop to end the loops of the kubelet
+	done     chan struct{}
+	stopOnce sync.Once
+	// logger is tagged with the component and the node; nil logs to the default logger
+	logger *slog.Logger
 }
 
+// NewKubelet creates a new Kubelet with the default options
 func NewKubelet(nodeName, apiServerURL string) (*Kubelet, error) {
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	return NewKubeletWithOptions(nodeName, apiServerURL, DefaultOptions())
+}
+
+// NewKubeletWithOptions creates a new Kubelet with the given intervals and max pods, running containers with Docker
+func NewKubeletWithOptions(nodeName, apiServerURL string, options Options) (*Kubelet, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	dockerClient, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation())
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %v", err)
 	}
 
-	return &Kubelet{
-		nodeName:     nodeName,
-		apiServerURL: apiServerURL,
-		dockerClient: dockerClient,
-		pods:         make(map[string]*api.Pod),
-	}, nil
+	k, err := NewKubeletWithRuntime(nodeName, apiServerURL, options, kubecontainer.NewDockerRuntime(dockerClient))
+	if err != nil {
+		return nil, err
+	}
+	k.dockerClient = dockerClient
+	k.containerLogs = dockerClient.ContainerLogs
+	return k, nil
 }
 
-func (k *Kubelet) Start() error {
-	// Register the node with the API server
-	if err := k.registerNode(); err != nil {
-		return fmt.Errorf("failed to register node: %w", err)
+// NewKubeletWithRuntime creates a new Kubelet that runs the containers of its pods with runtime, such as a fake
+// one in tests. Container logs are only served with the Docker runtime of NewKubeletWithOptions.
+func NewKubeletWithRuntime(nodeName, apiServerURL string, options Options, runtime kubecontainer.ContainerRuntime) (*Kubelet, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
 	}
 
-	// TODO: Implement other Kubelet functionality here
+	apiClient, err := client.New(apiServerURL, apiClientOptions)
+	if err != nil {
+		return nil, err
+	}
 
-	// Start watching for pod assignments
-	go k.watchPods()
+	k := &Kubelet{
+		nodeName:            nodeName,
+		nodeUID:             machineID(machineIDPath),
+		apiClient:           apiClient,
+		pods:                newPodManager(),
+		restartBackoff:      newBackoff(DefaultRestartBackoff, MaxRestartBackoff),
+		registrationBackoff: newBackoff(DefaultRegistrationBackoff, MaxRegistrationBackoff),
+		statusBackoff:       newBackoff(DefaultStatusUpdateBackoff, MaxStatusUpdateBackoff),
+		runtime:             runtime,
+		images:              newImageManager(runtime),
+		options:             options,
+		capacity:            nodeCapacity(options.MaxPods),
+		address:             nodeAddress(options.Address, net.InterfaceAddrs),
+		now:                 time.Now,
+		startQueue:          newPodStartQueue(),
+		statusQueue:         newPodStatusQueue(DefaultStatusUpdateQPS, DefaultStatusUpdateBurst),
+		done:                make(chan struct{}),
+	}
+	k.startPod = k.runPod
+	k.stopPod = k.killPod
+	k.containerLogs = func(context.Context, string, container.LogsOptions) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("%w: container logs are only served with the Docker runtime", errors.ErrUnsupported)
+	}
+	k.sampleUsage = k.sampleNodeUsage
+	k.SetLogger(slog.Default())
+	return k, nil
+}
 
-	// Start updating pod statuses
-	go k.updatePodStatuses()
+// SetLogger makes the kubelet log to logger instead of the default logger
+func (k *Kubelet) SetLogger(logger *slog.Logger) {
+	k.logger = logging.WithComponent(logger, "kubelet").With("node", k.nodeName)
+	k.apiServerLog.logger = k.logger
+}
 
-	return nil
+// podContext returns ctx carrying the trace ID of pod, so the logs of the work on the pod and the API calls
+// made for it are tagged with it
+func podContext(ctx context.Context, pod *api.Pod) context.Context {
+	return logging.WithRequestID(ctx, pod.TraceID())
 }
 
-func (k *Kubelet) registerNode() error {
-	node := &api.Node{
-		ObjectMeta: api.ObjectMeta{
-			Name: k.nodeName,
-		},
-		Status: api.NodeReady,
+// log returns the logger of the kubelet, falling back to the default logger for kubelets built without a
+// constructor
+func (k *Kubelet) log() *slog.Logger {
+	if k.logger == nil {
+		return logging.Component("kubelet").With("node", k.nodeName)
 	}
+	return k.logger
+}
 
-	jsonData, err := json.Marshal(node)
-	if err != nil {
-		return fmt.Errorf("failed to marshal node data: %w", err)
+func (k *Kubelet) Start() error {
+	// Reconcile the desired pods with the containers that are running, leaving the containers of the
+	// previous run alone until they are recovered
+	k.recovering.Store(true)
+	go k.syncLoop()
+
+	// Static pods run even while the API server is not reachable
+	if k.options.PodManifestPath != "" {
+		go k.watchStaticPods()
 	}
 
-	resp, err := http.Post("http://"+k.apiServerURL+"/api/v1/nodes", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to send request to API server: %w", err)
+	// Register the node with the API server, waiting for it if it is not reachable yet
+	if err := k.registerNodeWithRetry(); err != nil {
+		return fmt.Errorf("failed to register node: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to register node, status code: %d", resp.StatusCode)
+	// Adopt the containers of the pods still assigned to this node and stop the others
+	if err := k.recoverPods(context.Background()); err != nil {
+		return err
 	}
 
+	// TODO: Implement other Kubelet functionality here
+
+	// Start watching for pod assignments, which are handed to the sync loop
+	go k.watchPods()
+
+	// Start reporting the node status, and renewing the node lease that tells the node is alive in between
+	go k.heartbeat()
+	if k.options.NodeLeaseDurationSeconds > 0 {
+		go k.renewNodeLease()
+	}
+
+	// Remove the dead containers of pods that are gone
+	go k.garbageCollectContainers()
+
+	// Serve health, pods, container logs and metrics
+	k.startServer()
+
 	return nil
 }
 
-func (k *Kubelet) watchPods() {
-	for {
-		pods, err := k.getPodAssignments()
-		if err != nil {
-			log.Printf("Error getting pod assignments: %v", err)
-			time.Sleep(5 * time.Second)
+// runNewPods records the pods newly assigned to this node as desired and asks the sync loop to start them.
+// A tracked pod that was deleted and recreated under the same name is replaced by the new pod.
+func (k *Kubelet) runNewPods(pods []*api.Pod) error {
+	added := false
+	for _, pod := range pods {
+		if !k.isAssignedToNode(pod) {
 			continue
 		}
-
-		if err := k.runNewPods(pods); err != nil {
-			log.Printf("Error running new pods: %v", err)
+		k.forgetRecreatedPod(pod)
+		if k.pods.Add(pod) {
+			k.log().InfoContext(podContext(context.Background(), pod), "New pod assigned", "pod", pod.Name, "podUID", pod.UID)
+			added = true
 		}
+	}
+	if added {
+		k.requestSync()
+	}
+	return nil
+}
 
-		time.Sleep(10 * time.Second) // Poll every 10 seconds
+// forgetRecreatedPod stops tracking the pod with the name of pod if it has another UID, i.e. it was deleted
+// and recreated in the meantime. The sync loop then tears down the containers of the old pod.
+func (k *Kubelet) forgetRecreatedPod(pod *api.Pod) {
+	if tracked, ok := k.pods.Get(pod.Namespace, pod.Name); ok && tracked.UID != pod.UID {
+		k.log().Info("Pod was recreated, forgetting the old UID", "pod", pod.Name, "uid", pod.UID, "oldUID", tracked.UID)
+		k.removePod(pod.Namespace, pod.Name)
 	}
 }
 
-func (k *Kubelet) runNewPods(pods []*api.Pod) error {
-	for _, pod := range pods {
-		if _, exists := k.pods[pod.Name]; !exists {
-			log.Printf("New pod assigned: %s", pod.Name)
-			k.pods[pod.Name] = pod
-			go k.runPod(pod)
-		}
+// isAssignedToNode checks if the pod has been scheduled to this node and should be running on it.
+// Running pods are included so a restarted kubelet adopts the containers it started before, and deleted pods
+// waiting for the kubelet so it stops their containers.
+// The API server filters by node too, but the kubelet must never run a pod meant for another node.
+// Mirror pods are only copies of static pods, which the kubelet runs from their manifests.
+func (k *Kubelet) isAssignedToNode(pod *api.Pod) bool {
+	if pod.NodeName != k.nodeName || pod.IsMirrorPod() {
+		return false
 	}
-	return nil
+	if pod.IsDeleting() {
+		return waitsForKubelet(pod)
+	}
+	return pod.Status == api.PodScheduled || pod.Status == api.PodRunning
+}
+
+// waitsForKubelet checks if the pod was deleted and is kept until the kubelet has stopped its containers
+func waitsForKubelet(pod *api.Pod) bool {
+	return pod.IsDeleting() && slices.Contains(pod.Finalizers, api.PodKubeletFinalizer)
+}
+
+// ownsContainer checks if the container was created by this kubelet
+func (k *Kubelet) ownsContainer(c kubecontainer.Container) bool {
+	return c.NodeName == k.nodeName
 }
 
 func (k *Kubelet) getPodAssignments() ([]*api.Pod, error) {
-	//Assignment 5: Get Pods assigned to this node.
-	return nil, nil
+	pods, err := k.apiClient.Pods().List(context.Background(), client.PodListOptions{NodeName: k.nodeName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod assignments: %w", err)
+	}
+	return pods, nil
 }
 
+// runPod starts the containers of pod one by one in spec order. A container whose image cannot be pulled
+// waits for the pull to be retried, while a container that fails to be created or started rolls back the
+// containers started before it and fails the pod, so no half-started pod is left behind.
 func (k *Kubelet) runPod(pod *api.Pod) {
-	// Simulate running a pod
-	log.Printf("Running pod: %s", pod.Name)
+	ctx := podContext(context.Background(), pod)
+	k.log().InfoContext(ctx, "Running pod", "pod", pod.Name, "podUID", pod.UID)
+	var started []api.ContainerStatus
 	for _, container := range pod.Spec.Containers {
-		if err := k.StartContainer(context.Background(), pod, container.Name, container.Image); err != nil {
-			log.Printf("Failed to start container %s: %v", container.Name, err)
+		k.pods.Mutate(pod, func(p *api.Pod) {
+			p.SetContainerStatus(api.ContainerStatus{Name: container.Name, State: api.ContainerWaiting, Reason: string(ImagePulling)})
+		})
+		containerID, err := k.StartContainer(ctx, pod, container.Name, container.Image)
+		if isImagePullError(err) {
+			k.log().ErrorContext(ctx, "Failed to pull image", "pod", pod.Name, "container", container.Name, logging.Err(err))
+			k.recordImagePullFailure(pod, container.Name, err)
+			continue
+		}
+		if err != nil {
+			k.log().ErrorContext(ctx, "Failed to start container, rolling back the pod", "pod", pod.Name, "container", container.Name, logging.Err(err))
+			k.rollbackPodStart(pod, started, container.Name, err)
+			return
 		}
+		status := api.ContainerStatus{Name: container.Name, ContainerID: containerID, State: api.ContainerRunning}
+		started = append(started, status)
+		k.pods.Mutate(pod, func(p *api.Pod) {
+			p.SetContainerStatus(status)
+		})
 	}
-	// In a real implementation, this would involve setting up containers, etc.
 }
 
-func (k *Kubelet) StartContainer(ctx context.Context, pod *api.Pod, containerName, imageName string) error {
-
-	log.Printf("Pulling image: %s", imageName)
+// StartContainer pulls the image and starts a container for the pod, returning the ID of the new container.
+// A container that was created but could not be started is removed again.
+func (k *Kubelet) StartContainer(ctx context.Context, pod *api.Pod, containerName, imageName string) (string, error) {
 
-	// Pull the image
-	out, err := k.dockerClient.ImagePull(ctx, imageName, image.PullOptions{})
-	if err != nil {
-		panic(err)
-	}
-	defer out.Close()
-	_, err = io.Copy(os.Stdout, out)
-	if err != nil {
-		return fmt.Errorf("failed to pull image %s: %v", imageName, err)
+	if err := k.images.EnsureImage(ctx, imageName); err != nil {
+		return "", err
 	}
 
-	log.Printf("Successfully pulled image: %s", "nginx")
-
 	labels := map[string]string{
-		"gokube.pod.name":       pod.Name,
-		"gokube.pod.namespace":  pod.Namespace,
-		"gokube.container.name": containerName,
+		kubecontainer.LabelPodName:       pod.Name,
+		kubecontainer.LabelPodNamespace:  pod.Namespace,
+		kubecontainer.LabelPodUID:        pod.UID,
+		kubecontainer.LabelContainerName: containerName,
+		kubecontainer.LabelNodeName:      k.nodeName,
+
+		kubecontainer.LabelTerminationGracePeriod: strconv.FormatInt(int64(pod.Spec.TerminationGracePeriod()/time.Second), 10),
 	}
 
 	uniqueContainerName := names.SimpleNameGenerator.GenerateName(fmt.Sprintf("%s-%s", pod.Name, containerName))
-	// Create the container
-	resp, err := k.dockerClient.ContainerCreate(ctx, &container.Config{
-		Image:  imageName,
-		Labels: labels,
-		// You can add more configuration options here as needed
-	}, nil, nil, nil, uniqueContainerName)
+	var ports []api.ContainerPort
+	if container := pod.Spec.GetContainer(containerName); container != nil {
+		ports = container.Ports
+	}
+	containerID, err := k.runtime.CreateContainer(ctx, uniqueContainerName, imageName, labels, ports)
 	if err != nil {
-		return fmt.Errorf("failed to create container %s: %v", containerName, err)
+		return "", fmt.Errorf("failed to create container %s: %v", containerName, err)
 	}
 
-	// Start the container
-	if err := k.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return fmt.Errorf("failed to start container %s: %v", containerName, err)
+	if err := k.runtime.StartContainer(ctx, containerID); err != nil {
+		if removeErr := k.runtime.RemoveContainer(ctx, containerID); removeErr != nil && !errors.Is(removeErr, kubecontainer.ErrContainerNotFound) {
+			k.log().ErrorContext(ctx, "Failed to remove container that did not start", "pod", pod.Name, "container", containerName, logging.Err(removeErr))
+		}
+		return "", fmt.Errorf("failed to start container %s: %v", containerName, err)
 	}
 
-	fmt.Printf("Started container %s with ID %s\n", containerName, resp.ID)
-	return nil
+	k.log().InfoContext(ctx, "Started container", "pod", pod.Name, "container", containerName, "containerID", containerID)
+	return containerID, nil
 }
 
 func (k *Kubelet) GetNodeName() string {
@@ -183,32 +360,30 @@ type ContainerStatus struct {
 }
 
 func (k *Kubelet) ListContainers(ctx context.Context) ([]ContainerStatus, error) {
-	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{})
+	containers, err := k.runtime.ListContainers(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %v", err)
 	}
 
 	var statuses []ContainerStatus
 	for _, c := range containers {
-		podName, ok := c.Labels["gokube.pod.name"]
-		if !ok {
-			continue // Skip containers not managed by our system
+		if !c.Running || !k.ownsContainer(c) {
+			continue
 		}
 
-		pod, ok := k.pods[podName]
-		if !ok || pod.NodeName != k.nodeName {
-			continue // Skip pods not assigned to this node
+		pod, ok := k.pods.Get(c.PodNamespace, c.PodName)
+		if !ok || pod.NodeName != k.nodeName || pod.UID != c.PodUID {
+			continue // Skip pods not assigned to this node and containers of deleted pods with the same name
 		}
 
 		for _, containerSpec := range pod.Spec.Containers {
-			if containerSpec.Name == c.Labels["gokube.container.name"] {
-				status := ContainerStatus{
-					PodName:       podName,
+			if containerSpec.Name == c.ContainerName {
+				statuses = append(statuses, ContainerStatus{
+					PodName:       c.PodName,
 					ContainerName: containerSpec.Name,
 					ContainerID:   c.ID,
-					Status:        c.State,
-				}
-				statuses = append(statuses, status)
+					Status:        "running",
+				})
 				break
 			}
 		}
@@ -217,141 +392,23 @@ func (k *Kubelet) ListContainers(ctx context.Context) ([]ContainerStatus, error)
 	return statuses, nil
 }
 
-func (k *Kubelet) getPodStatus(ctx context.Context, pod *api.Pod) (api.PodStatus, error) {
-	var containerStates []containerState
-	for _, container := range pod.Spec.Containers {
-		state, err := k.getContainerState(ctx, container.Name)
-		if err != nil {
-			return api.PodRunning, fmt.Errorf("failed to get state for container %s: %w", container.Name, err)
-		}
-		containerStates = append(containerStates, state)
-	}
-
-	return determinePodStatus(containerStates), nil
-}
-
-type containerState struct {
-	exists   bool
-	running  bool
-	exitCode int
-}
-
-func (k *Kubelet) getContainerState(ctx context.Context, containerName string) (containerState, error) {
-	containerInfo, err := k.dockerClient.ContainerInspect(ctx, containerName)
-	if err != nil {
-		if client.IsErrNotFound(err) {
-			return containerState{exists: false}, nil
-		}
-		return containerState{}, err
-	}
-
-	return containerState{
-		exists:   true,
-		running:  containerInfo.State.Running,
-		exitCode: containerInfo.State.ExitCode,
-	}, nil
-}
-
-func determinePodStatus(states []containerState) api.PodStatus {
-	if anyContainerRunning(states) {
-		return api.PodRunning
-	}
-
-	if allContainersFailed(states) && anyContainerExists(states) {
-		return api.PodFailed
-	}
-
-	if allContainersSucceeded(states) {
-		return api.PodSucceeded
-	}
-
-	return api.PodScheduled
-}
-
-func allContainersSucceeded(states []containerState) bool {
-	for _, state := range states {
-		if state.exitCode != 0 {
-			return false
-		}
-	}
-	return true
-}
-
-func anyContainerRunning(states []containerState) bool {
-	for _, state := range states {
-		if state.running {
-			return true
-		}
-	}
-	return false
-}
-
-func allContainersFailed(states []containerState) bool {
-	for _, state := range states {
-		if state.exists && state.exitCode == 0 {
-			return false
-		}
-	}
-	return true
-}
-
-func anyContainerExists(states []containerState) bool {
-	for _, state := range states {
-		if state.exists {
-			return true
-		}
-	}
-	return false
-}
-
+// CleanupContainers stops and removes the containers of the pods this kubelet runs, giving each container
+// its grace period to exit
 func (k *Kubelet) CleanupContainers(ctx context.Context) error {
-	containers, err := k.dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	containers, err := k.runtime.ListContainers(ctx)
 	if err != nil {
 		return fmt.Errorf("error listing containers for cleanup: %v", err)
 	}
 
 	for _, c := range containers {
-		if podName, ok := c.Labels["gokube.pod.name"]; ok {
-			if pod, exists := k.pods[podName]; exists && pod.NodeName == k.nodeName {
-				err := k.dockerClient.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
-				if err != nil {
-					log.Printf("Error removing container %s: %v", c.ID, err)
-				} else {
-					log.Printf("Removed container %s for pod %s", c.ID, podName)
-				}
+		if pod, exists := k.pods.Get(c.PodNamespace, c.PodName); exists && pod.NodeName == k.nodeName && pod.UID == c.PodUID {
+			if err := k.stopContainer(ctx, c.ID, c.TerminationGracePeriod); err != nil {
+				k.log().Error("Error removing container", "containerID", c.ID, logging.Err(err))
+			} else {
+				k.log().Info("Removed container", "pod", c.PodName, "containerID", c.ID)
 			}
 		}
 	}
 
 	return nil
 }
-
-func (k *Kubelet) updatePodStatuses() {
-	ticker := time.NewTicker(10 * time.Second) // Check every 10 seconds
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			for _, pod := range k.pods {
-				status, err := k.getPodStatus(context.Background(), pod)
-				if err != nil {
-					log.Printf("Error getting status for pod %s: %v", pod.Name, err)
-					continue
-				}
-
-				if pod.Status != status {
-					pod.Status = status
-					if err := k.updatePodStatus(pod); err != nil {
-						log.Printf("Error updating status for pod %s: %v", pod.Name, err)
-					}
-				}
-			}
-		}
-	}
-}
-
-func (k *Kubelet) updatePodStatus(pod *api.Pod) error {
-	//Assignment 6: Update PodStatus with the APIServer.
-	return nil
-}
@@ -2,7 +2,12 @@ package kubelet
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,16 +16,29 @@ import (
 	"github.com/docker/docker/client"
 
 	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/kubelet/container/fakeruntime"
 )
 
+var nginxSpec = api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx"}}}
+
+// dockerTestsEnv opts in to the integration test that runs containers with a real Docker daemon.
+// Every other kubelet test runs against the fake runtime.
+const dockerTestsEnv = "GOKUBE_DOCKER_TESTS"
+
 func TestStartContainerWithRealDocker(t *testing.T) {
-	// Skip this test if we're not in an environment where we can connect to Docker
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv)
+	if os.Getenv(dockerTestsEnv) == "" {
+		t.Skipf("Skipping test: set %s=1 to run it against Docker", dockerTestsEnv)
+	}
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		t.Skip("Skipping test: unable to connect to Docker")
 	}
 
 	ctx := context.Background()
+	if _, err := dockerClient.Ping(ctx); err != nil {
+		t.Skipf("Skipping test: Docker is not available: %v", err)
+	}
 	podName := "test-pod"
 	containerName := "test-container"
 	imageName := "nginx"
@@ -41,6 +59,7 @@ func TestStartContainerWithRealDocker(t *testing.T) {
 	pod := &api.Pod{
 		ObjectMeta: api.ObjectMeta{Name: podName},
 		NodeName:   "test-node",
+		Status:     api.PodScheduled,
 		Spec: api.PodSpec{
 			Containers: []api.Container{{Name: containerName, Image: imageName}},
 		},
@@ -50,6 +69,7 @@ func TestStartContainerWithRealDocker(t *testing.T) {
 	if err != nil {
 		t.Fatalf("StartContainer failed: %v", err)
 	}
+	kubelet.syncPods(ctx)
 
 	// Wait for the container to be created and running
 	containerId, err := waitForContainer(ctx, dockerClient, uniqueContainerName, 60*time.Second)
@@ -76,6 +96,16 @@ func TestStartContainerWithRealDocker(t *testing.T) {
 		t.Errorf("Expected 1 container, got %d", len(containerStatuses))
 	}
 
+	// Containers get generated names, so the pod must be found running through the ID the kubelet recorded
+	kubelet.syncPods(ctx)
+	current, _ := kubelet.pods.Get("", podName)
+	if current.Status != api.PodRunning {
+		t.Errorf("Expected pod to be running, got %s", current.Status)
+	}
+	if status := current.GetContainerStatus(containerName); status == nil || status.ContainerID != containerId {
+		t.Errorf("Expected container %s to be recorded, got %+v", containerId, status)
+	}
+
 	// Clean up: stop and remove the container
 	timeout := 10
 	err = dockerClient.ContainerStop(ctx, containerId, container.StopOptions{Timeout: &timeout})
@@ -89,6 +119,141 @@ func TestStartContainerWithRealDocker(t *testing.T) {
 	}
 }
 
+func TestSyncStartsOnlyPodsAssignedToThisNode(t *testing.T) {
+	pods := []*api.Pod{
+		{ObjectMeta: api.ObjectMeta{Name: "mine"}, NodeName: "node-1", Status: api.PodScheduled, Spec: nginxSpec},
+		{ObjectMeta: api.ObjectMeta{Name: "other-node"}, NodeName: "node-2", Status: api.PodScheduled, Spec: nginxSpec},
+		{ObjectMeta: api.ObjectMeta{Name: "unscheduled"}, Status: api.PodPending, Spec: nginxSpec},
+		{ObjectMeta: api.ObjectMeta{Name: "finished"}, NodeName: "node-1", Status: api.PodSucceeded, Spec: nginxSpec},
+		{ObjectMeta: api.ObjectMeta{Name: "already-running"}, NodeName: "node-1", Status: api.PodRunning, Spec: nginxSpec},
+	}
+
+	var requestedNode string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedNode = r.URL.Query().Get("nodeName")
+		// Deliberately ignore the filter to prove the kubelet does not trust the response blindly
+		_ = json.NewEncoder(w).Encode(pods)
+	}))
+	defer server.Close()
+
+	// The running pod's container survived a kubelet restart
+	runtime := fakeruntime.New()
+	runtime.Add(kubecontainer.Container{ID: "nginx-1", PodName: "already-running", ContainerName: "nginx", NodeName: "node-1", Running: true})
+
+	var mutex sync.Mutex
+	var started []string
+	var wg sync.WaitGroup
+	kubelet := &Kubelet{
+		nodeName:      "node-1",
+		apiClient:     newTestAPIClient(t, server.URL),
+		pods:          newPodManager(),
+		runtime:       runtime,
+		statusBackoff: newBackoff(DefaultStatusUpdateBackoff, MaxStatusUpdateBackoff),
+		now:           time.Now,
+	}
+	kubelet.startPod = func(pod *api.Pod) {
+		defer wg.Done()
+		mutex.Lock()
+		defer mutex.Unlock()
+		started = append(started, pod.Name)
+	}
+
+	assigned, err := kubelet.getPodAssignments()
+	if err != nil {
+		t.Fatalf("Failed to get pod assignments: %v", err)
+	}
+	if requestedNode != "node-1" {
+		t.Errorf("Expected pods to be requested for node-1, got %q", requestedNode)
+	}
+
+	if err := kubelet.runNewPods(assigned); err != nil {
+		t.Fatalf("runNewPods failed: %v", err)
+	}
+	wg.Add(1)
+	kubelet.syncPods(context.Background())
+	wg.Wait()
+
+	if len(started) != 1 || started[0] != "mine" {
+		t.Errorf("Expected only pod mine to be started, got %v", started)
+	}
+	if kubelet.pods.Len() != 2 {
+		t.Errorf("Expected 2 tracked pods, got %d", kubelet.pods.Len())
+	}
+	adopted, _ := kubelet.pods.Get("", "already-running")
+	if status := adopted.GetContainerStatus("nginx"); status == nil || status.ContainerID != "nginx-1" {
+		t.Errorf("Expected the running container to be adopted, got %+v", status)
+	}
+}
+
+// Run with -race: the assignment and status loops share the pod manager
+func TestAssignmentAndStatusLoopsRunConcurrently(t *testing.T) {
+	var mutex sync.Mutex
+	var assignments []*api.Pod
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			mutex.Lock()
+			defer mutex.Unlock()
+			_ = json.NewEncoder(w).Encode(assignments)
+		case http.MethodPut:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	kubelet := &Kubelet{
+		nodeName:       "node-1",
+		apiClient:      newTestAPIClient(t, server.URL),
+		pods:           newPodManager(),
+		runtime:        fakeruntime.New(),
+		restartBackoff: newBackoff(DefaultRestartBackoff, MaxRestartBackoff),
+		statusBackoff:  newBackoff(DefaultStatusUpdateBackoff, MaxStatusUpdateBackoff),
+		images:         newImageManager(&fakeImagePuller{}),
+		now:            time.Now,
+	}
+	kubelet.startPod = func(pod *api.Pod) {}
+
+	const iterations = 50
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			mutex.Lock()
+			assignments = append(assignments, &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: fmt.Sprintf("pod-%d", i)},
+				NodeName:   "node-1",
+				Status:     api.PodScheduled,
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "c1", Image: "nginx"}}},
+			})
+			mutex.Unlock()
+
+			pods, err := kubelet.getPodAssignments()
+			if err != nil {
+				t.Errorf("Failed to get pod assignments: %v", err)
+				return
+			}
+			if err := kubelet.runNewPods(pods); err != nil {
+				t.Errorf("runNewPods failed: %v", err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			kubelet.syncPods(ctx)
+			_, _ = kubelet.ListContainers(ctx)
+		}
+	}()
+	wg.Wait()
+
+	if kubelet.pods.Len() != iterations {
+		t.Errorf("Expected %d tracked pods, got %d", iterations, kubelet.pods.Len())
+	}
+}
+
 func waitForContainer(ctx context.Context, client *client.Client, containerName string, timeout time.Duration) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -129,3 +294,81 @@ func listContainerIDs(ctx context.Context, dockerClient *client.Client, dockerCo
 	}
 	return containerIds
 }
+
+func TestPodStartsRespectConcurrencyLimit(t *testing.T) {
+	const maxParallel = 2
+	const podCount = 10
+
+	var mutex sync.Mutex
+	running, maxRunning, started := 0, 0, 0
+	release := make(chan struct{})
+
+	kubelet := &Kubelet{
+		nodeName: "node-1",
+		pods:     newPodManager(),
+		runtime:  fakeruntime.New(),
+		options:  Options{MaxParallelPodStarts: maxParallel},
+	}
+	kubelet.startPod = func(pod *api.Pod) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		<-release
+
+		mutex.Lock()
+		running--
+		started++
+		mutex.Unlock()
+	}
+
+	var pods []*api.Pod
+	for i := 0; i < podCount; i++ {
+		pods = append(pods, &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: fmt.Sprintf("pod-%d", i)},
+			NodeName:   "node-1",
+			Status:     api.PodScheduled,
+			Spec:       nginxSpec,
+		})
+	}
+	if err := kubelet.runNewPods(pods); err != nil {
+		t.Fatalf("runNewPods failed: %v", err)
+	}
+	kubelet.syncPods(context.Background())
+	// A repeated sync pass must not queue the pods again
+	kubelet.syncPods(context.Background())
+
+	waitFor := func(condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for pod starts")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return running == maxParallel
+	})
+	if !kubelet.pods.IsStarting(pods[0]) || !kubelet.pods.IsStarting(pods[podCount-1]) {
+		t.Errorf("Expected queued and running pods to be marked as starting")
+	}
+
+	close(release)
+	waitFor(func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return started == podCount
+	})
+
+	if maxRunning != maxParallel {
+		t.Errorf("Expected at most %d concurrent pod starts, got %d", maxParallel, maxRunning)
+	}
+	waitFor(func() bool { return !kubelet.pods.IsStarting(pods[podCount-1]) })
+}
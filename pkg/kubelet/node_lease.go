@@ -0,0 +1,79 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// renewNodeLease keeps the lease of the node alive, renewing it every quarter of its duration, so the node
+// controller knows the kubelet is alive while the node status is reported less often. Failures are logged and
+// retried on the next tick; the lease lasts through a few of them.
+func (k *Kubelet) renewNodeLease() {
+	ticker := time.NewTicker(time.Duration(k.options.NodeLeaseDurationSeconds) * time.Second / 4)
+	defer ticker.Stop()
+
+	for {
+		if err := k.syncNodeLease(); err != nil {
+			k.apiServerLog.Error(err, "Error renewing node lease")
+		} else {
+			k.apiServerLog.Reachable()
+		}
+		select {
+		case <-ticker.C:
+		case <-k.done:
+			return
+		}
+	}
+}
+
+// syncNodeLease renews the lease of the node once. A lease that was acquired anew, because the previous one
+// expired, makes the next heartbeat report the node status: the node controller may have marked the node
+// NotReady while the lease was gone.
+func (k *Kubelet) syncNodeLease() error {
+	lease, err := k.apiClient.NodeLeases().Renew(context.Background(), &api.Lease{
+		ObjectMeta:           api.ObjectMeta{Name: k.nodeName},
+		HolderIdentity:       k.nodeUID,
+		LeaseDurationSeconds: k.options.NodeLeaseDurationSeconds,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to renew node lease: %w", err)
+	}
+
+	k.nodeStatusMutex.Lock()
+	defer k.nodeStatusMutex.Unlock()
+	if !lease.AcquireTime.Equal(k.leaseAcquireTime) {
+		k.leaseAcquireTime = lease.AcquireTime
+		k.lastNodeStatus = nil
+	}
+	return nil
+}
+
+// nodeStatusReportDue reports whether the heartbeat should report node. Without a node lease every heartbeat
+// reports the status, as it is what tells the node is alive. With one, a status that did not change since the
+// last report is only reported again every NodeStatusReportFrequency.
+func (k *Kubelet) nodeStatusReportDue(node *api.Node) bool {
+	if k.options.NodeLeaseDurationSeconds == 0 {
+		return true
+	}
+
+	k.nodeStatusMutex.Lock()
+	defer k.nodeStatusMutex.Unlock()
+	last := k.lastNodeStatus
+	if last == nil || node.LastHeartbeatTime.Sub(last.LastHeartbeatTime) >= k.options.NodeStatusReportFrequency {
+		return true
+	}
+	unchanged := *node
+	unchanged.LastHeartbeatTime = last.LastHeartbeatTime
+	return !reflect.DeepEqual(&unchanged, last)
+}
+
+// recordNodeStatus remembers node as the status last accepted by the API server
+func (k *Kubelet) recordNodeStatus(node *api.Node) {
+	k.nodeStatusMutex.Lock()
+	defer k.nodeStatusMutex.Unlock()
+	k.lastNodeStatus = node
+}
@@ -0,0 +1,178 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/logging"
+)
+
+const (
+	// DefaultRegistrationBackoff is how long the kubelet waits before retrying a failed node registration
+	DefaultRegistrationBackoff = 500 * time.Millisecond
+	// MaxRegistrationBackoff caps the delay between node registration attempts
+	MaxRegistrationBackoff = 30 * time.Second
+
+	machineIDPath = "/etc/machine-id"
+	// registrationKey is the backoff key of node registration attempts
+	registrationKey = "register-node"
+)
+
+var (
+	// ErrNodeUIDConflict is returned when the node name is already registered by another machine
+	ErrNodeUIDConflict = errors.New("node is registered with a different UID")
+	// ErrRegistrationTimeout is returned when the API server stays unavailable for the whole RegistrationTimeout
+	ErrRegistrationTimeout = errors.New("timed out registering node")
+	// ErrStopped is returned when the kubelet is stopped before it registered the node
+	ErrStopped = errors.New("kubelet stopped")
+	// errAPIServerUnavailable marks registration failures that are worth retrying
+	errAPIServerUnavailable = errors.New("API server unavailable")
+	// errWaitingForDeadNode marks a UID conflict with a node that is NotReady, whose object the node
+	// controller removes once it has been dead for long enough, so registration is retried until then
+	errWaitingForDeadNode = errors.New("waiting for the dead node to be removed")
+)
+
+// registerNodeWithRetry registers the node, retrying with backoff for as long as the API server is
+// unreachable or failing, or the node name is held by a dead node of another machine, up to
+// RegistrationTimeout. Any other error, such as a UID conflict with a Ready node, is returned immediately.
+func (k *Kubelet) registerNodeWithRetry() error {
+	defer k.registrationBackoff.Reset(registrationKey)
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := k.registerNode()
+		if err == nil || !(errors.Is(err, errAPIServerUnavailable) || errors.Is(err, errWaitingForDeadNode)) {
+			return err
+		}
+
+		k.registrationBackoff.Next(registrationKey)
+		delay := k.registrationBackoff.Delay(registrationKey)
+		if timeout := k.options.RegistrationTimeout; timeout > 0 {
+			remaining := timeout - time.Since(start)
+			if remaining <= 0 {
+				return fmt.Errorf("%w %s after %d attempts in %v: %v", ErrRegistrationTimeout, k.nodeName, attempt, timeout, err)
+			}
+			delay = min(delay, remaining)
+		}
+		k.log().Warn("Registering node failed, retrying", "attempt", attempt, "delay", delay, logging.Err(err))
+		if !k.wait(delay) {
+			return fmt.Errorf("%w while registering node %s: %v", ErrStopped, k.nodeName, err)
+		}
+	}
+}
+
+// registerNode creates the node object. If the node is already registered, e.g. because the kubelet
+// restarted, it is re-registered: the existing node must have been created by this machine, and its status
+// is refreshed instead.
+func (k *Kubelet) registerNode() error {
+	_, err := k.apiClient.Nodes().Create(context.Background(), k.nodeStatus())
+	switch {
+	case err == nil:
+		return nil
+	case client.IsConflict(err):
+		return k.reregisterNode()
+	default:
+		return markUnavailable(fmt.Errorf("failed to register node: %w", err))
+	}
+}
+
+// reregisterNode takes over a node object that already exists. A node without a UID predates this
+// kubelet's identity and is adopted; a node with another machine's UID is a name conflict, e.g. a cloned
+// machine or a stale DNS name. The node of another machine is only taken over once the node controller has
+// removed it, along with the pods bound to it, after it was NotReady for long enough.
+func (k *Kubelet) reregisterNode() error {
+	existing, err := k.getNode()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case k.nodeUID == "" || existing.UID == k.nodeUID:
+		k.log().Info("Node is already registered, updating its status")
+	case existing.UID == "":
+		k.log().Info("Node is already registered without a UID, adopting it")
+		existing.UID = k.nodeUID
+		if err := k.updateNode(existing); err != nil {
+			return err
+		}
+	case existing.Status == api.NodeNotReady:
+		return fmt.Errorf("%w: node %s belongs to UID %s, which is NotReady, this machine is %s", errWaitingForDeadNode,
+			k.nodeName, existing.UID, k.nodeUID)
+	default:
+		return fmt.Errorf("%w: node %s belongs to UID %s, this machine is %s", ErrNodeUIDConflict, k.nodeName, existing.UID, k.nodeUID)
+	}
+
+	if err := k.updateNodeStatus(); err != nil {
+		return fmt.Errorf("%w: %v", errAPIServerUnavailable, err)
+	}
+	return nil
+}
+
+// getNode fetches the node object registered under this kubelet's node name
+func (k *Kubelet) getNode() (*api.Node, error) {
+	node, err := k.apiClient.Nodes().Get(context.Background(), k.nodeName)
+	switch {
+	case err == nil:
+		return node, nil
+	case client.IsNotFound(err):
+		// A node deleted between the create and the get is simply registered again on the next attempt
+		return nil, fmt.Errorf("%w: failed to get node: %w", errAPIServerUnavailable, err)
+	default:
+		return nil, markUnavailable(fmt.Errorf("failed to get node: %w", err))
+	}
+}
+
+// updateNode replaces the whole node object
+func (k *Kubelet) updateNode(node *api.Node) error {
+	if _, err := k.apiClient.Nodes().Update(context.Background(), node); err != nil {
+		return markUnavailable(fmt.Errorf("failed to update node: %w", err))
+	}
+	return nil
+}
+
+// markUnavailable marks err as worth retrying if the API server could not be reached or failed
+func markUnavailable(err error) error {
+	if errors.Is(err, client.ErrUnreachable) || client.IsServerError(err) {
+		return fmt.Errorf("%w: %w", errAPIServerUnavailable, err)
+	}
+	return err
+}
+
+// ResolveNodeName returns the name the kubelet registers its node under: name when it is set, otherwise the
+// lowercased host name of the machine. It fails early if the result is not a valid node name, rather than
+// having the API server reject the node during registration.
+func ResolveNodeName(name string) (string, error) {
+	return resolveNodeName(name, os.Hostname)
+}
+
+func resolveNodeName(name string, hostname func() (string, error)) (string, error) {
+	if name != "" {
+		return name, api.ValidateNodeName(name)
+	}
+
+	host, err := hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the host name: %w", err)
+	}
+	// Host names are case-insensitive and may be written fully qualified with a trailing dot
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if err := api.ValidateNodeName(name); err != nil {
+		return "", fmt.Errorf("host name %q cannot be used as the node name: %w", host, err)
+	}
+	return name, nil
+}
+
+// machineID reads the identifier of the machine, returning an empty string if it is not available
+func machineID(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,96 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+)
+
+// nodeStatusUpdateRetry is how many times a single heartbeat is attempted before waiting for the next tick
+const nodeStatusUpdateRetry = 3
+
+// heartbeat keeps the node object current by checking the node status every NodeStatusUpdateFrequency and
+// reporting it when it changed or is due, see nodeStatusReportDue. Failures are logged and retried on the next
+// tick; they never stop the kubelet.
+func (k *Kubelet) heartbeat() {
+	ticker := time.NewTicker(k.options.NodeStatusUpdateFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-k.done:
+			return
+		}
+		if err := k.syncNodeStatus(); err != nil {
+			k.apiServerLog.Error(err, "Error updating node status")
+			continue
+		}
+		k.apiServerLog.Reachable()
+	}
+}
+
+// syncNodeStatus reports the node status if it is due, retrying a few times before giving up until the next
+// heartbeat
+func (k *Kubelet) syncNodeStatus() error {
+	if !k.nodeStatusReportDue(k.nodeStatus()) {
+		return nil
+	}
+	var err error
+	for i := 0; i < nodeStatusUpdateRetry; i++ {
+		if err = k.updateNodeStatus(); err == nil {
+			return nil
+		}
+		// An API server that cannot be reached will not be reached by retrying right away
+		if isConnectionError(err) {
+			return err
+		}
+		k.log().Error("Error updating node status, will retry", logging.Err(err))
+	}
+	return fmt.Errorf("failed to update node status after %d attempts: %w", nodeStatusUpdateRetry, err)
+}
+
+// nodeStatus builds the node object the kubelet reports to the API server
+func (k *Kubelet) nodeStatus() *api.Node {
+	return &api.Node{
+		ObjectMeta: api.ObjectMeta{
+			Name: k.nodeName,
+			UID:  k.nodeUID,
+		},
+		Status:            api.NodeReady,
+		LastHeartbeatTime: k.now().UTC(),
+		Capacity:          k.capacity,
+		Address:           k.address,
+		Conditions:        k.pressure.Conditions(),
+	}
+}
+
+func (k *Kubelet) updateNodeStatus() error {
+	node := k.nodeStatus()
+	if _, err := k.apiClient.Nodes().UpdateStatus(context.Background(), node); err != nil {
+		return fmt.Errorf("failed to update node status: %w", err)
+	}
+	k.recordNodeStatus(node)
+	return nil
+}
+
+// nodeAddress returns the address the kubelet serves its API on, or the first address of the machine that is
+// not a loopback address when it serves on all interfaces. Without one the node is only reachable locally.
+func nodeAddress(address string, interfaceAddrs func() ([]net.Addr, error)) string {
+	if ip := net.ParseIP(address); ip != nil && !ip.IsUnspecified() {
+		return address
+	}
+	addrs, err := interfaceAddrs()
+	if err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+				return ipNet.IP.String()
+			}
+		}
+	}
+	return "127.0.0.1"
+}
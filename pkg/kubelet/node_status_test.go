@@ -0,0 +1,482 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+// fakeNodeAPI records the node requests the kubelet sends and answers them with canned status codes
+type fakeNodeAPI struct {
+	mutex        sync.Mutex
+	createStatus int
+	// createFailures answers the first creates with 503, as an API server that is still starting
+	createFailures int
+	updateStatuses []int
+	// existing is the node served by GET, nil for 404
+	existing *api.Node
+	// onGet is called for every GET of the node, e.g. to remove it as the node controller would
+	onGet    func(f *fakeNodeAPI)
+	created  []*api.Node
+	updates  []*api.Node
+	replaced []*api.Node
+	// leases are the node leases renewed, answered with leaseAcquireTime as the time the lease was acquired
+	leases           []*api.Lease
+	leaseAcquireTime time.Time
+}
+
+func (f *fakeNodeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/nodes":
+		node := &api.Node{}
+		_ = json.NewDecoder(r.Body).Decode(node)
+		f.created = append(f.created, node)
+		if f.createFailures > 0 {
+			f.createFailures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(f.createStatus)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/nodes/node-1":
+		existing := f.existing
+		if f.onGet != nil {
+			f.onGet(f)
+		}
+		if existing == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(existing)
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1/nodes/node-1":
+		node := &api.Node{}
+		_ = json.NewDecoder(r.Body).Decode(node)
+		f.replaced = append(f.replaced, node)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1/leases/nodes/node-1":
+		lease := &api.Lease{}
+		_ = json.NewDecoder(r.Body).Decode(lease)
+		f.leases = append(f.leases, lease)
+		renewed := *lease
+		renewed.AcquireTime = f.leaseAcquireTime
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(&renewed)
+	case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/status"):
+		node := &api.Node{}
+		_ = json.NewDecoder(r.Body).Decode(node)
+		f.updates = append(f.updates, node)
+
+		status := http.StatusOK
+		if len(f.updateStatuses) > 0 {
+			status, f.updateStatuses = f.updateStatuses[0], f.updateStatuses[1:]
+		}
+		w.WriteHeader(status)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestKubelet(t *testing.T, handler http.Handler) *Kubelet {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return &Kubelet{
+		nodeName:            "node-1",
+		nodeUID:             "machine-1",
+		apiClient:           newTestAPIClient(t, server.URL),
+		pods:                newPodManager(),
+		restartBackoff:      newBackoff(DefaultRestartBackoff, MaxRestartBackoff),
+		registrationBackoff: newBackoff(time.Millisecond, time.Millisecond),
+		statusBackoff:       newBackoff(DefaultStatusUpdateBackoff, MaxStatusUpdateBackoff),
+		images:              newImageManager(&fakeImagePuller{}),
+		options:             DefaultOptions(),
+		capacity:            nodeCapacity(DefaultMaxPods),
+		now:                 func() time.Time { return now },
+		syncRequests:        make(chan struct{}, 1),
+	}
+}
+
+// newTestAPIClient returns a client of the API server at address
+func newTestAPIClient(t *testing.T, address string) *client.Client {
+	t.Helper()
+	c, err := client.New(address, apiClientOptions)
+	require.NoError(t, err)
+	return c
+}
+
+func TestRegisterNode(t *testing.T) {
+	t.Run("should create the node", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{createStatus: http.StatusCreated}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.registerNode())
+		assert.Empty(t, fakeAPI.updates)
+	})
+
+	t.Run("should report the node capacity and configured max pods", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{createStatus: http.StatusCreated}
+		server := httptest.NewServer(fakeAPI)
+		defer server.Close()
+
+		options := DefaultOptions()
+		options.MaxPods = 7
+		kubelet, err := NewKubeletWithOptions("node-1", strings.TrimPrefix(server.URL, "http://"), options)
+		require.NoError(t, err)
+
+		require.NoError(t, kubelet.registerNode())
+		require.Len(t, fakeAPI.created, 1)
+		assert.Greater(t, fakeAPI.created[0].Capacity.CPU, int64(0))
+		assert.Greater(t, fakeAPI.created[0].Capacity.Memory, int64(0))
+		assert.Equal(t, int32(7), fakeAPI.created[0].Capacity.MaxPods)
+	})
+
+	t.Run("should register the node with the machine UID", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{createStatus: http.StatusCreated}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.registerNode())
+		require.Len(t, fakeAPI.created, 1)
+		assert.Equal(t, "machine-1", fakeAPI.created[0].UID)
+	})
+
+	t.Run("should update the status of a node this machine registered", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{
+			createStatus: http.StatusConflict,
+			existing:     &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1", UID: "machine-1"}},
+		}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.registerNode())
+		assert.Empty(t, fakeAPI.replaced)
+		require.Len(t, fakeAPI.updates, 1)
+		assert.Equal(t, "node-1", fakeAPI.updates[0].Name)
+		assert.Equal(t, api.NodeReady, fakeAPI.updates[0].Status)
+	})
+
+	t.Run("should adopt a registered node without a UID", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{
+			createStatus: http.StatusConflict,
+			existing:     &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1"}, Status: api.NodeNotReady},
+		}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.registerNode())
+		require.Len(t, fakeAPI.replaced, 1)
+		assert.Equal(t, "machine-1", fakeAPI.replaced[0].UID)
+		assert.Len(t, fakeAPI.updates, 1)
+	})
+
+	t.Run("should fail when the node belongs to another machine", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{
+			createStatus: http.StatusConflict,
+			existing:     &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1", UID: "machine-2"}},
+		}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		err := kubelet.registerNodeWithRetry()
+		assert.ErrorIs(t, err, ErrNodeUIDConflict)
+		assert.Len(t, fakeAPI.created, 1, "a UID conflict must not be retried")
+		assert.Empty(t, fakeAPI.replaced)
+		assert.Empty(t, fakeAPI.updates)
+	})
+
+	t.Run("should fail on client errors", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{createStatus: http.StatusBadRequest}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		assert.Error(t, kubelet.registerNodeWithRetry())
+		assert.Len(t, fakeAPI.created, 1)
+	})
+}
+
+func TestRegisterNodeWithRetry(t *testing.T) {
+	t.Run("should retry while the API server is unavailable", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{createStatus: http.StatusCreated, createFailures: 3}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.registerNodeWithRetry())
+		assert.Len(t, fakeAPI.created, 4)
+		assert.Zero(t, kubelet.registrationBackoff.Delay(registrationKey))
+	})
+
+	t.Run("should retry while the API server is unreachable", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{createStatus: http.StatusCreated}
+		address := unusedAddress(t)
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.apiClient = newTestAPIClient(t, address)
+
+		done := make(chan error, 1)
+		go func() { done <- kubelet.registerNodeWithRetry() }()
+
+		// Bring the API server up on the address the kubelet is retrying
+		time.Sleep(20 * time.Millisecond)
+		startServerOn(t, address, fakeAPI)
+
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("registration did not complete after the API server came up")
+		}
+		assert.Len(t, fakeAPI.created, 1)
+	})
+
+	t.Run("should take over the node of another machine once it is removed", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{
+			createStatus: http.StatusConflict,
+			existing:     &api.Node{ObjectMeta: api.ObjectMeta{Name: "node-1", UID: "machine-2"}, Status: api.NodeNotReady},
+		}
+		gets := 0
+		fakeAPI.onGet = func(f *fakeNodeAPI) {
+			// The node controller removes the dead node while the kubelet waits
+			if gets++; gets == 2 {
+				f.existing, f.createStatus = nil, http.StatusCreated
+			}
+		}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.registerNodeWithRetry())
+		require.Len(t, fakeAPI.created, 3)
+		assert.Equal(t, "machine-1", fakeAPI.created[2].UID)
+		assert.Empty(t, fakeAPI.replaced, "the node of the other machine must not be overwritten")
+		assert.Empty(t, fakeAPI.updates)
+	})
+
+	t.Run("should give up after the registration timeout", func(t *testing.T) {
+		kubelet := newTestKubelet(t, &fakeNodeAPI{createStatus: http.StatusCreated})
+		kubelet.apiClient = newTestAPIClient(t, unusedAddress(t))
+		kubelet.options.RegistrationTimeout = 50 * time.Millisecond
+		kubelet.registrationBackoff = newBackoff(10*time.Millisecond, 10*time.Millisecond)
+
+		err := kubelet.registerNodeWithRetry()
+		assert.ErrorIs(t, err, ErrRegistrationTimeout)
+		assert.Zero(t, kubelet.registrationBackoff.Delay(registrationKey))
+	})
+}
+
+func TestStartWaitsForAPIServer(t *testing.T) {
+	fakeAPI := &fakeNodeAPI{createStatus: http.StatusCreated}
+	address := unusedAddress(t)
+	kubelet := newTestKubelet(t, fakeAPI)
+	kubelet.apiClient = newTestAPIClient(t, address)
+	kubelet.runtime = fakeruntime.New()
+	kubelet.registrationBackoff = newBackoff(100*time.Millisecond, 500*time.Millisecond)
+	kubelet.options.Port = freePort(t)
+	t.Cleanup(func() { _ = kubelet.Stop(context.Background()) })
+
+	done := make(chan error, 1)
+	go func() { done <- kubelet.Start() }()
+
+	// The kubelet starts first, the API server two seconds later
+	time.Sleep(2 * time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("Start returned before the API server was up: %v", err)
+	default:
+	}
+	startServerOn(t, address, fakeAPI)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the node was not registered after the API server came up")
+	}
+	fakeAPI.mutex.Lock()
+	defer fakeAPI.mutex.Unlock()
+	require.Len(t, fakeAPI.created, 1)
+	assert.Equal(t, "node-1", fakeAPI.created[0].Name)
+}
+
+// unusedAddress returns a local address nothing listens on
+func unusedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return address
+}
+
+func freePort(t *testing.T) int {
+	_, port, err := net.SplitHostPort(unusedAddress(t))
+	require.NoError(t, err)
+	number, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return number
+}
+
+// startServerOn serves handler on address, as an API server that comes up late
+func startServerOn(t *testing.T, address string, handler http.Handler) {
+	listener, err := net.Listen("tcp", address)
+	require.NoError(t, err)
+	server := &httptest.Server{Listener: listener, Config: &http.Server{Handler: handler}}
+	server.Start()
+	t.Cleanup(server.Close)
+}
+
+func TestResolveNodeName(t *testing.T) {
+	hostname := func(name string) func() (string, error) {
+		return func() (string, error) { return name, nil }
+	}
+
+	t.Run("should use the given name", func(t *testing.T) {
+		name, err := resolveNodeName("node-1", hostname("host"))
+		require.NoError(t, err)
+		assert.Equal(t, "node-1", name)
+	})
+
+	t.Run("should reject an invalid given name", func(t *testing.T) {
+		_, err := resolveNodeName("Node_1", hostname("host"))
+		assert.ErrorIs(t, err, api.ErrInvalidNodeName)
+	})
+
+	t.Run("should default to the lowercased host name", func(t *testing.T) {
+		name, err := resolveNodeName("", hostname("Worker-1.Example.COM."))
+		require.NoError(t, err)
+		assert.Equal(t, "worker-1.example.com", name)
+	})
+
+	t.Run("should reject a host name that is not a valid node name", func(t *testing.T) {
+		_, err := resolveNodeName("", hostname("my_laptop"))
+		assert.ErrorIs(t, err, api.ErrInvalidNodeName)
+		assert.ErrorContains(t, err, "my_laptop")
+	})
+
+	t.Run("should fail when the host name is unknown", func(t *testing.T) {
+		_, err := resolveNodeName("", func() (string, error) { return "", errors.New("no host name") })
+		assert.Error(t, err)
+	})
+}
+
+func TestNodeAddress(t *testing.T) {
+	interfaceAddrs := func(cidrs ...string) func() ([]net.Addr, error) {
+		return func() ([]net.Addr, error) {
+			var addrs []net.Addr
+			for _, cidr := range cidrs {
+				ip, ipNet, err := net.ParseCIDR(cidr)
+				require.NoError(t, err)
+				addrs = append(addrs, &net.IPNet{IP: ip, Mask: ipNet.Mask})
+			}
+			return addrs, nil
+		}
+	}
+
+	assert.Equal(t, "10.0.0.5", nodeAddress("10.0.0.5", interfaceAddrs("192.168.1.2/24")), "the address the kubelet serves on")
+	assert.Equal(t, "192.168.1.2", nodeAddress("0.0.0.0", interfaceAddrs("127.0.0.1/8", "fe80::1/64", "192.168.1.2/24")),
+		"the first address that is not a loopback address when serving on all interfaces")
+	assert.Equal(t, "127.0.0.1", nodeAddress("0.0.0.0", interfaceAddrs("127.0.0.1/8")), "only reachable locally")
+}
+
+func TestMachineID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine-id")
+	require.NoError(t, os.WriteFile(path, []byte("abc123\n"), 0o644))
+
+	assert.Equal(t, "abc123", machineID(path))
+	assert.Empty(t, machineID(filepath.Join(t.TempDir(), "missing")))
+}
+
+func TestSyncNodeStatus(t *testing.T) {
+	t.Run("should report ready with the heartbeat time", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.syncNodeStatus())
+		require.Len(t, fakeAPI.updates, 1)
+		assert.Equal(t, api.NodeReady, fakeAPI.updates[0].Status)
+		assert.True(t, kubelet.now().Equal(fakeAPI.updates[0].LastHeartbeatTime))
+		assert.Equal(t, kubelet.capacity, fakeAPI.updates[0].Capacity)
+	})
+
+	t.Run("should retry failed updates", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{updateStatuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		require.NoError(t, kubelet.syncNodeStatus())
+		assert.Len(t, fakeAPI.updates, 3)
+	})
+
+	t.Run("should give up after the retry limit", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{updateStatuses: []int{
+			http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK,
+		}}
+		kubelet := newTestKubelet(t, fakeAPI)
+
+		assert.Error(t, kubelet.syncNodeStatus())
+		assert.Len(t, fakeAPI.updates, nodeStatusUpdateRetry)
+	})
+	t.Run("should skip unchanged statuses until the report frequency while the lease is held", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{}
+		kubelet := newTestKubelet(t, fakeAPI)
+		now := kubelet.now()
+		kubelet.now = func() time.Time { return now }
+
+		require.NoError(t, kubelet.syncNodeStatus())
+		now = now.Add(kubelet.options.NodeStatusUpdateFrequency)
+		require.NoError(t, kubelet.syncNodeStatus())
+		assert.Len(t, fakeAPI.updates, 1, "an unchanged status should not be reported again")
+
+		// A changed status is reported right away
+		kubelet.capacity.MaxPods++
+		require.NoError(t, kubelet.syncNodeStatus())
+		assert.Len(t, fakeAPI.updates, 2)
+
+		// An unchanged one once the report frequency passed
+		now = now.Add(kubelet.options.NodeStatusReportFrequency)
+		require.NoError(t, kubelet.syncNodeStatus())
+		require.Len(t, fakeAPI.updates, 3)
+		assert.True(t, now.Equal(fakeAPI.updates[2].LastHeartbeatTime))
+	})
+
+	t.Run("should report every status without a lease", func(t *testing.T) {
+		fakeAPI := &fakeNodeAPI{}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.options.NodeLeaseDurationSeconds = 0
+
+		require.NoError(t, kubelet.syncNodeStatus())
+		require.NoError(t, kubelet.syncNodeStatus())
+		assert.Len(t, fakeAPI.updates, 2)
+	})
+}
+
+func TestSyncNodeLease(t *testing.T) {
+	acquired := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)
+	fakeAPI := &fakeNodeAPI{leaseAcquireTime: acquired}
+	kubelet := newTestKubelet(t, fakeAPI)
+
+	require.NoError(t, kubelet.syncNodeLease())
+	require.Len(t, fakeAPI.leases, 1)
+	assert.Equal(t, "machine-1", fakeAPI.leases[0].HolderIdentity)
+	assert.Equal(t, int32(DefaultNodeLeaseDurationSeconds), fakeAPI.leases[0].LeaseDurationSeconds)
+
+	require.NoError(t, kubelet.syncNodeStatus())
+	require.NoError(t, kubelet.syncNodeLease())
+	require.NoError(t, kubelet.syncNodeStatus())
+	assert.Len(t, fakeAPI.updates, 1, "renewing the held lease should not make the status due")
+
+	// The lease expired and was acquired again, so the node may have been marked NotReady meanwhile
+	fakeAPI.leaseAcquireTime = acquired.Add(time.Minute)
+	require.NoError(t, kubelet.syncNodeLease())
+	require.NoError(t, kubelet.syncNodeStatus())
+	assert.Len(t, fakeAPI.updates, 2)
+}
@@ -0,0 +1,194 @@
+package kubelet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+const (
+	// DefaultNodeStatusUpdateFrequency is how often the kubelet checks the node status for changes to report when
+	// no frequency is configured
+	DefaultNodeStatusUpdateFrequency = 10 * time.Second
+	// DefaultNodeStatusReportFrequency is how often the kubelet reports a node status that did not change while
+	// its node lease tells the node is alive
+	DefaultNodeStatusReportFrequency = time.Minute
+	// DefaultNodeLeaseDurationSeconds is how long the node lease lasts after the kubelet last renewed it
+	DefaultNodeLeaseDurationSeconds = 40
+	// DefaultRelistPeriod is how often the kubelet lists its pods when the API server cannot watch them
+	DefaultRelistPeriod = 60 * time.Second
+	// DefaultSyncInterval is how often the kubelet reconciles its pods when no pod event asks for it sooner
+	DefaultSyncInterval = 10 * time.Second
+	// DefaultStatusUpdateInterval disables reporting pod statuses that did not change
+	DefaultStatusUpdateInterval = 0
+	// DefaultAddress serves the kubelet API on all interfaces
+	DefaultAddress = "0.0.0.0"
+	// DefaultPort is the port the kubelet serves its API on
+	DefaultPort = 10250
+	// DefaultMaxParallelPodStarts is how many pods are started concurrently when no limit is configured
+	DefaultMaxParallelPodStarts = 4
+	// DefaultMaxPods is the number of pods a node advertises when no limit is configured
+	DefaultMaxPods = 16
+	// DefaultContainerGCPeriod is how often the kubelet removes dead containers
+	DefaultContainerGCPeriod = time.Minute
+	// DefaultMaxDeadContainersPerPod is how many dead containers of a removed pod are kept for debugging
+	DefaultMaxDeadContainersPerPod = 1
+	// DefaultOrphanGracePeriod is how old a container must be before it is removed for having no pod
+	DefaultOrphanGracePeriod = time.Minute
+	// DefaultFileCheckFrequency is how often the kubelet reads the static pod manifests
+	DefaultFileCheckFrequency = 20 * time.Second
+	// DefaultRegistrationTimeout is how long the kubelet waits for the API server to register the node
+	DefaultRegistrationTimeout = 5 * time.Minute
+	// DefaultDockerRootDir is where Docker keeps images and containers, whose disk is watched for pressure
+	DefaultDockerRootDir = "/var/lib/docker"
+
+	MinNodeStatusUpdateFrequency    = 100 * time.Millisecond
+	MinSyncInterval                 = 100 * time.Millisecond
+	MinStatusUpdateInterval         = 100 * time.Millisecond
+	MinRelistPeriod                 = 100 * time.Millisecond
+	MinMaxPods                      = 1
+	MinMaxParallelPodStarts         = 1
+	MinContainerGCPeriod            = 100 * time.Millisecond
+	MinFileCheckFrequency           = 100 * time.Millisecond
+	MaxPort                         = 65535
+	MaxEvictionDiskAvailablePercent = 99
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid kubelet options")
+)
+
+// Options configures the periodic work of a Kubelet and the capacity it advertises
+type Options struct {
+	NodeStatusUpdateFrequency time.Duration
+	RelistPeriod              time.Duration
+	MaxPods                   int32
+	// NodeStatusReportFrequency is how often the node status is reported when it did not change, while the node
+	// lease tells the node is alive
+	NodeStatusReportFrequency time.Duration
+	// NodeLeaseDurationSeconds is how long the lease of the node lasts after it was last renewed; the kubelet
+	// renews it every quarter of that. Zero disables the lease, and the node status is then reported every
+	// NodeStatusUpdateFrequency to tell the node is alive.
+	NodeLeaseDurationSeconds int32
+	// SyncInterval is how often the desired pods are reconciled with the containers of the runtime
+	SyncInterval time.Duration
+	// StatusUpdateInterval is how often pod statuses are reported even when they did not change; zero
+	// reports them only on changes
+	StatusUpdateInterval time.Duration
+	// MaxParallelPodStarts bounds how many pods pull images and create containers at the same time
+	MaxParallelPodStarts int
+	// Address is the IP address the kubelet serves its API on
+	Address string
+	// Port is where the kubelet serves healthz, its pods and container logs
+	Port int
+	// ContainerGCPeriod is how often dead containers of removed pods are garbage collected
+	ContainerGCPeriod time.Duration
+	// MaxDeadContainersPerPod is how many dead containers of a removed pod are kept for debugging
+	MaxDeadContainersPerPod int
+	// OrphanGracePeriod keeps containers created this recently from being removed as orphans, as their pod
+	// may have been created after the API server was asked for the pods of the node
+	OrphanGracePeriod time.Duration
+	// PodManifestPath is a directory of pod manifests the kubelet runs as static pods; empty disables them
+	PodManifestPath string
+	// FileCheckFrequency is how often the manifests in PodManifestPath are read
+	FileCheckFrequency time.Duration
+	// RegistrationTimeout is how long Start retries registering the node before giving up; zero retries forever
+	RegistrationTimeout time.Duration
+	// EvictionMemoryAvailable puts the node under memory pressure when less memory than this many bytes is
+	// available, and pods are evicted until enough is free again; zero disables it
+	EvictionMemoryAvailable int64
+	// EvictionDiskAvailablePercent puts the node under disk pressure when less than this percentage of the
+	// disk of DockerRootDir is available, and pods are evicted until enough is free again; zero disables it
+	EvictionDiskAvailablePercent int
+	// DockerRootDir is the directory whose disk is watched for EvictionDiskAvailablePercent
+	DockerRootDir string
+}
+
+// DefaultOptions returns the Options used by NewKubelet
+func DefaultOptions() Options {
+	return Options{
+		NodeStatusUpdateFrequency: DefaultNodeStatusUpdateFrequency,
+		NodeStatusReportFrequency: DefaultNodeStatusReportFrequency,
+		NodeLeaseDurationSeconds:  DefaultNodeLeaseDurationSeconds,
+		RelistPeriod:              DefaultRelistPeriod,
+		SyncInterval:              DefaultSyncInterval,
+		StatusUpdateInterval:      DefaultStatusUpdateInterval,
+		MaxPods:                   DefaultMaxPods,
+		MaxParallelPodStarts:      DefaultMaxParallelPodStarts,
+		Address:                   DefaultAddress,
+		Port:                      DefaultPort,
+		ContainerGCPeriod:         DefaultContainerGCPeriod,
+		MaxDeadContainersPerPod:   DefaultMaxDeadContainersPerPod,
+		OrphanGracePeriod:         DefaultOrphanGracePeriod,
+		FileCheckFrequency:        DefaultFileCheckFrequency,
+		RegistrationTimeout:       DefaultRegistrationTimeout,
+		DockerRootDir:             DefaultDockerRootDir,
+	}
+}
+
+// Validate checks that the options are within sane bounds
+func (o Options) Validate() error {
+	if o.NodeStatusUpdateFrequency < MinNodeStatusUpdateFrequency {
+		return fmt.Errorf("%w: node status update frequency %v is below the minimum of %v", ErrInvalidOptions, o.NodeStatusUpdateFrequency, MinNodeStatusUpdateFrequency)
+	}
+	if o.NodeLeaseDurationSeconds != 0 && o.NodeLeaseDurationSeconds < api.MinLeaseDurationSeconds {
+		return fmt.Errorf("%w: node lease duration must be 0 or at least %d seconds, got %d", ErrInvalidOptions, api.MinLeaseDurationSeconds, o.NodeLeaseDurationSeconds)
+	}
+	if o.NodeLeaseDurationSeconds != 0 && o.NodeStatusReportFrequency < o.NodeStatusUpdateFrequency {
+		return fmt.Errorf("%w: node status report frequency %v is below the node status update frequency of %v", ErrInvalidOptions, o.NodeStatusReportFrequency, o.NodeStatusUpdateFrequency)
+	}
+	if o.RelistPeriod < MinRelistPeriod {
+		return fmt.Errorf("%w: relist period %v is below the minimum of %v", ErrInvalidOptions, o.RelistPeriod, MinRelistPeriod)
+	}
+	if o.SyncInterval < MinSyncInterval {
+		return fmt.Errorf("%w: sync interval %v is below the minimum of %v", ErrInvalidOptions, o.SyncInterval, MinSyncInterval)
+	}
+	if o.StatusUpdateInterval != 0 && o.StatusUpdateInterval < MinStatusUpdateInterval {
+		return fmt.Errorf("%w: status update interval %v is below the minimum of %v", ErrInvalidOptions, o.StatusUpdateInterval, MinStatusUpdateInterval)
+	}
+	if o.MaxPods < MinMaxPods {
+		return fmt.Errorf("%w: max pods must be at least %d, got %d", ErrInvalidOptions, MinMaxPods, o.MaxPods)
+	}
+	if o.MaxParallelPodStarts < MinMaxParallelPodStarts {
+		return fmt.Errorf("%w: max parallel pod starts must be at least %d, got %d", ErrInvalidOptions, MinMaxParallelPodStarts, o.MaxParallelPodStarts)
+	}
+	if net.ParseIP(o.Address) == nil {
+		return fmt.Errorf("%w: address must be an IP address, got %q", ErrInvalidOptions, o.Address)
+	}
+	if o.Port < 1 || o.Port > MaxPort {
+		return fmt.Errorf("%w: port must be between 1 and %d, got %d", ErrInvalidOptions, MaxPort, o.Port)
+	}
+	if o.ContainerGCPeriod < MinContainerGCPeriod {
+		return fmt.Errorf("%w: container GC period %v is below the minimum of %v", ErrInvalidOptions, o.ContainerGCPeriod, MinContainerGCPeriod)
+	}
+	if o.MaxDeadContainersPerPod < 0 {
+		return fmt.Errorf("%w: max dead containers per pod must not be negative, got %d", ErrInvalidOptions, o.MaxDeadContainersPerPod)
+	}
+	if o.OrphanGracePeriod < 0 {
+		return fmt.Errorf("%w: orphan grace period must not be negative, got %v", ErrInvalidOptions, o.OrphanGracePeriod)
+	}
+	if o.PodManifestPath != "" && o.FileCheckFrequency < MinFileCheckFrequency {
+		return fmt.Errorf("%w: file check frequency %v is below the minimum of %v", ErrInvalidOptions, o.FileCheckFrequency, MinFileCheckFrequency)
+	}
+	if o.RegistrationTimeout < 0 {
+		return fmt.Errorf("%w: registration timeout must not be negative, got %v", ErrInvalidOptions, o.RegistrationTimeout)
+	}
+	if o.EvictionMemoryAvailable < 0 {
+		return fmt.Errorf("%w: eviction memory available must not be negative, got %d", ErrInvalidOptions, o.EvictionMemoryAvailable)
+	}
+	if o.EvictionDiskAvailablePercent < 0 || o.EvictionDiskAvailablePercent > MaxEvictionDiskAvailablePercent {
+		return fmt.Errorf("%w: eviction disk available percent must be between 0 and %d, got %d", ErrInvalidOptions, MaxEvictionDiskAvailablePercent, o.EvictionDiskAvailablePercent)
+	}
+	if o.EvictionDiskAvailablePercent > 0 && o.DockerRootDir == "" {
+		return fmt.Errorf("%w: a docker root dir is required to watch its disk for pressure", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// evictionEnabled checks if the kubelet watches the node for pressure and evicts pods
+func (o Options) evictionEnabled() bool {
+	return o.EvictionMemoryAvailable > 0 || o.EvictionDiskAvailablePercent > 0
+}
@@ -0,0 +1,83 @@
+package kubelet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	withDefaults := func(modify func(o *Options)) Options {
+		options := DefaultOptions()
+		modify(&options)
+		return options
+	}
+
+	testCases := []struct {
+		name    string
+		options Options
+		wantErr bool
+	}{
+		{name: "defaults", options: DefaultOptions()},
+		{name: "minimum values", options: Options{
+			NodeStatusUpdateFrequency: MinNodeStatusUpdateFrequency,
+			RelistPeriod:              MinRelistPeriod,
+			SyncInterval:              MinSyncInterval,
+			MaxPods:                   MinMaxPods,
+			MaxParallelPodStarts:      MinMaxParallelPodStarts,
+			Address:                   "127.0.0.1",
+			Port:                      1,
+			ContainerGCPeriod:         MinContainerGCPeriod,
+		}},
+		{name: "frequency too short", options: withDefaults(func(o *Options) { o.NodeStatusUpdateFrequency = time.Millisecond }), wantErr: true},
+		{name: "relist period too short", options: withDefaults(func(o *Options) { o.RelistPeriod = time.Millisecond }), wantErr: true},
+		{name: "sync interval too short", options: withDefaults(func(o *Options) { o.SyncInterval = time.Millisecond }), wantErr: true},
+		{name: "status update interval too short", options: withDefaults(func(o *Options) { o.StatusUpdateInterval = time.Millisecond }), wantErr: true},
+		{name: "status update interval", options: withDefaults(func(o *Options) { o.StatusUpdateInterval = time.Second })},
+		{name: "IPv6 address", options: withDefaults(func(o *Options) { o.Address = "::1" })},
+		{name: "host name address", options: withDefaults(func(o *Options) { o.Address = "localhost" }), wantErr: true},
+		{name: "zero max pods", options: withDefaults(func(o *Options) { o.MaxPods = 0 }), wantErr: true},
+		{name: "zero parallel pod starts", options: withDefaults(func(o *Options) { o.MaxParallelPodStarts = 0 }), wantErr: true},
+		{name: "zero port", options: withDefaults(func(o *Options) { o.Port = 0 }), wantErr: true},
+		{name: "container GC period too short", options: withDefaults(func(o *Options) { o.ContainerGCPeriod = time.Millisecond }), wantErr: true},
+		{name: "negative orphan grace period", options: withDefaults(func(o *Options) { o.OrphanGracePeriod = -time.Second }), wantErr: true},
+		{name: "negative dead containers per pod", options: withDefaults(func(o *Options) { o.MaxDeadContainersPerPod = -1 }), wantErr: true},
+		{name: "file check frequency too short", options: withDefaults(func(o *Options) { o.PodManifestPath, o.FileCheckFrequency = "/etc/gokube/manifests", time.Millisecond }), wantErr: true},
+		{name: "file check frequency unused without manifest path", options: withDefaults(func(o *Options) { o.FileCheckFrequency = 0 })},
+		{name: "no registration timeout", options: withDefaults(func(o *Options) { o.RegistrationTimeout = 0 })},
+		{name: "negative registration timeout", options: withDefaults(func(o *Options) { o.RegistrationTimeout = -time.Second }), wantErr: true},
+		{name: "port out of range", options: withDefaults(func(o *Options) { o.Port = MaxPort + 1 }), wantErr: true},
+		{name: "node lease disabled", options: withDefaults(func(o *Options) { o.NodeLeaseDurationSeconds, o.NodeStatusReportFrequency = 0, 0 })},
+		{name: "node lease duration too short", options: withDefaults(func(o *Options) { o.NodeLeaseDurationSeconds = 1 }), wantErr: true},
+		{name: "node status report frequency below update frequency", options: withDefaults(func(o *Options) { o.NodeStatusReportFrequency = time.Second }), wantErr: true},
+		{name: "eviction thresholds", options: withDefaults(func(o *Options) { o.EvictionMemoryAvailable, o.EvictionDiskAvailablePercent = 100<<20, 10 })},
+		{name: "negative eviction memory available", options: withDefaults(func(o *Options) { o.EvictionMemoryAvailable = -1 }), wantErr: true},
+		{name: "eviction disk available percent too high", options: withDefaults(func(o *Options) { o.EvictionDiskAvailablePercent = 100 }), wantErr: true},
+		{name: "eviction disk available percent without docker root dir", options: withDefaults(func(o *Options) { o.EvictionDiskAvailablePercent, o.DockerRootDir = 10, "" }), wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidOptions)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewKubeletWithOptions(t *testing.T) {
+	_, err := NewKubeletWithOptions("node-1", "localhost:8080", Options{})
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+
+	options := DefaultOptions()
+	options.NodeStatusUpdateFrequency = time.Second
+	options.MaxPods = 4
+	k, err := NewKubeletWithOptions("node-1", "localhost:8080", options)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, k.options.NodeStatusUpdateFrequency)
+	assert.Equal(t, int32(4), k.capacity.MaxPods)
+}
@@ -0,0 +1,158 @@
+package kubelet
+
+import (
+	"sync"
+
+	"gokube/pkg/api"
+)
+
+// podManager keeps track of the pods the kubelet is running. It is shared by the
+// assignment and status loops, so every access goes through its lock. Pods are looked up by namespace and
+// name, as only one pod of a name can be assigned per namespace at a time, but changes are only applied to
+// the same pod instance.
+type podManager struct {
+	mutex sync.RWMutex
+	// pods holds the tracked pods by podFullName
+	pods map[string]*api.Pod
+	// starting holds the pod instances, by podKey, that are queued or being started and whose containers
+	// may not exist yet
+	starting map[string]struct{}
+}
+
+// podFullName identifies a pod on the node. Pod names are only unique within a namespace, so pods of the same
+// name in different namespaces are told apart; a pod without a namespace is in the default namespace.
+func podFullName(namespace, name string) string {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	return namespace + "/" + name
+}
+
+// podKey identifies a pod instance. A pod that is deleted and recreated under the same name gets a new UID,
+// so the state and containers of the old pod are never mistaken for the new one's.
+func podKey(namespace, name, uid string) string {
+	return podFullName(namespace, name) + "/" + uid
+}
+
+func newPodManager() *podManager {
+	return &podManager{
+		pods:     make(map[string]*api.Pod),
+		starting: make(map[string]struct{}),
+	}
+}
+
+// Add records the pod if it is not tracked yet and reports whether it was added
+func (m *podManager) Add(pod *api.Pod) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := podFullName(pod.Namespace, pod.Name)
+	if _, exists := m.pods[name]; exists {
+		return false
+	}
+	m.pods[name] = pod
+	return true
+}
+
+// Update replaces the tracked copy of the pod
+func (m *podManager) Update(pod *api.Pod) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.pods[podFullName(pod.Namespace, pod.Name)] = pod
+}
+
+// Mutate applies fn to a copy of the tracked instance of pod and stores the copy, so concurrent readers
+// keep seeing the previous version. It returns the updated pod, or false if the pod is not tracked or was
+// replaced by a pod with another UID.
+func (m *podManager) Mutate(pod *api.Pod, fn func(pod *api.Pod)) (*api.Pod, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := podFullName(pod.Namespace, pod.Name)
+	current, ok := m.pods[name]
+	if !ok || current.UID != pod.UID {
+		return nil, false
+	}
+	updatedPod := *current
+	updatedPod.ContainerStatuses = append([]api.ContainerStatus(nil), current.ContainerStatuses...)
+	fn(&updatedPod)
+	m.pods[name] = &updatedPod
+	return &updatedPod, true
+}
+
+// Get returns the tracked pod with the given namespace and name
+func (m *podManager) Get(namespace, name string) (*api.Pod, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	pod, ok := m.pods[podFullName(namespace, name)]
+	return pod, ok
+}
+
+// List returns a snapshot of the tracked pods that is safe to iterate without holding the lock
+func (m *podManager) List() []*api.Pod {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	pods := make([]*api.Pod, 0, len(m.pods))
+	for _, pod := range m.pods {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+// Has checks if this instance of the pod is tracked, i.e. it was neither removed nor replaced by a pod with
+// the same namespace and name and another UID
+func (m *podManager) Has(pod *api.Pod) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	current, ok := m.pods[podFullName(pod.Namespace, pod.Name)]
+	return ok && current.UID == pod.UID
+}
+
+// Delete stops tracking the pod with the given namespace and name
+func (m *podManager) Delete(namespace, name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	fullName := podFullName(namespace, name)
+	if pod, ok := m.pods[fullName]; ok {
+		delete(m.starting, podKey(pod.Namespace, pod.Name, pod.UID))
+	}
+	delete(m.pods, fullName)
+}
+
+// MarkStarting records that the pod's containers are being started
+func (m *podManager) MarkStarting(pod *api.Pod) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.starting[podKey(pod.Namespace, pod.Name, pod.UID)] = struct{}{}
+}
+
+// MarkStarted records that the kubelet finished starting the pod's containers
+func (m *podManager) MarkStarted(pod *api.Pod) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.starting, podKey(pod.Namespace, pod.Name, pod.UID))
+}
+
+// IsStarting checks if the pod is queued or being started
+func (m *podManager) IsStarting(pod *api.Pod) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, ok := m.starting[podKey(pod.Namespace, pod.Name, pod.UID)]
+	return ok
+}
+
+// Len returns the number of tracked pods
+func (m *podManager) Len() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return len(m.pods)
+}
@@ -0,0 +1,208 @@
+package kubelet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+)
+
+const (
+	// DefaultStatusUpdateBackoff is how long the kubelet waits before retrying a failed pod status update
+	DefaultStatusUpdateBackoff = 1 * time.Second
+	// MaxStatusUpdateBackoff caps the delay between status update attempts for a pod
+	MaxStatusUpdateBackoff = 1 * time.Minute
+)
+
+// updatePodStatus queues the status of a pod to be reported to the API server, see reportPodStatus. A status
+// of the pod that was not sent yet is replaced, and failed updates are retried with backoff until one lands.
+func (k *Kubelet) updatePodStatus(pod *api.Pod) {
+	k.statusUpdates().Add(pod)
+}
+
+// reportPodStatus sends the status of a pod to the API server, queueing it again with backoff if that fails.
+// A pod the API server no longer has is forgotten, unless it is a static pod whose mirror must be published
+// again; on a conflict the pod is fetched again to find out whether it was recreated or bound to another node
+// in the meantime.
+func (k *Kubelet) reportPodStatus(pod *api.Pod) {
+	fullName := podFullName(pod.Namespace, pod.Name)
+	err := k.putPodStatus(pod)
+	if client.IsConflict(err) {
+		err = k.resolvePodStatusConflict(pod)
+	}
+	switch {
+	case err == nil:
+		k.statusBackoff.Reset(fullName)
+		k.statusReports.Record(fullName, k.now())
+	case client.IsNotFound(err) && k.staticPods.Has(pod.Namespace, pod.Name):
+		// The mirror pod is published again on the next manifest check
+		k.staticPods.MarkUnmirrored(pod.Namespace, pod.Name)
+	case client.IsNotFound(err):
+		k.log().Info("Pod no longer exists on the API server", "pod", pod.Name)
+		k.removePod(pod.Namespace, pod.Name)
+	default:
+		k.statusBackoff.Next(fullName)
+		delay := k.statusBackoff.Delay(fullName)
+		k.apiServerLog.Error(err, "Error updating status for pod %s, retrying in %v", pod.Name, delay)
+		k.statusUpdates().Retry(pod, delay)
+	}
+}
+
+// hasPendingStatusUpdate checks if the last status update of the pod failed and still has to be retried
+func (k *Kubelet) hasPendingStatusUpdate(pod *api.Pod) bool {
+	return k.statusBackoff.Delay(podFullName(pod.Namespace, pod.Name)) > 0
+}
+
+// statusReportDue checks if the status of the pod has to be reported again although it did not change,
+// because StatusUpdateInterval passed since it was last accepted by the API server
+func (k *Kubelet) statusReportDue(pod *api.Pod) bool {
+	if k.options.StatusUpdateInterval == 0 {
+		return false
+	}
+	last, ok := k.statusReports.Last(podFullName(pod.Namespace, pod.Name))
+	return !ok || k.now().Sub(last) >= k.options.StatusUpdateInterval
+}
+
+// podStatusReports remembers when the API server last accepted the status of every pod, by podFullName. The
+// zero value is ready to use.
+type podStatusReports struct {
+	mutex sync.Mutex
+	times map[string]time.Time
+}
+
+// Record remembers that the status of the pod was reported at the given time
+func (r *podStatusReports) Record(fullName string, at time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.times == nil {
+		r.times = make(map[string]time.Time)
+	}
+	r.times[fullName] = at
+}
+
+// Last returns when the status of the pod was last reported
+func (r *podStatusReports) Last(fullName string) (time.Time, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	at, ok := r.times[fullName]
+	return at, ok
+}
+
+// Forget drops the report time of a pod the kubelet no longer runs
+func (r *podStatusReports) Forget(fullName string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.times, fullName)
+}
+
+// resolvePodStatusConflict fetches the pod after the API server rejected its status update. A pod that was
+// recreated or bound to another node is forgotten; otherwise its metadata is refreshed and the update is
+// retried once.
+func (k *Kubelet) resolvePodStatusConflict(pod *api.Pod) error {
+	current, err := k.apiClient.PodsIn(pod.Namespace).Get(context.Background(), pod.Name)
+	if err != nil {
+		return err
+	}
+
+	if current.UID != pod.UID || current.NodeName != k.nodeName {
+		k.log().Info("Pod was recreated or moved off this node, forgetting it", "pod", pod.Name)
+		k.removePod(pod.Namespace, pod.Name)
+		return nil
+	}
+
+	updatedPod, ok := k.pods.Mutate(pod, func(p *api.Pod) { p.ObjectMeta = current.ObjectMeta })
+	if !ok {
+		return nil
+	}
+	return k.putPodStatus(updatedPod)
+}
+
+// putPodStatus sends the status of a pod to its status subresource. Only the status and the preconditions
+// identifying the pod are sent, so a stale spec or node assignment is never written back.
+func (k *Kubelet) putPodStatus(pod *api.Pod) error {
+	// The API server logs the update with the trace ID of the pod
+	ctx := podContext(context.Background(), pod)
+	_, err := k.apiClient.Pods().UpdateStatus(ctx, &api.PodStatusUpdate{
+		Name:              pod.Name,
+		Namespace:         pod.Namespace,
+		UID:               pod.UID,
+		NodeName:          k.nodeName,
+		Status:            pod.Status,
+		ContainerStatuses: pod.ContainerStatuses,
+	})
+	if err == nil {
+		k.log().DebugContext(ctx, "Reported pod status", "pod", pod.Name, "status", pod.Status)
+	}
+	return err
+}
+
+// finalizePod removes the finalizer of the kubelet from a deleted pod whose containers it stopped, through the
+// status subresource of the pod like every status the kubelet reports
+func (k *Kubelet) finalizePod(pod *api.Pod) error {
+	ctx := podContext(context.Background(), pod)
+	_, err := k.apiClient.Pods().UpdateStatus(ctx, &api.PodStatusUpdate{
+		Name:              pod.Name,
+		Namespace:         pod.Namespace,
+		UID:               pod.UID,
+		NodeName:          k.nodeName,
+		Status:            api.PodTerminating,
+		ContainerStatuses: pod.ContainerStatuses,
+		RemoveFinalizers:  []string{api.PodKubeletFinalizer},
+	})
+	if err == nil {
+		k.log().InfoContext(ctx, "Stopped the containers of deleted pod", "pod", pod.Name)
+	}
+	return err
+}
+
+// determinePodStatus derives the pod phase from its container statuses:
+//   - Scheduled while any container has not been created yet, e.g. because its image is being pulled
+//   - Running while any container runs or is waiting to be restarted after a crash
+//   - Succeeded once every container exited with code 0, Failed once every container exited and one of them failed
+func determinePodStatus(statuses []api.ContainerStatus) api.PodStatus {
+	if anyContainerNotCreated(statuses) {
+		return api.PodScheduled
+	}
+
+	if anyContainerRunning(statuses) {
+		return api.PodRunning
+	}
+
+	if anyContainerFailed(statuses) {
+		return api.PodFailed
+	}
+
+	return api.PodSucceeded
+}
+
+func anyContainerRunning(statuses []api.ContainerStatus) bool {
+	for _, status := range statuses {
+		if status.State == api.ContainerRunning || (status.State == api.ContainerWaiting && status.Reason == CrashLoopBackOff) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyContainerNotCreated(statuses []api.ContainerStatus) bool {
+	for _, status := range statuses {
+		if status.State == api.ContainerWaiting && status.Reason != CrashLoopBackOff {
+			return true
+		}
+	}
+	return false
+}
+
+func anyContainerFailed(statuses []api.ContainerStatus) bool {
+	for _, status := range statuses {
+		if status.State == api.ContainerTerminated && status.ExitCode != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,167 +1,352 @@
-// File: pkg/kubelet/pod_status_test.go
-
 package kubelet
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 
 	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/kubelet/container/fakeruntime"
 )
 
-func TestGetPodStatus(t *testing.T) {
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	require.NoError(t, err)
-	defer dockerClient.Close()
-
-	kubelet := &Kubelet{
-		dockerClient: dockerClient,
+func TestSyncPodStatus(t *testing.T) {
+	running := func(name string) kubecontainer.Container {
+		return kubecontainer.Container{ID: name + "-id", PodName: "test-pod", ContainerName: name, NodeName: "node-1", Running: true}
+	}
+	exited := func(name string, exitCode int) kubecontainer.Container {
+		return kubecontainer.Container{ID: name + "-id", PodName: "test-pod", ContainerName: name, NodeName: "node-1", ExitCode: exitCode}
 	}
 
 	tests := []struct {
-		name            string
-		containerNames  []string
-		setupContainers func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string
-		expectedStatus  api.PodStatus
+		name           string
+		containerNames []string
+		containers     []kubecontainer.Container
+		expectedStatus api.PodStatus
 	}{
 		{
 			name:           "All containers running",
 			containerNames: []string{"c1", "c2", "c3"},
-			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
-				return createContainers(t, ctx, dockerClient, containerNames, func(config *container.Config) {
-					config.Cmd = []string{"sleep", "infinity"}
-				})
-			},
+			containers:     []kubecontainer.Container{running("c1"), running("c2"), running("c3")},
 			expectedStatus: api.PodRunning,
 		},
 		{
 			name:           "One container running, others completed successfully",
 			containerNames: []string{"c1", "c2", "c3"},
-			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
-				existedIds := createContainers(t, ctx, dockerClient, []string{containerNames[0], containerNames[1]}, func(config *container.Config) {
-					config.Cmd = []string{"echo", "success"}
-				})
-				// Keep one container running
-				runningContainerIds := createContainers(t, ctx, dockerClient, []string{containerNames[2]}, func(config *container.Config) {
-					config.Cmd = []string{"sleep", "infinity"}
-				})
-				require.NoError(t, err)
-				return append(existedIds, runningContainerIds...)
-			},
+			containers:     []kubecontainer.Container{exited("c1", 0), exited("c2", 0), running("c3")},
 			expectedStatus: api.PodRunning,
 		},
 		{
 			name:           "All containers completed successfully",
 			containerNames: []string{"c1", "c2", "c3"},
-			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
-				return createContainers(t, ctx, dockerClient, containerNames, func(config *container.Config) {
-					config.Cmd = []string{"echo", "success"}
-				})
-			},
+			containers:     []kubecontainer.Container{exited("c1", 0), exited("c2", 0), exited("c3", 0)},
 			expectedStatus: api.PodSucceeded,
 		},
 		{
 			name:           "All containers failed",
 			containerNames: []string{"c1", "c2", "c3"},
-			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
-				return createContainers(t, ctx, dockerClient, containerNames, func(config *container.Config) {
-					config.Cmd = []string{"sh", "-c", "exit 1"}
-				})
-			},
+			containers:     []kubecontainer.Container{exited("c1", 1), exited("c2", 1), exited("c3", 1)},
 			expectedStatus: api.PodFailed,
 		},
 		{
 			name:           "Mixed container states",
 			containerNames: []string{"c1", "c2", "c3"},
-			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
-				ids := make([]string, 3)
-
-				// Running container
-				runningIDs := createContainers(t, ctx, dockerClient, []string{containerNames[0]}, func(config *container.Config) {
-					config.Cmd = []string{"sleep", "infinity"}
-				})
-				ids[0] = runningIDs[0]
-
-				// Completed container
-				completedIDs := createContainers(t, ctx, dockerClient, []string{containerNames[1]}, func(config *container.Config) {
-					config.Cmd = []string{"echo", "success"}
-				})
-				ids[1] = completedIDs[0]
-
-				// Failed container
-				failedIDs := createContainers(t, ctx, dockerClient, []string{containerNames[2]}, func(config *container.Config) {
-					config.Cmd = []string{"sh", "-c", "exit 1"}
-				})
-				ids[2] = failedIDs[0]
-
-				return ids
-			},
+			containers:     []kubecontainer.Container{running("c1"), exited("c2", 0), exited("c3", 1)},
 			expectedStatus: api.PodRunning,
 		},
 		{
 			name:           "No containers created",
 			containerNames: []string{},
-			setupContainers: func(t *testing.T, ctx context.Context, containerNames []string, dockerClient *client.Client) []string {
-				return []string{}
-			},
 			expectedStatus: api.PodSucceeded,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ctx := context.Background()
-
-			containerIDs := tt.setupContainers(t, ctx, tt.containerNames, dockerClient)
-			defer removeContainers(t, ctx, dockerClient, containerIDs)
+			kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			runtime := fakeruntime.New()
+			for _, c := range tt.containers {
+				runtime.Add(c)
+			}
+			kubelet.runtime = runtime
 
 			pod := &api.Pod{
 				ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+				NodeName:   "node-1",
+				Status:     api.PodRunning,
 				Spec: api.PodSpec{
-					Containers: make([]api.Container, len(tt.containerNames)),
+					Containers:    make([]api.Container, len(tt.containerNames)),
+					RestartPolicy: api.RestartPolicyNever,
 				},
 			}
 			for i := range tt.containerNames {
-				pod.Spec.Containers[i] = api.Container{Name: tt.containerNames[i]}
+				pod.Spec.Containers[i] = api.Container{Name: tt.containerNames[i], Image: "alpine"}
 			}
+			kubelet.pods.Add(pod)
+
+			kubelet.syncPods(context.Background())
 
-			status, err := kubelet.getPodStatus(ctx, pod)
-			require.NoError(t, err)
-			assert.Equal(t, tt.expectedStatus, status)
+			current, ok := kubelet.pods.Get(pod.Namespace, pod.Name)
+			require.True(t, ok)
+			assert.Equal(t, tt.expectedStatus, current.Status)
+			require.Len(t, current.ContainerStatuses, len(tt.containerNames))
+			for i, name := range tt.containerNames {
+				assert.Equal(t, name, current.ContainerStatuses[i].Name)
+				assert.Equal(t, name+"-id", current.ContainerStatuses[i].ContainerID)
+			}
+			assert.Empty(t, runtime.Created(), "no container should be started")
 		})
 	}
 }
 
-func createContainers(t *testing.T, ctx context.Context, dockerClient *client.Client, containerNames []string, configModifier func(*container.Config)) []string {
-	ids := make([]string, len(containerNames))
-	for i, name := range containerNames {
-		config := &container.Config{
-			Image: "alpine:latest",
+// fakePodStatusAPI serves the status subresource and GET of a single pod
+type fakePodStatusAPI struct {
+	mutex sync.Mutex
+	// statusCodes are returned by consecutive status updates; once used up, updates succeed
+	statusCodes []int
+	current     *api.Pod
+	requests    []string
+	bodies      []map[string]any
+	// accepted are the bodies of the status updates that succeeded
+	accepted []map[string]any
+}
+
+func (f *fakePodStatusAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1/pods/web/status":
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.bodies = append(f.bodies, body)
+		if len(f.statusCodes) > 0 {
+			code := f.statusCodes[0]
+			f.statusCodes = f.statusCodes[1:]
+			w.WriteHeader(code)
+			return
 		}
-		configModifier(config)
+		f.accepted = append(f.accepted, body)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/pods/web" && f.current != nil:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(f.current)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
 
-		resp, err := dockerClient.ContainerCreate(ctx, config, nil, nil, nil, name)
-		require.NoError(t, err)
-		ids[i] = resp.ID
+func (f *fakePodStatusAPI) snapshot() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.requests...)
+}
 
-		err = dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{})
-		require.NoError(t, err)
+func TestUpdatePodStatus(t *testing.T) {
+	newPod := func() *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web", UID: "uid-1"},
+			NodeName:   "node-1",
+			Status:     api.PodRunning,
+			Spec:       nginxSpec,
+			ContainerStatuses: []api.ContainerStatus{
+				{Name: "nginx", ContainerID: "container-1", State: api.ContainerRunning},
+			},
+		}
 	}
 
-	// Give containers time to run
-	time.Sleep(2 * time.Second)
-	return ids
+	t.Run("should send only the status to the status subresource", func(t *testing.T) {
+		fakeAPI := &fakePodStatusAPI{}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.pods.Add(newPod())
+
+		kubelet.reportPodStatus(newPod())
+
+		assert.Equal(t, []string{"PUT /api/v1/pods/web/status"}, fakeAPI.snapshot())
+		require.Len(t, fakeAPI.bodies, 1)
+		body := fakeAPI.bodies[0]
+		assert.NotContains(t, body, "spec")
+		assert.NotContains(t, body, "metadata")
+		assert.Equal(t, "web", body["name"])
+		assert.Equal(t, "uid-1", body["uid"])
+		assert.Equal(t, string(api.PodRunning), body["status"])
+		assert.Len(t, body["containerStatuses"], 1)
+		assert.False(t, kubelet.hasPendingStatusUpdate(newPod()))
+	})
+
+	t.Run("should forget a pod the API server no longer has", func(t *testing.T) {
+		fakeAPI := &fakePodStatusAPI{statusCodes: []int{http.StatusNotFound}}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.pods.Add(newPod())
+
+		kubelet.reportPodStatus(newPod())
+
+		assert.Equal(t, 0, kubelet.pods.Len())
+		assert.False(t, kubelet.hasPendingStatusUpdate(newPod()))
+	})
+
+	t.Run("should refetch the pod on a conflict and retry", func(t *testing.T) {
+		current := newPod()
+		current.Labels = map[string]string{"app": "web"}
+		fakeAPI := &fakePodStatusAPI{statusCodes: []int{http.StatusConflict}, current: current}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.pods.Add(newPod())
+
+		kubelet.reportPodStatus(newPod())
+
+		assert.Equal(t, []string{"PUT /api/v1/pods/web/status", "GET /api/v1/pods/web", "PUT /api/v1/pods/web/status"}, fakeAPI.snapshot())
+		tracked, ok := kubelet.pods.Get("", "web")
+		require.True(t, ok)
+		assert.Equal(t, "web", tracked.Labels["app"])
+		assert.False(t, kubelet.hasPendingStatusUpdate(newPod()))
+	})
+
+	t.Run("should forget a pod that was bound to another node", func(t *testing.T) {
+		current := newPod()
+		current.NodeName = "node-2"
+		fakeAPI := &fakePodStatusAPI{statusCodes: []int{http.StatusConflict}, current: current}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.pods.Add(newPod())
+
+		kubelet.reportPodStatus(newPod())
+
+		assert.Equal(t, []string{"PUT /api/v1/pods/web/status", "GET /api/v1/pods/web"}, fakeAPI.snapshot())
+		assert.Equal(t, 0, kubelet.pods.Len())
+	})
+
+	t.Run("should report only the latest status once the API server accepts updates", func(t *testing.T) {
+		fakeAPI := &fakePodStatusAPI{statusCodes: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.statusBackoff = newBackoff(10*time.Millisecond, 10*time.Millisecond)
+		kubelet.statusQueue = newPodStatusQueue(rate.Inf, 1)
+		pod := newPod()
+		pod.Status = api.PodScheduled
+		kubelet.pods.Add(pod)
+
+		kubelet.updatePodStatus(pod)
+		require.Eventually(t, func() bool { return len(fakeAPI.snapshot()) >= 1 }, time.Second, time.Millisecond)
+
+		// The pod starts running while the API server is still failing
+		kubelet.updatePodStatus(newPod())
+
+		require.Eventually(t, func() bool {
+			fakeAPI.mutex.Lock()
+			defer fakeAPI.mutex.Unlock()
+			return len(fakeAPI.accepted) > 0
+		}, 5*time.Second, time.Millisecond)
+		assert.Never(t, func() bool { return len(fakeAPI.snapshot()) > 4 }, 50*time.Millisecond, time.Millisecond,
+			"no update should be sent once one landed")
+		fakeAPI.mutex.Lock()
+		defer fakeAPI.mutex.Unlock()
+		require.Len(t, fakeAPI.accepted, 1)
+		assert.Equal(t, string(api.PodRunning), fakeAPI.accepted[0]["status"])
+		assert.Equal(t, uint64(3), kubelet.statusQueue.Retries())
+		assert.Zero(t, kubelet.statusQueue.Len())
+		assert.False(t, kubelet.hasPendingStatusUpdate(pod))
+	})
+
+	t.Run("should send the queued statuses within the rate limit", func(t *testing.T) {
+		fakeAPI := &fakePodStatusAPI{}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.statusQueue = newPodStatusQueue(rate.Every(time.Hour), 1)
+		other := newPod()
+		other.Name = "db"
+		kubelet.pods.Add(newPod())
+		kubelet.pods.Add(other)
+
+		kubelet.updatePodStatus(newPod())
+		kubelet.updatePodStatus(other)
+
+		require.Eventually(t, func() bool { return len(fakeAPI.snapshot()) == 1 }, time.Second, time.Millisecond)
+		assert.Never(t, func() bool { return len(fakeAPI.snapshot()) > 1 }, 50*time.Millisecond, time.Millisecond)
+		assert.Equal(t, 1, kubelet.statusQueue.Len(), "the status of db should wait for the next token")
+	})
+
+	t.Run("should report an unchanged status every status update interval", func(t *testing.T) {
+		fakeAPI := &fakePodStatusAPI{}
+		kubelet := newTestKubelet(t, fakeAPI)
+		kubelet.options.StatusUpdateInterval = time.Minute
+		// The clock is read by the status worker too
+		var clock sync.Mutex
+		now := time.Now()
+		kubelet.now = func() time.Time {
+			clock.Lock()
+			defer clock.Unlock()
+			return now
+		}
+		advance := func(d time.Duration) {
+			clock.Lock()
+			defer clock.Unlock()
+			now = now.Add(d)
+		}
+		runtime := fakeruntime.New()
+		runtime.Add(kubecontainer.Container{ID: "container-1", PodName: "web", PodUID: "uid-1", ContainerName: "nginx", NodeName: "node-1", Running: true})
+		kubelet.runtime = runtime
+		kubelet.pods.Add(newPod())
+
+		reported := func(n int) func() bool {
+			return func() bool { return len(fakeAPI.snapshot()) == n }
+		}
+
+		// Never reported before
+		kubelet.syncPods(context.Background())
+		require.Eventually(t, func() bool {
+			_, ok := kubelet.statusReports.Last(podFullName("", "web"))
+			return ok
+		}, time.Second, time.Millisecond)
+		require.Len(t, fakeAPI.snapshot(), 1)
+
+		advance(30 * time.Second)
+		kubelet.syncPods(context.Background())
+		assert.Never(t, reported(2), 50*time.Millisecond, time.Millisecond)
+
+		advance(30 * time.Second)
+		kubelet.syncPods(context.Background())
+		assert.Eventually(t, reported(2), time.Second, time.Millisecond)
+	})
 }
 
-func removeContainers(t *testing.T, ctx context.Context, dockerClient *client.Client, containerIDs []string) {
-	for _, id := range containerIDs {
-		err := dockerClient.ContainerRemove(ctx, id, container.RemoveOptions{Force: true})
-		assert.NoError(t, err)
+func TestDeterminePodStatus(t *testing.T) {
+	running := api.ContainerStatus{State: api.ContainerRunning}
+	succeeded := api.ContainerStatus{State: api.ContainerTerminated}
+	failed := api.ContainerStatus{State: api.ContainerTerminated, ExitCode: 1}
+
+	tests := []struct {
+		name           string
+		statuses       []api.ContainerStatus
+		expectedStatus api.PodStatus
+	}{
+		{name: "All containers running", statuses: []api.ContainerStatus{running, running, running}, expectedStatus: api.PodRunning},
+		{name: "One container running, others completed successfully", statuses: []api.ContainerStatus{succeeded, succeeded, running}, expectedStatus: api.PodRunning},
+		{name: "All containers completed successfully", statuses: []api.ContainerStatus{succeeded, succeeded, succeeded}, expectedStatus: api.PodSucceeded},
+		{name: "All containers failed", statuses: []api.ContainerStatus{failed, failed, failed}, expectedStatus: api.PodFailed},
+		{name: "Mixed container states", statuses: []api.ContainerStatus{running, succeeded, failed}, expectedStatus: api.PodRunning},
+		{name: "No containers created", statuses: nil, expectedStatus: api.PodSucceeded},
+		{name: "Completed with one failure", statuses: []api.ContainerStatus{succeeded, failed}, expectedStatus: api.PodFailed},
+		{
+			name:           "Waiting for an image",
+			statuses:       []api.ContainerStatus{running, {State: api.ContainerWaiting, Reason: ImagePullBackOff}},
+			expectedStatus: api.PodScheduled,
+		},
+		{
+			name:           "Crash looping",
+			statuses:       []api.ContainerStatus{{State: api.ContainerWaiting, Reason: CrashLoopBackOff, ExitCode: 1, RestartCount: 3}},
+			expectedStatus: api.PodRunning,
+		},
+		{name: "Container not created yet", statuses: []api.ContainerStatus{{State: api.ContainerWaiting}}, expectedStatus: api.PodScheduled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStatus, determinePodStatus(tt.statuses))
+		})
 	}
 }
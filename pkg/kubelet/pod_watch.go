@@ -0,0 +1,196 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/logging"
+)
+
+const (
+	// watchRetryDelay is how long the kubelet waits before relisting after an established watch dropped
+	watchRetryDelay = 1 * time.Second
+)
+
+// watchPods keeps the pods of this node in sync with the API server. Every round starts with a full
+// list to catch up on changes missed while no watch was open, then follows the watch until it drops.
+// When the API server cannot watch, the kubelet falls back to relisting every RelistPeriod.
+func (k *Kubelet) watchPods() {
+	for {
+		if err := k.relistPods(); err != nil {
+			k.apiServerLog.Error(err, "Error getting pod assignments")
+			// No watch can be opened either until the API server is back
+			if isConnectionError(err) {
+				if !k.wait(watchRetryDelay) {
+					return
+				}
+				continue
+			}
+		} else {
+			k.apiServerLog.Reachable()
+		}
+
+		ctx, cancel := k.stopContext()
+		err := k.watchPodAssignments(ctx)
+		stopped := ctx.Err() != nil
+		cancel()
+		delay := watchRetryDelay
+		switch {
+		case errors.Is(err, client.ErrWatchNotSupported):
+			delay = k.options.RelistPeriod
+		case stopped:
+		default:
+			k.apiServerLog.Error(err, "Pod watch ended, relisting")
+		}
+		if !k.wait(delay) {
+			return
+		}
+	}
+}
+
+// relistPods records the pods assigned to this node and forgets the ones that are no longer listed,
+// catching up on deletions missed while no watch was open
+func (k *Kubelet) relistPods() error {
+	pods, err := k.getPodAssignments()
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]struct{}, len(pods))
+	for _, pod := range pods {
+		if pod.NodeName != k.nodeName {
+			continue
+		}
+		listed[podFullName(pod.Namespace, pod.Name)] = struct{}{}
+		// A deletion missed while no watch was open
+		if pod.IsDeleting() {
+			if _, ok := k.pods.Mutate(pod, func(p *api.Pod) { p.ObjectMeta = pod.ObjectMeta }); ok {
+				k.requestSync()
+			}
+		}
+		// The manifest of a static pod was removed while its mirror could not be deleted
+		if pod.IsMirrorPod() && !k.staticPods.Has(pod.Namespace, pod.Name) {
+			if err := k.deleteMirrorPod(pod); err != nil {
+				k.log().Error("Error deleting stale mirror pod", "pod", pod.Name, logging.Err(err))
+			}
+		}
+	}
+	for _, pod := range k.pods.List() {
+		if _, ok := listed[podFullName(pod.Namespace, pod.Name)]; !ok && !k.staticPods.Has(pod.Namespace, pod.Name) {
+			k.removePod(pod.Namespace, pod.Name)
+		}
+	}
+
+	return k.runNewPods(pods)
+}
+
+// watchPodAssignments opens a watch on the pods assigned to this node and handles events until the
+// stream ends. It returns an error wrapping client.ErrWatchNotSupported if the API server cannot watch.
+func (k *Kubelet) watchPodAssignments(ctx context.Context) error {
+	watch, err := k.apiClient.Pods().Watch(ctx, client.PodListOptions{NodeName: k.nodeName})
+	if err != nil {
+		return err
+	}
+	defer watch.Close()
+
+	for {
+		event, err := watch.Next()
+		if err != nil {
+			return err
+		}
+		k.handlePodEvent(event)
+	}
+}
+
+// handlePodEvent records pods newly scheduled to this node, refreshes the metadata of pods it runs,
+// and forgets pods that were deleted or moved off this node. Starting and stopping containers is left
+// to the sync loop, which is asked for a pass when a pod is being deleted. A deleted mirror pod is published
+// again.
+func (k *Kubelet) handlePodEvent(event api.PodWatchEvent) {
+	pod := event.Object
+
+	// Static pods come from their manifests; the API server only has their mirror
+	if k.staticPods.Has(pod.Namespace, pod.Name) {
+		if event.Type == api.EventDeleted {
+			k.staticPods.MarkUnmirrored(pod.Namespace, pod.Name)
+		}
+		return
+	}
+
+	switch event.Type {
+	case api.EventAdded, api.EventModified:
+		k.forgetRecreatedPod(pod)
+		if _, tracked := k.pods.Get(pod.Namespace, pod.Name); !tracked {
+			if err := k.runNewPods([]*api.Pod{pod}); err != nil {
+				k.log().Error("Error running new pod", "pod", pod.Name, logging.Err(err))
+			}
+			return
+		}
+		if pod.NodeName != k.nodeName {
+			k.removePod(pod.Namespace, pod.Name)
+			return
+		}
+		k.pods.Mutate(pod, func(p *api.Pod) { p.ObjectMeta = pod.ObjectMeta })
+		if waitsForKubelet(pod) {
+			k.requestSync()
+		}
+	case api.EventDeleted:
+		// The deletion of an older pod with the same name leaves the tracked pod alone
+		if k.pods.Has(pod) {
+			k.removePod(pod.Namespace, pod.Name)
+		}
+	}
+}
+
+// removePod stops tracking the pod with the given namespace and name; the sync loop stops its containers
+func (k *Kubelet) removePod(namespace, name string) {
+	if _, tracked := k.pods.Get(namespace, name); !tracked {
+		return
+	}
+	fullName := podFullName(namespace, name)
+	k.pods.Delete(namespace, name)
+	k.statusBackoff.Reset(fullName)
+	k.statusReports.Forget(fullName)
+	k.statusUpdates().Forget(fullName)
+	k.log().Info("Pod removed from node", "pod", name)
+	k.requestSync()
+}
+
+// killPod stops the containers the kubelet started for the pod and removes them. The containers are stopped
+// concurrently, each getting the termination grace period of the pod to exit before it is killed.
+func (k *Kubelet) killPod(pod *api.Pod) {
+	gracePeriod := pod.Spec.TerminationGracePeriod()
+	var wg sync.WaitGroup
+	for _, status := range pod.ContainerStatuses {
+		if status.ContainerID == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := k.stopContainer(context.Background(), status.ContainerID, gracePeriod); err != nil {
+				k.log().Error("Error stopping container", "pod", pod.Name, "container", status.Name, logging.Err(err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// stopContainer asks the container to exit, kills it once gracePeriod has passed and removes it. A container
+// that is already gone counts as stopped.
+func (k *Kubelet) stopContainer(ctx context.Context, containerID string, gracePeriod time.Duration) error {
+	if err := k.runtime.StopContainer(ctx, containerID, gracePeriod); err != nil && !errors.Is(err, kubecontainer.ErrContainerNotFound) {
+		// Removing kills the container, so it does not outlive a failed stop
+		k.log().Warn("Error stopping container gracefully, killing it", "containerID", containerID, logging.Err(err))
+	}
+	if err := k.runtime.RemoveContainer(ctx, containerID); err != nil && !errors.Is(err, kubecontainer.ErrContainerNotFound) {
+		return fmt.Errorf("failed to remove container %s: %w", containerID, err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+// podRecorder collects the pods a test kubelet starts and stops
+type podRecorder struct {
+	mutex   sync.Mutex
+	started []string
+	stopped []string
+}
+
+func (r *podRecorder) start(pod *api.Pod) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.started = append(r.started, pod.Name)
+}
+
+func (r *podRecorder) stop(pod *api.Pod) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = append(r.stopped, pod.Name)
+}
+
+func (r *podRecorder) snapshot() ([]string, []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.started...), append([]string(nil), r.stopped...)
+}
+
+func TestWatchPodAssignments(t *testing.T) {
+	scheduledPod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "c1", Image: "nginx"}}},
+	}
+	otherNodePod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "elsewhere"},
+		NodeName:   "node-2",
+		Status:     api.PodScheduled,
+	}
+
+	var requestedNode string
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedNode = r.URL.Query().Get("nodeName")
+		require.Equal(t, "true", r.URL.Query().Get("watch"))
+
+		w.Header().Set("Content-Type", api.WatchContentType)
+		encoder := json.NewEncoder(w)
+		for _, event := range []api.PodWatchEvent{
+			{Type: api.EventAdded, Object: scheduledPod},
+			{Type: api.EventAdded, Object: otherNodePod},
+			{Type: api.EventModified, Object: scheduledPod},
+			{Type: api.EventDeleted, Object: scheduledPod},
+		} {
+			_ = encoder.Encode(event)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	recorder := &podRecorder{}
+	kubelet.startPod = recorder.start
+	kubelet.stopPod = recorder.stop
+
+	err := kubelet.watchPodAssignments(context.Background())
+	assert.Error(t, err, "the watch should report that the stream ended")
+	assert.NotErrorIs(t, err, client.ErrWatchNotSupported)
+	assert.Equal(t, "node-1", requestedNode)
+
+	// Pods are only recorded and forgotten; starting and stopping is left to the sync loop
+	started, stopped := recorder.snapshot()
+	assert.Empty(t, started)
+	assert.Empty(t, stopped)
+	assert.Equal(t, 0, kubelet.pods.Len())
+	assert.Len(t, kubelet.syncRequests, 1, "the events should ask the sync loop for a pass")
+}
+
+func TestSyncTearsDownPodsMovedOffTheNode(t *testing.T) {
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "c1", Image: "nginx"}}},
+	}
+	kubelet := newTestKubelet(t, http.NotFoundHandler())
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+	recorder := &podRecorder{}
+	kubelet.startPod = recorder.start
+	kubelet.stopPod = recorder.stop
+
+	kubelet.handlePodEvent(api.PodWatchEvent{Type: api.EventAdded, Object: pod})
+	assert.Equal(t, 1, kubelet.pods.Len())
+	kubelet.syncPods(context.Background())
+	assert.Eventually(t, func() bool {
+		started, _ := recorder.snapshot()
+		return len(started) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The pod's container is running when the pod is moved to another node
+	runtime.Add(kubecontainer.Container{ID: "web-c1", PodName: "web", ContainerName: "c1", NodeName: "node-1", Running: true})
+	moved := *pod
+	moved.NodeName = "node-2"
+	kubelet.handlePodEvent(api.PodWatchEvent{Type: api.EventModified, Object: &moved})
+	assert.Equal(t, 0, kubelet.pods.Len())
+
+	kubelet.syncPods(context.Background())
+	started, stopped := recorder.snapshot()
+	assert.Equal(t, []string{"web"}, started)
+	assert.Equal(t, []string{"web"}, stopped)
+}
+
+func TestSyncReplacesContainersOfRecreatedPod(t *testing.T) {
+	newPod := func(uid string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web", UID: uid},
+			NodeName:   "node-1",
+			Status:     api.PodScheduled,
+			Spec:       nginxSpec,
+		}
+	}
+	kubelet := newTestKubelet(t, http.NotFoundHandler())
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+	kubelet.startPod = kubelet.runPod
+	kubelet.stopPod = kubelet.killPod
+
+	require.NoError(t, kubelet.runNewPods([]*api.Pod{newPod("uid-1")}))
+	kubelet.syncPods(context.Background())
+	require.Eventually(t, func() bool { return len(runtime.Running("web")) == 1 }, time.Second, 10*time.Millisecond)
+	oldContainer := runtime.Running("web")[0]
+
+	// The pod is deleted and created again under the same name while the kubelet was not watching
+	require.NoError(t, kubelet.runNewPods([]*api.Pod{newPod("uid-2")}))
+	tracked, ok := kubelet.pods.Get("", "web")
+	require.True(t, ok)
+	assert.Equal(t, "uid-2", tracked.UID)
+	assert.Empty(t, tracked.ContainerStatuses, "the new pod must not adopt the status of the old one")
+
+	kubelet.syncPods(context.Background())
+	require.Eventually(t, func() bool {
+		running := runtime.Running("web")
+		return len(running) == 1 && running[0] != oldContainer
+	}, time.Second, 10*time.Millisecond)
+
+	// The old container was stopped and removed before the new one was created
+	calls := runtime.Calls()
+	removed := slices.Index(calls, "RemoveContainer "+oldContainer)
+	require.NotEqual(t, -1, removed)
+	assert.True(t, strings.HasPrefix(calls[len(calls)-2], "CreateContainer "), "the new container should be created last, got %v", calls)
+	assert.Less(t, removed, len(calls)-2, "stale containers must be removed first")
+	containers, err := runtime.ListContainers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "uid-2", containers[0].PodUID)
+
+	// A late deletion event of the old pod leaves the new one alone
+	kubelet.handlePodEvent(api.PodWatchEvent{Type: api.EventDeleted, Object: newPod("uid-1")})
+	assert.Equal(t, 1, kubelet.pods.Len())
+}
+
+func TestSyncRunsPodsOfTheSameNameInDifferentNamespaces(t *testing.T) {
+	newPod := func(namespace, uid string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web", Namespace: namespace, UID: uid},
+			NodeName:   "node-1",
+			Status:     api.PodScheduled,
+			Spec:       nginxSpec,
+		}
+	}
+	// The API server accepts every status update, so the pods are only forgotten when they are deleted
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+	kubelet.startPod = kubelet.runPod
+	kubelet.stopPod = kubelet.killPod
+
+	require.NoError(t, kubelet.runNewPods([]*api.Pod{newPod("team-a", "uid-a"), newPod("team-b", "uid-b")}))
+	assert.Equal(t, 2, kubelet.pods.Len())
+	kubelet.syncPods(context.Background())
+	require.Eventually(t, func() bool { return len(runtime.Running("web")) == 2 }, time.Second, 10*time.Millisecond)
+	running := runtime.Running("web")
+
+	// Neither pod is mistaken for a recreated instance of the other
+	for range 3 {
+		kubelet.syncPods(context.Background())
+	}
+	assert.Equal(t, running, runtime.Running("web"))
+	for namespace, uid := range map[string]string{"team-a": "uid-a", "team-b": "uid-b"} {
+		tracked, ok := kubelet.pods.Get(namespace, "web")
+		require.True(t, ok)
+		assert.Equal(t, uid, tracked.UID)
+		assert.Equal(t, api.PodRunning, tracked.Status)
+	}
+
+	// Deleting the pod of one namespace leaves the other running
+	kubelet.handlePodEvent(api.PodWatchEvent{Type: api.EventDeleted, Object: newPod("team-a", "uid-a")})
+	kubelet.syncPods(context.Background())
+	require.Eventually(t, func() bool { return len(runtime.Running("web")) == 1 }, time.Second, 10*time.Millisecond)
+	_, ok := kubelet.pods.Get("team-b", "web")
+	assert.True(t, ok)
+	containers, err := runtime.ListContainers(context.Background())
+	require.NoError(t, err)
+	for _, c := range containers {
+		if c.Running {
+			assert.Equal(t, "team-b", c.PodNamespace)
+		}
+	}
+}
+
+func TestWatchPodAssignmentsNotSupported(t *testing.T) {
+	// An API server without watch support answers with a plain pod list
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]*api.Pod{})
+	}))
+
+	err := kubelet.watchPodAssignments(context.Background())
+	assert.ErrorIs(t, err, client.ErrWatchNotSupported)
+}
+
+func TestKillPodStopsAndRemovesContainers(t *testing.T) {
+	runtime := fakeruntime.New()
+	runtime.Add(kubecontainer.Container{ID: "web-c1", PodName: "web", ContainerName: "c1", NodeName: "node-1", Running: true})
+	kubelet := newTestKubelet(t, http.NotFoundHandler())
+	kubelet.runtime = runtime
+
+	kubelet.killPod(&api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		ContainerStatuses: []api.ContainerStatus{
+			{Name: "c1", ContainerID: "web-c1", State: api.ContainerRunning},
+			// Already gone containers are skipped quietly
+			{Name: "c2", ContainerID: "web-c2", State: api.ContainerRunning},
+			{Name: "c3", State: api.ContainerWaiting},
+		},
+	})
+
+	// The containers are stopped concurrently, each before it is removed
+	calls := runtime.Calls()
+	assert.ElementsMatch(t, []string{
+		"StopContainer web-c1", "RemoveContainer web-c1",
+		"StopContainer web-c2", "RemoveContainer web-c2",
+	}, calls)
+	assert.Less(t, slices.Index(calls, "StopContainer web-c1"), slices.Index(calls, "RemoveContainer web-c1"))
+	assert.Empty(t, runtime.Running("web"))
+}
+
+func TestKillPodHonorsTerminationGracePeriod(t *testing.T) {
+	runPod := func(t *testing.T, gracePeriodSeconds *int64) (*fakeruntime.FakeRuntime, string) {
+		runtime := fakeruntime.New()
+		runtime.TrapSIGTERM("db")
+		kubelet := newTestKubelet(t, http.NotFoundHandler())
+		kubelet.runtime = runtime
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "db", UID: "uid-1"},
+			Spec: api.PodSpec{
+				Containers:                    []api.Container{{Name: "db", Image: "postgres"}},
+				TerminationGracePeriodSeconds: gracePeriodSeconds,
+			},
+		}
+		kubelet.pods.Add(pod)
+		kubelet.runPod(pod)
+		current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+		containerID := current.GetContainerStatus("db").ContainerID
+
+		kubelet.killPod(current)
+		return runtime, containerID
+	}
+
+	t.Run("should let a container trapping SIGTERM exit within the default grace period", func(t *testing.T) {
+		runtime, containerID := runPod(t, nil)
+
+		timeout, exitCode, stopped := runtime.StopResult(containerID)
+		require.True(t, stopped)
+		assert.Equal(t, 30*time.Second, timeout)
+		assert.Equal(t, 0, exitCode, "the container exits cleanly on SIGTERM")
+		assert.Equal(t, []string{"StopContainer " + containerID, "RemoveContainer " + containerID}, runtime.Calls()[len(runtime.Calls())-2:])
+	})
+
+	t.Run("should kill the container straight away without a grace period", func(t *testing.T) {
+		zero := int64(0)
+		runtime, containerID := runPod(t, &zero)
+
+		timeout, exitCode, stopped := runtime.StopResult(containerID)
+		require.True(t, stopped)
+		assert.Equal(t, time.Duration(0), timeout)
+		assert.Equal(t, 137, exitCode, "the container is killed")
+	})
+}
+
+func TestTeardownUsesTheGracePeriodOfTheContainers(t *testing.T) {
+	runtime := fakeruntime.New()
+	runtime.TrapSIGTERM("db")
+	kubelet := newTestKubelet(t, http.NotFoundHandler())
+	kubelet.runtime = runtime
+	kubelet.stopPod = kubelet.killPod
+	gracePeriod := int64(90)
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "db", UID: "uid-1"},
+		Spec: api.PodSpec{
+			Containers:                    []api.Container{{Name: "db", Image: "postgres"}},
+			TerminationGracePeriodSeconds: &gracePeriod,
+		},
+	}
+	kubelet.pods.Add(pod)
+	kubelet.runPod(pod)
+
+	// The spec of a removed pod is gone, so the grace period comes from the container
+	kubelet.removePod(pod.Namespace, pod.Name)
+	kubelet.syncPods(context.Background())
+
+	timeout, exitCode, stopped := runtime.StopResult("container-1")
+	require.True(t, stopped)
+	assert.Equal(t, 90*time.Second, timeout)
+	assert.Equal(t, 0, exitCode)
+	assert.Empty(t, runtime.Running(pod.Name))
+}
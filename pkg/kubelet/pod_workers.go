@@ -0,0 +1,112 @@
+package kubelet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+)
+
+// podStartQueue is an unbounded FIFO of pods waiting to be started. Enqueueing never blocks,
+// so a burst of assignments cannot stall the watch loop.
+type podStartQueue struct {
+	mutex sync.Mutex
+	items []queuedPod
+	// ready has a token whenever the queue may be non-empty
+	ready chan struct{}
+}
+
+type queuedPod struct {
+	pod      *api.Pod
+	enqueued time.Time
+}
+
+func newPodStartQueue() *podStartQueue {
+	return &podStartQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// Push adds the pod to the back of the queue
+func (q *podStartQueue) Push(item queuedPod) {
+	q.mutex.Lock()
+	q.items = append(q.items, item)
+	q.mutex.Unlock()
+	q.signal()
+}
+
+// Pop blocks until a pod is queued and removes it from the front of the queue
+func (q *podStartQueue) Pop() queuedPod {
+	for {
+		q.mutex.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items = q.items[1:]
+			remaining := len(q.items)
+			q.mutex.Unlock()
+
+			// Pass the wake-up on so another idle worker picks up the rest
+			if remaining > 0 {
+				q.signal()
+			}
+			return item
+		}
+		q.mutex.Unlock()
+		<-q.ready
+	}
+}
+
+// Len returns the number of queued pods
+func (q *podStartQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.items)
+}
+
+func (q *podStartQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// enqueuePodStart marks the pod as starting and hands it to the pod workers, starting them on first use
+func (k *Kubelet) enqueuePodStart(pod *api.Pod) {
+	k.startWorkersOnce.Do(func() {
+		if k.startQueue == nil {
+			k.startQueue = newPodStartQueue()
+		}
+		for i := 0; i < k.maxParallelPodStarts(); i++ {
+			go k.podStartWorker()
+		}
+	})
+
+	k.pods.MarkStarting(pod)
+	k.startQueue.Push(queuedPod{pod: pod, enqueued: time.Now()})
+}
+
+// podStartWorker starts queued pods one at a time, so at most MaxParallelPodStarts pods pull images and
+// create containers concurrently. The containers of a pod are still started in order by startPod.
+func (k *Kubelet) podStartWorker() {
+	for {
+		item := k.startQueue.Pop()
+		if current, ok := k.pods.Get(item.pod.Namespace, item.pod.Name); !ok || current.UID != item.pod.UID || current.IsDeleting() {
+			// Deleted or recreated while it was waiting in the queue
+			k.pods.MarkStarted(item.pod)
+			continue
+		}
+
+		k.log().InfoContext(podContext(context.Background(), item.pod), "Starting pod from the start queue", "pod", item.pod.Name, "waited", time.Since(item.enqueued), "queued", k.startQueue.Len())
+		k.startPod(item.pod)
+		k.pods.MarkStarted(item.pod)
+	}
+}
+
+func (k *Kubelet) maxParallelPodStarts() int {
+	if k.options.MaxParallelPodStarts < MinMaxParallelPodStarts {
+		return DefaultMaxParallelPodStarts
+	}
+	return k.options.MaxParallelPodStarts
+}
@@ -0,0 +1,108 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+)
+
+// recoverPods rebuilds the state of a restarted kubelet before the pod watch starts, retrying until the API
+// server answers. Until it is done the sync loop keeps the containers of pods it does not know about, as
+// they may belong to pods that are still assigned to this node.
+func (k *Kubelet) recoverPods(ctx context.Context) error {
+	defer k.recovering.Store(false)
+	for {
+		err := k.restorePods(ctx)
+		if err == nil {
+			k.requestSync()
+			return nil
+		}
+		k.apiServerLog.Error(err, "Error recovering pods from the containers of the previous run")
+		if !k.wait(watchRetryDelay) {
+			return fmt.Errorf("%w while recovering pods of node %s: %v", ErrStopped, k.nodeName, err)
+		}
+	}
+}
+
+// restorePods looks at the containers this node left behind. The containers of a pod that is still assigned
+// to the node with the same UID are adopted: the pod is tracked with their statuses, so the sync loop
+// watches them instead of starting duplicates. The running containers of pods that are gone, e.g. because
+// they were deleted while the kubelet was down, are stopped; their dead containers are left to the garbage
+// collector. Containers of static pods are left to the static pod source.
+func (k *Kubelet) restorePods(ctx context.Context) error {
+	containers, err := k.runtime.ListContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+	byPod := make(map[string][]kubecontainer.Container)
+	for _, c := range containers {
+		if c.PodName != "" && k.ownsContainer(c) {
+			key := podKey(c.PodNamespace, c.PodName, c.PodUID)
+			byPod[key] = append(byPod[key], c)
+		}
+	}
+	if len(byPod) == 0 {
+		return nil
+	}
+
+	pods, err := k.getPodAssignments()
+	if err != nil {
+		return err
+	}
+	k.apiServerLog.Reachable()
+	for _, pod := range pods {
+		key := podKey(pod.Namespace, pod.Name, pod.UID)
+		podContainers, ok := byPod[key]
+		if !ok || pod.NodeName != k.nodeName {
+			continue
+		}
+		switch {
+		case pod.IsMirrorPod():
+			// Mirror pods share the UID of their static pod, which runs from its manifest
+			delete(byPod, key)
+		case k.isAssignedToNode(pod):
+			delete(byPod, key)
+			if k.pods.Add(adoptedPod(pod, podContainers)) {
+				k.log().InfoContext(podContext(ctx, pod), "Adopted the containers of pod", "pod", pod.Name, "podUID", pod.UID,
+					"containers", len(podContainers))
+			}
+		}
+	}
+
+	for _, podContainers := range byPod {
+		c := podContainers[0]
+		if k.pods.Has(&api.Pod{ObjectMeta: api.ObjectMeta{Namespace: c.PodNamespace, Name: c.PodName, UID: c.PodUID}}) {
+			continue // Static pods read before the recovery
+		}
+		k.teardownPod(podContainers)
+	}
+	return nil
+}
+
+// adoptedPod returns a copy of pod whose container statuses describe the newest container of each of its
+// containers, keeping the restart counts the API server recorded. A container that is gone is reported
+// waiting, so the sync loop starts it again.
+func adoptedPod(pod *api.Pod, containers []kubecontainer.Container) *api.Pod {
+	adopted := *pod
+	adopted.ContainerStatuses = make([]api.ContainerStatus, 0, len(pod.Spec.Containers))
+	for _, spec := range pod.Spec.Containers {
+		status := api.ContainerStatus{Name: spec.Name, Image: spec.Image, State: api.ContainerWaiting}
+		if recorded := pod.GetContainerStatus(spec.Name); recorded != nil {
+			status.RestartCount = recorded.RestartCount
+		}
+		if c, found := findContainer("", spec.Name, containers); found {
+			status.ContainerID = c.ID
+			status.ImageID = c.ImageID
+			if c.Running {
+				status.State = api.ContainerRunning
+			} else {
+				status.State = api.ContainerTerminated
+				status.ExitCode = c.ExitCode
+			}
+		}
+		adopted.ContainerStatuses = append(adopted.ContainerStatuses, status)
+	}
+	return &adopted
+}
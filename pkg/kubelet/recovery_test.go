@@ -0,0 +1,172 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+// fakePodAPI serves the pods of a test and records their status updates. It cannot watch, so the kubelet
+// relists the pods instead.
+type fakePodAPI struct {
+	mutex sync.Mutex
+	pods  map[string]*api.Pod
+	// listDelay holds back the pod lists, as a slow API server
+	listDelay time.Duration
+}
+
+func (f *fakePodAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/pods":
+		f.mutex.Lock()
+		delay := f.listDelay
+		f.mutex.Unlock()
+		time.Sleep(delay)
+
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		pods := make([]*api.Pod, 0, len(f.pods))
+		for _, pod := range f.pods {
+			pods = append(pods, pod)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(pods)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/api/v1/pods/"):
+		update := &api.PodStatusUpdate{}
+		_ = json.NewDecoder(r.Body).Decode(update)
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if pod, ok := f.pods[update.Name]; ok {
+			updated := *pod
+			updated.Status, updated.ContainerStatuses = update.Status, update.ContainerStatuses
+			f.pods[update.Name] = &updated
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/nodes":
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (f *fakePodAPI) delete(name string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.pods, name)
+}
+
+// startTestKubelet starts a kubelet of node-1 on runtime, as a new kubelet process
+func startTestKubelet(t *testing.T, apiServerURL string, runtime *fakeruntime.FakeRuntime) *Kubelet {
+	t.Helper()
+	options := DefaultOptions()
+	options.SyncInterval = MinSyncInterval
+	options.RelistPeriod = MinRelistPeriod
+	options.Address = "127.0.0.1"
+	options.Port = freePort(t)
+	kubelet, err := NewKubeletWithRuntime("node-1", apiServerURL, options, runtime)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = kubelet.Stop(context.Background()) })
+	require.NoError(t, kubelet.Start())
+	return kubelet
+}
+
+func TestKubeletRestartAdoptsContainers(t *testing.T) {
+	fakeAPI := &fakePodAPI{pods: map[string]*api.Pod{}}
+	for _, name := range []string{"web", "gone"} {
+		fakeAPI.pods[name] = &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name, UID: "uid-" + name},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}}},
+			NodeName:   "node-1",
+			Status:     api.PodScheduled,
+		}
+	}
+	server := httptest.NewServer(fakeAPI)
+	t.Cleanup(server.Close)
+	runtime := fakeruntime.New()
+	runtime.AddImage("nginx")
+
+	first := startTestKubelet(t, server.URL, runtime)
+	require.Eventually(t, func() bool {
+		return len(runtime.Running("web")) == 1 && len(runtime.Running("gone")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	running := runtime.Running("web")
+	require.NoError(t, first.Stop(context.Background()))
+
+	// The pod is deleted while the kubelet is down, and the API server is slow to list the pods after the
+	// restart, so the sync loop runs a few times before the pods are known
+	fakeAPI.delete("gone")
+	fakeAPI.mutex.Lock()
+	fakeAPI.listDelay = 5 * MinSyncInterval
+	fakeAPI.mutex.Unlock()
+	startTestKubelet(t, server.URL, runtime)
+
+	assert.Empty(t, runtime.Running("gone"), "the container of the deleted pod should be stopped")
+	time.Sleep(3 * MinSyncInterval)
+	assert.Equal(t, running, runtime.Running("web"), "the running container should be adopted")
+	assert.Len(t, runtime.Created(), 2, "no container should be created after the restart")
+}
+
+func TestRestorePods(t *testing.T) {
+	restartCount := api.ContainerStatus{Name: "app", RestartCount: 3}
+	listed := []*api.Pod{
+		{
+			ObjectMeta:        api.ObjectMeta{Name: "web", UID: "uid-web"},
+			Spec:              api.PodSpec{Containers: []api.Container{{Name: "app", Image: "nginx"}, {Name: "sidecar", Image: "envoy"}}},
+			NodeName:          "node-1",
+			Status:            api.PodRunning,
+			ContainerStatuses: []api.ContainerStatus{restartCount},
+		},
+		// Finished while the kubelet was down; its containers are not adopted
+		{ObjectMeta: api.ObjectMeta{Name: "done", UID: "uid-done"}, NodeName: "node-1", Status: api.PodSucceeded},
+		// The copy of a static pod, whose containers are left to the static pod source
+		{
+			ObjectMeta: api.ObjectMeta{Name: "static-node-1", UID: "uid-static", Labels: map[string]string{api.MirrorPodLabel: "node-1"}},
+			NodeName:   "node-1",
+			Status:     api.PodRunning,
+		},
+	}
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(listed)
+	}))
+	stopped := &podRecorder{}
+	kubelet.stopPod = stopped.stop
+
+	runtime := fakeruntime.New()
+	for _, c := range []kubecontainer.Container{
+		// The newest container of a pod is the one adopted
+		gokubeContainer("web-old", "web", "uid-web", false, 1),
+		gokubeContainer("web-new", "web", "uid-web", true, 2),
+		gokubeContainer("web-previous-pod", "web", "uid-web-old", true, 1),
+		gokubeContainer("deleted", "deleted", "uid-deleted", true, 1),
+		gokubeContainer("done", "done", "uid-done", true, 1),
+		gokubeContainer("static", "static-node-1", "uid-static", true, 1),
+		{ID: "other-node", PodName: "deleted", PodUID: "uid-deleted", NodeName: "node-2", Running: true},
+	} {
+		runtime.Add(c)
+	}
+	kubelet.runtime = runtime
+
+	require.NoError(t, kubelet.restorePods(context.Background()))
+
+	pod, ok := kubelet.pods.Get("", "web")
+	require.True(t, ok)
+	assert.Equal(t, []api.ContainerStatus{
+		{Name: "app", Image: "nginx", ContainerID: "web-new", State: api.ContainerRunning, RestartCount: 3},
+		{Name: "sidecar", Image: "envoy", State: api.ContainerWaiting},
+	}, pod.ContainerStatuses)
+	assert.Equal(t, 1, kubelet.pods.Len())
+	_, torndown := stopped.snapshot()
+	sort.Strings(torndown)
+	assert.Equal(t, []string{"deleted", "done", "web"}, torndown)
+}
@@ -0,0 +1,158 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gokube/pkg/api"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/logging"
+)
+
+const (
+	// CrashLoopBackOff is the reason reported for a container whose restart is being delayed
+	CrashLoopBackOff = "CrashLoopBackOff"
+	// StartError is the reason reported for a container that could not be created or started
+	StartError = "StartError"
+
+	// startErrorExitCode is reported for a container that never ran, like a container runtime does when
+	// the command of a container cannot be run
+	startErrorExitCode = 128
+)
+
+// restartExitedContainers applies the pod's restart policy to the observed statuses of its containers and
+// returns the updated statuses. Exited containers are replaced when the policy allows it, and containers
+// that do not exist, e.g. because they were removed behind the kubelet's back, are created again.
+// Repeated restarts of the same container are delayed by an exponential backoff, during which the
+// container is reported as waiting in CrashLoopBackOff.
+func (k *Kubelet) restartExitedContainers(ctx context.Context, pod *api.Pod, statuses []api.ContainerStatus) []api.ContainerStatus {
+	for i, spec := range pod.Spec.Containers {
+		current := statuses[i]
+		missing := current.State == api.ContainerWaiting && current.Reason == ""
+		exited := current.State == api.ContainerTerminated ||
+			(current.State == api.ContainerWaiting && current.Reason == CrashLoopBackOff)
+		if !missing && !(exited && pod.Spec.ShouldRestart(current.ExitCode)) {
+			continue
+		}
+
+		next := current
+		key := restartKey(pod, spec.Name)
+		if k.restartBackoff.InBackoff(key) {
+			next.State = api.ContainerWaiting
+			next.Reason = CrashLoopBackOff
+			statuses[i] = next
+			continue
+		}
+
+		containerID, err := k.restartContainer(ctx, pod, spec, current.ContainerID)
+		k.restartBackoff.Next(key)
+		if isImagePullError(err) {
+			// Retried by the image manager's own backoff from now on
+			next.State = api.ContainerWaiting
+			next.Reason = imagePullReason(err)
+			next.Message = err.Error()
+			statuses[i] = next
+			continue
+		}
+		if err != nil {
+			k.log().Error("Failed to restart container", "pod", pod.Name, "container", spec.Name, logging.Err(err))
+			next.State = api.ContainerWaiting
+			next.Reason = CrashLoopBackOff
+			statuses[i] = next
+			continue
+		}
+		k.log().Info("Restarted container", "pod", pod.Name, "container", spec.Name, "nextRestartDelay", k.restartBackoff.Delay(key))
+		next.ContainerID = containerID
+		next.State = api.ContainerRunning
+		next.Reason = ""
+		next.ExitCode = 0
+		if exited {
+			next.RestartCount++
+		}
+		statuses[i] = next
+	}
+	return statuses
+}
+
+// restartContainer removes the exited container, if it still exists, and starts a replacement from the same spec
+func (k *Kubelet) restartContainer(ctx context.Context, pod *api.Pod, spec api.Container, containerID string) (string, error) {
+	if containerID != "" {
+		if err := k.runtime.RemoveContainer(ctx, containerID); err != nil && !errors.Is(err, kubecontainer.ErrContainerNotFound) {
+			return "", fmt.Errorf("failed to remove exited container %s: %w", containerID, err)
+		}
+	}
+	return k.StartContainer(ctx, pod, spec.Name, spec.Image)
+}
+
+func restartKey(pod *api.Pod, containerName string) string {
+	return pod.Name + "/" + containerName
+}
+
+// rollbackPodStart stops and removes the containers already started for pod after its container
+// failedContainer could not be started, and reports the pod as Failed with the error of that container
+func (k *Kubelet) rollbackPodStart(pod *api.Pod, started []api.ContainerStatus, failedContainer string, err error) {
+	k.killPod(&api.Pod{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec, ContainerStatuses: started})
+
+	statuses := make([]api.ContainerStatus, 0, len(started)+1)
+	for _, status := range started {
+		statuses = append(statuses, api.ContainerStatus{
+			Name:    status.Name,
+			State:   api.ContainerTerminated,
+			Message: fmt.Sprintf("stopped because container %s failed to start", failedContainer),
+		})
+	}
+	statuses = append(statuses, api.ContainerStatus{
+		Name:     failedContainer,
+		State:    api.ContainerTerminated,
+		Reason:   StartError,
+		Message:  err.Error(),
+		ExitCode: startErrorExitCode,
+	})
+
+	updatedPod, ok := k.pods.Mutate(pod, func(p *api.Pod) {
+		p.Status = api.PodFailed
+		p.ContainerStatuses = statuses
+	})
+	if !ok {
+		return
+	}
+	k.updatePodStatus(updatedPod)
+}
+
+// failedStart returns the status of the container that failed the start of pod, or nil if the pod did not
+// fail to start
+func failedStart(pod *api.Pod) *api.ContainerStatus {
+	if pod.Status != api.PodFailed {
+		return nil
+	}
+	for i := range pod.ContainerStatuses {
+		if pod.ContainerStatuses[i].Reason == StartError {
+			return &pod.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
+
+// retryFailedStart starts a pod that failed to start again from scratch when its restart policy allows it.
+// Repeated attempts are delayed by the restart backoff, during which the pod stays Failed.
+func (k *Kubelet) retryFailedStart(pod *api.Pod, failed *api.ContainerStatus) {
+	if !pod.Spec.ShouldRestart(failed.ExitCode) {
+		return
+	}
+	key := pod.Name
+	if k.restartBackoff.InBackoff(key) {
+		return
+	}
+	k.restartBackoff.Next(key)
+
+	k.log().Info("Retrying the start of pod", "pod", pod.Name, "nextRetryDelay", k.restartBackoff.Delay(key))
+	updatedPod, ok := k.pods.Mutate(pod, func(p *api.Pod) {
+		p.Status = api.PodScheduled
+		p.ContainerStatuses = nil
+	})
+	if !ok {
+		return
+	}
+	k.enqueuePodStart(updatedPod)
+}
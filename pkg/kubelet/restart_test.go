@@ -0,0 +1,215 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+// newRestartTestKubelet returns a kubelet on a fake runtime running a pod whose single container exits
+func newRestartTestKubelet(t *testing.T, policy api.RestartPolicy) (*Kubelet, *fakeruntime.FakeRuntime, *api.Pod) {
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	runtime := fakeruntime.New()
+	kubelet.runtime = runtime
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "crashing-pod"},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec: api.PodSpec{
+			Containers:    []api.Container{{Name: "hello", Image: "hello-world"}},
+			RestartPolicy: policy,
+		},
+	}
+	kubelet.pods.Add(pod)
+	kubelet.runPod(pod)
+	return kubelet, runtime, pod
+}
+
+func TestRestartExitedContainersWithBackoff(t *testing.T) {
+	kubelet, runtime, pod := newRestartTestKubelet(t, api.RestartPolicyAlways)
+	now := time.Now()
+	kubelet.restartBackoff.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	sync := func() *api.ContainerStatus {
+		current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+		runtime.Exit(current.GetContainerStatus("hello").ContainerID, 0)
+		kubelet.syncPods(ctx)
+		current, _ = kubelet.pods.Get(pod.Namespace, pod.Name)
+		return current.GetContainerStatus("hello")
+	}
+
+	// The first exit is restarted straight away
+	status := sync()
+	assert.Equal(t, int32(1), status.RestartCount)
+	assert.Equal(t, api.ContainerRunning, status.State)
+
+	// The second exit happens inside the initial backoff
+	status = sync()
+	assert.Equal(t, int32(1), status.RestartCount)
+	assert.Equal(t, api.ContainerWaiting, status.State)
+	assert.Equal(t, CrashLoopBackOff, status.Reason)
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, api.PodRunning, current.Status, "a crash looping pod is still running")
+
+	now = now.Add(DefaultRestartBackoff)
+	status = sync()
+	assert.Equal(t, int32(2), status.RestartCount)
+
+	// The delay has doubled, so waiting the initial backoff again is not enough
+	now = now.Add(DefaultRestartBackoff)
+	status = sync()
+	assert.Equal(t, int32(2), status.RestartCount)
+	assert.Equal(t, CrashLoopBackOff, status.Reason)
+
+	now = now.Add(DefaultRestartBackoff)
+	status = sync()
+	assert.Equal(t, int32(3), status.RestartCount)
+
+	// Every restart replaced the exited container
+	assert.Len(t, runtime.Removed(), 3)
+	assert.Len(t, runtime.Running(pod.Name), 1)
+}
+
+func TestRestartPolicyNeverLeavesExitedContainers(t *testing.T) {
+	kubelet, runtime, pod := newRestartTestKubelet(t, api.RestartPolicyNever)
+
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	containerID := current.GetContainerStatus("hello").ContainerID
+	runtime.Exit(containerID, 0)
+	kubelet.syncPods(context.Background())
+
+	current, _ = kubelet.pods.Get(pod.Namespace, pod.Name)
+	status := current.GetContainerStatus("hello")
+	assert.Equal(t, containerID, status.ContainerID)
+	assert.Equal(t, api.ContainerTerminated, status.State)
+	assert.Equal(t, int32(0), status.RestartCount)
+	assert.Equal(t, api.PodSucceeded, current.Status)
+	assert.Empty(t, runtime.Removed())
+}
+
+func TestSyncRecreatesMissingContainers(t *testing.T) {
+	kubelet, runtime, pod := newRestartTestKubelet(t, api.RestartPolicyNever)
+
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	require.NoError(t, runtime.RemoveContainer(context.Background(), current.GetContainerStatus("hello").ContainerID))
+	kubelet.syncPods(context.Background())
+
+	current, _ = kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, api.ContainerRunning, current.GetContainerStatus("hello").State)
+	assert.Len(t, runtime.Running(pod.Name), 1)
+}
+
+// newPodStartTestKubelet returns a kubelet on a fake runtime and a pod of three containers whose second
+// container fails to start
+func newPodStartTestKubelet(t *testing.T, policy api.RestartPolicy) (*Kubelet, *fakeruntime.FakeRuntime, *api.Pod) {
+	kubelet := newTestKubelet(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	runtime := fakeruntime.New()
+	runtime.FailStart("sidecar", errors.New("exec format error"))
+	kubelet.runtime = runtime
+	kubelet.startPod = kubelet.runPod
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web-pod", UID: "uid-1"},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{Name: "web", Image: "nginx"},
+				{Name: "sidecar", Image: "envoy"},
+				{Name: "metrics", Image: "exporter"},
+			},
+			RestartPolicy: policy,
+		},
+	}
+	kubelet.pods.Add(pod)
+	return kubelet, runtime, pod
+}
+
+func createdContainers(runtime *fakeruntime.FakeRuntime) []string {
+	var created []string
+	for _, call := range runtime.Calls() {
+		if strings.HasPrefix(call, "CreateContainer ") {
+			created = append(created, call)
+		}
+	}
+	return created
+}
+
+func TestPodStartRollsBackOnContainerFailure(t *testing.T) {
+	kubelet, runtime, pod := newPodStartTestKubelet(t, api.RestartPolicyNever)
+
+	kubelet.runPod(pod)
+
+	// The containers are started in spec order and the start stops at the failing one
+	created := createdContainers(runtime)
+	require.Len(t, created, 2)
+	assert.True(t, strings.HasPrefix(created[0], "CreateContainer web-pod-web"))
+	assert.True(t, strings.HasPrefix(created[1], "CreateContainer web-pod-sidecar"))
+
+	// Neither the started nor the failed container is left behind
+	assert.Empty(t, runtime.Running(pod.Name))
+	assert.Equal(t, []string{"container-1"}, runtime.Stopped())
+	assert.ElementsMatch(t, []string{"container-1", "container-2"}, runtime.Removed())
+
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, api.PodFailed, current.Status)
+	web := current.GetContainerStatus("web")
+	require.NotNil(t, web)
+	assert.Equal(t, api.ContainerTerminated, web.State)
+	assert.Empty(t, web.ContainerID)
+	sidecar := current.GetContainerStatus("sidecar")
+	require.NotNil(t, sidecar)
+	assert.Equal(t, api.ContainerTerminated, sidecar.State)
+	assert.Equal(t, StartError, sidecar.Reason)
+	assert.Contains(t, sidecar.Message, "exec format error")
+	assert.Nil(t, current.GetContainerStatus("metrics"), "containers after the failing one are not started")
+
+	// Without a restart policy the pod stays failed
+	kubelet.syncPods(context.Background())
+	current, _ = kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, api.PodFailed, current.Status)
+	assert.Len(t, createdContainers(runtime), 2)
+}
+
+func TestFailedPodStartIsRetriedByRestartPolicy(t *testing.T) {
+	kubelet, runtime, pod := newPodStartTestKubelet(t, api.RestartPolicyOnFailure)
+	now := time.Now()
+	kubelet.restartBackoff.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	kubelet.runPod(pod)
+	isFailed := func() bool {
+		current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+		return current.Status == api.PodFailed && !kubelet.pods.IsStarting(pod)
+	}
+	require.True(t, isFailed())
+
+	// The first retry starts the whole pod again straight away
+	kubelet.syncPods(ctx)
+	assert.Eventually(t, func() bool { return isFailed() && len(createdContainers(runtime)) == 4 }, time.Second, 10*time.Millisecond)
+
+	// The next retry waits for the restart backoff
+	kubelet.syncPods(ctx)
+	assert.Len(t, createdContainers(runtime), 4)
+
+	runtime.FailStart("sidecar", nil)
+	now = now.Add(DefaultRestartBackoff)
+	kubelet.syncPods(ctx)
+	assert.Eventually(t, func() bool { return len(runtime.Running(pod.Name)) == 3 }, time.Second, 10*time.Millisecond)
+
+	kubelet.syncPods(ctx)
+	current, _ := kubelet.pods.Get(pod.Namespace, pod.Name)
+	assert.Equal(t, api.PodRunning, current.Status)
+	assert.Equal(t, api.ContainerRunning, current.GetContainerStatus("sidecar").State)
+}
@@ -0,0 +1,162 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+)
+
+// startServer serves the kubelet API on the configured port until Stop is called
+func (k *Kubelet) startServer() {
+	restContainer := restful.NewContainer()
+	k.registerRoutes(restContainer)
+
+	server := &http.Server{
+		Addr:    net.JoinHostPort(k.options.Address, strconv.Itoa(k.options.Port)),
+		Handler: restContainer,
+	}
+
+	k.serverMutex.Lock()
+	defer k.serverMutex.Unlock()
+	select {
+	case <-k.done:
+		// Stopped before the server started
+		return
+	default:
+	}
+	k.server = server
+
+	go func() {
+		k.log().Info("Kubelet server listening", "address", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			k.log().Error("Kubelet server stopped", logging.Err(err))
+		}
+	}()
+}
+
+// Stop ends the loops of the kubelet and shuts its server down, waiting for in-flight requests until ctx is
+// done. The containers of its pods keep running; CleanupContainers removes them.
+func (k *Kubelet) Stop(ctx context.Context) error {
+	k.stopOnce.Do(func() {
+		if k.done != nil {
+			close(k.done)
+		}
+	})
+	k.serverMutex.Lock()
+	server := k.server
+	k.serverMutex.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// registerRoutes adds the kubelet API routes and its metrics on /metrics to the container
+func (k *Kubelet) registerRoutes(restContainer *restful.Container) {
+	ws := new(restful.WebService)
+
+	ws.Path("/").Produces(restful.MIME_JSON)
+	ws.Route(ws.GET("/healthz").To(k.healthz))
+	ws.Route(ws.GET("/pods").To(k.listPods))
+	ws.Route(ws.GET("/containerLogs/{namespace}/{podName}/{containerName}").
+		Param(ws.QueryParameter("follow", "stream the logs until the container stops").DataType("boolean")).
+		Param(ws.QueryParameter("tailLines", "number of lines to show from the end of the logs").DataType("integer")).
+		Produces("text/plain").
+		To(k.containerLogsHandler))
+
+	restContainer.Add(ws)
+
+	metrics := prometheus.NewRegistry()
+	metrics.MustRegister(k.statusUpdates().Collectors()...)
+	restContainer.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{}))
+}
+
+func (k *Kubelet) healthz(request *restful.Request, response *restful.Response) {
+	api.WriteResponse(response, http.StatusOK, nil)
+}
+
+// listPods returns the kubelet's current view of its pods, including container statuses
+func (k *Kubelet) listPods(request *restful.Request, response *restful.Response) {
+	pods := k.pods.List()
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	api.WriteResponse(response, http.StatusOK, pods)
+}
+
+// containerLogsHandler streams the logs of a container of a pod running on this node
+func (k *Kubelet) containerLogsHandler(request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("namespace")
+	podName := request.PathParameter("podName")
+	containerName := request.PathParameter("containerName")
+
+	pod, ok := k.pods.Get(namespace, podName)
+	if !ok {
+		api.WriteError(response, http.StatusNotFound, fmt.Errorf("pod %s/%s is not running on this node", namespace, podName))
+		return
+	}
+	status := pod.GetContainerStatus(containerName)
+	if status == nil || status.ContainerID == "" {
+		api.WriteError(response, http.StatusNotFound, fmt.Errorf("container %s of pod %s has not been started", containerName, podName))
+		return
+	}
+
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true}
+	if follow := request.QueryParameter("follow"); follow != "" {
+		value, err := strconv.ParseBool(follow)
+		if err != nil {
+			api.WriteError(response, http.StatusBadRequest, fmt.Errorf("invalid follow parameter: %w", err))
+			return
+		}
+		options.Follow = value
+	}
+	if tailLines := request.QueryParameter("tailLines"); tailLines != "" {
+		lines, err := strconv.Atoi(tailLines)
+		if err != nil || lines < 0 {
+			api.WriteError(response, http.StatusBadRequest, fmt.Errorf("invalid tailLines parameter: %q", tailLines))
+			return
+		}
+		options.Tail = tailLines
+	}
+
+	logs, err := k.containerLogs(request.Request.Context(), status.ContainerID, options)
+	if err != nil {
+		api.WriteError(response, http.StatusInternalServerError, fmt.Errorf("failed to get logs of container %s: %w", containerName, err))
+		return
+	}
+	defer logs.Close()
+
+	response.Header().Set("Content-Type", "text/plain")
+	response.WriteHeader(http.StatusOK)
+
+	// Docker multiplexes stdout and stderr; both are written to the response as they arrive
+	writer := &flushWriter{writer: response.ResponseWriter}
+	if _, err := stdcopy.StdCopy(writer, writer, logs); err != nil && !errors.Is(err, context.Canceled) {
+		k.log().Error("Error streaming container logs", "pod", podName, "container", containerName, logging.Err(err))
+	}
+}
+
+// flushWriter flushes after every write so followed logs reach the client immediately
+type flushWriter struct {
+	writer io.Writer
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,182 @@
+package kubelet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+)
+
+// multiplexedLogs frames stdout and stderr the way the Docker daemon does
+func multiplexedLogs(t *testing.T, stdout, stderr string) io.ReadCloser {
+	var buf bytes.Buffer
+	_, err := stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(stdout))
+	require.NoError(t, err)
+	_, err = stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte(stderr))
+	require.NoError(t, err)
+	return io.NopCloser(&buf)
+}
+
+func newServerTestKubelet(t *testing.T) (*Kubelet, *httptest.Server) {
+	kubelet := &Kubelet{
+		nodeName: "node-1",
+		pods:     newPodManager(),
+	}
+	kubelet.pods.Add(&api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		NodeName:   "node-1",
+		Status:     api.PodRunning,
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx"}, {Name: "sidecar", Image: "busybox"}}},
+		ContainerStatuses: []api.ContainerStatus{
+			{Name: "nginx", ContainerID: "abc123", State: api.ContainerRunning},
+			{Name: "sidecar", State: api.ContainerWaiting, Reason: ImagePullBackOff},
+		},
+	})
+
+	restContainer := restful.NewContainer()
+	kubelet.registerRoutes(restContainer)
+	server := httptest.NewServer(restContainer)
+	t.Cleanup(server.Close)
+	return kubelet, server
+}
+
+func TestKubeletServer_Healthz(t *testing.T) {
+	_, server := newServerTestKubelet(t)
+
+	resp, err := http.Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestKubeletServer_Metrics(t *testing.T) {
+	kubelet, server := newServerTestKubelet(t)
+	kubelet.statusUpdates().Retry(&api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}}, time.Hour)
+
+	resp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "gokube_kubelet_status_queue_depth 1")
+	assert.Contains(t, string(body), "gokube_kubelet_status_update_retries_total 1")
+}
+
+func TestKubeletServer_Pods(t *testing.T) {
+	_, server := newServerTestKubelet(t)
+
+	resp, err := http.Get(server.URL + "/pods")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var pods []*api.Pod
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&pods))
+	require.Len(t, pods, 1)
+	assert.Equal(t, "web", pods[0].Name)
+	require.Len(t, pods[0].ContainerStatuses, 2)
+	assert.Equal(t, ImagePullBackOff, pods[0].GetContainerStatus("sidecar").Reason)
+}
+
+func TestKubeletServer_ContainerLogs(t *testing.T) {
+	kubelet, server := newServerTestKubelet(t)
+
+	var requestedID string
+	var requestedOptions container.LogsOptions
+	kubelet.containerLogs = func(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+		requestedID, requestedOptions = containerID, options
+		return multiplexedLogs(t, "hello from stdout\n", "hello from stderr\n"), nil
+	}
+
+	t.Run("should stream the container logs", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/containerLogs/default/web/nginx?follow=true&tailLines=10")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello from stdout\nhello from stderr\n", string(body))
+		assert.Equal(t, "abc123", requestedID)
+		assert.True(t, requestedOptions.Follow)
+		assert.Equal(t, "10", requestedOptions.Tail)
+	})
+
+	t.Run("should stream the logs of the pod of the requested namespace", func(t *testing.T) {
+		kubelet.pods.Add(&api.Pod{
+			ObjectMeta:        api.ObjectMeta{Name: "web", Namespace: "team-a"},
+			NodeName:          "node-1",
+			Status:            api.PodRunning,
+			Spec:              api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx"}}},
+			ContainerStatuses: []api.ContainerStatus{{Name: "nginx", ContainerID: "def456", State: api.ContainerRunning}},
+		})
+		t.Cleanup(func() { kubelet.pods.Delete("team-a", "web") })
+
+		resp, err := http.Get(server.URL + "/containerLogs/team-a/web/nginx")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "def456", requestedID)
+	})
+
+	testCases := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "unknown pod", path: "/containerLogs/default/missing/nginx", status: http.StatusNotFound},
+		{name: "pod of another namespace", path: "/containerLogs/team-b/web/nginx", status: http.StatusNotFound},
+		{name: "container not started", path: "/containerLogs/default/web/sidecar", status: http.StatusNotFound},
+		{name: "invalid follow", path: "/containerLogs/default/web/nginx?follow=maybe", status: http.StatusBadRequest},
+		{name: "invalid tailLines", path: "/containerLogs/default/web/nginx?tailLines=-1", status: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tc.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestKubeletServer_StartAndStop(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	kubelet := &Kubelet{pods: newPodManager(), options: DefaultOptions()}
+	kubelet.options.Port = port
+	healthz := fmt.Sprintf("http://localhost:%d/healthz", port)
+
+	kubelet.startServer()
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(healthz)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 20*time.Millisecond)
+
+	require.NoError(t, kubelet.Stop(context.Background()))
+	_, err = http.Get(healthz)
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package kubelet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/logging"
+)
+
+// staticPod is a pod the kubelet runs from a manifest file rather than from an API server assignment
+type staticPod struct {
+	pod *api.Pod
+	// path is the manifest the pod was read from
+	path string
+	// mirrored is set once the read-only copy of the pod exists on the API server
+	mirrored bool
+}
+
+// staticPods holds the static pods of the kubelet by podFullName. The zero value is ready to use.
+type staticPods struct {
+	mutex sync.Mutex
+	pods  map[string]*staticPod
+}
+
+// Has checks if the pod with the given namespace and name is a static pod
+func (s *staticPods) Has(namespace, name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.pods[podFullName(namespace, name)]
+	return ok
+}
+
+// MarkUnmirrored records that the API server lost the copy of the static pod with the given namespace and
+// name, so it is published again
+func (s *staticPods) MarkUnmirrored(namespace, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, ok := s.pods[podFullName(namespace, name)]; ok {
+		entry.mirrored = false
+	}
+}
+
+// watchStaticPods runs the pods defined in the manifests of PodManifestPath, reading the directory every
+// FileCheckFrequency. It needs no API server, so static pods also run while the node cannot register.
+func (k *Kubelet) watchStaticPods() {
+	ticker := time.NewTicker(k.options.FileCheckFrequency)
+	defer ticker.Stop()
+
+	for {
+		k.syncStaticPods()
+		select {
+		case <-ticker.C:
+		case <-k.done:
+			return
+		}
+	}
+}
+
+// syncStaticPods starts the pods of new manifests, tears down the pods whose manifest was removed and
+// publishes the static pods that are not mirrored to the API server yet. A pod whose manifest changed is
+// torn down and started from the new manifest on the next check.
+func (k *Kubelet) syncStaticPods() {
+	manifests, unreadable, err := readPodManifests(k.options.PodManifestPath, k.nodeName, k.log())
+	if err != nil {
+		k.log().Error("Error reading pod manifests", "path", k.options.PodManifestPath, logging.Err(err))
+		return
+	}
+
+	k.staticPods.mutex.Lock()
+	if k.staticPods.pods == nil {
+		k.staticPods.pods = make(map[string]*staticPod)
+	}
+	var added, removed []*staticPod
+	for name, manifest := range manifests {
+		current, known := k.staticPods.pods[name]
+		switch {
+		case !known:
+			k.staticPods.pods[name] = manifest
+			added = append(added, manifest)
+		case !reflect.DeepEqual(current.pod.Spec, manifest.pod.Spec):
+			k.log().Info("Manifest of static pod changed, restarting it", "pod", current.pod.Name)
+			delete(k.staticPods.pods, name)
+			removed = append(removed, current)
+		}
+	}
+	for name, current := range k.staticPods.pods {
+		// A manifest that cannot be parsed, e.g. because it is being written, keeps its pod running
+		if _, ok := manifests[name]; !ok && !unreadable[current.path] {
+			delete(k.staticPods.pods, name)
+			removed = append(removed, current)
+		}
+	}
+	k.staticPods.mutex.Unlock()
+
+	for _, entry := range removed {
+		k.removePod(entry.pod.Namespace, entry.pod.Name)
+		if err := k.deleteMirrorPod(entry.pod); err != nil {
+			k.apiServerLog.Error(err, "Error deleting mirror pod %s", entry.pod.Name)
+		}
+	}
+	for _, entry := range added {
+		if k.pods.Add(entry.pod) {
+			k.log().Info("Static pod added", "pod", entry.pod.Name, "path", entry.path)
+			k.requestSync()
+		}
+	}
+
+	k.mirrorStaticPods()
+}
+
+// mirrorStaticPods publishes a read-only copy of every static pod the API server does not have yet
+func (k *Kubelet) mirrorStaticPods() {
+	k.staticPods.mutex.Lock()
+	var pending []*api.Pod
+	for _, entry := range k.staticPods.pods {
+		if !entry.mirrored {
+			pending = append(pending, entry.pod)
+		}
+	}
+	k.staticPods.mutex.Unlock()
+
+	for _, pod := range pending {
+		if err := k.createMirrorPod(pod); err != nil {
+			k.apiServerLog.Error(err, "Error creating mirror pod %s, retrying on the next check", pod.Name)
+			continue
+		}
+		k.staticPods.mutex.Lock()
+		if entry, ok := k.staticPods.pods[podFullName(pod.Namespace, pod.Name)]; ok && entry.pod == pod {
+			entry.mirrored = true
+		}
+		k.staticPods.mutex.Unlock()
+	}
+}
+
+// readPodManifests parses the JSON and YAML pod manifests in dir and returns them by podFullName. Pods are
+// named <pod>-<node> and bound to the node, so the pods of kubelets sharing manifests do not collide on the
+// API server. The paths of manifests that could not be parsed are returned separately and logged to logger.
+func readPodManifests(dir, nodeName string, logger *slog.Logger) (map[string]*staticPod, map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifests := make(map[string]*staticPod)
+	unreadable := make(map[string]bool)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !isManifestFile(entry.Name()) {
+			continue
+		}
+
+		pod, err := readPodManifest(path, nodeName)
+		if err != nil {
+			logger.Warn("Skipping pod manifest", "path", path, logging.Err(err))
+			unreadable[path] = true
+			continue
+		}
+		name := podFullName(pod.Namespace, pod.Name)
+		if other, ok := manifests[name]; ok {
+			logger.Warn("Skipping pod manifest, the pod is already defined in another", "path", path, "pod", pod.Name, "definedIn", other.path)
+			continue
+		}
+		manifests[name] = &staticPod{pod: pod, path: path}
+	}
+	return manifests, unreadable, nil
+}
+
+func isManifestFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func readPodManifest(path, nodeName string) (*api.Pod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pod := &api.Pod{}
+	// YAML is a superset of JSON, so both are read the same way
+	if err := yaml.Unmarshal(data, pod); err != nil {
+		return nil, fmt.Errorf("failed to parse pod: %w", err)
+	}
+	if err := pod.Validate(); err != nil {
+		return nil, err
+	}
+
+	pod.Name = pod.Name + "-" + nodeName
+	pod.UID = staticPodUID(pod.Name, data)
+	pod.NodeName = nodeName
+	pod.Status = api.PodScheduled
+	pod.ContainerStatuses = nil
+	labels := make(map[string]string, len(pod.Labels)+1)
+	for key, value := range pod.Labels {
+		labels[key] = value
+	}
+	labels[api.MirrorPodLabel] = nodeName
+	pod.Labels = labels
+	return pod, nil
+}
+
+// staticPodUID derives the UID of a static pod from its manifest, so the pod keeps its UID across kubelet
+// restarts and gets a new one when the manifest changes. The mirror pod is created with the same UID.
+func staticPodUID(podName string, manifest []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(podName))
+	hash.Write(manifest)
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+// createMirrorPod creates the read-only API server copy of a static pod. A copy that already exists,
+// e.g. because the kubelet restarted, is kept.
+func (k *Kubelet) createMirrorPod(pod *api.Pod) error {
+	if _, err := k.apiClient.Pods().Create(context.Background(), pod); err != nil && !client.IsConflict(err) {
+		return fmt.Errorf("failed to create mirror pod: %w", err)
+	}
+	return nil
+}
+
+// deleteMirrorPod removes the API server copy of a static pod that is no longer run
+func (k *Kubelet) deleteMirrorPod(pod *api.Pod) error {
+	if err := k.apiClient.PodsIn(pod.Namespace).Delete(context.Background(), pod.Name); err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("failed to delete mirror pod: %w", err)
+	}
+	return nil
+}
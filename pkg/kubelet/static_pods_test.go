@@ -0,0 +1,176 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/kubelet/container/fakeruntime"
+)
+
+const webManifest = `
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  containers:
+    - name: nginx
+      image: nginx:alpine
+`
+
+func writeManifest(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestReadPodManifests(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "web.yaml", webManifest)
+	writeManifest(t, dir, "db.json", `{"metadata": {"name": "db"}, "spec": {"containers": [{"name": "postgres", "image": "postgres"}]}}`)
+	broken := writeManifest(t, dir, "broken.yml", "metadata: [")
+	writeManifest(t, dir, "no-containers.yaml", "metadata:\n  name: empty\n")
+	writeManifest(t, dir, "README.txt", "not a manifest")
+	writeManifest(t, dir, ".web.yaml.swp", webManifest)
+
+	manifests, unreadable, err := readPodManifests(dir, "node-1", slog.Default())
+	require.NoError(t, err)
+
+	require.Len(t, manifests, 2)
+	web := manifests[podFullName("", "web-node-1")].pod
+	assert.Equal(t, "node-1", web.NodeName)
+	assert.Equal(t, api.PodScheduled, web.Status)
+	assert.Equal(t, "nginx:alpine", web.Spec.Containers[0].Image)
+	assert.Equal(t, "web", web.Labels["app"])
+	assert.True(t, web.IsMirrorPod())
+	assert.NotEmpty(t, web.UID, "static pods need a UID for their containers and mirror")
+	assert.Contains(t, manifests, podFullName("", "db-node-1"))
+
+	assert.True(t, unreadable[broken])
+	assert.Len(t, unreadable, 2)
+
+	_, _, err = readPodManifests(filepath.Join(dir, "missing"), "node-1", slog.Default())
+	assert.Error(t, err)
+}
+
+// fakeMirrorAPI records the mirror pods a kubelet creates and deletes, and lists the given pods
+type fakeMirrorAPI struct {
+	mutex   sync.Mutex
+	created []string
+	deleted []string
+	listed  []*api.Pod
+}
+
+func (f *fakeMirrorAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/pods":
+		var pod api.Pod
+		_ = json.NewDecoder(r.Body).Decode(&pod)
+		f.created = append(f.created, pod.Name)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodDelete:
+		f.deleted = append(f.deleted, filepath.Base(r.URL.Path))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/pods":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(f.listed)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (f *fakeMirrorAPI) snapshot() ([]string, []string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.created...), append([]string(nil), f.deleted...)
+}
+
+func newStaticPodTestKubelet(t *testing.T, fakeAPI http.Handler) (*Kubelet, *fakeruntime.FakeRuntime, string) {
+	dir := t.TempDir()
+	runtime := fakeruntime.New()
+	kubelet := newTestKubelet(t, fakeAPI)
+	kubelet.runtime = runtime
+	kubelet.options.PodManifestPath = dir
+	kubelet.startPod = kubelet.runPod
+	kubelet.stopPod = kubelet.killPod
+	return kubelet, runtime, dir
+}
+
+func TestStaticPodsRunFromManifests(t *testing.T) {
+	fakeAPI := &fakeMirrorAPI{}
+	kubelet, runtime, dir := newStaticPodTestKubelet(t, fakeAPI)
+	path := writeManifest(t, dir, "web.yaml", webManifest)
+
+	kubelet.syncStaticPods()
+	kubelet.syncPods(context.Background())
+	assert.Eventually(t, func() bool { return len(runtime.Running("web-node-1")) == 1 }, time.Second, 10*time.Millisecond)
+	created, _ := fakeAPI.snapshot()
+	assert.Equal(t, []string{"web-node-1"}, created, "the static pod should be mirrored")
+
+	// The API server does not list the static pod as an assignment, and must not make the kubelet forget it
+	fakeAPI.mutex.Lock()
+	fakeAPI.listed = []*api.Pod{{ObjectMeta: api.ObjectMeta{Name: "other"}, NodeName: "node-2", Status: api.PodScheduled}}
+	fakeAPI.mutex.Unlock()
+	require.NoError(t, kubelet.relistPods())
+	_, tracked := kubelet.pods.Get("", "web-node-1")
+	assert.True(t, tracked)
+
+	// Removing the manifest tears the pod down and deletes its mirror
+	require.NoError(t, os.Remove(path))
+	kubelet.syncStaticPods()
+	kubelet.syncPods(context.Background())
+	assert.Empty(t, runtime.Running("web-node-1"))
+	assert.Equal(t, 0, kubelet.pods.Len())
+	_, deleted := fakeAPI.snapshot()
+	assert.Equal(t, []string{"web-node-1"}, deleted)
+}
+
+func TestStaticPodsRunWithoutAPIServer(t *testing.T) {
+	kubelet, runtime, dir := newStaticPodTestKubelet(t, http.NotFoundHandler())
+	// Nothing listens on the API server address
+	kubelet.apiClient = newTestAPIClient(t, "127.0.0.1:1")
+	writeManifest(t, dir, "web.yaml", webManifest)
+
+	kubelet.syncStaticPods()
+	kubelet.syncPods(context.Background())
+	assert.Eventually(t, func() bool { return len(runtime.Running("web-node-1")) == 1 }, time.Second, 10*time.Millisecond)
+
+	// An unparseable manifest, e.g. one being rewritten, keeps the pod running
+	writeManifest(t, dir, "web.yaml", "spec: [")
+	kubelet.syncStaticPods()
+	kubelet.syncPods(context.Background())
+	assert.Len(t, runtime.Running("web-node-1"), 1)
+}
+
+func TestMirrorPodsAreNotRunAsAssignments(t *testing.T) {
+	mirror := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web-node-1", Labels: map[string]string{api.MirrorPodLabel: "node-1"}},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+		Spec:       nginxSpec,
+	}
+	fakeAPI := &fakeMirrorAPI{listed: []*api.Pod{mirror}}
+	kubelet, _, _ := newStaticPodTestKubelet(t, fakeAPI)
+
+	// The manifest of the mirrored pod is gone, so its stale mirror is deleted
+	require.NoError(t, kubelet.relistPods())
+
+	assert.Equal(t, 0, kubelet.pods.Len())
+	_, deleted := fakeAPI.snapshot()
+	assert.Equal(t, []string{"web-node-1"}, deleted)
+}
@@ -0,0 +1,224 @@
+package kubelet
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+
+	"gokube/pkg/api"
+)
+
+const (
+	// DefaultStatusUpdateQPS bounds how many pod status updates the kubelet sends per second, retries included,
+	// so a node recovering from an API server outage does not flood it
+	DefaultStatusUpdateQPS = 10
+	// DefaultStatusUpdateBurst is how many status updates may be sent at once before DefaultStatusUpdateQPS applies
+	DefaultStatusUpdateBurst = 20
+)
+
+// podStatusQueue holds the pod statuses waiting to be reported to the API server, one per pod: a newer status
+// replaces the queued one, so a pod whose updates keep failing reports only its latest status once the API
+// server is back. Enqueueing never blocks.
+type podStatusQueue struct {
+	mutex sync.Mutex
+	// pending holds the queued statuses by podFullName
+	pending map[string]*queuedStatus
+	// order is the order in which the pods in pending were queued
+	order []string
+	// ready has a token whenever a status may have become ready to send
+	ready   chan struct{}
+	limiter *rate.Limiter
+	retries atomic.Uint64
+}
+
+type queuedStatus struct {
+	pod *api.Pod
+	// notBefore holds a failed update back until its backoff elapsed
+	notBefore time.Time
+}
+
+// newPodStatusQueue creates a queue whose statuses are sent at most qps per second, in bursts of up to burst
+func newPodStatusQueue(qps rate.Limit, burst int) *podStatusQueue {
+	return &podStatusQueue{
+		pending: make(map[string]*queuedStatus),
+		ready:   make(chan struct{}, 1),
+		limiter: rate.NewLimiter(qps, burst),
+	}
+}
+
+// Add queues the status of pod, replacing a status of the pod that was not sent yet. A pod waiting for the
+// backoff of a failed update keeps waiting.
+func (q *podStatusQueue) Add(pod *api.Pod) {
+	name := podFullName(pod.Namespace, pod.Name)
+	q.mutex.Lock()
+	if item, ok := q.pending[name]; ok {
+		item.pod = pod
+	} else {
+		q.pending[name] = &queuedStatus{pod: pod}
+		q.order = append(q.order, name)
+	}
+	q.mutex.Unlock()
+	q.signal()
+}
+
+// Retry queues the status of pod again after its update failed, to be sent once delay elapsed. A newer
+// status queued in the meantime is sent in its place.
+func (q *podStatusQueue) Retry(pod *api.Pod, delay time.Duration) {
+	q.retries.Add(1)
+	notBefore := time.Now().Add(delay)
+
+	name := podFullName(pod.Namespace, pod.Name)
+	q.mutex.Lock()
+	if item, ok := q.pending[name]; ok {
+		item.notBefore = notBefore
+	} else {
+		q.pending[name] = &queuedStatus{pod: pod, notBefore: notBefore}
+		q.order = append(q.order, name)
+	}
+	q.mutex.Unlock()
+	q.signal()
+}
+
+// Forget drops the queued status of a pod the kubelet no longer runs, given by podFullName
+func (q *podStatusQueue) Forget(fullName string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.pending[fullName]; ok {
+		delete(q.pending, fullName)
+		q.removeFromOrder(fullName)
+	}
+}
+
+// Pop blocks until a status is ready to be sent, and removes it from the queue. It returns false once ctx
+// is done.
+func (q *podStatusQueue) Pop(ctx context.Context) (*api.Pod, bool) {
+	for {
+		pod, wait := q.popReady()
+		if pod != nil {
+			return pod, true
+		}
+
+		var timer *time.Timer
+		var retry <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			retry = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-q.ready:
+		case <-retry:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// popReady removes the first status whose backoff elapsed. With none, it returns how long until the next
+// backoff elapses, zero if no status is queued.
+func (q *podStatusQueue) popReady() (*api.Pod, time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, name := range q.order {
+		item := q.pending[name]
+		if until := item.notBefore.Sub(now); until > 0 {
+			if wait == 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		delete(q.pending, name)
+		q.removeFromOrder(name)
+		return item.pod, 0
+	}
+	return nil, wait
+}
+
+func (q *podStatusQueue) removeFromOrder(fullName string) {
+	for i, name := range q.order {
+		if name == fullName {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len returns the number of queued statuses
+func (q *podStatusQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.pending)
+}
+
+// Retries returns the number of failed status updates queued again
+func (q *podStatusQueue) Retries() uint64 {
+	return q.retries.Load()
+}
+
+func (q *podStatusQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Collectors returns the collectors of the gokube_kubelet_status_queue_depth gauge and the
+// gokube_kubelet_status_update_retries_total counter
+func (q *podStatusQueue) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gokube_kubelet_status_queue_depth",
+			Help: "Number of pod statuses waiting to be reported to the API server.",
+		}, func() float64 { return float64(q.Len()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "gokube_kubelet_status_update_retries_total",
+			Help: "Number of pod status updates that failed and were queued again.",
+		}, func() float64 { return float64(q.Retries()) }),
+	}
+}
+
+// statusUpdates returns the status queue of the kubelet, starting the worker that drains it on first use
+func (k *Kubelet) statusUpdates() *podStatusQueue {
+	k.statusWorkerOnce.Do(func() {
+		if k.statusQueue == nil {
+			k.statusQueue = newPodStatusQueue(DefaultStatusUpdateQPS, DefaultStatusUpdateBurst)
+		}
+		go k.statusUpdateWorker()
+	})
+	return k.statusQueue
+}
+
+// statusUpdateWorker reports the queued pod statuses one at a time, within the rate limit of the queue,
+// until the kubelet is stopped
+func (k *Kubelet) statusUpdateWorker() {
+	ctx, cancel := k.stopContext()
+	defer cancel()
+
+	for {
+		// The token is taken before a status is popped, so a newer status queued while waiting for it is
+		// the one sent
+		if err := k.statusQueue.limiter.Wait(ctx); err != nil {
+			return
+		}
+		pod, ok := k.statusQueue.Pop(ctx)
+		if !ok {
+			return
+		}
+		if !k.pods.Has(pod) {
+			// Removed or recreated while its status was queued
+			continue
+		}
+		k.reportPodStatus(pod)
+	}
+}
@@ -0,0 +1,294 @@
+package kubelet
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	kubecontainer "gokube/pkg/kubelet/container"
+	"gokube/pkg/logging"
+)
+
+// syncLoop reconciles the pods of this node every SyncInterval and whenever the pod sources request it.
+// When eviction is enabled, every pass also checks the node for pressure.
+func (k *Kubelet) syncLoop() {
+	ticker := time.NewTicker(k.options.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-k.syncRequests:
+		case <-k.done:
+			return
+		}
+		k.syncPods(context.Background())
+		if k.options.evictionEnabled() {
+			k.checkNodePressure(context.Background())
+		}
+	}
+}
+
+// wait sleeps for d, or less if the kubelet is stopped first. It returns false once the kubelet is stopped.
+func (k *Kubelet) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-k.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// stopContext returns a context that is canceled once the kubelet is stopped, for the requests that block
+// until the API server answers, such as a watch
+func (k *Kubelet) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-k.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// requestSync asks the sync loop for a pass without waiting for the next tick. Requests made while
+// a pass is already pending are merged into it.
+func (k *Kubelet) requestSync() {
+	select {
+	case k.syncRequests <- struct{}{}:
+	default:
+	}
+}
+
+// syncPods is one pass of the sync loop. It compares the desired pods recorded by the pod sources with
+// the containers the runtime actually has, then per pod starts, keeps, restarts or tears down containers
+// and reports the resulting status. Containers are matched to pods by pod UID, so the containers of a
+// deleted pod are never adopted by a new pod with the same name.
+func (k *Kubelet) syncPods(ctx context.Context) {
+	containers, err := k.runtime.ListContainers(ctx)
+	if err != nil {
+		k.log().Error("Error listing containers, skipping sync", logging.Err(err))
+		return
+	}
+
+	actual := make(map[string][]kubecontainer.Container)
+	for _, c := range containers {
+		if k.ownsContainer(c) {
+			key := podKey(c.PodNamespace, c.PodName, c.PodUID)
+			actual[key] = append(actual[key], c)
+		}
+	}
+
+	pods := k.pods.List()
+	desired := make(map[string]struct{}, len(pods))
+	for _, pod := range pods {
+		desired[podKey(pod.Namespace, pod.Name, pod.UID)] = struct{}{}
+	}
+
+	// Containers of pods that are no longer desired on this node are torn down first, so a pod that
+	// reuses the name of a deleted pod only starts once the old containers are gone. Before the containers
+	// of the previous run are recovered, their pods are not known yet.
+	recovering := k.recovering.Load()
+	for key, podContainers := range actual {
+		if _, ok := desired[key]; !ok && !recovering {
+			k.teardownPod(podContainers)
+		}
+	}
+
+	for _, pod := range pods {
+		// Containers of pods still waiting for a start worker do not exist yet
+		if k.pods.IsStarting(pod) {
+			continue
+		}
+		k.syncPod(ctx, pod, actual[podKey(pod.Namespace, pod.Name, pod.UID)])
+	}
+}
+
+// syncPod reconciles one desired pod with its containers
+func (k *Kubelet) syncPod(ctx context.Context, pod *api.Pod, containers []kubecontainer.Container) {
+	if pod.IsDeleting() {
+		k.terminatePod(ctx, pod, containers)
+		return
+	}
+
+	if len(pod.ContainerStatuses) == 0 && len(containers) == 0 && len(pod.Spec.Containers) > 0 {
+		k.log().InfoContext(podContext(ctx, pod), "Starting pod", "pod", pod.Name)
+		k.enqueuePodStart(pod)
+		return
+	}
+
+	if failed := failedStart(pod); failed != nil {
+		k.retryFailedStart(pod, failed)
+		return
+	}
+
+	k.retryImagePulls(ctx, pod)
+
+	// Retrying image pulls may have started containers
+	pod, ok := k.pods.Get(pod.Namespace, pod.Name)
+	if !ok {
+		return
+	}
+
+	containerStatuses := make([]api.ContainerStatus, 0, len(pod.Spec.Containers))
+	for _, spec := range pod.Spec.Containers {
+		containerStatuses = append(containerStatuses, k.observeContainerStatus(ctx, pod, spec, containers))
+	}
+	containerStatuses = k.restartExitedContainers(ctx, pod, containerStatuses)
+	status := determinePodStatus(containerStatuses)
+
+	if pod.Status == status && slices.Equal(pod.ContainerStatuses, containerStatuses) {
+		// Failed updates are retried by the status queue
+		if k.statusReportDue(pod) {
+			k.updatePodStatus(pod)
+		}
+		return
+	}
+	updatedPod, ok := k.pods.Mutate(pod, func(p *api.Pod) {
+		p.Status = status
+		p.ContainerStatuses = containerStatuses
+	})
+	if !ok {
+		return
+	}
+	k.updatePodStatus(updatedPod)
+}
+
+// observeContainerStatus returns the status of a container of pod as the runtime sees it. The restart count
+// and the reason of a waiting container are kept from the status the kubelet recorded.
+func (k *Kubelet) observeContainerStatus(ctx context.Context, pod *api.Pod, spec api.Container, containers []kubecontainer.Container) api.ContainerStatus {
+	status := api.ContainerStatus{Name: spec.Name, State: api.ContainerWaiting}
+	if current := pod.GetContainerStatus(spec.Name); current != nil {
+		status = *current
+	}
+	status.Image = spec.Image
+
+	// Waiting on its image or a restart backoff; there is no container to look at
+	if status.State == api.ContainerWaiting && status.Reason != "" {
+		return status
+	}
+
+	c, found := findContainer(status.ContainerID, spec.Name, containers)
+	if !found && status.ContainerID != "" {
+		// Started after the runtime was listed
+		inspected, err := k.runtime.InspectContainer(ctx, status.ContainerID)
+		switch {
+		case err == nil:
+			c, found = inspected, true
+		case !errors.Is(err, kubecontainer.ErrContainerNotFound):
+			k.log().Error("Failed to inspect container", "pod", pod.Name, "container", spec.Name, logging.Err(err))
+			return status
+		}
+	}
+
+	if !found {
+		status.ContainerID = ""
+		status.ImageID = ""
+		status.State = api.ContainerWaiting
+		status.ExitCode = 0
+		return status
+	}
+
+	status.ContainerID = c.ID
+	status.ImageID = c.ImageID
+	status.Reason = ""
+	status.Message = ""
+	if c.Running {
+		status.State = api.ContainerRunning
+		status.ExitCode = 0
+	} else {
+		status.State = api.ContainerTerminated
+		status.ExitCode = c.ExitCode
+	}
+	return status
+}
+
+// findContainer returns the container with the recorded ID or, when no ID was recorded, e.g. because
+// the kubelet restarted, the most recently created container with the given name
+func findContainer(containerID, containerName string, containers []kubecontainer.Container) (kubecontainer.Container, bool) {
+	var newest kubecontainer.Container
+	found := false
+	for _, c := range containers {
+		if containerID != "" {
+			if c.ID == containerID {
+				return c, true
+			}
+			continue
+		}
+		if c.ContainerName == containerName && (!found || c.Created.After(newest.Created)) {
+			newest, found = c, true
+		}
+	}
+	return newest, found
+}
+
+// terminatePod stops the running containers of a pod that was deleted, honoring its grace period, and then
+// removes the finalizer of the kubelet from the pod, which lets the API server remove it. The pod is forgotten
+// once the finalizer is removed; until then every sync pass tries again.
+func (k *Kubelet) terminatePod(ctx context.Context, pod *api.Pod, containers []kubecontainer.Container) {
+	stopping := *pod
+	stopping.ContainerStatuses = nil
+	for _, c := range containers {
+		if c.Running {
+			stopping.ContainerStatuses = append(stopping.ContainerStatuses, api.ContainerStatus{
+				Name:        c.ContainerName,
+				ContainerID: c.ID,
+				State:       api.ContainerRunning,
+			})
+		}
+	}
+	if len(stopping.ContainerStatuses) > 0 {
+		k.log().InfoContext(podContext(ctx, pod), "Stopping pod, it was deleted", "pod", pod.Name)
+		k.stopPod(&stopping)
+	}
+
+	err := k.finalizePod(pod)
+	switch {
+	// A pod that is gone or was recreated no longer waits for this kubelet
+	case err == nil, client.IsNotFound(err), client.IsConflict(err):
+		k.removePod(pod.Namespace, pod.Name)
+	default:
+		k.apiServerLog.Error(err, "Error finalizing deleted pod %s, retrying", pod.Name)
+	}
+}
+
+// teardownPod stops the running containers of a pod instance that is no longer desired on this node,
+// honoring the grace period the containers were created with. Its dead containers are left to the garbage
+// collector. All containers belong to the same pod instance.
+func (k *Kubelet) teardownPod(containers []kubecontainer.Container) {
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{
+		Namespace: containers[0].PodNamespace,
+		Name:      containers[0].PodName,
+		UID:       containers[0].PodUID,
+	}}
+	var gracePeriod time.Duration
+	for _, c := range containers {
+		if c.Running {
+			pod.ContainerStatuses = append(pod.ContainerStatuses, api.ContainerStatus{
+				Name:        c.ContainerName,
+				ContainerID: c.ID,
+				State:       api.ContainerRunning,
+			})
+			gracePeriod = max(gracePeriod, c.TerminationGracePeriod)
+		}
+	}
+	gracePeriodSeconds := int64(gracePeriod / time.Second)
+	pod.Spec.TerminationGracePeriodSeconds = &gracePeriodSeconds
+	if len(pod.ContainerStatuses) == 0 {
+		return
+	}
+
+	if current, tracked := k.pods.Get(pod.Namespace, pod.Name); tracked && current.UID != pod.UID {
+		k.log().Info("Stopping stale containers, the pod was recreated", "pod", pod.Name, "oldUID", pod.UID, "uid", current.UID)
+	} else {
+		k.log().Info("Stopping pod, it is no longer assigned to this node", "pod", pod.Name)
+	}
+	k.stopPod(pod)
+}
@@ -0,0 +1,182 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// ComponentKey is the attribute naming the component that logged a record, e.g. scheduler
+	ComponentKey = "component"
+	// RequestIDKey is the attribute of the ID of the API request a record was logged for, or of the trace ID
+	// of the object it was logged for
+	RequestIDKey = "requestID"
+	// OperationIDKey is the attribute of the ID of the pass of a control loop a record was logged in
+	OperationIDKey = "operationID"
+	// ErrorKey is the attribute of the error a record reports
+	ErrorKey = "error"
+
+	// RequestIDHeader carries the request ID of an API request. The API server makes one up for requests
+	// without it and echoes it in the response.
+	RequestIDHeader = "X-Request-ID"
+)
+
+var (
+	ErrInvalidLevel  = errors.New("invalid log level")
+	ErrInvalidFormat = errors.New("invalid log format")
+)
+
+// Options configures the logs of a binary
+type Options struct {
+	// Level is the least severe level logged: debug, info, warn or error
+	Level string
+	// Format is text or json
+	Format string
+}
+
+// DefaultOptions logs info and above as text
+func DefaultOptions() Options {
+	return Options{Level: "info", Format: FormatText}
+}
+
+// AddFlags registers --log-level and --log-format, defaulting to the current options
+func (o *Options) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.Level, "log-level", o.Level, "The least severe level logged: debug, info, warn or error")
+	flags.StringVar(&o.Format, "log-format", o.Format, "The format of the logs: text or json")
+}
+
+// Validate checks the level and the format
+func (o Options) Validate() error {
+	if _, err := parseLevel(o.Level); err != nil {
+		return err
+	}
+	if o.Format != FormatText && o.Format != FormatJSON {
+		return fmt.Errorf("%w %q: expected %s or %s", ErrInvalidFormat, o.Format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// New creates a logger writing to w. Records logged with a context carrying a request ID or an operation ID,
+// see WithRequestID and WithOperationID, get a RequestIDKey or OperationIDKey attribute.
+func New(w io.Writer, options Options) (*slog.Logger, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := parseLevel(options.Level)
+	handlerOptions := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if options.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOptions)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Setup makes a logger writing to stderr the default of slog and of the log package. Binaries call it before
+// creating their components, which log through the default logger unless given another one.
+func Setup(options Options) error {
+	logger, err := New(os.Stderr, options)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// Component returns the default logger, tagged with the component
+func Component(component string) *slog.Logger {
+	return WithComponent(slog.Default(), component)
+}
+
+// WithComponent tags the records of logger with the component
+func WithComponent(logger *slog.Logger, component string) *slog.Logger {
+	return logger.With(ComponentKey, component)
+}
+
+// Err returns the attribute of an error
+func Err(err error) slog.Attr {
+	return slog.Any(ErrorKey, err)
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("%w %q: expected debug, info, warn or error", ErrInvalidLevel, level)
+	}
+}
+
+type (
+	requestIDContextKey   struct{}
+	operationIDContextKey struct{}
+)
+
+// NewRequestID returns a new unique request ID
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// WithRequestID returns a context carrying the ID of the API request it is used for
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestID returns the request ID ctx carries, or an empty string
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// WithOperationID returns a context carrying the ID of a pass of a control loop, such as a scheduling pass
+func WithOperationID(ctx context.Context, operationID string) context.Context {
+	return context.WithValue(ctx, operationIDContextKey{}, operationID)
+}
+
+// OperationID returns the operation ID ctx carries, or an empty string
+func OperationID(ctx context.Context) string {
+	operationID, _ := ctx.Value(operationIDContextKey{}).(string)
+	return operationID
+}
+
+// contextHandler adds the request and operation IDs of the context of a record to it
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String(RequestIDKey, requestID))
+	}
+	if operationID := OperationID(ctx); operationID != "" {
+		record.AddAttrs(slog.String(OperationIDKey, operationID))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should log JSON above the level with component and request ID", func(t *testing.T) {
+		var out bytes.Buffer
+		logger, err := New(&out, Options{Level: "warn", Format: FormatJSON})
+		require.NoError(t, err)
+		logger = WithComponent(logger, "scheduler")
+
+		logger.Info("not logged")
+		ctx := WithRequestID(context.Background(), "req-1")
+		logger.WarnContext(ctx, "Failed to schedule pods", Err(errors.New("no nodes")))
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(out.Bytes(), &record), out.String())
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, "Failed to schedule pods", record["msg"])
+		assert.Equal(t, "scheduler", record[ComponentKey])
+		assert.Equal(t, "req-1", record[RequestIDKey])
+		assert.Equal(t, "no nodes", record[ErrorKey])
+	})
+
+	t.Run("should log the operation ID of the context", func(t *testing.T) {
+		var out bytes.Buffer
+		logger, err := New(&out, Options{Level: "info", Format: FormatText})
+		require.NoError(t, err)
+
+		ctx := WithOperationID(WithRequestID(context.Background(), "trace-1"), "pass-1")
+		logger.InfoContext(ctx, "Created pod", "pod", "web")
+		assert.Contains(t, out.String(), `msg="Created pod" pod=web requestID=trace-1 operationID=pass-1`)
+	})
+
+	t.Run("should log text", func(t *testing.T) {
+		var out bytes.Buffer
+		logger, err := New(&out, Options{Level: "DEBUG", Format: FormatText})
+		require.NoError(t, err)
+
+		logger.Debug("Starting pod", "pod", "web")
+		assert.Contains(t, out.String(), `level=DEBUG msg="Starting pod" pod=web`)
+	})
+
+	t.Run("should reject invalid options", func(t *testing.T) {
+		_, err := New(&bytes.Buffer{}, Options{Level: "verbose", Format: FormatText})
+		assert.ErrorIs(t, err, ErrInvalidLevel)
+		_, err = New(&bytes.Buffer{}, Options{Level: "info", Format: "xml"})
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+	})
+}
+
+func TestOptions_AddFlags(t *testing.T) {
+	options := DefaultOptions()
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	options.AddFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--log-level=error", "--log-format=json"}))
+
+	assert.Equal(t, Options{Level: "error", Format: FormatJSON}, options)
+}
@@ -0,0 +1,173 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"gokube/pkg/api"
+)
+
+var (
+	ErrInvalidManifest = errors.New("invalid manifest")
+	ErrUnknownKind     = errors.New("unknown kind")
+)
+
+// Manifest is a single object of a manifest file
+type Manifest struct {
+	// Source names the document in messages, e.g. web.yaml, document 2
+	Source string
+	// Kind is the kind of the object, e.g. Pod
+	Kind string
+	// Object is a *api.Pod, *api.Node or *api.ReplicaSet
+	Object any
+}
+
+// ObjectMeta returns the metadata of the object
+func (m *Manifest) ObjectMeta() *api.ObjectMeta {
+	switch object := m.Object.(type) {
+	case *api.Pod:
+		return &object.ObjectMeta
+	case *api.Node:
+		return &object.ObjectMeta
+	case *api.ReplicaSet:
+		return &object.ObjectMeta
+	default:
+		panic(fmt.Sprintf("object of unknown kind %T", object))
+	}
+}
+
+// Files lists the JSON and YAML files of a directory in name order, skipping hidden files and subdirectories
+func Files(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !IsManifestFile(entry.Name()) {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// IsManifestFile checks if name has the extension of a JSON or YAML file
+func IsManifestFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// ReadFile reads the manifests of a file
+func ReadFile(path string) ([]Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse parses the documents of a file separated by --- lines. YAML is a superset of JSON, so JSON files are
+// read the same way. Empty documents are skipped but still counted, so messages point at the right document.
+func Parse(filename string, data []byte) ([]Manifest, error) {
+	var manifests []Manifest
+	for i, document := range splitDocuments(data) {
+		source := fmt.Sprintf("%s, document %d", filename, i+1)
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		m, err := parseManifest(document)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if m == nil {
+			// Only comments
+			continue
+		}
+		m.Source = source
+		manifests = append(manifests, *m)
+	}
+	return manifests, nil
+}
+
+func splitDocuments(data []byte) [][]byte {
+	var documents [][]byte
+	var document bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "---\t") {
+			documents = append(documents, bytes.Clone(document.Bytes()))
+			document.Reset()
+			continue
+		}
+		document.WriteString(line)
+		document.WriteByte('\n')
+	}
+	return append(documents, document.Bytes())
+}
+
+// parseManifest decodes a document into the object its kind names. Fields the object doesn't have are
+// rejected, so a misspelt field is not silently dropped. A document of only comments gives a nil manifest.
+func parseManifest(document []byte) (*Manifest, error) {
+	data, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: expected an object: %w", ErrInvalidManifest, err)
+	}
+
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil || kind == "" {
+		return nil, fmt.Errorf("%w: kind is not set", ErrInvalidManifest)
+	}
+	// Objects don't carry their kind and API version, they are implied by the endpoint
+	delete(fields, "kind")
+	delete(fields, "apiVersion")
+
+	var object any
+	switch kind {
+	case "Pod":
+		object = &api.Pod{}
+	case "Node":
+		object = &api.Node{}
+	case api.KindReplicaSet:
+		object = &api.ReplicaSet{}
+	default:
+		return nil, fmt.Errorf("%w %q: expected Pod, Node or ReplicaSet", ErrUnknownKind, kind)
+	}
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(object); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidManifest, kind, err)
+	}
+	m := &Manifest{Kind: kind, Object: object}
+	if m.ObjectMeta().Name == "" {
+		return nil, fmt.Errorf("%w: %s: metadata.name is not set", ErrInvalidManifest, kind)
+	}
+	return m, nil
+}
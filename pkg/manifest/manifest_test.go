@@ -0,0 +1,40 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gokube/pkg/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	manifests, err := Parse("web.yaml", []byte("# comments only\n---\nkind: Pod\nmetadata:\n  name: web\n"+
+		"spec:\n  containers:\n  - name: nginx\n    image: nginx\n---\n---\n{\"kind\": \"Node\", \"metadata\": {\"name\": \"node-1\"}}\n"))
+	require.NoError(t, err)
+	require.Len(t, manifests, 2)
+	assert.Equal(t, "web.yaml, document 2", manifests[0].Source)
+	assert.Equal(t, "web", manifests[0].Object.(*api.Pod).Name)
+	assert.Equal(t, "web.yaml, document 4", manifests[1].Source)
+	assert.Equal(t, "node-1", manifests[1].ObjectMeta().Name)
+
+	_, err = Parse("svc.yaml", []byte("kind: Service\nmetadata:\n  name: web\n"))
+	assert.ErrorIs(t, err, ErrUnknownKind)
+	_, err = Parse("pod.yaml", []byte("kind: Pod\nmetadata:\n  name: web\nspec:\n  containrs: []\n"))
+	assert.ErrorIs(t, err, ErrInvalidManifest)
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.yml", "a.json", "c.yaml", ".hidden.yaml", "README.md"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.yaml"), 0o755))
+
+	paths, err := Files(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.yml"), filepath.Join(dir, "c.yaml")}, paths)
+}
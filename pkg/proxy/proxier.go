@@ -0,0 +1,283 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client/informers"
+	"gokube/pkg/logging"
+)
+
+const (
+	// DefaultSyncPeriod is how often the proxy rebuilds its forwarding table when no change asks for it sooner
+	DefaultSyncPeriod = 10 * time.Second
+	// DefaultDialTimeout is how long the proxy waits for a backend to accept a connection before trying the
+	// next one
+	DefaultDialTimeout = 2 * time.Second
+)
+
+// ServicePortName identifies a port of a Service
+type ServicePortName struct {
+	Namespace string
+	Name      string
+	Port      string
+}
+
+func (n ServicePortName) String() string {
+	if n.Port == "" {
+		return n.Namespace + "/" + n.Name
+	}
+	return n.Namespace + "/" + n.Name + ":" + n.Port
+}
+
+// Proxier forwards the connections to the ports of the Services to their endpoints, the way the userspace mode
+// of kube-proxy did: it listens on every Service port of the node and copies each connection to and from a
+// backend picked round-robin. A backend that cannot be reached is skipped for the next one.
+type Proxier struct {
+	// address is the IP address the Service ports are opened on
+	address     string
+	dialTimeout time.Duration
+	logger      *slog.Logger
+
+	mutex    sync.Mutex
+	services map[ServicePortName]*serviceProxy
+}
+
+// NewProxier creates a Proxier that opens the Service ports on address, e.g. 0.0.0.0 for all interfaces
+func NewProxier(address string) *Proxier {
+	return &Proxier{
+		address:     address,
+		dialTimeout: DefaultDialTimeout,
+		logger:      logging.Component("proxy"),
+		services:    map[ServicePortName]*serviceProxy{},
+	}
+}
+
+// SetLogger makes the proxy log to logger instead of the default logger
+func (p *Proxier) SetLogger(logger *slog.Logger) {
+	p.logger = logging.WithComponent(logger, "proxy")
+}
+
+// Run syncs the proxy with the Services and Endpoints of the informers whenever they change, and every
+// syncPeriod, until ctx is done. The informers must be started by the caller. The Service ports are closed
+// when Run returns.
+func (p *Proxier) Run(ctx context.Context, services *informers.Informer[api.Service], endpoints *informers.Informer[api.Endpoints],
+	syncPeriod time.Duration) {
+	defer p.Close()
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	services.AddEventHandler(informers.EventHandler[api.Service]{
+		AddFunc:    func(*api.Service) { notify() },
+		UpdateFunc: func(_, _ *api.Service) { notify() },
+		DeleteFunc: func(*api.Service) { notify() },
+	})
+	endpoints.AddEventHandler(informers.EventHandler[api.Endpoints]{
+		AddFunc:    func(*api.Endpoints) { notify() },
+		UpdateFunc: func(_, _ *api.Endpoints) { notify() },
+		DeleteFunc: func(*api.Endpoints) { notify() },
+	})
+	// Syncing before the caches are filled would close the ports of every Service
+	if !informers.WaitForCacheSync(ctx, services.HasSynced, endpoints.HasSynced) {
+		return
+	}
+
+	ticker := time.NewTicker(syncPeriod)
+	defer ticker.Stop()
+	for {
+		// The ports that failed to open were logged by Sync and are retried on the next one
+		_ = p.Sync(services.Store().List(), endpoints.Store().List())
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync opens a port for every port of the Services, closes the ports of the Services that are gone, and
+// forwards new connections to the current Endpoints. Connections already forwarded are left alone. It returns
+// the errors of the ports that could not be opened, e.g. because they are taken.
+func (p *Proxier) Sync(services []*api.Service, endpoints []*api.Endpoints) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	backends := map[ServicePortName][]string{}
+	for _, e := range endpoints {
+		for _, port := range e.Ports {
+			name := ServicePortName{Namespace: e.Namespace, Name: e.Name, Port: port.Name}
+			for _, address := range port.Addresses {
+				backends[name] = append(backends[name], net.JoinHostPort(address.IP, strconv.Itoa(int(address.Port))))
+			}
+		}
+	}
+
+	wanted := map[ServicePortName]int32{}
+	for _, service := range services {
+		for _, port := range service.Spec.Ports {
+			wanted[ServicePortName{Namespace: service.Namespace, Name: service.Name, Port: port.Name}] = port.Port
+		}
+	}
+	for name, service := range p.services {
+		if port, ok := wanted[name]; !ok || port != service.port {
+			p.logger.Info("Closing service port", "service", name.String(), "port", service.port)
+			service.close()
+			delete(p.services, name)
+		}
+	}
+
+	var errs []error
+	for name, port := range wanted {
+		service, ok := p.services[name]
+		if !ok {
+			var err error
+			if service, err = p.open(name, port); err != nil {
+				p.logger.Error("Failed to open service port", "service", name.String(), "port", port, logging.Err(err))
+				errs = append(errs, err)
+				continue
+			}
+			p.services[name] = service
+			p.logger.Info("Opened service port", "service", name.String(), "port", port)
+		}
+		if service.setBackends(backends[name]) {
+			p.logger.Info("Updated service backends", "service", name.String(), "backends", backends[name])
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the ports of all Services
+func (p *Proxier) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for name, service := range p.services {
+		service.close()
+		delete(p.services, name)
+	}
+}
+
+func (p *Proxier) open(name ServicePortName, port int32) (*serviceProxy, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(p.address, strconv.Itoa(int(port))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open port %d of service %s: %w", port, name, err)
+	}
+	service := &serviceProxy{
+		port:        port,
+		listener:    listener,
+		dialTimeout: p.dialTimeout,
+		logger:      p.logger.With("service", name.String()),
+	}
+	go service.serve()
+	return service, nil
+}
+
+// serviceProxy forwards the connections to a port of a Service
+type serviceProxy struct {
+	port        int32
+	listener    net.Listener
+	dialTimeout time.Duration
+	logger      *slog.Logger
+
+	mutex    sync.Mutex
+	backends []string
+	// next is the index of the backend the next connection goes to
+	next int
+}
+
+// setBackends replaces the backends, reporting whether they changed
+func (s *serviceProxy) setBackends(backends []string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if slices.Equal(s.backends, backends) {
+		return false
+	}
+	s.backends = backends
+	s.next = 0
+	return true
+}
+
+// pick returns the backends in the order the connection tries them, starting with the next one round-robin
+func (s *serviceProxy) pick() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.backends) == 0 {
+		return nil
+	}
+	start := s.next % len(s.backends)
+	s.next = start + 1
+	return append(append([]string{}, s.backends[start:]...), s.backends[:start]...)
+}
+
+func (s *serviceProxy) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			// The listener was closed by close
+			return
+		}
+		go s.forward(conn)
+	}
+}
+
+// forward copies the connection to and from the first backend that accepts it
+func (s *serviceProxy) forward(conn net.Conn) {
+	defer conn.Close()
+
+	backends := s.pick()
+	if len(backends) == 0 {
+		s.logger.Warn("Dropping connection, the service has no endpoints", "client", conn.RemoteAddr().String())
+		return
+	}
+	var backend net.Conn
+	for _, address := range backends {
+		var err error
+		if backend, err = net.DialTimeout("tcp", address, s.dialTimeout); err == nil {
+			break
+		}
+		s.logger.Warn("Failed to connect to backend, trying the next one", "backend", address, logging.Err(err))
+	}
+	if backend == nil {
+		s.logger.Error("Dropping connection, no backend accepted it", "client", conn.RemoteAddr().String())
+		return
+	}
+	defer backend.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(backend, conn)
+		closeWrite(backend)
+	}()
+	_, _ = io.Copy(conn, backend)
+	closeWrite(conn)
+	<-done
+}
+
+func (s *serviceProxy) close() {
+	_ = s.listener.Close()
+}
+
+// closeWrite tells the other end of a TCP connection that nothing more will be sent
+func closeWrite(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.CloseWrite()
+	}
+}
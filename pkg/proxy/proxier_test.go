@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client/informers"
+	"gokube/pkg/storage"
+	"gokube/pkg/testing/cluster"
+)
+
+// startBackend serves name to every connection on a free local port, like a pod published on a host port,
+// and returns the port
+func startBackend(t *testing.T, name string) int32 {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(name + "\n"))
+			_ = conn.Close()
+		}
+	}()
+	return int32(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// freePort returns a local port nothing listens on, for a Service
+func freePort(t *testing.T) int32 {
+	t.Helper()
+	port, err := storage.PickAvailableRandomPort()
+	require.NoError(t, err)
+	return int32(port)
+}
+
+// call connects to the port and returns the name of the backend that answered, or an empty string
+func call(port int32) string {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))), time.Second)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func newService(name string, port int32) *api.Service {
+	return &api.Service{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: api.DefaultNamespace},
+		Spec: api.ServiceSpec{
+			Selector: map[string]string{"app": name},
+			Ports:    []api.ServicePort{{Port: port, TargetPort: 8080}},
+		},
+	}
+}
+
+func newEndpoints(name string, ports ...int32) *api.Endpoints {
+	port := api.EndpointPort{Addresses: []api.EndpointAddress{}}
+	for _, p := range ports {
+		port.Addresses = append(port.Addresses, api.EndpointAddress{IP: "127.0.0.1", Port: p})
+	}
+	return &api.Endpoints{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: api.DefaultNamespace},
+		Ports:      []api.EndpointPort{port},
+	}
+}
+
+func TestProxier_Sync(t *testing.T) {
+	a, b := startBackend(t, "a"), startBackend(t, "b")
+	servicePort := freePort(t)
+	proxier := NewProxier("127.0.0.1")
+	defer proxier.Close()
+	services := []*api.Service{newService("web", servicePort)}
+
+	t.Run("should round-robin connections over the endpoints", func(t *testing.T) {
+		require.NoError(t, proxier.Sync(services, []*api.Endpoints{newEndpoints("web", a, b)}))
+		assert.Equal(t, []string{"a", "b", "a", "b"}, []string{call(servicePort), call(servicePort), call(servicePort), call(servicePort)})
+	})
+
+	t.Run("should skip endpoints that refuse connections", func(t *testing.T) {
+		require.NoError(t, proxier.Sync(services, []*api.Endpoints{newEndpoints("web", freePort(t), b)}))
+		assert.Equal(t, []string{"b", "b"}, []string{call(servicePort), call(servicePort)})
+	})
+
+	t.Run("should drop connections while the service has no endpoints", func(t *testing.T) {
+		require.NoError(t, proxier.Sync(services, nil))
+		assert.Empty(t, call(servicePort))
+	})
+
+	t.Run("should close the port of a deleted service", func(t *testing.T) {
+		require.NoError(t, proxier.Sync(nil, nil))
+		_, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(servicePort))), time.Second)
+		assert.Error(t, err)
+	})
+
+	t.Run("should report a port that is taken", func(t *testing.T) {
+		taken, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer taken.Close()
+		port := int32(taken.Addr().(*net.TCPAddr).Port)
+		assert.Error(t, proxier.Sync([]*api.Service{newService("web", port)}, nil))
+	})
+}
+
+// TestProxier_ForwardsToPodsOfService runs a backend pod on each of two kubelets, and checks that the proxy
+// spreads connections to the Service over both, then only sends them to the pod that is left once the other
+// is deleted
+func TestProxier_ForwardsToPodsOfService(t *testing.T) {
+	options := cluster.DefaultOptions()
+	options.Kubelets = 2
+	c := cluster.ForTest(t, options)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The fake runtime starts no processes, so the backends stand in for the containers on their host ports
+	for i, name := range []string{"web-0", "web-1"} {
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name, Labels: map[string]string{"app": "web"}},
+			Spec: api.PodSpec{Containers: []api.Container{{
+				Name:  "web",
+				Image: "nginx:latest",
+				Ports: []api.ContainerPort{{ContainerPort: 8080, HostPort: startBackend(t, name)}},
+			}}},
+			NodeName: "node-" + strconv.Itoa(i),
+			Status:   api.PodScheduled,
+		}
+		_, err := c.Client().Pods().Create(ctx, pod)
+		require.NoError(t, err)
+	}
+	servicePort := freePort(t)
+	_, err := c.Client().Services(api.DefaultNamespace).Create(ctx, newService("web", servicePort))
+	require.NoError(t, err)
+
+	factory := informers.NewSharedInformerFactory(c.Client(), 50*time.Millisecond)
+	services, endpoints := factory.Services(), factory.Endpoints()
+	factory.Start(ctx)
+	proxier := NewProxier("127.0.0.1")
+	go proxier.Run(ctx, services, endpoints, time.Second)
+
+	// Returns the backends that answered a few connections in a row
+	backends := func() map[string]bool {
+		answered := map[string]bool{}
+		for range 4 {
+			answered[call(servicePort)] = true
+		}
+		return answered
+	}
+	require.Eventually(t, func() bool {
+		answered := backends()
+		return answered["web-0"] && answered["web-1"]
+	}, 10*time.Second, 50*time.Millisecond, "both pods should receive connections")
+
+	require.NoError(t, c.Client().Pods().Delete(ctx, "web-0"))
+	require.Eventually(t, func() bool {
+		answered := backends()
+		return len(answered) == 1 && answered["web-1"]
+	}, 10*time.Second, 50*time.Millisecond, "only the pod that is left should receive connections")
+}
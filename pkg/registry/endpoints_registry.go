@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// Endpoints are keyed like the Services they are named after
+const endpointsPrefix = "/registry/endpoints/"
+
+var (
+	ErrEndpointsNotFound   = fmt.Errorf("endpoints %w", ErrNotFound)
+	ErrListEndpointsFailed = errors.New("failed to list endpoints")
+)
+
+// EndpointsRegistry stores the Endpoints of the Services, which only the endpoints controller writes
+type EndpointsRegistry struct {
+	storage storage.Storage
+	mutex   sync.RWMutex
+}
+
+// NewEndpointsRegistry creates a new EndpointsRegistry
+func NewEndpointsRegistry(storage storage.Storage) *EndpointsRegistry {
+	return &EndpointsRegistry{storage: storage}
+}
+
+func (r *EndpointsRegistry) generateKey(namespace, name string) string {
+	return endpointsPrefix + namespace + "/" + name
+}
+
+// Get retrieves the Endpoints of a Service
+func (r *EndpointsRegistry) Get(ctx context.Context, namespace, name string) (*api.Endpoints, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	endpoints := &api.Endpoints{}
+	if err := r.storage.Get(ctx, r.generateKey(namespace, name), endpoints); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s/%s", ErrEndpointsNotFound, namespace, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get endpoints: %v", ErrInternal, err)
+		}
+	}
+	return endpoints, nil
+}
+
+// List retrieves the Endpoints of a namespace, or of all namespaces if namespace is empty, ordered by
+// namespace and name
+func (r *EndpointsRegistry) List(ctx context.Context, namespace string) ([]*api.Endpoints, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	endpoints, err := listOf[api.Endpoints](ctx, r.storage, namespacedPrefix(endpointsPrefix, namespace))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListEndpointsFailed, err)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return lessNamespaced(&endpoints[i].ObjectMeta, &endpoints[j].ObjectMeta)
+	})
+	return endpoints, nil
+}
+
+// Set stores the Endpoints of a Service, replacing the ones stored before
+func (r *EndpointsRegistry) Set(ctx context.Context, endpoints *api.Endpoints) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	endpoints.Namespace = api.NamespaceOf(&endpoints.ObjectMeta)
+	key := r.generateKey(endpoints.Namespace, endpoints.Name)
+	exists, err := r.storage.Exists(ctx, key)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: failed to get endpoints: %v", ErrInternal, err)
+	case !exists:
+		return r.storage.Create(ctx, key, endpoints)
+	default:
+		return r.storage.Update(ctx, key, endpoints)
+	}
+}
+
+// Delete removes the Endpoints of a Service, failing with ErrEndpointsNotFound if it has none
+func (r *EndpointsRegistry) Delete(ctx context.Context, namespace, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return deleteAs(ctx, r.storage, r.generateKey(namespace, name), fmt.Errorf("%w: %s/%s", ErrEndpointsNotFound, namespace, name))
+}
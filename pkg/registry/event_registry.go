@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+const eventPrefix = "/registry/events/"
+
+var (
+	ErrEventInvalid       = errors.New("invalid event")
+	ErrListEventsFailed   = errors.New("failed to list events")
+	ErrEventAlreadyExists = errors.New("event already exists")
+)
+
+// EventRegistry stores the events components record about objects. Repeats of an event are folded into the
+// event recorded first, so an object that keeps failing the same way has one event with a growing count
+// rather than one event per failure.
+type EventRegistry struct {
+	storage storage.Storage
+	// mutex serializes creates, so two repeats of an event cannot both create it
+	mutex sync.Mutex
+}
+
+// NewEventRegistry creates a new EventRegistry
+func NewEventRegistry(storage storage.Storage) *EventRegistry {
+	return &EventRegistry{storage: storage}
+}
+
+// CreateEvent stores a new event, filling in the timestamps and count of an event reported without them. An
+// event without a name is a repeat of an earlier one if it is about the same object, from the same source,
+// with the same type, reason and message: the earlier event then gets its count incremented and its last
+// timestamp moved to the repeat, and event is set to the result. A named event is stored as it is.
+func (r *EventRegistry) CreateEvent(ctx context.Context, event *api.Event) error {
+	if event.Type == "" {
+		event.Type = api.EventTypeNormal
+	}
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrEventInvalid, err)
+	}
+	if event.FirstTimestamp.IsZero() {
+		event.FirstTimestamp = time.Now().UTC()
+	}
+	if event.LastTimestamp.IsZero() {
+		event.LastTimestamp = event.FirstTimestamp
+	}
+	if event.Count == 0 {
+		event.Count = 1
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	named := event.Name != ""
+	if !named {
+		event.Name = repeatName(event)
+	}
+	key := generateKey(eventPrefix, event.Name)
+	existing := &api.Event{}
+	err := r.storage.Get(ctx, key, existing)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return r.storage.Create(ctx, key, event)
+	case err != nil:
+		return fmt.Errorf("%w: failed to get event: %v", ErrInternal, err)
+	case named:
+		return fmt.Errorf("%w: %s", ErrEventAlreadyExists, event.Name)
+	}
+
+	existing.Count += event.Count
+	if event.LastTimestamp.After(existing.LastTimestamp) {
+		existing.LastTimestamp = event.LastTimestamp
+	}
+	if err := r.storage.Update(ctx, key, existing); err != nil {
+		return err
+	}
+	*event = *existing
+	return nil
+}
+
+// repeatName names an event after its involved object and what makes it a repeat of another, so the repeats of
+// an event are stored under the same key
+func repeatName(event *api.Event) string {
+	hash := fnv.New64a()
+	for _, field := range []string{event.InvolvedObject.Kind, event.InvolvedObject.Name, event.InvolvedObject.UID,
+		event.Source, event.Type, event.Reason, event.Message} {
+		_, _ = hash.Write([]byte(field))
+		_, _ = hash.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s.%016x", event.InvolvedObject.Name, hash.Sum64())
+}
+
+// ListEvents retrieves the events about the given object; an empty kind or name matches any
+func (r *EventRegistry) ListEvents(ctx context.Context, kind, name string) ([]*api.Event, error) {
+	events, err := listOf[api.Event](ctx, r.storage, eventPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListEventsFailed, err)
+	}
+
+	filtered := make([]*api.Event, 0, len(events))
+	for _, event := range events {
+		if (kind == "" || event.InvolvedObject.Kind == kind) && (name == "" || event.InvolvedObject.Name == name) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered, nil
+}
+
+// DeleteExpired removes the events that last happened before the given time, returning how many it removed
+func (r *EventRegistry) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events, err := listOf[api.Event](ctx, r.storage, eventPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrListEventsFailed, err)
+	}
+	deleted := 0
+	for _, event := range events {
+		if !event.LastTimestamp.Before(before) {
+			continue
+		}
+		err := r.storage.Delete(ctx, generateKey(eventPrefix, event.Name))
+		if errors.Is(err, storage.ErrNotFound) {
+			// Collected by another process meanwhile
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete event %s: %w", event.Name, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func TestEventRegistry(t *testing.T) {
+	t.Run("should create and list events by involved object", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			eventRegistry := NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+
+			evicted := &api.Event{
+				InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web"},
+				Type:           api.EventTypeWarning,
+				Reason:         "Evicted",
+			}
+			require.NoError(t, eventRegistry.CreateEvent(ctx, evicted))
+			assert.Contains(t, evicted.Name, "web.")
+			assert.Equal(t, int32(1), evicted.Count)
+			assert.False(t, evicted.FirstTimestamp.IsZero())
+			assert.Equal(t, evicted.FirstTimestamp, evicted.LastTimestamp)
+
+			pressure := &api.Event{
+				InvolvedObject: api.ObjectReference{Kind: api.KindNode, Name: "node-1"},
+				Reason:         "NodeHasDiskPressure",
+			}
+			require.NoError(t, eventRegistry.CreateEvent(ctx, pressure))
+			assert.Equal(t, api.EventTypeNormal, pressure.Type)
+
+			all, err := eventRegistry.ListEvents(ctx, "", "")
+			require.NoError(t, err)
+			assert.Len(t, all, 2)
+
+			podEvents, err := eventRegistry.ListEvents(ctx, api.KindPod, "web")
+			require.NoError(t, err)
+			require.Len(t, podEvents, 1)
+			assert.Equal(t, "Evicted", podEvents[0].Reason)
+
+			none, err := eventRegistry.ListEvents(ctx, api.KindNode, "web")
+			require.NoError(t, err)
+			assert.Empty(t, none)
+		})
+	})
+
+	t.Run("should reject an event without a reason", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			eventRegistry := NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+
+			err := eventRegistry.CreateEvent(context.Background(), &api.Event{
+				InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web"},
+			})
+			assert.ErrorIs(t, err, ErrEventInvalid)
+		})
+	})
+
+	t.Run("should fold repeats into the count of the first event", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			eventRegistry := NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+			first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			backOff := func(at time.Time) *api.Event {
+				return &api.Event{
+					InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: "web", UID: "uid-1"},
+					Type:           api.EventTypeWarning,
+					Reason:         "BackOff",
+					Message:        "Back-off restarting failed container",
+					Source:         "kubelet/node-1",
+					FirstTimestamp: at,
+				}
+			}
+			for i := range 3 {
+				event := backOff(first.Add(time.Duration(i) * time.Minute))
+				require.NoError(t, eventRegistry.CreateEvent(ctx, event))
+				assert.Equal(t, int32(i+1), event.Count, "the created event reports the folded count")
+			}
+			other := backOff(first)
+			other.Reason = "Failed"
+			require.NoError(t, eventRegistry.CreateEvent(ctx, other))
+
+			events, err := eventRegistry.ListEvents(ctx, api.KindPod, "web")
+			require.NoError(t, err)
+			require.Len(t, events, 2, "repeats do not add keys")
+			byReason := map[string]*api.Event{events[0].Reason: events[0], events[1].Reason: events[1]}
+			assert.Equal(t, int32(3), byReason["BackOff"].Count)
+			assert.Equal(t, first, byReason["BackOff"].FirstTimestamp.UTC())
+			assert.Equal(t, first.Add(2*time.Minute), byReason["BackOff"].LastTimestamp.UTC())
+			assert.Equal(t, int32(1), byReason["Failed"].Count)
+		})
+	})
+
+	t.Run("should delete the events that last happened before the cutoff", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			eventRegistry := NewEventRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			for name, at := range map[string]time.Time{"old": now.Add(-2 * time.Hour), "recent": now.Add(-time.Minute)} {
+				require.NoError(t, eventRegistry.CreateEvent(ctx, &api.Event{
+					InvolvedObject: api.ObjectReference{Kind: api.KindPod, Name: name},
+					Reason:         "Started",
+					FirstTimestamp: at,
+				}))
+			}
+
+			deleted, err := eventRegistry.DeleteExpired(ctx, now.Add(-time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+			events, err := eventRegistry.ListEvents(ctx, "", "")
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "recent", events[0].InvolvedObject.Name)
+		})
+	})
+}
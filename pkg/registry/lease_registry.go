@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+const nodeLeasePrefix = "/registry/leases/nodes/"
+
+var (
+	ErrLeaseNotFound    = fmt.Errorf("lease %w", ErrNotFound)
+	ErrLeaseInvalid     = errors.New("invalid lease")
+	ErrListLeasesFailed = errors.New("failed to list leases")
+	// ErrLeaseHeld is returned when the lease is held by another holder that is still alive
+	ErrLeaseHeld = errors.New("lease is held by another holder")
+)
+
+// NodeLeaseRegistry stores the leases of the nodes, which tell the node controller that their kubelets are
+// alive. A lease is stored with a TTL of its duration and renewed by keeping the TTL alive, so renewals write
+// nothing to storage, and a lease that is not renewed disappears on its own.
+type NodeLeaseRegistry struct {
+	storage storage.Storage
+	// mutex guards held
+	mutex sync.Mutex
+	// held remembers the storage lease each node lease was stored with, to renew it
+	held map[string]storage.LeaseID
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+// NewNodeLeaseRegistry creates a new NodeLeaseRegistry. Renewing leases needs a storage.TTLStorage; with
+// another storage RenewNodeLease fails.
+func NewNodeLeaseRegistry(store storage.Storage) *NodeLeaseRegistry {
+	return &NodeLeaseRegistry{
+		storage: store,
+		held:    make(map[string]storage.LeaseID),
+		now:     time.Now,
+	}
+}
+
+// RenewNodeLease renews the lease of a node for its duration, acquiring it if it is not held yet or expired.
+// The lease is set to the stored one. A lease another holder keeps alive is not taken over: ErrLeaseHeld is
+// returned until it expires.
+func (r *NodeLeaseRegistry) RenewNodeLease(ctx context.Context, lease *api.Lease) error {
+	if err := lease.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrLeaseInvalid, err)
+	}
+	ttlStorage, ok := r.storage.(storage.TTLStorage)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrInternal, storage.ErrTTLUnsupported)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := generateKey(nodeLeasePrefix, lease.Name)
+	if id, ok := r.held[lease.Name]; ok {
+		err := ttlStorage.KeepAlive(ctx, id)
+		if err == nil {
+			stored := &api.Lease{}
+			if err := r.storage.Get(ctx, key, stored); err == nil && stored.HolderIdentity == lease.HolderIdentity {
+				*lease = *stored
+				return nil
+			}
+			// The lease was deleted, or is renewed by another holder, e.g. a new machine of the node
+		} else if !errors.Is(err, storage.ErrLeaseNotFound) {
+			return fmt.Errorf("%w: failed to renew lease: %v", ErrInternal, err)
+		}
+		delete(r.held, lease.Name)
+	}
+
+	lease.AcquireTime = r.now().UTC()
+	id, err := r.acquire(ctx, ttlStorage, key, lease)
+	switch {
+	case errors.Is(err, ErrLeaseHeld):
+		return err
+	case err != nil:
+		return fmt.Errorf("%w: failed to acquire lease: %v", ErrInternal, err)
+	}
+	r.held[lease.Name] = id
+	return nil
+}
+
+// acquire stores lease under key with a new storage lease. A lease stored before by the same holder, e.g. by an
+// earlier run of its kubelet, is taken over: it is removed as it was read and the lease stored again. A stored
+// lease is alive until it expires, so one of another holder is left alone and ErrLeaseHeld returned.
+func (r *NodeLeaseRegistry) acquire(ctx context.Context, ttlStorage storage.TTLStorage, key string, lease *api.Lease) (storage.LeaseID, error) {
+	for range conflictRetries {
+		id, err := ttlStorage.CreateWithTTL(ctx, key, lease, int64(lease.LeaseDurationSeconds))
+		if !errors.Is(err, storage.ErrKeyExists) {
+			return id, err
+		}
+		stored := &api.Lease{}
+		if err := r.storage.Get(ctx, key, stored); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				// The stored lease expired meanwhile
+				continue
+			}
+			return 0, err
+		}
+		if stored.HolderIdentity != lease.HolderIdentity {
+			return 0, fmt.Errorf("%w: %s is held by %s", ErrLeaseHeld, lease.Name, stored.HolderIdentity)
+		}
+		err = r.storage.Txn(ctx, []storage.TxnOp{storage.DeleteObjectOp(key, stored)})
+		if err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrConflict) {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", storage.ErrKeyExists, key)
+}
+
+// GetNodeLease retrieves the lease of the named node, which exists only while its kubelet renews it
+func (r *NodeLeaseRegistry) GetNodeLease(ctx context.Context, name string) (*api.Lease, error) {
+	lease := &api.Lease{}
+	if err := r.storage.Get(ctx, generateKey(nodeLeasePrefix, name), lease); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrLeaseNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get lease: %v", ErrInternal, err)
+		}
+	}
+	return lease, nil
+}
+
+// ListNodeLeases retrieves the leases of the nodes whose kubelets are alive
+func (r *NodeLeaseRegistry) ListNodeLeases(ctx context.Context) ([]*api.Lease, error) {
+	leases, err := listOf[api.Lease](ctx, r.storage, nodeLeasePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListLeasesFailed, err)
+	}
+	return leases, nil
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/mock/gomock"
+
+	mockStorage "gokube/mocks/pkg/storage"
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func TestNodeLeaseRegistry(t *testing.T) {
+	newLease := func(name, holder string) *api.Lease {
+		return &api.Lease{ObjectMeta: api.ObjectMeta{Name: name}, HolderIdentity: holder, LeaseDurationSeconds: api.MinLeaseDurationSeconds}
+	}
+
+	t.Run("should keep renewed leases and expire the others", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			leaseRegistry := NewNodeLeaseRegistry(storage.NewEtcdStorage(etcdServer))
+			acquired := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			leaseRegistry.now = func() time.Time { return acquired }
+			ctx := context.Background()
+
+			renewed := newLease("node-1", "machine-1")
+			require.NoError(t, leaseRegistry.RenewNodeLease(ctx, renewed))
+			assert.Equal(t, acquired, renewed.AcquireTime)
+			require.NoError(t, leaseRegistry.RenewNodeLease(ctx, newLease("node-2", "machine-2")))
+
+			leases, err := leaseRegistry.ListNodeLeases(ctx)
+			require.NoError(t, err)
+			assert.Len(t, leases, 2)
+
+			// Renewing a held lease keeps the lease acquired first
+			leaseRegistry.now = func() time.Time { return acquired.Add(time.Minute) }
+			require.Eventually(t, func() bool {
+				lease := newLease("node-1", "machine-1")
+				require.NoError(t, leaseRegistry.RenewNodeLease(ctx, lease))
+				require.Equal(t, acquired, lease.AcquireTime)
+				_, err := leaseRegistry.GetNodeLease(ctx, "node-2")
+				return err != nil
+			}, 10*time.Second, 200*time.Millisecond)
+			_, err = leaseRegistry.GetNodeLease(ctx, "node-2")
+			assert.ErrorIs(t, err, ErrLeaseNotFound)
+
+			leases, err = leaseRegistry.ListNodeLeases(ctx)
+			require.NoError(t, err)
+			require.Len(t, leases, 1)
+			assert.Equal(t, "machine-1", leases[0].HolderIdentity)
+
+			// An expired lease is acquired again
+			reacquired := newLease("node-2", "machine-2")
+			require.NoError(t, leaseRegistry.RenewNodeLease(ctx, reacquired))
+			assert.Equal(t, acquired.Add(time.Minute), reacquired.AcquireTime)
+		})
+	})
+
+	t.Run("should hand the lease to a new holder once it expired", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			leaseRegistry := NewNodeLeaseRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+
+			require.NoError(t, leaseRegistry.RenewNodeLease(ctx, newLease("node-1", "machine-1")))
+			err := leaseRegistry.RenewNodeLease(ctx, newLease("node-1", "machine-2"))
+			assert.ErrorIs(t, err, ErrLeaseHeld, "a lease that is alive is not taken from its holder")
+
+			// A restarted kubelet knows nothing of the lease it stored before, and takes it over
+			restarted := NewNodeLeaseRegistry(storage.NewEtcdStorage(etcdServer))
+			require.NoError(t, restarted.RenewNodeLease(ctx, newLease("node-1", "machine-1")))
+			require.NoError(t, restarted.RenewNodeLease(ctx, newLease("node-1", "machine-1")))
+			lease, err := restarted.GetNodeLease(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Equal(t, "machine-1", lease.HolderIdentity)
+
+			// Once machine-1 stops renewing, the lease expires and machine-2 acquires it
+			require.Eventually(t, func() bool {
+				return restarted.RenewNodeLease(ctx, newLease("node-1", "machine-2")) == nil
+			}, 10*time.Second, 200*time.Millisecond)
+			lease, err = restarted.GetNodeLease(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Equal(t, "machine-2", lease.HolderIdentity)
+		})
+	})
+
+	t.Run("should reject invalid leases", func(t *testing.T) {
+		leaseRegistry := NewNodeLeaseRegistry(mockStorage.NewMockStorage(gomock.NewController(t)))
+
+		err := leaseRegistry.RenewNodeLease(context.Background(), newLease("Node_1", "machine-1"))
+		assert.ErrorIs(t, err, ErrLeaseInvalid)
+		lease := newLease("node-1", "machine-1")
+		lease.LeaseDurationSeconds = 1
+		assert.ErrorIs(t, leaseRegistry.RenewNodeLease(context.Background(), lease), ErrLeaseInvalid)
+	})
+
+	t.Run("should fail without a storage that expires keys", func(t *testing.T) {
+		// The mock has no CreateWithTTL, and expects no call
+		leaseRegistry := NewNodeLeaseRegistry(mockStorage.NewMockStorage(gomock.NewController(t)))
+
+		err := leaseRegistry.RenewNodeLease(context.Background(), newLease("node-1", "machine-1"))
+		assert.ErrorIs(t, err, ErrInternal)
+	})
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// LimitRanges are keyed by namespace, like ResourceQuotas
+const limitRangePrefix = "/registry/limitranges/"
+
+var (
+	ErrLimitRangeNotFound      = fmt.Errorf("limit range %w", ErrNotFound)
+	ErrLimitRangeAlreadyExists = errors.New("limit range already exists")
+	ErrLimitRangeInvalid       = errors.New("invalid limit range")
+	ErrListLimitRangesFailed   = errors.New("failed to list limit ranges")
+)
+
+// LimitRangeRegistry stores the LimitRanges that default and bound the resources of containers
+type LimitRangeRegistry struct {
+	storage    storage.Storage
+	mutex      sync.RWMutex
+	namespaces *NamespaceRegistry
+}
+
+// NewLimitRangeRegistry creates a new LimitRangeRegistry
+func NewLimitRangeRegistry(storage storage.Storage) *LimitRangeRegistry {
+	return &LimitRangeRegistry{storage: storage}
+}
+
+// SetNamespaceRegistry makes Create reject limit ranges whose namespace does not exist or is terminating
+func (r *LimitRangeRegistry) SetNamespaceRegistry(namespaces *NamespaceRegistry) {
+	r.namespaces = namespaces
+}
+
+func (r *LimitRangeRegistry) generateKey(namespace, name string) string {
+	return limitRangePrefix + namespace + "/" + name
+}
+
+// Create stores a new LimitRange. A limit range without a namespace applies to the default namespace.
+func (r *LimitRangeRegistry) Create(ctx context.Context, limitRange *api.LimitRange) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	limitRange.Namespace = api.NamespaceOf(&limitRange.ObjectMeta)
+	if r.namespaces != nil {
+		if err := r.namespaces.CheckActive(ctx, limitRange.Namespace); err != nil {
+			return err
+		}
+	}
+	if err := limitRange.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrLimitRangeInvalid, err)
+	}
+	key := r.generateKey(limitRange.Namespace, limitRange.Name)
+
+	if limitRange.UID == "" {
+		limitRange.UID = uuid.NewString()
+	}
+	if err := r.storage.Create(ctx, key, limitRange); err != nil {
+		return existsAs(err, fmt.Errorf("%w: %s/%s", ErrLimitRangeAlreadyExists, limitRange.Namespace, limitRange.Name))
+	}
+	return nil
+}
+
+// Get retrieves a LimitRange
+func (r *LimitRangeRegistry) Get(ctx context.Context, namespace, name string) (*api.LimitRange, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	limitRange := &api.LimitRange{}
+	if err := r.storage.Get(ctx, r.generateKey(namespace, name), limitRange); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s/%s", ErrLimitRangeNotFound, namespace, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get limit range: %v", ErrInternal, err)
+		}
+	}
+	return limitRange, nil
+}
+
+// List retrieves the LimitRanges of a namespace in name order, which is the order their defaults apply in
+func (r *LimitRangeRegistry) List(ctx context.Context, namespace string) ([]*api.LimitRange, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	limitRanges, err := listOf[api.LimitRange](ctx, r.storage, limitRangePrefix+namespace+"/")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListLimitRangesFailed, err)
+	}
+	sort.Slice(limitRanges, func(i, j int) bool { return limitRanges[i].Name < limitRanges[j].Name })
+	return limitRanges, nil
+}
+
+// Delete removes a LimitRange. Pods created under it keep the resources it gave them.
+func (r *LimitRangeRegistry) Delete(ctx context.Context, namespace, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := r.generateKey(namespace, name)
+	return deleteAs(ctx, r.storage, key, fmt.Errorf("%w: %s/%s", ErrLimitRangeNotFound, namespace, name))
+}
+
+// DeleteNamespace removes the LimitRanges of a namespace
+func (r *LimitRangeRegistry) DeleteNamespace(ctx context.Context, namespace string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.storage.DeletePrefix(ctx, limitRangePrefix+namespace+"/")
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func TestLimitRangeRegistry_CreatePod(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		limitRangeRegistry := NewLimitRangeRegistry(store)
+		quotaRegistry := NewResourceQuotaRegistry(store)
+		podRegistry := NewPodRegistry(store)
+		podRegistry.SetLimitRangeRegistry(limitRangeRegistry)
+		podRegistry.SetResourceQuotaRegistry(quotaRegistry)
+		ctx := context.Background()
+
+		require.NoError(t, limitRangeRegistry.Create(ctx, &api.LimitRange{
+			ObjectMeta: api.ObjectMeta{Name: "limits", Namespace: "staging"},
+			Spec: api.LimitRangeSpec{
+				Default:        api.ResourceList{api.ResourceCPU: "500m"},
+				DefaultRequest: api.ResourceList{api.ResourceCPU: "250m"},
+				Min:            api.ResourceList{api.ResourceCPU: "100m"},
+			},
+		}))
+		require.NoError(t, quotaRegistry.Create(ctx, newQuota("staging", api.ResourceList{api.ResourceCPU: "1"})))
+
+		// The defaults are stored with the pod and count towards the quota
+		defaulted := newRequestingPod("web-1", "staging", "")
+		require.NoError(t, podRegistry.CreatePod(ctx, defaulted))
+		stored, err := podRegistry.GetPod(ctx, "staging", "web-1")
+		require.NoError(t, err)
+		assert.Equal(t, &api.ResourceRequirements{
+			Requests: api.ResourceList{api.ResourceCPU: "250m"},
+			Limits:   api.ResourceList{api.ResourceCPU: "500m"},
+		}, stored.Spec.Containers[0].Resources)
+		quota, err := quotaRegistry.Get(ctx, "staging", "compute")
+		require.NoError(t, err)
+		assert.Equal(t, "250m", quota.Status.Used[api.ResourceCPU])
+
+		tooSmall := newRequestingPod("web-2", "staging", "50m")
+		err = podRegistry.CreatePod(ctx, tooSmall)
+		assert.ErrorIs(t, err, ErrPodInvalid)
+		var fieldErrs api.FieldErrors
+		require.ErrorAs(t, err, &fieldErrs)
+		assert.Equal(t, api.FieldErrors{{
+			Field:   "spec.containers[0].resources.requests.cpu",
+			Message: "must be at least 100m under limit range limits, got 50m",
+		}}, fieldErrs)
+
+		// Pods of other namespaces are not defaulted
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-3", "", "")))
+		stored, err = podRegistry.GetPod(ctx, api.DefaultNamespace, "web-3")
+		require.NoError(t, err)
+		assert.Nil(t, stored.Spec.Containers[0].Resources)
+
+		require.NoError(t, limitRangeRegistry.Delete(ctx, "staging", "limits"))
+		assert.ErrorIs(t, limitRangeRegistry.Delete(ctx, "staging", "limits"), ErrLimitRangeNotFound)
+		require.NoError(t, podRegistry.CreatePod(ctx, tooSmall))
+	})
+}
+
+func TestLimitRangeRegistry_Create(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		limitRangeRegistry := NewLimitRangeRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+
+		limitRange := &api.LimitRange{ObjectMeta: api.ObjectMeta{Name: "limits"}, Spec: api.LimitRangeSpec{Max: api.ResourceList{api.ResourceMemory: "1Gi"}}}
+		require.NoError(t, limitRangeRegistry.Create(ctx, limitRange))
+		assert.Equal(t, api.DefaultNamespace, limitRange.Namespace)
+		assert.ErrorIs(t, limitRangeRegistry.Create(ctx, limitRange), ErrLimitRangeAlreadyExists)
+
+		invalid := &api.LimitRange{ObjectMeta: api.ObjectMeta{Name: "other"}, Spec: api.LimitRangeSpec{Min: api.ResourceList{api.ResourceMemory: "lots"}}}
+		assert.ErrorIs(t, limitRangeRegistry.Create(ctx, invalid), ErrLimitRangeInvalid)
+
+		got, err := limitRangeRegistry.Get(ctx, api.DefaultNamespace, "limits")
+		require.NoError(t, err)
+		assert.Equal(t, limitRange.UID, got.UID)
+		_, err = limitRangeRegistry.Get(ctx, "staging", "limits")
+		assert.ErrorIs(t, err, ErrLimitRangeNotFound)
+	})
+}
@@ -0,0 +1,12 @@
+package registry
+
+import (
+	"os"
+	"testing"
+
+	"gokube/pkg/storage"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(storage.RunWithSharedEtcd(m))
+}
@@ -17,16 +17,26 @@ limitations under the License.
 package names
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"sync"
 	"time"
 )
 
-var rng = struct {
+// ErrNameExhausted is returned when every name generated for a base was already taken
+var ErrNameExhausted = errors.New("failed to generate a name that is not taken")
+
+// MaxGenerateAttempts is how many names GenerateNameWithCheck tries before giving up
+const MaxGenerateAttempts = 10
+
+// lockedRand is a random source that is safe for concurrent use
+type lockedRand struct {
 	sync.Mutex
 	rand *rand.Rand
-}{
+}
+
+var rng = &lockedRand{
 	rand: rand.New(rand.NewSource(time.Now().UnixNano())),
 }
 
@@ -40,12 +50,20 @@ type NameGenerator interface {
 }
 
 // simpleNameGenerator generates random names.
-type simpleNameGenerator struct{}
+type simpleNameGenerator struct {
+	rng *lockedRand
+}
 
 // SimpleNameGenerator is a generator that returns the name plus a random suffix of five alphanumerics
 // when a name is requested. The string is guaranteed to not exceed the length of a standard Kubernetes
 // name (63 characters)
-var SimpleNameGenerator NameGenerator = simpleNameGenerator{}
+var SimpleNameGenerator NameGenerator = simpleNameGenerator{rng: rng}
+
+// NewSimpleNameGenerator returns a generator like SimpleNameGenerator that draws its suffixes from source,
+// e.g. a seeded one for reproducible names in tests. The generator guards source, so it may be shared.
+func NewSimpleNameGenerator(source rand.Source) NameGenerator {
+	return simpleNameGenerator{rng: &lockedRand{rand: rand.New(source)}}
+}
 
 const (
 	// TODO: make this flexible for non-core resources with alternate naming rules.
@@ -54,11 +72,27 @@ const (
 	MaxGeneratedNameLength = maxNameLength - randomLength
 )
 
-func (simpleNameGenerator) GenerateName(base string) string {
+func (g simpleNameGenerator) GenerateName(base string) string {
 	if len(base) > MaxGeneratedNameLength {
 		base = base[:MaxGeneratedNameLength]
 	}
-	return fmt.Sprintf("%s%s", base, String(randomLength))
+	return fmt.Sprintf("%s%s", base, g.rng.String(randomLength))
+}
+
+// GenerateNameWithCheck generates names from base with SimpleNameGenerator until one is not taken, as
+// reported by exists. It gives up with ErrNameExhausted after MaxGenerateAttempts names.
+func GenerateNameWithCheck(base string, exists func(name string) bool) (string, error) {
+	return GenerateUniqueName(SimpleNameGenerator, base, exists)
+}
+
+// GenerateUniqueName is GenerateNameWithCheck with the given generator
+func GenerateUniqueName(generator NameGenerator, base string, exists func(name string) bool) (string, error) {
+	for range MaxGenerateAttempts {
+		if name := generator.GenerateName(base); !exists(name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %d names generated from %q were taken", ErrNameExhausted, MaxGenerateAttempts, base)
 }
 
 const (
@@ -80,15 +114,20 @@ const (
 // - from each int63, we are extracting multiple random letters by bit-shifting and masking
 // - if some index is out of range of alphanums we neglect it (unlikely to happen multiple times in a row)
 func String(n int) string {
+	return rng.String(n)
+}
+
+// String generates a random string like the String function, from r
+func (r *lockedRand) String(n int) string {
 	b := make([]byte, n)
-	rng.Lock()
-	defer rng.Unlock()
+	r.Lock()
+	defer r.Unlock()
 
-	randomInt63 := rng.rand.Int63()
+	randomInt63 := r.rand.Int63()
 	remaining := maxAlphanumsPerInt
 	for i := 0; i < n; {
 		if remaining == 0 {
-			randomInt63, remaining = rng.rand.Int63(), maxAlphanumsPerInt
+			randomInt63, remaining = r.rand.Int63(), maxAlphanumsPerInt
 		}
 		if idx := int(randomInt63 & alphanumsIdxMask); idx < len(alphanums) {
 			b[i] = alphanums[idx]
@@ -17,10 +17,12 @@ limitations under the License.
 package names
 
 import (
+	"math/rand"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSimpleNameGenerator(t *testing.T) {
@@ -52,6 +54,48 @@ func TestSimpleNameGenerator(t *testing.T) {
 	})
 }
 
+func TestNewSimpleNameGenerator(t *testing.T) {
+	t.Run("SeededGeneratorIsReproducible", func(t *testing.T) {
+		first := NewSimpleNameGenerator(rand.NewSource(42))
+		second := NewSimpleNameGenerator(rand.NewSource(42))
+
+		for range 3 {
+			name := first.GenerateName("web-")
+			assert.Equal(t, name, second.GenerateName("web-"))
+			assert.True(t, strings.HasPrefix(name, "web-"))
+		}
+		assert.NotEqual(t, first.GenerateName("web-"), NewSimpleNameGenerator(rand.NewSource(7)).GenerateName("web-"))
+	})
+}
+
+func TestGenerateUniqueName(t *testing.T) {
+	t.Run("RetriesOnCollision", func(t *testing.T) {
+		taken := NewSimpleNameGenerator(rand.NewSource(42)).GenerateName("web-")
+		var checked []string
+		exists := func(name string) bool {
+			checked = append(checked, name)
+			return name == taken
+		}
+
+		name, err := GenerateUniqueName(NewSimpleNameGenerator(rand.NewSource(42)), "web-", exists)
+
+		require.NoError(t, err)
+		assert.NotEqual(t, taken, name)
+		assert.Equal(t, []string{taken, name}, checked)
+	})
+
+	t.Run("GivesUpWhenEveryNameIsTaken", func(t *testing.T) {
+		attempts := 0
+		_, err := GenerateNameWithCheck("web-", func(string) bool {
+			attempts++
+			return true
+		})
+
+		assert.ErrorIs(t, err, ErrNameExhausted)
+		assert.Equal(t, MaxGenerateAttempts, attempts)
+	})
+}
+
 func TestString(t *testing.T) {
 	t.Run("GeneratesStringOfCorrectLength", func(t *testing.T) {
 		length := 10
@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+const namespacePrefix = "/registry/namespaces/"
+
+var (
+	ErrNamespaceNotFound      = fmt.Errorf("namespace %w", ErrNotFound)
+	ErrNamespaceAlreadyExists = errors.New("namespace already exists")
+	ErrListNamespacesFailed   = errors.New("failed to list namespaces")
+	ErrNamespaceInvalid       = errors.New("invalid namespace")
+	// ErrNamespaceTerminating is returned when an object is created in a namespace that is being deleted
+	ErrNamespaceTerminating = errors.New("namespace is terminating")
+	// ErrNamespaceProtected is returned when the default namespace is deleted
+	ErrNamespaceProtected = errors.New("namespace cannot be deleted")
+	// ErrNamespaceNotTerminating is returned when a namespace is finalized before it was deleted
+	ErrNamespaceNotTerminating = errors.New("namespace is not terminating")
+)
+
+// NamespaceRegistry stores namespaces and tells the other registries which namespaces accept new objects
+type NamespaceRegistry struct {
+	storage storage.Storage
+	mutex   sync.RWMutex
+}
+
+// NewNamespaceRegistry creates a new NamespaceRegistry
+func NewNamespaceRegistry(storage storage.Storage) *NamespaceRegistry {
+	return &NamespaceRegistry{storage: storage}
+}
+
+// Create stores a new, active namespace. A namespace without a UID is given a new one.
+func (r *NamespaceRegistry) Create(ctx context.Context, namespace *api.Namespace) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := namespace.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrNamespaceInvalid, err)
+	}
+	key := generateKey(namespacePrefix, namespace.Name)
+
+	if namespace.UID == "" {
+		namespace.UID = uuid.NewString()
+	}
+	namespace.Status.Phase = api.NamespaceActive
+	if err := r.storage.Create(ctx, key, namespace); err != nil {
+		return existsAs(err, fmt.Errorf("%w: %s", ErrNamespaceAlreadyExists, namespace.Name))
+	}
+	return nil
+}
+
+// EnsureDefault creates the default namespace unless it exists
+func (r *NamespaceRegistry) EnsureDefault(ctx context.Context) error {
+	err := r.Create(ctx, &api.Namespace{ObjectMeta: api.ObjectMeta{Name: api.DefaultNamespace}})
+	if errors.Is(err, ErrNamespaceAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// Get retrieves a namespace by name
+func (r *NamespaceRegistry) Get(ctx context.Context, name string) (*api.Namespace, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.get(ctx, name)
+}
+
+func (r *NamespaceRegistry) get(ctx context.Context, name string) (*api.Namespace, error) {
+	namespace := &api.Namespace{}
+	if err := r.storage.Get(ctx, generateKey(namespacePrefix, name), namespace); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s", ErrNamespaceNotFound, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get namespace: %v", ErrInternal, err)
+		}
+	}
+	return namespace, nil
+}
+
+// List retrieves all namespaces
+func (r *NamespaceRegistry) List(ctx context.Context) ([]*api.Namespace, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	namespaces, err := listOf[api.Namespace](ctx, r.storage, namespacePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListNamespacesFailed, err)
+	}
+	return namespaces, nil
+}
+
+// Delete marks a namespace as terminating and returns it. The namespace controller deletes its objects and
+// then finalizes it. Deleting a terminating namespace again changes nothing. The default namespace cannot
+// be deleted.
+func (r *NamespaceRegistry) Delete(ctx context.Context, name string) (*api.Namespace, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if name == api.DefaultNamespace {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceProtected, name)
+	}
+	namespace, err := r.get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if namespace.IsTerminating() {
+		return namespace, nil
+	}
+	namespace.Status.Phase = api.NamespaceTerminating
+	if err := r.storage.Update(ctx, generateKey(namespacePrefix, name), namespace); err != nil {
+		return nil, err
+	}
+	return namespace, nil
+}
+
+// Finalize removes a terminating namespace once its objects are deleted
+func (r *NamespaceRegistry) Finalize(ctx context.Context, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	namespace, err := r.get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !namespace.IsTerminating() {
+		return fmt.Errorf("%w: %s", ErrNamespaceNotTerminating, name)
+	}
+	return deleteAs(ctx, r.storage, generateKey(namespacePrefix, name), fmt.Errorf("%w: %s", ErrNamespaceNotFound, name))
+}
+
+// CheckActive checks that new objects can be created in a namespace, i.e. it exists and is not terminating.
+// An empty namespace is the default namespace, which is always active.
+func (r *NamespaceRegistry) CheckActive(ctx context.Context, name string) error {
+	if name == "" || name == api.DefaultNamespace {
+		return nil
+	}
+	namespace, err := r.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if namespace.IsTerminating() {
+		return fmt.Errorf("%w: %s", ErrNamespaceTerminating, name)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func TestNamespaceRegistry(t *testing.T) {
+	t.Run("should create, terminate and finalize a namespace", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			namespaceRegistry := NewNamespaceRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+
+			staging := &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "staging"}}
+			require.NoError(t, namespaceRegistry.Create(ctx, staging))
+			assert.NotEmpty(t, staging.UID)
+			assert.Equal(t, api.NamespaceActive, staging.Status.Phase)
+			assert.ErrorIs(t, namespaceRegistry.Create(ctx, staging), ErrNamespaceAlreadyExists)
+			require.NoError(t, namespaceRegistry.CheckActive(ctx, "staging"))
+
+			assert.ErrorIs(t, namespaceRegistry.Finalize(ctx, "staging"), ErrNamespaceNotTerminating)
+			deleted, err := namespaceRegistry.Delete(ctx, "staging")
+			require.NoError(t, err)
+			assert.Equal(t, api.NamespaceTerminating, deleted.Status.Phase)
+			assert.ErrorIs(t, namespaceRegistry.CheckActive(ctx, "staging"), ErrNamespaceTerminating)
+
+			// Deleting again is a no-op
+			deleted, err = namespaceRegistry.Delete(ctx, "staging")
+			require.NoError(t, err)
+			assert.True(t, deleted.IsTerminating())
+
+			require.NoError(t, namespaceRegistry.Finalize(ctx, "staging"))
+			_, err = namespaceRegistry.Get(ctx, "staging")
+			assert.ErrorIs(t, err, ErrNamespaceNotFound)
+			assert.ErrorIs(t, namespaceRegistry.CheckActive(ctx, "staging"), ErrNamespaceNotFound)
+		})
+	})
+
+	t.Run("should keep the default namespace", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			namespaceRegistry := NewNamespaceRegistry(storage.NewEtcdStorage(etcdServer))
+			ctx := context.Background()
+
+			require.NoError(t, namespaceRegistry.EnsureDefault(ctx))
+			require.NoError(t, namespaceRegistry.EnsureDefault(ctx))
+			namespaces, err := namespaceRegistry.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, namespaces, 1)
+			assert.Equal(t, api.DefaultNamespace, namespaces[0].Name)
+
+			_, err = namespaceRegistry.Delete(ctx, api.DefaultNamespace)
+			assert.ErrorIs(t, err, ErrNamespaceProtected)
+			assert.NoError(t, namespaceRegistry.CheckActive(ctx, ""))
+		})
+	})
+
+	t.Run("should reject invalid names", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			namespaceRegistry := NewNamespaceRegistry(storage.NewEtcdStorage(etcdServer))
+
+			for _, name := range []string{"", "Staging", "team.staging", "-staging"} {
+				err := namespaceRegistry.Create(context.Background(), &api.Namespace{ObjectMeta: api.ObjectMeta{Name: name}})
+				assert.ErrorIs(t, err, ErrNamespaceInvalid, name)
+			}
+		})
+	})
+}
+
+func TestPodRegistry_CreatePodInNamespace(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		namespaceRegistry := NewNamespaceRegistry(store)
+		podRegistry := NewPodRegistry(store)
+		podRegistry.SetNamespaceRegistry(namespaceRegistry)
+		ctx := context.Background()
+
+		pod := newBatchTestPod("web")
+		pod.Namespace = "staging"
+		assert.ErrorIs(t, podRegistry.CreatePod(ctx, pod), ErrNamespaceNotFound)
+
+		require.NoError(t, namespaceRegistry.Create(ctx, &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "staging"}}))
+		require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		require.NoError(t, podRegistry.CreatePod(ctx, newBatchTestPod("default-web")))
+
+		_, err := namespaceRegistry.Delete(ctx, "staging")
+		require.NoError(t, err)
+		late := newBatchTestPod("late")
+		late.Namespace = "staging"
+		assert.ErrorIs(t, podRegistry.CreatePod(ctx, late), ErrNamespaceTerminating)
+	})
+}
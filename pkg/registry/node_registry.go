@@ -15,10 +15,13 @@ const (
 )
 
 var (
-	ErrNodeNotFound      = errors.New("node not found")
+	ErrNodeNotFound      = fmt.Errorf("node %w", ErrNotFound)
 	ErrNodeAlreadyExists = errors.New("node already exists")
 	ErrListNodesFailed   = errors.New("failed to list nodes")
 	ErrNodeInvalid       = errors.New("invalid node")
+	// ErrNodeConflict is returned for an update of a node that was changed since the resource version it
+	// carries, or a status update from another machine than the one the node belongs to
+	ErrNodeConflict = errors.New("node update conflicts with the current node")
 )
 
 // NodeRegistry provides CRUD operations for Node objects
@@ -36,21 +39,21 @@ func generateKey(prefix, name string) string {
 	return path.Join(prefix, name)
 }
 
-// CreateNode stores a new Node
+// CreateNode stores a new Node with the time it is created at. A node keeps the UID its kubelet registers it
+// with, the ID of its machine, and is given a new one without it.
 func (r *NodeRegistry) CreateNode(ctx context.Context, node *api.Node) error {
 	key := generateKey(nodePrefix, node.Name)
-	existingNode := &api.Node{}
-
-	if err := r.storage.Get(ctx, key, existingNode); err == nil {
-		return fmt.Errorf("%w: %s", ErrNodeAlreadyExists, node.Name)
-	}
 
 	// Validate Node spec
 	if err := node.Validate(); err != nil {
-		return fmt.Errorf("%w: %v", ErrNodeInvalid, err)
+		return fmt.Errorf("%w: %w", ErrNodeInvalid, err)
 	}
+	stampCreated(&node.ObjectMeta)
 
-	return r.storage.Create(ctx, key, node)
+	if err := r.storage.Create(ctx, key, node); err != nil {
+		return existsAs(err, fmt.Errorf("%w: %s", ErrNodeAlreadyExists, node.Name))
+	}
+	return nil
 }
 
 // GetNode retrieves a Node by name
@@ -70,31 +73,103 @@ func (r *NodeRegistry) GetNode(ctx context.Context, name string) (*api.Node, err
 	return node, nil
 }
 
-// UpdateNode updates an existing Node
+// GetNodes retrieves the named Nodes, reading up to MaxConcurrentGets of them from storage at once. The
+// names of the Nodes that do not exist are returned as missing rather than failing the call.
+func (r *NodeRegistry) GetNodes(ctx context.Context, names []string) (map[string]*api.Node, []string, error) {
+	return getAll(ctx, names, r.GetNode, ErrNodeNotFound)
+}
+
+// UpdateNode updates an existing Node. It returns ErrNodeNotFound if the Node does not exist or is deleted
+// meanwhile, rather than creating it. A node updated without a UID or creation time keeps the one it has.
 func (r *NodeRegistry) UpdateNode(ctx context.Context, node *api.Node) error {
 	key := generateKey(nodePrefix, node.Name)
 
 	// Validate Node spec
 	if err := node.Validate(); err != nil {
-		return fmt.Errorf("%w: %v", ErrNodeInvalid, err)
+		return fmt.Errorf("%w: %w", ErrNodeInvalid, err)
+	}
+	existing, err := r.GetNode(ctx, node.Name)
+	if err != nil {
+		return err
 	}
+	keepCreated(&node.ObjectMeta, &existing.ObjectMeta)
 
-	return r.storage.Update(ctx, key, node)
+	return replace(ctx, r.storage, key, node, fmt.Errorf("%w: %s", ErrNodeNotFound, node.Name), ErrNodeConflict)
+}
+
+// UpdateNodeStatus replaces the status fields of an existing Node, leaving its spec and metadata untouched.
+// It returns ErrNodeConflict if the update names another UID than the node's, i.e. it comes from the kubelet of
+// another machine, such as the previous owner of a node that was taken over.
+func (r *NodeRegistry) UpdateNodeStatus(ctx context.Context, node *api.Node) error {
+	existingNode, err := r.GetNode(ctx, node.Name)
+	if err != nil {
+		return err
+	}
+	if node.UID != "" && existingNode.UID != "" && node.UID != existingNode.UID {
+		return fmt.Errorf("%w: node %s belongs to another machine, UID %s, not %s", ErrNodeConflict, node.Name, existingNode.UID, node.UID)
+	}
+
+	existingNode.Status = node.Status
+	existingNode.LastHeartbeatTime = node.LastHeartbeatTime
+	existingNode.Capacity = node.Capacity
+	existingNode.Conditions = node.Conditions
+	existingNode.Address = node.Address
+
+	key := generateKey(nodePrefix, node.Name)
+	return conflictAs(r.storage.Update(ctx, key, existingNode), ErrNodeConflict)
 }
 
-// DeleteNode removes a Node by name
+// DeleteNode removes a Node by name. It returns ErrNodeNotFound if there is no such Node, which IgnoreNotFound
+// turns into success.
 func (r *NodeRegistry) DeleteNode(ctx context.Context, name string) error {
 	key := generateKey(nodePrefix, name)
-	return r.storage.Delete(ctx, key)
+	return deleteAs(ctx, r.storage, key, fmt.Errorf("%w: %s", ErrNodeNotFound, name))
 }
 
 // ListNodes retrieves all Nodes
 func (r *NodeRegistry) ListNodes(ctx context.Context) ([]*api.Node, error) {
-	nodes := make([]*api.Node, 0)
-
-	if err := r.storage.List(ctx, nodePrefix, &nodes); err != nil {
+	nodes, err := listOf[api.Node](ctx, r.storage, nodePrefix)
+	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrListNodesFailed, err)
 	}
 
 	return nodes, nil
 }
+
+// ListNodesWithRevision is ListNodes that also returns the revision the Nodes were read at, for WatchNodes to
+// follow their changes from without missing any
+func (r *NodeRegistry) ListNodesWithRevision(ctx context.Context) ([]*api.Node, int64, error) {
+	nodes, revision, err := listWithRevisionOf[api.Node](ctx, r.storage, nodePrefix)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListNodesFailed, err)
+	}
+	return nodes, revision, nil
+}
+
+// ListNodeNames returns the names of all Nodes in name order, without reading the Nodes
+func (r *NodeRegistry) ListNodeNames(ctx context.Context) ([]string, error) {
+	names, err := listNames(ctx, r.storage, nodePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListNodesFailed, err)
+	}
+	return names, nil
+}
+
+// NodeEvent is a change of a Node. For api.EventDeleted, Node is the Node as it was when deleted.
+type NodeEvent struct {
+	Type api.EventType
+	Node *api.Node
+}
+
+// WatchNodes follows the changes of the Nodes made after revision, that of ListNodesWithRevision, or from now
+// on for 0. The channel is closed when ctx is done or the watch ends, after which the caller lists the Nodes
+// again and watches anew.
+func (r *NodeRegistry) WatchNodes(ctx context.Context, revision int64) (<-chan NodeEvent, error) {
+	events, err := watchOf(ctx, r.storage, nodePrefix, revision, func(eventType api.EventType, node *api.Node) NodeEvent {
+		return NodeEvent{Type: eventType, Node: node}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to watch nodes: %v", ErrInternal, err)
+	}
+	return events, nil
+}
@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/mock/gomock"
 
 	mockStorage "gokube/mocks/pkg/storage"
@@ -17,86 +17,108 @@ import (
 )
 
 func TestNewNodeRegistry(t *testing.T) {
-	etcdStorage := storage.NewEtcdStorage(nil)
-	nodeRegistry := NewNodeRegistry(etcdStorage)
+	memoryStorage := storage.NewMemoryStorage()
+	nodeRegistry := NewNodeRegistry(memoryStorage)
 
 	assert.NotNil(t, nodeRegistry)
-	assert.Equal(t, etcdStorage, nodeRegistry.storage)
+	assert.Equal(t, memoryStorage, nodeRegistry.storage)
 }
 
 func TestNodeRegistry_CreateNode(t *testing.T) {
 	t.Run("should create node", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			node := createTestNode("test-node-1", "123")
-
-			err := nodeRegistry.CreateNode(context.Background(), node)
-			assert.NoError(t, err)
-
-			// Verify node was created
-			retrievedNode, err := nodeRegistry.GetNode(context.Background(), "test-node-1")
-			require.NoError(t, err)
-			assert.Equal(t, "test-node-1", retrievedNode.Name)
-			assert.Equal(t, "123", retrievedNode.UID)
-		})
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		node := createTestNode("test-node-1", "123")
+
+		err := nodeRegistry.CreateNode(context.Background(), node)
+		assert.NoError(t, err)
+
+		// Verify node was created
+		retrievedNode, err := nodeRegistry.GetNode(context.Background(), "test-node-1")
+		require.NoError(t, err)
+		assert.Equal(t, "test-node-1", retrievedNode.Name)
+		assert.Equal(t, "123", retrievedNode.UID, "the node keeps the machine ID of its kubelet")
+		assert.WithinDuration(t, time.Now(), retrievedNode.CreationTimestamp, time.Minute)
+
+		// An update without the creation time keeps it
+		update := createTestNode("test-node-1", "123")
+		update.Status = api.NodeNotReady
+		require.NoError(t, nodeRegistry.UpdateNode(context.Background(), update))
+		updatedNode, err := nodeRegistry.GetNode(context.Background(), "test-node-1")
+		require.NoError(t, err)
+		assert.True(t, retrievedNode.CreationTimestamp.Equal(updatedNode.CreationTimestamp))
 	})
 
 	t.Run("should fail to create node with the same name", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			node := createTestNode("duplicate-node", "123")
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		node := createTestNode("duplicate-node", "123")
 
-			err := nodeRegistry.CreateNode(context.Background(), node)
-			require.NoError(t, err)
+		err := nodeRegistry.CreateNode(context.Background(), node)
+		require.NoError(t, err)
 
-			// Attempt to create another node with the same name
-			err = nodeRegistry.CreateNode(context.Background(), node)
-			assert.ErrorIs(t, err, ErrNodeAlreadyExists)
-		})
+		// Attempt to create another node with the same name
+		err = nodeRegistry.CreateNode(context.Background(), node)
+		assert.ErrorIs(t, err, ErrNodeAlreadyExists)
 	})
 
 	t.Run("should fail to create invalid node", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			node := createTestNode("", "123") // Invalid node with empty name
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		node := createTestNode("", "123") // Invalid node with empty name
 
-			err := nodeRegistry.CreateNode(context.Background(), node)
-			assert.ErrorIs(t, err, ErrNodeInvalid)
-		})
+		err := nodeRegistry.CreateNode(context.Background(), node)
+		assert.ErrorIs(t, err, ErrNodeInvalid)
 	})
 }
 
+func TestNodeRegistry_WatchNodes(t *testing.T) {
+	nodeRegistry := NewNodeRegistry(storage.NewMemoryStorage())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := nodeRegistry.WatchNodes(ctx, 0)
+	require.NoError(t, err)
+
+	node := createTestNode("node-1", "123")
+	require.NoError(t, nodeRegistry.CreateNode(ctx, node))
+	node.Status = api.NodeNotReady
+	require.NoError(t, nodeRegistry.UpdateNode(ctx, node))
+	require.NoError(t, nodeRegistry.DeleteNode(ctx, "node-1"))
+
+	var got []NodeEvent
+	for range 3 {
+		got = append(got, nextEvent(t, ctx, events))
+	}
+	assert.Equal(t, []api.EventType{api.EventAdded, api.EventModified, api.EventDeleted}, []api.EventType{got[0].Type, got[1].Type, got[2].Type})
+	assert.Equal(t, "123", got[0].Node.UID)
+	assert.Equal(t, api.NodeNotReady, got[1].Node.Status)
+	assert.Equal(t, api.NodeNotReady, got[2].Node.Status)
+}
+
 func TestNodeRegistry_GetNode(t *testing.T) {
 	t.Run("should return node if it exists", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeName := "test-node-2"
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			ctx := context.Background()
-
-			createTestNodeInRegistry(t, nodeRegistry, nodeName, "456")
-
-			node, err := nodeRegistry.GetNode(ctx, nodeName)
-			assert.NoError(t, err)
-			assert.NotNil(t, node)
-			assert.Equal(t, nodeName, node.Name)
-			assert.Equal(t, "456", node.UID)
-			assert.False(t, node.Spec.Unschedulable)
-		})
+		memoryStorage := storage.NewMemoryStorage()
+		nodeName := "test-node-2"
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		ctx := context.Background()
+
+		createTestNodeInRegistry(t, nodeRegistry, nodeName, "456")
+
+		node, err := nodeRegistry.GetNode(ctx, nodeName)
+		assert.NoError(t, err)
+		assert.NotNil(t, node)
+		assert.Equal(t, nodeName, node.Name)
+		assert.Equal(t, "456", node.UID)
+		assert.False(t, node.Spec.Unschedulable)
 	})
 
 	t.Run("should return error if node does not exist", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		ctx := context.Background()
 
-			_, err := nodeRegistry.GetNode(ctx, "non-existent-node")
-			assert.Errorf(t, err, "node non-existent-node not found")
-		})
+		_, err := nodeRegistry.GetNode(ctx, "non-existent-node")
+		assert.Errorf(t, err, "node non-existent-node not found")
 	})
 
 	t.Run("should return ErrInternal on storage error", func(t *testing.T) {
@@ -116,62 +138,138 @@ func TestNodeRegistry_GetNode(t *testing.T) {
 
 func TestNodeRegistry_UpdateNode(t *testing.T) {
 	t.Run("should update node", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			nodeName := "test-node-3"
-			createTestNodeInRegistry(t, nodeRegistry, nodeName, "789")
-
-			node, err := nodeRegistry.GetNode(context.Background(), nodeName)
-			require.NoError(t, err)
-
-			node.Spec.Unschedulable = true
-			err = nodeRegistry.UpdateNode(context.Background(), node)
-			assert.NoError(t, err)
-
-			updatedNode, err := nodeRegistry.GetNode(context.Background(), nodeName)
-			assert.NoError(t, err)
-			assert.True(t, updatedNode.Spec.Unschedulable)
-		})
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		nodeName := "test-node-3"
+		createTestNodeInRegistry(t, nodeRegistry, nodeName, "789")
+
+		node, err := nodeRegistry.GetNode(context.Background(), nodeName)
+		require.NoError(t, err)
+
+		node.Spec.Unschedulable = true
+		err = nodeRegistry.UpdateNode(context.Background(), node)
+		assert.NoError(t, err)
+
+		updatedNode, err := nodeRegistry.GetNode(context.Background(), nodeName)
+		assert.NoError(t, err)
+		assert.True(t, updatedNode.Spec.Unschedulable)
 	})
 
 	t.Run("should fail to update invalid node", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			nodeName := "test-node-3"
-			createTestNodeInRegistry(t, nodeRegistry, nodeName, "789")
-
-			node, err := nodeRegistry.GetNode(context.Background(), nodeName)
-			require.NoError(t, err)
-
-			node.Name = "" // Invalid node with empty name
-			err = nodeRegistry.UpdateNode(context.Background(), node)
-			assert.ErrorIs(t, err, ErrNodeInvalid)
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		nodeName := "test-node-3"
+		createTestNodeInRegistry(t, nodeRegistry, nodeName, "789")
+
+		node, err := nodeRegistry.GetNode(context.Background(), nodeName)
+		require.NoError(t, err)
+
+		node.Name = "" // Invalid node with empty name
+		err = nodeRegistry.UpdateNode(context.Background(), node)
+		assert.ErrorIs(t, err, ErrNodeInvalid)
+	})
+
+	t.Run("should not create a missing or deleted node", func(t *testing.T) {
+		ctx := context.Background()
+		nodeRegistry := NewNodeRegistry(storage.NewMemoryStorage())
+
+		err := nodeRegistry.UpdateNode(ctx, createTestNode("never-created", "123"))
+		assert.ErrorIs(t, err, ErrNodeNotFound)
+
+		createTestNodeInRegistry(t, nodeRegistry, "deleted", "456")
+		node, err := nodeRegistry.GetNode(ctx, "deleted")
+		require.NoError(t, err)
+		require.NoError(t, nodeRegistry.DeleteNode(ctx, "deleted"))
+		node.ResourceVersion = ""
+		assert.ErrorIs(t, nodeRegistry.UpdateNode(ctx, node), ErrNodeNotFound)
+
+		_, err = nodeRegistry.GetNode(ctx, "deleted")
+		assert.ErrorIs(t, err, ErrNodeNotFound)
+	})
+}
+
+func TestNodeRegistry_UpdateNodeStatus(t *testing.T) {
+	t.Run("should update status without touching the spec", func(t *testing.T) {
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		nodeName := "test-node-4"
+		createTestNodeInRegistry(t, nodeRegistry, nodeName, "101")
+
+		node, err := nodeRegistry.GetNode(context.Background(), nodeName)
+		require.NoError(t, err)
+		node.Spec.Unschedulable = true
+		require.NoError(t, nodeRegistry.UpdateNode(context.Background(), node))
+
+		heartbeat := time.Now().UTC().Truncate(time.Second)
+		err = nodeRegistry.UpdateNodeStatus(context.Background(), &api.Node{
+			ObjectMeta:        api.ObjectMeta{Name: nodeName},
+			Status:            api.NodeNotReady,
+			LastHeartbeatTime: heartbeat,
+			Capacity:          api.NodeCapacity{CPU: 4, Memory: 1 << 30, MaxPods: 16},
+			Conditions:        []api.NodeCondition{{Type: api.NodeDiskPressure, Status: api.ConditionTrue}},
+		})
+		assert.NoError(t, err)
+
+		updatedNode, err := nodeRegistry.GetNode(context.Background(), nodeName)
+		assert.NoError(t, err)
+		assert.Equal(t, api.NodeNotReady, updatedNode.Status)
+		assert.True(t, heartbeat.Equal(updatedNode.LastHeartbeatTime))
+		assert.Equal(t, int32(16), updatedNode.Capacity.MaxPods)
+		assert.Equal(t, api.ConditionTrue, updatedNode.GetCondition(api.NodeDiskPressure).Status)
+		assert.True(t, updatedNode.Spec.Unschedulable)
+	})
+
+	t.Run("should fail for a missing node", func(t *testing.T) {
+		nodeRegistry := NewNodeRegistry(storage.NewMemoryStorage())
+
+		err := nodeRegistry.UpdateNodeStatus(context.Background(), &api.Node{ObjectMeta: api.ObjectMeta{Name: "missing"}})
+		assert.ErrorIs(t, err, ErrNodeNotFound)
+	})
+
+	t.Run("should reject an update from another machine", func(t *testing.T) {
+		nodeRegistry := NewNodeRegistry(storage.NewMemoryStorage())
+		createTestNodeInRegistry(t, nodeRegistry, "test-node-5", "machine-1")
+
+		err := nodeRegistry.UpdateNodeStatus(context.Background(), &api.Node{
+			ObjectMeta: api.ObjectMeta{Name: "test-node-5", UID: "machine-2"},
+			Status:     api.NodeReady,
 		})
+		assert.ErrorIs(t, err, ErrNodeConflict)
+
+		node, err := nodeRegistry.GetNode(context.Background(), "test-node-5")
+		require.NoError(t, err)
+		assert.Equal(t, "machine-1", node.UID)
 	})
 }
 
 func TestNodeRegistry_ListNodes(t *testing.T) {
+	t.Run("should list the node names", func(t *testing.T) {
+		nodeRegistry := NewNodeRegistry(storage.NewMemoryStorage())
+		createTestNodeInRegistry(t, nodeRegistry, "node-b", "101")
+		createTestNodeInRegistry(t, nodeRegistry, "node-a", "102")
+
+		names, err := nodeRegistry.ListNodeNames(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"node-a", "node-b"}, names)
+	})
+
 	t.Run("should list nodes", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			nodeRegistry := NewNodeRegistry(etcdStorage)
-			ctx := context.Background()
-
-			// Clear existing nodes
-			clearNodes(t, nodeRegistry)
-
-			// Create test nodes
-			createTestNodeInRegistry(t, nodeRegistry, "test-node-4", "101")
-			createTestNodeInRegistry(t, nodeRegistry, "test-node-5", "102")
-
-			nodes, err := nodeRegistry.ListNodes(ctx)
-			assert.NoError(t, err)
-			assert.Len(t, nodes, 2)
-			assert.Contains(t, []string{nodes[0].Name, nodes[1].Name}, "test-node-4")
-			assert.Contains(t, []string{nodes[0].Name, nodes[1].Name}, "test-node-5")
-		})
+		memoryStorage := storage.NewMemoryStorage()
+		nodeRegistry := NewNodeRegistry(memoryStorage)
+		ctx := context.Background()
+
+		// Clear existing nodes
+		clearNodes(t, nodeRegistry)
+
+		// Create test nodes
+		createTestNodeInRegistry(t, nodeRegistry, "test-node-4", "101")
+		createTestNodeInRegistry(t, nodeRegistry, "test-node-5", "102")
+
+		nodes, err := nodeRegistry.ListNodes(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, nodes, 2)
+		assert.Contains(t, []string{nodes[0].Name, nodes[1].Name}, "test-node-4")
+		assert.Contains(t, []string{nodes[0].Name, nodes[1].Name}, "test-node-5")
 	})
 
 	t.Run("should handle error returned by the storage provider", func(t *testing.T) {
@@ -182,7 +280,7 @@ func TestNodeRegistry_ListNodes(t *testing.T) {
 		nodeRegistry := NewNodeRegistry(mStorage)
 		ctx := context.Background()
 
-		mStorage.EXPECT().List(ctx, nodePrefix, gomock.Any()).Return(errors.New("failed to list nodes"))
+		mStorage.EXPECT().ListFunc(ctx, nodePrefix, gomock.Any(), gomock.Any()).Return(errors.New("failed to list nodes"))
 
 		nodes, err := nodeRegistry.ListNodes(ctx)
 
@@ -192,31 +290,29 @@ func TestNodeRegistry_ListNodes(t *testing.T) {
 }
 
 func TestNodeRegistry_DeleteNode(t *testing.T) {
-	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-		etcdStorage := storage.NewEtcdStorage(etcdServer)
-		nodeRegistry := NewNodeRegistry(etcdStorage)
-		ctx := context.Background()
+	memoryStorage := storage.NewMemoryStorage()
+	nodeRegistry := NewNodeRegistry(memoryStorage)
+	ctx := context.Background()
 
-		nodeName := "test-node-6"
-		createTestNodeInRegistry(t, nodeRegistry, nodeName, "103")
+	nodeName := "test-node-6"
+	createTestNodeInRegistry(t, nodeRegistry, nodeName, "103")
 
-		err := nodeRegistry.DeleteNode(ctx, nodeName)
-		assert.NoError(t, err)
+	err := nodeRegistry.DeleteNode(ctx, nodeName)
+	assert.NoError(t, err)
 
-		_, err = nodeRegistry.GetNode(ctx, nodeName)
-		assert.Error(t, err)
-	})
+	_, err = nodeRegistry.GetNode(ctx, nodeName)
+	assert.Error(t, err)
 }
 
 func TestDeleteNonExistentNode(t *testing.T) {
-	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-		etcdStorage := storage.NewEtcdStorage(etcdServer)
-		nodeRegistry := NewNodeRegistry(etcdStorage)
-		ctx := context.Background()
+	memoryStorage := storage.NewMemoryStorage()
+	nodeRegistry := NewNodeRegistry(memoryStorage)
+	ctx := context.Background()
 
-		err := nodeRegistry.DeleteNode(ctx, "non-existent-node")
-		assert.NoError(t, err) // Deleting a non-existent node should not return an error
-	})
+	err := nodeRegistry.DeleteNode(ctx, "non-existent-node")
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+	assert.EqualError(t, err, "node not found: non-existent-node")
+	assert.NoError(t, IgnoreNotFound(err), "the delete can be made idempotent")
 }
 
 // Helper functions
@@ -250,3 +346,15 @@ func clearNodes(t *testing.T, nodeRegistry *NodeRegistry) {
 		require.NoError(t, err)
 	}
 }
+
+func TestNodeRegistry_GetNodes(t *testing.T) {
+	registry := NewNodeRegistry(storage.NewMemoryStorage())
+	createTestNodeInRegistry(t, registry, "node-1", "uid-1")
+	createTestNodeInRegistry(t, registry, "node-2", "uid-2")
+
+	nodes, missing, err := registry.GetNodes(context.Background(), []string{"node-2", "node-3", "node-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "uid-1", nodes["node-1"].UID)
+	assert.Equal(t, "uid-2", nodes["node-2"].UID)
+	assert.Equal(t, []string{"node-3"}, missing)
+}
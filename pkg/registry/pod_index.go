@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// podIndexPrefix is where the index entries of the pods are kept, one per pod under
+// /registry/index/pods/status/<status>/<namespace>/<name> and one per bound pod under
+// /registry/index/pods/node/<node>/<namespace>/<name>, so the pods of a status or a node are found without
+// decoding all pods
+const podIndexPrefix = "/registry/index/pods/"
+
+const (
+	podStatusIndex = "status"
+	podNodeIndex   = "node"
+)
+
+// podIndexEntry is the value of an index entry, which names the pod and the index it is listed in. The entries
+// written before pods had namespaces have none; they match no pod and are removed like any stale entry.
+type podIndexEntry struct {
+	Index     string `json:"index"`
+	Value     string `json:"value"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod"`
+}
+
+// ref is the namespace/name of the pod of the entry
+func (e *podIndexEntry) ref() string {
+	return e.Namespace + "/" + e.Pod
+}
+
+func podIndexPrefixOf(index, value string) string {
+	return podIndexPrefix + index + "/" + value + "/"
+}
+
+func (e *podIndexEntry) key() string {
+	if e.Namespace == "" {
+		return podIndexPrefixOf(e.Index, e.Value) + e.Pod
+	}
+	return podIndexPrefixOf(e.Index, e.Value) + e.Namespace + "/" + e.Pod
+}
+
+// podIndexEntries returns the index entries of pod, none for nil
+func podIndexEntries(pod *api.Pod) []podIndexEntry {
+	if pod == nil {
+		return nil
+	}
+	namespace := api.NamespaceOf(&pod.ObjectMeta)
+	entries := []podIndexEntry{{Index: podStatusIndex, Value: string(pod.Status), Namespace: namespace, Pod: pod.Name}}
+	if pod.NodeName != "" {
+		entries = append(entries, podIndexEntry{Index: podNodeIndex, Value: pod.NodeName, Namespace: namespace, Pod: pod.Name})
+	}
+	return entries
+}
+
+// matchesIndexEntry reports whether pod belongs in the index entry
+func matchesIndexEntry(pod *api.Pod, entry *podIndexEntry) bool {
+	for _, e := range podIndexEntries(pod) {
+		if e == *entry {
+			return true
+		}
+	}
+	return false
+}
+
+// writeIndexed writes a pod with op and keeps its index entries in step, in one storage transaction. old is the
+// stored version of the pod, nil if there is none, and pod the version op stores, nil for a delete. The entries
+// the pod gains are added and the ones it loses removed with the write, so they are never missing, and are
+// exact as long as op only succeeds on old, as an update at the resource version of old does. It returns the
+// error of the transaction for the caller to make sense of.
+func (r *PodRegistry) writeIndexed(ctx context.Context, old, pod *api.Pod, op storage.TxnOp) error {
+	txn := []storage.TxnOp{op}
+	for _, entry := range podIndexEntries(pod) {
+		if !matchesIndexEntry(old, &entry) {
+			txn = append(txn, storage.UpdateOp(entry.key(), &entry))
+		}
+	}
+	for _, entry := range podIndexEntries(old) {
+		if !matchesIndexEntry(pod, &entry) {
+			txn = append(txn, storage.DeleteOp(entry.key()))
+		}
+	}
+	return r.storage.Txn(ctx, txn)
+}
+
+// listIndexed returns the pods listed in the index under value, in namespace and name order. The entries of
+// pods that are gone or no longer match, left by a delete racing with an update or by an older version, are
+// skipped and removed. An entry a concurrent write adds back between the read of its pod and its removal is
+// lost until ReconcileIndex adds it again, which takes a pod leaving and coming back to the same entry within
+// that window.
+func (r *PodRegistry) listIndexed(ctx context.Context, index, value string) ([]*api.Pod, error) {
+	entries, err := listOf[podIndexEntry](ctx, r.storage, podIndexPrefixOf(index, value))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	// The pods are fetched by namespace/name, which tells the pods of the same name apart
+	refs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		refs = append(refs, entry.ref())
+	}
+	found, _, err := getAll(ctx, refs, func(ctx context.Context, ref string) (*api.Pod, error) {
+		namespace, name, _ := strings.Cut(ref, "/")
+		return r.getPod(ctx, namespace, name)
+	}, ErrPodNotFound)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+
+	pods := make([]*api.Pod, 0, len(found))
+	for _, entry := range entries {
+		if pod, ok := found[entry.ref()]; ok && matchesIndexEntry(pod, entry) {
+			pods = append(pods, pod)
+			continue
+		}
+		_ = r.storage.Delete(ctx, entry.key())
+	}
+	return pods, nil
+}
+
+// ReconcileIndex brings the index entries of the pods in line with the stored pods, adding the missing
+// entries and removing the stale ones, and returns how many it fixed. Entries go missing when pods are
+// written without the index, by an older version or outside the registry. The current state of a pod is
+// read again before its entry is fixed, as another process may have changed it since the pods were listed.
+func (r *PodRegistry) ReconcileIndex(ctx context.Context) (int, error) {
+	pods, err := listOf[api.Pod](ctx, r.storage, podPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	entries, err := listOf[podIndexEntry](ctx, r.storage, podIndexPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to list the pod index: %v", ErrInternal, err)
+	}
+
+	wanted := make(map[string]podIndexEntry)
+	for _, pod := range pods {
+		for _, entry := range podIndexEntries(pod) {
+			wanted[entry.key()] = entry
+		}
+	}
+	fixed := 0
+	for _, entry := range entries {
+		key := entry.key()
+		if _, ok := wanted[key]; ok {
+			delete(wanted, key)
+			continue
+		}
+		if pod, err := r.getPod(ctx, entry.Namespace, entry.Pod); err == nil && matchesIndexEntry(pod, entry) {
+			continue
+		}
+		// An entry another process removed meanwhile is fixed all the same
+		if err := r.storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fixed, fmt.Errorf("%w: failed to remove index entry %s: %v", ErrInternal, key, err)
+		}
+		fixed++
+	}
+	for key, entry := range wanted {
+		if pod, err := r.getPod(ctx, entry.Namespace, entry.Pod); err != nil || !matchesIndexEntry(pod, &entry) {
+			continue
+		}
+		if err := r.storage.Update(ctx, key, &entry); err != nil {
+			return fixed, fmt.Errorf("%w: failed to add index entry %s: %v", ErrInternal, key, err)
+		}
+		fixed++
+	}
+	return fixed, nil
+}
+
+// MigrateLegacyPods moves the pods stored by older versions under /pods/<name>, before pods had namespaces, to
+// their namespace, the default one for a pod without it, and returns how many it moved. Their index entries
+// are left for ReconcileIndex to replace. A pod whose name is taken in its namespace is left where it is.
+func (r *PodRegistry) MigrateLegacyPods(ctx context.Context) (int, error) {
+	keys, err := r.storage.ListKeys(ctx, podPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	migrated := 0
+	for _, key := range keys {
+		if strings.Contains(strings.TrimPrefix(key, podPrefix), "/") {
+			continue
+		}
+		pod := &api.Pod{}
+		if err := r.storage.Get(ctx, key, pod); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return migrated, fmt.Errorf("%w: failed to get pod: %v", ErrInternal, err)
+		}
+		pod.Namespace = api.NamespaceOf(&pod.ObjectMeta)
+		pod.ResourceVersion = ""
+		err := r.storage.Txn(ctx, []storage.TxnOp{
+			storage.CreateOp(r.generateKey(pod.Namespace, pod.Name), pod),
+			storage.DeleteOp(key),
+		})
+		switch {
+		case errors.Is(err, storage.ErrKeyExists):
+			continue
+		case err != nil:
+			return migrated, fmt.Errorf("%w: failed to migrate pod %s: %v", ErrInternal, pod.Name, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,225 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func podNames(pods []*api.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+func indexKeys(t *testing.T, s storage.Storage) []string {
+	t.Helper()
+	entries, err := storage.ListOf[podIndexEntry](context.Background(), s, podIndexPrefix)
+	require.NoError(t, err)
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.key())
+	}
+	return keys
+}
+
+func TestPodRegistry_PodIndex(t *testing.T) {
+	t.Run("should follow the pods through create, bind, status update and delete", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+			ctx := context.Background()
+
+			for _, name := range []string{"web-1", "web-2"} {
+				require.NoError(t, registry.CreatePod(ctx, newBatchTestPod(name)))
+			}
+			assert.ElementsMatch(t, []string{
+				"/registry/index/pods/status/Pending/default/web-1",
+				"/registry/index/pods/status/Pending/default/web-2",
+			}, indexKeys(t, etcdStorage))
+
+			pod, err := registry.GetPod(ctx, api.DefaultNamespace, "web-1")
+			require.NoError(t, err)
+			pod.NodeName = "node-1"
+			pod.Status = api.PodScheduled
+			require.NoError(t, registry.UpdatePod(ctx, pod))
+			require.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web-1", Status: api.PodRunning}))
+			assert.ElementsMatch(t, []string{
+				"/registry/index/pods/status/Running/default/web-1",
+				"/registry/index/pods/node/node-1/default/web-1",
+				"/registry/index/pods/status/Pending/default/web-2",
+			}, indexKeys(t, etcdStorage))
+
+			pending, err := registry.ListPendingPods(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"web-2"}, podNames(pending))
+			onNode, err := registry.ListPodsByNode(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"web-1"}, podNames(onNode))
+			assert.Equal(t, api.PodRunning, onNode[0].Status)
+
+			require.NoError(t, registry.DeletePod(ctx, api.DefaultNamespace, "web-1"))
+			assert.Equal(t, []string{"/registry/index/pods/status/Pending/default/web-2"}, indexKeys(t, etcdStorage))
+			onNode, err = registry.ListPodsByNode(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Empty(t, onNode)
+		})
+	})
+
+	t.Run("should skip and remove stale entries when listing", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			etcdStorage := storage.NewEtcdStorage(etcdServer)
+			registry := NewPodRegistry(etcdStorage)
+			ctx := context.Background()
+			require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-1")))
+
+			// Left behind by a write that failed after indexing, and by a pod deleted without the registry
+			for _, entry := range []podIndexEntry{
+				{Index: podStatusIndex, Value: string(api.PodPending), Namespace: api.DefaultNamespace, Pod: "gone"},
+				{Index: podNodeIndex, Value: "node-1", Namespace: api.DefaultNamespace, Pod: "web-1"},
+			} {
+				require.NoError(t, etcdStorage.Update(ctx, entry.key(), &entry))
+			}
+
+			pending, err := registry.ListPendingPods(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"web-1"}, podNames(pending))
+			onNode, err := registry.ListPodsByNode(ctx, "node-1")
+			require.NoError(t, err)
+			assert.Empty(t, onNode)
+			assert.Equal(t, []string{"/registry/index/pods/status/Pending/default/web-1"}, indexKeys(t, etcdStorage))
+		})
+	})
+}
+
+func TestPodRegistry_ReconcileIndex(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		registry := NewPodRegistry(etcdStorage)
+		ctx := context.Background()
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-1")))
+
+		// Stored by a version without the index, and an entry of a pod that is gone
+		unindexed := newBatchTestPod("web-2")
+		unindexed.NodeName = "node-1"
+		unindexed.Status = api.PodRunning
+		require.NoError(t, etcdStorage.Create(ctx, podPrefix+"default/web-2", unindexed))
+		stale := podIndexEntry{Index: podStatusIndex, Value: string(api.PodRunning), Namespace: api.DefaultNamespace, Pod: "gone"}
+		require.NoError(t, etcdStorage.Update(ctx, stale.key(), &stale))
+
+		fixed, err := registry.ReconcileIndex(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 3, fixed)
+		assert.ElementsMatch(t, []string{
+			"/registry/index/pods/status/Pending/default/web-1",
+			"/registry/index/pods/status/Running/default/web-2",
+			"/registry/index/pods/node/node-1/default/web-2",
+		}, indexKeys(t, etcdStorage))
+
+		onNode, err := registry.ListPodsByNode(ctx, "node-1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web-2"}, podNames(onNode))
+
+		fixed, err = registry.ReconcileIndex(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, fixed, "an index in line with the pods should be left alone")
+	})
+}
+
+func TestPodRegistry_MigrateLegacyPods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdServer)
+		registry := NewPodRegistry(etcdStorage)
+		ctx := context.Background()
+
+		// Stored by a version without namespaces, with the index entry it wrote; one pod has the name of a
+		// pod stored since
+		legacy := newBatchTestPod("web-1")
+		legacy.NodeName = "node-1"
+		legacy.Status = api.PodRunning
+		require.NoError(t, etcdStorage.Create(ctx, podPrefix+"web-1", legacy))
+		entry := podIndexEntry{Index: podNodeIndex, Value: "node-1", Pod: "web-1"}
+		require.NoError(t, etcdStorage.Update(ctx, entry.key(), &entry))
+		require.NoError(t, etcdStorage.Create(ctx, podPrefix+"web-2", newBatchTestPod("web-2")))
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-2")))
+
+		migrated, err := registry.MigrateLegacyPods(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, migrated, "a pod whose name is taken is not moved")
+		pod, err := registry.GetPod(ctx, api.DefaultNamespace, "web-1")
+		require.NoError(t, err)
+		assert.Equal(t, api.DefaultNamespace, pod.Namespace)
+		assert.Equal(t, "node-1", pod.NodeName)
+		keys, err := etcdStorage.ListKeys(ctx, podPrefix)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{podPrefix + "default/web-1", podPrefix + "default/web-2", podPrefix + "web-2"}, keys)
+
+		_, err = registry.ReconcileIndex(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"/registry/index/pods/status/Running/default/web-1",
+			"/registry/index/pods/node/node-1/default/web-1",
+			"/registry/index/pods/status/Pending/default/web-2",
+		}, indexKeys(t, etcdStorage), "the entries without a namespace are replaced")
+
+		migrated, err = registry.MigrateLegacyPods(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, migrated)
+	})
+}
+
+// BenchmarkPodRegistry_ListPendingPods compares listing the 50 pending pods among 5000 through the index with
+// listing all pods and filtering them
+func BenchmarkPodRegistry_ListPendingPods(b *testing.B) {
+	registry := NewPodRegistry(storage.NewEtcdStorage(storage.NewTestEtcdClient(b)))
+	ctx := context.Background()
+	for i := range 5000 {
+		pod := newBatchTestPod(fmt.Sprintf("web-%d", i))
+		if i%100 != 0 {
+			pod.NodeName = fmt.Sprintf("node-%d", i%10)
+			pod.Status = api.PodRunning
+		}
+		if err := registry.CreatePod(ctx, pod); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("scan", func(b *testing.B) {
+		for range b.N {
+			pods, err := registry.ListPods(ctx, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			var pending []*api.Pod
+			for _, pod := range pods {
+				if pod.Status == api.PodPending {
+					pending = append(pending, pod)
+				}
+			}
+			if len(pending) != 50 {
+				b.Fatalf("listed %d pending pods", len(pending))
+			}
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for range b.N {
+			pending, err := registry.ListPendingPods(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(pending) != 50 {
+				b.Fatalf("listed %d pending pods", len(pending))
+			}
+		}
+	})
+}
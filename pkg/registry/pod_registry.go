@@ -4,25 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"slices"
+	"strings"
+	"time"
 
 	"gokube/pkg/api"
 	"gokube/pkg/storage"
 )
 
+// podPrefix is where the pods are kept, under /pods/<namespace>/<name>. Pods stored by older versions under
+// /pods/<name> are moved to the default namespace by MigrateLegacyPods.
 const podPrefix = "/pods/"
 
 var (
 	ErrPodAlreadyExists = errors.New("pod already exists")
-	ErrPodNotFound      = errors.New("pod not found")
+	ErrPodNotFound      = fmt.Errorf("pod %w", ErrNotFound)
 	ErrListPodsFailed   = errors.New("failed to list pods")
 	ErrPodInvalid       = errors.New("invalid pod")
+	// ErrPodConflict is returned when an update was meant for a pod that was changed since the resource version
+	// it carries, or a status update for a pod that was recreated or rebound since
+	ErrPodConflict = errors.New("pod update conflicts with the current pod")
+	// ErrMirrorPodReadOnly is returned when the spec of a mirror pod is updated; only its kubelet may change it
+	ErrMirrorPodReadOnly = errors.New("mirror pods are read-only")
+	// ErrPodImmutable is returned for an update that changes a field of a pod that is fixed once it is created
+	ErrPodImmutable = errors.New("pod update changes immutable fields")
+	// ErrInvalidTransition is returned for an update that moves a pod to a status it cannot reach from its
+	// current one, see api.PodStatus.CanTransitionTo
+	ErrInvalidTransition = errors.New("invalid pod status transition")
 )
 
-// PodRegistry provides thread-safe operations for managing Pod objects in the storage.
+// PodRegistry provides thread-safe operations for managing Pod objects in the storage. It holds no lock: every
+// write of a pod is a storage transaction conditioned on what it was checked against, so concurrent writes, from
+// this process or another, are either kept apart or retried.
 type PodRegistry struct {
-	storage storage.Storage
-	mutex   sync.RWMutex
+	storage     storage.Storage
+	namespaces  *NamespaceRegistry
+	quotas      *ResourceQuotaRegistry
+	limitRanges *LimitRangeRegistry
 }
 
 // NewPodRegistry creates a new PodRegistry with the given storage.
@@ -32,30 +50,194 @@ func NewPodRegistry(storage storage.Storage) *PodRegistry {
 	}
 }
 
-func (r *PodRegistry) generateKey(podName string) string {
-	return fmt.Sprintf("%s%s", podPrefix, podName)
+// SetNamespaceRegistry makes CreatePod reject pods whose namespace does not exist or is terminating. Without
+// it pods are created in any namespace.
+func (r *PodRegistry) SetNamespaceRegistry(namespaces *NamespaceRegistry) {
+	r.namespaces = namespaces
 }
 
-// CreatePod creates a new pod in the registry.
-// It returns an error if the pod already exists or if the pod spec is invalid.
-// If the pod status is not set, it defaults to api.PodPending.
+// SetResourceQuotaRegistry makes CreatePod reject pods that would take their namespace over one of its
+// ResourceQuotas. A create commits the quotas it was admitted by along with the pods, so of concurrent creates
+// going through the same quotas only one succeeds at a time and the others are admitted again; two pods cannot
+// both take the last of a quota.
+func (r *PodRegistry) SetResourceQuotaRegistry(quotas *ResourceQuotaRegistry) {
+	r.quotas = quotas
+}
+
+// SetLimitRangeRegistry makes CreatePod give the containers of a pod the default resources of the LimitRanges
+// of its namespace, and reject containers outside their bounds
+func (r *PodRegistry) SetLimitRangeRegistry(limitRanges *LimitRangeRegistry) {
+	r.limitRanges = limitRanges
+}
+
+// generateKey returns the key of a pod, of the default namespace if namespace is empty
+func (r *PodRegistry) generateKey(namespace, podName string) string {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	return podPrefix + namespace + "/" + podName
+}
+
+// CreatePod creates a new pod in its namespace, the default namespace for a pod without one.
+// It returns an error if the pod already exists in the namespace or if the pod spec is invalid.
+// If the pod status is not set, it defaults to api.PodPending. The pod is given a new UID, so a pod recreated
+// under the name of a deleted pod can be told apart from it, and its creation time; only a mirror pod may bring
+// its own UID, the one of its static pod. A pod without a termination grace period gets the default one. A
+// pod without a trace ID is traced with the ID of the request creating it.
 func (r *PodRegistry) CreatePod(ctx context.Context, pod *api.Pod) error {
-	//Assignment 1: Implement CreatePod
+	return r.CreatePodsWith(ctx, []*api.Pod{pod})
+}
+
+// CreatePodsWith creates pods as CreatePod does, along with the writes of ops, all in one storage transaction:
+// either all of the pods are created and ops written, or none. The pods are admitted together, so they fit
+// the quotas of their namespace only if all of them do. It fails with ErrPodAlreadyExists if one of the pods
+// exists, and with the *storage.TxnError of the key if the condition of one of ops fails. Pods that lose a quota
+// to a concurrent create are admitted again, up to a few times.
+func (r *PodRegistry) CreatePodsWith(ctx context.Context, pods []*api.Pod, ops ...storage.TxnOp) error {
+	// An attempt that is retried gives the pods back the UIDs and creation times they came with, so they are
+	// admitted again as they were
+	given := make([]api.ObjectMeta, len(pods))
+	for i, pod := range pods {
+		given[i] = pod.ObjectMeta
+	}
+	var err error
+	for range conflictRetries {
+		if err = r.createPodsWith(ctx, pods, ops); !quotaConflict(err) {
+			return err
+		}
+		for i, pod := range pods {
+			pod.UID, pod.CreationTimestamp = given[i].UID, given[i].CreationTimestamp
+		}
+	}
+	return err
+}
+
+// createPodsWith admits and creates pods once, failing with a *storage.TxnError of a quota key if another create
+// went through the same quotas meanwhile
+func (r *PodRegistry) createPodsWith(ctx context.Context, pods []*api.Pod, ops []storage.TxnOp) error {
+	for _, pod := range pods {
+		if err := r.admit(ctx, pod); err != nil {
+			return err
+		}
+	}
+	var quotaOps []storage.TxnOp
+	if r.quotas != nil {
+		var err error
+		if quotaOps, err = r.quotas.AdmitPods(ctx, pods); err != nil {
+			return err
+		}
+	}
+
+	txn := make([]storage.TxnOp, 0, 3*len(pods)+len(quotaOps)+len(ops))
+	podNames := make(map[string]string, len(pods))
+	for _, pod := range pods {
+		stampCreated(&pod.ObjectMeta)
+		for _, entry := range podIndexEntries(pod) {
+			txn = append(txn, storage.UpdateOp(entry.key(), &entry))
+		}
+		key := r.generateKey(pod.Namespace, pod.Name)
+		txn = append(txn, storage.CreateOp(key, pod))
+		podNames[key] = pod.Namespace + "/" + pod.Name
+	}
+	txn = append(txn, quotaOps...)
+	txn = append(txn, ops...)
+
+	err := r.storage.Txn(ctx, txn)
+	var txnErr *storage.TxnError
+	if errors.As(err, &txnErr) {
+		if name, ok := podNames[txnErr.Key]; ok {
+			return existsAs(err, fmt.Errorf("%w: %s", ErrPodAlreadyExists, name))
+		}
+	}
+	return err
+}
+
+// quotaConflict reports whether a create failed because a quota it was admitted by changed, which admitting the
+// pods again sorts out
+func quotaConflict(err error) bool {
+	var txnErr *storage.TxnError
+	return errors.As(err, &txnErr) && strings.HasPrefix(txnErr.Key, resourceQuotaPrefix)
+}
+
+// admit checks that pod may be created in its namespace and gives it its defaults, leaving the quotas to the
+// caller, which may admit several pods at once
+func (r *PodRegistry) admit(ctx context.Context, pod *api.Pod) error {
+	pod.Namespace = api.NamespaceOf(&pod.ObjectMeta)
+	if r.namespaces != nil {
+		if err := r.namespaces.CheckActive(ctx, pod.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if pod.UID != "" && !pod.IsMirrorPod() {
+		return fmt.Errorf("%w: %s: uid is assigned by the server", ErrPodInvalid, pod.Name)
+	}
+	if pod.IsDeleting() {
+		return fmt.Errorf("%w: %s: deletionTimestamp is set by the server", ErrPodInvalid, pod.Name)
+	}
+	if pod.Status == "" {
+		pod.Status = api.PodPending
+	}
+	if pod.TraceID() == "" {
+		pod.SetTraceID(traceID(ctx))
+	}
+	if pod.Spec.TerminationGracePeriodSeconds == nil {
+		gracePeriod := api.DefaultTerminationGracePeriodSeconds
+		pod.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	}
+
+	// The defaults are stored with the pod, so what it was given is visible rather than implied
+	var limitRanges []*api.LimitRange
+	if r.limitRanges != nil {
+		var err error
+		if limitRanges, err = r.limitRanges.List(ctx, pod.Namespace); err != nil {
+			return err
+		}
+		for _, limitRange := range limitRanges {
+			limitRange.ApplyDefaults(pod)
+		}
+	}
+
+	// Validate Pod spec
+	if err := pod.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrPodInvalid, err)
+	}
+	var fieldErrs api.FieldErrors
+	for _, limitRange := range limitRanges {
+		fieldErrs = append(fieldErrs, limitRange.Check(pod)...)
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrPodInvalid, fieldErrs)
+	}
 	return nil
 }
 
 // GetPod retrieves a Pod by its name from the registry.
 // It returns the Pod object if found, otherwise it returns an error indicating that the Pod was not found.
-func (r *PodRegistry) GetPod(ctx context.Context, name string) (*api.Pod, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+// An empty namespace is the default namespace.
+func (r *PodRegistry) GetPod(ctx context.Context, namespace, name string) (*api.Pod, error) {
+	return r.getPod(ctx, namespace, name)
+}
 
-	key := r.generateKey(name)
+// GetPods retrieves the named Pods of a namespace, reading up to MaxConcurrentGets of them from storage at
+// once, which is faster than getting them one by one and lighter than listing all Pods. The names of the Pods
+// that do not exist are returned as missing rather than failing the call.
+func (r *PodRegistry) GetPods(ctx context.Context, namespace string, names []string) (map[string]*api.Pod, []string, error) {
+	return getAll(ctx, names, func(ctx context.Context, name string) (*api.Pod, error) {
+		return r.getPod(ctx, namespace, name)
+	}, ErrPodNotFound)
+}
+
+func (r *PodRegistry) getPod(ctx context.Context, namespace, name string) (*api.Pod, error) {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	key := r.generateKey(namespace, name)
 	pod := &api.Pod{}
 	if err := r.storage.Get(ctx, key, pod); err != nil {
 		switch {
 		case errors.Is(err, storage.ErrNotFound):
-			return nil, fmt.Errorf("%w: %s", ErrPodNotFound, name)
+			return nil, fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, name)
 		default:
 			return nil, fmt.Errorf("%w: failed to get pod: %v", ErrInternal, err)
 		}
@@ -64,72 +246,388 @@ func (r *PodRegistry) GetPod(ctx context.Context, name string) (*api.Pod, error)
 	return pod, nil
 }
 
-// UpdatePod updates an existing Pod in the registry.
-// It returns an error if the Pod spec is invalid.
+// UpdatePod updates an existing Pod of the namespace of pod.
+// It returns ErrPodNotFound if the Pod does not exist or is deleted meanwhile, rather than creating it, an
+// error if the Pod spec is invalid or the Pod is a mirror pod, and ErrPodImmutable if the update changes more
+// of the pod than its images, metadata, status and node binding, see api.Pod.ValidateUpdate. It returns
+// ErrInvalidTransition for a status the pod cannot move to, such as a finished pod going back to Pending.
+// A pod updated without a status, termination grace period, UID, creation time or finalizers keeps the ones it
+// has; its deletion time is always kept, and a pod being deleted gains no finalizers.
 func (r *PodRegistry) UpdatePod(ctx context.Context, pod *api.Pod) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	pod.Namespace = api.NamespaceOf(&pod.ObjectMeta)
+	if pod.ResourceVersion != "" {
+		return r.updatePod(ctx, pod)
+	}
+	// An update without a resource version is written at the version it was checked against, and checked
+	// again, as it was given, against a pod changed in between
+	given := *pod
+	return retryOnConflict(func() error {
+		*pod = given
+		return r.updatePod(ctx, pod)
+	})
+}
 
-	key := r.generateKey(pod.Name)
+func (r *PodRegistry) updatePod(ctx context.Context, pod *api.Pod) error {
+	key := r.generateKey(pod.Namespace, pod.Name)
+	existingPod, err := r.getPod(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return err
+	}
+	if existingPod.IsMirrorPod() {
+		return fmt.Errorf("%w: %s", ErrMirrorPodReadOnly, pod.Name)
+	}
+	if pod.Spec.TerminationGracePeriodSeconds == nil {
+		pod.Spec.TerminationGracePeriodSeconds = existingPod.Spec.TerminationGracePeriodSeconds
+	}
+	keepCreated(&pod.ObjectMeta, &existingPod.ObjectMeta)
+	pod.DeletionTimestamp = existingPod.DeletionTimestamp
+	if pod.Finalizers == nil {
+		pod.Finalizers = existingPod.Finalizers
+	}
+	if pod.Status == "" {
+		pod.Status = existingPod.Status
+	}
+	// A stale update is left to fail with a conflict rather than be judged against a status it never saw
+	stale := pod.ResourceVersion != "" && pod.ResourceVersion != existingPod.ResourceVersion
+	if !stale {
+		if err := checkStatusChange(existingPod, pod.Status); err != nil {
+			return err
+		}
+	}
+
+	// The defaults are stored with the pod, so what it was given is visible rather than implied
+	var limitRanges []*api.LimitRange
+	if r.limitRanges != nil {
+		if limitRanges, err = r.limitRanges.List(ctx, pod.Namespace); err != nil {
+			return err
+		}
+		for _, limitRange := range limitRanges {
+			limitRange.ApplyDefaults(pod)
+		}
+	}
 
 	// Validate Pod spec
 	if err := pod.Validate(); err != nil {
-		return fmt.Errorf("%w: %v", ErrPodInvalid, err)
+		return fmt.Errorf("%w: %w", ErrPodInvalid, err)
+	}
+	var fieldErrs api.FieldErrors
+	for _, limitRange := range limitRanges {
+		fieldErrs = append(fieldErrs, limitRange.Check(pod)...)
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrPodInvalid, fieldErrs)
+	}
+	if err := pod.ValidateUpdate(existingPod); err != nil {
+		return fmt.Errorf("%w: %w", ErrPodImmutable, err)
 	}
 
-	return r.storage.Update(ctx, key, pod)
+	if pod.ResourceVersion == "" {
+		pod.ResourceVersion = existingPod.ResourceVersion
+	}
+	err = r.writeIndexed(ctx, existingPod, pod, storage.ReplaceOp(key, pod))
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s/%s", ErrPodNotFound, pod.Namespace, pod.Name)
+	}
+	return conflictAs(err, ErrPodConflict)
 }
 
-// DeletePod removes a Pod from the registry by its name.
-// It returns an error if the deletion fails.
-func (r *PodRegistry) DeletePod(ctx context.Context, name string) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// checkStatusChange checks that the stored pod existing may move to status, one of api.PodStatuses that the
+// transition table allows from its status. Both UpdatePod and UpdatePodStatus check a status before writing
+// it. It returns ErrPodInvalid for an unknown status and ErrInvalidTransition for one the pod cannot reach.
+func checkStatusChange(existing *api.Pod, status api.PodStatus) error {
+	if err := api.ValidatePodStatus(status); err != nil {
+		return fmt.Errorf("%w: %w", ErrPodInvalid, err)
+	}
+	if !existing.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: pod %s cannot go from %s to %s", ErrInvalidTransition, existing.Name, existing.Status, status)
+	}
+	return nil
+}
+
+// UpdatePodStatus replaces the status and container statuses of an existing Pod of the namespace of update, the
+// default namespace if it has none, leaving its spec, metadata and node assignment untouched. A pod changed
+// between the read and the write, such as by the scheduler binding it, is read again, so neither change is
+// lost. It returns ErrPodConflict if the update names a different UID or node, and like UpdatePod
+// ErrPodInvalid for an unknown status and ErrInvalidTransition for a status the pod cannot move to.
+//
+// The finalizers of update.RemoveFinalizers are removed from the pod, and a pod being deleted is removed with
+// the last of them. A pod being deleted keeps its status, Terminating unless it had finished.
+func (r *PodRegistry) UpdatePodStatus(ctx context.Context, update *api.PodStatusUpdate) error {
+	key := r.generateKey(update.Namespace, update.Name)
+	return retryOnConflict(func() error {
+		pod, err := r.getPod(ctx, update.Namespace, update.Name)
+		if err != nil {
+			return err
+		}
+		if update.UID != "" && pod.UID != "" && update.UID != pod.UID {
+			return fmt.Errorf("%w: pod %s has UID %s, not %s", ErrPodConflict, update.Name, pod.UID, update.UID)
+		}
+		if update.NodeName != "" && update.NodeName != pod.NodeName {
+			return fmt.Errorf("%w: pod %s is bound to node %q, not %q", ErrPodConflict, update.Name, pod.NodeName, update.NodeName)
+		}
 
-	key := r.generateKey(name)
-	return r.storage.Delete(ctx, key)
+		old := *pod
+		if pod.IsDeleting() {
+			// The status is kept, so only its value is checked
+			if err := api.ValidatePodStatus(update.Status); err != nil {
+				return fmt.Errorf("%w: %w", ErrPodInvalid, err)
+			}
+		} else {
+			if err := checkStatusChange(pod, update.Status); err != nil {
+				return err
+			}
+			pod.Status = update.Status
+		}
+		pod.ContainerStatuses = update.ContainerStatuses
+		if len(update.RemoveFinalizers) > 0 {
+			pod.Finalizers = slices.DeleteFunc(slices.Clone(pod.Finalizers), func(finalizer string) bool {
+				return slices.Contains(update.RemoveFinalizers, finalizer)
+			})
+			if pod.IsDeleting() && len(pod.Finalizers) == 0 {
+				return r.removePod(ctx, &old)
+			}
+		}
+		// The pod is written at the version it was read at, so a pod deleted meanwhile is not found
+		err = r.writeIndexed(ctx, &old, pod, storage.UpdateOp(key, pod))
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: %s/%s", ErrPodNotFound, api.NamespaceOf(&pod.ObjectMeta), update.Name)
+		}
+		return conflictAs(err, ErrPodConflict)
+	})
 }
 
-// ListPods retrieves all Pods from the registry.
-// It returns a slice of Pod objects and an error if the listing fails.
-func (r *PodRegistry) ListPods(ctx context.Context) ([]*api.Pod, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+// DeletePod removes a Pod from the registry by its namespace, the default one if empty, and name.
+// It returns ErrPodNotFound if there is no such Pod, which IgnoreNotFound turns into success, or an error if
+// the deletion fails. The pod is removed at once, whatever its finalizers, as a forced deletion does; see
+// DeletePodGracefully.
+func (r *PodRegistry) DeletePod(ctx context.Context, namespace, name string) error {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	key := r.generateKey(namespace, name)
+	notFound := fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, name)
+	pod := &api.Pod{}
+	if err := r.storage.Get(ctx, key, pod); err != nil {
+		// Any index entries of a pod that cannot be read are left to the readers to remove
+		return deleteAs(ctx, r.storage, key, notFound)
+	}
+	// The entries of the pod as read are removed with it; those it gained from a write in between are stale and
+	// removed by the readers
+	err := r.writeIndexed(ctx, pod, nil, storage.DeleteExistingOp(key))
+	if errors.Is(err, storage.ErrNotFound) {
+		return notFound
+	}
+	return err
+}
+
+// DeletePodGracefully deletes a Pod of a namespace, the default one if empty, once what its finalizers stand for
+// is done. A pod bound to a node that has not finished gets api.PodKubeletFinalizer, so it is kept until its
+// kubelet has stopped its containers; mirror pods are left out, as their kubelet runs them from a manifest. A
+// pod with finalizers is marked with the time it was deleted and, unless it finished, the Terminating status,
+// and returned; one without is removed at once, like DeletePod does, and nil returned. Deleting a pod that is
+// being deleted changes nothing.
+func (r *PodRegistry) DeletePodGracefully(ctx context.Context, namespace, name string) (*api.Pod, error) {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	key := r.generateKey(namespace, name)
+	var deleting *api.Pod
+	err := retryOnConflict(func() error {
+		pod, err := r.getPod(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
+		if pod.IsDeleting() {
+			deleting = pod
+			return nil
+		}
 
-	var pods []*api.Pod
-	if err := r.storage.List(ctx, podPrefix, &pods); err != nil {
+		old := *pod
+		if pod.NodeName != "" && !pod.IsMirrorPod() && !pod.Status.IsFinished() && !slices.Contains(pod.Finalizers, api.PodKubeletFinalizer) {
+			pod.Finalizers = append(slices.Clone(pod.Finalizers), api.PodKubeletFinalizer)
+		}
+		if len(pod.Finalizers) == 0 {
+			deleting = nil
+			return r.removePod(ctx, &old)
+		}
+		deletionTimestamp := time.Now().UTC()
+		pod.DeletionTimestamp = &deletionTimestamp
+		if !pod.Status.IsFinished() {
+			pod.Status = api.PodTerminating
+		}
+		err = r.writeIndexed(ctx, &old, pod, storage.UpdateOp(key, pod))
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, name)
+		}
+		deleting = pod
+		return conflictAs(err, ErrPodConflict)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleting, nil
+}
+
+// removePod removes pod, as it was read, with its index entries. It fails with ErrPodConflict if the pod was
+// changed since, and ErrPodNotFound if it is gone.
+func (r *PodRegistry) removePod(ctx context.Context, pod *api.Pod) error {
+	namespace := api.NamespaceOf(&pod.ObjectMeta)
+	err := r.writeIndexed(ctx, pod, nil, storage.DeleteObjectOp(r.generateKey(namespace, pod.Name), pod))
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, pod.Name)
+	}
+	return conflictAs(err, ErrPodConflict)
+}
+
+// ListPods retrieves the Pods of a namespace, or of all namespaces if namespace is empty, ordered by namespace
+// and name.
+// It returns a slice of Pod objects and an error if the listing fails.
+func (r *PodRegistry) ListPods(ctx context.Context, namespace string) ([]*api.Pod, error) {
+	pods, err := listOf[api.Pod](ctx, r.storage, namespacedPrefix(podPrefix, namespace))
+	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
 	}
 
 	return pods, nil
 }
 
-// listPodsByStatus retrieves all Pods with a specific status from the registry.
-// It returns a slice of Pod objects with the given status and an error if the listing fails.
-func (r *PodRegistry) listPodsByStatus(ctx context.Context, status api.PodStatus) ([]*api.Pod, error) {
-	pods, err := r.ListPods(ctx)
+// ListPodsWithRevision is ListPods that also returns the revision the Pods were read at, for WatchPods to follow
+// their changes from without missing any
+func (r *PodRegistry) ListPodsWithRevision(ctx context.Context, namespace string) ([]*api.Pod, int64, error) {
+	pods, revision, err := listWithRevisionOf[api.Pod](ctx, r.storage, namespacedPrefix(podPrefix, namespace))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	return pods, revision, nil
+}
+
+// PodEvent is a change of a Pod. For api.EventDeleted, Pod is the Pod as it was when deleted.
+type PodEvent struct {
+	Type api.EventType
+	Pod  *api.Pod
+}
+
+// WatchPods follows the changes of the Pods made after revision, that of ListPodsWithRevision, or from now on
+// for 0. The channel is closed when ctx is done or the watch ends, after which the caller lists the Pods again
+// and watches anew.
+func (r *PodRegistry) WatchPods(ctx context.Context, revision int64) (<-chan PodEvent, error) {
+	events, err := watchOf(ctx, r.storage, podPrefix, revision, func(eventType api.EventType, pod *api.Pod) PodEvent {
+		return PodEvent{Type: eventType, Pod: pod}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to watch pods: %v", ErrInternal, err)
+	}
+	return events, nil
+}
+
+// ListPodNames returns the names of the Pods of a namespace in name order, without reading the Pods. For an
+// empty namespace those of all namespaces are returned, ordered by namespace first, so a name may repeat.
+func (r *PodRegistry) ListPodNames(ctx context.Context, namespace string) ([]string, error) {
+	names, err := listNames(ctx, r.storage, namespacedPrefix(podPrefix, namespace))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
 	}
+	for i, name := range names {
+		names[i] = name[strings.LastIndex(name, "/")+1:]
+	}
+	return names, nil
+}
+
+// ListPodsPage retrieves the page of the Pods of a namespace, or of all namespaces if namespace is empty, that
+// options select, in namespace and name order, and returns the continue token of the next page, "" after the
+// last one. It fails with ErrInvalidContinue for a token that was not returned with a page of Pods.
+func (r *PodRegistry) ListPodsPage(ctx context.Context, namespace string, options storage.ListOptions) ([]*api.Pod, string, error) {
+	pods, next, err := listPageOf[api.Pod](ctx, r.storage, namespacedPrefix(podPrefix, namespace), options)
+	switch {
+	case errors.Is(err, storage.ErrInvalidContinue):
+		return nil, "", fmt.Errorf("%w: %q", ErrInvalidContinue, options.Continue)
+	case err != nil:
+		return nil, "", fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	return pods, next, nil
+}
 
-	filteredPods := make([]*api.Pod, 0)
+// CountPods returns the number of Pods. Storage counts them without reading any, so it stays cheap however
+// many Pods there are.
+func (r *PodRegistry) CountPods(ctx context.Context) (int64, error) {
+	count, err := r.storage.Count(ctx, podPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	return count, nil
+}
+
+// podStatusOnly is the part of a stored Pod CountPodsByStatus decodes, sparing the decoding of the rest
+type podStatusOnly struct {
+	Status api.PodStatus `json:"status"`
+}
+
+// CountPodsByStatus counts the Pods of each status. Only the status of the stored Pods is decoded, which
+// makes it much cheaper than listing them, e.g. to report metrics. Statuses without Pods are left out.
+func (r *PodRegistry) CountPodsByStatus(ctx context.Context) (map[api.PodStatus]int, error) {
+	pods, err := listOf[podStatusOnly](ctx, r.storage, podPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+
+	counts := make(map[api.PodStatus]int)
 	for _, pod := range pods {
-		if pod.Status == status {
-			filteredPods = append(filteredPods, pod)
-		}
+		counts[pod.Status]++
 	}
+	return counts, nil
+}
 
-	return filteredPods, nil
+// ListPodsByStatus retrieves all Pods with a specific status from the registry, through the index of their
+// statuses rather than by listing all Pods.
+// It returns ErrPodInvalid wrapping api.ErrInvalidPodStatus for a status that is not one of api.PodStatuses.
+func (r *PodRegistry) ListPodsByStatus(ctx context.Context, status api.PodStatus) ([]*api.Pod, error) {
+	if err := api.ValidatePodStatus(status); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPodInvalid, err)
+	}
+	return r.listIndexed(ctx, podStatusIndex, string(status))
+}
+
+// ListPodsByNode retrieves the Pods bound to the named node, through the index of their nodes rather than by
+// listing all Pods
+func (r *PodRegistry) ListPodsByNode(ctx context.Context, nodeName string) ([]*api.Pod, error) {
+	return r.listIndexed(ctx, podNodeIndex, nodeName)
+}
+
+// ListPodsBySelector retrieves the Pods of all namespaces whose labels hold every key and value of selector. An
+// empty selector selects all Pods.
+func (r *PodRegistry) ListPodsBySelector(ctx context.Context, selector map[string]string) ([]*api.Pod, error) {
+	pods, err := r.ListPods(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]*api.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if api.SelectorMatches(selector, pod.Labels) {
+			selected = append(selected, pod)
+		}
+	}
+	return selected, nil
 }
 
-// ListUnassignedPods retrieves all Pods with a status of PodPending from the registry.
+// ListUnassignedPods retrieves the Pods of all namespaces that are not bound to a node, whatever their status,
+// for the scheduler to bind. Finished Pods and Pods being deleted are left out, as they are never bound.
 // It returns a slice of unassigned Pod objects and an error if the listing fails.
 func (r *PodRegistry) ListUnassignedPods(ctx context.Context) ([]*api.Pod, error) {
-	return r.listPodsByStatus(ctx, api.PodPending)
+	pods, err := r.ListPods(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	unassigned := make([]*api.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.NodeName == "" && !pod.Status.IsFinished() && !pod.IsDeleting() {
+			unassigned = append(unassigned, pod)
+		}
+	}
+	return unassigned, nil
 }
 
 // ListPendingPods retrieves all Pods with a status of PodPending from the registry.
 // It returns a slice of pending Pod objects and an error if the listing fails.
 func (r *PodRegistry) ListPendingPods(ctx context.Context) ([]*api.Pod, error) {
-	return r.listPodsByStatus(ctx, api.PodPending)
+	return r.ListPodsByStatus(ctx, api.PodPending)
 }
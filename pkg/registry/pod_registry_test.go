@@ -4,10 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	mockStorage "gokube/mocks/pkg/storage"
 	"gokube/pkg/api"
+	"gokube/pkg/runtime"
 	"gokube/pkg/storage"
 
 	"github.com/stretchr/testify/assert"
@@ -18,66 +24,60 @@ import (
 )
 
 func TestNewPodRegistry(t *testing.T) {
-	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-		etcdStorage := storage.NewEtcdStorage(etcdServer)
-		registry := NewPodRegistry(etcdStorage)
+	memoryStorage := storage.NewMemoryStorage()
+	registry := NewPodRegistry(memoryStorage)
 
-		assert.NotNil(t, registry)
-		assert.Equal(t, etcdStorage, registry.storage)
-	})
+	assert.NotNil(t, registry)
+	assert.Equal(t, memoryStorage, registry.storage)
 }
 
 func TestPodRegistry_GetPod(t *testing.T) {
 	t.Run("should return pod if it exists", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
 
-			pod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "test-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:  "test-container",
-							Image: "nginx:latest",
-						},
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name: "test-pod",
+			},
+			Spec: api.PodSpec{
+				Containers: []api.Container{
+					{
+						Name:  "test-container",
+						Image: "nginx:latest",
 					},
-					Replicas: 3,
 				},
-				Status: api.PodPending,
-			}
+				Replicas: 3,
+			},
+			Status: api.PodPending,
+		}
 
-			err := registry.CreatePod(ctx, pod)
-			require.NoError(t, err)
+		err := registry.CreatePod(ctx, pod)
+		require.NoError(t, err)
 
-			// Test GetPod
-			retrievedPod, err := registry.GetPod(ctx, "test-pod")
-			require.NoError(t, err)
+		// Test GetPod
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+		require.NoError(t, err)
 
-			// Verify pod name and status
-			assert.Equal(t, "test-pod", retrievedPod.Name)
-			assert.Equal(t, api.PodPending, retrievedPod.Status)
+		// Verify pod name and status
+		assert.Equal(t, "test-pod", retrievedPod.Name)
+		assert.Equal(t, api.PodPending, retrievedPod.Status)
 
-			// Verify pod spec
-			assert.Len(t, retrievedPod.Spec.Containers, 1)
-			assert.Equal(t, "nginx:latest", retrievedPod.Spec.Containers[0].Image)
-			assert.Equal(t, int32(3), retrievedPod.Spec.Replicas)
-		})
+		// Verify pod spec
+		assert.Len(t, retrievedPod.Spec.Containers, 1)
+		assert.Equal(t, "nginx:latest", retrievedPod.Spec.Containers[0].Image)
+		assert.Equal(t, int32(3), retrievedPod.Spec.Replicas)
 	})
 
 	t.Run("should return error if pod does not exist", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
 
-			_, err := registry.GetPod(ctx, "non-existent-pod")
-			assert.ErrorIs(t, err, ErrPodNotFound)
-			assert.EqualError(t, err, "pod not found: non-existent-pod")
-		})
+		_, err := registry.GetPod(ctx, api.DefaultNamespace, "non-existent-pod")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+		assert.EqualError(t, err, "pod not found: default/non-existent-pod")
 	})
 
 	t.Run("should return error if storage returns ErrInternal", func(t *testing.T) {
@@ -90,209 +90,331 @@ func TestPodRegistry_GetPod(t *testing.T) {
 
 		mStorage.EXPECT().Get(ctx, gomock.Any(), gomock.Any()).Return(fmt.Errorf("storage error"))
 
-		_, err := registry.GetPod(ctx, "invalid-pod")
+		_, err := registry.GetPod(ctx, api.DefaultNamespace, "invalid-pod")
+		assert.ErrorIs(t, err, ErrInternal)
+	})
+}
+
+func TestPodRegistry_GetPods(t *testing.T) {
+	t.Run("should return the pods that exist and the names that are missing", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		for _, name := range []string{"web-1", "web-2", "web-3"} {
+			require.NoError(t, registry.CreatePod(ctx, newBatchTestPod(name)))
+		}
+
+		pods, missing, err := registry.GetPods(ctx, api.DefaultNamespace, []string{"web-3", "gone", "web-1", "web-3", "also-gone", "gone"})
+		require.NoError(t, err)
+		assert.Len(t, pods, 2)
+		assert.Equal(t, "web-1", pods["web-1"].Name)
+		assert.Equal(t, "web-3", pods["web-3"].Name)
+		assert.Equal(t, []string{"gone", "also-gone"}, missing, "missing names are listed once, in the order asked")
+
+		pods, missing, err = registry.GetPods(ctx, api.DefaultNamespace, nil)
+		require.NoError(t, err)
+		assert.Empty(t, pods)
+		assert.Empty(t, missing)
+	})
+
+	t.Run("should fail if storage fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mStorage := mockStorage.NewMockStorage(ctrl)
+		registry := NewPodRegistry(mStorage)
+		ctx := context.Background()
+
+		mStorage.EXPECT().Get(gomock.Any(), podPrefix+"default/web-1", gomock.Any()).Return(storage.ErrNotFound).AnyTimes()
+		mStorage.EXPECT().Get(gomock.Any(), podPrefix+"default/web-2", gomock.Any()).Return(fmt.Errorf("storage error"))
+
+		_, _, err := registry.GetPods(ctx, api.DefaultNamespace, []string{"web-1", "web-2"})
 		assert.ErrorIs(t, err, ErrInternal)
 	})
 }
 
+func TestPodRegistry_Namespaces(t *testing.T) {
+	registry := NewPodRegistry(storage.NewMemoryStorage())
+	ctx := context.Background()
+	inStaging := newBatchTestPod("web")
+	inStaging.Namespace = "staging"
+	require.NoError(t, registry.CreatePod(ctx, inStaging))
+	require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web")), "names only need to be unique in a namespace")
+
+	pod, err := registry.GetPod(ctx, "", "web")
+	require.NoError(t, err)
+	assert.Equal(t, api.DefaultNamespace, pod.Namespace, "a pod without a namespace is in the default one")
+	pod, err = registry.GetPod(ctx, "staging", "web")
+	require.NoError(t, err)
+	assert.Equal(t, inStaging.UID, pod.UID)
+	_, err = registry.GetPod(ctx, "prod", "web")
+	assert.ErrorIs(t, err, ErrPodNotFound)
+
+	pods, err := registry.ListPods(ctx, "staging")
+	require.NoError(t, err)
+	require.Len(t, pods, 1)
+	assert.Equal(t, "staging", pods[0].Namespace)
+	pods, err = registry.ListPods(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, pods, 2, "all namespaces are listed without one")
+	names, err := registry.ListPodNames(ctx, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, names)
+
+	require.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web", Namespace: "staging", Status: api.PodRunning}))
+	require.NoError(t, registry.DeletePod(ctx, "staging", "web"))
+	pod, err = registry.GetPod(ctx, api.DefaultNamespace, "web")
+	require.NoError(t, err)
+	assert.Equal(t, api.PodPending, pod.Status, "the pod of the other namespace is left alone")
+}
+
 func TestPodRegistry_CreatePod(t *testing.T) {
 	t.Run("should create pod", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
 
-			// Test Create
-			pod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "test-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name: "test-container", Image: "nginx:latest",
-						},
+		// Test Create
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name: "test-pod",
+			},
+			Spec: api.PodSpec{
+				Containers: []api.Container{
+					{
+						Name: "test-container", Image: "nginx:latest",
 					},
-					Replicas: 3,
 				},
-				Status: api.PodPending,
-			}
+				Replicas: 3,
+			},
+			Status: api.PodPending,
+		}
 
-			err := registry.CreatePod(ctx, pod)
-			require.NoError(t, err)
+		err := registry.CreatePod(ctx, pod)
+		require.NoError(t, err)
 
-			// Verify pod was created
-			_, err = registry.GetPod(ctx, "test-pod")
-			require.NoError(t, err)
-		})
+		// Verify pod was created
+		_, err = registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+		require.NoError(t, err)
 	})
 
 	t.Run("should fail to create pod with the same name", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
 
-			// Create the first pod
-			pod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "duplicate-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:  "test-container",
-							Image: "nginx:latest",
-						},
+		// Create the first pod
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name: "duplicate-pod",
+			},
+			Spec: api.PodSpec{
+				Containers: []api.Container{
+					{
+						Name:  "test-container",
+						Image: "nginx:latest",
 					},
-					Replicas: 3,
 				},
-				Status: api.PodPending,
-			}
+				Replicas: 3,
+			},
+			Status: api.PodPending,
+		}
 
-			err := registry.CreatePod(ctx, pod)
-			require.NoError(t, err)
+		err := registry.CreatePod(ctx, pod)
+		require.NoError(t, err)
 
-			// Attempt to create another pod with the same name
-			err = registry.CreatePod(ctx, pod)
-			assert.ErrorIs(t, err, ErrPodAlreadyExists)
-			assert.EqualError(t, err, "pod already exists: duplicate-pod")
-		})
+		// Attempt to create another pod with the same name, without the UID the first one was given
+		pod.UID = ""
+		err = registry.CreatePod(ctx, pod)
+		assert.ErrorIs(t, err, ErrPodAlreadyExists)
+		assert.EqualError(t, err, "pod already exists: default/duplicate-pod")
 	})
 
 	t.Run("should set default status when pod status is not provided", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
 
-			// Create a pod without specifying the status
-			pod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "no-status-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:  "test-container",
-							Image: "nginx:latest",
-						},
+		// Create a pod without specifying the status
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name: "no-status-pod",
+			},
+			Spec: api.PodSpec{
+				Containers: []api.Container{
+					{
+						Name:  "test-container",
+						Image: "nginx:latest",
 					},
-					Replicas: 3,
 				},
-			}
+				Replicas: 3,
+			},
+		}
 
-			err := registry.CreatePod(ctx, pod)
-			require.NoError(t, err)
+		err := registry.CreatePod(ctx, pod)
+		require.NoError(t, err)
 
-			// Verify pod was created with default status
-			retrievedPod, err := registry.GetPod(ctx, "no-status-pod")
-			require.NoError(t, err)
-			assert.Equal(t, api.PodPending, retrievedPod.Status)
-		})
+		// Verify pod was created with default status
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "no-status-pod")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodPending, retrievedPod.Status)
 	})
 
-	t.Run("should validate pod spec", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
-
-			// Create a pod with an invalid spec
-			pod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "invalid-spec-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:  "test-container",
-							Image: "", // Invalid because image is empty
-						},
-					},
-					Replicas: 3,
-				},
+	t.Run("should assign a UID, creation time and grace period to a pod", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		newPod := func(uid string) *api.Pod {
+			return &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: "web", UID: uid},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
 			}
+		}
 
-			err := registry.CreatePod(ctx, pod)
-			assert.ErrorIs(t, err, ErrPodInvalid)
-		})
+		require.NoError(t, registry.CreatePod(ctx, newPod("")))
+		first, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.NotEmpty(t, first.UID)
+		assert.WithinDuration(t, time.Now(), first.CreationTimestamp, time.Minute)
+		assert.Equal(t, time.UTC, first.CreationTimestamp.Location())
+		require.NotNil(t, first.Spec.TerminationGracePeriodSeconds)
+		assert.Equal(t, api.DefaultTerminationGracePeriodSeconds, *first.Spec.TerminationGracePeriodSeconds)
+
+		// A pod recreated under the same name gets a different UID
+		require.NoError(t, registry.DeletePod(ctx, api.DefaultNamespace, "web"))
+		require.NoError(t, registry.CreatePod(ctx, newPod("")))
+		second, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.NotEqual(t, first.UID, second.UID)
+
+		// Only the mirror of a static pod may bring its own UID, the one of the static pod
+		require.NoError(t, registry.DeletePod(ctx, api.DefaultNamespace, "web"))
+		err = registry.CreatePod(ctx, newPod("client-uid"))
+		assert.ErrorIs(t, err, ErrPodInvalid)
+		assert.ErrorContains(t, err, "uid is assigned by the server")
+		mirror := newPod("static-uid")
+		mirror.Labels = map[string]string{api.MirrorPodLabel: "node-1"}
+		require.NoError(t, registry.CreatePod(ctx, mirror))
+		third, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, "static-uid", third.UID)
 	})
-}
 
-func TestPodRegistry_UpdatePod(t *testing.T) {
-	t.Run("should update pod status", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
-			ctx := context.Background()
+	t.Run("should keep the UID and creation time of a pod updated without them", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web")))
+		created, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
 
-			pod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "test-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name: "test-container", Image: "nginx:latest",
-						},
-					},
-					Replicas: 3,
-				},
-				Status: api.PodPending,
-			}
+		update := newBatchTestPod("web")
+		update.Status = api.PodRunning
+		require.NoError(t, registry.UpdatePod(ctx, update))
+		updated, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, created.UID, updated.UID)
+		assert.True(t, created.CreationTimestamp.Equal(updated.CreationTimestamp))
+	})
 
-			err := registry.CreatePod(ctx, pod)
-			require.NoError(t, err)
+	t.Run("should validate pod spec", func(t *testing.T) {
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
 
-			// Update pod status
-			pod.Status = api.PodRunning
-			err = registry.UpdatePod(ctx, pod)
-			require.NoError(t, err)
+		// Create a pod with an invalid spec
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{
+				Name: "invalid-spec-pod",
+			},
+			Spec: api.PodSpec{
+				Containers: []api.Container{
+					{
+						Name:  "test-container",
+						Image: "", // Invalid because image is empty
+					},
+				},
+				Replicas: 3,
+			},
+		}
 
-			// Verify updated status
-			retrievedPod, err := registry.GetPod(ctx, "test-pod")
-			require.NoError(t, err)
-			assert.Equal(t, api.PodRunning, retrievedPod.Status)
-		})
+		err := registry.CreatePod(ctx, pod)
+		assert.ErrorIs(t, err, ErrPodInvalid)
+		var fieldErrs api.FieldErrors
+		require.ErrorAs(t, err, &fieldErrs)
+		assert.Equal(t, api.FieldErrors{{Field: "spec.containers[0].image", Message: "is required"}}, fieldErrs)
 	})
-	t.Run("should validate pod spec on update", func(t *testing.T) {
+	t.Run("should create a pod once when created concurrently", func(t *testing.T) {
 		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewPodRegistry(etcdStorage)
 			ctx := context.Background()
-
-			validPod := &api.Pod{
-				ObjectMeta: api.ObjectMeta{
-					Name: "valid-pod",
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:  "test-container",
-							Image: "nginx:latest",
-						},
-					},
-					Replicas: 3,
-				},
-				Status: api.PodPending,
+			const creators = 10
+
+			// Every creator has its own registry, like API servers sharing etcd, so only the storage serializes them
+			var wg sync.WaitGroup
+			errs := make([]error, creators)
+			for i := range creators {
+				registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = registry.CreatePod(ctx, &api.Pod{
+						ObjectMeta: api.ObjectMeta{Name: "web", Labels: map[string]string{"creator": fmt.Sprint(i)}},
+						Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx:latest"}}},
+					})
+				}()
 			}
-
-			err := registry.CreatePod(ctx, validPod)
+			wg.Wait()
+
+			created := -1
+			for i, err := range errs {
+				if err == nil {
+					assert.Equal(t, -1, created, "only one create succeeds")
+					created = i
+					continue
+				}
+				assert.ErrorIs(t, err, ErrPodAlreadyExists)
+			}
+			require.NotEqual(t, -1, created, "one create succeeds")
+			pod, err := NewPodRegistry(storage.NewEtcdStorage(etcdServer)).GetPod(ctx, api.DefaultNamespace, "web")
 			require.NoError(t, err)
-
-			// Update pod with invalid spec
-			validPod.Spec.Containers[0].Image = "" // Invalid because image is empty
-			err = registry.UpdatePod(ctx, validPod)
-			assert.ErrorIs(t, err, ErrPodInvalid)
+			assert.Equal(t, fmt.Sprint(created), pod.Labels["creator"], "the pod of the create that succeeded is stored")
 		})
 	})
+	t.Run("should create pods with other writes or none of them", func(t *testing.T) {
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-2")))
+
+		err := registry.CreatePodsWith(ctx, []*api.Pod{newBatchTestPod("web-1"), newBatchTestPod("web-2")},
+			storage.UpdateOp("/other", &api.Pod{}))
+		assert.ErrorIs(t, err, ErrPodAlreadyExists)
+		assert.EqualError(t, err, "pod already exists: default/web-2")
+		_, err = registry.GetPod(ctx, api.DefaultNamespace, "web-1")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+		assert.ErrorIs(t, memoryStorage.Get(ctx, "/other", &api.Pod{}), storage.ErrNotFound)
+
+		pods := []*api.Pod{newBatchTestPod("web-1"), newBatchTestPod("web-3")}
+		require.NoError(t, registry.CreatePodsWith(ctx, pods, storage.UpdateOp("/other", &api.Pod{})))
+		assert.Equal(t, api.PodPending, pods[0].Status)
+		assert.NotEmpty(t, pods[0].UID)
+		require.NoError(t, memoryStorage.Get(ctx, "/other", &api.Pod{}))
+		pending, err := registry.ListPendingPods(ctx)
+		require.NoError(t, err)
+		assert.Len(t, pending, 3, "the index entries are written with the pods")
+
+		stale := &api.Pod{ObjectMeta: api.ObjectMeta{ResourceVersion: "1"}}
+		err = registry.CreatePodsWith(ctx, []*api.Pod{newBatchTestPod("web-4")}, storage.UpdateOp("/other", stale))
+		var txnErr *storage.TxnError
+		require.ErrorAs(t, err, &txnErr)
+		assert.Equal(t, "/other", txnErr.Key)
+		assert.ErrorIs(t, err, storage.ErrConflict)
+		_, err = registry.GetPod(ctx, api.DefaultNamespace, "web-4")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+	})
 }
 
-func TestPodRegistry_DeletePod(t *testing.T) {
-	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-		etcdStorage := storage.NewEtcdStorage(etcdServer)
-		registry := NewPodRegistry(etcdStorage)
+func TestPodRegistry_UpdatePod(t *testing.T) {
+	t.Run("should update pod status", func(t *testing.T) {
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
 		ctx := context.Background()
 
 		pod := &api.Pod{
@@ -302,8 +424,7 @@ func TestPodRegistry_DeletePod(t *testing.T) {
 			Spec: api.PodSpec{
 				Containers: []api.Container{
 					{
-						Name:  "test-container",
-						Image: "nginx:latest",
+						Name: "test-container", Image: "nginx:latest",
 					},
 				},
 				Replicas: 3,
@@ -314,31 +435,67 @@ func TestPodRegistry_DeletePod(t *testing.T) {
 		err := registry.CreatePod(ctx, pod)
 		require.NoError(t, err)
 
-		err = registry.DeletePod(ctx, "test-pod")
+		// Update pod status
+		pod.Status = api.PodRunning
+		err = registry.UpdatePod(ctx, pod)
 		require.NoError(t, err)
 
-		_, err = registry.GetPod(ctx, "test-pod")
-		assert.Error(t, err)
+		// Verify updated status
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodRunning, retrievedPod.Status)
 	})
-}
+	t.Run("should only move a pod to a status it can reach", func(t *testing.T) {
+		for _, from := range api.PodStatuses {
+			for _, to := range api.PodStatuses {
+				t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+					registry := NewPodRegistry(storage.NewMemoryStorage())
+					ctx := context.Background()
+					pod := newBatchTestPod("web")
+					pod.Status = from
+					require.NoError(t, registry.CreatePod(ctx, pod))
+
+					update := newBatchTestPod("web")
+					update.Status = to
+					err := registry.UpdatePod(ctx, update)
+					retrievedPod, getErr := registry.GetPod(ctx, api.DefaultNamespace, "web")
+					require.NoError(t, getErr)
+					if from.CanTransitionTo(to) {
+						require.NoError(t, err)
+						assert.Equal(t, to, retrievedPod.Status)
+					} else {
+						assert.ErrorIs(t, err, ErrInvalidTransition)
+						assert.Equal(t, from, retrievedPod.Status, "a refused update leaves the pod as it was")
+					}
+				})
+			}
+		}
 
-func TestPodRegistry_ListPods(t *testing.T) {
-	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-		etcdStorage := storage.NewEtcdStorage(etcdServer)
-		registry := NewPodRegistry(etcdStorage)
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		finished := newBatchTestPod("web")
+		finished.Status = api.PodSucceeded
+		require.NoError(t, registry.CreatePod(ctx, finished))
+		update := newBatchTestPod("web")
+		update.Spec.Containers[0].Image = "nginx:1.27"
+		require.NoError(t, registry.UpdatePod(ctx, update), "an update without a status keeps the one the pod has")
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodSucceeded, retrievedPod.Status)
+	})
+	t.Run("should validate pod spec on update", func(t *testing.T) {
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewPodRegistry(memoryStorage)
 		ctx := context.Background()
 
-		// Test cases
-
-		// Test ListPods
-		pod1 := &api.Pod{
+		validPod := &api.Pod{
 			ObjectMeta: api.ObjectMeta{
-				Name: "test-pod-1",
+				Name: "valid-pod",
 			},
 			Spec: api.PodSpec{
 				Containers: []api.Container{
 					{
-						Name:  "test-container-1",
+						Name:  "test-container",
 						Image: "nginx:latest",
 					},
 				},
@@ -347,35 +504,537 @@ func TestPodRegistry_ListPods(t *testing.T) {
 			Status: api.PodPending,
 		}
 
-		pod2 := &api.Pod{
+		err := registry.CreatePod(ctx, validPod)
+		require.NoError(t, err)
+
+		// Update pod with invalid spec
+		validPod.Spec.Containers[0].Image = "" // Invalid because image is empty
+		err = registry.UpdatePod(ctx, validPod)
+		assert.ErrorIs(t, err, ErrPodInvalid)
+	})
+	t.Run("should reject structural changes to the spec", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx:latest"}}},
+		}
+		require.NoError(t, registry.CreatePod(ctx, pod))
+
+		renamed := *pod
+		renamed.Spec.Containers = []api.Container{{Name: "nginx", Image: "nginx:latest"}}
+		assert.ErrorIs(t, registry.UpdatePod(ctx, &renamed), ErrPodImmutable)
+
+		// A new image, a label and the binding may change; the grace period that is left out is kept
+		updated := *pod
+		updated.Labels = map[string]string{"app": "web"}
+		updated.Spec.Containers = []api.Container{{Name: "web", Image: "nginx:1.27"}}
+		updated.Spec.TerminationGracePeriodSeconds = nil
+		updated.NodeName = "node-1"
+		require.NoError(t, registry.UpdatePod(ctx, &updated))
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.27", retrievedPod.Spec.Containers[0].Image)
+		assert.Equal(t, api.DefaultTerminationGracePeriodSeconds, *retrievedPod.Spec.TerminationGracePeriodSeconds)
+
+		updated.NodeName = "node-2"
+		assert.ErrorIs(t, registry.UpdatePod(ctx, &updated), ErrPodImmutable, "a bound pod cannot move")
+	})
+	t.Run("should reject updates of mirror pods", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+
+		mirrorPod := &api.Pod{
 			ObjectMeta: api.ObjectMeta{
-				Name: "test-pod-2",
+				Name:   "web-node-1",
+				Labels: map[string]string{api.MirrorPodLabel: "node-1"},
 			},
 			Spec: api.PodSpec{
-				Containers: []api.Container{
-					{
-						Name:  "test-container-2",
-						Image: "nginx:latest",
-					},
+				Containers: []api.Container{{Name: "web", Image: "nginx:latest"}},
+			},
+			NodeName: "node-1",
+			Status:   api.PodScheduled,
+		}
+		require.NoError(t, registry.CreatePod(ctx, mirrorPod))
+
+		mirrorPod.Spec.Containers[0].Image = "nginx:1.19"
+		err := registry.UpdatePod(ctx, mirrorPod)
+		assert.ErrorIs(t, err, ErrMirrorPodReadOnly)
+
+		// The kubelet running it still reports its status
+		err = registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web-node-1", NodeName: "node-1", Status: api.PodRunning})
+		require.NoError(t, err)
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "web-node-1")
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:latest", retrievedPod.Spec.Containers[0].Image)
+		assert.Equal(t, api.PodRunning, retrievedPod.Status)
+	})
+	t.Run("should reject an update of a stale version", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+
+		require.NoError(t, registry.CreatePod(ctx, &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "web"},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx:latest"}}},
+		}))
+		pod, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+
+		// The kubelet reports a status after the pod was read
+		require.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web", Status: api.PodRunning}))
+
+		pod.Spec.Containers[0].Image = "nginx:1.27"
+		err = registry.UpdatePod(ctx, pod)
+		assert.ErrorIs(t, err, ErrPodConflict)
+		assert.ErrorIs(t, err, storage.ErrConflict)
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodRunning, retrievedPod.Status, "the status is not overwritten")
+		assert.Equal(t, "nginx:latest", retrievedPod.Spec.Containers[0].Image)
+
+		retrievedPod.Spec.Containers[0].Image = "nginx:1.27"
+		require.NoError(t, registry.UpdatePod(ctx, retrievedPod), "the current version may be updated")
+	})
+}
+
+func TestPodRegistry_UpdatePod_NotFound(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		ctx := context.Background()
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+
+		err := registry.UpdatePod(ctx, newBatchTestPod("never-created"))
+		assert.ErrorIs(t, err, ErrPodNotFound)
+
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("deleted")))
+		pod, err := registry.GetPod(ctx, api.DefaultNamespace, "deleted")
+		require.NoError(t, err)
+		require.NoError(t, registry.DeletePod(ctx, api.DefaultNamespace, "deleted"))
+		pod.ResourceVersion = ""
+		pod.Status = api.PodRunning
+		assert.ErrorIs(t, registry.UpdatePod(ctx, pod), ErrPodNotFound)
+
+		for _, name := range []string{"never-created", "deleted"} {
+			resp, err := etcdServer.Get(ctx, podPrefix+"default/"+name)
+			require.NoError(t, err)
+			assert.Zero(t, resp.Count, "the update does not recreate %s", name)
+		}
+	})
+}
+
+func TestPodRegistry_UpdatePodStatus(t *testing.T) {
+	newBoundPod := func() *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: "test-pod"},
+			Spec: api.PodSpec{
+				Containers: []api.Container{{Name: "test-container", Image: "nginx:latest"}},
+			},
+			NodeName: "node-1",
+			Status:   api.PodScheduled,
+		}
+	}
+
+	t.Run("should update the status without touching the spec", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		pod := newBoundPod()
+		require.NoError(t, registry.CreatePod(ctx, pod))
+
+		err := registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{
+			Name:              "test-pod",
+			UID:               pod.UID,
+			NodeName:          "node-1",
+			Status:            api.PodRunning,
+			ContainerStatuses: []api.ContainerStatus{{Name: "test-container", State: api.ContainerRunning}},
+		})
+		require.NoError(t, err)
+
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodRunning, retrievedPod.Status)
+		assert.Len(t, retrievedPod.ContainerStatuses, 1)
+		assert.Equal(t, "nginx:latest", retrievedPod.Spec.Containers[0].Image)
+		assert.Equal(t, "node-1", retrievedPod.NodeName)
+	})
+
+	t.Run("should keep a node binding made while the status is written", func(t *testing.T) {
+		memoryStorage := storage.NewMemoryStorage()
+		scheduler := NewPodRegistry(memoryStorage)
+		ctx := context.Background()
+		require.NoError(t, scheduler.CreatePod(ctx, newBatchTestPod("test-pod")))
+
+		// The scheduler binds the pod between the read and the write of the status
+		bound := false
+		chaosStorage := storage.NewChaosStorage(memoryStorage, func(call storage.Call) storage.Fault {
+			if call.Op == storage.OpUpdate && call.Key == "/pods/default/test-pod" && !bound {
+				bound = true
+				pod, err := scheduler.GetPod(ctx, api.DefaultNamespace, "test-pod")
+				require.NoError(t, err)
+				pod.NodeName = "node-1"
+				require.NoError(t, scheduler.UpdatePod(ctx, pod))
+			}
+			return storage.Fault{}
+		})
+		registry := NewPodRegistry(chaosStorage)
+
+		require.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{
+			Name:              "test-pod",
+			Status:            api.PodRunning,
+			ContainerStatuses: []api.ContainerStatus{{Name: "nginx", State: api.ContainerRunning}},
+		}))
+
+		assert.True(t, bound)
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+		require.NoError(t, err)
+		assert.Equal(t, "node-1", retrievedPod.NodeName)
+		assert.Equal(t, api.PodRunning, retrievedPod.Status)
+		assert.Len(t, retrievedPod.ContainerStatuses, 1)
+	})
+
+	t.Run("should reject updates for a recreated or rebound pod", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		pod := newBoundPod()
+		require.NoError(t, registry.CreatePod(ctx, pod))
+
+		err := registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "test-pod", UID: "uid-0", NodeName: "node-1", Status: api.PodRunning})
+		assert.ErrorIs(t, err, ErrPodConflict)
+
+		err = registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "test-pod", UID: pod.UID, NodeName: "node-2", Status: api.PodRunning})
+		assert.ErrorIs(t, err, ErrPodConflict)
+
+		retrievedPod, err := registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodScheduled, retrievedPod.Status)
+	})
+
+	t.Run("should only move a pod to a status it can reach", func(t *testing.T) {
+		for _, from := range api.PodStatuses {
+			for _, to := range append(slices.Clone(api.PodStatuses), "", "Bogus") {
+				t.Run(string(from)+" to "+string(to), func(t *testing.T) {
+					registry := NewPodRegistry(storage.NewMemoryStorage())
+					ctx := context.Background()
+					pod := newBatchTestPod("web")
+					pod.Status = from
+					require.NoError(t, registry.CreatePod(ctx, pod))
+
+					err := registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web", Status: to})
+					retrievedPod, getErr := registry.GetPod(ctx, api.DefaultNamespace, "web")
+					require.NoError(t, getErr)
+					switch {
+					case api.ValidatePodStatus(to) != nil:
+						assert.ErrorIs(t, err, ErrPodInvalid)
+						assert.Equal(t, from, retrievedPod.Status, "an unknown status is never stored")
+					case from.CanTransitionTo(to):
+						require.NoError(t, err)
+						assert.Equal(t, to, retrievedPod.Status)
+					default:
+						assert.ErrorIs(t, err, ErrInvalidTransition)
+						assert.Equal(t, from, retrievedPod.Status, "a refused update leaves the pod as it was")
+					}
+				})
+			}
+		}
+	})
+
+	t.Run("should fail for a missing pod", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+
+		err := registry.UpdatePodStatus(context.Background(), &api.PodStatusUpdate{Name: "missing"})
+		assert.ErrorIs(t, err, ErrPodNotFound)
+	})
+}
+
+func TestPodRegistry_DeletePod(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	registry := NewPodRegistry(memoryStorage)
+	ctx := context.Background()
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name: "test-pod",
+		},
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{
+					Name:  "test-container",
+					Image: "nginx:latest",
 				},
-				Replicas: 3,
 			},
-			Status: api.PodRunning,
+			Replicas: 3,
+		},
+		Status: api.PodPending,
+	}
+
+	err := registry.CreatePod(ctx, pod)
+	require.NoError(t, err)
+
+	err = registry.DeletePod(ctx, api.DefaultNamespace, "test-pod")
+	require.NoError(t, err)
+
+	_, err = registry.GetPod(ctx, api.DefaultNamespace, "test-pod")
+	assert.Error(t, err)
+
+	// A second delete finds nothing to delete
+	err = registry.DeletePod(ctx, api.DefaultNamespace, "test-pod")
+	assert.ErrorIs(t, err, ErrPodNotFound)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, IgnoreNotFound(err))
+	assert.ErrorIs(t, registry.DeletePod(ctx, api.DefaultNamespace, "never-created"), ErrPodNotFound)
+}
+
+func TestPodRegistry_DeletePodGracefully(t *testing.T) {
+	newPod := func(name, nodeName string, status api.PodStatus) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: name},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			NodeName:   nodeName,
+			Status:     status,
+		}
+	}
+
+	t.Run("should remove a pod no kubelet runs at once", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		require.NoError(t, registry.CreatePod(ctx, newPod("web", "", api.PodPending)))
+
+		pod, err := registry.DeletePodGracefully(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Nil(t, pod)
+		_, err = registry.GetPod(ctx, api.DefaultNamespace, "web")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+		_, err = registry.DeletePodGracefully(ctx, api.DefaultNamespace, "web")
+		assert.ErrorIs(t, err, ErrPodNotFound)
+	})
+
+	t.Run("should keep a bound pod until the kubelet removes its finalizer", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		require.NoError(t, registry.CreatePod(ctx, newPod("web", "node-1", api.PodRunning)))
+
+		pod, err := registry.DeletePodGracefully(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		require.NotNil(t, pod)
+		require.NotNil(t, pod.DeletionTimestamp)
+		assert.Equal(t, api.PodTerminating, pod.Status)
+		assert.Equal(t, []string{api.PodKubeletFinalizer}, pod.Finalizers)
+		assert.False(t, pod.IsActive())
+
+		again, err := registry.DeletePodGracefully(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, pod.DeletionTimestamp, again.DeletionTimestamp, "deleting twice keeps the first timestamp")
+		terminating, err := registry.ListPodsByStatus(ctx, api.PodTerminating)
+		require.NoError(t, err)
+		assert.Len(t, terminating, 1)
+
+		// Neither a status nor an update brings the pod back
+		require.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web", Status: api.PodRunning}))
+		stored, err := registry.GetPod(ctx, api.DefaultNamespace, "web")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodTerminating, stored.Status)
+		stored.Finalizers = append(stored.Finalizers, "example.com/backup")
+		assert.ErrorIs(t, registry.UpdatePod(ctx, stored), ErrPodImmutable)
+
+		require.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{
+			Name:             "web",
+			Status:           api.PodTerminating,
+			RemoveFinalizers: []string{api.PodKubeletFinalizer},
+		}))
+		_, err = registry.GetPod(ctx, api.DefaultNamespace, "web")
+		assert.ErrorIs(t, err, ErrPodNotFound, "the pod is removed with its last finalizer")
+		terminating, err = registry.ListPodsByStatus(ctx, api.PodTerminating)
+		require.NoError(t, err)
+		assert.Empty(t, terminating)
+	})
+
+	t.Run("should keep the status of a finished pod", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		pod := newPod("job", "node-1", api.PodSucceeded)
+		pod.Finalizers = []string{"example.com/backup"}
+		require.NoError(t, registry.CreatePod(ctx, pod))
+
+		deleting, err := registry.DeletePodGracefully(ctx, api.DefaultNamespace, "job")
+		require.NoError(t, err)
+		assert.Equal(t, api.PodSucceeded, deleting.Status)
+		assert.Equal(t, []string{"example.com/backup"}, deleting.Finalizers, "no kubelet has to stop a finished pod")
+	})
+
+	t.Run("should refuse a pod created as deleted", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		pod := newPod("web", "", api.PodPending)
+		now := time.Now()
+		pod.DeletionTimestamp = &now
+		assert.ErrorIs(t, registry.CreatePod(context.Background(), pod), ErrPodInvalid)
+	})
+}
+
+// TestPodRegistry_ConcurrentWrites binds pods and reports their status at the same time, as the scheduler and
+// the kubelets do, while the pending pods are listed. Without a lock in the registry it is the storage
+// transactions that must keep both changes of every pod and its index entries; run it with -race.
+func TestPodRegistry_ConcurrentWrites(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+		const pods = 20
+
+		var wg sync.WaitGroup
+		for i := range pods {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, registry.CreatePod(ctx, newBatchTestPod(fmt.Sprintf("web-%d", i))))
+			}()
+		}
+		wg.Wait()
+
+		done := make(chan struct{})
+		listed := make(chan struct{})
+		go func() {
+			defer close(listed)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_, err := registry.ListPendingPods(ctx)
+				assert.NoError(t, err)
+			}
+		}()
+		for i := range pods {
+			name := fmt.Sprintf("web-%d", i)
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				// A bind without a resource version or status keeps whatever status the pod has by then
+				pod, err := registry.GetPod(ctx, api.DefaultNamespace, name)
+				if !assert.NoError(t, err) {
+					return
+				}
+				pod.NodeName = fmt.Sprintf("node-%d", i%3)
+				pod.ResourceVersion, pod.Status = "", ""
+				assert.NoError(t, registry.UpdatePod(ctx, pod))
+			}()
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, registry.UpdatePodStatus(ctx, &api.PodStatusUpdate{
+					Name:   name,
+					Status: api.PodRunning,
+				}))
+			}()
+		}
+		wg.Wait()
+		close(done)
+		<-listed
+
+		all, err := registry.ListPods(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, all, pods)
+		for _, pod := range all {
+			assert.NotEmpty(t, pod.NodeName, "the binding of %s is kept", pod.Name)
+			assert.Equal(t, api.PodRunning, pod.Status, "the status of %s is kept", pod.Name)
 		}
+		bound := 0
+		for node := range 3 {
+			onNode, err := registry.ListPodsByNode(ctx, fmt.Sprintf("node-%d", node))
+			require.NoError(t, err)
+			bound += len(onNode)
+		}
+		assert.Equal(t, pods, bound)
+		running, err := registry.ListPodsByStatus(ctx, api.PodRunning)
+		require.NoError(t, err)
+		assert.Len(t, running, pods)
+		pending, err := registry.ListPendingPods(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		fixed, err := registry.ReconcileIndex(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, fixed, "the index is in step with the pods")
+	})
+}
+
+func TestPodRegistry_ListPods(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	registry := NewPodRegistry(memoryStorage)
+	ctx := context.Background()
+
+	// Test cases
+
+	// Test ListPods
+	pod1 := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name: "test-pod-1",
+		},
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{
+					Name:  "test-container-1",
+					Image: "nginx:latest",
+				},
+			},
+			Replicas: 3,
+		},
+		Status: api.PodPending,
+	}
+
+	pod2 := &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name: "test-pod-2",
+		},
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{
+					Name:  "test-container-2",
+					Image: "nginx:latest",
+				},
+			},
+			Replicas: 3,
+		},
+		Status: api.PodRunning,
+	}
+
+	err := registry.CreatePod(ctx, pod1)
+	require.NoError(t, err)
+
+	err = registry.CreatePod(ctx, pod2)
+	require.NoError(t, err)
+
+	pods, err := registry.ListPods(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, pods, 2)
+
+	// Verify pod names
+	assert.Equal(t, "test-pod-1", pods[0].Name)
+	assert.Equal(t, "test-pod-2", pods[1].Name)
 
-		err := registry.CreatePod(ctx, pod1)
+	t.Run("should list the pods a page at a time", func(t *testing.T) {
+		page, next, err := registry.ListPodsPage(ctx, "", storage.ListOptions{Limit: 1})
 		require.NoError(t, err)
+		require.Len(t, page, 1)
+		assert.Equal(t, "test-pod-1", page[0].Name)
+		require.NotEmpty(t, next)
 
-		err = registry.CreatePod(ctx, pod2)
+		page, next, err = registry.ListPodsPage(ctx, "", storage.ListOptions{Limit: 1, Continue: next})
 		require.NoError(t, err)
+		require.Len(t, page, 1)
+		assert.Equal(t, "test-pod-2", page[0].Name)
+		assert.Empty(t, next)
 
-		pods, err := registry.ListPods(ctx)
+		_, _, err = registry.ListPodsPage(ctx, "", storage.ListOptions{Continue: "bogus"})
+		assert.ErrorIs(t, err, ErrInvalidContinue)
+	})
+
+	t.Run("should list the pod names without the index entries", func(t *testing.T) {
+		names, err := registry.ListPodNames(ctx, "")
 		require.NoError(t, err)
-		require.Len(t, pods, 2)
+		assert.Equal(t, []string{"test-pod-1", "test-pod-2"}, names)
+	})
 
-		// Verify pod names
-		assert.Equal(t, "test-pod-1", pods[0].Name)
-		assert.Equal(t, "test-pod-2", pods[1].Name)
+	t.Run("should count the pods without the index entries", func(t *testing.T) {
+		count, err := registry.CountPods(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
 	})
 
 	t.Run("should handle error returned by the storage provider", func(t *testing.T) {
@@ -386,15 +1045,217 @@ func TestPodRegistry_ListPods(t *testing.T) {
 		registry := NewPodRegistry(mStorage)
 		ctx := context.Background()
 
-		mStorage.EXPECT().List(ctx, podPrefix, gomock.Any()).Return(errors.New("failed to list pods"))
+		mStorage.EXPECT().ListFunc(ctx, podPrefix, gomock.Any(), gomock.Any()).Return(errors.New("failed to list pods"))
 
-		pods, err := registry.ListPods(ctx)
+		pods, err := registry.ListPods(ctx, "")
 
 		assert.ErrorIs(t, err, ErrListPodsFailed, "Expected error when listing pods")
 		assert.Nil(t, pods, "Expected nil list of pods")
 	})
 }
 
+func TestPodRegistry_ListPods_CorruptValue(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx := context.Background()
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-1")))
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-2")))
+		_, err := etcdServer.Put(ctx, podPrefix+"default/garbage", "\x00not a pod")
+		require.NoError(t, err)
+
+		pods, err := registry.ListPods(ctx, "")
+		require.NoError(t, err, "a corrupt value is skipped rather than failing the list")
+		require.Len(t, pods, 2)
+		assert.Equal(t, "web-1", pods[0].Name)
+		assert.Equal(t, "web-2", pods[1].Name)
+
+		page, _, err := registry.ListPodsPage(ctx, "", storage.ListOptions{})
+		require.NoError(t, err)
+		assert.Len(t, page, 2)
+	})
+}
+
+func TestPodRegistry_WatchPods(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		registry := NewPodRegistry(storage.NewEtcdStorage(etcdServer))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		events, err := registry.WatchPods(ctx, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-1")))
+		pod, err := registry.GetPod(ctx, api.DefaultNamespace, "web-1")
+		require.NoError(t, err)
+		pod.Spec.Containers[0].Image = "nginx:1.27"
+		require.NoError(t, registry.UpdatePod(ctx, pod))
+		require.NoError(t, registry.DeletePod(ctx, api.DefaultNamespace, "web-1"))
+
+		event := nextEvent(t, ctx, events)
+		assert.Equal(t, api.EventAdded, event.Type)
+		assert.Equal(t, "web-1", event.Pod.Name)
+		assert.Equal(t, "nginx:latest", event.Pod.Spec.Containers[0].Image)
+
+		event = nextEvent(t, ctx, events)
+		assert.Equal(t, api.EventModified, event.Type)
+		assert.Equal(t, "nginx:1.27", event.Pod.Spec.Containers[0].Image)
+		assert.Equal(t, pod.ResourceVersion, event.Pod.ResourceVersion)
+
+		event = nextEvent(t, ctx, events)
+		assert.Equal(t, api.EventDeleted, event.Type)
+		assert.Equal(t, "web-1", event.Pod.Name)
+		assert.Equal(t, "nginx:1.27", event.Pod.Spec.Containers[0].Image, "the deleted pod is decoded from its last value")
+
+		cancel()
+		for range events {
+		}
+	})
+}
+
+func TestPodRegistry_WatchPodsFromList(t *testing.T) {
+	registry := NewPodRegistry(storage.NewMemoryStorage())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-1")))
+	pods, revision, err := registry.ListPodsWithRevision(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, pods, 1)
+
+	require.NoError(t, registry.CreatePod(ctx, newBatchTestPod("web-2")))
+	require.NoError(t, registry.DeletePod(ctx, api.DefaultNamespace, "web-1"))
+	events, err := registry.WatchPods(ctx, revision)
+	require.NoError(t, err)
+
+	event := nextEvent(t, ctx, events)
+	assert.Equal(t, api.EventAdded, event.Type)
+	assert.Equal(t, "web-2", event.Pod.Name, "the pods created after the list are seen, not those listed")
+	event = nextEvent(t, ctx, events)
+	assert.Equal(t, api.EventDeleted, event.Type)
+	assert.Equal(t, "web-1", event.Pod.Name)
+}
+
+// nextEvent returns the next event of a watch, failing the test if the watch ends or ctx is done first
+func nextEvent[E any](t *testing.T, ctx context.Context, events <-chan E) E {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "the watch ended")
+		return event
+	case <-ctx.Done():
+		require.FailNow(t, "no event")
+	}
+	var none E
+	return none
+}
+
+func TestPodRegistry_CountPodsByStatus(t *testing.T) {
+	t.Run("should count the pods of each status", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		statuses := map[string]api.PodStatus{"web-1": api.PodRunning, "web-2": api.PodRunning, "web-3": api.PodFailed, "web-4": ""}
+		for name, status := range statuses {
+			pod := newBatchTestPod(name)
+			pod.Status = status
+			require.NoError(t, registry.CreatePod(ctx, pod))
+		}
+
+		counts, err := registry.CountPodsByStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[api.PodStatus]int{api.PodRunning: 2, api.PodFailed: 1, api.PodPending: 1}, counts)
+	})
+
+	t.Run("should count the pods stored with the compressed JSON codec", func(t *testing.T) {
+		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+			registry := NewPodRegistry(storage.NewEtcdStorageWithCodec(etcdServer, runtime.CompressedJSONCodec))
+			ctx := context.Background()
+			for _, name := range []string{"web-1", "web-2"} {
+				pod := newBatchTestPod(name)
+				pod.Status = api.PodRunning
+				require.NoError(t, registry.CreatePod(ctx, pod))
+			}
+
+			counts, err := registry.CountPodsByStatus(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, map[api.PodStatus]int{api.PodRunning: 2}, counts)
+		})
+	})
+
+	t.Run("should return error if listing fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mStorage := mockStorage.NewMockStorage(ctrl)
+		registry := NewPodRegistry(mStorage)
+		mStorage.EXPECT().ListFunc(gomock.Any(), podPrefix, gomock.Any(), gomock.Any()).Return(errors.New("failed to list pods"))
+
+		_, err := registry.CountPodsByStatus(context.Background())
+		assert.ErrorIs(t, err, ErrListPodsFailed)
+	})
+}
+
+func TestPodRegistry_ListPodsByStatus(t *testing.T) {
+	t.Run("should list the pods of each status", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+		ctx := context.Background()
+		for _, status := range api.PodStatuses {
+			pod := newBatchTestPod(strings.ToLower(string(status)))
+			pod.Status = status
+			require.NoError(t, registry.CreatePod(ctx, pod))
+		}
+
+		for _, status := range api.PodStatuses {
+			pods, err := registry.ListPodsByStatus(ctx, status)
+			require.NoError(t, err)
+			require.Len(t, pods, 1, status)
+			assert.Equal(t, strings.ToLower(string(status)), pods[0].Name)
+			assert.Equal(t, status, pods[0].Status)
+		}
+	})
+
+	t.Run("should reject an unknown status", func(t *testing.T) {
+		registry := NewPodRegistry(storage.NewMemoryStorage())
+
+		pods, err := registry.ListPodsByStatus(context.Background(), "Crashing")
+		assert.ErrorIs(t, err, ErrPodInvalid)
+		assert.ErrorIs(t, err, api.ErrInvalidPodStatus)
+		assert.Nil(t, pods)
+	})
+}
+
+func TestPodRegistry_ListPodsBySelector(t *testing.T) {
+	registry := NewPodRegistry(storage.NewMemoryStorage())
+	ctx := context.Background()
+	for name, labels := range map[string]map[string]string{
+		"web-1":  {"app": "web", "tier": "frontend"},
+		"web-2":  {"app": "web", "tier": "backend"},
+		"db-1":   {"app": "db"},
+		"plain":  nil,
+		"canary": {"app": "web"},
+	} {
+		pod := newBatchTestPod(name)
+		pod.Labels = labels
+		require.NoError(t, registry.CreatePod(ctx, pod))
+	}
+
+	tests := []struct {
+		name     string
+		selector map[string]string
+		expected []string
+	}{
+		{name: "empty selector matches all", selector: map[string]string{}, expected: []string{"canary", "db-1", "plain", "web-1", "web-2"}},
+		{name: "nil selector matches all", expected: []string{"canary", "db-1", "plain", "web-1", "web-2"}},
+		{name: "pods with more labels match", selector: map[string]string{"app": "web"}, expected: []string{"canary", "web-1", "web-2"}},
+		{name: "all pairs must match", selector: map[string]string{"app": "web", "tier": "frontend"}, expected: []string{"web-1"}},
+		{name: "no pod matches", selector: map[string]string{"app": "web", "tier": "cache"}, expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods, err := registry.ListPodsBySelector(ctx, tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, podNames(pods))
+		})
+	}
+}
+
 func TestPodRegistry_ListPendingPods(t *testing.T) {
 	t.Run("should list pending pods", func(t *testing.T) {
 		testCases := []struct {
@@ -445,24 +1306,22 @@ func TestPodRegistry_ListPendingPods(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Run(tc.name, func(t *testing.T) {
-				storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-					etcdStorage := storage.NewEtcdStorage(etcdServer)
-					registry := NewPodRegistry(etcdStorage)
-					ctx := context.Background()
-
-					// Create test pods
-					for _, pod := range tc.podsToCreate {
-						if err := registry.CreatePod(ctx, pod); err != nil {
-							t.Fatalf("Failed to create test pod: %v", err)
-						}
+				memoryStorage := storage.NewMemoryStorage()
+				registry := NewPodRegistry(memoryStorage)
+				ctx := context.Background()
+
+				// Create test pods
+				for _, pod := range tc.podsToCreate {
+					if err := registry.CreatePod(ctx, pod); err != nil {
+						t.Fatalf("Failed to create test pod: %v", err)
 					}
+				}
 
-					// Call ListPendingPods
-					pods, err := registry.ListPendingPods(ctx)
-					require.NoError(t, err)
+				// Call ListPendingPods
+				pods, err := registry.ListPendingPods(ctx)
+				require.NoError(t, err)
 
-					assert.Equal(t, tc.expectedPendingPods, len(pods))
-				})
+				assert.Equal(t, tc.expectedPendingPods, len(pods))
 			})
 		}
 	})
@@ -475,7 +1334,8 @@ func TestPodRegistry_ListPendingPods(t *testing.T) {
 		registry := NewPodRegistry(mStorage)
 		ctx := context.Background()
 
-		mStorage.EXPECT().List(ctx, podPrefix, gomock.Any()).Return(errors.New("failed to list pods"))
+		mStorage.EXPECT().ListFunc(ctx, podIndexPrefixOf(podStatusIndex, string(api.PodPending)), gomock.Any(), gomock.Any()).
+			Return(errors.New("failed to list pods"))
 
 		pods, err := registry.ListPendingPods(ctx)
 
@@ -495,11 +1355,11 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 				name: "no unassigned pods",
 				podsToCreate: []*api.Pod{
 					{ObjectMeta: api.ObjectMeta{Name: "pod1"},
-						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Spec:     api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						NodeName: "node-1", Status: api.PodRunning},
 					{ObjectMeta: api.ObjectMeta{Name: "pod2"},
-						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Spec:     api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						NodeName: "node-1", Status: api.PodRunning},
 				},
 				expectedUnassignedPods: 0,
 			},
@@ -510,14 +1370,32 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
 						Status: api.PodPending},
 					{ObjectMeta: api.ObjectMeta{Name: "pod4"},
-						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
-						Status: api.PodRunning},
+						Spec:     api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						NodeName: "node-1", Status: api.PodRunning},
 					{ObjectMeta: api.ObjectMeta{Name: "pod5"},
 						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
 						Status: api.PodPending},
 				},
 				expectedUnassignedPods: 2,
 			},
+			{
+				name: "pods without a node whatever their status",
+				podsToCreate: []*api.Pod{
+					{ObjectMeta: api.ObjectMeta{Name: "pod8"},
+						Spec:     api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						NodeName: "node-1", Status: api.PodPending},
+					{ObjectMeta: api.ObjectMeta{Name: "pod9"},
+						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						Status: api.PodScheduled},
+					{ObjectMeta: api.ObjectMeta{Name: "pod10"},
+						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						Status: api.PodSucceeded},
+					{ObjectMeta: api.ObjectMeta{Name: "pod11"},
+						Spec:   api.PodSpec{Containers: []api.Container{{Name: "test-container2", Image: "nginx"}}},
+						Status: api.PodFailed},
+				},
+				expectedUnassignedPods: 1,
+			},
 			{
 				name: "all unassigned pods",
 				podsToCreate: []*api.Pod{
@@ -534,24 +1412,22 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Run(tc.name, func(t *testing.T) {
-				storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-					etcdStorage := storage.NewEtcdStorage(etcdServer)
-					registry := NewPodRegistry(etcdStorage)
-					ctx := context.Background()
-
-					// Create test pods
-					for _, pod := range tc.podsToCreate {
-						if err := registry.CreatePod(ctx, pod); err != nil {
-							t.Fatalf("Failed to create test pod: %v", err)
-						}
+				memoryStorage := storage.NewMemoryStorage()
+				registry := NewPodRegistry(memoryStorage)
+				ctx := context.Background()
+
+				// Create test pods
+				for _, pod := range tc.podsToCreate {
+					if err := registry.CreatePod(ctx, pod); err != nil {
+						t.Fatalf("Failed to create test pod: %v", err)
 					}
+				}
 
-					// Call ListUnassignedPods
-					pods, err := registry.ListUnassignedPods(ctx)
-					require.NoError(t, err)
+				// Call ListUnassignedPods
+				pods, err := registry.ListUnassignedPods(ctx)
+				require.NoError(t, err)
 
-					assert.Equal(t, tc.expectedUnassignedPods, len(pods))
-				})
+				assert.Equal(t, tc.expectedUnassignedPods, len(pods))
 			})
 		}
 	})
@@ -564,7 +1440,8 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 		registry := NewPodRegistry(mStorage)
 		ctx := context.Background()
 
-		mStorage.EXPECT().List(ctx, podPrefix, gomock.Any()).Return(errors.New("failed to list pods"))
+		mStorage.EXPECT().ListFunc(ctx, podPrefix, gomock.Any(), gomock.Any()).
+			Return(errors.New("failed to list pods"))
 
 		pods, err := registry.ListUnassignedPods(ctx)
 
@@ -572,3 +1449,129 @@ func TestPodRegistry_ListUnassignedPods(t *testing.T) {
 		assert.Nil(t, pods, "Expected nil list of pods")
 	})
 }
+
+func newBatchTestPod(name string) *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+	}
+}
+
+// BenchmarkPodRegistry_GetPods compares getting 50 pods in a batch with getting them one by one
+func BenchmarkPodRegistry_GetPods(b *testing.B) {
+	registry := NewPodRegistry(storage.NewEtcdStorage(storage.NewTestEtcdClient(b)))
+	ctx := context.Background()
+	names := make([]string, 50)
+	for i := range names {
+		names[i] = fmt.Sprintf("web-%d", i)
+		if err := registry.CreatePod(ctx, newBatchTestPod(names[i])); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for range b.N {
+			for _, name := range names {
+				if _, err := registry.GetPod(ctx, api.DefaultNamespace, name); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for range b.N {
+			if _, _, err := registry.GetPods(ctx, api.DefaultNamespace, names); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// lockedPodRegistry holds a mutex around every call to the registry, as the registry did before it left
+// keeping concurrent writes apart to storage transactions
+type lockedPodRegistry struct {
+	mu       sync.Mutex
+	registry *PodRegistry
+}
+
+func (r *lockedPodRegistry) CreatePod(ctx context.Context, pod *api.Pod) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.registry.CreatePod(ctx, pod)
+}
+
+func (r *lockedPodRegistry) GetPod(ctx context.Context, namespace, name string) (*api.Pod, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.registry.GetPod(ctx, namespace, name)
+}
+
+// BenchmarkPodRegistry_ParallelCreateAndGet compares creating and getting pods from parallel goroutines through
+// the registry locked as it used to be with the registry as it is
+func BenchmarkPodRegistry_ParallelCreateAndGet(b *testing.B) {
+	registry := NewPodRegistry(storage.NewEtcdStorage(storage.NewTestEtcdClient(b)))
+	ctx := context.Background()
+	var created atomic.Int64
+	createAndGet := func(b *testing.B, registry interface {
+		CreatePod(ctx context.Context, pod *api.Pod) error
+		GetPod(ctx context.Context, namespace, name string) (*api.Pod, error)
+	}) {
+		// The calls mostly wait on etcd, so there are more of them in flight than there are CPUs
+		b.SetParallelism(8)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				name := fmt.Sprintf("web-%d", created.Add(1))
+				if err := registry.CreatePod(ctx, newBatchTestPod(name)); err != nil {
+					b.Error(err)
+					return
+				}
+				if _, err := registry.GetPod(ctx, api.DefaultNamespace, name); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	b.Run("locked", func(b *testing.B) {
+		createAndGet(b, &lockedPodRegistry{registry: registry})
+	})
+	b.Run("unlocked", func(b *testing.B) {
+		createAndGet(b, registry)
+	})
+}
+
+// BenchmarkPodRegistry_CountPodsByStatus compares counting 5000 pods by status with listing them
+func BenchmarkPodRegistry_CountPodsByStatus(b *testing.B) {
+	registry := NewPodRegistry(storage.NewEtcdStorage(storage.NewTestEtcdClient(b)))
+	ctx := context.Background()
+	statuses := []api.PodStatus{api.PodPending, api.PodScheduled, api.PodRunning, api.PodSucceeded, api.PodFailed}
+	for i := range 5000 {
+		pod := newBatchTestPod(fmt.Sprintf("web-%d", i))
+		pod.Status = statuses[i%len(statuses)]
+		pod.Labels = map[string]string{"app": "web", "tier": "frontend"}
+		if err := registry.CreatePod(ctx, pod); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("list", func(b *testing.B) {
+		for range b.N {
+			pods, err := registry.ListPods(ctx, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			counts := make(map[api.PodStatus]int)
+			for _, pod := range pods {
+				counts[pod.Status]++
+			}
+		}
+	})
+	b.Run("count", func(b *testing.B) {
+		for range b.N {
+			if _, err := registry.CountPodsByStatus(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
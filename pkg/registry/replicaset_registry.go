@@ -4,71 +4,146 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"strings"
+	"sync/atomic"
 
 	"gokube/pkg/api"
 	"gokube/pkg/storage"
 )
 
-const (
-	replicaSetPrefix = "/replicasets"
-)
+// replicaSetPrefix is where the ReplicaSets are kept, under /replicasets/<namespace>/<name>. ReplicaSets stored
+// by older versions under /replicasets/<name> are moved to their namespace by MigrateLegacyReplicaSets.
+const replicaSetPrefix = "/replicasets/"
 
 var (
 	ErrReplicaSetExists   = errors.New("replicaset already exists")
-	ErrReplicaSetNotFound = errors.New("replicaset not found")
+	ErrReplicaSetNotFound = fmt.Errorf("replicaset %w", ErrNotFound)
 	ErrListReplicaSets    = errors.New("error listing replicasets")
+	ErrReplicaSetInvalid  = errors.New("invalid replicaset")
+	// ErrReplicaSetConflict is returned for an update of a ReplicaSet that was changed since the resource
+	// version it carries, or a scale meant for a ReplicaSet that was recreated since
+	ErrReplicaSetConflict = errors.New("replicaset was changed")
+	// ErrReplicaSetImmutable is returned for an update that changes the selector of a ReplicaSet
+	ErrReplicaSetImmutable = errors.New("replicaset update changes immutable fields")
 )
 
+// ReplicaSetRegistry stores ReplicaSets. Like PodRegistry it holds no lock: its writes are conditioned on the
+// version of the ReplicaSet they were checked against, and the ones that read it first retry on a conflict.
 type ReplicaSetRegistry struct {
-	storage storage.Storage
-	mutex   sync.RWMutex
+	storage     storage.Storage
+	maxReplicas atomic.Int32
+	namespaces  *NamespaceRegistry
 }
 
 func NewReplicaSetRegistry(storage storage.Storage) *ReplicaSetRegistry {
-	return &ReplicaSetRegistry{
+	r := &ReplicaSetRegistry{
 		storage: storage,
 	}
+	r.maxReplicas.Store(api.DefaultMaxReplicasPerReplicaSet)
+	return r
+}
+
+// SetMaxReplicas changes the largest replica count accepted on create and update
+func (r *ReplicaSetRegistry) SetMaxReplicas(maxReplicas int32) {
+	r.maxReplicas.Store(maxReplicas)
+}
+
+// MaxReplicas returns the largest replica count accepted on create and update
+func (r *ReplicaSetRegistry) MaxReplicas() int32 {
+	return r.maxReplicas.Load()
+}
+
+// SetNamespaceRegistry makes Create reject ReplicaSets whose namespace does not exist or is terminating.
+// Without it ReplicaSets are created in any namespace.
+func (r *ReplicaSetRegistry) SetNamespaceRegistry(namespaces *NamespaceRegistry) {
+	r.namespaces = namespaces
 }
 
-func (r *ReplicaSetRegistry) generateKey(name string) string {
-	return fmt.Sprintf("%s/%s", replicaSetPrefix, name)
+func (r *ReplicaSetRegistry) validate(rs *api.ReplicaSet) error {
+	var fieldErrs api.FieldErrors
+	if rs.Spec.Replicas < 0 {
+		fieldErrs = append(fieldErrs, api.FieldError{Field: "spec.replicas", Message: fmt.Sprintf("must be at least 0, got %d", rs.Spec.Replicas)})
+	}
+	if maxReplicas := r.MaxReplicas(); rs.Spec.Replicas > maxReplicas {
+		fieldErrs = append(fieldErrs, api.FieldError{Field: "spec.replicas", Message: fmt.Sprintf("must be at most %d, got %d", maxReplicas, rs.Spec.Replicas)})
+	}
+	if len(fieldErrs) > 0 {
+		return fmt.Errorf("%w: %w", ErrReplicaSetInvalid, fieldErrs)
+	}
+	return nil
+}
+
+// generateKey returns the key of a ReplicaSet, of the default namespace if namespace is empty
+func (r *ReplicaSetRegistry) generateKey(namespace, name string) string {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	return replicaSetPrefix + namespace + "/" + name
 }
 
+// Create creates a ReplicaSet in its namespace, the default namespace for a ReplicaSet without one
 func (r *ReplicaSetRegistry) Create(ctx context.Context, rs *api.ReplicaSet) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	rs.Namespace = api.NamespaceOf(&rs.ObjectMeta)
+	if r.namespaces != nil {
+		if err := r.namespaces.CheckActive(ctx, rs.Namespace); err != nil {
+			return err
+		}
+	}
 
-	key := r.generateKey(rs.Name)
+	key := r.generateKey(rs.Namespace, rs.Name)
 
-	// Check if ReplicaSet already exists
-	existingRS := &api.ReplicaSet{}
-	if err := r.storage.Get(ctx, key, existingRS); err == nil {
-		return fmt.Errorf("%w: %s", ErrReplicaSetExists, rs.Name)
+	if err := r.validate(rs); err != nil {
+		return err
+	}
+	// The UID tells the ReplicaSet apart from ReplicaSets recreated under its name, e.g. in owner references
+	if rs.UID != "" {
+		return fmt.Errorf("%w: %s: uid is assigned by the server", ErrReplicaSetInvalid, rs.Name)
+	}
+	stampCreated(&rs.ObjectMeta)
+	// The pods of the ReplicaSet are traced with the ID of the request creating it
+	if rs.TraceID() == "" {
+		rs.SetTraceID(traceID(ctx))
 	}
 
 	// Store the ReplicaSet
-	return r.storage.Create(ctx, key, rs)
+	if err := r.storage.Create(ctx, key, rs); err != nil {
+		return existsAs(err, fmt.Errorf("%w: %s/%s", ErrReplicaSetExists, rs.Namespace, rs.Name))
+	}
+	return nil
 }
 
-func (r *ReplicaSetRegistry) Get(ctx context.Context, name string) (*api.ReplicaSet, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
-
-	key := r.generateKey(name)
+// Get retrieves a ReplicaSet of a namespace, the default one if empty, by its name
+func (r *ReplicaSetRegistry) Get(ctx context.Context, namespace, name string) (*api.ReplicaSet, error) {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	key := r.generateKey(namespace, name)
 	rs := &api.ReplicaSet{}
 	if err := r.storage.Get(ctx, key, rs); err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, name)
+		return nil, fmt.Errorf("%w: %s/%s", ErrReplicaSetNotFound, namespace, name)
 	}
 
 	return rs, nil
 }
 
+// Update replaces the spec of an existing ReplicaSet of the namespace of rs. It returns ErrReplicaSetImmutable
+// if the update changes the selector, see api.ReplicaSet.ValidateUpdate, and ErrReplicaSetNotFound rather than
+// recreating a ReplicaSet deleted meanwhile.
 func (r *ReplicaSetRegistry) Update(ctx context.Context, rs *api.ReplicaSet) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	if rs.ResourceVersion != "" {
+		return r.update(ctx, rs)
+	}
+	// Without a resource version the update is written at the version it was checked against, and checked
+	// again against a ReplicaSet changed in between
+	given := *rs
+	return retryOnConflict(func() error {
+		*rs = given
+		return r.update(ctx, rs)
+	})
+}
 
-	key := r.generateKey(rs.Name)
+func (r *ReplicaSetRegistry) update(ctx context.Context, rs *api.ReplicaSet) error {
+	key := r.generateKey(rs.Namespace, rs.Name)
 
 	// Check if ReplicaSet exists
 	existingRS := &api.ReplicaSet{}
@@ -76,27 +151,162 @@ func (r *ReplicaSetRegistry) Update(ctx context.Context, rs *api.ReplicaSet) err
 		return fmt.Errorf("%w: %s", ErrReplicaSetNotFound, rs.Name)
 	}
 
+	if err := r.validate(rs); err != nil {
+		return err
+	}
+	if err := rs.ValidateUpdate(existingRS); err != nil {
+		return fmt.Errorf("%w: %w", ErrReplicaSetImmutable, err)
+	}
+	keepCreated(&rs.ObjectMeta, &existingRS.ObjectMeta)
+	if rs.ResourceVersion == "" {
+		rs.ResourceVersion = existingRS.ResourceVersion
+	}
+
 	// Update the ReplicaSet
-	return r.storage.Update(ctx, key, rs)
+	return replace(ctx, r.storage, key, rs, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, rs.Name), ErrReplicaSetConflict)
 }
 
-func (r *ReplicaSetRegistry) Delete(ctx context.Context, name string) error {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// UpdateStatus writes only the status of rs, leaving the stored spec untouched.
+// It is used by the controller, which must be able to report on objects whose spec no longer validates. A
+// ReplicaSet changed between the read and the write is read again, so the change is kept.
+func (r *ReplicaSetRegistry) UpdateStatus(ctx context.Context, rs *api.ReplicaSet) error {
+	key := r.generateKey(rs.Namespace, rs.Name)
+	return retryOnConflict(func() error {
+		existingRS := &api.ReplicaSet{}
+		if err := r.storage.Get(ctx, key, existingRS); err != nil {
+			return fmt.Errorf("%w: %s", ErrReplicaSetNotFound, rs.Name)
+		}
 
-	key := r.generateKey(name)
-	return r.storage.Delete(ctx, key)
+		existingRS.Status = rs.Status
+		return replace(ctx, r.storage, key, existingRS, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, rs.Name), ErrReplicaSetConflict)
+	})
 }
 
-func (r *ReplicaSetRegistry) List(ctx context.Context) ([]*api.ReplicaSet, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+// UpdateStatusOp returns the write of UpdateStatus, for a transaction with other writes. The write fails the
+// transaction with storage.ErrConflict if the ReplicaSet is changed before it is committed.
+func (r *ReplicaSetRegistry) UpdateStatusOp(ctx context.Context, rs *api.ReplicaSet) (storage.TxnOp, error) {
+	key := r.generateKey(rs.Namespace, rs.Name)
+
+	existingRS := &api.ReplicaSet{}
+	if err := r.storage.Get(ctx, key, existingRS); err != nil {
+		return storage.TxnOp{}, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, rs.Name)
+	}
+
+	existingRS.Status = rs.Status
+	return storage.UpdateOp(key, existingRS), nil
+}
 
-	var replicaSets []*api.ReplicaSet
+// UpdateScale sets the replica count of the ReplicaSet the scale names in its namespace, leaving the rest of it
+// untouched.
+// It fails with ErrReplicaSetConflict if the scale has a UID and the ReplicaSet was recreated since. A
+// ReplicaSet changed between the read and the write is read again, so the change is kept.
+func (r *ReplicaSetRegistry) UpdateScale(ctx context.Context, scale *api.Scale) (*api.ReplicaSet, error) {
+	key := r.generateKey(scale.Namespace, scale.Name)
+	var existingRS *api.ReplicaSet
+	err := retryOnConflict(func() error {
+		existingRS = &api.ReplicaSet{}
+		if err := r.storage.Get(ctx, key, existingRS); err != nil {
+			return fmt.Errorf("%w: %s", ErrReplicaSetNotFound, scale.Name)
+		}
+		if scale.UID != "" && scale.UID != existingRS.UID {
+			return fmt.Errorf("%w: %s has UID %q, not %q", ErrReplicaSetConflict, scale.Name, existingRS.UID, scale.UID)
+		}
+
+		existingRS.Spec.Replicas = scale.Spec.Replicas
+		if err := r.validate(existingRS); err != nil {
+			return err
+		}
+		return replace(ctx, r.storage, key, existingRS, fmt.Errorf("%w: %s", ErrReplicaSetNotFound, scale.Name), ErrReplicaSetConflict)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existingRS, nil
+}
+
+// Delete removes a ReplicaSet of a namespace, the default one if empty, by name. It returns
+// ErrReplicaSetNotFound if there is no such ReplicaSet, which IgnoreNotFound turns into success.
+func (r *ReplicaSetRegistry) Delete(ctx context.Context, namespace, name string) error {
+	if namespace == "" {
+		namespace = api.DefaultNamespace
+	}
+	key := r.generateKey(namespace, name)
+	return deleteAs(ctx, r.storage, key, fmt.Errorf("%w: %s/%s", ErrReplicaSetNotFound, namespace, name))
+}
 
-	if err := r.storage.List(ctx, replicaSetPrefix, &replicaSets); err != nil {
+// List retrieves the ReplicaSets of a namespace, or of all namespaces if namespace is empty
+func (r *ReplicaSetRegistry) List(ctx context.Context, namespace string) ([]*api.ReplicaSet, error) {
+	replicaSets, err := listOf[api.ReplicaSet](ctx, r.storage, namespacedPrefix(replicaSetPrefix, namespace))
+	if err != nil {
 		return nil, fmt.Errorf("%w", ErrListReplicaSets)
 	}
 
 	return replicaSets, nil
 }
+
+// ListWithRevision is List that also returns the revision the ReplicaSets were read at, for Watch to follow
+// their changes from without missing any
+func (r *ReplicaSetRegistry) ListWithRevision(ctx context.Context, namespace string) ([]*api.ReplicaSet, int64, error) {
+	replicaSets, revision, err := listWithRevisionOf[api.ReplicaSet](ctx, r.storage, namespacedPrefix(replicaSetPrefix, namespace))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrListReplicaSets, err)
+	}
+	return replicaSets, revision, nil
+}
+
+// ReplicaSetEvent is a change of a ReplicaSet. For api.EventDeleted, ReplicaSet is the ReplicaSet as it was
+// when deleted.
+type ReplicaSetEvent struct {
+	Type       api.EventType
+	ReplicaSet *api.ReplicaSet
+}
+
+// Watch follows the changes of the ReplicaSets made after revision, that of ListWithRevision, or from now on
+// for 0. The channel is closed when ctx is done or the watch ends, after which the caller lists the
+// ReplicaSets again and watches anew.
+func (r *ReplicaSetRegistry) Watch(ctx context.Context, revision int64) (<-chan ReplicaSetEvent, error) {
+	events, err := watchOf(ctx, r.storage, replicaSetPrefix, revision, func(eventType api.EventType, rs *api.ReplicaSet) ReplicaSetEvent {
+		return ReplicaSetEvent{Type: eventType, ReplicaSet: rs}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to watch replicasets: %v", ErrInternal, err)
+	}
+	return events, nil
+}
+
+// MigrateLegacyReplicaSets moves the ReplicaSets stored by older versions under /replicasets/<name>, before they
+// were kept by namespace, to their namespace, the default one for a ReplicaSet without it, and returns how many it
+// moved. A ReplicaSet whose name is taken in its namespace is left where it is.
+func (r *ReplicaSetRegistry) MigrateLegacyReplicaSets(ctx context.Context) (int, error) {
+	keys, err := r.storage.ListKeys(ctx, replicaSetPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrListReplicaSets, err)
+	}
+	migrated := 0
+	for _, key := range keys {
+		if strings.Contains(strings.TrimPrefix(key, replicaSetPrefix), "/") {
+			continue
+		}
+		rs := &api.ReplicaSet{}
+		if err := r.storage.Get(ctx, key, rs); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return migrated, fmt.Errorf("%w: failed to get replicaset: %v", ErrInternal, err)
+		}
+		rs.Namespace = api.NamespaceOf(&rs.ObjectMeta)
+		rs.ResourceVersion = ""
+		err := r.storage.Txn(ctx, []storage.TxnOp{
+			storage.CreateOp(r.generateKey(rs.Namespace, rs.Name), rs),
+			storage.DeleteOp(key),
+		})
+		switch {
+		case errors.Is(err, storage.ErrKeyExists):
+			continue
+		case err != nil:
+			return migrated, fmt.Errorf("%w: failed to migrate replicaset %s: %v", ErrInternal, rs.Name, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -3,11 +3,12 @@ package registry
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/mock/gomock"
 
 	mockStorage "gokube/mocks/pkg/storage"
@@ -36,93 +37,160 @@ func createTestReplicaSet(name string, replicas int32, image string) *api.Replic
 	}
 }
 
+func TestReplicaSetRegistry_Watch(t *testing.T) {
+	registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, err := registry.Watch(ctx, 0)
+	require.NoError(t, err)
+
+	rs := createTestReplicaSet("web", 3, "nginx:latest")
+	require.NoError(t, registry.Create(ctx, rs))
+	rs.Spec.Replicas = 5
+	require.NoError(t, registry.Update(ctx, rs))
+	require.NoError(t, registry.Delete(ctx, api.DefaultNamespace, "web"))
+
+	event := nextEvent(t, ctx, events)
+	assert.Equal(t, api.EventAdded, event.Type)
+	assert.Equal(t, int32(3), event.ReplicaSet.Spec.Replicas)
+	event = nextEvent(t, ctx, events)
+	assert.Equal(t, api.EventModified, event.Type)
+	assert.Equal(t, int32(5), event.ReplicaSet.Spec.Replicas)
+	event = nextEvent(t, ctx, events)
+	assert.Equal(t, api.EventDeleted, event.Type)
+	assert.Equal(t, "web", event.ReplicaSet.Name)
+}
+
 func TestReplicaSetRegistry_Create(t *testing.T) {
 	t.Run("should create ReplicaSet successfully", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			ctx := context.Background()
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
-			registry := NewReplicaSetRegistry(etcdStorage)
+		ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+		registry := NewReplicaSetRegistry(memoryStorage)
 
-			err := registry.Create(ctx, rs)
-			require.NoError(t, err, "Failed to create ReplicaSet")
+		err := registry.Create(ctx, rs)
+		require.NoError(t, err, "Failed to create ReplicaSet")
 
-			_, err = registry.Get(ctx, "test-replicaset")
-			require.NoError(t, err, "Failed to get created ReplicaSet")
-		})
+		stored, err := registry.Get(ctx, api.DefaultNamespace, "test-replicaset")
+		require.NoError(t, err, "Failed to get created ReplicaSet")
+		assert.NotEmpty(t, stored.UID)
+		assert.WithinDuration(t, time.Now(), stored.CreationTimestamp, time.Minute)
+	})
+
+	t.Run("should reject a ReplicaSet with a UID", func(t *testing.T) {
+		registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+		rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+		rs.UID = "my-uid"
+
+		err := registry.Create(context.Background(), rs)
+		assert.ErrorIs(t, err, ErrReplicaSetInvalid)
 	})
 
 	// Add a test case to verify that the Create method returns an error if the ReplicaSet already exists.
 	t.Run("should return error if ReplicaSet already exists", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			ctx := context.Background()
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
-			registry := NewReplicaSetRegistry(etcdStorage)
+		ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+		registry := NewReplicaSetRegistry(memoryStorage)
 
-			err := registry.Create(ctx, rs)
-			require.NoError(t, err, "Failed to create ReplicaSet")
+		err := registry.Create(ctx, rs)
+		require.NoError(t, err, "Failed to create ReplicaSet")
 
-			err = registry.Create(ctx, rs)
-			assert.ErrorIs(t, err, ErrReplicaSetExists, "Expected error when creating existing ReplicaSet")
-		})
+		rs.UID = ""
+		err = registry.Create(ctx, rs)
+		assert.ErrorIs(t, err, ErrReplicaSetExists, "Expected error when creating existing ReplicaSet")
 	})
 }
 
 func TestReplicaSetRegistry_Get(t *testing.T) {
 	t.Run("should return ReplicaSet if it exists", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			ctx := context.Background()
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
-			registry := NewReplicaSetRegistry(etcdStorage)
+		ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+		registry := NewReplicaSetRegistry(memoryStorage)
 
-			err := registry.Create(ctx, rs)
-			require.NoError(t, err, "Failed to create ReplicaSet")
+		err := registry.Create(ctx, rs)
+		require.NoError(t, err, "Failed to create ReplicaSet")
 
-			retrievedRS, err := registry.Get(ctx, "test-replicaset")
-			require.NoError(t, err, "Failed to get ReplicaSet")
+		retrievedRS, err := registry.Get(ctx, api.DefaultNamespace, "test-replicaset")
+		require.NoError(t, err, "Failed to get ReplicaSet")
 
-			assert.Equal(t, "test-replicaset", retrievedRS.Name)
-			assert.Equal(t, int32(3), retrievedRS.Spec.Replicas)
-			assert.Len(t, retrievedRS.Spec.Template.Spec.Containers, 1)
-			assert.Equal(t, "nginx:latest", retrievedRS.Spec.Template.Spec.Containers[0].Image)
-		})
+		assert.Equal(t, "test-replicaset", retrievedRS.Name)
+		assert.Equal(t, int32(3), retrievedRS.Spec.Replicas)
+		assert.Len(t, retrievedRS.Spec.Template.Spec.Containers, 1)
+		assert.Equal(t, "nginx:latest", retrievedRS.Spec.Template.Spec.Containers[0].Image)
 	})
 
 	t.Run("should return error if ReplicaSet does not exist", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewReplicaSetRegistry(etcdStorage)
-			ctx := context.Background()
-
-			_, err := registry.Get(ctx, "non-existent-replicaset")
-			assert.ErrorIs(t, err, ErrReplicaSetNotFound, "Expected ErrReplicaSetNotFound error")
-		})
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewReplicaSetRegistry(memoryStorage)
+		ctx := context.Background()
+
+		_, err := registry.Get(ctx, api.DefaultNamespace, "non-existent-replicaset")
+		assert.ErrorIs(t, err, ErrReplicaSetNotFound, "Expected ErrReplicaSetNotFound error")
 	})
 }
 
 func TestReplicaSetRegistry_Update(t *testing.T) {
 	t.Run("should update ReplicaSet successfully", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			ctx := context.Background()
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
-			registry := NewReplicaSetRegistry(etcdStorage)
+		ctx := context.Background()
+		memoryStorage := storage.NewMemoryStorage()
+		rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+		registry := NewReplicaSetRegistry(memoryStorage)
 
-			require.NoError(t, registry.Create(ctx, rs))
+		require.NoError(t, registry.Create(ctx, rs))
+
+		updatedRS := createTestReplicaSet("test-replicaset", 5, "nginx:1.19")
+		err := registry.Update(ctx, updatedRS)
+		require.NoError(t, err, "Failed to update ReplicaSet")
+
+		retrievedRS, err := registry.Get(ctx, api.DefaultNamespace, "test-replicaset")
+		require.NoError(t, err, "Failed to get updated ReplicaSet")
+
+		assert.Equal(t, int32(5), retrievedRS.Spec.Replicas)
+		assert.Len(t, retrievedRS.Spec.Template.Spec.Containers, 1)
+		assert.Equal(t, "nginx:1.19", retrievedRS.Spec.Template.Spec.Containers[0].Image)
+	})
+
+	t.Run("should reject a change of the selector", func(t *testing.T) {
+		ctx := context.Background()
+		registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+		require.NoError(t, registry.Create(ctx, createTestReplicaSet("test-replicaset", 3, "nginx:latest")))
 
-			updatedRS := createTestReplicaSet("test-replicaset", 5, "nginx:1.19")
-			err := registry.Update(ctx, updatedRS)
-			require.NoError(t, err, "Failed to update ReplicaSet")
+		updatedRS := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+		updatedRS.Spec.Selector = map[string]string{"app": "other"}
+		err := registry.Update(ctx, updatedRS)
+		assert.ErrorIs(t, err, ErrReplicaSetImmutable)
+		var fieldErrs api.FieldErrors
+		require.ErrorAs(t, err, &fieldErrs)
+		assert.Equal(t, "spec.selector", fieldErrs[0].Field)
+
+		retrievedRS, err := registry.Get(ctx, api.DefaultNamespace, "test-replicaset")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"app": "test"}, retrievedRS.Spec.Selector)
+	})
 
-			retrievedRS, err := registry.Get(ctx, "test-replicaset")
-			require.NoError(t, err, "Failed to get updated ReplicaSet")
+	t.Run("should accept an update that changes nothing", func(t *testing.T) {
+		ctx := context.Background()
+		registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+		require.NoError(t, registry.Create(ctx, createTestReplicaSet("test-replicaset", 3, "nginx:latest")))
 
-			assert.Equal(t, int32(5), retrievedRS.Spec.Replicas)
-			assert.Len(t, retrievedRS.Spec.Template.Spec.Containers, 1)
-			assert.Equal(t, "nginx:1.19", retrievedRS.Spec.Template.Spec.Containers[0].Image)
-		})
+		assert.NoError(t, registry.Update(ctx, createTestReplicaSet("test-replicaset", 3, "nginx:latest")))
+	})
+
+	t.Run("should accept a change of the template only", func(t *testing.T) {
+		ctx := context.Background()
+		registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+		require.NoError(t, registry.Create(ctx, createTestReplicaSet("test-replicaset", 3, "nginx:latest")))
+
+		updatedRS := createTestReplicaSet("test-replicaset", 3, "nginx:1.19")
+		updatedRS.Spec.Template.Labels = map[string]string{"app": "test", "version": "1.19"}
+		require.NoError(t, registry.Update(ctx, updatedRS))
+
+		retrievedRS, err := registry.Get(ctx, api.DefaultNamespace, "test-replicaset")
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.19", retrievedRS.Spec.Template.Spec.Containers[0].Image)
+		assert.Equal(t, "1.19", retrievedRS.Spec.Template.Labels["version"])
 	})
 
 	t.Run("should handle error returned by storage provider", func(t *testing.T) {
@@ -144,61 +212,251 @@ func TestReplicaSetRegistry_Update(t *testing.T) {
 
 func TestReplicaSetRegistry_List(t *testing.T) {
 	t.Run("should list all ReplicaSets", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			etcdStorage := storage.NewEtcdStorage(etcdServer)
-			registry := NewReplicaSetRegistry(etcdStorage)
-			ctx := context.Background()
-
-			replicaSets := []*api.ReplicaSet{
-				createTestReplicaSet("test-replicaset-1", 3, "nginx:latest"),
-				createTestReplicaSet("test-replicaset-2", 2, "nginx:1.19"),
-			}
-
-			for _, rs := range replicaSets {
-				err := registry.Create(ctx, rs)
-				require.NoError(t, err)
-			}
-
-			rsList, err := registry.List(ctx)
-			require.NoError(t, err, "Failed to list ReplicaSets")
-
-			assert.Len(t, rsList, len(replicaSets))
-			assert.ElementsMatch(t, replicaSets, rsList)
-		})
+		memoryStorage := storage.NewMemoryStorage()
+		registry := NewReplicaSetRegistry(memoryStorage)
+		ctx := context.Background()
+
+		replicaSets := []*api.ReplicaSet{
+			createTestReplicaSet("test-replicaset-1", 3, "nginx:latest"),
+			createTestReplicaSet("test-replicaset-2", 2, "nginx:1.19"),
+		}
+
+		for _, rs := range replicaSets {
+			err := registry.Create(ctx, rs)
+			require.NoError(t, err)
+		}
+
+		rsList, err := registry.List(ctx, "")
+		require.NoError(t, err, "Failed to list ReplicaSets")
+
+		assert.Len(t, rsList, len(replicaSets))
+		assert.ElementsMatch(t, replicaSets, rsList)
 	})
 
 	t.Run("should handle error returned by the storage provider", func(t *testing.T) {
-		storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-			ctrl := gomock.NewController(t)
-			defer ctrl.Finish()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
 
-			mStorage := mockStorage.NewMockStorage(ctrl)
-			registry := NewReplicaSetRegistry(mStorage)
-			ctx := context.Background()
+		mStorage := mockStorage.NewMockStorage(ctrl)
+		registry := NewReplicaSetRegistry(mStorage)
+		ctx := context.Background()
 
-			mStorage.EXPECT().List(ctx, gomock.Any(), gomock.Any()).Return(errors.New("failed to list ReplicaSets"))
+		mStorage.EXPECT().ListFunc(ctx, gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("failed to list ReplicaSets"))
 
-			rsList, err := registry.List(ctx)
+		rsList, err := registry.List(ctx, "")
 
-			assert.ErrorIs(t, err, ErrListReplicaSets, "Expected error when listing ReplicaSets")
-			assert.Nil(t, rsList, "Expected nil list of ReplicaSets")
-		})
+		assert.ErrorIs(t, err, ErrListReplicaSets, "Expected error when listing ReplicaSets")
+		assert.Nil(t, rsList, "Expected nil list of ReplicaSets")
 	})
 }
 
 func TestReplicaSetRegistry_Delete(t *testing.T) {
-	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
-		etcdStorage := storage.NewEtcdStorage(etcdServer)
-		registry := NewReplicaSetRegistry(etcdStorage)
-		ctx := context.Background()
+	memoryStorage := storage.NewMemoryStorage()
+	registry := NewReplicaSetRegistry(memoryStorage)
+	ctx := context.Background()
 
-		rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
-		require.NoError(t, registry.Create(ctx, rs))
+	rs := createTestReplicaSet("test-replicaset", 3, "nginx:latest")
+	require.NoError(t, registry.Create(ctx, rs))
 
-		err := registry.Delete(ctx, "test-replicaset")
-		require.NoError(t, err, "Failed to delete ReplicaSet")
+	err := registry.Delete(ctx, api.DefaultNamespace, "test-replicaset")
+	require.NoError(t, err, "Failed to delete ReplicaSet")
 
-		_, err = registry.Get(ctx, "test-replicaset")
-		assert.Error(t, err, "Expected error when getting deleted ReplicaSet")
-	})
+	_, err = registry.Get(ctx, api.DefaultNamespace, "test-replicaset")
+	assert.Error(t, err, "Expected error when getting deleted ReplicaSet")
+}
+
+func TestReplicaSetRegistry_Namespaces(t *testing.T) {
+	registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+	ctx := context.Background()
+	inStaging := createTestReplicaSet("web", 3, "nginx:latest")
+	inStaging.Namespace = "staging"
+	require.NoError(t, registry.Create(ctx, inStaging))
+	require.NoError(t, registry.Create(ctx, createTestReplicaSet("web", 1, "nginx:latest")), "names only need to be unique in a namespace")
+
+	rs, err := registry.Get(ctx, "", "web")
+	require.NoError(t, err)
+	assert.Equal(t, api.DefaultNamespace, rs.Namespace, "a replicaset without a namespace is in the default one")
+	rs, err = registry.Get(ctx, "staging", "web")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), rs.Spec.Replicas)
+	_, err = registry.Get(ctx, "prod", "web")
+	assert.ErrorIs(t, err, ErrReplicaSetNotFound)
+
+	replicaSets, err := registry.List(ctx, "staging")
+	require.NoError(t, err)
+	require.Len(t, replicaSets, 1)
+	assert.Equal(t, "staging", replicaSets[0].Namespace)
+	replicaSets, err = registry.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, replicaSets, 2, "all namespaces are listed without one")
+
+	scaled, err := registry.UpdateScale(ctx, &api.Scale{ObjectMeta: api.ObjectMeta{Name: "web", Namespace: "staging"}, Spec: api.ScaleSpec{Replicas: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, "staging", scaled.Namespace)
+	require.NoError(t, registry.Delete(ctx, "staging", "web"))
+	rs, err = registry.Get(ctx, api.DefaultNamespace, "web")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), rs.Spec.Replicas, "the replicaset of the other namespace is left alone")
+}
+
+func TestReplicaSetRegistry_MigrateLegacyReplicaSets(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage()
+	registry := NewReplicaSetRegistry(memoryStorage)
+	ctx := context.Background()
+
+	// Stored by a version that kept ReplicaSets by name only; one has the name of a ReplicaSet stored since
+	inStaging := createTestReplicaSet("api", 2, "nginx:latest")
+	inStaging.Namespace = "staging"
+	require.NoError(t, memoryStorage.Create(ctx, replicaSetPrefix+"api", inStaging))
+	require.NoError(t, memoryStorage.Create(ctx, replicaSetPrefix+"web", createTestReplicaSet("web", 1, "nginx:latest")))
+	require.NoError(t, registry.Create(ctx, createTestReplicaSet("web", 3, "nginx:latest")))
+
+	migrated, err := registry.MigrateLegacyReplicaSets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, migrated, "a replicaset whose name is taken is not moved")
+	rs, err := registry.Get(ctx, "staging", "api")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), rs.Spec.Replicas)
+	rs, err = registry.Get(ctx, api.DefaultNamespace, "web")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), rs.Spec.Replicas)
+	keys, err := memoryStorage.ListKeys(ctx, replicaSetPrefix)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{replicaSetPrefix + "staging/api", replicaSetPrefix + "default/web", replicaSetPrefix + "web"}, keys)
+
+	migrated, err = registry.MigrateLegacyReplicaSets(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, migrated)
+}
+
+func TestReplicaSetRegistry_MaxReplicas(t *testing.T) {
+	ctx := context.Background()
+	registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+	assert.Equal(t, api.DefaultMaxReplicasPerReplicaSet, registry.MaxReplicas())
+
+	err := registry.Create(ctx, createTestReplicaSet("too-many", api.DefaultMaxReplicasPerReplicaSet+1, "nginx:latest"))
+	assert.ErrorIs(t, err, ErrReplicaSetInvalid)
+
+	err = registry.Create(ctx, createTestReplicaSet("negative", -1, "nginx:latest"))
+	assert.ErrorIs(t, err, ErrReplicaSetInvalid)
+	assert.ErrorContains(t, err, "spec.replicas must be at least 0, got -1")
+
+	registry.SetMaxReplicas(10)
+	rs := createTestReplicaSet("capped", 10, "nginx:latest")
+	require.NoError(t, registry.Create(ctx, rs))
+
+	rs.Spec.Replicas = 11
+	assert.ErrorIs(t, registry.Update(ctx, rs), ErrReplicaSetInvalid)
+}
+
+func TestReplicaSetRegistry_UpdateStatus(t *testing.T) {
+	ctx := context.Background()
+	registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+
+	rs := createTestReplicaSet("status-rs", 3, "nginx:latest")
+	require.NoError(t, registry.Create(ctx, rs))
+
+	stale := createTestReplicaSet("status-rs", 1, "busybox")
+	stale.Status.Replicas = 3
+	require.NoError(t, registry.UpdateStatus(ctx, stale))
+
+	stored, err := registry.Get(ctx, api.DefaultNamespace, "status-rs")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), stored.Status.Replicas)
+	assert.Equal(t, int32(3), stored.Spec.Replicas, "status update must not change the spec")
+
+	err = registry.UpdateStatus(ctx, createTestReplicaSet("missing", 1, "nginx"))
+	assert.ErrorIs(t, err, ErrReplicaSetNotFound)
+}
+
+// TestReplicaSetRegistry_ConcurrentUpdates scales a ReplicaSet while its status is reported, as kubectl and
+// the controller do; each write is retried on the other rather than losing it or failing. Run it with -race.
+func TestReplicaSetRegistry_ConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+	require.NoError(t, registry.Create(ctx, createTestReplicaSet("web", 1, "nginx:latest")))
+
+	const updates = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			_, err := registry.UpdateScale(ctx, &api.Scale{ObjectMeta: api.ObjectMeta{Name: "web"}, Spec: api.ScaleSpec{Replicas: int32(i)}})
+			assert.NoError(t, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			assert.NoError(t, registry.UpdateStatus(ctx, &api.ReplicaSet{
+				ObjectMeta: api.ObjectMeta{Name: "web"},
+				Status:     api.ReplicaSetStatus{Replicas: int32(i)},
+			}))
+		}
+	}()
+	wg.Wait()
+
+	stored, err := registry.Get(ctx, api.DefaultNamespace, "web")
+	require.NoError(t, err)
+	assert.Equal(t, int32(updates), stored.Spec.Replicas, "no scale is lost")
+	assert.Equal(t, int32(updates), stored.Status.Replicas, "no status is lost")
+}
+
+func TestReplicaSetRegistry_UpdateStatusOp(t *testing.T) {
+	ctx := context.Background()
+	memoryStorage := storage.NewMemoryStorage()
+	registry := NewReplicaSetRegistry(memoryStorage)
+
+	rs := createTestReplicaSet("status-rs", 3, "nginx:latest")
+	require.NoError(t, registry.Create(ctx, rs))
+	rs.Status.Replicas = 3
+	op, err := registry.UpdateStatusOp(ctx, rs)
+	require.NoError(t, err)
+
+	// A change between reading the ReplicaSet and committing its status fails the transaction
+	scaled := createTestReplicaSet("status-rs", 5, "nginx:latest")
+	require.NoError(t, registry.Update(ctx, scaled))
+	assert.ErrorIs(t, memoryStorage.Txn(ctx, []storage.TxnOp{op}), storage.ErrConflict)
+
+	op, err = registry.UpdateStatusOp(ctx, rs)
+	require.NoError(t, err)
+	require.NoError(t, memoryStorage.Txn(ctx, []storage.TxnOp{op}))
+	stored, err := registry.Get(ctx, api.DefaultNamespace, "status-rs")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), stored.Status.Replicas)
+	assert.Equal(t, int32(5), stored.Spec.Replicas, "status update must not change the spec")
+
+	_, err = registry.UpdateStatusOp(ctx, createTestReplicaSet("missing", 1, "nginx"))
+	assert.ErrorIs(t, err, ErrReplicaSetNotFound)
+}
+
+func TestReplicaSetRegistry_UpdateScale(t *testing.T) {
+	ctx := context.Background()
+	registry := NewReplicaSetRegistry(storage.NewMemoryStorage())
+	registry.SetMaxReplicas(10)
+
+	rs := createTestReplicaSet("scaled-rs", 3, "nginx:latest")
+	rs.Status.Replicas = 3
+	require.NoError(t, registry.Create(ctx, rs))
+
+	updated, err := registry.UpdateScale(ctx, &api.Scale{ObjectMeta: api.ObjectMeta{Name: "scaled-rs", UID: rs.UID}, Spec: api.ScaleSpec{Replicas: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), updated.Spec.Replicas)
+	stored, err := registry.Get(ctx, api.DefaultNamespace, "scaled-rs")
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), stored.Spec.Replicas)
+	assert.Equal(t, "nginx:latest", stored.Spec.Template.Spec.Containers[0].Image, "scaling leaves the rest of the spec alone")
+	assert.Equal(t, int32(3), stored.Status.Replicas)
+
+	_, err = registry.UpdateScale(ctx, &api.Scale{ObjectMeta: api.ObjectMeta{Name: "scaled-rs", UID: "uid-0"}, Spec: api.ScaleSpec{Replicas: 1}})
+	assert.ErrorIs(t, err, ErrReplicaSetConflict, "the ReplicaSet was recreated")
+	_, err = registry.UpdateScale(ctx, &api.Scale{ObjectMeta: api.ObjectMeta{Name: "scaled-rs"}, Spec: api.ScaleSpec{Replicas: 11}})
+	assert.ErrorIs(t, err, ErrReplicaSetInvalid)
+	_, err = registry.UpdateScale(ctx, &api.Scale{ObjectMeta: api.ObjectMeta{Name: "missing"}, Spec: api.ScaleSpec{Replicas: 1}})
+	assert.ErrorIs(t, err, ErrReplicaSetNotFound)
+
+	stored, err = registry.Get(ctx, api.DefaultNamespace, "scaled-rs")
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), stored.Spec.Replicas)
 }
@@ -0,0 +1,242 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// ResourceQuotas are keyed by namespace, as each namespace names its own, e.g. compute
+const resourceQuotaPrefix = "/registry/resourcequotas/"
+
+var (
+	ErrResourceQuotaNotFound      = fmt.Errorf("resource quota %w", ErrNotFound)
+	ErrResourceQuotaAlreadyExists = errors.New("resource quota already exists")
+	ErrResourceQuotaInvalid       = errors.New("invalid resource quota")
+	ErrListResourceQuotasFailed   = errors.New("failed to list resource quotas")
+	// ErrQuotaExceeded is returned when a pod would take its namespace over a ResourceQuota
+	ErrQuotaExceeded = errors.New("exceeded quota")
+)
+
+// ResourceQuotaRegistry stores ResourceQuotas and admits the pods that stay within them. What the pods of a
+// namespace use is worked out from the pods on every read and admission rather than kept, so it can never
+// drift from them.
+type ResourceQuotaRegistry struct {
+	storage    storage.Storage
+	mutex      sync.RWMutex
+	namespaces *NamespaceRegistry
+}
+
+// NewResourceQuotaRegistry creates a new ResourceQuotaRegistry
+func NewResourceQuotaRegistry(storage storage.Storage) *ResourceQuotaRegistry {
+	return &ResourceQuotaRegistry{storage: storage}
+}
+
+// SetNamespaceRegistry makes Create reject quotas whose namespace does not exist or is terminating
+func (r *ResourceQuotaRegistry) SetNamespaceRegistry(namespaces *NamespaceRegistry) {
+	r.namespaces = namespaces
+}
+
+func (r *ResourceQuotaRegistry) generateKey(namespace, name string) string {
+	return resourceQuotaPrefix + namespace + "/" + name
+}
+
+// Create stores a new ResourceQuota. A quota without a namespace limits the default namespace.
+func (r *ResourceQuotaRegistry) Create(ctx context.Context, quota *api.ResourceQuota) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	quota.Namespace = api.NamespaceOf(&quota.ObjectMeta)
+	if r.namespaces != nil {
+		if err := r.namespaces.CheckActive(ctx, quota.Namespace); err != nil {
+			return err
+		}
+	}
+	if err := quota.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrResourceQuotaInvalid, err)
+	}
+	key := r.generateKey(quota.Namespace, quota.Name)
+
+	if quota.UID == "" {
+		quota.UID = uuid.NewString()
+	}
+	quota.Status = api.ResourceQuotaStatus{}
+	if err := r.storage.Create(ctx, key, quota); err != nil {
+		return existsAs(err, fmt.Errorf("%w: %s/%s", ErrResourceQuotaAlreadyExists, quota.Namespace, quota.Name))
+	}
+	return r.setStatus(ctx, quota.Namespace, []*api.ResourceQuota{quota})
+}
+
+// Get retrieves a ResourceQuota with what the pods of its namespace use
+func (r *ResourceQuotaRegistry) Get(ctx context.Context, namespace, name string) (*api.ResourceQuota, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	quota := &api.ResourceQuota{}
+	if err := r.storage.Get(ctx, r.generateKey(namespace, name), quota); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s/%s", ErrResourceQuotaNotFound, namespace, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get resource quota: %v", ErrInternal, err)
+		}
+	}
+	if err := r.setStatus(ctx, namespace, []*api.ResourceQuota{quota}); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// List retrieves the ResourceQuotas of a namespace with what its pods use
+func (r *ResourceQuotaRegistry) List(ctx context.Context, namespace string) ([]*api.ResourceQuota, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	quotas, err := r.list(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.setStatus(ctx, namespace, quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+func (r *ResourceQuotaRegistry) list(ctx context.Context, namespace string) ([]*api.ResourceQuota, error) {
+	quotas, err := listOf[api.ResourceQuota](ctx, r.storage, resourceQuotaPrefix+namespace+"/")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListResourceQuotasFailed, err)
+	}
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Name < quotas[j].Name })
+	return quotas, nil
+}
+
+// Delete removes a ResourceQuota
+func (r *ResourceQuotaRegistry) Delete(ctx context.Context, namespace, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := r.generateKey(namespace, name)
+	return deleteAs(ctx, r.storage, key, fmt.Errorf("%w: %s/%s", ErrResourceQuotaNotFound, namespace, name))
+}
+
+// DeleteNamespace removes the ResourceQuotas of a namespace
+func (r *ResourceQuotaRegistry) DeleteNamespace(ctx context.Context, namespace string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.storage.DeletePrefix(ctx, resourceQuotaPrefix+namespace+"/")
+}
+
+// AdmitPods checks that creating all of pods keeps their namespaces within their ResourceQuotas, with the
+// requests of the pods of a namespace added up. Only the resources the pods request are checked, so a pod
+// without a cpu request fits a cpu quota that is used up.
+//
+// It returns the writes to commit in the transaction creating the pods: they store the quotas checked again at
+// the version they were read, so that transaction fails with storage.ErrConflict if another create went through
+// the same quotas meanwhile, and two pods cannot both take the last of a quota. The caller then admits the pods
+// again.
+func (r *ResourceQuotaRegistry) AdmitPods(ctx context.Context, pods []*api.Pod) ([]storage.TxnOp, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	requested := map[string]map[api.ResourceName]int64{}
+	var namespaces []string
+	for _, pod := range pods {
+		namespace := api.NamespaceOf(&pod.ObjectMeta)
+		if requested[namespace] == nil {
+			requested[namespace] = map[api.ResourceName]int64{}
+			namespaces = append(namespaces, namespace)
+		}
+		for resource, value := range pod.ResourceRequests() {
+			requested[namespace][resource] += value
+		}
+	}
+	var ops []storage.TxnOp
+	for _, namespace := range namespaces {
+		quotas, err := r.admit(ctx, namespace, requested[namespace])
+		if err != nil {
+			return nil, err
+		}
+		for _, quota := range quotas {
+			ops = append(ops, storage.UpdateOp(r.generateKey(namespace, quota.Name), quota))
+		}
+	}
+	return ops, nil
+}
+
+// admit checks that the pods of a namespace can request requested on top of what they use, and returns the
+// quotas it checked
+func (r *ResourceQuotaRegistry) admit(ctx context.Context, namespace string, requested map[api.ResourceName]int64) ([]*api.ResourceQuota, error) {
+	quotas, err := r.list(ctx, namespace)
+	if err != nil || len(quotas) == 0 {
+		return nil, err
+	}
+	used, err := r.usage(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, quota := range quotas {
+		for _, resource := range slices.Sorted(maps.Keys(quota.Spec.Hard)) {
+			if requested[resource] == 0 {
+				continue
+			}
+			hard, err := api.ParseQuantity(resource, quota.Spec.Hard[resource])
+			if err != nil {
+				return nil, fmt.Errorf("%w: quota %s: %w", ErrInternal, quota.Name, err)
+			}
+			if used[resource]+requested[resource] > hard {
+				return nil, fmt.Errorf("%w %s in namespace %s: %s requested %s, used %s, limited to %s", ErrQuotaExceeded,
+					quota.Name, namespace, resource, api.FormatQuantity(resource, requested[resource]),
+					api.FormatQuantity(resource, used[resource]), quota.Spec.Hard[resource])
+			}
+		}
+	}
+	return quotas, nil
+}
+
+// usage sums the requests of the pods of a namespace that have not finished, in the base unit of each resource
+func (r *ResourceQuotaRegistry) usage(ctx context.Context, namespace string) (map[api.ResourceName]int64, error) {
+	pods, err := listOf[api.Pod](ctx, r.storage, podPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListPodsFailed, err)
+	}
+	used := map[api.ResourceName]int64{}
+	for _, pod := range pods {
+		if api.NamespaceOf(&pod.ObjectMeta) != namespace || pod.Status == api.PodSucceeded || pod.Status == api.PodFailed {
+			continue
+		}
+		for resource, value := range pod.ResourceRequests() {
+			used[resource] += value
+		}
+	}
+	return used, nil
+}
+
+// setStatus reports the limits of the quotas of a namespace next to what its pods use
+func (r *ResourceQuotaRegistry) setStatus(ctx context.Context, namespace string, quotas []*api.ResourceQuota) error {
+	if len(quotas) == 0 {
+		return nil
+	}
+	used, err := r.usage(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	for _, quota := range quotas {
+		quota.Status.Hard = maps.Clone(quota.Spec.Hard)
+		quota.Status.Used = api.ResourceList{}
+		for resource := range quota.Spec.Hard {
+			quota.Status.Used[resource] = api.FormatQuantity(resource, used[resource])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// withQuotaRegistries runs test with a pod registry that admits pods through the quota registry
+func withQuotaRegistries(t *testing.T, test func(t *testing.T, podRegistry *PodRegistry, quotaRegistry *ResourceQuotaRegistry)) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		quotaRegistry := NewResourceQuotaRegistry(store)
+		podRegistry := NewPodRegistry(store)
+		podRegistry.SetResourceQuotaRegistry(quotaRegistry)
+		test(t, podRegistry, quotaRegistry)
+	})
+}
+
+func newQuota(namespace string, hard api.ResourceList) *api.ResourceQuota {
+	return &api.ResourceQuota{ObjectMeta: api.ObjectMeta{Name: "compute", Namespace: namespace}, Spec: api.ResourceQuotaSpec{Hard: hard}}
+}
+
+func newRequestingPod(name, namespace, cpu string) *api.Pod {
+	pod := newBatchTestPod(name)
+	pod.Namespace = namespace
+	if cpu != "" {
+		pod.Spec.Containers[0].Resources = &api.ResourceRequirements{Requests: api.ResourceList{api.ResourceCPU: cpu}}
+	}
+	return pod
+}
+
+func TestResourceQuotaRegistry_PodCount(t *testing.T) {
+	withQuotaRegistries(t, func(t *testing.T, podRegistry *PodRegistry, quotaRegistry *ResourceQuotaRegistry) {
+		ctx := context.Background()
+		require.NoError(t, quotaRegistry.Create(ctx, newQuota("staging", api.ResourceList{api.ResourcePods: "2"})))
+
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-1", "staging", "")))
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-2", "staging", "")))
+		err := podRegistry.CreatePod(ctx, newRequestingPod("web-3", "staging", ""))
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.EqualError(t, err, "exceeded quota compute in namespace staging: pods requested 1, used 2, limited to 2")
+
+		// Other namespaces are not limited
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-3", "", "")))
+
+		quota, err := quotaRegistry.Get(ctx, "staging", "compute")
+		require.NoError(t, err)
+		assert.Equal(t, api.ResourceList{api.ResourcePods: "2"}, quota.Status.Hard)
+		assert.Equal(t, api.ResourceList{api.ResourcePods: "2"}, quota.Status.Used)
+
+		// Usage is worked out afresh, so deleted and finished pods free the quota at once
+		require.NoError(t, podRegistry.DeletePod(ctx, "staging", "web-1"))
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-4", "staging", "")))
+		require.NoError(t, podRegistry.UpdatePodStatus(ctx, &api.PodStatusUpdate{Name: "web-4", Namespace: "staging", Status: api.PodFailed}))
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-5", "staging", "")))
+	})
+}
+
+func TestResourceQuotaRegistry_CPU(t *testing.T) {
+	withQuotaRegistries(t, func(t *testing.T, podRegistry *PodRegistry, quotaRegistry *ResourceQuotaRegistry) {
+		ctx := context.Background()
+		require.NoError(t, quotaRegistry.Create(ctx, newQuota("", api.ResourceList{api.ResourceCPU: "1", api.ResourceMemory: "1Gi"})))
+
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-1", "", "600m")))
+		err := podRegistry.CreatePod(ctx, newRequestingPod("web-2", "", "0.5"))
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.EqualError(t, err, "exceeded quota compute in namespace default: cpu requested 500m, used 600m, limited to 1")
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-2", "", "400m")))
+		// Pods that request no cpu are not held back by a cpu quota
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-3", "", "")))
+
+		quotas, err := quotaRegistry.List(ctx, api.DefaultNamespace)
+		require.NoError(t, err)
+		require.Len(t, quotas, 1)
+		assert.Equal(t, api.ResourceList{api.ResourceCPU: "1", api.ResourceMemory: "0"}, quotas[0].Status.Used)
+	})
+}
+
+func TestResourceQuotaRegistry_AdmitPods(t *testing.T) {
+	withQuotaRegistries(t, func(t *testing.T, podRegistry *PodRegistry, quotaRegistry *ResourceQuotaRegistry) {
+		ctx := context.Background()
+		require.NoError(t, quotaRegistry.Create(ctx, newQuota("", api.ResourceList{api.ResourceCPU: "1"})))
+		require.NoError(t, podRegistry.CreatePod(ctx, newRequestingPod("web-1", "", "400m")))
+
+		// Each pod fits on its own, but not both
+		err := podRegistry.CreatePodsWith(ctx, []*api.Pod{newRequestingPod("web-2", "", "300m"), newRequestingPod("web-3", "", "400m")})
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.EqualError(t, err, "exceeded quota compute in namespace default: cpu requested 700m, used 400m, limited to 1")
+		pods, err := podRegistry.ListPods(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, pods, 1)
+
+		require.NoError(t, podRegistry.CreatePodsWith(ctx, []*api.Pod{newRequestingPod("web-2", "", "300m"), newRequestingPod("web-3", "staging", "400m")}))
+	})
+}
+
+func TestResourceQuotaRegistry_ConcurrentCreates(t *testing.T) {
+	withQuotaRegistries(t, func(t *testing.T, podRegistry *PodRegistry, quotaRegistry *ResourceQuotaRegistry) {
+		ctx := context.Background()
+		require.NoError(t, quotaRegistry.Create(ctx, newQuota("staging", api.ResourceList{api.ResourcePods: "3", api.ResourceCPU: "2"})))
+
+		var created, rejected atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := podRegistry.CreatePod(ctx, newRequestingPod(fmt.Sprintf("web-%d", i), "staging", "500m"))
+				switch {
+				case err == nil:
+					created.Add(1)
+				case assert.ErrorIs(t, err, ErrQuotaExceeded):
+					rejected.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(3), created.Load())
+		assert.Equal(t, int32(7), rejected.Load())
+		quota, err := quotaRegistry.Get(ctx, "staging", "compute")
+		require.NoError(t, err)
+		assert.Equal(t, api.ResourceList{api.ResourcePods: "3", api.ResourceCPU: "1500m"}, quota.Status.Used)
+	})
+}
+
+func TestResourceQuotaRegistry_Create(t *testing.T) {
+	withQuotaRegistries(t, func(t *testing.T, podRegistry *PodRegistry, quotaRegistry *ResourceQuotaRegistry) {
+		ctx := context.Background()
+		quota := newQuota("staging", api.ResourceList{api.ResourcePods: "2"})
+		require.NoError(t, quotaRegistry.Create(ctx, quota))
+		assert.NotEmpty(t, quota.UID)
+		assert.ErrorIs(t, quotaRegistry.Create(ctx, newQuota("staging", api.ResourceList{api.ResourcePods: "5"})), ErrResourceQuotaAlreadyExists)
+		// Each namespace names its own quotas
+		require.NoError(t, quotaRegistry.Create(ctx, newQuota("staging-2", api.ResourceList{api.ResourcePods: "5"})))
+
+		err := quotaRegistry.Create(ctx, newQuota("staging", api.ResourceList{"gpu": "1", api.ResourceCPU: "lots"}))
+		assert.ErrorIs(t, err, ErrResourceQuotaInvalid)
+		var fieldErrs api.FieldErrors
+		require.ErrorAs(t, err, &fieldErrs)
+		assert.Equal(t, api.FieldErrors{
+			{Field: "spec.hard.cpu", Message: `must be a quantity, such as 500m, got "lots"`},
+			{Field: "spec.hard.gpu", Message: "is not a resource: must be pods, cpu or memory"},
+		}, fieldErrs)
+
+		require.NoError(t, quotaRegistry.Delete(ctx, "staging", "compute"))
+		assert.ErrorIs(t, quotaRegistry.Delete(ctx, "staging", "compute"), ErrResourceQuotaNotFound)
+		quotas, err := quotaRegistry.List(ctx, "staging-2")
+		require.NoError(t, err)
+		assert.Len(t, quotas, 1)
+	})
+}
@@ -0,0 +1,137 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+// Services are keyed by namespace, like ResourceQuotas
+const servicePrefix = "/registry/services/"
+
+var (
+	ErrServiceNotFound      = fmt.Errorf("service %w", ErrNotFound)
+	ErrServiceAlreadyExists = errors.New("service already exists")
+	ErrServiceInvalid       = errors.New("invalid service")
+	ErrListServicesFailed   = errors.New("failed to list services")
+)
+
+// ServiceRegistry stores the Services that expose pods through the proxy
+type ServiceRegistry struct {
+	storage    storage.Storage
+	mutex      sync.RWMutex
+	namespaces *NamespaceRegistry
+}
+
+// NewServiceRegistry creates a new ServiceRegistry
+func NewServiceRegistry(storage storage.Storage) *ServiceRegistry {
+	return &ServiceRegistry{storage: storage}
+}
+
+// SetNamespaceRegistry makes Create reject services whose namespace does not exist or is terminating
+func (r *ServiceRegistry) SetNamespaceRegistry(namespaces *NamespaceRegistry) {
+	r.namespaces = namespaces
+}
+
+func (r *ServiceRegistry) generateKey(namespace, name string) string {
+	return servicePrefix + namespace + "/" + name
+}
+
+// Create stores a new Service. A service without a namespace selects the pods of the default namespace.
+func (r *ServiceRegistry) Create(ctx context.Context, service *api.Service) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	service.Namespace = api.NamespaceOf(&service.ObjectMeta)
+	if r.namespaces != nil {
+		if err := r.namespaces.CheckActive(ctx, service.Namespace); err != nil {
+			return err
+		}
+	}
+	if err := service.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrServiceInvalid, err)
+	}
+	key := r.generateKey(service.Namespace, service.Name)
+
+	if service.UID == "" {
+		service.UID = uuid.NewString()
+	}
+	if err := r.storage.Create(ctx, key, service); err != nil {
+		return existsAs(err, fmt.Errorf("%w: %s/%s", ErrServiceAlreadyExists, service.Namespace, service.Name))
+	}
+	return nil
+}
+
+// Get retrieves a Service
+func (r *ServiceRegistry) Get(ctx context.Context, namespace, name string) (*api.Service, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	service := &api.Service{}
+	if err := r.storage.Get(ctx, r.generateKey(namespace, name), service); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return nil, fmt.Errorf("%w: %s/%s", ErrServiceNotFound, namespace, name)
+		default:
+			return nil, fmt.Errorf("%w: failed to get service: %v", ErrInternal, err)
+		}
+	}
+	return service, nil
+}
+
+// List retrieves the Services of a namespace, or of all namespaces if namespace is empty, ordered by namespace
+// and name
+func (r *ServiceRegistry) List(ctx context.Context, namespace string) ([]*api.Service, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	services, err := listOf[api.Service](ctx, r.storage, namespacedPrefix(servicePrefix, namespace))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrListServicesFailed, err)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return lessNamespaced(&services[i].ObjectMeta, &services[j].ObjectMeta)
+	})
+	return services, nil
+}
+
+// Delete removes a Service. Its endpoints are removed by the endpoints controller.
+func (r *ServiceRegistry) Delete(ctx context.Context, namespace, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := r.generateKey(namespace, name)
+	return deleteAs(ctx, r.storage, key, fmt.Errorf("%w: %s/%s", ErrServiceNotFound, namespace, name))
+}
+
+// DeleteNamespace removes the Services of a namespace
+func (r *ServiceRegistry) DeleteNamespace(ctx context.Context, namespace string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.storage.DeletePrefix(ctx, servicePrefix+namespace+"/")
+}
+
+// namespacedPrefix is the storage prefix of the objects of a namespace, or of all namespaces if namespace is
+// empty
+func namespacedPrefix(prefix, namespace string) string {
+	if namespace == "" {
+		return prefix
+	}
+	return prefix + namespace + "/"
+}
+
+// lessNamespaced orders objects by namespace, then name
+func lessNamespaced(a, b *api.ObjectMeta) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/storage"
+)
+
+func newService(name, namespace string) *api.Service {
+	return &api.Service{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       api.ServiceSpec{Selector: map[string]string{"app": name}, Ports: []api.ServicePort{{Port: 80}}},
+	}
+}
+
+func TestServiceRegistry(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdServer *clientv3.Client) {
+		store := storage.NewEtcdStorage(etcdServer)
+		namespaceRegistry := NewNamespaceRegistry(store)
+		serviceRegistry := NewServiceRegistry(store)
+		serviceRegistry.SetNamespaceRegistry(namespaceRegistry)
+		ctx := context.Background()
+		require.NoError(t, namespaceRegistry.Create(ctx, &api.Namespace{ObjectMeta: api.ObjectMeta{Name: "staging"}}))
+
+		require.NoError(t, serviceRegistry.Create(ctx, newService("web", "staging")))
+		require.NoError(t, serviceRegistry.Create(ctx, newService("web", "")))
+		assert.ErrorIs(t, serviceRegistry.Create(ctx, newService("web", "staging")), ErrServiceAlreadyExists)
+		assert.ErrorIs(t, serviceRegistry.Create(ctx, newService("web", "missing")), ErrNamespaceNotFound)
+		assert.ErrorIs(t, serviceRegistry.Create(ctx, newService("Web", "staging")), ErrServiceInvalid)
+
+		services, err := serviceRegistry.List(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, services, 2)
+		assert.Equal(t, []string{api.DefaultNamespace, "staging"}, []string{services[0].Namespace, services[1].Namespace})
+		services, err = serviceRegistry.List(ctx, "staging")
+		require.NoError(t, err)
+		assert.Len(t, services, 1)
+
+		require.NoError(t, serviceRegistry.Delete(ctx, "staging", "web"))
+		_, err = serviceRegistry.Get(ctx, "staging", "web")
+		assert.ErrorIs(t, err, ErrServiceNotFound)
+		assert.ErrorIs(t, serviceRegistry.Delete(ctx, "staging", "web"), ErrServiceNotFound)
+	})
+}
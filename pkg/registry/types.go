@@ -1,5 +1,254 @@
 package registry
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gokube/pkg/api"
+	"gokube/pkg/logging"
+	"gokube/pkg/runtime"
+	"gokube/pkg/storage"
+)
 
 var ErrInternal = errors.New("internal error")
+
+// ErrNotFound is wrapped by the errors of every registry for an object that does not exist, such as
+// ErrPodNotFound
+var ErrNotFound = errors.New("not found")
+
+// IgnoreNotFound returns nil for an error about an object that does not exist, and err otherwise. Callers for
+// which deleting an object that is already gone is success, like controllers cleaning up, delete with it.
+func IgnoreNotFound(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// ErrInvalidContinue is returned for a continue token that was not returned with a page of the same list
+var ErrInvalidContinue = errors.New("invalid continue token")
+
+// existsAs returns exists if the storage rejected a create because the key is taken, and err otherwise
+func existsAs(err, exists error) error {
+	if errors.Is(err, storage.ErrKeyExists) {
+		return exists
+	}
+	return err
+}
+
+// conflictAs returns err wrapped in conflict if the storage rejected a write because the object was changed
+// since the resource version it carries, and err as it is otherwise
+func conflictAs(err, conflict error) error {
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w: %w", conflict, err)
+	}
+	return err
+}
+
+// conflictRetries is how many times retryOnConflict attempts an update
+const conflictRetries = 5
+
+// retryOnConflict calls update, which reads an object, changes it and writes it back at the version it read,
+// again while the write fails with storage.ErrConflict because the object was changed in between. It returns
+// the error of the last attempt.
+func retryOnConflict(update func() error) error {
+	var err error
+	for range conflictRetries {
+		if err = update(); !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// deleteAs deletes key, returning notFound if there is nothing to delete
+func deleteAs(ctx context.Context, s storage.Storage, key string, notFound error) error {
+	if err := s.Delete(ctx, key); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return notFound
+		}
+		return err
+	}
+	return nil
+}
+
+// replace stores obj under key only if the key exists, so an update racing with a delete does not recreate the
+// object. It returns notFound if the key is gone, and the errors of a changed object wrapped in conflict like
+// conflictAs.
+func replace(ctx context.Context, s storage.Storage, key string, obj runtime.Object, notFound, conflict error) error {
+	err := s.Txn(ctx, []storage.TxnOp{storage.ReplaceOp(key, obj)})
+	if errors.Is(err, storage.ErrNotFound) {
+		return notFound
+	}
+	return conflictAs(err, conflict)
+}
+
+// stampCreated gives the metadata of an object being created a new UID and the time it is created at, unless
+// it has them
+func stampCreated(meta *api.ObjectMeta) {
+	if meta.UID == "" {
+		meta.UID = uuid.NewString()
+	}
+	if meta.CreationTimestamp.IsZero() {
+		meta.CreationTimestamp = time.Now().UTC()
+	}
+}
+
+// keepCreated gives the metadata of an update the UID and creation time of the object it updates, so an update
+// that leaves them out does not drop them
+func keepCreated(meta, existing *api.ObjectMeta) {
+	if meta.UID == "" {
+		meta.UID = existing.UID
+	}
+	if meta.CreationTimestamp.IsZero() {
+		meta.CreationTimestamp = existing.CreationTimestamp
+	}
+}
+
+// listOf lists the objects under prefix as storage.ListOf does, skipping the values that fail to decode rather
+// than failing with them, so one corrupt value does not take down every reader of the list. The keys of the
+// skipped values are logged with their errors.
+func listOf[T any](ctx context.Context, s storage.Storage, prefix string) ([]*T, error) {
+	items, err := storage.ListOf[T](ctx, s, prefix)
+	return items, skipCorrupt(ctx, err)
+}
+
+// listWithRevisionOf lists the objects under prefix like listOf, along with the revision they were read at
+func listWithRevisionOf[T any](ctx context.Context, s storage.Storage, prefix string) ([]*T, int64, error) {
+	items, revision, err := storage.ListWithRevisionOf[T](ctx, s, prefix)
+	return items, revision, skipCorrupt(ctx, err)
+}
+
+// listPageOf lists a page of the objects under prefix as storage.ListPageOf does, skipping the values that fail
+// to decode like listOf
+func listPageOf[T any](ctx context.Context, s storage.Storage, prefix string, options storage.ListOptions) ([]*T, string, error) {
+	items, next, err := storage.ListPageOf[T](ctx, s, prefix, options)
+	return items, next, skipCorrupt(ctx, err)
+}
+
+// listNames returns the keys under prefix without it, which are the names of the objects stored there
+func listNames(ctx context.Context, s storage.Storage, prefix string) ([]string, error) {
+	keys, err := s.ListKeys(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = strings.TrimPrefix(key, prefix)
+	}
+	return names, nil
+}
+
+// watchOf follows the changes of the objects under prefix made after revision, or from now on for 0, decoded as
+// T, and sends them as the events event makes of them. The channel is closed when ctx is done or the storage
+// watch ends, after which the caller relists and watches again.
+func watchOf[T any, E any](ctx context.Context, s storage.Storage, prefix string, revision int64, event func(api.EventType, *T) E) (<-chan E, error) {
+	changes, err := s.WatchFrom(ctx, prefix, revision, func() runtime.Object { return new(T) })
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan E)
+	go func() {
+		defer close(events)
+		for change := range changes {
+			eventType := api.EventModified
+			switch {
+			case change.Type == storage.WatchDelete:
+				eventType = api.EventDeleted
+			case change.Created:
+				eventType = api.EventAdded
+			}
+			select {
+			case events <- event(eventType, change.Object.(*T)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// skipCorrupt logs the values a list skipped as corrupt, and returns err unless it only reports them
+func skipCorrupt(ctx context.Context, err error) error {
+	var corrupt *storage.CorruptValuesError
+	if !errors.As(err, &corrupt) {
+		return err
+	}
+	logger := logging.Component("registry")
+	for _, value := range corrupt.Values {
+		logger.WarnContext(ctx, "Skipped corrupt value", "key", value.Key, logging.Err(value.Err))
+	}
+	return nil
+}
+
+// traceID returns the trace ID of an object created with ctx: the ID of the request creating it, or a new ID
+// when it is not created for a request
+func traceID(ctx context.Context) string {
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		return requestID
+	}
+	return logging.NewRequestID()
+}
+
+// MaxConcurrentGets bounds the storage reads a batch get, such as PodRegistry.GetPods, has in flight at once
+const MaxConcurrentGets = 16
+
+// getAll gets the objects of the named keys with up to MaxConcurrentGets reads at once. Names whose object
+// does not exist, i.e. get fails with notFound, are returned as missing, in the order they were asked for.
+// Any other failure fails the whole call and cancels the reads still to come. Names asked for twice are
+// read once.
+func getAll[T any](ctx context.Context, names []string, get func(ctx context.Context, name string) (*T, error),
+	notFound error) (map[string]*T, []string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		objects = make(map[string]*T, len(names))
+		seen    = make(map[string]bool, len(names))
+		failure error
+	)
+	slots := make(chan struct{}, MaxConcurrentGets)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			object, err := get(ctx, name)
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch {
+			case err == nil:
+				objects[name] = object
+			case !errors.Is(err, notFound) && failure == nil:
+				// The reads canceled after it fail too; report the failure that canceled them
+				failure = err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if failure != nil {
+		return nil, nil, failure
+	}
+
+	var missing []string
+	for _, name := range names {
+		if _, ok := objects[name]; !ok && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	return objects, missing, nil
+}
@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+)
+
+type testMeta struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type testPod struct {
+	testMeta `json:"metadata"`
+	Image    string    `json:"image"`
+	Replicas int32     `json:"replicas"`
+	Ports    []int     `json:"ports,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func TestCodecs(t *testing.T) {
+	pod := &testPod{
+		testMeta: testMeta{Name: "web", Labels: map[string]string{"app": "web"}},
+		Image:    "nginx:latest",
+		Replicas: 300,
+		Ports:    []int{80, 443},
+		Created:  time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+	for name, codec := range map[string]Codec{"json": JSONCodec, "compressed-json": CompressedJSONCodec} {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Encode(pod)
+			require.NoError(t, err)
+
+			decoded := &testPod{Image: "stale", Replicas: 1}
+			require.NoError(t, codec.Decode(data, decoded))
+			assert.Equal(t, pod, decoded)
+		})
+	}
+}
+
+func TestCompressedJSONCodec(t *testing.T) {
+	pod := benchmarkPod()
+	jsonData, err := JSONCodec.Encode(pod)
+	require.NoError(t, err)
+	data, err := CompressedJSONCodec.Encode(pod)
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(jsonData)/2, "a pod is stored in less than half its JSON")
+
+	// The deflaters are reused, so values encoded one after the other do not share any state
+	other := benchmarkPod()
+	other.Name = "api-5c6d7e8f9-a1b2c"
+	otherData, err := CompressedJSONCodec.Encode(other)
+	require.NoError(t, err)
+	decoded := &api.Pod{}
+	require.NoError(t, CompressedJSONCodec.Decode(data, decoded))
+	assert.Equal(t, pod, decoded)
+	require.NoError(t, CompressedJSONCodec.Decode(otherData, decoded))
+	assert.Equal(t, other, decoded)
+
+	assert.ErrorIs(t, CompressedJSONCodec.Decode(data[:len(data)-2], decoded), ErrInvalidCompressedJSON, "truncated")
+	assert.ErrorIs(t, CompressedJSONCodec.Decode(append([]byte{2}, data[1:]...), decoded), ErrInvalidCompressedJSON, "unknown format")
+	assert.ErrorIs(t, CompressedJSONCodec.Decode(jsonData, decoded), ErrInvalidCompressedJSON, "plain JSON")
+}
+
+func benchmarkPod() *api.Pod {
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name:              "web-7d4b9c8f6-x2k9p",
+			Namespace:         "default",
+			UID:               "4f1c0a6e-8a5b-4c1e-9a1f-6b3f1f0e2d11",
+			CreationTimestamp: time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC),
+			Labels:            map[string]string{"app": "web", "tier": "frontend"},
+			OwnerReferences:   []api.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d4b9c8f6", UID: "0b6c2a1e-3f4d-4e5f-8a9b-1c2d3e4f5a6b"}},
+		},
+		Spec: api.PodSpec{Containers: []api.Container{{
+			Name:  "nginx",
+			Image: "nginx:1.27",
+			Ports: []api.ContainerPort{{Name: "http", ContainerPort: 80}},
+		}}},
+		NodeName: "node-1",
+		Status:   api.PodRunning,
+	}
+}
+
+var benchmarkCodecs = []struct {
+	name  string
+	codec Codec
+}{
+	{name: "json", codec: JSONCodec},
+	{name: "compressed-json", codec: CompressedJSONCodec},
+}
+
+func BenchmarkCodec_Encode(b *testing.B) {
+	pod := benchmarkPod()
+	for _, bc := range benchmarkCodecs {
+		b.Run(bc.name, func(b *testing.B) {
+			var data []byte
+			b.ReportAllocs()
+			for range b.N {
+				var err error
+				if data, err = bc.codec.Encode(pod); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(data)), "bytes/value")
+		})
+	}
+}
+
+func BenchmarkCodec_Decode(b *testing.B) {
+	for _, bc := range benchmarkCodecs {
+		b.Run(bc.name, func(b *testing.B) {
+			data, err := bc.codec.Encode(benchmarkPod())
+			require.NoError(b, err)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if err := bc.codec.Decode(data, &api.Pod{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,21 +1,28 @@
 package runtime
 
 import (
-	"encoding/json"
 	"fmt"
 )
 
 // Object is a marker interface for Kubernetes-like API objects
 type Object interface{}
 
+// Versioned is an Object that carries the resource version of its stored copy. The storage sets it to the
+// etcd revision that last changed the object whenever the object is read or written, and updates it only if
+// it is unchanged since that version.
+type Versioned interface {
+	GetResourceVersion() string
+	SetResourceVersion(version string)
+}
+
 // Encode serializes an Object to JSON
 func Encode(obj Object) ([]byte, error) {
-	return json.Marshal(obj)
+	return JSONCodec.Encode(obj)
 }
 
 // Decode deserializes JSON data into an Object
 func Decode(data []byte, obj Object) error {
-	return json.Unmarshal(data, obj)
+	return JSONCodec.Decode(data, obj)
 }
 
 // GetObjectKind returns the kind of the object
@@ -2,16 +2,25 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"gokube/pkg/logging"
 	"gokube/pkg/registry"
 )
 
+var (
+	// ErrNoNodes is returned by a scheduling pass when there are no nodes to schedule pods to
+	ErrNoNodes = errors.New("no nodes available for scheduling")
+)
+
 type Scheduler struct {
 	podRegistry    *registry.PodRegistry
 	nodeRegistry   *registry.NodeRegistry
 	schedulingRate time.Duration
+	logger         *slog.Logger
 }
 
 func NewScheduler(podRegistry *registry.PodRegistry, nodeRegistry *registry.NodeRegistry, schedulingRate time.Duration) *Scheduler {
@@ -19,9 +28,15 @@ func NewScheduler(podRegistry *registry.PodRegistry, nodeRegistry *registry.Node
 		podRegistry:    podRegistry,
 		nodeRegistry:   nodeRegistry,
 		schedulingRate: schedulingRate,
+		logger:         logging.Component("scheduler"),
 	}
 }
 
+// SetLogger makes the scheduler log to logger instead of the default logger
+func (s *Scheduler) SetLogger(logger *slog.Logger) {
+	s.logger = logging.WithComponent(logger, "scheduler")
+}
+
 func (s *Scheduler) Start(ctx context.Context) {
 	ticker := time.NewTicker(s.schedulingRate)
 	defer ticker.Stop()
@@ -31,8 +46,15 @@ func (s *Scheduler) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := s.schedulePendingPods(ctx); err != nil {
-				fmt.Printf("Error scheduling pods: %v\n", err)
+			// The logs of a scheduling pass share an operation ID
+			passCtx := logging.WithOperationID(ctx, logging.NewRequestID())
+			err := s.schedulePendingPods(passCtx)
+			switch {
+			case errors.Is(err, ErrNoNodes):
+				// Expected while the nodes are registering
+				s.logger.WarnContext(passCtx, "No nodes to schedule pods to")
+			case err != nil:
+				s.logger.ErrorContext(passCtx, "Failed to schedule pods", logging.Err(err))
 			}
 		}
 	}
@@ -52,10 +74,15 @@ func (s *Scheduler) schedulePendingPods(ctx context.Context) error {
 	}
 
 	if len(nodes) == 0 {
-		return fmt.Errorf("no nodes available for scheduling")
+		return ErrNoNodes
+	}
+
+	for _, pod := range pods {
+		// The work on a pod is traced with the request that created it
+		podCtx := logging.WithRequestID(ctx, pod.TraceID())
+		s.logger.DebugContext(podCtx, "Scheduling pod", "pod", pod.Name, "podUID", pod.UID, "nodes", len(nodes))
 	}
 
 	//Assignment 4: Complete the scheduler implementation.
-	_ = pods
 	return nil
 }
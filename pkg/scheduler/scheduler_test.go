@@ -1,7 +1,12 @@
 package scheduler
 
 import (
+	"bytes"
 	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -136,7 +141,7 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 				}
 
 				// Check scheduled pods
-				scheduledPods, err := podRegistry.ListPods(ctx)
+				scheduledPods, err := podRegistry.ListPods(ctx, "")
 				require.NoErrorf(t, err, "Failed to list pods: %v", err)
 
 				scheduledCount := 0
@@ -151,3 +156,64 @@ func TestScheduler_SchedulePendingPods(t *testing.T) {
 		})
 	}
 }
+
+func TestScheduler_SurvivesStorageFailures(t *testing.T) {
+	storage.TestWithEmbeddedEtcd(t, func(t *testing.T, etcdClient *clientv3.Client) {
+		etcdStorage := storage.NewEtcdStorage(etcdClient)
+		chaos := storage.NewChaosStorage(etcdStorage, nil)
+		podRegistry := registry.NewPodRegistry(chaos)
+		nodeRegistry := registry.NewNodeRegistry(chaos)
+		scheduler := NewScheduler(podRegistry, nodeRegistry, 10*time.Millisecond)
+		logs := &lockedBuffer{}
+		scheduler.SetLogger(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		require.NoError(t, nodeRegistry.CreateNode(ctx, &api.Node{ObjectMeta: api.ObjectMeta{Name: "node1"}}))
+		for _, name := range []string{"pod1", "pod2"} {
+			pod := &api.Pod{
+				ObjectMeta: api.ObjectMeta{Name: name},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "container", Image: "nginx:latest"}}},
+				Status:     api.PodPending,
+			}
+			require.NoError(t, podRegistry.CreatePod(ctx, pod))
+		}
+
+		// The first listings of pods and nodes fail
+		chaos.SetPolicy(storage.Combine(
+			storage.Script(storage.Match{Op: storage.OpList, Prefix: "/registry/index/pods/"},
+				storage.Fault{Err: storage.ErrEtcdClient}, storage.Fault{Err: storage.ErrEtcdClient}),
+			storage.FailNth(storage.Match{Op: storage.OpList, Prefix: "/registry/nodes/"}, 1, storage.ErrEtcdClient),
+		))
+		go scheduler.Start(ctx)
+
+		// The scheduler keeps going after the failed passes, and a later pass gets to the pending pods
+		require.Eventually(t, func() bool {
+			return strings.Contains(logs.String(), "pod=pod1") && strings.Contains(logs.String(), "pod=pod2")
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		failures := regexp.MustCompile(`level=ERROR msg="Failed to schedule pods" component=scheduler error=".*injected`).
+			FindAllString(logs.String(), -1)
+		assert.Len(t, failures, 3, "the failed passes are logged, one for each injected fault")
+		assert.Contains(t, logs.String(), `failed to list pending pods`)
+		assert.Contains(t, logs.String(), `failed to list nodes`)
+	})
+}
+
+// lockedBuffer is a bytes.Buffer the scheduler can log to while the test reads it
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EtcdCerts are the files of a CA and of the server and client certificates it signed, for an etcd serving TLS
+// and the clients connecting to it
+type EtcdCerts struct {
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// GenerateSelfSignedCerts writes a new CA to dir with a server certificate for localhost and 127.0.0.1 and a
+// client certificate, valid for a day. They are meant for tests and demos of etcd over TLS, not for real
+// clusters.
+func GenerateSelfSignedCerts(dir string) (*EtcdCerts, error) {
+	certs := &EtcdCerts{
+		CAFile:         filepath.Join(dir, "ca.crt"),
+		ServerCertFile: filepath.Join(dir, "server.crt"),
+		ServerKeyFile:  filepath.Join(dir, "server.key"),
+		ClientCertFile: filepath.Join(dir, "client.crt"),
+		ClientKeyFile:  filepath.Join(dir, "client.key"),
+	}
+	notBefore := time.Now().Add(-time.Minute)
+	notAfter := notBefore.Add(24 * time.Hour)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the CA key: %w", err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gokube etcd CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if err := writeCert(certs.CAFile, "", ca, ca, caKey, caKey); err != nil {
+		return nil, err
+	}
+
+	server := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "etcd"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		// etcd checks the certificates of its peers and of itself with both usages
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if err := writeSignedCert(certs.ServerCertFile, certs.ServerKeyFile, server, ca, caKey); err != nil {
+		return nil, err
+	}
+
+	client := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "gokube"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if err := writeSignedCert(certs.ClientCertFile, certs.ClientKeyFile, client, ca, caKey); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// writeSignedCert writes template, signed by the CA, to certFile and its new key to keyFile
+func writeSignedCert(certFile, keyFile string, template, ca *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate a key for %s: %w", certFile, err)
+	}
+	return writeCert(certFile, keyFile, template, ca, key, caKey)
+}
+
+// writeCert writes template, signed by parent, to certFile and key to keyFile, unless keyFile is ""
+func writeCert(certFile, keyFile string, template, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) error {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", certFile, err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return err
+	}
+	if keyFile == "" {
+		return nil
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode the key of %s: %w", certFile, err)
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+}
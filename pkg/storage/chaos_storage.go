@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"gokube/pkg/runtime"
+)
+
+// Operation names a method of Storage
+type Operation string
+
+const (
+	OpCreate       Operation = "Create"
+	OpGet          Operation = "Get"
+	OpExists       Operation = "Exists"
+	OpUpdate       Operation = "Update"
+	OpDelete       Operation = "Delete"
+	OpDeletePrefix Operation = "DeletePrefix"
+	OpList         Operation = "List"
+	OpCount        Operation = "Count"
+	// OpKeepAlive renews a lease, which names no key
+	OpKeepAlive Operation = "KeepAlive"
+	// OpTxn is a transaction, which EtcdStorage reports without a key as its writes may span resources
+	OpTxn Operation = "Txn"
+	// OpWatch starts a watch of a prefix; the events that follow are not calls
+	OpWatch Operation = "Watch"
+)
+
+// Call records an operation a ChaosStorage was asked to do
+type Call struct {
+	Op Operation
+	// Key is the key, or the prefix for List, Count, DeletePrefix and Watch
+	Key string
+	// Err is the error the call returned, injected or not
+	Err error
+	// Injected reports whether the fault came from the policy rather than the inner storage
+	Injected bool
+}
+
+// Fault is what a ChaosPolicy does to a call: delay it by Latency, then fail it with Err without reaching the
+// inner storage. The zero Fault passes the call straight through.
+type Fault struct {
+	Err     error
+	Latency time.Duration
+}
+
+// ChaosPolicy decides the fault injected into each call. ChaosStorage asks it about one call at a time, so
+// policies can keep state without locking.
+type ChaosPolicy func(call Call) Fault
+
+// Match selects the calls a policy applies to: those of Op, or of any operation if Op is empty, on keys
+// starting with Prefix
+type Match struct {
+	Op     Operation
+	Prefix string
+}
+
+func (m Match) matches(call Call) bool {
+	return (m.Op == "" || m.Op == call.Op) && strings.HasPrefix(call.Key, m.Prefix)
+}
+
+// FailNth fails the nth call that matches, counting from 1, with err
+func FailNth(match Match, n int, err error) ChaosPolicy {
+	if n < 1 {
+		return Script(match)
+	}
+	return Script(match, append(make([]Fault, n-1), Fault{Err: err})...)
+}
+
+// FailRandomly fails each matching call with err with the given probability, drawing from a source seeded
+// with seed so failing tests can be replayed
+func FailRandomly(match Match, probability float64, err error, seed int64) ChaosPolicy {
+	random := rand.New(rand.NewSource(seed))
+	return func(call Call) Fault {
+		if match.matches(call) && random.Float64() < probability {
+			return Fault{Err: err}
+		}
+		return Fault{}
+	}
+}
+
+// Script injects faults into the matching calls in order, one fault per call, and passes the calls after the
+// last fault through
+func Script(match Match, faults ...Fault) ChaosPolicy {
+	next := 0
+	return func(call Call) Fault {
+		if !match.matches(call) || next >= len(faults) {
+			return Fault{}
+		}
+		next++
+		return faults[next-1]
+	}
+}
+
+// Delay slows every matching call down by latency
+func Delay(match Match, latency time.Duration) ChaosPolicy {
+	return func(call Call) Fault {
+		if match.matches(call) {
+			return Fault{Latency: latency}
+		}
+		return Fault{}
+	}
+}
+
+// Combine asks every policy about each call. The latencies add up and the first error wins.
+func Combine(policies ...ChaosPolicy) ChaosPolicy {
+	return func(call Call) Fault {
+		var combined Fault
+		for _, policy := range policies {
+			fault := policy(call)
+			combined.Latency += fault.Latency
+			if combined.Err == nil {
+				combined.Err = fault.Err
+			}
+		}
+		return combined
+	}
+}
+
+// ChaosStorage wraps a Storage, injecting the faults of a policy into its calls and recording every call for
+// assertions. It is meant for resilience tests.
+type ChaosStorage struct {
+	inner Storage
+
+	mutex  sync.Mutex
+	policy ChaosPolicy
+	calls  []Call
+}
+
+// NewChaosStorage wraps inner with policy; a nil policy injects nothing
+func NewChaosStorage(inner Storage, policy ChaosPolicy) *ChaosStorage {
+	return &ChaosStorage{inner: inner, policy: policy}
+}
+
+// SetPolicy replaces the policy for the calls to come, e.g. nil to let storage recover
+func (s *ChaosStorage) SetPolicy(policy ChaosPolicy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.policy = policy
+}
+
+// Calls returns the calls made so far, in order
+func (s *ChaosStorage) Calls() []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CountCalls returns how many of the calls made so far match
+func (s *ChaosStorage) CountCalls(match Match) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	count := 0
+	for _, call := range s.calls {
+		if match.matches(call) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *ChaosStorage) Create(ctx context.Context, key string, obj runtime.Object) error {
+	return s.do(ctx, OpCreate, key, func() error { return s.inner.Create(ctx, key, obj) })
+}
+
+func (s *ChaosStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
+	return s.do(ctx, OpGet, key, func() error { return s.inner.Get(ctx, key, obj) })
+}
+
+func (s *ChaosStorage) Exists(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := s.do(ctx, OpExists, key, func() error {
+		var err error
+		exists, err = s.inner.Exists(ctx, key)
+		return err
+	})
+	return exists, err
+}
+
+func (s *ChaosStorage) Update(ctx context.Context, key string, obj runtime.Object) error {
+	return s.do(ctx, OpUpdate, key, func() error { return s.inner.Update(ctx, key, obj) })
+}
+
+func (s *ChaosStorage) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, OpDelete, key, func() error { return s.inner.Delete(ctx, key) })
+}
+
+func (s *ChaosStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	return s.do(ctx, OpDeletePrefix, prefix, func() error { return s.inner.DeletePrefix(ctx, prefix) })
+}
+
+func (s *ChaosStorage) List(ctx context.Context, prefix string, listObj interface{}) error {
+	return s.do(ctx, OpList, prefix, func() error { return s.inner.List(ctx, prefix, listObj) })
+}
+
+func (s *ChaosStorage) ListFunc(ctx context.Context, prefix string, newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+	return s.do(ctx, OpList, prefix, func() error { return s.inner.ListFunc(ctx, prefix, newItem, appendItem) })
+}
+
+// ListPage is recorded as OpList
+func (s *ChaosStorage) ListPage(ctx context.Context, prefix string, options ListOptions, listObj interface{}) (string, error) {
+	var next string
+	err := s.do(ctx, OpList, prefix, func() error {
+		var err error
+		next, err = s.inner.ListPage(ctx, prefix, options, listObj)
+		return err
+	})
+	return next, err
+}
+
+// ListPageFunc is recorded as OpList
+func (s *ChaosStorage) ListPageFunc(ctx context.Context, prefix string, options ListOptions, newItem func() runtime.Object, appendItem func(runtime.Object)) (ListResult, error) {
+	var result ListResult
+	err := s.do(ctx, OpList, prefix, func() error {
+		var err error
+		result, err = s.inner.ListPageFunc(ctx, prefix, options, newItem, appendItem)
+		return err
+	})
+	return result, err
+}
+
+// ListKeys is recorded as OpList, as it lists the keys of a prefix
+func (s *ChaosStorage) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := s.do(ctx, OpList, prefix, func() error {
+		var err error
+		keys, err = s.inner.ListKeys(ctx, prefix)
+		return err
+	})
+	return keys, err
+}
+
+func (s *ChaosStorage) Count(ctx context.Context, prefix string) (int64, error) {
+	var count int64
+	err := s.do(ctx, OpCount, prefix, func() error {
+		var err error
+		count, err = s.inner.Count(ctx, prefix)
+		return err
+	})
+	return count, err
+}
+
+func (s *ChaosStorage) Watch(ctx context.Context, prefix string, newItem func() runtime.Object) (<-chan ObjectEvent, error) {
+	var events <-chan ObjectEvent
+	err := s.do(ctx, OpWatch, prefix, func() error {
+		var err error
+		events, err = s.inner.Watch(ctx, prefix, newItem)
+		return err
+	})
+	return events, err
+}
+
+// WatchFrom is recorded as OpWatch
+func (s *ChaosStorage) WatchFrom(ctx context.Context, prefix string, revision int64, newItem func() runtime.Object) (<-chan ObjectEvent, error) {
+	var events <-chan ObjectEvent
+	err := s.do(ctx, OpWatch, prefix, func() error {
+		var err error
+		events, err = s.inner.WatchFrom(ctx, prefix, revision, newItem)
+		return err
+	})
+	return events, err
+}
+
+// Txn is recorded as a call per write, with the operation and key of the write, so policies fail the writes
+// they would fail outside a transaction. The policy is asked about every write; the latencies it injects add
+// up, and the first error it injects fails the whole transaction without reaching the inner storage.
+func (s *ChaosStorage) Txn(ctx context.Context, ops []TxnOp) error {
+	s.mutex.Lock()
+	faults := make([]Fault, len(ops))
+	var fault Fault
+	for i, op := range ops {
+		if s.policy != nil {
+			faults[i] = s.policy(Call{Op: op.Op, Key: op.Key})
+		}
+		fault.Latency += faults[i].Latency
+		if fault.Err == nil {
+			fault.Err = faults[i].Err
+		}
+	}
+	s.mutex.Unlock()
+
+	err := s.inject(ctx, fault)
+	injected := err != nil
+	if !injected {
+		err = s.inner.Txn(ctx, ops)
+	}
+
+	s.mutex.Lock()
+	for i, op := range ops {
+		s.calls = append(s.calls, Call{Op: op.Op, Key: op.Key, Err: err, Injected: injected && faults[i].Err != nil})
+	}
+	s.mutex.Unlock()
+	return err
+}
+
+// CreateWithTTL is recorded as OpCreate. It fails with ErrTTLUnsupported if the inner storage cannot expire
+// keys.
+func (s *ChaosStorage) CreateWithTTL(ctx context.Context, key string, obj runtime.Object, ttlSeconds int64) (LeaseID, error) {
+	var id LeaseID
+	err := s.do(ctx, OpCreate, key, func() error {
+		inner, ok := s.inner.(TTLStorage)
+		if !ok {
+			return ErrTTLUnsupported
+		}
+		var err error
+		id, err = inner.CreateWithTTL(ctx, key, obj, ttlSeconds)
+		return err
+	})
+	return id, err
+}
+
+// KeepAlive is recorded as OpKeepAlive, without a key. It fails with ErrTTLUnsupported if the inner storage
+// cannot expire keys.
+func (s *ChaosStorage) KeepAlive(ctx context.Context, id LeaseID) error {
+	return s.do(ctx, OpKeepAlive, "", func() error {
+		inner, ok := s.inner.(TTLStorage)
+		if !ok {
+			return ErrTTLUnsupported
+		}
+		return inner.KeepAlive(ctx, id)
+	})
+}
+
+// do applies the fault of the policy to a call, passing it on to the inner storage unless it fails
+func (s *ChaosStorage) do(ctx context.Context, op Operation, key string, call func() error) error {
+	s.mutex.Lock()
+	var fault Fault
+	if s.policy != nil {
+		fault = s.policy(Call{Op: op, Key: key})
+	}
+	s.mutex.Unlock()
+
+	err := s.inject(ctx, fault)
+	injected := err != nil
+	if !injected {
+		err = call()
+	}
+
+	s.mutex.Lock()
+	s.calls = append(s.calls, Call{Op: op, Key: key, Err: err, Injected: injected})
+	s.mutex.Unlock()
+	return err
+}
+
+func (s *ChaosStorage) inject(ctx context.Context, fault Fault) error {
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrEtcdClient, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	if fault.Err != nil {
+		return fmt.Errorf("%w (injected)", fault.Err)
+	}
+	return nil
+}
+
+var _ Storage = &ChaosStorage{}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestChaosStorage_FailNth(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewChaosStorage(NewEtcdStorage(cli), FailNth(Match{Op: OpUpdate, Prefix: "/pods/"}, 3, ErrConflict))
+		ctx := context.Background()
+
+		require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "v0"}))
+		require.NoError(t, storage.Update(ctx, "/nodes/node-1", &TestObject{Name: "other prefix"}))
+		require.NoError(t, storage.Update(ctx, "/pods/web", &TestObject{Name: "v1"}))
+		require.NoError(t, storage.Update(ctx, "/pods/web", &TestObject{Name: "v2"}))
+		err := storage.Update(ctx, "/pods/web", &TestObject{Name: "v3"})
+		assert.ErrorIs(t, err, ErrConflict)
+		require.NoError(t, storage.Update(ctx, "/pods/web", &TestObject{Name: "v4"}))
+
+		var stored TestObject
+		require.NoError(t, storage.Get(ctx, "/pods/web", &stored))
+		assert.Equal(t, "v4", stored.Name, "the failed update did not reach etcd")
+
+		assert.Equal(t, 4, storage.CountCalls(Match{Op: OpUpdate, Prefix: "/pods/"}))
+		calls := storage.Calls()
+		require.Len(t, calls, 7)
+		assert.Equal(t, Call{Op: OpUpdate, Key: "/pods/web", Err: err, Injected: true}, calls[4])
+		assert.False(t, calls[5].Injected)
+	})
+}
+
+func TestChaosStorage_Script(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewChaosStorage(NewEtcdStorage(cli), Script(Match{Op: OpList},
+			Fault{Err: ErrEtcdClient}, Fault{}, Fault{Err: ErrEtcdClient}))
+		ctx := context.Background()
+
+		var objects []*TestObject
+		assert.ErrorIs(t, storage.List(ctx, "/pods/", &objects), ErrEtcdClient)
+		assert.NoError(t, storage.List(ctx, "/pods/", &objects))
+		assert.ErrorIs(t, storage.List(ctx, "/pods/", &objects), ErrEtcdClient)
+		assert.NoError(t, storage.List(ctx, "/pods/", &objects), "the script is over")
+
+		storage.SetPolicy(FailRandomly(Match{}, 1, ErrEtcdClient, 1))
+		assert.ErrorIs(t, storage.Delete(ctx, "/pods/web"), ErrEtcdClient)
+		storage.SetPolicy(nil)
+		assert.ErrorIs(t, storage.Delete(ctx, "/pods/web"), ErrNotFound, "the delete reaches the storage")
+	})
+}
+
+func TestChaosStorage_Delay(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewChaosStorage(NewEtcdStorage(cli), Combine(
+			Delay(Match{Op: OpGet}, 50*time.Millisecond),
+			FailNth(Match{Op: OpGet}, 1, ErrEtcdClient)))
+
+		started := time.Now()
+		var object TestObject
+		assert.ErrorIs(t, storage.Get(context.Background(), "/pods/web", &object), ErrEtcdClient)
+		assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, storage.Get(ctx, "/pods/web", &object), ErrEtcdClient, "a delayed call gives up with its context")
+	})
+}
+
+func TestChaosStorage_Txn(t *testing.T) {
+	storage := NewChaosStorage(NewMemoryStorage(), FailNth(Match{Op: OpCreate, Prefix: "/pods/"}, 2, ErrEtcdClient))
+	ctx := context.Background()
+
+	ops := []TxnOp{CreateOp("/pods/a", &TestObject{Name: "a"}), CreateOp("/pods/b", &TestObject{Name: "b"}), DeleteOp("/nodes/node-1")}
+	err := storage.Txn(ctx, ops)
+	assert.ErrorIs(t, err, ErrEtcdClient, "a fault of one write fails the transaction")
+	count, err := storage.Count(ctx, "/pods/")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	require.NoError(t, storage.Txn(ctx, ops))
+
+	calls := storage.Calls()
+	require.Len(t, calls, 7)
+	assert.Equal(t, []bool{false, true, false}, []bool{calls[0].Injected, calls[1].Injected, calls[2].Injected})
+	assert.Equal(t, Call{Op: OpDelete, Key: "/nodes/node-1"}, calls[6])
+	assert.Equal(t, 4, storage.CountCalls(Match{Op: OpCreate, Prefix: "/pods/"}))
+}
+
+func TestFailRandomly_IsReproducible(t *testing.T) {
+	outcomes := func() []bool {
+		policy := FailRandomly(Match{}, 0.5, ErrEtcdClient, 42)
+		var failed []bool
+		for range 20 {
+			failed = append(failed, policy(Call{Op: OpGet, Key: "/pods/web"}).Err != nil)
+		}
+		return failed
+	}
+	first := outcomes()
+	assert.Equal(t, first, outcomes())
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultDialTimeout is how long NewEtcdClient waits for etcd when EtcdConfig leaves it unset
+const DefaultDialTimeout = 5 * time.Second
+
+// ErrInvalidEtcdConfig is returned for an EtcdConfig that cannot make a client
+var ErrInvalidEtcdConfig = fmt.Errorf("invalid etcd config")
+
+// EtcdConfig is how to reach and authenticate to etcd. Without certificates the connection is plaintext, and
+// without a username it is unauthenticated, as with the embedded etcd.
+type EtcdConfig struct {
+	// Endpoints are the etcd servers, as host:port or URLs
+	Endpoints []string
+	// DialTimeout bounds connecting to etcd; 0 means DefaultDialTimeout
+	DialTimeout time.Duration
+	// CertFile and KeyFile are the client certificate and its key, for etcd requiring client certificates
+	CertFile string
+	KeyFile  string
+	// CAFile verifies the certificate of etcd, instead of the CAs of the system. Setting it or the client
+	// certificate makes the connection TLS.
+	CAFile string
+	// Username and Password authenticate to etcd with auth enabled
+	Username string
+	Password string
+}
+
+// AddFlags registers --etcd-servers, --etcd-cert, --etcd-key, --etcd-ca, --etcd-username and --etcd-password,
+// defaulting to the current config. What no --etcd-servers means is left to the command, which knows where its
+// etcd runs by default.
+func (c *EtcdConfig) AddFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(&c.Endpoints, "etcd-servers", c.Endpoints, "The etcd servers to connect to, as host:port or URLs")
+	flags.StringVar(&c.CertFile, "etcd-cert", c.CertFile, "The client certificate to connect to etcd with")
+	flags.StringVar(&c.KeyFile, "etcd-key", c.KeyFile, "The key of --etcd-cert")
+	flags.StringVar(&c.CAFile, "etcd-ca", c.CAFile, "The CA to verify the certificate of etcd with, which makes the connection TLS")
+	flags.StringVar(&c.Username, "etcd-username", c.Username, "The user to authenticate to etcd as")
+	flags.StringVar(&c.Password, "etcd-password", c.Password, "The password of --etcd-username")
+}
+
+// Validate checks that the config names endpoints, and a certificate with its key and a user with a password
+func (c EtcdConfig) Validate() error {
+	switch {
+	case len(c.Endpoints) == 0:
+		return fmt.Errorf("%w: no endpoints", ErrInvalidEtcdConfig)
+	case (c.CertFile == "") != (c.KeyFile == ""):
+		return fmt.Errorf("%w: a client certificate needs both --etcd-cert and --etcd-key", ErrInvalidEtcdConfig)
+	case c.Password != "" && c.Username == "":
+		return fmt.Errorf("%w: --etcd-password without --etcd-username", ErrInvalidEtcdConfig)
+	}
+	return nil
+}
+
+// usesTLS reports whether the connection is TLS
+func (c EtcdConfig) usesTLS() bool {
+	return c.CertFile != "" || c.CAFile != ""
+}
+
+// NewEtcdClient connects to the etcd of config. Endpoints given as host:port get the scheme of the connection,
+// https with TLS and http without.
+func NewEtcdClient(config EtcdConfig) (*clientv3.Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	scheme := "http://"
+	if config.usesTLS() {
+		var err error
+		tlsInfo := transport.TLSInfo{CertFile: config.CertFile, KeyFile: config.KeyFile, TrustedCAFile: config.CAFile}
+		if tlsConfig, err = tlsInfo.ClientConfig(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEtcdConfig, err)
+		}
+		scheme = "https://"
+	}
+	endpoints := make([]string, 0, len(config.Endpoints))
+	for _, endpoint := range config.Endpoints {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = scheme + endpoint
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	dialTimeout := config.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: dialTimeout,
+		TLS:         tlsConfig,
+		Username:    config.Username,
+		Password:    config.Password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	return cli, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// startTestEtcd starts an embedded etcd of the test's own with options and returns its client endpoint
+func startTestEtcd(t *testing.T, options EmbeddedEtcdOptions) string {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o700))
+	options.Dir, options.Logger = dir, newTestLogger(t)
+	etcdServer, port, err := StartEmbeddedEtcdWithOptions(options)
+	require.NoError(t, err)
+	t.Cleanup(func() { StopEmbeddedEtcd(etcdServer) })
+	return fmt.Sprintf("127.0.0.1:%d", port)
+}
+
+// newTestClient connects with config, to be closed when the test is over
+func newTestClient(t *testing.T, config EtcdConfig) *clientv3.Client {
+	cli, err := NewEtcdClient(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cli.Close() })
+	return cli
+}
+
+func TestNewEtcdClient_TLS(t *testing.T) {
+	certs, err := GenerateSelfSignedCerts(t.TempDir())
+	require.NoError(t, err)
+	endpoint := startTestEtcd(t, EmbeddedEtcdOptions{Certs: certs})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cli := newTestClient(t, EtcdConfig{
+		Endpoints: []string{endpoint},
+		CertFile:  certs.ClientCertFile,
+		KeyFile:   certs.ClientKeyFile,
+		CAFile:    certs.CAFile,
+	})
+	storage := NewEtcdStorage(cli)
+	require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+	var obj TestObject
+	require.NoError(t, storage.Get(ctx, "/pods/web", &obj))
+	assert.Equal(t, "web", obj.Name)
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, time.Second)
+	defer shortCancel()
+	withoutCert := newTestClient(t, EtcdConfig{Endpoints: []string{endpoint}, CAFile: certs.CAFile})
+	assert.Error(t, NewEtcdStorage(withoutCert).Get(shortCtx, "/pods/web", &obj), "etcd requires a client certificate")
+}
+
+func TestNewEtcdClient_Auth(t *testing.T) {
+	endpoint := startTestEtcd(t, EmbeddedEtcdOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admin := newTestClient(t, EtcdConfig{Endpoints: []string{endpoint}})
+	_, err := admin.UserAdd(ctx, "root", "secret")
+	require.NoError(t, err)
+	_, err = admin.UserGrantRole(ctx, "root", "root")
+	require.NoError(t, err)
+	_, err = admin.AuthEnable(ctx)
+	require.NoError(t, err)
+
+	cli := newTestClient(t, EtcdConfig{Endpoints: []string{endpoint}, Username: "root", Password: "secret"})
+	require.NoError(t, NewEtcdStorage(cli).Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+
+	anonymous := newTestClient(t, EtcdConfig{Endpoints: []string{endpoint}})
+	err = NewEtcdStorage(anonymous).Create(ctx, "/pods/db", &TestObject{Name: "db"})
+	assert.ErrorIs(t, err, ErrEtcdClient, "etcd rejects the requests of unauthenticated clients")
+
+	_, err = NewEtcdClient(EtcdConfig{Endpoints: []string{endpoint}, Username: "root", Password: "wrong"})
+	assert.Error(t, err)
+}
+
+func TestEtcdConfig_Validate(t *testing.T) {
+	endpoints := []string{"localhost:2379"}
+	assert.NoError(t, EtcdConfig{Endpoints: endpoints}.Validate())
+	assert.NoError(t, EtcdConfig{Endpoints: endpoints, CAFile: "ca.crt", Username: "root"}.Validate())
+	assert.ErrorIs(t, EtcdConfig{}.Validate(), ErrInvalidEtcdConfig)
+	assert.ErrorIs(t, EtcdConfig{Endpoints: endpoints, CertFile: "client.crt"}.Validate(), ErrInvalidEtcdConfig)
+	assert.ErrorIs(t, EtcdConfig{Endpoints: endpoints, Password: "secret"}.Validate(), ErrInvalidEtcdConfig)
+
+	_, err := NewEtcdClient(EtcdConfig{Endpoints: endpoints, CAFile: "/missing/ca.crt"})
+	assert.ErrorIs(t, err, ErrInvalidEtcdConfig)
+}
@@ -6,43 +6,75 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"go.etcd.io/etcd/client/pkg/v3/transport"
 	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
 )
 
+// EmbeddedEtcdOptions configures an embedded etcd. The zero value listens on free ports, keeps the data in a
+// new temporary directory and logs to stderr.
+type EmbeddedEtcdOptions struct {
+	// PeerPort and ClientPort are the ports to listen on; zero picks a free one
+	PeerPort   int
+	ClientPort int
+	// Dir is the data directory, created if missing; "" keeps the data in a new temporary directory. It is
+	// removed when the server stops, unless PreserveData is set.
+	Dir string
+	// PreserveData keeps Dir when the server stops, so a server started on it again finds the data. A temporary
+	// directory is always removed.
+	PreserveData bool
+	// Logger receives the logs of etcd instead of stderr
+	Logger *zap.Logger
+	// Certs makes etcd serve its clients over TLS with the server certificate, accepting only clients with a
+	// certificate of the CA, such as those of GenerateSelfSignedCerts; nil serves plaintext
+	Certs *EtcdCerts
+}
+
+// preserved holds the servers whose data directory StopEmbeddedEtcd keeps
+var preserved sync.Map
+
 func StartEmbeddedEtcd() (*embed.Etcd, int, error) {
-	return StartEmbeddedEtcdWithPort(0, 0)
+	return StartEmbeddedEtcdWithOptions(EmbeddedEtcdOptions{})
 }
 
 func StartEmbeddedEtcdWithPort(peerPort, clientPort int) (*embed.Etcd, int, error) {
-	cfg := embed.NewConfig()
-	dir, err := createTempDir()
-	if err != nil {
-		return nil, 0, err
-	}
-
-	cfg.Dir = dir
+	return StartEmbeddedEtcdWithOptions(EmbeddedEtcdOptions{PeerPort: peerPort, ClientPort: clientPort})
+}
 
-	if peerPort == 0 {
-		peerPort, err = PickAvailableRandomPort()
+// StartEmbeddedEtcdWithOptions starts an embedded etcd and waits until it is ready, returning the client port.
+// Free ports are picked by listening on port 0, so servers started side by side never race for a port.
+func StartEmbeddedEtcdWithOptions(options EmbeddedEtcdOptions) (*embed.Etcd, int, error) {
+	cfg := embed.NewConfig()
+	cfg.Dir = options.Dir
+	if cfg.Dir == "" {
+		dir, err := createTempDir()
 		if err != nil {
 			return nil, 0, err
 		}
+		cfg.Dir = dir
+		options.PreserveData = false
 	}
 
-	cfg.ListenPeerUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", peerPort)}}
-
-	if clientPort == 0 {
-		clientPort, err = PickAvailableRandomPort()
-		if err != nil {
-			return nil, 0, err
+	clientScheme := "http"
+	if options.Certs != nil {
+		clientScheme = "https"
+		cfg.ClientTLSInfo = transport.TLSInfo{
+			CertFile:       options.Certs.ServerCertFile,
+			KeyFile:        options.Certs.ServerKeyFile,
+			TrustedCAFile:  options.Certs.CAFile,
+			ClientCertAuth: true,
 		}
 	}
-
-	cfg.ListenClientUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", clientPort)}}
+	cfg.ListenPeerUrls = []url.URL{{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", options.PeerPort)}}
+	cfg.ListenClientUrls = []url.URL{{Scheme: clientScheme, Host: fmt.Sprintf("127.0.0.1:%d", options.ClientPort)}}
 	cfg.Logger = "zap"
 	cfg.LogOutputs = []string{"stderr"}
+	if options.Logger != nil {
+		cfg.ZapLoggerBuilder = embed.NewZapLoggerBuilder(options.Logger)
+	}
 
 	e, err := embed.StartEtcd(cfg)
 	if err != nil {
@@ -51,12 +83,17 @@ func StartEmbeddedEtcdWithPort(peerPort, clientPort int) (*embed.Etcd, int, erro
 
 	select {
 	case <-e.Server.ReadyNotify():
-		fmt.Printf("Embedded etcd is ready with peer port %d and client port %d!\n", peerPort, clientPort)
 	case <-time.After(10 * time.Second):
-		e.Server.Stop() // trigger a shutdown
+		e.Close() // trigger a shutdown
 		return nil, 0, fmt.Errorf("server took too long to start")
 	}
 
+	if options.PreserveData {
+		preserved.Store(e, struct{}{})
+	}
+	peerPort := e.Peers[0].Listener.Addr().(*net.TCPAddr).Port
+	clientPort := e.Clients[0].Addr().(*net.TCPAddr).Port
+	e.GetLogger().Info("embedded etcd is ready", zap.Int("peer-port", peerPort), zap.Int("client-port", clientPort), zap.String("data-dir", cfg.Dir))
 	return e, clientPort, nil
 }
 
@@ -74,11 +111,16 @@ func PickAvailableRandomPort() (int, error) {
 	return port, nil
 }
 
-// StopEmbeddedEtcd stops the embedded etcd server and removes the data directory.
+// StopEmbeddedEtcd stops the embedded etcd server and removes the data directory, unless it was started with
+// PreserveData.
 func StopEmbeddedEtcd(e *embed.Etcd) {
 	e.Close()
+	if _, ok := preserved.LoadAndDelete(e); ok {
+		e.GetLogger().Info("embedded etcd stopped and data directory kept", zap.String("data-dir", e.Config().Dir))
+		return
+	}
 	_ = os.RemoveAll(e.Config().Dir)
-	fmt.Println("Embedded etcd server stopped and data directory removed")
+	e.GetLogger().Info("embedded etcd stopped and data directory removed")
 }
 
 func createTempDir() (string, error) {
@@ -3,21 +3,66 @@ package storage
 import (
 	"context"
 	"fmt"
-	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"gokube/pkg/runtime"
 
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/namespace"
 )
 
 // EtcdStorage implements the Storage interface using etcd
 type EtcdStorage struct {
 	client *clientv3.Client
+	// kv sends the key-value requests, those of client unless they are retried
+	kv clientv3.KV
+	// metrics observes the requests, if set
+	metrics StorageMetrics
+	// codec encodes the stored values
+	codec runtime.Codec
+	// root is prepended to every key in etcd, "" for none
+	root string
+	// changes is the etcd watch the watches of the storage follow
+	changes *changeFeed
 }
 
-// NewEtcdStorage creates a new EtcdStorage
+// NewEtcdStorage creates a new EtcdStorage storing JSON, whose requests fail as soon as etcd fails them
 func NewEtcdStorage(client *clientv3.Client) *EtcdStorage {
-	return &EtcdStorage{client: client}
+	return &EtcdStorage{client: client, kv: client.KV, codec: runtime.JSONCodec, changes: newChangeFeed()}
+}
+
+// NewEtcdStorageWithCodec creates an EtcdStorage storing the values it writes with codec. Values stored as
+// JSON before are still read, so a storage can switch to another codec, such as runtime.CompressedJSONCodec,
+// and the values are converted as they are written again.
+func NewEtcdStorageWithCodec(client *clientv3.Client, codec runtime.Codec) *EtcdStorage {
+	return &EtcdStorage{client: client, kv: client.KV, codec: codec, changes: newChangeFeed()}
+}
+
+// SetRootPrefix keeps the keys of the storage under root in etcd, e.g. ClusterPrefix("dev"), so storages with
+// different roots share an etcd without seeing each other's keys. The root is prepended to the keys the storage
+// is given and stripped from the ones it hands back, such as those of watch events, so callers never see it. It
+// is set once, before the storage is used.
+func (s *EtcdStorage) SetRootPrefix(root string) {
+	s.root = root
+	if root != "" {
+		s.kv = namespace.NewKV(s.kv, root)
+	}
+}
+
+// ClusterPrefix returns the root prefix of the keys of the named cluster, /gokube/<name>, and "" for the
+// unnamed cluster, whose keys are at the root of etcd as they always were. A name must not contain a slash,
+// so the keys of one cluster are never under those of another.
+func ClusterPrefix(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidClusterName, name)
+	}
+	if name == "" {
+		return "", nil
+	}
+	return "/gokube/" + name, nil
 }
 
 var (
@@ -25,23 +70,44 @@ var (
 	ErrDecoding   = fmt.Errorf("error decoding object")
 	ErrNotFound   = fmt.Errorf("object not found")
 	ErrEtcdClient = fmt.Errorf("etcd client error")
+	// ErrKeyExists is returned by Create when an object is already stored under the key
+	ErrKeyExists = fmt.Errorf("object already exists")
+	// ErrConflict is returned by Update when the object was changed since the resource version it carries
+	ErrConflict = fmt.Errorf("object was modified concurrently")
+	// ErrInvalidListObject is returned by List for anything but a non-nil pointer to a slice of pointers
+	ErrInvalidListObject = fmt.Errorf("list object must be a pointer to a slice of pointers")
+	// ErrInvalidContinue is returned by ListPage for a continue token that was not returned for the prefix
+	ErrInvalidContinue = fmt.Errorf("invalid continue token")
+	// ErrInvalidClusterName is returned by ClusterPrefix for a name that cannot be part of a key prefix
+	ErrInvalidClusterName = fmt.Errorf("invalid cluster name")
 )
 
-func (s *EtcdStorage) Create(ctx context.Context, key string, obj runtime.Object) error {
-	data, err := runtime.Encode(obj)
+// Create stores obj under key if nothing is stored there yet, and fails with ErrKeyExists otherwise. The check
+// and the write are one etcd transaction, so of concurrent creates of a key exactly one succeeds.
+func (s *EtcdStorage) Create(ctx context.Context, key string, obj runtime.Object) (err error) {
+	defer s.observe(OpCreate, key, time.Now(), &err)
+	data, err := s.codec.Encode(obj)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEncoding, err)
 	}
 
-	_, err = s.client.Put(ctx, key, string(data))
+	resp, err := s.kv.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(data))).
+		Commit()
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: %s", ErrKeyExists, key)
+	}
+	setResourceVersion(obj, resp.Header.Revision)
 	return nil
 }
 
-func (s *EtcdStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
-	resp, err := s.client.Get(ctx, key)
+func (s *EtcdStorage) Get(ctx context.Context, key string, obj runtime.Object) (err error) {
+	defer s.observe(OpGet, key, time.Now(), &err)
+	resp, err := s.kv.Get(ctx, key)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
@@ -50,62 +116,291 @@ func (s *EtcdStorage) Get(ctx context.Context, key string, obj runtime.Object) e
 		return fmt.Errorf("%w: %s", ErrNotFound, key)
 	}
 
-	if err := runtime.Decode(resp.Kvs[0].Value, obj); err != nil {
-		return fmt.Errorf("%w: %v", ErrDecoding, err)
+	return s.decode(resp.Kvs[0], obj)
+}
+
+// Exists reports whether a value is stored under key. Only the count is asked of etcd, so the value is neither
+// sent nor decoded.
+func (s *EtcdStorage) Exists(ctx context.Context, key string) (exists bool, err error) {
+	defer s.observe(OpExists, key, time.Now(), &err)
+	resp, err := s.kv.Get(ctx, key, clientv3.WithCountOnly(), clientv3.WithKeysOnly())
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
-	return nil
+	return resp.Count > 0, nil
 }
 
-func (s *EtcdStorage) Update(ctx context.Context, key string, obj runtime.Object) error {
-	data, err := runtime.Encode(obj)
+// Update stores obj under key. An object with a resource version is only stored if the key was not changed
+// since that version was read, otherwise the update fails with ErrConflict, or ErrNotFound if the key was
+// deleted. An object without one overwrites whatever is stored.
+func (s *EtcdStorage) Update(ctx context.Context, key string, obj runtime.Object) (err error) {
+	defer s.observe(OpUpdate, key, time.Now(), &err)
+	data, err := s.codec.Encode(obj)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEncoding, err)
 	}
 
-	if _, err = s.client.Put(ctx, key, string(data)); err != nil {
+	version := resourceVersion(obj)
+	if version == "" {
+		resp, err := s.kv.Put(ctx, key, string(data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		setResourceVersion(obj, resp.Header.Revision)
+		return nil
+	}
+
+	revision, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s has invalid resource version %q", ErrConflict, key, version)
+	}
+	resp, err := s.kv.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(data))).
+		Else(clientv3.OpGet(key, clientv3.WithKeysOnly())).
+		Commit()
+	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
+	if !resp.Succeeded {
+		if len(resp.Responses[0].GetResponseRange().Kvs) == 0 {
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return fmt.Errorf("%w: %s was changed since version %s", ErrConflict, key, version)
+	}
+	setResourceVersion(obj, resp.Header.Revision)
 	return nil
 }
 
-func (s *EtcdStorage) Delete(ctx context.Context, key string) error {
-	if _, err := s.client.Delete(ctx, key); err != nil {
-		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+// Txn sends ops as one etcd transaction, comparing the create revision of the keys created and the mod revision
+// of the keys updated with a resource version. When a compare fails, the transaction reads those keys instead
+// of writing, to tell which one failed it.
+func (s *EtcdStorage) Txn(ctx context.Context, ops []TxnOp) (err error) {
+	defer s.observe(OpTxn, "", time.Now(), &err)
+	if err := checkTxn(ops); err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	var cmps []clientv3.Cmp
+	var thens, elses []clientv3.Op
+	var compared []TxnOp
+	for _, op := range ops {
+		if op.Op == OpDelete {
+			thens = append(thens, clientv3.OpDelete(op.Key))
+			var cmp clientv3.Cmp
+			if version := resourceVersion(op.Object); version != "" {
+				revision, err := strconv.ParseInt(version, 10, 64)
+				if err != nil {
+					return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s has invalid resource version %q", ErrConflict, op.Key, version)}
+				}
+				cmp = clientv3.Compare(clientv3.ModRevision(op.Key), "=", revision)
+			} else if op.MustExist {
+				cmp = clientv3.Compare(clientv3.CreateRevision(op.Key), "!=", 0)
+			} else {
+				continue
+			}
+			cmps = append(cmps, cmp)
+			elses = append(elses, clientv3.OpGet(op.Key, clientv3.WithKeysOnly()))
+			compared = append(compared, op)
+			continue
+		}
+		data, err := s.codec.Encode(op.Object)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEncoding, err)
+		}
+		thens = append(thens, clientv3.OpPut(op.Key, string(data)))
+
+		var cmp clientv3.Cmp
+		if op.Op == OpCreate {
+			cmp = clientv3.Compare(clientv3.CreateRevision(op.Key), "=", 0)
+		} else if version := resourceVersion(op.Object); version != "" {
+			revision, err := strconv.ParseInt(version, 10, 64)
+			if err != nil {
+				return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s has invalid resource version %q", ErrConflict, op.Key, version)}
+			}
+			cmp = clientv3.Compare(clientv3.ModRevision(op.Key), "=", revision)
+		} else if op.MustExist {
+			cmp = clientv3.Compare(clientv3.CreateRevision(op.Key), "!=", 0)
+		} else {
+			continue
+		}
+		cmps = append(cmps, cmp)
+		elses = append(elses, clientv3.OpGet(op.Key, clientv3.WithKeysOnly()))
+		compared = append(compared, op)
 	}
 
+	resp, err := s.kv.Txn(ctx).If(cmps...).Then(thens...).Else(elses...).Commit()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	if !resp.Succeeded {
+		for i, op := range compared {
+			var modRevision int64
+			kvs := resp.Responses[i].GetResponseRange().Kvs
+			if len(kvs) > 0 {
+				modRevision = kvs[0].ModRevision
+			}
+			if err := txnCondition(op, len(kvs) > 0, modRevision); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%w: transaction failed", ErrConflict)
+	}
+	for _, op := range ops {
+		if op.Object != nil {
+			setResourceVersion(op.Object, resp.Header.Revision)
+		}
+	}
 	return nil
 }
 
-func (s *EtcdStorage) List(ctx context.Context, prefix string, listObj interface{}) error {
-	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix())
+// Delete deletes key, failing with ErrNotFound if there is nothing to delete
+func (s *EtcdStorage) Delete(ctx context.Context, key string) (err error) {
+	defer s.observe(OpDelete, key, time.Now(), &err)
+	resp, err := s.kv.Delete(ctx, key)
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
-
-	listValue := reflect.ValueOf(listObj)
-	if listValue.Kind() != reflect.Ptr || listValue.Elem().Kind() != reflect.Slice {
-		return fmt.Errorf("listObj must be a pointer to a slice")
+	if resp.Deleted == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
 	}
 
-	sliceValue := listValue.Elem()
-	elementType := sliceValue.Type().Elem()
+	return nil
+}
+
+// List decodes the values under prefix into listObj, which must be a pointer to a slice of pointers. It finds
+// the type of the items by reflection; ListFunc, or ListOf, avoid that.
+func (s *EtcdStorage) List(ctx context.Context, prefix string, listObj interface{}) error {
+	return listInto(listObj, func(newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+		return s.ListFunc(ctx, prefix, newItem, appendItem)
+	})
+}
+
+// ListFunc decodes each value under prefix, in key order, into an object returned by newItem and hands it to
+// appendItem. The values that fail to decode are skipped, and reported in a *CorruptValuesError.
+func (s *EtcdStorage) ListFunc(ctx context.Context, prefix string, newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+	_, err := s.ListPageFunc(ctx, prefix, ListOptions{}, newItem, appendItem)
+	return err
+}
+
+// ListPage decodes the page of the values under prefix that options select into listObj, which must be a
+// pointer to a slice of pointers
+func (s *EtcdStorage) ListPage(ctx context.Context, prefix string, options ListOptions, listObj interface{}) (string, error) {
+	var result ListResult
+	err := listInto(listObj, func(newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+		var err error
+		result, err = s.ListPageFunc(ctx, prefix, options, newItem, appendItem)
+		return err
+	})
+	return result.Continue, err
+}
+
+// ListPageFunc decodes the values under prefix, in key order, from the key the continue token of options
+// names, and up to its limit. Only the page is read from etcd. Each page reflects the values when it is read,
+// so values changed between pages may be missed or seen twice. The values that fail to decode are skipped, and
+// reported in a *CorruptValuesError once the others were handed over, unless options are strict. The revision
+// of the result is that of the etcd response.
+func (s *EtcdStorage) ListPageFunc(ctx context.Context, prefix string, options ListOptions, newItem func() runtime.Object, appendItem func(runtime.Object)) (result ListResult, err error) {
+	defer s.observe(OpList, prefix, time.Now(), &err)
+	start, err := decodeContinue(prefix, options.Continue)
+	if err != nil {
+		return ListResult{}, err
+	}
+	resp, err := s.kv.Get(ctx, start, clientv3.WithRange(clientv3.GetPrefixRangeEnd(prefix)), clientv3.WithLimit(options.Limit))
+	if err != nil {
+		return ListResult{}, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	result.Revision = resp.Header.Revision
 
+	objects := make([]runtime.Object, 0, len(resp.Kvs))
+	var corrupt corruptValues
 	for _, kv := range resp.Kvs {
-		obj := reflect.New(elementType.Elem()).Interface().(runtime.Object)
-		if err := runtime.Decode(kv.Value, obj); err != nil {
-			return fmt.Errorf("%w: %v", ErrDecoding, err)
+		obj := newItem()
+		if err := s.decode(kv, obj); err != nil {
+			if err := corrupt.skip(options, string(kv.Key), err); err != nil {
+				return ListResult{}, err
+			}
+			continue
 		}
-		sliceValue = reflect.Append(sliceValue, reflect.ValueOf(obj))
+		objects = append(objects, obj)
+	}
+	for _, obj := range objects {
+		appendItem(obj)
+	}
+	if resp.More && len(resp.Kvs) > 0 {
+		// The next page starts right after the last key of this one
+		result.Continue = encodeContinue(string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00")
+	}
+	return result, corrupt.err()
+}
+
+func (s *EtcdStorage) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	defer s.observe(OpDeletePrefix, prefix, time.Now(), &err)
+	if _, err := s.kv.Delete(ctx, prefix, clientv3.WithPrefix()); err != nil {
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
 	}
 
-	listValue.Elem().Set(sliceValue)
 	return nil
 }
 
-func (s *EtcdStorage) DeletePrefix(ctx context.Context, prefix string) error {
-	if _, err := s.client.Delete(ctx, prefix, clientv3.WithPrefix()); err != nil {
-		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+// Count returns how many values are stored under prefix. etcd counts them itself, so no value is transferred.
+func (s *EtcdStorage) Count(ctx context.Context, prefix string) (count int64, err error) {
+	defer s.observe(OpCount, prefix, time.Now(), &err)
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	return resp.Count, nil
+}
+
+// ListKeys returns the keys under prefix in key order. Only the keys are sent by etcd.
+func (s *EtcdStorage) ListKeys(ctx context.Context, prefix string) (keys []string, err error) {
+	defer s.observe(OpList, prefix, time.Now(), &err)
+	resp, err := s.kv.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	keys = make([]string, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		keys[i] = string(kv.Key)
 	}
+	return keys, nil
+}
+
+// decode decodes the value of kv into obj, with the revision that last changed it as its resource version
+func (s *EtcdStorage) decode(kv *mvccpb.KeyValue, obj runtime.Object) error {
+	return s.decodeValue(kv.Value, kv.ModRevision, obj)
+}
 
+// decodeValue decodes data into obj, with revision as its resource version
+func (s *EtcdStorage) decodeValue(data []byte, revision int64, obj runtime.Object) error {
+	codec := s.codec
+	// A value starting with { is JSON, stored before the codec was set; CompressedJSONCodec values never start
+	// with one
+	if len(data) > 0 && data[0] == '{' {
+		codec = runtime.JSONCodec
+	}
+	if err := codec.Decode(data, obj); err != nil {
+		return fmt.Errorf("%w: %v", ErrDecoding, err)
+	}
+	setResourceVersion(obj, revision)
 	return nil
 }
+
+// resourceVersion returns the resource version of obj, "" if it carries none
+func resourceVersion(obj runtime.Object) string {
+	if versioned, ok := obj.(runtime.Versioned); ok {
+		return versioned.GetResourceVersion()
+	}
+	return ""
+}
+
+// setResourceVersion sets the resource version of obj to revision, if it carries one
+func setResourceVersion(obj runtime.Object, revision int64) {
+	if versioned, ok := obj.(runtime.Versioned); ok {
+		versioned.SetResourceVersion(strconv.FormatInt(revision, 10))
+	}
+}
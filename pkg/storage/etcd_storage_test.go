@@ -2,12 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/runtime"
 )
 
 type TestObject struct {
@@ -29,6 +35,11 @@ func TestEtcdStorage_Create(t *testing.T) {
 		assert.NoError(t, err)
 
 		assert.Equal(t, "test-value", retrievedObj.Name)
+
+		err = storage.Create(ctx, "test-key", &TestObject{Name: "other-value"})
+		assert.ErrorIs(t, err, ErrKeyExists)
+		require.NoError(t, storage.Get(ctx, "test-key", &retrievedObj))
+		assert.Equal(t, "test-value", retrievedObj.Name, "the stored object is kept")
 	})
 }
 
@@ -54,6 +65,155 @@ func TestEtcdStorage_Update(t *testing.T) {
 	})
 }
 
+func TestEtcdStorage_ResourceVersion(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx := context.Background()
+
+		pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}}
+		require.NoError(t, storage.Create(ctx, "/pods/web", pod))
+		created := pod.ResourceVersion
+		require.NotEmpty(t, created)
+
+		var got api.Pod
+		require.NoError(t, storage.Get(ctx, "/pods/web", &got))
+		assert.Equal(t, created, got.ResourceVersion)
+
+		require.NoError(t, storage.Update(ctx, "/pods/web", pod))
+		assert.NotEqual(t, created, pod.ResourceVersion, "every write makes a new version")
+
+		pods, err := ListOf[api.Pod](ctx, storage, "/pods/")
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, pod.ResourceVersion, pods[0].ResourceVersion)
+	})
+}
+
+func TestEtcdStorage_UpdateConflict(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx := context.Background()
+
+		require.NoError(t, storage.Create(ctx, "/pods/web", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web"}}))
+		var first, second api.Pod
+		require.NoError(t, storage.Get(ctx, "/pods/web", &first))
+		require.NoError(t, storage.Get(ctx, "/pods/web", &second))
+
+		first.Status = api.PodRunning
+		require.NoError(t, storage.Update(ctx, "/pods/web", &first))
+		second.NodeName = "node-1"
+		err := storage.Update(ctx, "/pods/web", &second)
+		assert.ErrorIs(t, err, ErrConflict, "the second writer read a stale version")
+
+		var stored api.Pod
+		require.NoError(t, storage.Get(ctx, "/pods/web", &stored))
+		assert.Equal(t, api.PodRunning, stored.Status)
+		assert.Empty(t, stored.NodeName)
+
+		second.ResourceVersion = "not-a-revision"
+		assert.ErrorIs(t, storage.Update(ctx, "/pods/web", &second), ErrConflict)
+
+		second.ResourceVersion = ""
+		require.NoError(t, storage.Update(ctx, "/pods/web", &second), "an update without a version is unconditional")
+
+		require.NoError(t, storage.Delete(ctx, "/pods/web"))
+		assert.ErrorIs(t, storage.Update(ctx, "/pods/web", &first), ErrNotFound, "the object was deleted since it was read")
+	})
+}
+
+func TestEtcdStorage_Codec(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx := context.Background()
+		require.NoError(t, NewEtcdStorage(cli).Create(ctx, "/pods/legacy", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "legacy"}}))
+
+		storage := NewEtcdStorageWithCodec(cli, runtime.CompressedJSONCodec)
+		var pod api.Pod
+		require.NoError(t, storage.Get(ctx, "/pods/legacy", &pod), "values stored as JSON are still read")
+		assert.Equal(t, "legacy", pod.Name)
+
+		pod.Status = api.PodRunning
+		require.NoError(t, storage.Update(ctx, "/pods/legacy", &pod))
+		resp, err := cli.Get(ctx, "/pods/legacy")
+		require.NoError(t, err)
+		assert.NotEqual(t, byte('{'), resp.Kvs[0].Value[0], "written again with the codec")
+		assert.NotContains(t, string(resp.Kvs[0].Value), "status")
+
+		pods, err := ListOf[api.Pod](ctx, storage, "/pods/")
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, api.PodRunning, pods[0].Status)
+	})
+}
+
+func TestEtcdStorage_RootPrefix(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		newClusterStorage := func(name string) *EtcdStorage {
+			root, err := ClusterPrefix(name)
+			require.NoError(t, err)
+			storage := NewEtcdStorage(cli)
+			storage.SetRootPrefix(root)
+			return storage
+		}
+		dev, prod := newClusterStorage("dev"), newClusterStorage("prod")
+
+		require.NoError(t, dev.Create(ctx, "/pods/web", &TestObject{Name: "dev"}))
+		require.NoError(t, prod.Create(ctx, "/pods/web", &TestObject{Name: "prod"}), "the same key in another cluster")
+		require.NoError(t, dev.Create(ctx, "/pods/db", &TestObject{Name: "dev db"}))
+		var obj TestObject
+		require.NoError(t, prod.Get(ctx, "/pods/web", &obj))
+		assert.Equal(t, "prod", obj.Name)
+		assert.ErrorIs(t, prod.Get(ctx, "/pods/db", &obj), ErrNotFound)
+
+		resp, err := cli.Get(ctx, "/", clientv3.WithPrefix(), clientv3.WithKeysOnly())
+		require.NoError(t, err)
+		var keys []string
+		for _, kv := range resp.Kvs {
+			keys = append(keys, string(kv.Key))
+		}
+		assert.Equal(t, []string{"/gokube/dev/pods/db", "/gokube/dev/pods/web", "/gokube/prod/pods/web"}, keys)
+
+		objects, err := ListOf[TestObject](ctx, dev, "/pods/")
+		require.NoError(t, err)
+		assert.Len(t, objects, 2)
+		page, next, err := ListPageOf[TestObject](ctx, dev, "/pods/", ListOptions{Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "dev db", page[0].Name)
+		page, _, err = ListPageOf[TestObject](ctx, dev, "/pods/", ListOptions{Limit: 1, Continue: next})
+		require.NoError(t, err, "the continue token names the key without the root")
+		assert.Equal(t, "dev", page[0].Name)
+		count, err := prod.Count(ctx, "/pods/")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		require.NoError(t, dev.Txn(ctx, []TxnOp{CreateOp("/pods/cache", &TestObject{Name: "dev cache"}), DeleteOp("/pods/db")}))
+		require.NoError(t, dev.DeletePrefix(ctx, "/"))
+		count, err = dev.Count(ctx, "/")
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		require.NoError(t, prod.Get(ctx, "/pods/web", &obj), "deleting everything of a cluster leaves the others")
+
+		events, err := prod.Watch(ctx, "/pods/", func() runtime.Object { return &TestObject{} })
+		require.NoError(t, err)
+		require.NoError(t, dev.Update(ctx, "/pods/web", &TestObject{Name: "dev"}))
+		require.NoError(t, prod.Update(ctx, "/pods/web", &TestObject{Name: "prod"}))
+		select {
+		case event := <-events:
+			assert.Equal(t, "/pods/web", event.Key, "the keys of events are without the root")
+			assert.Equal(t, &TestObject{Name: "prod"}, event.Object, "the changes of other clusters are not watched")
+		case <-ctx.Done():
+			t.Fatal("no event received")
+		}
+
+		_, err = ClusterPrefix("dev/pods")
+		assert.ErrorIs(t, err, ErrInvalidClusterName)
+		root, err := ClusterPrefix("")
+		require.NoError(t, err)
+		assert.Empty(t, root, "the unnamed cluster keeps its keys at the root")
+	})
+}
+
 func TestEtcdStorage_Delete(t *testing.T) {
 	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
 		storage := NewEtcdStorage(cli)
@@ -96,6 +256,165 @@ func TestEtcdStorage_List(t *testing.T) {
 	})
 }
 
+func TestEtcdStorage_ListErrors(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, storage.Create(ctx, "/prefix/key1", &TestObject{Name: "value1"}))
+
+		for name, listObj := range map[string]interface{}{
+			"nil":                        nil,
+			"slice instead of a pointer": []*TestObject{},
+			"pointer to a struct":        &TestObject{},
+			"slice of structs":           &[]TestObject{},
+			"nil pointer":                (*[]*TestObject)(nil),
+		} {
+			t.Run(name, func(t *testing.T) {
+				assert.ErrorIs(t, storage.List(ctx, "/prefix/", listObj), ErrInvalidListObject)
+			})
+		}
+
+		t.Run("corrupt value", func(t *testing.T) {
+			_, err := cli.Put(ctx, "/prefix/key2", "{not json")
+			require.NoError(t, err)
+			list := []*TestObject{{Name: "before"}}
+
+			err = storage.List(ctx, "/prefix/", &list)
+			assert.ErrorIs(t, err, ErrDecoding)
+			var corrupt *CorruptValuesError
+			require.ErrorAs(t, err, &corrupt)
+			require.Len(t, corrupt.Values, 1)
+			assert.Equal(t, "/prefix/key2", corrupt.Values[0].Key)
+			assert.Equal(t, []*TestObject{{Name: "before"}, {Name: "value1"}}, list, "the other values are listed")
+			objects, err := ListOf[TestObject](ctx, storage, "/prefix/")
+			assert.ErrorIs(t, err, ErrDecoding)
+			assert.Equal(t, []*TestObject{{Name: "value1"}}, objects)
+
+			list = []*TestObject{{Name: "before"}}
+			_, err = storage.ListPage(ctx, "/prefix/", ListOptions{Strict: true}, &list)
+			assert.ErrorIs(t, err, ErrDecoding)
+			assert.False(t, errors.As(err, &corrupt), "a strict list fails at the corrupt value")
+			assert.Equal(t, []*TestObject{{Name: "before"}}, list, "the list should be left untouched")
+		})
+	})
+}
+
+func TestListOf(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		empty, err := ListOf[TestObject](ctx, storage, "/prefix/")
+		require.NoError(t, err)
+		assert.NotNil(t, empty, "an empty list should encode as [], not null")
+		assert.Empty(t, empty)
+
+		require.NoError(t, storage.Create(ctx, "/prefix/b", &TestObject{Name: "b"}))
+		require.NoError(t, storage.Create(ctx, "/prefix/a", &TestObject{Name: "a"}))
+		list, err := ListOf[TestObject](ctx, storage, "/prefix/")
+		require.NoError(t, err)
+		assert.Equal(t, []*TestObject{{Name: "a"}, {Name: "b"}}, list, "the objects should be in key order")
+	})
+}
+
+// FuzzListInto feeds arbitrary values to the reflective List, which must decode each into a new item or
+// fail with ErrDecoding, but never panic
+func FuzzListInto(f *testing.F) {
+	f.Add([]byte(`{"name":"web"}`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`[1,2]`))
+	f.Add([]byte(`{"name":`))
+	f.Fuzz(func(t *testing.T, value []byte) {
+		var list []*TestObject
+		err := listInto(&list, func(newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+			for range 2 {
+				obj := newItem()
+				if err := runtime.Decode(value, obj); err != nil {
+					return fmt.Errorf("%w: %v", ErrDecoding, err)
+				}
+				appendItem(obj)
+			}
+			return nil
+		})
+		if err != nil {
+			assert.ErrorIs(t, err, ErrDecoding)
+			assert.Empty(t, list)
+			return
+		}
+		assert.Len(t, list, 2)
+		assert.NotSame(t, list[0], list[1], "each value should be decoded into an item of its own")
+	})
+}
+
+// benchmarkPods stores 5000 pods under /pods/ for the list benchmarks
+func benchmarkPods(b *testing.B) *EtcdStorage {
+	b.Helper()
+	cli := NewTestEtcdClient(b)
+	storage := NewEtcdStorage(cli)
+	ctx := context.Background()
+	for i := range 5000 {
+		pod := &api.Pod{
+			ObjectMeta: api.ObjectMeta{Name: fmt.Sprintf("web-%04d", i), Namespace: api.DefaultNamespace, Labels: map[string]string{"app": "web"}},
+			Spec:       api.PodSpec{Containers: []api.Container{{Name: "web", Image: "nginx:latest", Ports: []api.ContainerPort{{ContainerPort: 80}}}}},
+			NodeName:   "node-1",
+			Status:     api.PodRunning,
+		}
+		if err := storage.Create(ctx, "/pods/"+pod.Name, pod); err != nil {
+			b.Fatalf("Failed to create pod: %v", err)
+		}
+	}
+	return storage
+}
+
+func BenchmarkEtcdStorage_List(b *testing.B) {
+	storage := benchmarkPods(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		var pods []*api.Pod
+		if err := storage.List(context.Background(), "/pods/", &pods); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListOf(b *testing.B) {
+	storage := benchmarkPods(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := ListOf[api.Pod](context.Background(), storage, "/pods/"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEtcdStorage_Get and BenchmarkEtcdStorage_Exists compare checking that a pod is there by reading it
+// with asking etcd whether it is
+func BenchmarkEtcdStorage_Get(b *testing.B) {
+	storage := benchmarkPods(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := storage.Get(context.Background(), "/pods/web-2500", &api.Pod{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEtcdStorage_Exists(b *testing.B) {
+	storage := benchmarkPods(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if exists, err := storage.Exists(context.Background(), "/pods/web-2500"); err != nil || !exists {
+			b.Fatal(exists, err)
+		}
+	}
+}
+
 func TestWatch(t *testing.T) {
 	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
 		watchKey := "/watch-test/key"
@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/server/v3/embed"
 )
 
 func TestStartEmbeddedEtcd(t *testing.T) {
@@ -31,3 +37,28 @@ func TestStopEmbeddedEtcd(t *testing.T) {
 	_, err = os.Stat(etcd.Config().Dir)
 	assert.True(t, os.IsNotExist(err), "Expected data directory to be removed, but it still exists")
 }
+
+func TestStartEmbeddedEtcd_PreserveData(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "etcd")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := func(preserveData bool) (*embed.Etcd, *EtcdStorage) {
+		etcd, port, err := StartEmbeddedEtcdWithOptions(EmbeddedEtcdOptions{Dir: dir, PreserveData: preserveData, Logger: newTestLogger(t)})
+		require.NoError(t, err)
+		cli := newTestClient(t, EtcdConfig{Endpoints: []string{fmt.Sprintf("127.0.0.1:%d", port)}})
+		return etcd, NewEtcdStorage(cli)
+	}
+
+	etcd, storage := start(true)
+	require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+	StopEmbeddedEtcd(etcd)
+	assert.DirExists(t, dir)
+
+	etcd, storage = start(false)
+	var obj TestObject
+	require.NoError(t, storage.Get(ctx, "/pods/web", &obj), "the data survives a restart")
+	assert.Equal(t, "web", obj.Name)
+
+	StopEmbeddedEtcd(etcd)
+	assert.NoDirExists(t, dir, "a directory that is not preserved is removed")
+}
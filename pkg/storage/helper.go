@@ -1,36 +1,156 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/namespace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// sharedEtcd is the embedded etcd RunWithSharedEtcd started for the tests of the package, if any
+var sharedEtcd *sharedEtcdServer
+
+type sharedEtcdServer struct {
+	endpoint string
+	// tests numbers the key prefixes handed out to the tests
+	tests atomic.Int64
+}
+
 // TestWithEmbeddedEtcd takes in testing.T, starts the embedded etcd server
 // handles the cleanup of the server after the test is done and invokes the test function
 // with the embedded etcd server instance
 func TestWithEmbeddedEtcd(t *testing.T, test func(t *testing.T, etcdServer *clientv3.Client)) {
-	etcdServer, port, err := StartEmbeddedEtcd()
+	t.Helper()
+	test(t, NewTestEtcdClient(t))
+}
+
+// NewTestEtcdClient returns a client of an embedded etcd of the test's own, which is torn down once the test
+// and its subtests are over, so tests calling t.Parallel are safe. The logs of etcd go to the test log. When
+// the package runs RunWithSharedEtcd, the client is of the shared etcd instead, seeing only a key prefix of
+// the test's own, which is deleted once the test is over.
+func NewTestEtcdClient(t testing.TB) *clientv3.Client {
+	t.Helper()
+	if sharedEtcd != nil {
+		return sharedEtcd.client(t)
+	}
+
+	logger := newTestLogger(t)
+	dir := t.TempDir()
+	// etcd warns about data directories others can read
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatalf("Failed to restrict the etcd data directory: %v", err)
+	}
+	etcdServer, port, err := StartEmbeddedEtcdWithOptions(EmbeddedEtcdOptions{Dir: dir, Logger: logger})
 	if err != nil {
 		t.Fatalf("Failed to start embedded etcd: %v", err)
 	}
-	defer StopEmbeddedEtcd(etcdServer)
+	t.Cleanup(func() { StopEmbeddedEtcd(etcdServer) })
 
 	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   []string{fmt.Sprintf("http://localhost:%d", port)},
+		Endpoints:   []string{fmt.Sprintf("http://127.0.0.1:%d", port)},
 		DialTimeout: 5 * time.Second,
+		Logger:      logger,
 	})
+	if err != nil {
+		t.Fatalf("Failed to create etcd client: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := cli.Close(); err != nil {
+			t.Errorf("Failed to close etcd client: %v", err)
+		}
+	})
+	return cli
+}
+
+// RunWithSharedEtcd runs the tests of a package against a single embedded etcd rather than one per test,
+// which is much faster. Each test sees a key prefix of its own, so tests still start from an empty etcd.
+// Call it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(storage.RunWithSharedEtcd(m))
+//	}
+func RunWithSharedEtcd(m *testing.M) int {
+	// Only errors are logged, as there is no test log to send them to
+	logger := zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.Lock(os.Stderr), zap.ErrorLevel))
+	etcdServer, port, err := StartEmbeddedEtcdWithOptions(EmbeddedEtcdOptions{Logger: logger})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start embedded etcd: %v\n", err)
+		return 1
+	}
+	defer StopEmbeddedEtcd(etcdServer)
 
+	sharedEtcd = &sharedEtcdServer{endpoint: fmt.Sprintf("http://127.0.0.1:%d", port)}
+	defer func() { sharedEtcd = nil }()
+	return m.Run()
+}
+
+// client returns a client of the shared etcd that sees the keys under a prefix of the test's own
+func (s *sharedEtcdServer) client(t testing.TB) *clientv3.Client {
+	t.Helper()
+	logger := newTestLogger(t)
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{s.endpoint},
+		DialTimeout: 5 * time.Second,
+		Logger:      logger,
+	})
 	if err != nil {
 		t.Fatalf("Failed to create etcd client: %v", err)
 	}
-	defer func() {
+
+	prefix := fmt.Sprintf("/tests/%d/", s.tests.Add(1))
+	cli.KV = namespace.NewKV(cli.KV, prefix)
+	cli.Watcher = namespace.NewWatcher(cli.Watcher, prefix)
+	cli.Lease = namespace.NewLease(cli.Lease, prefix)
+	t.Cleanup(func() {
+		// The empty prefix is every key of the test
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := NewEtcdStorage(cli).DeletePrefix(ctx, ""); err != nil {
+			t.Errorf("Failed to delete the keys of the test: %v", err)
+		}
 		if err := cli.Close(); err != nil {
-			t.Fatalf("Failed to close etcd client: %v", err)
+			t.Errorf("Failed to close etcd client: %v", err)
 		}
-	}()
+	})
+	return cli
+}
+
+// newTestLogger returns a logger that writes warnings and errors to the test log until the test is over.
+// etcd may log from goroutines that outlive the test, which t.Log does not allow, so those logs are dropped.
+func newTestLogger(t testing.TB) *zap.Logger {
+	writer := &testLogWriter{t: t}
+	t.Cleanup(writer.close)
+	return zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		zapcore.AddSync(writer), zap.WarnLevel))
+}
+
+type testLogWriter struct {
+	mutex  sync.Mutex
+	t      testing.TB
+	closed bool
+}
+
+func (w *testLogWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.closed {
+		w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	}
+	return len(p), nil
+}
 
-	test(t, cli)
+func (w *testLogWriter) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
 }
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// testIsolation runs parallel tests writing the same keys and checks that none sees the keys of another
+func testIsolation(t *testing.T) {
+	for i := range 3 {
+		t.Run(fmt.Sprintf("test-%d", i), func(t *testing.T) {
+			t.Parallel()
+			TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+				storage := NewEtcdStorage(cli)
+				ctx := context.Background()
+
+				var objects []*TestObject
+				require.NoError(t, storage.List(ctx, "/objects/", &objects))
+				assert.Empty(t, objects, "the test starts from an empty etcd")
+
+				require.NoError(t, storage.Create(ctx, "/objects/shared", &TestObject{Name: t.Name()}))
+				var object TestObject
+				require.NoError(t, storage.Get(ctx, "/objects/shared", &object))
+				assert.Equal(t, t.Name(), object.Name)
+			})
+		})
+	}
+}
+
+func TestWithEmbeddedEtcd_Parallel(t *testing.T) {
+	testIsolation(t)
+}
+
+func TestRunWithSharedEtcd_IsolatesTests(t *testing.T) {
+	etcdServer, port, err := StartEmbeddedEtcdWithOptions(EmbeddedEtcdOptions{Dir: t.TempDir(), Logger: newTestLogger(t)})
+	require.NoError(t, err)
+	t.Cleanup(func() { StopEmbeddedEtcd(etcdServer) })
+	sharedEtcd = &sharedEtcdServer{endpoint: fmt.Sprintf("http://127.0.0.1:%d", port)}
+	defer func() { sharedEtcd = nil }()
+
+	t.Run("tests", testIsolation)
+
+	// The keys of the tests were deleted once they were over
+	cli := NewTestEtcdClient(t)
+	response, err := cli.Get(context.Background(), "", clientv3.WithPrefix())
+	require.NoError(t, err)
+	assert.Zero(t, response.Count)
+	raw, err := clientv3.New(clientv3.Config{Endpoints: []string{sharedEtcd.endpoint}, Logger: newTestLogger(t)})
+	require.NoError(t, err)
+	defer raw.Close()
+	response, err = raw.Get(context.Background(), "/tests/", clientv3.WithPrefix())
+	require.NoError(t, err)
+	assert.Zero(t, response.Count, "no keys are left behind")
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gokube/pkg/runtime"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// LeaseID identifies an etcd lease. The keys attached to a lease are deleted once it expires.
+type LeaseID int64
+
+var (
+	// ErrLeaseNotFound is returned by KeepAlive for a lease that expired or was revoked
+	ErrLeaseNotFound = fmt.Errorf("lease not found")
+	// ErrTTLUnsupported is returned for keys with a TTL by the storages that cannot expire keys
+	ErrTTLUnsupported = fmt.Errorf("storage cannot expire keys")
+)
+
+// TTLStorage is a Storage whose keys can expire, such as EtcdStorage
+type TTLStorage interface {
+	Storage
+	// CreateWithTTL stores obj under a new key, to be deleted ttlSeconds after the returned lease was last kept
+	// alive. It fails with ErrKeyExists if the key exists.
+	CreateWithTTL(ctx context.Context, key string, obj runtime.Object, ttlSeconds int64) (LeaseID, error)
+	// KeepAlive renews the lease for another TTL
+	KeepAlive(ctx context.Context, id LeaseID) error
+}
+
+// CreateWithTTL stores obj under key attached to a new lease of ttlSeconds, so the key is deleted unless the
+// lease is kept alive. etcd rounds short TTLs up to its minimum of about two seconds. Like Create it fails with
+// ErrKeyExists if the key exists, and the new lease is revoked.
+func (s *EtcdStorage) CreateWithTTL(ctx context.Context, key string, obj runtime.Object, ttlSeconds int64) (id LeaseID, err error) {
+	defer s.observe(OpCreate, key, time.Now(), &err)
+	data, err := s.codec.Encode(obj)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+
+	lease, err := s.client.Grant(ctx, ttlSeconds)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	resp, err := s.kv.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(data), clientv3.WithLease(lease.ID))).
+		Commit()
+	if err != nil {
+		// The lease holds no key, so it is only left to expire
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	if !resp.Succeeded {
+		// Failing to revoke it leaves the lease to expire all the same
+		_, _ = s.client.Revoke(ctx, lease.ID)
+		return 0, fmt.Errorf("%w: %s", ErrKeyExists, key)
+	}
+	setResourceVersion(obj, resp.Header.Revision)
+	return LeaseID(lease.ID), nil
+}
+
+// KeepAlive renews the lease once for another TTL. It writes no key and creates no revision, so it is much
+// cheaper than storing the key again. It returns ErrLeaseNotFound once the lease expired.
+func (s *EtcdStorage) KeepAlive(ctx context.Context, id LeaseID) (err error) {
+	defer s.observe(OpKeepAlive, "", time.Now(), &err)
+	if _, err := s.client.KeepAliveOnce(ctx, clientv3.LeaseID(id)); err != nil {
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return fmt.Errorf("%w: %x", ErrLeaseNotFound, id)
+		}
+		return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdStorage_CreateWithTTL(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		kept, err := storage.CreateWithTTL(ctx, "/leases/kept", &TestObject{Name: "kept"}, 2)
+		require.NoError(t, err)
+		// etcd rounds the TTL up to its minimum, but the key still expires once the lease is not renewed
+		expiring, err := storage.CreateWithTTL(ctx, "/leases/expiring", &TestObject{Name: "expiring"}, 1)
+		require.NoError(t, err)
+
+		var obj TestObject
+		require.NoError(t, storage.Get(ctx, "/leases/expiring", &obj))
+		assert.Equal(t, "expiring", obj.Name)
+		_, err = storage.CreateWithTTL(ctx, "/leases/expiring", &TestObject{Name: "replaced"}, 2)
+		assert.ErrorIs(t, err, ErrKeyExists, "an existing key is not replaced")
+		require.NoError(t, storage.Get(ctx, "/leases/expiring", &obj))
+		assert.Equal(t, "expiring", obj.Name)
+
+		// Only the kept lease is renewed, so only the other key expires
+		require.Eventually(t, func() bool {
+			require.NoError(t, storage.KeepAlive(ctx, kept))
+			return storage.Get(ctx, "/leases/expiring", &obj) != nil
+		}, 10*time.Second, 200*time.Millisecond)
+		assert.ErrorIs(t, storage.Get(ctx, "/leases/expiring", &obj), ErrNotFound)
+		require.NoError(t, storage.Get(ctx, "/leases/kept", &obj))
+		assert.Equal(t, "kept", obj.Name)
+
+		assert.ErrorIs(t, storage.KeepAlive(ctx, expiring), ErrLeaseNotFound)
+	})
+}
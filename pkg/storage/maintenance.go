@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gokube/pkg/logging"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	// DefaultCompactionInterval is how often the revisions of etcd are compacted when no interval is configured
+	DefaultCompactionInterval = 5 * time.Minute
+	// DefaultDefragmentInterval is how often the members of etcd are defragmented when no interval is configured
+	DefaultDefragmentInterval = time.Hour
+)
+
+// Maintainer keeps etcd from running out of space: etcd keeps every revision of every key until they are
+// compacted, and the space they took is only given back to the file system by defragmenting. Without both a
+// long-running cluster ends up failing its writes with "mvcc: database space exceeded".
+type Maintainer struct {
+	client             *clientv3.Client
+	compactionInterval time.Duration
+	defragmentInterval time.Duration
+	logger             *slog.Logger
+	// compacted is the revision of the last compaction, so an idle etcd is not compacted again
+	compacted int64
+}
+
+// NewMaintainer creates a Maintainer that compacts the revisions of the etcd of client every compactionInterval
+// and defragments its members every defragmentInterval. An interval of 0 turns that part off.
+func NewMaintainer(client *clientv3.Client, compactionInterval, defragmentInterval time.Duration) *Maintainer {
+	return &Maintainer{
+		client:             client,
+		compactionInterval: compactionInterval,
+		defragmentInterval: defragmentInterval,
+		logger:             logging.Component("etcd-maintainer"),
+	}
+}
+
+// Start compacts and defragments etcd at their intervals until ctx is done. The failures are logged and
+// retried at the next interval.
+func (m *Maintainer) Start(ctx context.Context) {
+	compactions := tick(m.compactionInterval)
+	defer compactions.Stop()
+	defragmentations := tick(m.defragmentInterval)
+	defer defragmentations.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-compactions.C:
+			if _, err := m.Compact(ctx); err != nil {
+				m.logger.ErrorContext(ctx, "Failed to compact etcd", logging.Err(err))
+			}
+		case <-defragmentations.C:
+			if err := m.Defragment(ctx); err != nil {
+				m.logger.ErrorContext(ctx, "Failed to defragment etcd", logging.Err(err))
+			}
+		}
+	}
+}
+
+// tick returns a ticker of interval, or a stopped one that never ticks for an interval of 0
+func tick(interval time.Duration) *time.Ticker {
+	if interval <= 0 {
+		ticker := time.NewTicker(time.Hour)
+		ticker.Stop()
+		return ticker
+	}
+	return time.NewTicker(interval)
+}
+
+// Compact discards the revisions of etcd older than the current one, and returns the revision it compacted
+// to. Watches started at an older revision fail with a compacted error and relist. It does nothing if etcd was
+// not written since the last compaction.
+func (m *Maintainer) Compact(ctx context.Context) (int64, error) {
+	endpoint := m.client.Endpoints()[0]
+	before, err := m.client.Status(ctx, endpoint)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	revision := before.Header.Revision
+	if revision <= m.compacted {
+		return m.compacted, nil
+	}
+	if _, err := m.client.Compact(ctx, revision, clientv3.WithCompactPhysical()); err != nil && !errors.Is(err, rpctypes.ErrCompacted) {
+		return 0, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	m.compacted = revision
+
+	after, err := m.client.Status(ctx, endpoint)
+	if err != nil {
+		return revision, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+	}
+	m.logger.InfoContext(ctx, "Compacted etcd", "revision", revision,
+		"inUseBefore", before.DbSizeInUse, "inUseAfter", after.DbSizeInUse)
+	return revision, nil
+}
+
+// Defragment gives the space freed by compaction back to the file system, one member at a time since a member
+// serves nothing while it is defragmented
+func (m *Maintainer) Defragment(ctx context.Context) error {
+	for _, endpoint := range m.client.Endpoints() {
+		before, err := m.client.Status(ctx, endpoint)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		if _, err := m.client.Defragment(ctx, endpoint); err != nil {
+			return fmt.Errorf("%w: failed to defragment %s: %v", ErrEtcdClient, endpoint, err)
+		}
+		after, err := m.client.Status(ctx, endpoint)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrEtcdClient, err)
+		}
+		m.logger.InfoContext(ctx, "Defragmented etcd", "endpoint", endpoint,
+			"sizeBefore", before.DbSize, "sizeAfter", after.DbSize)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestMaintainer_Compact(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		storage := NewEtcdStorage(cli)
+		obj := &TestObject{Name: "web"}
+		require.NoError(t, storage.Create(ctx, "/pods/web", obj))
+		first, err := cli.Get(ctx, "/pods/web")
+		require.NoError(t, err)
+		for range 10 {
+			require.NoError(t, storage.Update(ctx, "/pods/web", obj))
+		}
+
+		maintainer := NewMaintainer(cli, 0, 0)
+		revision, err := maintainer.Compact(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, revision, first.Header.Revision)
+
+		_, err = cli.Get(ctx, "/pods/web", clientv3.WithRev(first.Header.Revision))
+		assert.ErrorIs(t, err, rpctypes.ErrCompacted, "the old revisions are gone")
+		require.NoError(t, storage.Get(ctx, "/pods/web", obj), "the current one is kept")
+
+		again, err := maintainer.Compact(ctx)
+		require.NoError(t, err, "compacting an idle etcd does nothing")
+		assert.Equal(t, revision, again)
+
+		assert.NoError(t, maintainer.Defragment(ctx))
+		require.NoError(t, storage.Get(ctx, "/pods/web", obj))
+	})
+}
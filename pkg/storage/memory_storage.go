@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gokube/pkg/runtime"
+)
+
+// memoryValue is a stored object, encoded as etcd would store it, with the revision that last changed it
+type memoryValue struct {
+	data        []byte
+	modRevision int64
+}
+
+// MemoryStorage implements the Storage interface with a map, for tests and demos that do not need etcd. It
+// behaves like EtcdStorage: objects are stored encoded, so callers never share them, every write makes a new
+// revision that becomes the resource version of the object, lists are in key order, and the same errors are
+// returned.
+type MemoryStorage struct {
+	mutex    sync.RWMutex
+	values   map[string]memoryValue
+	revision int64
+	// changes gets every write, under the write lock so its watchers see them in order
+	changes *Broadcaster
+	// history holds the last changes, for WatchFrom to replay. compacted is the revision of the latest change
+	// dropped from it; a watch from before it would miss changes.
+	history   []WatchEvent
+	compacted int64
+}
+
+// MemoryHistory is how many changes a MemoryStorage keeps at least for WatchFrom
+const MemoryHistory = 1000
+
+// NewMemoryStorage creates an empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{values: make(map[string]memoryValue), changes: NewBroadcaster(DefaultWatchBufferSize)}
+}
+
+// Create stores obj under key if nothing is stored there yet, and fails with ErrKeyExists otherwise
+func (s *MemoryStorage) Create(ctx context.Context, key string, obj runtime.Object) error {
+	data, err := runtime.Encode(obj)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.values[key]; ok {
+		return fmt.Errorf("%w: %s", ErrKeyExists, key)
+	}
+	s.put(key, data, obj)
+	return nil
+}
+
+func (s *MemoryStorage) Get(ctx context.Context, key string, obj runtime.Object) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, ok := s.values[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return value.decode(obj)
+}
+
+// Exists reports whether a value is stored under key
+func (s *MemoryStorage) Exists(ctx context.Context, key string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.values[key]
+	return ok, nil
+}
+
+// Update stores obj under key. Like EtcdStorage.Update, an object with a resource version is only stored if the
+// key was not changed since that version, otherwise the update fails with ErrConflict, or ErrNotFound if the key
+// was deleted.
+func (s *MemoryStorage) Update(ctx context.Context, key string, obj runtime.Object) error {
+	data, err := runtime.Encode(obj)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if version := resourceVersion(obj); version != "" {
+		value, ok := s.values[key]
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: %s", ErrNotFound, key)
+		case strconv.FormatInt(value.modRevision, 10) != version:
+			return fmt.Errorf("%w: %s was changed since version %s", ErrConflict, key, version)
+		}
+	}
+	s.put(key, data, obj)
+	return nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.values[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	s.revision++
+	s.remove(key, value)
+	return nil
+}
+
+func (s *MemoryStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var keys []string
+	for key := range s.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	// etcd deletes the keys of a prefix in one revision, reporting them in key order
+	sort.Strings(keys)
+	s.revision++
+	for _, key := range keys {
+		s.remove(key, s.values[key])
+	}
+	return nil
+}
+
+// List decodes the values under prefix into listObj, which must be a pointer to a slice of pointers
+func (s *MemoryStorage) List(ctx context.Context, prefix string, listObj interface{}) error {
+	return listInto(listObj, func(newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+		return s.ListFunc(ctx, prefix, newItem, appendItem)
+	})
+}
+
+// ListFunc decodes each value under prefix, in key order, into an object returned by newItem and hands it to
+// appendItem. The values that fail to decode are skipped, and reported in a *CorruptValuesError.
+func (s *MemoryStorage) ListFunc(ctx context.Context, prefix string, newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+	_, err := s.ListPageFunc(ctx, prefix, ListOptions{}, newItem, appendItem)
+	return err
+}
+
+// ListPage decodes the page of the values under prefix that options select into listObj, which must be a
+// pointer to a slice of pointers
+func (s *MemoryStorage) ListPage(ctx context.Context, prefix string, options ListOptions, listObj interface{}) (string, error) {
+	var result ListResult
+	err := listInto(listObj, func(newItem func() runtime.Object, appendItem func(runtime.Object)) error {
+		var err error
+		result, err = s.ListPageFunc(ctx, prefix, options, newItem, appendItem)
+		return err
+	})
+	return result.Continue, err
+}
+
+// ListPageFunc decodes the values under prefix, in key order, from the key the continue token of options
+// names, and up to its limit. The values that fail to decode are skipped, and reported in a
+// *CorruptValuesError once the others were handed over, unless options are strict.
+func (s *MemoryStorage) ListPageFunc(ctx context.Context, prefix string, options ListOptions, newItem func() runtime.Object, appendItem func(runtime.Object)) (ListResult, error) {
+	start, err := decodeContinue(prefix, options.Continue)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	s.mutex.RLock()
+	result := ListResult{Revision: s.revision}
+	keys := make([]string, 0)
+	for key := range s.values {
+		if strings.HasPrefix(key, prefix) && key >= start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if options.Limit > 0 && int64(len(keys)) > options.Limit {
+		keys = keys[:options.Limit]
+		result.Continue = encodeContinue(keys[len(keys)-1] + "\x00")
+	}
+	objects := make([]runtime.Object, 0, len(keys))
+	var corrupt corruptValues
+	for _, key := range keys {
+		obj := newItem()
+		if err := s.values[key].decode(obj); err != nil {
+			if err := corrupt.skip(options, key, err); err != nil {
+				s.mutex.RUnlock()
+				return ListResult{}, err
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	s.mutex.RUnlock()
+
+	// appendItem runs unlocked, so it may use the storage
+	for _, obj := range objects {
+		appendItem(obj)
+	}
+	return result, corrupt.err()
+}
+
+// Count returns how many values are stored under prefix
+func (s *MemoryStorage) Count(ctx context.Context, prefix string) (int64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var count int64
+	for key := range s.values {
+		if strings.HasPrefix(key, prefix) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ListKeys returns the keys under prefix in key order
+func (s *MemoryStorage) ListKeys(ctx context.Context, prefix string) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0)
+	for key := range s.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Txn makes the writes of ops in one revision if all their conditions hold, checked as EtcdStorage checks them
+func (s *MemoryStorage) Txn(ctx context.Context, ops []TxnOp) error {
+	if err := checkTxn(ops); err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	data := make([][]byte, len(ops))
+	for i, op := range ops {
+		if op.Op == OpDelete {
+			continue
+		}
+		var err error
+		if data[i], err = runtime.Encode(op.Object); err != nil {
+			return fmt.Errorf("%w: %v", ErrEncoding, err)
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, op := range ops {
+		value, ok := s.values[op.Key]
+		if err := txnCondition(op, ok, value.modRevision); err != nil {
+			return err
+		}
+	}
+	s.revision++
+	for i, op := range ops {
+		if op.Op == OpDelete {
+			if value, ok := s.values[op.Key]; ok {
+				s.remove(op.Key, value)
+			}
+			continue
+		}
+		s.store(op.Key, data[i], op.Object)
+	}
+	return nil
+}
+
+// Watch follows the changes of the keys under prefix from now on, as EtcdStorage.Watch does. The channel is also
+// closed if the caller falls so far behind that changes were dropped.
+func (s *MemoryStorage) Watch(ctx context.Context, prefix string, newItem func() runtime.Object) (<-chan ObjectEvent, error) {
+	return s.WatchFrom(ctx, prefix, 0, newItem)
+}
+
+// WatchFrom is Watch from the changes made after revision, or from now on for revision 0. Only the last
+// changes are kept, at least MemoryHistory of them; a watch from before them is closed right away, as etcd
+// closes a watch from a compacted revision.
+func (s *MemoryStorage) WatchFrom(ctx context.Context, prefix string, revision int64, newItem func() runtime.Object) (<-chan ObjectEvent, error) {
+	// Writes broadcast under the write lock, so no change falls between the replayed and the watched ones
+	s.mutex.RLock()
+	if revision > 0 && revision < s.compacted {
+		s.mutex.RUnlock()
+		objects := make(chan ObjectEvent)
+		close(objects)
+		return objects, nil
+	}
+	var replay []WatchEvent
+	if revision > 0 {
+		for _, event := range s.history {
+			if event.Revision > revision {
+				replay = append(replay, event)
+			}
+		}
+	}
+	watcher := s.changes.Watch(ctx)
+	s.mutex.RUnlock()
+	return follow(ctx, watcher, replay, prefix, revision, decodeMemoryValue, newItem), nil
+}
+
+// put stores data under key in a new revision, which becomes the resource version of obj. The caller holds
+// the write lock.
+func (s *MemoryStorage) put(key string, data []byte, obj runtime.Object) {
+	s.revision++
+	s.store(key, data, obj)
+}
+
+// store stores data under key in the current revision and tells the watchers. The caller holds the write lock.
+func (s *MemoryStorage) store(key string, data []byte, obj runtime.Object) {
+	_, exists := s.values[key]
+	s.values[key] = memoryValue{data: data, modRevision: s.revision}
+	setResourceVersion(obj, s.revision)
+	s.record(WatchEvent{Type: WatchPut, Key: key, Value: data, Created: !exists, Revision: s.revision})
+}
+
+// remove deletes the value under key in the current revision and tells the watchers. The caller holds the write
+// lock.
+func (s *MemoryStorage) remove(key string, value memoryValue) {
+	delete(s.values, key)
+	s.record(WatchEvent{Type: WatchDelete, Key: key, PrevValue: value.data, Revision: s.revision})
+}
+
+// record keeps event in the history and tells the watchers. The caller holds the write lock.
+func (s *MemoryStorage) record(event WatchEvent) {
+	// The oldest changes are dropped in bulk rather than one by one, so a write copies no history
+	if len(s.history) == 2*MemoryHistory {
+		s.compacted = s.history[MemoryHistory-1].Revision
+		s.history = append(s.history[:0], s.history[MemoryHistory:]...)
+	}
+	s.history = append(s.history, event)
+	s.changes.Broadcast(event)
+}
+
+func decodeMemoryValue(data []byte, revision int64, obj runtime.Object) error {
+	return memoryValue{data: data, modRevision: revision}.decode(obj)
+}
+
+func (v memoryValue) decode(obj runtime.Object) error {
+	if err := runtime.Decode(v.data, obj); err != nil {
+		return fmt.Errorf("%w: %v", ErrDecoding, err)
+	}
+	setResourceVersion(obj, v.modRevision)
+	return nil
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/runtime"
+)
+
+// TestStorageImplementations checks that MemoryStorage behaves like EtcdStorage, by running the same
+// operations against both
+func TestStorageImplementations(t *testing.T) {
+	t.Run("etcd", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			testStorageSemantics(t, NewEtcdStorage(cli))
+			testStoragePaging(t, NewEtcdStorage(cli))
+			testStorageCount(t, NewEtcdStorage(cli))
+			testStorageExists(t, NewEtcdStorage(cli))
+			testStorageWatch(t, NewEtcdStorage(cli))
+			testStorageWatchFrom(t, NewEtcdStorage(cli))
+			testStorageTxn(t, NewEtcdStorage(cli))
+		})
+	})
+	t.Run("etcd with compressed JSON", func(t *testing.T) {
+		TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+			testStorageSemantics(t, NewEtcdStorageWithCodec(cli, runtime.CompressedJSONCodec))
+			testStoragePaging(t, NewEtcdStorageWithCodec(cli, runtime.CompressedJSONCodec))
+		})
+	})
+	t.Run("memory", func(t *testing.T) {
+		testStorageSemantics(t, NewMemoryStorage())
+		testStoragePaging(t, NewMemoryStorage())
+		testStorageCount(t, NewMemoryStorage())
+		testStorageExists(t, NewMemoryStorage())
+		testStorageWatch(t, NewMemoryStorage())
+		testStorageWatchFrom(t, NewMemoryStorage())
+		testStorageTxn(t, NewMemoryStorage())
+	})
+}
+
+func testStorageSemantics(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	for _, name := range []string{"web-2", "web-10", "web-1"} {
+		require.NoError(t, s.Create(ctx, "/pods/"+name, &api.Pod{ObjectMeta: api.ObjectMeta{Name: name}}))
+	}
+	require.NoError(t, s.Create(ctx, "/podsets/web", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "not-a-pod"}}))
+	assert.ErrorIs(t, s.Create(ctx, "/pods/web-1", &api.Pod{}), ErrKeyExists)
+
+	pods, err := ListOf[api.Pod](ctx, s, "/pods/")
+	require.NoError(t, err)
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+		assert.NotEmpty(t, pod.ResourceVersion)
+	}
+	assert.Equal(t, []string{"web-1", "web-10", "web-2"}, names, "lists are in lexical key order")
+	var list []*api.Pod
+	require.NoError(t, s.List(ctx, "/pods/", &list))
+	assert.Equal(t, pods, list)
+	assert.ErrorIs(t, s.List(ctx, "/pods/", []*api.Pod{}), ErrInvalidListObject)
+
+	var pod api.Pod
+	require.NoError(t, s.Get(ctx, "/pods/web-1", &pod))
+	stale := pod
+	pod.Status = api.PodRunning
+	require.NoError(t, s.Update(ctx, "/pods/web-1", &pod))
+	assert.NotEqual(t, stale.ResourceVersion, pod.ResourceVersion)
+	assert.ErrorIs(t, s.Update(ctx, "/pods/web-1", &stale), ErrConflict)
+	stale.ResourceVersion = ""
+	require.NoError(t, s.Update(ctx, "/pods/web-1", &stale), "an update without a version is unconditional")
+
+	pod.Status = api.PodFailed
+	assert.NoError(t, s.Delete(ctx, "/pods/web-1"))
+	assert.ErrorIs(t, s.Delete(ctx, "/pods/web-1"), ErrNotFound, "deleting a missing key is reported")
+	assert.ErrorIs(t, s.Get(ctx, "/pods/web-1", &pod), ErrNotFound)
+	assert.ErrorIs(t, s.Update(ctx, "/pods/web-1", &pod), ErrNotFound)
+
+	require.NoError(t, s.DeletePrefix(ctx, "/pods/"))
+	pods, err = ListOf[api.Pod](ctx, s, "/pods/")
+	require.NoError(t, err)
+	assert.Empty(t, pods)
+	require.NoError(t, s.Get(ctx, "/podsets/web", &pod), "keys outside the prefix are kept")
+}
+
+func testStoragePaging(t *testing.T, s Storage) {
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, s.Create(ctx, "/paged/"+name, &TestObject{Name: name}))
+	}
+	require.NoError(t, s.Create(ctx, "/paged0", &TestObject{Name: "outside"}))
+
+	var pages [][]string
+	options := ListOptions{Limit: 2}
+	for {
+		items, next, err := ListPageOf[TestObject](ctx, s, "/paged/", options)
+		require.NoError(t, err)
+		var page []string
+		for _, item := range items {
+			page = append(page, item.Name)
+		}
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+		options.Continue = next
+	}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, pages)
+
+	var list []*TestObject
+	next, err := s.ListPage(ctx, "/paged/", ListOptions{}, &list)
+	require.NoError(t, err)
+	assert.Len(t, list, 5, "limit 0 means no limit")
+	assert.Empty(t, next)
+
+	items, next, err := ListPageOf[TestObject](ctx, s, "/paged/", ListOptions{Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, items, 5)
+	assert.Empty(t, next, "a page holding the rest of the list is the last one")
+
+	items, next, err = ListPageOf[TestObject](ctx, s, "/paged/", ListOptions{Continue: encodeContinue("/paged/z")})
+	require.NoError(t, err)
+	assert.Empty(t, items, "a token past the end selects an empty page")
+	assert.Empty(t, next)
+
+	_, _, err = ListPageOf[TestObject](ctx, s, "/paged/", ListOptions{Continue: "not base64!"})
+	assert.ErrorIs(t, err, ErrInvalidContinue)
+	_, _, err = ListPageOf[TestObject](ctx, s, "/paged/", ListOptions{Continue: encodeContinue("/other/a")})
+	assert.ErrorIs(t, err, ErrInvalidContinue, "a token of another list")
+}
+
+func testStorageCount(t *testing.T, s Storage) {
+	ctx := context.Background()
+	count, err := s.Count(ctx, "/counted/")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	for i := 0; i < 300; i++ {
+		require.NoError(t, s.Create(ctx, fmt.Sprintf("/counted/%03d", i), &TestObject{Name: strconv.Itoa(i)}))
+	}
+	require.NoError(t, s.Create(ctx, "/counted0", &TestObject{Name: "outside"}))
+	count, err = s.Count(ctx, "/counted/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), count)
+
+	require.NoError(t, s.Delete(ctx, "/counted/000"))
+	count, err = s.Count(ctx, "/counted/1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), count, "a narrower prefix")
+	keys, err := s.ListKeys(ctx, "/counted/1")
+	require.NoError(t, err)
+	require.Len(t, keys, 100)
+	assert.Equal(t, "/counted/100", keys[0])
+	assert.Equal(t, "/counted/199", keys[99])
+	count, err = s.Count(ctx, "/counted/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(299), count)
+}
+
+func testStorageExists(t *testing.T, s Storage) {
+	ctx := context.Background()
+	require.NoError(t, s.Create(ctx, "/exists/web", &TestObject{Name: "web"}))
+
+	exists, err := s.Exists(ctx, "/exists/web")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = s.Exists(ctx, "/exists/we")
+	require.NoError(t, err)
+	assert.False(t, exists, "a prefix of a key is not the key")
+
+	require.NoError(t, s.Delete(ctx, "/exists/web"))
+	exists, err = s.Exists(ctx, "/exists/web")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func testStorageWatch(t *testing.T, s Storage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events, err := s.Watch(ctx, "/watched/", func() runtime.Object { return &TestObject{} })
+	require.NoError(t, err)
+	next := func() ObjectEvent {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "the watch ended")
+			return event
+		case <-ctx.Done():
+			require.FailNow(t, "no event")
+			return ObjectEvent{}
+		}
+	}
+
+	obj := &TestObject{Name: "web"}
+	require.NoError(t, s.Create(ctx, "/watched/web", obj))
+	require.NoError(t, s.Create(ctx, "/unwatched", &TestObject{Name: "other"}))
+	obj.Name = "web-2"
+	require.NoError(t, s.Update(ctx, "/watched/web", obj))
+	require.NoError(t, s.Delete(ctx, "/watched/web"))
+
+	event := next()
+	assert.Equal(t, WatchPut, event.Type)
+	assert.True(t, event.Created)
+	assert.Equal(t, "/watched/web", event.Key)
+	assert.Equal(t, &TestObject{Name: "web"}, event.Object)
+
+	event = next()
+	assert.Equal(t, WatchPut, event.Type)
+	assert.False(t, event.Created)
+	assert.Equal(t, &TestObject{Name: "web-2"}, event.Object)
+
+	event = next()
+	assert.Equal(t, WatchDelete, event.Type)
+	assert.Equal(t, &TestObject{Name: "web-2"}, event.Object, "a delete carries the removed object")
+	assert.Greater(t, event.Revision, int64(0))
+
+	cancel()
+	for range events {
+	}
+}
+
+func testStorageWatchFrom(t *testing.T, s Storage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, s.Create(ctx, "/listed/a", &TestObject{Name: "a"}))
+	require.NoError(t, s.Create(ctx, "/listed/b", &TestObject{Name: "b"}))
+	listed, revision, err := ListWithRevisionOf[TestObject](ctx, s, "/listed/")
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	require.NoError(t, s.Create(ctx, "/listed/c", &TestObject{Name: "c"}))
+	require.NoError(t, s.Delete(ctx, "/listed/a"))
+	events, err := s.WatchFrom(ctx, "/listed/", revision, func() runtime.Object { return &TestObject{} })
+	require.NoError(t, err)
+	require.NoError(t, s.Update(ctx, "/listed/b", &TestObject{Name: "b-2"}))
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "the watch ended")
+			got = append(got, fmt.Sprintf("%s %s", event.Type, event.Object.(*TestObject).Name))
+		case <-ctx.Done():
+			require.FailNow(t, "missing events", "got %v", got)
+		}
+	}
+	assert.Equal(t, []string{"PUT c", "DELETE a", "PUT b-2"}, got, "the changes after the list, each once")
+	select {
+	case event := <-events:
+		assert.Fail(t, "an event too many", "%+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func testStorageTxn(t *testing.T, s Storage) {
+	ctx := context.Background()
+	require.NoError(t, s.Create(ctx, "/txn/rs", &api.Pod{ObjectMeta: api.ObjectMeta{Name: "rs"}}))
+	require.NoError(t, s.Create(ctx, "/txn/old", &TestObject{Name: "old"}))
+	var rs api.Pod
+	require.NoError(t, s.Get(ctx, "/txn/rs", &rs))
+
+	a, b := &TestObject{Name: "a"}, &TestObject{Name: "b"}
+	rs.Status = api.PodRunning
+	require.NoError(t, s.Txn(ctx, []TxnOp{CreateOp("/txn/a", a), CreateOp("/txn/b", b), UpdateOp("/txn/rs", &rs), DeleteOp("/txn/old")}))
+	names, err := ListOf[TestObject](ctx, s, "/txn/")
+	require.NoError(t, err)
+	assert.Len(t, names, 3)
+	var updated api.Pod
+	require.NoError(t, s.Get(ctx, "/txn/rs", &updated))
+	assert.Equal(t, api.PodRunning, updated.Status)
+	assert.Equal(t, updated.ResourceVersion, rs.ResourceVersion, "the written objects get the version of the transaction")
+	assert.ErrorIs(t, s.Get(ctx, "/txn/old", &TestObject{}), ErrNotFound)
+
+	var txnErr *TxnError
+	err = s.Txn(ctx, []TxnOp{CreateOp("/txn/c", &TestObject{Name: "c"}), CreateOp("/txn/b", &TestObject{Name: "b"})})
+	require.ErrorAs(t, err, &txnErr)
+	assert.Equal(t, "/txn/b", txnErr.Key)
+	assert.ErrorIs(t, err, ErrKeyExists)
+	assert.ErrorIs(t, s.Get(ctx, "/txn/c", &TestObject{}), ErrNotFound, "nothing is written when a condition fails")
+
+	stale := rs
+	stale.ResourceVersion = "1"
+	err = s.Txn(ctx, []TxnOp{CreateOp("/txn/c", &TestObject{Name: "c"}), UpdateOp("/txn/rs", &stale)})
+	require.ErrorAs(t, err, &txnErr)
+	assert.Equal(t, "/txn/rs", txnErr.Key)
+	assert.ErrorIs(t, err, ErrConflict)
+	err = s.Txn(ctx, []TxnOp{UpdateOp("/txn/missing", &rs)})
+	require.ErrorAs(t, err, &txnErr)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Get(ctx, "/txn/c", &TestObject{}), ErrNotFound)
+
+	// A replace without a resource version fails for a missing key rather than creating it
+	err = s.Txn(ctx, []TxnOp{ReplaceOp("/txn/missing", &TestObject{Name: "missing"})})
+	require.ErrorAs(t, err, &txnErr)
+	assert.Equal(t, "/txn/missing", txnErr.Key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Get(ctx, "/txn/missing", &TestObject{}), ErrNotFound)
+	require.NoError(t, s.Txn(ctx, []TxnOp{ReplaceOp("/txn/a", &TestObject{Name: "replaced"})}))
+	var replaced TestObject
+	require.NoError(t, s.Get(ctx, "/txn/a", &replaced))
+	assert.Equal(t, "replaced", replaced.Name)
+
+	// Of two deletes of the same key that must exist, the second fails
+	require.NoError(t, s.Txn(ctx, []TxnOp{DeleteExistingOp("/txn/a")}))
+	err = s.Txn(ctx, []TxnOp{DeleteExistingOp("/txn/a"), DeleteOp("/txn/b")})
+	require.ErrorAs(t, err, &txnErr)
+	assert.Equal(t, "/txn/a", txnErr.Key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, s.Get(ctx, "/txn/b", &TestObject{}), "nothing is deleted when a condition fails")
+
+	// A delete of an object as it was read fails once the object was changed
+	var current api.Pod
+	require.NoError(t, s.Get(ctx, "/txn/rs", &current))
+	err = s.Txn(ctx, []TxnOp{DeleteObjectOp("/txn/rs", &stale)})
+	require.ErrorAs(t, err, &txnErr)
+	assert.ErrorIs(t, err, ErrConflict)
+	require.NoError(t, s.Txn(ctx, []TxnOp{DeleteObjectOp("/txn/rs", &current)}))
+	assert.ErrorIs(t, s.Get(ctx, "/txn/rs", &api.Pod{}), ErrNotFound)
+	assert.ErrorIs(t, s.Txn(ctx, []TxnOp{DeleteObjectOp("/txn/rs", &current)}), ErrNotFound)
+
+	assert.ErrorIs(t, s.Txn(ctx, []TxnOp{DeleteOp("/txn/a"), DeleteOp("/txn/a")}), ErrInvalidTxn)
+	assert.ErrorIs(t, s.Txn(ctx, []TxnOp{{Op: OpGet, Key: "/txn/a"}}), ErrInvalidTxn)
+	assert.NoError(t, s.Txn(ctx, nil))
+
+	many := make([]TxnOp, 0, MaxTxnOps+1)
+	for i := 0; i < cap(many); i++ {
+		many = append(many, CreateOp(fmt.Sprintf("/txn/many/%04d", i), &TestObject{Name: strconv.Itoa(i)}))
+	}
+	assert.ErrorIs(t, s.Txn(ctx, many), ErrInvalidTxn, "more writes than etcd allows")
+	require.NoError(t, s.Txn(ctx, many[:MaxTxnOps]))
+	count, err := s.Count(ctx, "/txn/many/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(MaxTxnOps), count)
+}
+
+func TestMemoryStorage_CopiesObjects(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+
+	pod := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", Labels: map[string]string{"app": "web"}}}
+	require.NoError(t, s.Create(ctx, "/pods/web", pod))
+	pod.Labels["app"] = "changed"
+
+	var stored api.Pod
+	require.NoError(t, s.Get(ctx, "/pods/web", &stored))
+	assert.Equal(t, "web", stored.Labels["app"], "the stored object is not shared with the caller")
+}
+
+func TestMemoryStorage_WatchFromCompacted(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+	require.NoError(t, s.Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+	_, revision, err := ListWithRevisionOf[TestObject](ctx, s, "/pods/")
+	require.NoError(t, err)
+	for range 2 * MemoryHistory {
+		require.NoError(t, s.Update(ctx, "/pods/web", &TestObject{Name: "web"}))
+	}
+
+	events, err := s.WatchFrom(ctx, "/pods/", revision, func() runtime.Object { return &TestObject{} })
+	require.NoError(t, err)
+	_, ok := <-events
+	assert.False(t, ok, "a watch from a revision no longer kept is closed, for the caller to relist")
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StorageMetrics observes the requests of an EtcdStorage, e.g. to tell slow etcd requests from slow callers
+type StorageMetrics interface {
+	// ObserveRequest records a request of op on the keys of prefix that took duration and failed with err,
+	// or succeeded if err is nil
+	ObserveRequest(op Operation, prefix string, duration time.Duration, err error)
+}
+
+// SetMetrics makes the storage report its requests to metrics; nil reports nothing
+func (s *EtcdStorage) SetMetrics(metrics StorageMetrics) {
+	s.metrics = metrics
+}
+
+// observe reports a request of op on key that started at start and failed with *err, if set
+func (s *EtcdStorage) observe(op Operation, key string, start time.Time, err *error) {
+	if s.metrics != nil {
+		s.metrics.ObserveRequest(op, metricsPrefix(key), time.Since(start), *err)
+	}
+}
+
+// metricsPrefix returns the prefix of the resource key is stored under, like /registry/nodes/ for a node or
+// /pods/ for a pod, so the metrics have a series per resource rather than per object. Requests without a key,
+// like renewing a lease, have none.
+func metricsPrefix(key string) string {
+	segments := strings.SplitN(strings.TrimPrefix(key, "/"), "/", 3)
+	switch {
+	case key == "":
+		return ""
+	case segments[0] == "":
+		return "/"
+	case segments[0] == "registry" && len(segments) > 1:
+		return "/registry/" + segments[1] + "/"
+	default:
+		return "/" + segments[0] + "/"
+	}
+}
+
+// PrometheusStorageMetrics is the StorageMetrics of the gokube_storage_requests_total counter, by op, prefix
+// and result, and the gokube_storage_request_duration_seconds histogram, by op and prefix. It is a collector,
+// to be registered by the owner of the storage.
+type PrometheusStorageMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewPrometheusStorageMetrics creates a PrometheusStorageMetrics without any requests
+func NewPrometheusStorageMetrics() *PrometheusStorageMetrics {
+	return &PrometheusStorageMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gokube_storage_requests_total",
+			Help: "Number of storage requests by operation, key prefix and result.",
+		}, []string{"op", "prefix", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gokube_storage_request_duration_seconds",
+			Help:    "Duration of storage requests by operation and key prefix.",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"op", "prefix"}),
+	}
+}
+
+func (m *PrometheusStorageMetrics) ObserveRequest(op Operation, prefix string, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	name := strings.ToLower(string(op))
+	m.requests.WithLabelValues(name, prefix, result).Inc()
+	m.duration.WithLabelValues(name, prefix).Observe(duration.Seconds())
+}
+
+func (m *PrometheusStorageMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+func (m *PrometheusStorageMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+var _ StorageMetrics = &PrometheusStorageMetrics{}
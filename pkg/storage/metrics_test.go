@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestMetricsPrefix(t *testing.T) {
+	tests := map[string]string{
+		"/registry/nodes/node-1":                  "/registry/nodes/",
+		"/registry/index/pods/status/Pending/web": "/registry/index/",
+		"/registry/services/default/web":          "/registry/services/",
+		"/pods/web":                               "/pods/",
+		"/pods/":                                  "/pods/",
+		"/replicasets":                            "/replicasets/",
+		"/":                                       "/",
+		"":                                        "",
+	}
+	for key, want := range tests {
+		assert.Equal(t, want, metricsPrefix(key), key)
+	}
+}
+
+func TestEtcdStorage_Metrics(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		metrics := NewPrometheusStorageMetrics()
+		storage.SetMetrics(metrics)
+		ctx := context.Background()
+
+		require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+		var obj TestObject
+		require.NoError(t, storage.Get(ctx, "/pods/web", &obj))
+		assert.ErrorIs(t, storage.Get(ctx, "/pods/missing", &obj), ErrNotFound)
+		_, err := ListOf[TestObject](ctx, storage, "/pods/")
+		require.NoError(t, err)
+
+		expected := `
+# HELP gokube_storage_requests_total Number of storage requests by operation, key prefix and result.
+# TYPE gokube_storage_requests_total counter
+gokube_storage_requests_total{op="create",prefix="/pods/",result="success"} 1
+gokube_storage_requests_total{op="get",prefix="/pods/",result="error"} 1
+gokube_storage_requests_total{op="get",prefix="/pods/",result="success"} 1
+gokube_storage_requests_total{op="list",prefix="/pods/",result="success"} 1
+`
+		require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(expected), "gokube_storage_requests_total"))
+		assert.Equal(t, 3, testutil.CollectAndCount(metrics, "gokube_storage_request_duration_seconds"), "a histogram per op and prefix")
+	})
+}
+
+// recordingMetrics records the requests a storage observed
+type recordingMetrics struct {
+	requests []Operation
+}
+
+func (m *recordingMetrics) ObserveRequest(op Operation, prefix string, duration time.Duration, err error) {
+	m.requests = append(m.requests, op)
+}
+
+func TestEtcdStorage_MetricsOfEveryOperation(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		metrics := &recordingMetrics{}
+		storage.SetMetrics(metrics)
+		ctx := context.Background()
+
+		require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+		require.NoError(t, storage.Update(ctx, "/pods/web", &TestObject{Name: "web"}))
+		var list []*TestObject
+		_, err := storage.ListPage(ctx, "/pods/", ListOptions{Limit: 1}, &list)
+		require.NoError(t, err)
+		_, err = storage.Count(ctx, "/pods/")
+		require.NoError(t, err)
+		require.NoError(t, storage.Delete(ctx, "/pods/web"))
+		require.NoError(t, storage.DeletePrefix(ctx, "/pods/"))
+
+		assert.Equal(t, []Operation{OpCreate, OpUpdate, OpList, OpCount, OpDelete, OpDeletePrefix}, metrics.requests)
+	})
+}
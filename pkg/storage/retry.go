@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"gokube/pkg/runtime"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryConfig is how EtcdStorage retries requests that fail with a transient error, such as etcd electing a
+// leader or restarting
+type RetryConfig struct {
+	// MaxAttempts is how many times a request is sent at most, the first time included
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt. It doubles for every attempt after that.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// AttemptTimeout bounds each attempt, so a request to an etcd that is down fails and is retried rather than
+	// waiting for the caller's deadline; 0 leaves the attempts to the caller's deadline
+	AttemptTimeout time.Duration
+}
+
+// DefaultRetryConfig rides out an etcd restart of a few seconds
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	AttemptTimeout: 5 * time.Second,
+}
+
+// NewEtcdStorageWithRetry creates an EtcdStorage that retries its requests to etcd as config says while they
+// fail with a transient error. What a request answers, like ErrNotFound or ErrConflict, is never retried. A
+// write whose answer was lost may have been applied, so a retried Create can fail with ErrKeyExists and a
+// retried Update of a resource version with ErrConflict. The values are written with codec and read like
+// NewEtcdStorageWithCodec reads them.
+func NewEtcdStorageWithRetry(client *clientv3.Client, config RetryConfig, codec runtime.Codec) *EtcdStorage {
+	return &EtcdStorage{client: client, kv: &retryKV{kv: client.KV, config: config}, codec: codec, changes: newChangeFeed()}
+}
+
+// retryKV is a clientv3.KV retrying the requests of another one
+type retryKV struct {
+	kv     clientv3.KV
+	config RetryConfig
+}
+
+func (r *retryKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	resp, err := r.Do(ctx, clientv3.OpPut(key, val, opts...))
+	return resp.Put(), err
+}
+
+func (r *retryKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := r.Do(ctx, clientv3.OpGet(key, opts...))
+	return resp.Get(), err
+}
+
+func (r *retryKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	resp, err := r.Do(ctx, clientv3.OpDelete(key, opts...))
+	return resp.Del(), err
+}
+
+func (r *retryKV) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	var resp *clientv3.CompactResponse
+	err := r.retry(ctx, func(ctx context.Context) (err error) {
+		resp, err = r.kv.Compact(ctx, rev, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryKV) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	var resp clientv3.OpResponse
+	err := r.retry(ctx, func(ctx context.Context) (err error) {
+		resp, err = r.kv.Do(ctx, op)
+		return err
+	})
+	return resp, err
+}
+
+func (r *retryKV) Txn(ctx context.Context) clientv3.Txn {
+	return &retryTxn{kv: r, ctx: ctx}
+}
+
+// retryTxn collects a transaction, to send it anew on every attempt of its commit
+type retryTxn struct {
+	kv    *retryKV
+	ctx   context.Context
+	cmps  []clientv3.Cmp
+	thens []clientv3.Op
+	elses []clientv3.Op
+}
+
+func (t *retryTxn) If(cmps ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cmps...)
+	return t
+}
+
+func (t *retryTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.thens = append(t.thens, ops...)
+	return t
+}
+
+func (t *retryTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.elses = append(t.elses, ops...)
+	return t
+}
+
+func (t *retryTxn) Commit() (*clientv3.TxnResponse, error) {
+	var resp *clientv3.TxnResponse
+	err := t.kv.retry(t.ctx, func(ctx context.Context) (err error) {
+		resp, err = t.kv.kv.Txn(ctx).If(t.cmps...).Then(t.thens...).Else(t.elses...).Commit()
+		return err
+	})
+	return resp, err
+}
+
+// retry calls attempt until it succeeds, fails with an error that is not transient, the attempts run out or
+// ctx is done, backing off exponentially with jitter between attempts. It returns the error of the last attempt.
+func (r *retryKV) retry(ctx context.Context, attempt func(ctx context.Context) error) error {
+	backoff := r.config.InitialBackoff
+	for n := 1; ; n++ {
+		err := r.attempt(ctx, attempt)
+		if err == nil || n >= r.config.MaxAttempts || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// Equal jitter: wait between half the backoff and all of it, so clients failing together spread out
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, r.config.MaxBackoff)
+	}
+}
+
+func (r *retryKV) attempt(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if r.config.AttemptTimeout <= 0 {
+		return attempt(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.config.AttemptTimeout)
+	defer cancel()
+	return attempt(ctx)
+}
+
+// isTransient checks if a request failed for a reason that may go away, etcd being unavailable or too slow
+// to answer, rather than because of the request itself
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var etcdErr rpctypes.EtcdError
+	if errors.As(err, &etcdErr) {
+		return etcdErr.Code() == codes.Unavailable
+	}
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
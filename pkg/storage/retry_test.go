@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/api"
+	"gokube/pkg/runtime"
+)
+
+// flakyKV fails the next requests with err while down is above zero, as etcd does while it has no leader
+type flakyKV struct {
+	clientv3.KV
+	down     int
+	err      error
+	requests int
+}
+
+func (f *flakyKV) fail() error {
+	f.requests++
+	if f.down > 0 {
+		f.down--
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyKV) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	if err := f.fail(); err != nil {
+		return clientv3.OpResponse{}, err
+	}
+	return f.KV.Do(ctx, op)
+}
+
+func (f *flakyKV) Txn(ctx context.Context) clientv3.Txn {
+	return &flakyTxn{Txn: f.KV.Txn(ctx), kv: f}
+}
+
+type flakyTxn struct {
+	clientv3.Txn
+	kv *flakyKV
+}
+
+func (t *flakyTxn) If(cmps ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cmps...)
+	return t
+}
+
+func (t *flakyTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *flakyTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *flakyTxn) Commit() (*clientv3.TxnResponse, error) {
+	if err := t.kv.fail(); err != nil {
+		return nil, err
+	}
+	return t.Txn.Commit()
+}
+
+func TestEtcdStorageWithRetry(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		ctx := context.Background()
+		kv := &flakyKV{KV: cli.KV, err: rpctypes.ErrNoLeader}
+		storage := NewEtcdStorageWithRetry(&clientv3.Client{KV: kv}, RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     20 * time.Millisecond,
+		}, runtime.CompressedJSONCodec)
+
+		t.Run("should succeed once etcd is back within the attempts", func(t *testing.T) {
+			kv.down, kv.requests = 2, 0
+			require.NoError(t, storage.Create(ctx, "/retried/web", &TestObject{Name: "web"}))
+			var obj TestObject
+			kv.down = 2
+			require.NoError(t, storage.Get(ctx, "/retried/web", &obj))
+			assert.Equal(t, "web", obj.Name)
+			assert.Equal(t, 6, kv.requests)
+		})
+
+		t.Run("should give up after the last attempt", func(t *testing.T) {
+			kv.down, kv.requests = 3, 0
+			var obj TestObject
+			err := storage.Get(ctx, "/retried/web", &obj)
+			assert.ErrorIs(t, err, ErrEtcdClient)
+			assert.Equal(t, 3, kv.requests)
+		})
+
+		t.Run("should not retry what etcd answered", func(t *testing.T) {
+			kv.down, kv.requests = 0, 0
+			var obj TestObject
+			assert.ErrorIs(t, storage.Get(ctx, "/retried/missing", &obj), ErrNotFound)
+			assert.ErrorIs(t, storage.Create(ctx, "/retried/web", &TestObject{Name: "web"}), ErrKeyExists)
+			stale := &api.Pod{ObjectMeta: api.ObjectMeta{Name: "web", ResourceVersion: "1"}}
+			assert.ErrorIs(t, storage.Update(ctx, "/retried/web", stale), ErrConflict)
+			assert.Equal(t, 3, kv.requests)
+
+			kv.down, kv.requests, kv.err = 1, 0, rpctypes.ErrPermissionDenied
+			assert.ErrorIs(t, storage.Get(ctx, "/retried/web", &obj), ErrEtcdClient)
+			assert.Equal(t, 1, kv.requests, "a request etcd rejects fails the same way again")
+		})
+
+		t.Run("should stop retrying when the caller gives up", func(t *testing.T) {
+			kv.down, kv.requests, kv.err = 3, 0, rpctypes.ErrNoLeader
+			ctx, cancel := context.WithCancel(ctx)
+			cancel()
+			var obj TestObject
+			assert.Error(t, storage.Get(ctx, "/retried/web", &obj))
+			assert.Equal(t, 1, kv.requests)
+		})
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.True(t, isTransient(rpctypes.ErrNoLeader))
+	assert.True(t, isTransient(rpctypes.ErrGRPCLeaderChanged), "as gRPC sends it")
+	assert.True(t, isTransient(context.DeadlineExceeded))
+	assert.False(t, isTransient(rpctypes.ErrPermissionDenied))
+	assert.False(t, isTransient(context.Canceled))
+	assert.False(t, isTransient(errors.New("etcdserver: mvcc: required revision has been compacted")))
+}
@@ -2,6 +2,11 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 
 	"gokube/pkg/runtime"
 )
@@ -12,8 +17,185 @@ import (
 type Storage interface {
 	Create(ctx context.Context, key string, obj runtime.Object) error
 	Get(ctx context.Context, key string, obj runtime.Object) error
+	// Exists reports whether a value is stored under key, without reading or decoding it
+	Exists(ctx context.Context, key string) (bool, error)
 	Update(ctx context.Context, key string, obj runtime.Object) error
+	// Delete deletes key, failing with ErrNotFound if there is nothing to delete
 	Delete(ctx context.Context, key string) error
 	DeletePrefix(ctx context.Context, prefix string) error
 	List(ctx context.Context, prefix string, listObj interface{}) error
+	// ListFunc decodes each value under prefix into an object returned by newItem and hands it to appendItem.
+	// Values that fail to decode are skipped and reported in a *CorruptValuesError, returned once the others
+	// were handed over.
+	ListFunc(ctx context.Context, prefix string, newItem func() runtime.Object, appendItem func(runtime.Object)) error
+	// ListPage is List for the page of the values under prefix that options select. It returns the continue
+	// token of the next page, "" after the last one.
+	ListPage(ctx context.Context, prefix string, options ListOptions, listObj interface{}) (string, error)
+	// ListPageFunc is ListFunc for the page of the values under prefix that options select. It returns the
+	// continue token of the next page and the revision the page was read at.
+	ListPageFunc(ctx context.Context, prefix string, options ListOptions, newItem func() runtime.Object, appendItem func(runtime.Object)) (ListResult, error)
+	// ListKeys returns the keys under prefix in key order, without reading their values
+	ListKeys(ctx context.Context, prefix string) ([]string, error)
+	// Count returns how many values are stored under prefix, without reading them
+	Count(ctx context.Context, prefix string) (int64, error)
+	// Txn makes the writes of ops all at once, or none of them if the condition of any fails, which it reports
+	// as a *TxnError naming the key
+	Txn(ctx context.Context, ops []TxnOp) error
+	// Watch follows the changes of the keys under prefix from now on, decoding each value into an object returned
+	// by newItem. The channel is closed when ctx is done or the watch ends, after which the caller relists and
+	// watches again.
+	Watch(ctx context.Context, prefix string, newItem func() runtime.Object) (<-chan ObjectEvent, error)
+	// WatchFrom is Watch from the changes made after revision, such as that of a list, so a list followed by a
+	// watch misses no change and sees none twice. The channel is closed right away if the changes after
+	// revision were compacted.
+	WatchFrom(ctx context.Context, prefix string, revision int64, newItem func() runtime.Object) (<-chan ObjectEvent, error)
+}
+
+// ListResult describes a page of a list
+type ListResult struct {
+	// Continue is the token of the next page, "" after the last one
+	Continue string
+	// Revision is the revision the page was read at; a WatchFrom it sees every change made since
+	Revision int64
+}
+
+// ListOptions select a page of a list
+type ListOptions struct {
+	// Limit is the most values a page holds; 0 means no limit
+	Limit int64
+	// Continue is the token returned with the previous page, "" for the first page
+	Continue string
+	// Strict fails the list with ErrDecoding at the first value that fails to decode, handing nothing over,
+	// rather than skipping the corrupt values and reporting them in a *CorruptValuesError
+	Strict bool
+}
+
+// CorruptValue is a stored value that failed to decode, e.g. because it was edited by hand or truncated
+type CorruptValue struct {
+	Key string
+	Err error
+}
+
+// CorruptValuesError is returned by a list that skipped corrupt values, along with the values that decoded,
+// so a single bad value does not hide all the others. It wraps ErrDecoding.
+type CorruptValuesError struct {
+	Values []CorruptValue
+}
+
+func (e *CorruptValuesError) Error() string {
+	messages := make([]string, 0, len(e.Values))
+	for _, value := range e.Values {
+		messages = append(messages, fmt.Sprintf("%s: %v", value.Key, value.Err))
+	}
+	return fmt.Sprintf("%d corrupt values skipped: %s", len(e.Values), strings.Join(messages, "; "))
+}
+
+func (e *CorruptValuesError) Unwrap() error {
+	return ErrDecoding
+}
+
+// corruptValues collects the values a list skips, and returns them as the error of the list
+type corruptValues []CorruptValue
+
+// skip records the value of key as corrupt, unless options are strict, when it returns err to fail the list
+func (c *corruptValues) skip(options ListOptions, key string, err error) error {
+	if options.Strict {
+		return err
+	}
+	*c = append(*c, CorruptValue{Key: key, Err: err})
+	return nil
+}
+
+func (c corruptValues) err() error {
+	if len(c) == 0 {
+		return nil
+	}
+	return &CorruptValuesError{Values: c}
+}
+
+// isCorruptValues checks if err only reports skipped corrupt values, so the items of the list are good
+func isCorruptValues(err error) bool {
+	var corrupt *CorruptValuesError
+	return errors.As(err, &corrupt)
+}
+
+// ListOf returns the objects under prefix, decoded as T. Unlike List it needs no reflection. The objects that
+// decoded are returned along with a *CorruptValuesError for those that did not.
+func ListOf[T any](ctx context.Context, s Storage, prefix string) ([]*T, error) {
+	items := make([]*T, 0)
+	err := s.ListFunc(ctx, prefix, func() runtime.Object { return new(T) }, func(obj runtime.Object) {
+		items = append(items, obj.(*T))
+	})
+	if err != nil && !isCorruptValues(err) {
+		return nil, err
+	}
+	return items, err
+}
+
+// ListPageOf returns the page of the objects under prefix that options select, decoded as T, and the continue
+// token of the next page, "" after the last one. Like ListOf, the objects that decoded are returned along with
+// a *CorruptValuesError for those that did not.
+func ListPageOf[T any](ctx context.Context, s Storage, prefix string, options ListOptions) ([]*T, string, error) {
+	items := make([]*T, 0)
+	result, err := s.ListPageFunc(ctx, prefix, options, func() runtime.Object { return new(T) }, func(obj runtime.Object) {
+		items = append(items, obj.(*T))
+	})
+	if err != nil && !isCorruptValues(err) {
+		return nil, "", err
+	}
+	return items, result.Continue, err
+}
+
+// ListWithRevisionOf is ListOf that also returns the revision the objects were read at, to watch their changes
+// from with WatchFrom
+func ListWithRevisionOf[T any](ctx context.Context, s Storage, prefix string) ([]*T, int64, error) {
+	items := make([]*T, 0)
+	result, err := s.ListPageFunc(ctx, prefix, ListOptions{}, func() runtime.Object { return new(T) }, func(obj runtime.Object) {
+		items = append(items, obj.(*T))
+	})
+	if err != nil && !isCorruptValues(err) {
+		return nil, 0, err
+	}
+	return items, result.Revision, err
+}
+
+// encodeContinue returns the continue token of a page starting at key
+func encodeContinue(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeContinue returns the key the page of token starts at, the first key under prefix without a token. A
+// token not made for a list of prefix fails with ErrInvalidContinue.
+func decodeContinue(prefix, token string) (string, error) {
+	if token == "" {
+		return prefix, nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(key), prefix) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidContinue, token)
+	}
+	return string(key), nil
+}
+
+// listInto implements List on top of listFunc, a ListFunc bound to the prefix. The items are appended to the
+// slice listObj points to, which is left untouched on errors other than a *CorruptValuesError.
+func listInto(listObj interface{}, listFunc func(newItem func() runtime.Object, appendItem func(runtime.Object)) error) error {
+	listValue := reflect.ValueOf(listObj)
+	if listValue.Kind() != reflect.Pointer || listValue.IsNil() || listValue.Elem().Kind() != reflect.Slice ||
+		listValue.Elem().Type().Elem().Kind() != reflect.Pointer {
+		return fmt.Errorf("%w, got %T", ErrInvalidListObject, listObj)
+	}
+
+	items := listValue.Elem()
+	itemType := items.Type().Elem().Elem()
+	err := listFunc(func() runtime.Object {
+		return reflect.New(itemType).Interface()
+	}, func(obj runtime.Object) {
+		items = reflect.Append(items, reflect.ValueOf(obj))
+	})
+	if err != nil && !isCorruptValues(err) {
+		return err
+	}
+	listValue.Elem().Set(items)
+	return err
 }
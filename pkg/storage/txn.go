@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+
+	"gokube/pkg/runtime"
+)
+
+// TxnOp is a write of a transaction: OpCreate, OpUpdate or OpDelete of a key. Each write has the conditions
+// of the method of the same name, and fails the whole transaction if they do not hold.
+type TxnOp struct {
+	Op  Operation
+	Key string
+	// Object is what OpCreate and OpUpdate store. Once the transaction succeeds, its resource version is that
+	// of the transaction. For OpDelete it is optional, and only its resource version is looked at.
+	Object runtime.Object
+	// MustExist makes OpUpdate and OpDelete fail the transaction if the key does not exist, even for an Object
+	// without a resource version
+	MustExist bool
+}
+
+// CreateOp creates obj under key, failing the transaction if the key is taken
+func CreateOp(key string, obj runtime.Object) TxnOp {
+	return TxnOp{Op: OpCreate, Key: key, Object: obj}
+}
+
+// UpdateOp stores obj under key, failing the transaction if obj has a resource version and the key was changed
+// since or deleted
+func UpdateOp(key string, obj runtime.Object) TxnOp {
+	return TxnOp{Op: OpUpdate, Key: key, Object: obj}
+}
+
+// ReplaceOp stores obj under key like UpdateOp, and also fails the transaction if the key does not exist, so
+// an update racing with a delete does not recreate the key
+func ReplaceOp(key string, obj runtime.Object) TxnOp {
+	return TxnOp{Op: OpUpdate, Key: key, Object: obj, MustExist: true}
+}
+
+// DeleteOp deletes key, if it exists
+func DeleteOp(key string) TxnOp {
+	return TxnOp{Op: OpDelete, Key: key}
+}
+
+// DeleteExistingOp deletes key like DeleteOp, and fails the transaction if the key does not exist, so of two
+// concurrent deletes only one succeeds
+func DeleteExistingOp(key string) TxnOp {
+	return TxnOp{Op: OpDelete, Key: key, MustExist: true}
+}
+
+// DeleteObjectOp deletes key like DeleteExistingOp, and also fails the transaction if obj has a resource version
+// and the key was changed since, so an object is only deleted as it was read
+func DeleteObjectOp(key string, obj runtime.Object) TxnOp {
+	return TxnOp{Op: OpDelete, Key: key, Object: obj, MustExist: true}
+}
+
+// MaxTxnOps is how many writes a transaction may hold, the limit of an etcd run with its defaults. The memory
+// storage holds transactions to it as well, so what works against one works against any etcd.
+const MaxTxnOps = 128
+
+var (
+	// ErrInvalidTxn is returned by Txn for a transaction that cannot be sent, such as one writing a key twice
+	ErrInvalidTxn = fmt.Errorf("invalid transaction")
+)
+
+// TxnError is returned by Txn when the condition of one of its writes does not hold, so none of them was made
+type TxnError struct {
+	// Key is the key of the write
+	Key string
+	// Err is why the write could not be made: ErrKeyExists, ErrConflict or ErrNotFound
+	Err error
+}
+
+func (e *TxnError) Error() string {
+	return fmt.Sprintf("transaction failed on %s: %v", e.Key, e.Err)
+}
+
+func (e *TxnError) Unwrap() error {
+	return e.Err
+}
+
+// checkTxn checks that ops can be sent as a transaction: no more than MaxTxnOps known writes, objects for the
+// ones storing them, and every key written once, as etcd demands
+func checkTxn(ops []TxnOp) error {
+	if len(ops) > MaxTxnOps {
+		return fmt.Errorf("%w: %d writes, more than the %d allowed", ErrInvalidTxn, len(ops), MaxTxnOps)
+	}
+	keys := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		switch {
+		case op.Op != OpCreate && op.Op != OpUpdate && op.Op != OpDelete:
+			return fmt.Errorf("%w: %s of %s is not a write", ErrInvalidTxn, op.Op, op.Key)
+		case op.Op != OpDelete && op.Object == nil:
+			return fmt.Errorf("%w: %s of %s without an object", ErrInvalidTxn, op.Op, op.Key)
+		case keys[op.Key]:
+			return fmt.Errorf("%w: %s is written twice", ErrInvalidTxn, op.Key)
+		}
+		keys[op.Key] = true
+	}
+	return nil
+}
+
+// txnCondition checks the condition of op against its key, which has been changed last at modRevision if it
+// exists. It returns the *TxnError failing the transaction, or nil if the condition holds.
+func txnCondition(op TxnOp, exists bool, modRevision int64) error {
+	switch op.Op {
+	case OpCreate:
+		if exists {
+			return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s", ErrKeyExists, op.Key)}
+		}
+	case OpUpdate:
+		version := resourceVersion(op.Object)
+		switch {
+		case !exists && (version != "" || op.MustExist):
+			return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s", ErrNotFound, op.Key)}
+		case version == "":
+		case strconv.FormatInt(modRevision, 10) != version:
+			return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s was changed since version %s", ErrConflict, op.Key, version)}
+		}
+	case OpDelete:
+		var version string
+		if op.Object != nil {
+			version = resourceVersion(op.Object)
+		}
+		switch {
+		case !exists && op.MustExist:
+			return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s", ErrNotFound, op.Key)}
+		case exists && version != "" && strconv.FormatInt(modRevision, 10) != version:
+			return &TxnError{Key: op.Key, Err: fmt.Errorf("%w: %s was changed since version %s", ErrConflict, op.Key, version)}
+		}
+	}
+	return nil
+}
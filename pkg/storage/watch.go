@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gokube/pkg/runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/namespace"
+)
+
+// DefaultWatchBufferSize is how many events a watcher may fall behind before it is desynced
+const DefaultWatchBufferSize = 100
+
+// WatchEventType is the kind of change a storage watch event reports
+type WatchEventType string
+
+const (
+	WatchPut    WatchEventType = "PUT"
+	WatchDelete WatchEventType = "DELETE"
+	// WatchDesync tells a watcher that fell behind that its events were dropped. It must relist, at Revision
+	// or later, before following the watch again.
+	WatchDesync WatchEventType = "DESYNC"
+)
+
+// WatchEvent is a change of a key, or a desync notice
+type WatchEvent struct {
+	Type WatchEventType
+	Key  string
+	// Value is the new value of the key as the codec of the storage encoded it, empty for WatchDelete
+	Value []byte
+	// PrevValue is the value a WatchDelete removed, for watches that ask for it
+	PrevValue []byte
+	// Created reports whether a WatchPut created the key rather than changed its value
+	Created bool
+	// Revision is the etcd revision of the change
+	Revision int64
+}
+
+// ObjectEvent is a change of a key with its value decoded, as Storage.Watch reports it
+type ObjectEvent struct {
+	// Type is WatchPut or WatchDelete
+	Type WatchEventType
+	Key  string
+	// Created reports whether a WatchPut created the key rather than changed its value
+	Created bool
+	// Object is the new object for WatchPut and the removed one for WatchDelete, with Revision as its resource
+	// version. It is left as newItem made it if the removed value is no longer known.
+	Object   runtime.Object
+	Revision int64
+}
+
+// decodeEvent decodes the value of event, or the value it removed for WatchDelete, into an object from newItem
+func decodeEvent(event WatchEvent, decode func(data []byte, revision int64, obj runtime.Object) error, newItem func() runtime.Object) (ObjectEvent, error) {
+	object := ObjectEvent{Type: event.Type, Key: event.Key, Created: event.Created, Object: newItem(), Revision: event.Revision}
+	data := event.Value
+	if event.Type == WatchDelete {
+		data = event.PrevValue
+	}
+	if len(data) == 0 {
+		setResourceVersion(object.Object, event.Revision)
+		return object, nil
+	}
+	return object, decode(data, event.Revision, object.Object)
+}
+
+// Broadcaster fans the events of a single source, such as an etcd watch, out to any number of watchers.
+// Delivery never blocks the source: each watcher has a bounded buffer, and a watcher whose buffer is full
+// loses the events in it and gets a WatchDesync event instead, so one stalled consumer cannot hold up the
+// others.
+type Broadcaster struct {
+	bufferSize int
+	// mutex guards watchers and closed, and makes Broadcast and Stop exclusive, so no event is sent on a
+	// closed channel
+	mutex    sync.Mutex
+	watchers map[*Watcher]struct{}
+	closed   bool
+	dropped  atomic.Uint64
+}
+
+// NewBroadcaster creates a Broadcaster whose watchers buffer up to bufferSize events, at least 1
+func NewBroadcaster(bufferSize int) *Broadcaster {
+	return &Broadcaster{
+		bufferSize: max(bufferSize, 1),
+		watchers:   make(map[*Watcher]struct{}),
+	}
+}
+
+// Watcher receives the events of a Broadcaster from the time it was added
+type Watcher struct {
+	broadcaster *Broadcaster
+	events      chan WatchEvent
+}
+
+// Watch adds a watcher, which is stopped when ctx is done, the Broadcaster is closed or Stop is called
+func (b *Broadcaster) Watch(ctx context.Context) *Watcher {
+	w := &Watcher{broadcaster: b, events: make(chan WatchEvent, b.bufferSize)}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		close(w.events)
+		return w
+	}
+	b.watchers[w] = struct{}{}
+	context.AfterFunc(ctx, w.Stop)
+	return w
+}
+
+// ResultChan returns the events of the watcher. It is closed once the watcher is stopped.
+func (w *Watcher) ResultChan() <-chan WatchEvent {
+	return w.events
+}
+
+// Stop removes the watcher from its Broadcaster and closes its channel. It may be called more than once.
+func (w *Watcher) Stop() {
+	b := w.broadcaster
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.watchers[w]; ok {
+		delete(b.watchers, w)
+		close(w.events)
+	}
+}
+
+// Broadcast hands event to every watcher without waiting for any. The events a watcher has not read yet are
+// dropped if its buffer is full, and replaced by a WatchDesync event at the revision of event, which a later
+// overflow replaces in turn. Broadcast must be called from a single goroutine, so watchers see the events in
+// order.
+func (b *Broadcaster) Broadcast(event WatchEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for w := range b.watchers {
+		select {
+		case w.events <- event:
+			continue
+		default:
+		}
+
+		dropped := uint64(1)
+	drain:
+		for {
+			select {
+			case old := <-w.events:
+				if old.Type != WatchDesync {
+					dropped++
+				}
+			default:
+				break drain
+			}
+		}
+		// Only Broadcast sends, and the buffer was just emptied, so this does not block
+		w.events <- WatchEvent{Type: WatchDesync, Revision: event.Revision}
+		b.dropped.Add(dropped)
+	}
+}
+
+// Close stops all watchers; watchers added later are stopped right away
+func (b *Broadcaster) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	for w := range b.watchers {
+		delete(b.watchers, w)
+		close(w.events)
+	}
+}
+
+// Dropped returns the number of events dropped because watchers fell behind
+func (b *Broadcaster) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Metrics returns the collector of the gokube_watch_events_dropped_total counter, to be registered by the
+// owner of the Broadcaster
+func (b *Broadcaster) Metrics() prometheus.Collector {
+	return prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "gokube_watch_events_dropped_total",
+		Help: "Number of watch events dropped because a watcher fell behind.",
+	}, func() float64 { return float64(b.dropped.Load()) })
+}
+
+// EtcdWatchHistory is how many changes an EtcdStorage keeps at least for WatchFrom
+const EtcdWatchHistory = 1000
+
+// changeFeed is the single etcd watch of an EtcdStorage. Every watch of the storage follows it through its
+// Broadcaster rather than opening an etcd watch of its own, so a watcher that falls behind is desynced
+// instead of holding up etcd. The feed is started by the first watch and keeps the last changes, so a watch
+// from a recent revision replays them.
+type changeFeed struct {
+	broadcaster *Broadcaster
+	// mutex guards the fields below, and is held while a change is recorded and broadcast, so no change
+	// falls between the replayed and the watched ones
+	mutex   sync.Mutex
+	running bool
+	// history holds the last changes; from is the revision of the latest change that is not in it
+	history []WatchEvent
+	from    int64
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{broadcaster: NewBroadcaster(DefaultWatchBufferSize)}
+}
+
+// record keeps event in the history and tells the watchers. The caller holds the mutex.
+func (f *changeFeed) record(event WatchEvent) {
+	// The oldest changes are dropped in bulk rather than one by one, so a change copies no history
+	if len(f.history) == 2*EtcdWatchHistory {
+		f.from = f.history[EtcdWatchHistory-1].Revision
+		f.history = append(f.history[:0], f.history[EtcdWatchHistory:]...)
+	}
+	f.history = append(f.history, event)
+	f.broadcaster.Broadcast(event)
+}
+
+// followChanges adds a watcher of the change feed, starting it if it is not running, and returns the changes
+// after revision it missed. ok is false for a revision before the changes the feed has.
+func (s *EtcdStorage) followChanges(ctx context.Context, revision int64) (watcher *Watcher, replay []WatchEvent, ok bool, err error) {
+	f := s.changes
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.running {
+		from := revision
+		if from == 0 {
+			resp, err := s.kv.Get(ctx, "/", clientv3.WithCountOnly())
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("%w: %v", ErrEtcdClient, err)
+			}
+			from = resp.Header.Revision
+		}
+		// The feed outlives the watch that started it, and ends with the client or a failed etcd watch
+		changes := s.watcher().Watch(clientv3.WithRequireLeader(context.Background()), "", clientv3.WithPrefix(),
+			clientv3.WithPrevKV(), clientv3.WithRev(from+1))
+		f.running, f.history, f.from = true, nil, from
+		go s.feedChanges(changes)
+	}
+	if revision > 0 && revision < f.from {
+		return nil, nil, false, nil
+	}
+	if revision > 0 {
+		// The history changes as the feed goes on, so the replay is a copy
+		for _, event := range f.history {
+			if event.Revision > revision {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return f.broadcaster.Watch(ctx), replay, true, nil
+}
+
+// feedChanges hands the changes of the etcd watch to the change feed until the watch ends. Its watchers are
+// then desynced, to relist and watch again, which starts a new etcd watch.
+func (s *EtcdStorage) feedChanges(changes clientv3.WatchChan) {
+	f := s.changes
+	for resp := range changes {
+		if resp.Err() != nil {
+			break
+		}
+		f.mutex.Lock()
+		for _, ev := range resp.Events {
+			f.record(watchEvent(ev))
+		}
+		f.mutex.Unlock()
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.running = false
+	f.broadcaster.Broadcast(WatchEvent{Type: WatchDesync})
+}
+
+// WatchMetrics returns the collector of the number of watch events dropped because watchers fell behind, to
+// be registered by the owner of the storage
+func (s *EtcdStorage) WatchMetrics() prometheus.Collector {
+	return s.changes.broadcaster.Metrics()
+}
+
+// Watch follows the changes of the keys under prefix from now on, decoding each value into an object returned by
+// newItem. Deletes carry the removed object, which etcd sends with the event. Values that fail to decode are
+// skipped. The channel is closed when ctx is done, the etcd watch fails or the caller falls more than
+// DefaultWatchBufferSize changes behind, after which the caller relists and watches again.
+func (s *EtcdStorage) Watch(ctx context.Context, prefix string, newItem func() runtime.Object) (<-chan ObjectEvent, error) {
+	return s.WatchFrom(ctx, prefix, 0, newItem)
+}
+
+// WatchFrom is Watch from the changes made after revision, or from now on for revision 0. Only the changes since
+// the first watch are kept, at least EtcdWatchHistory of them; a watch from before them is closed right away, as
+// etcd closes a watch from a compacted revision.
+func (s *EtcdStorage) WatchFrom(ctx context.Context, prefix string, revision int64, newItem func() runtime.Object) (<-chan ObjectEvent, error) {
+	watcher, replay, ok, err := s.followChanges(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		objects := make(chan ObjectEvent)
+		close(objects)
+		return objects, nil
+	}
+	return follow(ctx, watcher, replay, prefix, revision, s.decodeValue, newItem), nil
+}
+
+// follow sends the changes after revision of replay, then those of watcher, of the keys under prefix to the
+// returned channel as objects from newItem. Values that fail to decode are skipped. The channel is closed when ctx
+// is done or the watcher is stopped or desynced.
+func follow(ctx context.Context, watcher *Watcher, replay []WatchEvent, prefix string, revision int64, decode func(data []byte, revision int64, obj runtime.Object) error, newItem func() runtime.Object) <-chan ObjectEvent {
+	objects := make(chan ObjectEvent)
+	send := func(event WatchEvent) bool {
+		// The watcher may still get changes made before revision, when the etcd watch lags behind a list
+		if !strings.HasPrefix(event.Key, prefix) || event.Revision <= revision {
+			return true
+		}
+		object, err := decodeEvent(event, decode, newItem)
+		if err != nil {
+			return true
+		}
+		select {
+		case objects <- object:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(objects)
+		defer watcher.Stop()
+		for _, event := range replay {
+			if !send(event) {
+				return
+			}
+		}
+		for event := range watcher.ResultChan() {
+			if event.Type == WatchDesync || !send(event) {
+				return
+			}
+		}
+	}()
+	return objects
+}
+
+// watcher returns the etcd watcher of the keys of the storage, under its root prefix
+func (s *EtcdStorage) watcher() clientv3.Watcher {
+	if s.root != "" {
+		return namespace.NewWatcher(s.client.Watcher, s.root)
+	}
+	return s.client.Watcher
+}
+
+// watchEvent converts an etcd event, with the value it removed if the watch asked for it
+func watchEvent(ev *clientv3.Event) WatchEvent {
+	event := WatchEvent{Type: WatchPut, Key: string(ev.Kv.Key), Value: ev.Kv.Value, Created: ev.IsCreate(), Revision: ev.Kv.ModRevision}
+	if ev.Type == clientv3.EventTypeDelete {
+		event.Type = WatchDelete
+		if ev.PrevKv != nil {
+			event.PrevValue = ev.PrevKv.Value
+		}
+	}
+	return event
+}
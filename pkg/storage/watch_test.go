@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"gokube/pkg/runtime"
+)
+
+// receive returns the next event of w, failing the test if none arrives in time
+func receive(t *testing.T, w *Watcher) WatchEvent {
+	t.Helper()
+	select {
+	case event, ok := <-w.ResultChan():
+		require.True(t, ok, "the watcher was stopped")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return WatchEvent{}
+	}
+}
+
+func TestBroadcaster_DesyncsSlowWatcher(t *testing.T) {
+	b := NewBroadcaster(3)
+	ctx := context.Background()
+	fast, slow := b.Watch(ctx), b.Watch(ctx)
+
+	// The slow watcher reads nothing while the fast one keeps up
+	for revision := int64(1); revision <= 10; revision++ {
+		b.Broadcast(WatchEvent{Type: WatchPut, Key: "/pods/web", Revision: revision})
+		assert.Equal(t, revision, receive(t, fast).Revision)
+	}
+
+	// Only the latest desync is left, at the revision of the last dropped event
+	assert.Equal(t, WatchEvent{Type: WatchDesync, Revision: 10}, receive(t, slow))
+	assert.Equal(t, uint64(10), b.Dropped())
+
+	// Once it caught up, the slow watcher gets the events again
+	b.Broadcast(WatchEvent{Type: WatchDelete, Key: "/pods/web", Revision: 11})
+	assert.Equal(t, WatchEvent{Type: WatchDelete, Key: "/pods/web", Revision: 11}, receive(t, slow))
+	assert.Equal(t, int64(11), receive(t, fast).Revision)
+}
+
+func TestBroadcaster_StopsWatchers(t *testing.T) {
+	b := NewBroadcaster(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, stopped, closed := b.Watch(ctx), b.Watch(context.Background()), b.Watch(context.Background())
+
+	cancel()
+	stopped.Stop()
+	stopped.Stop()
+	require.Eventually(t, func() bool {
+		_, ok := <-cancelled.ResultChan()
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+	_, ok := <-stopped.ResultChan()
+	assert.False(t, ok)
+
+	b.Close()
+	_, ok = <-closed.ResultChan()
+	assert.False(t, ok)
+	_, ok = <-b.Watch(context.Background()).ResultChan()
+	assert.False(t, ok, "a watcher added after Close should be stopped")
+	b.Broadcast(WatchEvent{Type: WatchPut, Revision: 1})
+}
+
+func TestEtcdStorage_WatchesShareOneFeed(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		newItem := func() runtime.Object { return &TestObject{} }
+		fast, err := storage.Watch(ctx, "/pods/", newItem)
+		require.NoError(t, err)
+		slow, err := storage.Watch(ctx, "/pods/", newItem)
+		require.NoError(t, err)
+		nodes, err := storage.Watch(ctx, "/nodes/", newItem)
+		require.NoError(t, err)
+		// The fast watch is read as the changes come in, into a channel that holds them all
+		fastEvents := make(chan ObjectEvent, 2*DefaultWatchBufferSize)
+		go func() {
+			for event := range fast {
+				fastEvents <- event
+			}
+			close(fastEvents)
+		}()
+
+		// The slow watch reads nothing while more changes are made than it may fall behind
+		var keys []string
+		for i := range DefaultWatchBufferSize + 20 {
+			key := fmt.Sprintf("/pods/pod-%03d", i)
+			keys = append(keys, key)
+			require.NoError(t, storage.Create(ctx, key, &TestObject{Name: key}))
+		}
+		require.NoError(t, storage.Create(ctx, "/nodes/node-1", &TestObject{Name: "node-1"}))
+
+		next := func(events <-chan ObjectEvent) ObjectEvent {
+			select {
+			case event, ok := <-events:
+				require.True(t, ok, "the watch ended")
+				return event
+			case <-time.After(5 * time.Second):
+				t.Fatal("no event received")
+				return ObjectEvent{}
+			}
+		}
+		var received []string
+		for len(received) < len(keys) {
+			received = append(received, next(fastEvents).Key)
+		}
+		assert.Equal(t, keys, received, "the fast watch should get every change in order")
+		assert.Equal(t, "/nodes/node-1", next(nodes).Key, "each watch gets the changes of its prefix")
+
+		var behind int
+		for range slow {
+			behind++
+		}
+		assert.Less(t, behind, len(keys), "the slow watch should be closed once it fell behind")
+		assert.Positive(t, storage.changes.broadcaster.Dropped())
+	})
+}
+
+func TestEtcdStorage_WatchFromBeforeFeed(t *testing.T) {
+	TestWithEmbeddedEtcd(t, func(t *testing.T, cli *clientv3.Client) {
+		storage := NewEtcdStorage(cli)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		newItem := func() runtime.Object { return &TestObject{} }
+
+		require.NoError(t, storage.Create(ctx, "/pods/web", &TestObject{Name: "web"}))
+		_, revision, err := ListWithRevisionOf[TestObject](ctx, storage, "/pods/")
+		require.NoError(t, err)
+		require.NoError(t, storage.Update(ctx, "/pods/web", &TestObject{Name: "web-2"}))
+		_, err = storage.Watch(ctx, "/pods/", newItem)
+		require.NoError(t, err)
+
+		events, err := storage.WatchFrom(ctx, "/pods/", revision, newItem)
+		require.NoError(t, err)
+		_, ok := <-events
+		assert.False(t, ok, "a watch from before the changes the storage keeps is closed, for the caller to relist")
+	})
+}
@@ -0,0 +1,482 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+
+	"gokube/pkg/api"
+	"gokube/pkg/api/server"
+	"gokube/pkg/client"
+	"gokube/pkg/client/informers"
+	"gokube/pkg/controller"
+	"gokube/pkg/kubelet"
+	"gokube/pkg/kubelet/container/fakeruntime"
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+	"gokube/pkg/scheduler"
+	"gokube/pkg/storage"
+)
+
+// Runtime is the container runtime the kubelets of a cluster run pods with
+type Runtime string
+
+const (
+	// RuntimeFake runs pods in memory, so tests need no Docker daemon
+	RuntimeFake Runtime = "fake"
+	// RuntimeDocker runs real containers with the Docker daemon of the environment
+	RuntimeDocker Runtime = "docker"
+)
+
+const (
+	// DefaultKubelets is the number of nodes of a cluster when no number is configured
+	DefaultKubelets = 3
+	// DefaultInterval is how often the components loop when no interval is configured: short, so a cluster
+	// converges in seconds
+	DefaultInterval = time.Second
+	// DefaultStartTimeout is how long Start waits for the nodes to be ready when no timeout is configured
+	DefaultStartTimeout = 30 * time.Second
+	// cleanupTimeout bounds how long Cleanup waits for the servers to shut down
+	cleanupTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid cluster options")
+	ErrStartFailed    = errors.New("failed to start cluster")
+)
+
+// Options configures the components of a test cluster
+type Options struct {
+	// Kubelets is the number of nodes, named node-0, node-1 and so on
+	Kubelets int
+	Runtime  Runtime
+	// KubeletInterval is how often the kubelets report the node status, relist and sync their pods
+	KubeletInterval time.Duration
+	// SchedulingRate is how often the scheduler runs
+	SchedulingRate time.Duration
+	// ResyncPeriod is how often the ReplicaSet controller reconciles when no pod changed
+	ResyncPeriod time.Duration
+	// EventTTL is how long events are kept after they last happened; zero keeps them for
+	// controller.DefaultEventTTL. Expired events are deleted every ResyncPeriod.
+	EventTTL time.Duration
+	// StartTimeout is how long Start waits for the API server and the nodes to be ready
+	StartTimeout time.Duration
+	// WrapStorage, if set, wraps the storage the API server and the control plane use, e.g. in a
+	// storage.ChaosStorage to inject faults
+	WrapStorage func(storage.Storage) storage.Storage
+	// Logger, if set, receives the logs of the components instead of the default logger
+	Logger *slog.Logger
+}
+
+// DefaultOptions returns the options of a cluster of DefaultKubelets nodes with a fake runtime
+func DefaultOptions() Options {
+	return Options{
+		Kubelets:        DefaultKubelets,
+		Runtime:         RuntimeFake,
+		KubeletInterval: DefaultInterval,
+		SchedulingRate:  DefaultInterval,
+		ResyncPeriod:    DefaultInterval,
+		StartTimeout:    DefaultStartTimeout,
+	}
+}
+
+// Validate checks that the options are within sane bounds
+func (o Options) Validate() error {
+	if o.Kubelets < 0 {
+		return fmt.Errorf("%w: kubelets must not be negative, got %d", ErrInvalidOptions, o.Kubelets)
+	}
+	if o.Runtime != RuntimeFake && o.Runtime != RuntimeDocker {
+		return fmt.Errorf("%w: runtime must be %q or %q, got %q", ErrInvalidOptions, RuntimeFake, RuntimeDocker, o.Runtime)
+	}
+	if o.SchedulingRate <= 0 {
+		return fmt.Errorf("%w: scheduling rate must be positive, got %v", ErrInvalidOptions, o.SchedulingRate)
+	}
+	if o.StartTimeout <= 0 {
+		return fmt.Errorf("%w: start timeout must be positive, got %v", ErrInvalidOptions, o.StartTimeout)
+	}
+	if o.EventTTL < 0 {
+		return fmt.Errorf("%w: event TTL must not be negative, got %v", ErrInvalidOptions, o.EventTTL)
+	}
+	// The kubelet and controller options check the other intervals
+	return nil
+}
+
+// Cluster is a gokube cluster in one process: embedded etcd, the API server, the ReplicaSet controller, the
+// scheduler and a kubelet per node
+type Cluster struct {
+	options    Options
+	etcdServer *embed.Etcd
+	etcdURL    string
+	etcdClient *clientv3.Client
+	storage    *storage.EtcdStorage
+	// serving is the storage of the API server and the control plane: storage, wrapped by WrapStorage
+	serving      storage.Storage
+	apiServer    *http.Server
+	apiServerURL string
+	client       *client.Client
+	kubelets     []*kubelet.Kubelet
+	// runtimes are the fake runtimes of the kubelets, in order; empty with RuntimeDocker
+	runtimes []*fakeruntime.FakeRuntime
+	// stop ends the controller and the scheduler
+	stop context.CancelFunc
+}
+
+// ForTest starts a cluster for the test, failing it if the cluster does not start, and cleans the cluster up
+// once the test is over
+func ForTest(t testing.TB, options Options) *Cluster {
+	t.Helper()
+	c, err := Start(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := c.Cleanup(); err != nil {
+			t.Errorf("Failed to clean up cluster: %v", err)
+		}
+	})
+	return c
+}
+
+// Start starts a cluster and waits until all of its nodes are ready. Whatever was started is cleaned up
+// again if that fails.
+func Start(options Options) (*Cluster, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	c := &Cluster{options: options}
+	if err := c.start(); err != nil {
+		if cleanupErr := c.Cleanup(); cleanupErr != nil {
+			c.logger().Error("Failed to clean up the cluster that did not start", logging.Err(cleanupErr))
+		}
+		return nil, fmt.Errorf("%w: %w", ErrStartFailed, err)
+	}
+	return c, nil
+}
+
+func (c *Cluster) start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.StartTimeout)
+	defer cancel()
+
+	etcdServer, port, err := storage.StartEmbeddedEtcd()
+	if err != nil {
+		return fmt.Errorf("failed to start embedded etcd: %w", err)
+	}
+	c.etcdServer = etcdServer
+	c.etcdURL = fmt.Sprintf("http://127.0.0.1:%d", port)
+	c.etcdClient, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{c.etcdURL},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	c.storage = storage.NewEtcdStorage(c.etcdClient)
+	c.serving = c.storage
+	if c.options.WrapStorage != nil {
+		c.serving = c.options.WrapStorage(c.storage)
+	}
+
+	if err := c.startAPIServer(ctx); err != nil {
+		return err
+	}
+	if err := c.startControlPlane(ctx); err != nil {
+		return err
+	}
+	return c.startKubelets(ctx)
+}
+
+// startAPIServer serves the API on a free port of the loopback interface
+func (c *Cluster) startAPIServer(ctx context.Context) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to listen for the API server: %w", err)
+	}
+	apiServer := server.NewAPIServer(c.serving)
+	apiServer.SetLogger(c.logger())
+	if err := apiServer.EnsureDefaultNamespace(ctx); err != nil {
+		return err
+	}
+	if err := apiServer.MigrateLegacyReplicaSets(ctx); err != nil {
+		return err
+	}
+	c.apiServer = &http.Server{Handler: apiServer.Handler()}
+	go func() {
+		if err := c.apiServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.logger().Error("API server stopped", logging.Err(err))
+		}
+	}()
+	c.apiServerURL = "http://" + listener.Addr().String()
+
+	c.client, err = client.New(c.apiServerURL, client.Options{})
+	if err != nil {
+		return err
+	}
+	return poll(ctx, "the API server to be ready", func() (bool, error) {
+		return c.client.Healthz(ctx) == nil, nil
+	})
+}
+
+// startControlPlane runs the ReplicaSet controller, reading pods through an informer, the namespace,
+// endpoints and event GC controllers and the scheduler
+func (c *Cluster) startControlPlane(ctx context.Context) error {
+	namespaceRegistry := registry.NewNamespaceRegistry(c.serving)
+	quotaRegistry := registry.NewResourceQuotaRegistry(c.serving)
+	limitRangeRegistry := registry.NewLimitRangeRegistry(c.serving)
+	serviceRegistry := registry.NewServiceRegistry(c.serving)
+	podRegistry := registry.NewPodRegistry(c.serving)
+	podRegistry.SetNamespaceRegistry(namespaceRegistry)
+	podRegistry.SetResourceQuotaRegistry(quotaRegistry)
+	podRegistry.SetLimitRangeRegistry(limitRangeRegistry)
+	rsRegistry := registry.NewReplicaSetRegistry(c.serving)
+	rsRegistry.SetNamespaceRegistry(namespaceRegistry)
+	rsController, err := controller.NewReplicaSetControllerWithOptions(rsRegistry, podRegistry, controller.Options{
+		ResyncPeriod: c.options.ResyncPeriod,
+		Workers:      controller.DefaultWorkers,
+	})
+	if err != nil {
+		return err
+	}
+	rsController.SetLogger(c.logger())
+
+	runCtx, stop := context.WithCancel(context.Background())
+	c.stop = stop
+	factory := informers.NewSharedInformerFactory(c.client, c.options.ResyncPeriod)
+	rsController.UsePodInformer(factory.Pods())
+	factory.Start(runCtx)
+	if !factory.WaitForCacheSync(ctx) {
+		return fmt.Errorf("timed out waiting for the pod informer to sync: %w", ctx.Err())
+	}
+	go rsController.Start(runCtx)
+
+	namespaceController := controller.NewNamespaceController(namespaceRegistry, rsRegistry, podRegistry, quotaRegistry, limitRangeRegistry, serviceRegistry, c.options.ResyncPeriod)
+	namespaceController.SetLogger(c.logger())
+	go namespaceController.Start(runCtx)
+
+	endpointsController := controller.NewEndpointsController(serviceRegistry, registry.NewEndpointsRegistry(c.serving), podRegistry,
+		registry.NewNodeRegistry(c.serving), c.options.ResyncPeriod)
+	endpointsController.SetLogger(c.logger())
+	go endpointsController.Start(runCtx)
+
+	eventTTL := c.options.EventTTL
+	if eventTTL == 0 {
+		eventTTL = controller.DefaultEventTTL
+	}
+	eventGCController := controller.NewEventGCController(registry.NewEventRegistry(c.serving), eventTTL, c.options.ResyncPeriod)
+	eventGCController.SetLogger(c.logger())
+	go eventGCController.Start(runCtx)
+
+	nodeController := controller.NewNodeController(registry.NewNodeRegistry(c.serving), podRegistry, controller.DefaultNodeMonitorGracePeriod,
+		controller.DefaultDeadNodeTimeout, c.options.ResyncPeriod)
+	nodeController.SetLeaseRegistry(registry.NewNodeLeaseRegistry(c.serving))
+	nodeController.SetLogger(c.logger())
+	go nodeController.Start(runCtx)
+
+	podIndexController := controller.NewPodIndexController(podRegistry, controller.DefaultPodIndexResyncPeriod)
+	podIndexController.SetLogger(c.logger())
+	go podIndexController.Start(runCtx)
+
+	sched := scheduler.NewScheduler(podRegistry, registry.NewNodeRegistry(c.serving), c.options.SchedulingRate)
+	sched.SetLogger(c.logger())
+	go sched.Start(runCtx)
+	return nil
+}
+
+// startKubelets starts a kubelet per node and waits for all nodes to be ready
+func (c *Cluster) startKubelets(ctx context.Context) error {
+	errs := make(chan error, c.options.Kubelets)
+	for i := range c.options.Kubelets {
+		nodeName := fmt.Sprintf("node-%d", i)
+		options, err := c.kubeletOptions()
+		if err != nil {
+			return fmt.Errorf("failed to configure kubelet %s: %w", nodeName, err)
+		}
+		k, err := c.newKubelet(nodeName, options)
+		if err != nil {
+			return fmt.Errorf("failed to create kubelet %s: %w", nodeName, err)
+		}
+		k.SetLogger(c.logger())
+		c.kubelets = append(c.kubelets, k)
+		go func() {
+			if err := k.Start(); err != nil {
+				errs <- fmt.Errorf("failed to start kubelet %s: %w", nodeName, err)
+			}
+		}()
+	}
+
+	return poll(ctx, fmt.Sprintf("%d nodes to be ready", c.options.Kubelets), func() (bool, error) {
+		select {
+		case err := <-errs:
+			return false, err
+		default:
+		}
+		nodes, err := c.client.Nodes().List(ctx)
+		if err != nil {
+			return false, nil
+		}
+		ready := 0
+		for _, node := range nodes {
+			if node.Status == api.NodeReady {
+				ready++
+			}
+		}
+		return ready == c.options.Kubelets, nil
+	})
+}
+
+// logger returns the logger of the components
+func (c *Cluster) logger() *slog.Logger {
+	if c.options.Logger == nil {
+		return slog.Default()
+	}
+	return c.options.Logger
+}
+
+func (c *Cluster) newKubelet(nodeName string, options kubelet.Options) (*kubelet.Kubelet, error) {
+	if c.options.Runtime == RuntimeDocker {
+		return kubelet.NewKubeletWithOptions(nodeName, c.apiServerURL, options)
+	}
+	runtime := fakeruntime.New()
+	c.runtimes = append(c.runtimes, runtime)
+	return kubelet.NewKubeletWithRuntime(nodeName, c.apiServerURL, options, runtime)
+}
+
+// kubeletOptions shortens the kubelet intervals to KubeletInterval, and the node leases to the shortest
+// duration so the lease of a stopped kubelet expires within seconds, and serves the kubelet API on a free port
+func (c *Cluster) kubeletOptions() (kubelet.Options, error) {
+	port, err := storage.PickAvailableRandomPort()
+	if err != nil {
+		return kubelet.Options{}, err
+	}
+
+	options := kubelet.DefaultOptions()
+	options.NodeStatusUpdateFrequency = c.options.KubeletInterval
+	options.NodeStatusReportFrequency = c.options.KubeletInterval
+	options.NodeLeaseDurationSeconds = api.MinLeaseDurationSeconds
+	options.RelistPeriod = c.options.KubeletInterval
+	options.SyncInterval = c.options.KubeletInterval
+	options.StatusUpdateInterval = 5 * c.options.KubeletInterval
+	options.Address = "127.0.0.1"
+	options.Port = port
+	return options, nil
+}
+
+// Cleanup removes the containers the kubelets started and stops the kubelets, the controller, the scheduler,
+// the API server and etcd, in that order. It can be called on a cluster that only partly started.
+func (c *Cluster) Cleanup() error {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, k := range c.kubelets {
+		// The kubelets need the API server to tell which containers are theirs
+		if err := k.CleanupContainers(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove the containers of %s: %w", k.GetNodeName(), err))
+		}
+		if err := k.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop kubelet %s: %w", k.GetNodeName(), err))
+		}
+	}
+	c.kubelets = nil
+	if c.stop != nil {
+		c.stop()
+	}
+	if c.apiServer != nil {
+		if err := c.apiServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop the API server: %w", err))
+		}
+		c.apiServer = nil
+	}
+	if c.etcdClient != nil {
+		if err := c.etcdClient.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close the etcd client: %w", err))
+		}
+		c.etcdClient = nil
+	}
+	if c.etcdServer != nil {
+		storage.StopEmbeddedEtcd(c.etcdServer)
+		c.etcdServer = nil
+	}
+	return errors.Join(errs...)
+}
+
+// Client returns a client of the API server
+func (c *Cluster) Client() *client.Client {
+	return c.client
+}
+
+// APIServerURL returns the URL of the API server, e.g. for gokubectl --server
+func (c *Cluster) APIServerURL() string {
+	return c.apiServerURL
+}
+
+// EtcdURL returns the client URL of etcd
+func (c *Cluster) EtcdURL() string {
+	return c.etcdURL
+}
+
+// EtcdClient returns the client of etcd the API server and the registries use
+func (c *Cluster) EtcdClient() *clientv3.Client {
+	return c.etcdClient
+}
+
+// Storage returns the etcd storage of the cluster, without the WrapStorage wrapper
+func (c *Cluster) Storage() *storage.EtcdStorage {
+	return c.storage
+}
+
+// PodRegistry returns a registry of the pods, bypassing the API server and WrapStorage
+func (c *Cluster) PodRegistry() *registry.PodRegistry {
+	return registry.NewPodRegistry(c.storage)
+}
+
+// NodeRegistry returns a registry of the nodes, bypassing the API server and WrapStorage
+func (c *Cluster) NodeRegistry() *registry.NodeRegistry {
+	return registry.NewNodeRegistry(c.storage)
+}
+
+// ReplicaSetRegistry returns a registry of the ReplicaSets, bypassing the API server and WrapStorage
+func (c *Cluster) ReplicaSetRegistry() *registry.ReplicaSetRegistry {
+	return registry.NewReplicaSetRegistry(c.storage)
+}
+
+// Kubelets returns the kubelets, in node order
+func (c *Cluster) Kubelets() []*kubelet.Kubelet {
+	return c.kubelets
+}
+
+// Runtime returns the fake container runtime of the kubelet of the i-th node, e.g. to make its containers
+// exit. It returns nil with RuntimeDocker.
+func (c *Cluster) Runtime(i int) *fakeruntime.FakeRuntime {
+	if i >= len(c.runtimes) {
+		return nil
+	}
+	return c.runtimes[i]
+}
+
+// poll calls check every 100ms until it reports done or fails, giving up once ctx is done
+func poll(ctx context.Context, what string, check func() (bool, error)) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s: %w", what, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
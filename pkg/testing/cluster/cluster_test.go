@@ -0,0 +1,244 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gokube/pkg/api"
+	"gokube/pkg/client"
+	"gokube/pkg/logging"
+	"gokube/pkg/registry"
+	"gokube/pkg/storage"
+)
+
+func TestClusterRunsPodsAndCleansUp(t *testing.T) {
+	options := DefaultOptions()
+	options.Kubelets = 2
+	c, err := Start(options)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	nodes, err := c.Client().Nodes().List(ctx)
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+	for _, node := range nodes {
+		assert.Equal(t, api.NodeReady, node.Status, node.Name)
+	}
+	require.Len(t, c.Kubelets(), 2)
+	require.NotNil(t, c.Runtime(1))
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+		NodeName:   "node-1",
+		Status:     api.PodScheduled,
+	}
+	_, err = c.Client().Pods().Create(ctx, pod)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		pod, err := c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+		return err == nil && pod.Status == api.PodRunning
+	}, 10*time.Second, 50*time.Millisecond)
+
+	apiClient := c.Client()
+	require.NoError(t, c.Cleanup())
+	assert.ErrorIs(t, apiClient.Healthz(ctx), client.ErrUnreachable, "the API server is stopped")
+	assert.NoError(t, c.Cleanup(), "cleaning up twice is harmless")
+}
+
+func TestClusterWithFlakyStorage(t *testing.T) {
+	options := DefaultOptions()
+	options.Kubelets = 1
+	var chaos *storage.ChaosStorage
+	options.WrapStorage = func(inner storage.Storage) storage.Storage {
+		chaos = storage.NewChaosStorage(inner, nil)
+		return chaos
+	}
+	c := ForTest(t, options)
+	ctx := context.Background()
+
+	// The first status updates of the kubelet fail
+	failure := storage.Fault{Err: storage.ErrEtcdClient}
+	chaos.SetPolicy(storage.Script(storage.Match{Op: storage.OpUpdate, Prefix: "/pods/"}, failure, failure))
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+		NodeName:   "node-0",
+		Status:     api.PodScheduled,
+	}
+	_, err := c.Client().Pods().Create(ctx, pod)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		pod, err := c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+		return err == nil && pod.Status == api.PodRunning
+	}, 20*time.Second, 50*time.Millisecond)
+
+	injected := 0
+	for _, call := range chaos.Calls() {
+		if call.Injected {
+			injected++
+		}
+	}
+	assert.Equal(t, 2, injected, "the status updates failed on the way")
+}
+
+func TestClusterExpiresLeaseOfStoppedKubelet(t *testing.T) {
+	options := DefaultOptions()
+	options.Kubelets = 1
+	c := ForTest(t, options)
+	ctx := context.Background()
+
+	require.Eventually(t, func() bool {
+		_, err := c.Client().NodeLeases().Get(ctx, "node-0")
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// The lease of a stopped kubelet expires long before its status would time out
+	require.NoError(t, c.Kubelets()[0].Stop(ctx))
+	require.Eventually(t, func() bool {
+		_, err := c.Client().NodeLeases().Get(ctx, "node-0")
+		return client.IsNotFound(err)
+	}, 10*time.Second, 100*time.Millisecond)
+	node, err := c.NodeRegistry().GetNode(ctx, "node-0")
+	require.NoError(t, err)
+	assert.Equal(t, api.NodeReady, node.Status)
+}
+
+func TestClusterDeletesPodAfterItsFinalizers(t *testing.T) {
+	options := DefaultOptions()
+	options.Kubelets = 1
+	c := ForTest(t, options)
+	ctx := context.Background()
+
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web", Finalizers: []string{"example.com/backup"}},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+		NodeName:   "node-0",
+		Status:     api.PodScheduled,
+	}
+	_, err := c.Client().Pods().Create(ctx, pod)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		pod, err := c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+		return err == nil && pod.Status == api.PodRunning
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NotEmpty(t, c.Runtime(0).Running("web"))
+
+	require.NoError(t, c.Client().Pods().Delete(ctx, "web"))
+	deleting, err := c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+	require.NoError(t, err, "the pod is kept while it has finalizers")
+	assert.NotNil(t, deleting.DeletionTimestamp)
+	assert.Equal(t, api.PodTerminating, deleting.Status)
+
+	// The kubelet stops the containers and removes its finalizer, the one of the user keeps the pod
+	require.Eventually(t, func() bool {
+		pod, err := c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+		return err == nil && len(pod.Finalizers) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Empty(t, c.Runtime(0).Running("web"))
+	deleting, err = c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com/backup"}, deleting.Finalizers)
+
+	_, err = c.Client().Pods().UpdateStatus(ctx, &api.PodStatusUpdate{
+		Name:             "web",
+		Status:           api.PodTerminating,
+		RemoveFinalizers: []string{"example.com/backup"},
+	})
+	require.NoError(t, err)
+	_, err = c.PodRegistry().GetPod(ctx, api.DefaultNamespace, "web")
+	assert.ErrorIs(t, err, registry.ErrPodNotFound, "the pod is removed with its last finalizer")
+}
+
+func TestClusterTracesRequests(t *testing.T) {
+	logs := &lockedBuffer{}
+	logger, err := logging.New(logs, logging.Options{Level: "debug", Format: logging.FormatText})
+	require.NoError(t, err)
+	options := DefaultOptions()
+	options.Kubelets = 1
+	options.Logger = logger
+	c := ForTest(t, options)
+
+	// A pod bound to a node is traced from its creation to its status reports
+	pod := &api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "web"},
+		Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+		NodeName:   "node-0",
+		Status:     api.PodScheduled,
+	}
+	_, err = c.Client().Pods().Create(logging.WithRequestID(context.Background(), "trace-web"), pod)
+	require.NoError(t, err)
+
+	// The pods of a ReplicaSet are traced with the request that created it, through the scheduler
+	rs := &api.ReplicaSet{
+		ObjectMeta: api.ObjectMeta{Name: "app"},
+		Spec: api.ReplicaSetSpec{
+			Replicas: 1,
+			Selector: map[string]string{"app": "app"},
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{Labels: map[string]string{"app": "app"}},
+				Spec:       api.PodSpec{Containers: []api.Container{{Name: "nginx", Image: "nginx:latest"}}},
+			},
+		},
+	}
+	_, err = c.Client().ReplicaSets().Create(logging.WithRequestID(context.Background(), "trace-app"), rs)
+	require.NoError(t, err)
+
+	steps := []string{
+		`msg="Served request" component=apiserver method=POST path=/api/v1/pods status=201 .*requestID=trace-web`,
+		`msg="Running pod" component=kubelet node=node-0 pod=web .*requestID=trace-web`,
+		`msg="Served request" component=apiserver method=PUT path=/api/v1/namespaces/default/pods/web/status status=200 .*requestID=trace-web`,
+		`msg="Served request" component=apiserver method=POST path=/api/v1/replicasets status=201 .*requestID=trace-app`,
+		`msg="Created pod" component=controller replicaSet=app replicaSetUID=\S+ .*requestID=trace-app operationID=`,
+		`msg="Scheduling pod" component=scheduler pod=app\S+ .*requestID=trace-app operationID=`,
+	}
+	for _, step := range steps {
+		assert.Eventually(t, func() bool {
+			return regexp.MustCompile(step).MatchString(logs.String())
+		}, 10*time.Second, 50*time.Millisecond, "no log line matches %s", step)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	options := DefaultOptions()
+	require.NoError(t, options.Validate())
+
+	options.Runtime = "podman"
+	assert.ErrorIs(t, options.Validate(), ErrInvalidOptions)
+
+	options = DefaultOptions()
+	options.EventTTL = -time.Second
+	assert.ErrorIs(t, options.Validate(), ErrInvalidOptions)
+
+	options = DefaultOptions()
+	options.Kubelets = -1
+	assert.ErrorIs(t, options.Validate(), ErrInvalidOptions)
+
+	_, err := Start(options)
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+}
+
+// lockedBuffer is a bytes.Buffer the components can log to while the test reads it
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}